@@ -0,0 +1,164 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\audit\audit.go
+ * @Description: 配置安全审计 - 只加载配置不启动服务，按规则检查管理接口认证、pprof 暴露、CORS、
+ *               明文密钥、TLS 与限流配置，输出按严重级别排序的问题列表，供流水线按级别阻断发布
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package audit
+
+import (
+	"sort"
+	"strings"
+
+	goconfig "github.com/kamalyes/go-config"
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-toolbox/pkg/safe"
+)
+
+// Severity 问题严重级别
+type Severity string
+
+// 严重级别，由低到高
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// severityRanks 严重级别的排序值
+var severityRanks = map[Severity]int{
+	SeverityInfo:     1,
+	SeverityLow:      2,
+	SeverityMedium:   3,
+	SeverityHigh:     4,
+	SeverityCritical: 5,
+}
+
+// Rank 严重级别的排序值，未知级别为 0
+func (s Severity) Rank() int {
+	return severityRanks[s]
+}
+
+// ParseSeverity 解析严重级别（不区分大小写）
+func ParseSeverity(s string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(s)))
+	if severity.Rank() == 0 {
+		return "", errors.NewErrorf(errors.ErrCodeInvalidParameter, "audit: unknown severity %q", s)
+	}
+	return severity, nil
+}
+
+// DefaultMaxRequestsPerSecond 单条限流规则每秒请求数超过该值视为过于宽松
+const DefaultMaxRequestsPerSecond = 10000
+
+// Finding 一条审计问题
+type Finding struct {
+	Rule     string   `json:"rule"`     // 规则名，如 pprof-exposed
+	Severity Severity `json:"severity"` // 严重级别，生产环境部分规则会提高一级
+	Key      string   `json:"key"`      // 对应的配置项，如 middleware.pprof.authentication
+	Message  string   `json:"message"`  // 问题描述
+	Advice   string   `json:"advice"`   // 修复建议
+}
+
+// Report 审计报告
+type Report struct {
+	Environment string    `json:"environment"`
+	Production  bool      `json:"production"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Count 严重级别不低于 min 的问题数
+func (r *Report) Count(min Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity.Rank() >= min.Rank() {
+			n++
+		}
+	}
+	return n
+}
+
+// Input 审计输入
+type Input struct {
+	Config      *gwconfig.Gateway // 合并默认值后的网关配置
+	Raw         map[string]any    // 配置文件原始内容，明文密钥与顶层 routes、features 段从这里读取
+	Environment string            // 审计时假定的运行环境
+	// MaxRequestsPerSecond 限流规则每秒请求数上限，超过视为过于宽松，0 使用 DefaultMaxRequestsPerSecond
+	MaxRequestsPerSecond int
+}
+
+// Load 按网关启动时的方式加载配置文件（不启动热更新），返回审计输入；env 为空时使用 APP_ENV 等环境变量决定的环境
+func Load(path string, env string) (*Input, error) {
+	config := gwconfig.Default()
+	builder := goconfig.NewManager(config).WithConfigPath(path)
+	if env != "" {
+		builder = builder.WithEnvironment(goconfig.EnvironmentType(env))
+	}
+	manager, err := builder.Build()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+	if err := goconfig.UnmarshalWithFlexibleNaming(manager.GetViper(), config); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+	return &Input{
+		Config:      safe.MergeWithDefaults(config, gwconfig.Default()),
+		Raw:         manager.GetViper().AllSettings(),
+		Environment: string(manager.GetEnvironment()),
+	}, nil
+}
+
+// Run 执行全部审计规则，问题按严重级别从高到低、同级按配置项排序
+func Run(in Input) *Report {
+	a := &auditor{in: in, maxRPS: in.MaxRequestsPerSecond}
+	if a.maxRPS <= 0 {
+		a.maxRPS = DefaultMaxRequestsPerSecond
+	}
+	if in.Config == nil {
+		a.in.Config = gwconfig.Default()
+	}
+	a.production = in.Environment == middleware.DefaultProductionEnvironment
+	if raw, ok := in.Raw[constants.ConfigKeyFeatures]; ok {
+		if features, err := middleware.ParseFeatures(raw); err == nil {
+			a.production = features.IsProduction(in.Environment)
+		}
+	}
+
+	for _, check := range rules {
+		check(a)
+	}
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		if a.findings[i].Severity != a.findings[j].Severity {
+			return a.findings[i].Severity.Rank() > a.findings[j].Severity.Rank()
+		}
+		return a.findings[i].Key < a.findings[j].Key
+	})
+	return &Report{Environment: in.Environment, Production: a.production, Findings: a.findings}
+}
+
+// auditor 一次审计的状态
+type auditor struct {
+	in         Input
+	production bool
+	maxRPS     int
+	findings   []Finding
+}
+
+// add 记录问题；prod 为生产环境下使用的级别
+func (a *auditor) add(rule string, severity, prod Severity, key, message, advice string) {
+	if a.production {
+		severity = prod
+	}
+	a.findings = append(a.findings, Finding{Rule: rule, Severity: severity, Key: key, Message: message, Advice: advice})
+}
@@ -0,0 +1,310 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\audit\rules.go
+ * @Description: 审计规则 - 每条规则只读取配置并记录问题；同一问题在生产环境按更高级别报告
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package audit
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/redact"
+)
+
+// rules 按顺序执行的审计规则
+var rules = []func(*auditor){
+	(*auditor).checkAdminRoutes,
+	(*auditor).checkPProf,
+	(*auditor).checkSwagger,
+	(*auditor).checkCORS,
+	(*auditor).checkSecrets,
+	(*auditor).checkTLS,
+	(*auditor).checkRateLimit,
+}
+
+// adminPathPrefixes 视为管理 / 诊断接口的路径前缀
+var adminPathPrefixes = []string{"/admin", "/debug", "/internal"}
+
+// 框架内置的默认密钥，未修改直接使用等同于公开密钥
+var defaultSecrets = []string{
+	"go-config-default-key",
+	"jwt_secret_key_please_change_in_production",
+	"csrf-secret",
+}
+
+// minSecretLength HMAC 类签名密钥的最小长度
+const minSecretLength = 32
+
+// isAdminPath 路径是否属于管理 / 诊断接口
+func isAdminPath(path string) bool {
+	path = strings.TrimSuffix(path, "*")
+	for _, prefix := range adminPathPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// configAuthEnabled 配置层是否启用了通用认证
+func (a *auditor) configAuthEnabled() bool {
+	sec := a.in.Config.Security
+	return sec != nil && sec.Auth != nil && sec.Auth.Enabled
+}
+
+// checkAdminRoutes 声明式路由中的管理 / 诊断路径必须声明认证要求；
+// 改写试算等管理接口依赖代码注册的认证中间件，配置层未启用认证时提示确认
+func (a *auditor) checkAdminRoutes() {
+	raw, ok := a.in.Raw[constants.ConfigKeyRoutes]
+	if !ok {
+		return
+	}
+	routes, err := middleware.ParseDeclarativeRoutes(raw)
+	if err != nil {
+		a.add("config-invalid", SeverityHigh, SeverityHigh, constants.ConfigKeyRoutes,
+			fmt.Sprintf("routes 段无法解析，网关将拒绝启动: %v", err), "按 docs/SERVER.md 修正 routes 段")
+		return
+	}
+
+	for i, rule := range routes.Rules {
+		auth := rule.Auth
+		if auth != nil && (auth.Required || len(auth.Roles) > 0 || len(auth.Scopes) > 0) {
+			continue
+		}
+		for _, path := range rule.Paths {
+			if !isAdminPath(path) {
+				continue
+			}
+			a.add("admin-route-unauthenticated", SeverityHigh, SeverityCritical,
+				fmt.Sprintf("routes.rules[%d]", i),
+				fmt.Sprintf("路由 %q 暴露管理路径 %s 但未声明认证要求", rule.Name, path),
+				"为该路由配置 auth.roles 或 auth.required，或移到仅内网可达的监听器")
+			break
+		}
+	}
+
+	if routes.RewriteTestPath != "" && !a.configAuthEnabled() {
+		a.add("admin-endpoint-auth", SeverityLow, SeverityMedium, "routes.rewrite-test-path",
+			fmt.Sprintf("改写试算接口 %s 已注册，配置中未启用 security.auth", routes.RewriteTestPath),
+			"确认该路径由 WithAuthentication / Casbin 等中间件保护，或置空关闭")
+	}
+}
+
+// checkPProf pprof 启用时必须限制来源 IP，并建议要求令牌
+func (a *auditor) checkPProf() {
+	mw := a.in.Config.Middleware
+	if mw == nil || mw.PProf == nil || !mw.PProf.Enabled {
+		return
+	}
+	auth := mw.PProf.Authentication
+	restricted := auth != nil && len(auth.AllowedIPs) > 0 && !slices.Contains(auth.AllowedIPs, "0.0.0.0/0")
+	tokenRequired := auth != nil && auth.Enabled && auth.RequireAuth && auth.AuthToken != ""
+
+	switch {
+	case !restricted && !tokenRequired:
+		a.add("pprof-exposed", SeverityHigh, SeverityCritical, "middleware.pprof.authentication",
+			"pprof 已启用，既未限制来源 IP 也未要求令牌，任何人都可以抓取堆栈与内存内容",
+			"配置 allowed-ips 为运维网段并启用 require-auth，生产环境建议通过 features 矩阵关闭 pprof")
+	case !restricted:
+		a.add("pprof-exposed", SeverityMedium, SeverityHigh, "middleware.pprof.authentication.allowed-ips",
+			"pprof 仅靠令牌保护，未限制来源 IP", "配置 allowed-ips 为运维网段")
+	case a.production:
+		a.add("pprof-enabled", SeverityInfo, SeverityLow, "middleware.pprof.enabled",
+			"生产环境启用了 pprof（已限制来源 IP）", "确认确有需要，或通过 features 矩阵按环境关闭")
+	}
+}
+
+// checkSwagger 生产环境不建议公开 API 文档
+func (a *auditor) checkSwagger() {
+	if !a.production || a.in.Config.Swagger == nil || !a.in.Config.Swagger.Enabled {
+		return
+	}
+	sec := a.in.Config.Security
+	if sec != nil && sec.Protection != nil && sec.Protection.Swagger != nil &&
+		sec.Protection.Swagger.Enabled && (sec.Protection.Swagger.AuthRequired || len(sec.Protection.Swagger.IPWhitelist) > 0) {
+		return
+	}
+	a.add("swagger-exposed", SeverityLow, SeverityMedium, "swagger.enabled",
+		"生产环境公开了 Swagger 文档，接口清单与参数可被枚举",
+		"通过 features 矩阵关闭 swagger，或配置 security.protection.swagger")
+}
+
+// checkCORS 允许任意来源时不能同时允许携带凭证
+func (a *auditor) checkCORS() {
+	c := a.in.Config.CORS
+	if c == nil || !c.Enabled {
+		return
+	}
+	anyOrigin := c.AllowedAllOrigins || slices.Contains(c.AllowedOrigins, "*")
+	switch {
+	case anyOrigin && c.AllowCredentials:
+		a.add("cors-credentials", SeverityHigh, SeverityCritical, "cors.allow-credentials",
+			"CORS 允许任意来源且允许携带凭证，任意站点都能以用户身份调用接口",
+			"把 allowed-origins 限定为受信任的域名，或关闭 allow-credentials")
+	case anyOrigin:
+		a.add("cors-any-origin", SeverityInfo, SeverityLow, "cors.allowed-origins",
+			"CORS 允许任意来源", "公开 API 之外建议限定 allowed-origins")
+	}
+}
+
+// checkSecrets 配置文件中的明文密钥与未修改的默认密钥
+func (a *auditor) checkSecrets() {
+	var keys []string
+	walkSecrets(a.in.Raw, "", func(key string) { keys = append(keys, key) })
+	sort.Strings(keys)
+	for _, key := range keys {
+		a.add("plaintext-secret", SeverityMedium, SeverityHigh, key,
+			"配置文件中包含明文密钥", "改为 ${ENV_VAR} 引用或从密钥管理服务注入")
+	}
+
+	cfg := a.in.Config
+	if sec := cfg.Security; sec != nil && sec.JWT != nil && sec.JWT.Enabled {
+		a.checkSigningKey("security.jwt.secret", sec.JWT.Secret)
+	}
+	if _, ok := a.in.Raw["jwt"]; ok && cfg.JWT != nil {
+		a.checkSigningKey("jwt.signing-key", cfg.JWT.SigningKey)
+	}
+}
+
+// checkSigningKey 签名密钥不能是框架默认值或过短
+func (a *auditor) checkSigningKey(key, secret string) {
+	switch {
+	case slices.Contains(defaultSecrets, secret):
+		a.add("default-secret", SeverityHigh, SeverityCritical, key,
+			"签名密钥仍是框架默认值，任何人都可以伪造令牌", "生成随机密钥并通过环境变量注入")
+	case len(secret) < minSecretLength:
+		a.add("weak-secret", SeverityMedium, SeverityHigh, key,
+			fmt.Sprintf("签名密钥只有 %d 个字符", len(secret)),
+			fmt.Sprintf("使用至少 %d 个字符的随机密钥", minSecretLength))
+	}
+}
+
+// isSecretLiteral 值是否为明文：环境变量引用、文件路径与空值不算
+func isSecretLiteral(value string) bool {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return false
+	case strings.Contains(value, "${"):
+		return false
+	case strings.HasPrefix(value, "/"), strings.HasPrefix(value, "./"), strings.HasPrefix(value, "file:"),
+		strings.HasPrefix(value, "env:"), strings.HasPrefix(value, "vault:"):
+		return false
+	}
+	return true
+}
+
+// walkSecrets 遍历原始配置，对值为明文的密钥类配置项调用 found
+func walkSecrets(node any, key string, found func(key string)) {
+	switch v := node.(type) {
+	case map[string]any:
+		for k, child := range v {
+			walkSecrets(child, joinKey(key, k), found)
+		}
+	case []any:
+		if !redact.IsSecretKey(lastSegment(key)) {
+			for i, child := range v {
+				walkSecrets(child, fmt.Sprintf("%s[%d]", key, i), found)
+			}
+			return
+		}
+		for _, child := range v {
+			if s, ok := child.(string); ok && isSecretLiteral(s) {
+				found(key)
+				return
+			}
+		}
+	case string:
+		if redact.IsSecretKey(lastSegment(key)) && isSecretLiteral(v) {
+			found(key)
+		}
+	}
+}
+
+// joinKey 拼接配置键
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// lastSegment 配置键最后一段（去掉数组下标）
+func lastSegment(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+// checkTLS 主监听器应启用 TLS，且不能使用过旧的协议版本或跳过证书校验
+func (a *auditor) checkTLS() {
+	h := a.in.Config.HTTPServer
+	if h == nil {
+		return
+	}
+	if !h.EnableTls {
+		a.add("tls-missing", SeverityInfo, SeverityMedium, "http.enable-tls",
+			"HTTP 监听器未启用 TLS，凭证与令牌以明文传输", "启用 TLS，或确认前置负载均衡已终止 TLS 且内网链路可信")
+		return
+	}
+	if h.TLS == nil {
+		return
+	}
+	if h.TLS.MinVersion == gwconfig.TLSVersion10 || h.TLS.MinVersion == gwconfig.TLSVersion11 {
+		a.add("tls-legacy-version", SeverityMedium, SeverityHigh, "http.tls.min-version",
+			fmt.Sprintf("允许已废弃的 %s", h.TLS.MinVersion), "min-version 至少设为 TLS12")
+	}
+	if h.TLS.InsecureSkipVerify {
+		a.add("tls-insecure-skip-verify", SeverityMedium, SeverityHigh, "http.tls.insecure-skip-verify",
+			"关闭了证书校验", "仅在开发环境使用")
+	}
+}
+
+// checkRateLimit 限流未启用或规则过于宽松
+func (a *auditor) checkRateLimit() {
+	rl := a.in.Config.RateLimit
+	if rl == nil || !rl.Enabled {
+		a.add("rate-limit-disabled", SeverityInfo, SeverityMedium, "rate-limit.enabled",
+			"未启用限流", "至少配置 per-ip 的全局限流，防止单个来源耗尽后端")
+		return
+	}
+	if g := rl.GlobalLimit; g != nil {
+		a.checkLimit("rate-limit.global-limit", g.RequestsPerSecond, g.BurstSize)
+	}
+	for i, r := range rl.Routes {
+		if r.Limit != nil {
+			a.checkLimit(fmt.Sprintf("rate-limit.routes[%d]", i), r.Limit.RequestsPerSecond, r.Limit.BurstSize)
+		}
+	}
+}
+
+// checkLimit 单条限流规则：每秒请求数超过上限，或突发超过每秒请求数的 10 倍
+func (a *auditor) checkLimit(key string, rps, burst int) {
+	switch {
+	case rps <= 0:
+		a.add("rate-limit-permissive", SeverityLow, SeverityMedium, key,
+			"限流规则未设置 requests-per-second，等同于不限流", "设置合理的 requests-per-second")
+	case rps > a.maxRPS:
+		a.add("rate-limit-permissive", SeverityLow, SeverityMedium, key,
+			fmt.Sprintf("每秒 %d 次请求超过审计上限 %d", rps, a.maxRPS), "按后端容量下调，或调整审计上限 -max-rps")
+	case burst > rps*10:
+		a.add("rate-limit-permissive", SeverityLow, SeverityMedium, key,
+			fmt.Sprintf("突发 %d 超过每秒请求数 %d 的 10 倍", burst, rps), "burst-size 一般为 requests-per-second 的 1～2 倍")
+	}
+}
@@ -0,0 +1,275 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\benchmarks\benchmarks.go
+ * @Description: 端到端基准场景 - 真实 TCP 监听 + keep-alive 客户端并发压测（类 wrk），
+ *               覆盖普通路由、代理路由（HTTP → gRPC）、限流路由、grpc-gateway 本地路由
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-logger"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 场景名称
+const (
+	ScenarioPlainRoute       = "PlainRoute"
+	ScenarioProxiedRoute     = "ProxiedRoute"
+	ScenarioRateLimitedRoute = "RateLimitedRoute"
+	ScenarioGatewayRoute     = "GatewayRoute"
+)
+
+// Scenario 基准场景
+type Scenario struct {
+	Name  string
+	Path  string                               // 压测请求路径
+	Setup func() (http.Handler, func(), error) // 构建被测处理器，返回清理函数
+}
+
+// Scenarios 全部场景（按报告顺序）
+var Scenarios = []Scenario{
+	{Name: ScenarioPlainRoute, Path: "/api/ping", Setup: setupPlainRoute},
+	{Name: ScenarioProxiedRoute, Path: "/v1/health/bench", Setup: setupProxiedRoute},
+	{Name: ScenarioRateLimitedRoute, Path: "/api/limited", Setup: setupRateLimitedRoute},
+	{Name: ScenarioGatewayRoute, Path: "/v1/health/bench", Setup: setupGatewayRoute},
+}
+
+// scenarioEnv 已启动的场景环境（处理器 + HTTP 监听 + keep-alive 客户端）
+type scenarioEnv struct {
+	srv       *httptest.Server
+	transport *http.Transport
+	client    *http.Client
+	url       string
+	cleanup   func()
+}
+
+// close 关闭监听与后端
+func (e *scenarioEnv) close() {
+	e.transport.CloseIdleConnections()
+	e.srv.Close()
+	e.cleanup()
+}
+
+var (
+	envMu sync.Mutex
+	envs  = map[string]*scenarioEnv{}
+)
+
+// scenarioEnvFor 获取场景环境，首次调用时启动
+// testing 会以递增的 b.N 多次调用同一基准函数，环境只构建一次，避免重复注册路由规则与后端连接
+func scenarioEnvFor(sc Scenario) (*scenarioEnv, error) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if env, ok := envs[sc.Name]; ok {
+		return env, nil
+	}
+
+	handler, cleanup, err := sc.Setup()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{MaxIdleConnsPerHost: 256, MaxConnsPerHost: 256}
+	env := &scenarioEnv{
+		srv:       httptest.NewServer(handler),
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+		cleanup:   cleanup,
+	}
+	env.url = env.srv.URL + sc.Path
+
+	// 预热并校验场景可用
+	if err := doRequest(env.client, env.url); err != nil {
+		env.close()
+		return nil, err
+	}
+	envs[sc.Name] = env
+	return env, nil
+}
+
+// CloseScenarios 关闭所有已启动的场景环境
+func CloseScenarios() {
+	envMu.Lock()
+	defer envMu.Unlock()
+	for name, env := range envs {
+		env.close()
+		delete(envs, name)
+	}
+}
+
+// RunScenario 多个 keep-alive 客户端通过真实 HTTP 监听并发请求，供 go test -bench 与 Run 共用
+func RunScenario(b *testing.B, sc Scenario) {
+	env, err := scenarioEnvFor(sc)
+	if err != nil {
+		b.Fatalf("setup %s: %v", sc.Name, err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := doRequest(env.client, env.url); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// doRequest 发送 GET 请求并读完响应体，非 200 视为错误
+func doRequest(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+var initOnce sync.Once
+
+// initGlobals 基准运行所需的最小全局状态：静默日志与默认网关配置
+func initGlobals() {
+	initOnce.Do(func() {
+		if global.LOGGER == nil {
+			global.LOGGER = global.WrapLogger(logger.NewEmptyLogger())
+		}
+		if global.GATEWAY == nil {
+			global.GATEWAY = gwconfig.Default()
+		}
+	})
+}
+
+// baseChain 网关默认始终启用的中间件：Recovery、请求上下文、CORS
+func baseChain() *middleware.Chain {
+	cfg := global.GATEWAY
+	return middleware.NewChain().
+		Use(middleware.MiddlewareRecovery, middleware.PriorityRecovery, middleware.MiddlewareFunc(middleware.RecoveryMiddleware(cfg.Middleware.Recovery))).
+		Use(middleware.MiddlewareRequestContext, middleware.PriorityRequestContext, middleware.MiddlewareFunc(middleware.RequestContextMiddleware())).
+		Use(middleware.MiddlewareCORS, middleware.PriorityCORS, middleware.MiddlewareFunc(middleware.CORSMiddleware(cfg.CORS)))
+}
+
+// pingHandler 普通业务处理器
+func pingHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "pong"})
+}
+
+// setupPlainRoute 普通路由
+func setupPlainRoute() (http.Handler, func(), error) {
+	initGlobals()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ping", pingHandler)
+	return baseChain().Then(mux), func() {}, nil
+}
+
+// setupRateLimitedRoute 限流路由
+func setupRateLimitedRoute() (http.Handler, func(), error) {
+	initGlobals()
+	cfg := ratelimit.Default()
+	cfg.Enabled = true
+	cfg.Strategy = ratelimit.StrategyTokenBucket
+	cfg.Routes = []ratelimit.RouteLimit{{
+		Path:  "/api/limited",
+		Limit: &ratelimit.LimitRule{RequestsPerSecond: 1 << 30, BurstSize: 1 << 30},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/limited", pingHandler)
+	chain := baseChain().Use(middleware.MiddlewareRateLimit, middleware.PriorityRateLimit,
+		middleware.MiddlewareFunc(middleware.RateLimitMiddleware(cfg)))
+	return chain.Then(mux), func() {}, nil
+}
+
+// registerHealthRoute 在 grpc-gateway mux 上注册 GET /v1/health/{service}，由 check 完成调用
+func registerHealthRoute(mux *runtime.ServeMux, check func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error)) error {
+	return mux.HandlePath(http.MethodGet, "/v1/health/{service}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		resp, err := check(r.Context(), &healthpb.HealthCheckRequest{Service: params["service"]})
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, err)
+			return
+		}
+		runtime.ForwardResponseMessage(r.Context(), mux, outbound, w, r, resp)
+	})
+}
+
+// newHealthServer 后端服务实现（bench 服务状态为 SERVING）
+func newHealthServer() *health.Server {
+	hs := health.NewServer()
+	hs.SetServingStatus("bench", healthpb.HealthCheckResponse_SERVING)
+	return hs
+}
+
+// setupGatewayRoute grpc-gateway 本地路由（对应 RegisterXxxHandlerServer 模式）
+func setupGatewayRoute() (http.Handler, func(), error) {
+	initGlobals()
+	hs := newHealthServer()
+	mux := runtime.NewServeMux()
+	if err := registerHealthRoute(mux, hs.Check); err != nil {
+		return nil, nil, err
+	}
+	return baseChain().Then(mux), func() {}, nil
+}
+
+// setupProxiedRoute 代理路由（对应 RegisterXxxHandlerFromEndpoint 模式），后端 gRPC 服务监听本地 TCP
+func setupProxiedRoute() (http.Handler, func(), error) {
+	initGlobals()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, newHealthServer())
+	go func() { _ = grpcServer.Serve(lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		grpcServer.Stop()
+		return nil, nil, err
+	}
+	client := healthpb.NewHealthClient(conn)
+
+	mux := runtime.NewServeMux()
+	if err := registerHealthRoute(mux, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		return client.Check(ctx, req)
+	}); err != nil {
+		conn.Close()
+		grpcServer.Stop()
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		conn.Close()
+		grpcServer.Stop()
+	}
+	return baseChain().Then(mux), cleanup, nil
+}
@@ -0,0 +1,36 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\benchmarks\benchmarks_test.go
+ * @Description: 端到端场景的 go test -bench 入口，与 gateway-cli bench 运行同一组场景
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package benchmarks
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain 全部基准结束后关闭场景环境（监听与后端 gRPC 服务）
+func TestMain(m *testing.M) {
+	code := m.Run()
+	CloseScenarios()
+	os.Exit(code)
+}
+
+// BenchmarkPlainRoute 普通路由：网关中间件链 + JSON 响应
+func BenchmarkPlainRoute(b *testing.B) { RunScenario(b, Scenarios[0]) }
+
+// BenchmarkProxiedRoute 代理路由：grpc-gateway → TCP gRPC 客户端 → 后端 gRPC 服务
+func BenchmarkProxiedRoute(b *testing.B) { RunScenario(b, Scenarios[1]) }
+
+// BenchmarkRateLimitedRoute 限流路由：中间件链 + 令牌桶路由限流（额度足够大，只测判定开销）
+func BenchmarkRateLimitedRoute(b *testing.B) { RunScenario(b, Scenarios[2]) }
+
+// BenchmarkGatewayRoute grpc-gateway 本地路由：路径模板匹配 + 进程内调用服务实现 + protobuf JSON 编码
+func BenchmarkGatewayRoute(b *testing.B) { RunScenario(b, Scenarios[3]) }
@@ -0,0 +1,86 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\benchmarks\ratelimit_test.go
+ * @Description: 限流策略对比基准 - 直接调用各策略限流器的 Allow（内嵌存储，不经过 HTTP），
+ *               只测单次判定开销，额度足够大，不触发拒绝与排队
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/store"
+)
+
+// BenchmarkRateLimitStrategies 各限流策略的单次判定开销，1024 个 key 轮换
+//
+// 参考结果（linux/amd64，1 CPU，内嵌存储）：
+//
+//	策略             ns/op    B/op   allocs/op   状态
+//	token-bucket       945     128          6    进程内原子变量
+//	fixed-window       964     120          7    进程内原子变量
+//	leaky-bucket      1276     208         10    状态存储，每 key 一个整数
+//	gcra              1262     208         10    状态存储，每 key 一个整数
+//	sliding-window   13462    5866         24    状态存储，每 key 保存窗口内全部请求时间
+//
+// 漏桶与 GCRA 在内嵌存储上比进程内的令牌桶多一次存储锁与字符串编解码；
+// 使用 Redis 时三种存储策略都是一次 EVALSHA 往返，开销由网络延迟决定，
+// 滑动窗口还需要额外的 ZSET 读写与分布式锁，漏桶与 GCRA 只有一次 GET + SET
+func BenchmarkRateLimitStrategies(b *testing.B) {
+	initGlobals()
+	if global.STORE == nil {
+		memory, err := store.NewMemoryStore(nil)
+		if err != nil {
+			b.Fatalf("memory store: %v", err)
+		}
+		global.STORE = memory
+	}
+
+	cfg := ratelimit.Default()
+	fixedWindow := middleware.NewFixedWindowLimiter(cfg)
+	defer fixedWindow.Stop()
+	rule := &ratelimit.LimitRule{RequestsPerSecond: 1 << 30, BurstSize: 1 << 30, WindowSize: time.Second}
+	limiters := []struct {
+		strategy ratelimit.Strategy
+		limiter  middleware.RateLimiter
+	}{
+		{ratelimit.StrategyTokenBucket, middleware.NewTokenBucketLimiter(cfg)},
+		{ratelimit.StrategyFixedWindow, fixedWindow},
+		{ratelimit.StrategySlidingWindow, middleware.NewSlidingWindowLimiter(cfg)},
+		{ratelimit.StrategyLeakyBucket, middleware.NewLeakyBucketLimiter(cfg)},
+		{middleware.StrategyGCRA, middleware.NewGCRALimiter(cfg)},
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256)
+	}
+
+	for _, l := range limiters {
+		b.Run(string(l.strategy), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				i := 0
+				for pb.Next() {
+					if _, err := l.limiter.Allow(ctx, keys[i%len(keys)], rule); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
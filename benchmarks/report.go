@@ -0,0 +1,197 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\benchmarks\report.go
+ * @Description: 基准报告 - 运行场景生成 JSON 报告，并与基线报告对比延迟/分配回归（不依赖特定 CI）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package benchmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+// Result 单个场景的基准结果
+type Result struct {
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	OpsPerSec   float64 `json:"ops_per_sec"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+// Report 基准报告
+type Report struct {
+	Timestamp time.Time `json:"timestamp"`
+	Commit    string    `json:"commit,omitempty"`
+	GoVersion string    `json:"go_version"`
+	GOOS      string    `json:"goos"`
+	GOARCH    string    `json:"goarch"`
+	CPUs      int       `json:"cpus"`
+	Results   []Result  `json:"results"`
+}
+
+// DefaultBenchTime 每个场景的默认运行时长
+const DefaultBenchTime = 3 * time.Second
+
+// Run 运行名称匹配 filter 的场景（filter 为 nil 时运行全部），每个场景累计运行 benchtime（不大于 0 时取默认 3s）；
+// commit 为空时尝试读取构建信息中的 VCS 版本
+func Run(filter *regexp.Regexp, commit string, benchtime time.Duration) (*Report, error) {
+	if benchtime <= 0 {
+		benchtime = DefaultBenchTime
+	}
+	report := &Report{
+		Timestamp: time.Now().UTC(),
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		CPUs:      runtime.GOMAXPROCS(0),
+	}
+	if report.Commit == "" {
+		report.Commit = vcsRevision()
+	}
+	defer CloseScenarios()
+
+	for _, sc := range Scenarios {
+		if filter != nil && !filter.MatchString(sc.Name) {
+			continue
+		}
+		res, err := measure(sc, benchtime)
+		if err != nil {
+			return nil, err
+		}
+		nsPerOp := float64(res.T.Nanoseconds()) / float64(res.N)
+		report.Results = append(report.Results, Result{
+			Name:        sc.Name,
+			Iterations:  res.N,
+			NsPerOp:     nsPerOp,
+			OpsPerSec:   1e9 / nsPerOp,
+			BytesPerOp:  res.AllocedBytesPerOp(),
+			AllocsPerOp: res.AllocsPerOp(),
+		})
+	}
+	return report, nil
+}
+
+// measure 以 testing.Benchmark 反复运行场景并累计结果，直到总时长达到 benchtime（至少一轮）；
+// testing.Benchmark 单轮按 testing 包默认的 1s 调整 b.N，不依赖 -test.* flag
+func measure(sc Scenario, benchtime time.Duration) (testing.BenchmarkResult, error) {
+	var total testing.BenchmarkResult
+	for total.N == 0 || total.T < benchtime {
+		res := testing.Benchmark(func(b *testing.B) { RunScenario(b, sc) })
+		if res.N == 0 {
+			return total, fmt.Errorf("benchmark %s failed", sc.Name)
+		}
+		total.N += res.N
+		total.T += res.T
+		total.MemAllocs += res.MemAllocs
+		total.MemBytes += res.MemBytes
+	}
+	return total, nil
+}
+
+// vcsRevision 构建信息中的 VCS 版本（go build 时写入）
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// LoadReport 读取 JSON 报告
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// Save 写入 JSON 报告
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Thresholds 回归阈值（相对基线的最大增幅，0.1 表示 10%）
+type Thresholds struct {
+	Latency float64 // ns/op
+	Allocs  float64 // allocs/op 与 B/op
+}
+
+// DefaultThresholds 默认阈值：延迟 10%，分配 10%
+func DefaultThresholds() Thresholds {
+	return Thresholds{Latency: 0.10, Allocs: 0.10}
+}
+
+// Regression 超出阈值的指标
+type Regression struct {
+	Name     string  `json:"name"`
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Change   float64 `json:"change"` // 相对增幅
+}
+
+// String 回归描述
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s: %.0f → %.0f (+%.1f%%)", r.Name, r.Metric, r.Baseline, r.Current, r.Change*100)
+}
+
+// Compare 对比当前报告与基线，返回超出阈值的回归；基线中不存在的场景不参与对比
+// 分配指标至少增加 1 个单位才算回归，避免基线为 0 时的噪声
+func Compare(baseline, current *Report, th Thresholds) []Regression {
+	base := make(map[string]Result, len(baseline.Results))
+	for _, r := range baseline.Results {
+		base[r.Name] = r
+	}
+
+	var regressions []Regression
+	check := func(name, metric string, old, cur, limit, minDelta float64) {
+		if cur-old < minDelta || cur <= old*(1+limit) {
+			return
+		}
+		change := 1.0
+		if old > 0 {
+			change = cur/old - 1
+		}
+		regressions = append(regressions, Regression{Name: name, Metric: metric, Baseline: old, Current: cur, Change: change})
+	}
+
+	for _, cur := range current.Results {
+		old, ok := base[cur.Name]
+		if !ok {
+			continue
+		}
+		check(cur.Name, "ns/op", old.NsPerOp, cur.NsPerOp, th.Latency, 0)
+		check(cur.Name, "allocs/op", float64(old.AllocsPerOp), float64(cur.AllocsPerOp), th.Allocs, 1)
+		check(cur.Name, "B/op", float64(old.BytesPerOp), float64(cur.BytesPerOp), th.Allocs, 1)
+	}
+	return regressions
+}
@@ -0,0 +1,39 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\build_info.go
+ * @Description: 构建信息注入 - 版本、提交与构建时间由 -ldflags 写入 main 包变量后传给网关
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// SetBuildInfo 设置构建信息，需在 NewGateway().Build() 之前调用
+// 构建信息覆盖配置文件中的 version / git-commit / build-time，体现在 Banner、健康检查、
+// 构建信息接口、build_info 指标以及（开启后的）响应头与 gRPC 响应元数据中
+//
+// 使用示例:
+//
+//	var (
+//	    Version   = "dev"
+//	    GitCommit = "unknown"
+//	    BuildTime = "unknown"
+//	)
+//
+//	// go build -ldflags "-X main.Version=v1.2.3 -X main.GitCommit=$(git rev-parse --short HEAD) -X main.BuildTime=$(date -u +%FT%TZ)"
+//	gateway.SetBuildInfo(Version, GitCommit, BuildTime)
+func SetBuildInfo(version, commit, buildTime string) {
+	global.SetBuildInfo(global.BuildInfo{Version: version, Commit: commit, BuildTime: buildTime})
+}
+
+// GetBuildInfo 返回当前构建信息
+func GetBuildInfo() global.BuildInfo {
+	return global.GetBuildInfo()
+}
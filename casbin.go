@@ -0,0 +1,33 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\casbin.go
+ * @Description: Casbin 授权判定入口 - 业务代码按资源与操作做细粒度权限检查
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"context"
+
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// Enforce 以请求上下文中的用户（及租户）判定能否对 obj 执行 act，需先通过 WithCasbin 配置
+//
+// 使用示例:
+//
+//	func (s *OrderService) Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundReply, error) {
+//	    ok, err := gateway.Enforce(ctx, "order:"+req.OrderId, "refund")
+//	    if err != nil || !ok {
+//	        return nil, status.Error(codes.PermissionDenied, "refund not allowed")
+//	    }
+//	    ...
+//	}
+func Enforce(ctx context.Context, obj, act string) (bool, error) {
+	return middleware.CasbinEnforce(ctx, obj, act)
+}
@@ -0,0 +1,27 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cdn_purge.go
+ * @Description: CDN 缓存清除入口 - 业务数据变更后按 surrogate key 或 URL 失效 CDN 缓存
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"context"
+
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// PurgeCDN 向 WithCacheControl 配置的全部 CDN 发送清除请求，返回各 CDN 的结果；任一 CDN 失败时返回错误
+//
+// 使用示例:
+//
+//	_, err := gateway.PurgeCDN(ctx, middleware.CDNPurgeRequest{Keys: []string{"product-42"}})
+func PurgeCDN(ctx context.Context, req middleware.CDNPurgeRequest) ([]middleware.CDNPurgeResult, error) {
+	return middleware.PurgeCDN(ctx, req)
+}
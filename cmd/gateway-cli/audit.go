@@ -0,0 +1,97 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\gateway-cli\audit.go
+ * @Description: audit 子命令 - 只加载配置不启动服务，输出安全审计报告；
+ *               存在不低于 -fail-on 级别的问题时以退出码 3 结束，供流水线阻断发布
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kamalyes/go-rpc-gateway/audit"
+)
+
+// exitCodeFindings 存在达到阻断级别的审计问题
+const exitCodeFindings = 3
+
+// runAudit 执行 audit 子命令
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	configPath := fs.String("c", "", "配置文件路径（必填）")
+	env := fs.String("env", "", "按该环境审计（如 production），默认读取 APP_ENV")
+	failOn := fs.String("fail-on", string(audit.SeverityHigh), "达到该级别时返回退出码 3：info / low / medium / high / critical")
+	format := fs.String("format", "text", "输出格式：text / json")
+	maxRPS := fs.Int("max-rps", audit.DefaultMaxRequestsPerSecond, "限流规则每秒请求数上限，超过视为过于宽松")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: gateway-cli audit -c config.yaml [options]")
+		fmt.Fprintln(fs.Output(), "退出码: 0 未发现达到阻断级别的问题，1 配置无法加载，3 存在达到阻断级别的问题")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		fs.Usage()
+		return fmt.Errorf("缺少 -c 配置文件路径")
+	}
+	threshold, err := audit.ParseSeverity(*failOn)
+	if err != nil {
+		return err
+	}
+
+	in, err := audit.Load(*configPath, *env)
+	if err != nil {
+		return err
+	}
+	in.MaxRequestsPerSecond = *maxRPS
+	report := audit.Run(*in)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	case "text":
+		printAuditReport(report)
+	default:
+		return fmt.Errorf("无效的 -format: %s", *format)
+	}
+
+	if n := report.Count(threshold); n > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d 个问题达到阻断级别 %s\n", n, threshold)
+		os.Exit(exitCodeFindings)
+	}
+	fmt.Fprintf(os.Stderr, "✅ 未发现 %s 及以上级别的问题\n", threshold)
+	return nil
+}
+
+// printAuditReport 以表格输出审计报告
+func printAuditReport(report *audit.Report) {
+	scope := "非生产"
+	if report.Production {
+		scope = "生产"
+	}
+	fmt.Printf("环境: %s（%s）  问题: %d\n\n", report.Environment, scope, len(report.Findings))
+	if len(report.Findings) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "级别\t规则\t配置项\t问题\t建议")
+	for _, f := range report.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Rule, f.Key, f.Message, f.Advice)
+	}
+	tw.Flush()
+}
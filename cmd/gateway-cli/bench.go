@@ -0,0 +1,90 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\gateway-cli\bench.go
+ * @Description: bench 子命令 - 运行端到端基准并输出 JSON 报告，可与基线报告对比作为性能回归门禁
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/kamalyes/go-rpc-gateway/benchmarks"
+)
+
+// runBench 执行 bench 子命令
+func runBench(args []string) error {
+	th := benchmarks.DefaultThresholds()
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	run := fs.String("run", "", "只运行名称匹配该正则的场景")
+	benchtime := fs.Duration("benchtime", benchmarks.DefaultBenchTime, "每个场景的累计运行时长（如 3s、500ms）")
+	output := fs.String("o", "-", "报告输出路径，- 表示标准输出")
+	baseline := fs.String("baseline", "", "基线报告路径，指定后对比并在回归超出阈值时返回非零退出码")
+	fs.Float64Var(&th.Latency, "max-latency-regression", th.Latency, "允许的 ns/op 最大增幅（0.1 表示 10%）")
+	fs.Float64Var(&th.Allocs, "max-alloc-regression", th.Allocs, "允许的 allocs/op、B/op 最大增幅")
+	commit := fs.String("commit", "", "报告中记录的提交（默认读取构建信息中的 VCS 版本）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: gateway-cli bench [options]")
+		fmt.Fprintln(fs.Output(), "场景:")
+		for _, sc := range benchmarks.Scenarios {
+			fmt.Fprintf(fs.Output(), "  %s (GET %s)\n", sc.Name, sc.Path)
+		}
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			return fmt.Errorf("无效的 -run: %w", err)
+		}
+	}
+
+	report, err := benchmarks.Run(filter, *commit, *benchtime)
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Results {
+		fmt.Fprintf(os.Stderr, "%-20s %10d %12.0f ns/op %12.0f ops/s %8d B/op %6d allocs/op\n",
+			r.Name, r.Iterations, r.NsPerOp, r.OpsPerSec, r.BytesPerOp, r.AllocsPerOp)
+	}
+
+	if *output == "-" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := report.Save(*output); err != nil {
+		return err
+	}
+
+	if *baseline == "" {
+		return nil
+	}
+	base, err := benchmarks.LoadReport(*baseline)
+	if err != nil {
+		return err
+	}
+	regressions := benchmarks.Compare(base, report, th)
+	if len(regressions) == 0 {
+		fmt.Fprintf(os.Stderr, "✅ 与基线 %s 相比无性能回归\n", base.Commit)
+		return nil
+	}
+	for _, r := range regressions {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", r)
+	}
+	return fmt.Errorf("%d 项指标超出回归阈值", len(regressions))
+}
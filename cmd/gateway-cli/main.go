@@ -0,0 +1,72 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\gateway-cli\main.go
+ * @Description: gateway-cli 命令行入口，提供项目脚手架、基准测试、配置审计、SDK 生成等子命令
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command 子命令定义
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// commands 已注册的子命令
+var commands = []command{
+	{name: "new", usage: "创建一个基于 go-rpc-gateway 的新服务项目", run: runNew},
+	{name: "bench", usage: "运行端到端基准并与基线报告对比", run: runBench},
+	{name: "audit", usage: "只加载配置并输出安全审计报告，可按级别阻断流水线", run: runAudit},
+	{name: "sdk", usage: "由 Swagger / OpenAPI 规范生成 TypeScript 或 Go 客户端 SDK", run: runSDK},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "-h" || name == "--help" || name == "help" {
+		printUsage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+// printUsage 打印帮助信息
+func printUsage() {
+	fmt.Println("用法: gateway-cli <command> [options]")
+	fmt.Println()
+	fmt.Println("可用命令:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Println()
+	fmt.Println("使用 gateway-cli <command> -h 查看命令参数")
+}
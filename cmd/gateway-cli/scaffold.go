@@ -0,0 +1,207 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\gateway-cli\scaffold.go
+ * @Description: new 子命令 - 生成基于 go-rpc-gateway 的服务项目骨架
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// projectNamePattern 项目名校验规则（小写字母开头，允许数字和中划线）
+var projectNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// scaffoldEnvironments 生成配置文件的环境列表（与 go-config 环境前缀约定一致）
+var scaffoldEnvironments = []string{"dev", "test", "prod"}
+
+// projectData 模板渲染数据
+type projectData struct {
+	Name        string // 项目名，例如 user-service
+	Module      string // Go module 路径
+	PackageName string // proto 包名，例如 userservice
+	ServiceName string // proto 服务名，例如 UserService
+	HTTPPort    int    // HTTP 端口
+	GRPCPort    int    // gRPC 端口
+	Env         string // 当前渲染的环境（仅配置模板使用）
+}
+
+// scaffoldFile 模板与输出路径的映射
+type scaffoldFile struct {
+	template string
+	output   string
+}
+
+// scaffoldFiles 需要生成的文件列表（输出路径同样支持模板语法）
+var scaffoldFiles = []scaffoldFile{
+	{template: "main.go.tmpl", output: "main.go"},
+	{template: "server.go.tmpl", output: "bootstrap/server.go"},
+	{template: "handlers.go.tmpl", output: "bootstrap/handlers.go"},
+	{template: "go.mod.tmpl", output: "go.mod"},
+	{template: "service.proto.tmpl", output: "proto/{{.PackageName}}/v1/{{.PackageName}}.proto"},
+	{template: "buf.yaml.tmpl", output: "buf.yaml"},
+	{template: "buf.gen.yaml.tmpl", output: "buf.gen.yaml"},
+	{template: "Makefile.tmpl", output: "Makefile"},
+	{template: "Dockerfile.tmpl", output: "Dockerfile"},
+	{template: "dockerignore.tmpl", output: ".dockerignore"},
+	{template: "gitignore.tmpl", output: ".gitignore"},
+	{template: "README.md.tmpl", output: "README.md"},
+}
+
+// runNew 执行 new 子命令
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := fs.String("module", "", "Go module 路径（默认与项目名相同）")
+	dir := fs.String("dir", ".", "项目生成的父目录")
+	httpPort := fs.Int("http-port", 8080, "HTTP 服务端口")
+	grpcPort := fs.Int("grpc-port", 9090, "gRPC 服务端口")
+	force := fs.Bool("force", false, "目标目录已存在时覆盖文件")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: gateway-cli new <project-name> [options]")
+		fs.PrintDefaults()
+	}
+
+	name, flagArgs := splitPositional(args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fs.Usage()
+		return fmt.Errorf("缺少项目名")
+	}
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("项目名 %q 不合法，只允许小写字母、数字和中划线，且必须以字母开头", name)
+	}
+
+	data := newProjectData(name, *module, *httpPort, *grpcPort)
+	root := filepath.Join(*dir, name)
+
+	if _, err := os.Stat(root); err == nil && !*force {
+		return fmt.Errorf("目录 %s 已存在，使用 -force 覆盖", root)
+	}
+
+	fmt.Printf("🚀 正在创建项目 %s (module=%s)\n", name, data.Module)
+
+	for _, f := range scaffoldFiles {
+		if err := renderToFile(root, f.template, f.output, data); err != nil {
+			return err
+		}
+	}
+
+	for _, env := range scaffoldEnvironments {
+		envData := data
+		envData.Env = env
+		output := fmt.Sprintf("resources/gateway-%s-%s.yaml", name, env)
+		if err := renderToFile(root, "config.yaml.tmpl", output, envData); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("✅ 项目创建完成，下一步:")
+	fmt.Printf("   cd %s\n", root)
+	fmt.Println("   make init   # 拉取依赖")
+	fmt.Println("   make proto  # 生成 protobuf 代码（需要 buf）")
+	fmt.Println("   make run    # 启动服务")
+	return nil
+}
+
+// newProjectData 根据项目名推导模板数据
+func newProjectData(name, module string, httpPort, grpcPort int) projectData {
+	if module == "" {
+		module = name
+	}
+
+	parts := strings.Split(name, "-")
+	var service strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		service.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+
+	serviceName := service.String()
+	if !strings.HasSuffix(serviceName, "Service") {
+		serviceName += "Service"
+	}
+
+	return projectData{
+		Name:        name,
+		Module:      module,
+		PackageName: strings.ReplaceAll(name, "-", ""),
+		ServiceName: serviceName,
+		HTTPPort:    httpPort,
+		GRPCPort:    grpcPort,
+	}
+}
+
+// splitPositional 支持 "new <name> [options]" 与 "new [options] <name>" 两种写法
+func splitPositional(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// renderToFile 渲染模板并写入目标文件
+func renderToFile(root, tmplName, output string, data projectData) error {
+	outPath, err := renderString(output, data)
+	if err != nil {
+		return fmt.Errorf("渲染输出路径 %s 失败: %w", output, err)
+	}
+
+	content, err := templateFS.ReadFile("templates/" + tmplName)
+	if err != nil {
+		return fmt.Errorf("读取模板 %s 失败: %w", tmplName, err)
+	}
+
+	rendered, err := renderString(string(content), data)
+	if err != nil {
+		return fmt.Errorf("渲染模板 %s 失败: %w", tmplName, err)
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(outPath))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(target, []byte(rendered), 0o644); err != nil {
+		return fmt.Errorf("写入文件 %s 失败: %w", target, err)
+	}
+
+	fmt.Printf("   + %s\n", outPath)
+	return nil
+}
+
+// renderString 使用 text/template 渲染字符串
+func renderString(text string, data projectData) (string, error) {
+	tmpl, err := template.New("scaffold").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,114 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\gateway-cli\sdk.go
+ * @Description: sdk 子命令 - 由 Swagger / OpenAPI 文件或运行中网关的规范地址离线生成客户端 SDK，
+ *               输出为 zip 或目录，便于在前端流水线中随接口变更自动更新
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/sdkgen"
+	"github.com/kamalyes/go-swagger/loader"
+)
+
+// stringList 可重复的字符串参数
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// runSDK 执行 sdk 子命令
+func runSDK(args []string) error {
+	fs := flag.NewFlagSet("sdk", flag.ContinueOnError)
+	var specs stringList
+	fs.Var(&specs, "spec", "规范文件路径或 http(s) 地址，可重复，多个规范合并生成（必填）")
+	lang := fs.String("lang", string(sdkgen.LanguageTypeScript), "目标语言：typescript / go")
+	output := fs.String("o", "", "输出路径，以 .zip 结尾时打包，否则写入目录；默认 <lang>.zip")
+	pkg := fs.String("package", sdkgen.DefaultPackage, "包名（package.json 的 name，最后一段作为 Go 包名）")
+	goModule := fs.String("go-module", "", "Go SDK 的模块路径，默认与包名相同")
+	templateDir := fs.String("templates", "", "自定义模板目录，其下 typescript/、go/ 中的 *.tmpl 覆盖或追加内置模板")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: gateway-cli sdk -spec swagger.json [-lang typescript] [-o client.zip] [options]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少 -spec 规范文件")
+	}
+	language, err := sdkgen.ParseLanguage(*lang)
+	if err != nil {
+		return err
+	}
+
+	loaded := make([]map[string]any, 0, len(specs))
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, spec := range specs {
+		var m map[string]any
+		if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+			m, err = loader.LoadSpecFromURL(client, spec)
+		} else {
+			m, err = loader.LoadSpecFromPath(spec)
+		}
+		if err != nil {
+			return fmt.Errorf("加载规范 %s 失败: %w", spec, err)
+		}
+		loaded = append(loaded, m)
+	}
+
+	files, err := sdkgen.Generate(language, sdkgen.Config{
+		Package:     *pkg,
+		GoModule:    *goModule,
+		TemplateDir: *templateDir,
+	}, loaded...)
+	if err != nil {
+		return err
+	}
+
+	out := *output
+	if out == "" {
+		out = string(language) + ".zip"
+	}
+	if strings.HasSuffix(out, ".zip") {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := sdkgen.WriteZip(f, "", files); err != nil {
+			return err
+		}
+	} else {
+		for _, file := range files {
+			target := filepath.Join(out, file.Name)
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, file.Data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("✅ 已生成 %s SDK（%d 个文件）: %s\n", language, len(files), out)
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\loadgen\main.go
+ * @Description: loadgen 压测命令入口 - 读取场景文件对网关实例施压，输出延迟直方图与错误分布
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// loadgen 按场景文件（路由、权重、请求体模板、加压曲线）对网关实例发起压测，
+// 用于上线前验证限流与熔断配置：429/503 等拒绝状态码会单独标注
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ loadgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run 解析参数并执行压测
+func run() error {
+	file := flag.String("f", "", "场景文件路径（YAML/JSON）")
+	target := flag.String("target", "", "覆盖场景文件中的 target")
+	concurrency := flag.Int("c", 0, "覆盖场景文件中的 concurrency")
+	output := flag.String("o", "", "JSON 报告输出路径")
+	quiet := flag.Bool("q", false, "不输出每秒进度")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "用法: loadgen -f scenario.yaml [options]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *file == "" {
+		flag.Usage()
+		return fmt.Errorf("缺少 -f 场景文件")
+	}
+
+	sc, err := LoadScenario(*file)
+	if err != nil {
+		return err
+	}
+	if *target != "" {
+		sc.Target = *target
+	}
+	if *concurrency > 0 {
+		sc.Concurrency = *concurrency
+	}
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+
+	// Ctrl+C 提前结束时仍输出已收集的统计
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "🚀 压测 %s：%d 条路由，%d 个阶段，共 %s，最大并发 %d\n",
+		sc.Target, len(sc.Routes), len(sc.Stages), sc.Duration(), sc.Concurrency)
+	var progress io.Writer = os.Stderr
+	if *quiet {
+		progress = nil
+	}
+	report := NewRunner(sc).Run(ctx, progress)
+	report.Print(os.Stdout)
+
+	if *output == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, append(data, '\n'), 0o644)
+}
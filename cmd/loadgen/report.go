@@ -0,0 +1,254 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\loadgen\report.go
+ * @Description: 压测统计与报告 - 按路由汇总延迟分位数、延迟直方图、状态码与错误分类
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// histogramBounds 延迟直方图桶上界
+var histogramBounds = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// RouteStats 单个路由的原始统计
+type RouteStats struct {
+	name      string
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[int]int64
+	errors    map[string]int64
+}
+
+// newRouteStats 创建路由统计
+func newRouteStats(name string) *RouteStats {
+	return &RouteStats{name: name, statuses: map[int]int64{}, errors: map[string]int64{}}
+}
+
+// record 记录一次收到响应的请求
+func (s *RouteStats) record(status int, latency time.Duration) {
+	s.mu.Lock()
+	s.latencies = append(s.latencies, latency)
+	s.statuses[status]++
+	s.mu.Unlock()
+}
+
+// recordError 记录一次未收到响应的请求
+func (s *RouteStats) recordError(kind string) {
+	s.mu.Lock()
+	s.errors[kind]++
+	s.mu.Unlock()
+}
+
+// Bucket 直方图桶
+type Bucket struct {
+	UpperBound string `json:"le"` // 空字符串表示 +Inf
+	Count      int64  `json:"count"`
+}
+
+// RouteReport 单个路由的汇总
+type RouteReport struct {
+	Name      string           `json:"name"`
+	Requests  int64            `json:"requests"`
+	Succeeded int64            `json:"succeeded"` // 2xx
+	RPS       float64          `json:"rps"`
+	Min       time.Duration    `json:"min_ns"`
+	Mean      time.Duration    `json:"mean_ns"`
+	P50       time.Duration    `json:"p50_ns"`
+	P90       time.Duration    `json:"p90_ns"`
+	P99       time.Duration    `json:"p99_ns"`
+	Max       time.Duration    `json:"max_ns"`
+	Histogram []Bucket         `json:"histogram"`
+	Statuses  map[int]int64    `json:"statuses"`
+	Errors    map[string]int64 `json:"errors,omitempty"`
+}
+
+// Report 压测报告
+type Report struct {
+	Target   string        `json:"target"`
+	Duration time.Duration `json:"duration_ns"`
+	Sent     int64         `json:"sent"`
+	Dropped  int64         `json:"dropped"` // 并发已满未能发出的请求
+	Routes   []RouteReport `json:"routes"`
+}
+
+// newReport 汇总各路由统计
+func newReport(sc *Scenario, elapsed time.Duration, sent, dropped int64, stats map[string]*RouteStats) *Report {
+	report := &Report{Target: sc.Target, Duration: elapsed, Sent: sent, Dropped: dropped}
+	for _, rt := range sc.Routes {
+		report.Routes = append(report.Routes, stats[rt.Name].summarize(elapsed))
+	}
+	return report
+}
+
+// summarize 计算分位数与直方图
+func (s *RouteStats) summarize(elapsed time.Duration) RouteReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rr := RouteReport{
+		Name:     s.name,
+		Statuses: make(map[int]int64, len(s.statuses)),
+		Errors:   make(map[string]int64, len(s.errors)),
+	}
+	for code, n := range s.statuses {
+		rr.Statuses[code] = n
+		rr.Requests += n
+		if code >= 200 && code < 300 {
+			rr.Succeeded += n
+		}
+	}
+	for kind, n := range s.errors {
+		rr.Errors[kind] = n
+		rr.Requests += n
+	}
+	if elapsed > 0 {
+		rr.RPS = float64(rr.Requests) / elapsed.Seconds()
+	}
+
+	lat := s.latencies
+	if len(lat) == 0 {
+		return rr
+	}
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	var sum time.Duration
+	for _, d := range lat {
+		sum += d
+	}
+	rr.Min, rr.Max = lat[0], lat[len(lat)-1]
+	rr.Mean = sum / time.Duration(len(lat))
+	rr.P50, rr.P90, rr.P99 = percentile(lat, 0.50), percentile(lat, 0.90), percentile(lat, 0.99)
+
+	i := 0
+	for _, bound := range histogramBounds {
+		var n int64
+		for ; i < len(lat) && lat[i] <= bound; i++ {
+			n++
+		}
+		rr.Histogram = append(rr.Histogram, Bucket{UpperBound: bound.String(), Count: n})
+	}
+	rr.Histogram = append(rr.Histogram, Bucket{Count: int64(len(lat) - i)})
+	return rr
+}
+
+// percentile 已排序样本的分位数（最近秩）
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	idx := int(q*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// statusHint 网关常见拒绝状态码的含义，帮助核对限流与熔断配置
+func statusHint(code int) string {
+	switch code {
+	case http.StatusTooManyRequests:
+		return "限流拒绝"
+	case http.StatusServiceUnavailable:
+		return "熔断打开或服务不可用"
+	case http.StatusGatewayTimeout:
+		return "上游超时"
+	case http.StatusBadGateway:
+		return "上游错误"
+	}
+	return ""
+}
+
+// Print 输出人类可读的报告
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "\n目标: %s  时长: %s  已发送: %d  丢弃: %d\n",
+		r.Target, r.Duration.Round(time.Millisecond), r.Sent, r.Dropped)
+	if r.Dropped > 0 {
+		fmt.Fprintln(w, "⚠️  有请求因并发已满未能发出，请调大 concurrency 或降低目标速率")
+	}
+
+	for _, rr := range r.Routes {
+		fmt.Fprintf(w, "\n== %s ==\n", rr.Name)
+		fmt.Fprintf(w, "请求: %d  成功(2xx): %d  速率: %.1f req/s\n", rr.Requests, rr.Succeeded, rr.RPS)
+		if rr.Requests == 0 {
+			continue
+		}
+		if len(rr.Histogram) > 0 {
+			fmt.Fprintf(w, "延迟: min %s  mean %s  p50 %s  p90 %s  p99 %s  max %s\n",
+				round(rr.Min), round(rr.Mean), round(rr.P50), round(rr.P90), round(rr.P99), round(rr.Max))
+			printHistogram(w, rr.Histogram)
+		}
+
+		if len(rr.Statuses) > 0 {
+			codes := make([]int, 0, len(rr.Statuses))
+			for code := range rr.Statuses {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			fmt.Fprintln(w, "状态码:")
+			for _, code := range codes {
+				fmt.Fprintf(w, "  %d %-8d %5.1f%% %s\n", code, rr.Statuses[code],
+					float64(rr.Statuses[code])*100/float64(rr.Requests), statusHint(code))
+			}
+		}
+
+		if len(rr.Errors) > 0 {
+			kinds := make([]string, 0, len(rr.Errors))
+			for kind := range rr.Errors {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			fmt.Fprintln(w, "传输错误:")
+			for _, kind := range kinds {
+				fmt.Fprintf(w, "  %-8d %s\n", rr.Errors[kind], kind)
+			}
+		}
+	}
+}
+
+// printHistogram 输出直方图，省略最大延迟之后的空桶
+func printHistogram(w io.Writer, buckets []Bucket) {
+	var peak int64
+	last := 0
+	for i, b := range buckets {
+		if b.Count > 0 {
+			peak, last = max(peak, b.Count), i
+		}
+	}
+	fmt.Fprintln(w, "延迟直方图:")
+	for _, b := range buckets[:last+1] {
+		le := b.UpperBound
+		if le == "" {
+			le = "+Inf"
+		}
+		fmt.Fprintf(w, "  <= %-6s %8d %s\n", le, b.Count, strings.Repeat("█", int(b.Count*40/peak)))
+	}
+}
+
+// round 按量级取整显示
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,186 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\loadgen\runner.go
+ * @Description: 压测执行器 - 按加压曲线开环发送请求（不因响应变慢而降速），按路由统计延迟与错误
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tickInterval 调度粒度
+const tickInterval = 10 * time.Millisecond
+
+// Runner 压测执行器
+type Runner struct {
+	sc     *Scenario
+	client *http.Client
+	picker *picker
+	stats  map[string]*RouteStats
+}
+
+// NewRunner 创建执行器（场景需已通过 Validate）
+func NewRunner(sc *Scenario) *Runner {
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: sc.Concurrency,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	r := &Runner{
+		sc:     sc,
+		client: &http.Client{Transport: transport, Timeout: sc.Timeout},
+		picker: newPicker(sc.Routes),
+		stats:  make(map[string]*RouteStats, len(sc.Routes)),
+	}
+	for _, rt := range sc.Routes {
+		r.stats[rt.Name] = newRouteStats(rt.Name)
+	}
+	return r
+}
+
+// Run 执行场景直至结束或 ctx 取消，progress 不为 nil 时每秒输出一次进度
+func (r *Runner) Run(ctx context.Context, progress io.Writer) *Report {
+	jobs := make(chan *Route, r.sc.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < r.sc.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rt := range jobs {
+				r.do(rt)
+			}
+		}()
+	}
+
+	start := time.Now()
+	total := r.sc.Duration()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	var (
+		sent, dropped int64
+		due           float64 // 按目标速率累计应发送的请求数
+		last          = start
+		lastReport    = start
+	)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed >= total {
+				break loop
+			}
+			due += r.sc.RateAt(elapsed) * now.Sub(last).Seconds()
+			last = now
+			for ; due >= 1; due-- {
+				select {
+				case jobs <- r.picker.pick():
+					sent++
+				default:
+					// 并发已满：记录为客户端丢弃，说明需要调大 concurrency 或目标已饱和
+					dropped++
+				}
+			}
+			if progress != nil && now.Sub(lastReport) >= time.Second {
+				lastReport = now
+				fmt.Fprintf(progress, "[%5.1fs] 目标速率 %7.1f req/s，已发送 %d，丢弃 %d\n",
+					elapsed.Seconds(), r.sc.RateAt(elapsed), sent, dropped)
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return newReport(r.sc, time.Since(start), sent, dropped, r.stats)
+}
+
+// do 发送一次请求并记录结果
+func (r *Runner) do(rt *Route) {
+	st := r.stats[rt.Name]
+	req, err := r.newRequest(rt)
+	if err != nil {
+		st.recordError("template: " + err.Error())
+		return
+	}
+
+	begin := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		st.recordError(classifyError(err))
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	st.record(resp.StatusCode, time.Since(begin))
+}
+
+// newRequest 渲染模板并构建请求
+func (r *Runner) newRequest(rt *Route) (*http.Request, error) {
+	path, err := render(rt.path)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if rt.body != nil {
+		s, err := render(rt.body)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(s)
+	}
+
+	req, err := http.NewRequest(rt.Method, r.sc.Target+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.sc.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range rt.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// classifyError 将传输层错误归类，便于汇总
+func classifyError(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	// 去掉 *url.Error 中随请求变化的 URL，按底层错误归类
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	switch {
+	case strings.Contains(err.Error(), "connection refused"):
+		return "connection refused"
+	case strings.Contains(err.Error(), "connection reset"):
+		return "connection reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected EOF"
+	}
+	return "transport: " + err.Error()
+}
@@ -0,0 +1,216 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\loadgen\scenario.go
+ * @Description: 压测场景定义 - 路由与权重、请求体模板、阶梯加压曲线（YAML/JSON 场景文件）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario 压测场景
+type Scenario struct {
+	Target      string            `yaml:"target"`      // 网关地址，例如 http://127.0.0.1:8080
+	Concurrency int               `yaml:"concurrency"` // 最大并发请求数
+	Timeout     time.Duration     `yaml:"timeout"`     // 单个请求超时
+	Headers     map[string]string `yaml:"headers"`     // 所有请求共用的请求头
+	Routes      []Route           `yaml:"routes"`
+	Stages      []Stage           `yaml:"stages"` // 加压曲线，按顺序执行
+}
+
+// Route 压测路由
+type Route struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"` // 默认 GET
+	Path    string            `yaml:"path"`   // 支持模板
+	Weight  int               `yaml:"weight"` // 流量权重，默认 1
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"` // 请求体模板
+
+	path *template.Template
+	body *template.Template
+}
+
+// Stage 加压阶段：在 Duration 内将速率从上一阶段的目标线性调整到 RPS
+type Stage struct {
+	Duration time.Duration `yaml:"duration"`
+	RPS      float64       `yaml:"rps"`
+}
+
+// LoadScenario 读取并校验场景文件（JSON 为 YAML 子集，两种格式均可）
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// Validate 校验场景并填充默认值、编译模板
+func (sc *Scenario) Validate() error {
+	if sc.Target == "" {
+		return fmt.Errorf("target 不能为空")
+	}
+	sc.Target = strings.TrimSuffix(sc.Target, "/")
+	if sc.Concurrency <= 0 {
+		sc.Concurrency = 64
+	}
+	if sc.Timeout <= 0 {
+		sc.Timeout = 10 * time.Second
+	}
+	if len(sc.Routes) == 0 {
+		return fmt.Errorf("routes 不能为空")
+	}
+	if len(sc.Stages) == 0 {
+		return fmt.Errorf("stages 不能为空")
+	}
+	for i, st := range sc.Stages {
+		if st.Duration <= 0 || st.RPS < 0 {
+			return fmt.Errorf("stages[%d]: duration 必须大于 0，rps 不能为负数", i)
+		}
+	}
+
+	for i := range sc.Routes {
+		rt := &sc.Routes[i]
+		if rt.Path == "" {
+			return fmt.Errorf("routes[%d]: path 不能为空", i)
+		}
+		if rt.Method == "" {
+			rt.Method = http.MethodGet
+		}
+		rt.Method = strings.ToUpper(rt.Method)
+		if rt.Name == "" {
+			rt.Name = rt.Method + " " + rt.Path
+		}
+		if rt.Weight <= 0 {
+			rt.Weight = 1
+		}
+		var err error
+		if rt.path, err = template.New(rt.Name).Funcs(templateFuncs).Parse(rt.Path); err != nil {
+			return fmt.Errorf("routes[%d] path: %w", i, err)
+		}
+		if rt.Body != "" {
+			if rt.body, err = template.New(rt.Name).Funcs(templateFuncs).Parse(rt.Body); err != nil {
+				return fmt.Errorf("routes[%d] body: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Duration 场景总时长
+func (sc *Scenario) Duration() time.Duration {
+	var total time.Duration
+	for _, st := range sc.Stages {
+		total += st.Duration
+	}
+	return total
+}
+
+// RateAt 场景开始后 elapsed 时刻的目标速率（第一阶段从 0 开始爬升）
+func (sc *Scenario) RateAt(elapsed time.Duration) float64 {
+	prev := 0.0
+	for _, st := range sc.Stages {
+		if elapsed < st.Duration {
+			return prev + (st.RPS-prev)*float64(elapsed)/float64(st.Duration)
+		}
+		elapsed -= st.Duration
+		prev = st.RPS
+	}
+	return prev
+}
+
+// picker 按权重选择路由
+type picker struct {
+	routes []*Route
+	cum    []int
+	total  int
+}
+
+// newPicker 创建路由选择器
+func newPicker(routes []Route) *picker {
+	p := &picker{}
+	for i := range routes {
+		p.total += routes[i].Weight
+		p.routes = append(p.routes, &routes[i])
+		p.cum = append(p.cum, p.total)
+	}
+	return p
+}
+
+// pick 随机选择一条路由
+func (p *picker) pick() *Route {
+	n := rand.IntN(p.total)
+	for i, c := range p.cum {
+		if n < c {
+			return p.routes[i]
+		}
+	}
+	return p.routes[len(p.routes)-1]
+}
+
+// seq 全局请求序号（模板函数 seq）
+var seq atomic.Uint64
+
+// templateFuncs 路径与请求体模板可用的函数
+var templateFuncs = template.FuncMap{
+	"seq":     func() uint64 { return seq.Add(1) },
+	"randInt": func(min, max int) int { return min + rand.IntN(max-min+1) },
+	"randStr": randString,
+	"uuid":    randUUID,
+	"now":     func() int64 { return time.Now().Unix() },
+	"nowMs":   func() int64 { return time.Now().UnixMilli() },
+	"pick":    func(items ...string) string { return items[rand.IntN(len(items))] },
+}
+
+const randAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randString 随机字母数字串
+func randString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randAlphabet[rand.IntN(len(randAlphabet))]
+	}
+	return string(b)
+}
+
+// randUUID 随机 UUID v4 字符串
+func randUUID() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(rand.IntN(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// render 渲染模板
+func render(t *template.Template) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
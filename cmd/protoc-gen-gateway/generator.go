@@ -0,0 +1,297 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\protoc-gen-gateway\generator.go
+ * @Description: 根据 google.api.http 注解生成路由元信息、Swagger 片段与注册函数
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	gatewayPackage = protogen.GoImportPath("github.com/kamalyes/go-rpc-gateway")
+	runtimePackage = protogen.GoImportPath("github.com/grpc-ecosystem/grpc-gateway/v2/runtime")
+	grpcPackage    = protogen.GoImportPath("google.golang.org/grpc")
+	contextPackage = protogen.GoImportPath("context")
+)
+
+// pathParamPattern 匹配路径模板中的变量，例如 {id} 或 {name=users/*}
+var pathParamPattern = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// generateOptions 插件参数
+type generateOptions struct {
+	proxy  bool // 生成远程代理注册函数
+	server bool // 生成本地服务注册函数
+}
+
+// httpBinding 单条 HTTP 绑定
+type httpBinding struct {
+	method  *protogen.Method
+	verb    string
+	pattern string
+	body    string
+}
+
+// generateFile 为单个 proto 文件生成 <file>.gateway.go，没有 HTTP 绑定的文件不生成
+func generateFile(plugin *protogen.Plugin, file *protogen.File, opts generateOptions) error {
+	type serviceBindings struct {
+		service  *protogen.Service
+		bindings []httpBinding
+	}
+
+	var services []serviceBindings
+	for _, service := range file.Services {
+		var bindings []httpBinding
+		for _, method := range service.Methods {
+			methodBindings, err := collectBindings(method)
+			if err != nil {
+				return err
+			}
+			bindings = append(bindings, methodBindings...)
+		}
+		if len(bindings) > 0 {
+			services = append(services, serviceBindings{service: service, bindings: bindings})
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	g := plugin.NewGeneratedFile(file.GeneratedFilenamePrefix+".gateway.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-gateway. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P("// \tprotoc-gen-gateway ", version)
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, s := range services {
+		if err := generateService(g, s.service, s.bindings, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectBindings 读取方法上的 google.api.http 注解（含 additional_bindings）
+func collectBindings(method *protogen.Method) ([]httpBinding, error) {
+	if !proto.HasExtension(method.Desc.Options(), annotations.E_Http) {
+		return nil, nil
+	}
+	rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil, nil
+	}
+
+	var bindings []httpBinding
+	rules := append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...)
+	for _, r := range rules {
+		verb, pattern := httpRulePattern(r)
+		if verb == "" || pattern == "" {
+			return nil, fmt.Errorf("%s: google.api.http 注解缺少 HTTP 方法或路径", method.Desc.FullName())
+		}
+		bindings = append(bindings, httpBinding{
+			method:  method,
+			verb:    verb,
+			pattern: pattern,
+			body:    r.GetBody(),
+		})
+	}
+	return bindings, nil
+}
+
+// httpRulePattern 解析 HttpRule 的 HTTP 方法与路径模板
+func httpRulePattern(rule *annotations.HttpRule) (string, string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", p.Get
+	case *annotations.HttpRule_Put:
+		return "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		return "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		return "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		return "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	default:
+		return "", ""
+	}
+}
+
+// generateService 生成单个服务的路由表、Swagger 片段与注册函数
+func generateService(g *protogen.GeneratedFile, service *protogen.Service, bindings []httpBinding, opts generateOptions) error {
+	name := service.GoName
+	routeInfo := gatewayPackage.Ident("RouteInfo")
+	gatewayType := gatewayPackage.Ident("Gateway")
+
+	// 路由元信息
+	g.P("// ", name, "GatewayRoutes ", name, " 的 HTTP 路由元信息")
+	g.P("var ", name, "GatewayRoutes = []", routeInfo, "{")
+	for _, b := range bindings {
+		g.P("{")
+		g.P("Service: ", strconv.Quote(string(service.Desc.FullName())), ",")
+		g.P("Method: ", strconv.Quote(string(b.method.Desc.Name())), ",")
+		g.P("FullMethod: ", strconv.Quote(fullMethodName(b.method)), ",")
+		g.P("HTTPMethod: ", strconv.Quote(b.verb), ",")
+		g.P("Pattern: ", strconv.Quote(b.pattern), ",")
+		if b.body != "" {
+			g.P("Body: ", strconv.Quote(b.body), ",")
+		}
+		if summary := methodSummary(b.method); summary != "" {
+			g.P("Summary: ", strconv.Quote(summary), ",")
+		}
+		if !b.method.Desc.IsStreamingClient() {
+			g.P("NewRequest: func() any { return &", b.method.Input.GoIdent, "{} },")
+		}
+		g.P("},")
+	}
+	g.P("}")
+	g.P()
+
+	// Swagger 片段
+	swaggerPaths, err := buildSwaggerPaths(service, bindings)
+	if err != nil {
+		return err
+	}
+	g.P("// ", name, "SwaggerPaths ", name, " 的 Swagger paths 片段（JSON）")
+	g.P("const ", name, "SwaggerPaths = ", goStringLiteral(swaggerPaths))
+	g.P()
+
+	// 本地服务注册
+	if opts.server {
+		g.P("// Register", name, "Gateway 注册本地 ", name, " 实现：gRPC 服务、gRPC-Gateway HTTP 处理器与路由元信息")
+		g.P("func Register", name, "Gateway(gw *", gatewayType, ", srv ", name, "Server) error {")
+		g.P("gw.RegisterService(func(s *", grpcPackage.Ident("Server"), ") {")
+		g.P("Register", name, "Server(s, srv)")
+		g.P("})")
+		g.P("if err := gw.RegisterGatewayHandler(func(ctx ", contextPackage.Ident("Context"), ", mux *", runtimePackage.Ident("ServeMux"), ") error {")
+		g.P("return Register", name, "HandlerServer(ctx, mux, srv)")
+		g.P("}); err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("gw.RegisterRoutes(", name, "GatewayRoutes...)")
+		g.P("return nil")
+		g.P("}")
+		g.P()
+	}
+
+	// 远程代理注册
+	if opts.proxy {
+		g.P("// Register", name, "GatewayProxy 通过 grpc.clients 中的服务名注册 ", name, " 远程代理与路由元信息")
+		g.P("func Register", name, "GatewayProxy(gw *", gatewayType, ", serviceName string) error {")
+		g.P("if err := gw.RegisterProxyHandlerByServiceName(serviceName, func(ctx ", contextPackage.Ident("Context"), ", mux *", runtimePackage.Ident("ServeMux"), ", conn *", grpcPackage.Ident("ClientConn"), ") error {")
+		g.P("return Register", name, "Handler(ctx, mux, conn)")
+		g.P("}); err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("gw.RegisterRoutes(", name, "GatewayRoutes...)")
+		g.P("return nil")
+		g.P("}")
+		g.P()
+	}
+	return nil
+}
+
+// fullMethodName 返回 gRPC 完整方法名
+func fullMethodName(method *protogen.Method) string {
+	return "/" + string(method.Parent.Desc.FullName()) + "/" + string(method.Desc.Name())
+}
+
+// methodSummary 取方法前置注释的第一行作为接口描述
+func methodSummary(method *protogen.Method) string {
+	comment := strings.TrimSpace(string(method.Comments.Leading))
+	if comment == "" {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(comment, "\n", 2)[0])
+}
+
+// buildSwaggerPaths 生成 Swagger 2.0 的 paths 片段，格式与 EndpointCollector.CollectFromSwagger 兼容
+func buildSwaggerPaths(service *protogen.Service, bindings []httpBinding) (string, error) {
+	paths := make(map[string]map[string]any)
+	for _, b := range bindings {
+		path, params := swaggerPath(b.pattern)
+
+		parameters := make([]map[string]any, 0, len(params)+1)
+		for _, p := range params {
+			parameters = append(parameters, map[string]any{
+				"name":     p,
+				"in":       "path",
+				"required": true,
+				"type":     "string",
+			})
+		}
+		if b.body != "" {
+			parameters = append(parameters, map[string]any{
+				"name":     "body",
+				"in":       "body",
+				"required": true,
+				"schema":   map[string]any{"type": "object"},
+			})
+		}
+
+		operation := map[string]any{
+			"operationId":   service.GoName + "_" + b.method.GoName,
+			"tags":          []string{service.GoName},
+			"x-grpc-method": fullMethodName(b.method),
+			"responses": map[string]any{
+				"200": map[string]any{"description": "A successful response."},
+			},
+		}
+		if summary := methodSummary(b.method); summary != "" {
+			operation["summary"] = summary
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(b.verb)] = operation
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"paths": paths}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("%s: 生成 Swagger 片段失败: %w", service.Desc.FullName(), err)
+	}
+	return string(data), nil
+}
+
+// swaggerPath 将 HTTP 路径模板转换为 Swagger 路径，并返回路径参数名
+// 例如 /v1/{name=users/*}/orders/{id} -> /v1/{name}/orders/{id}
+func swaggerPath(pattern string) (string, []string) {
+	var params []string
+	path := pathParamPattern.ReplaceAllStringFunc(pattern, func(m string) string {
+		name := pathParamPattern.FindStringSubmatch(m)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+// goStringLiteral 优先使用反引号字符串，内容包含反引号时退回双引号字符串
+func goStringLiteral(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,59 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cmd\protoc-gen-gateway\main.go
+ * @Description: protoc/buf 插件入口 - 读取 google.api.http 注解生成 go-rpc-gateway 注册代码
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// protoc-gen-gateway 为带 google.api.http 注解的服务生成 <file>.gateway.go，包含：
+//   - XxxGatewayRoutes 路由元信息（供 Gateway.RegisterRoutes 登记校验钩子与端点）
+//   - XxxSwaggerPaths Swagger paths 片段（可交给 EndpointCollector.CollectFromSwagger）
+//   - RegisterXxxGateway 本地服务一键注册（gRPC 服务 + HTTP Handler + 路由元信息）
+//   - RegisterXxxGatewayProxy 按服务名注册远程代理（复用 grpc.clients 配置）
+//
+// 生成文件与 protoc-gen-go / protoc-gen-go-grpc / protoc-gen-grpc-gateway 的输出位于同一 Go 包
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// version 插件版本
+const version = "v1.0.0"
+
+func main() {
+	if len(os.Args) == 2 && (os.Args[1] == "--version" || os.Args[1] == "-version") {
+		fmt.Printf("protoc-gen-gateway %s\n", version)
+		return
+	}
+
+	var flags flag.FlagSet
+	skipProxy := flags.Bool("skip_proxy", false, "不生成 RegisterXxxGatewayProxy 远程代理注册函数")
+	skipServer := flags.Bool("skip_server", false, "不生成 RegisterXxxGateway 本地服务注册函数")
+
+	protogen.Options{ParamFunc: flags.Set}.Run(func(plugin *protogen.Plugin) error {
+		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		opts := generateOptions{
+			proxy:  !*skipProxy,
+			server: !*skipServer,
+		}
+		for _, file := range plugin.Files {
+			if !file.Generate {
+				continue
+			}
+			if err := generateFile(plugin, file, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
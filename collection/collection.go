@@ -0,0 +1,250 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collection\collection.go
+ * @Description: 接口集合模型 - 把 Swagger 2.0 / OpenAPI 3 规范与手动注册的路由整理为按标签分组的请求列表：
+ *               路径参数统一为 :name 写法，查询参数与请求头按规范填充，请求体由 schema 生成示例
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package collection 把（聚合后的）Swagger / OpenAPI 规范与手动注册的路由导出为 Postman v2.1 集合、
+// Postman 环境与 Insomnia v4 导出文件，baseUrl 与 authToken 以变量形式提供，导入即可调试
+package collection
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// 集合中的变量名
+const (
+	VariableBaseURL   = "baseUrl"
+	VariableAuthToken = "authToken"
+)
+
+// DefaultName 规范没有标题时的集合名称
+const DefaultName = "Gateway API"
+
+// ManualRoutesFolder 规范之外的手动路由所在的分组
+const ManualRoutesFolder = "Manual Routes"
+
+// Config 导出配置
+type Config struct {
+	Name    string // 集合名称，默认取规范标题
+	BaseURL string // baseUrl 变量的初始值，为空时由下载请求推导（scheme://host）
+	// AuthHeader 携带 authToken 的请求头，为空时使用 Authorization: Bearer；
+	// 设置为 X-API-Key 等时以该请求头原样携带
+	AuthHeader string
+}
+
+// Route 规范之外手动注册的路由
+type Route struct {
+	Method  string   // HTTP 方法，为空时视为 GET
+	Path    string   // 路径，兼容 /v1/{id}、/files/{path...} 写法
+	Summary string   // 描述
+	Tags    []string // 分组，为空时归入 Manual Routes
+}
+
+// collection 导出前的中间模型
+type collection struct {
+	Name        string
+	Description string
+	Requests    []*request // 按分组、路径、方法排序
+}
+
+// request 一个请求
+type request struct {
+	ID          string // 由方法与路径生成的稳定 ID，重复导入时覆盖同一请求
+	Name        string
+	Description string
+	Folder      string // 分组，为空时位于集合根部
+	Method      string
+	Path        string // 路径参数为 :name 写法
+	PathParams  []*param
+	Query       []*param
+	Headers     []*param
+	Body        *body
+}
+
+// param 参数
+type param struct {
+	Name        string
+	Value       string
+	Description string
+	Required    bool // 非必填的查询参数与请求头导出为禁用状态
+	File        bool // 表单中的文件字段
+}
+
+// body 请求体
+type body struct {
+	Mime   string   // Content-Type
+	Text   string   // JSON 等文本请求体
+	Fields []*param // 表单请求体的字段，multipart/form-data 或 application/x-www-form-urlencoded
+}
+
+// 请求体类型
+const (
+	mimeJSON      = "application/json"
+	mimeMultipart = "multipart/form-data"
+	mimeForm      = "application/x-www-form-urlencoded"
+)
+
+// pathParamPattern 路径参数，兼容 grpc-gateway 的 {name=pattern} 与 ServeMux 的 {name...} 写法
+var pathParamPattern = regexp.MustCompile(`\{([^}=.]+)(?:\.\.\.)?(?:=[^}]*)?\}`)
+
+// specMethods 规范中的操作方法
+var specMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch,
+}
+
+// build 合并规范与手动路由，同一方法与路径以先出现者为准
+func build(cfg Config, routes []Route, specs ...map[string]any) (*collection, error) {
+	if len(specs) == 0 && len(routes) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "collection: no spec and no routes")
+	}
+	c := &collection{Name: cfg.Name}
+	seen := make(map[string]bool)
+	add := func(req *request) {
+		key := req.Method + " " + req.Path
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		req.ID = stableID(key)
+		c.Requests = append(c.Requests, req)
+	}
+
+	for _, spec := range specs {
+		info, _ := spec["info"].(map[string]any)
+		if c.Name == "" {
+			c.Name, _ = info["title"].(string)
+		}
+		if c.Description == "" {
+			c.Description, _ = info["description"].(string)
+		}
+		s := newSpecReader(spec)
+		paths, _ := spec["paths"].(map[string]any)
+		for _, path := range sortedKeys(paths) {
+			item, _ := paths[path].(map[string]any)
+			for _, method := range specMethods {
+				op, ok := item[strings.ToLower(method)].(map[string]any)
+				if !ok {
+					continue
+				}
+				add(s.request(method, s.basePath+path, item, op))
+			}
+		}
+	}
+
+	for _, route := range routes {
+		method := strings.ToUpper(route.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		folder := ManualRoutesFolder
+		if len(route.Tags) > 0 {
+			folder = route.Tags[0]
+		}
+		req := &request{
+			Name:        route.Summary,
+			Description: route.Summary,
+			Folder:      folder,
+			Method:      method,
+		}
+		req.Path, req.PathParams = convertPath(route.Path, nil)
+		if req.Name == "" {
+			req.Name = method + " " + route.Path
+		}
+		add(req)
+	}
+
+	if c.Name == "" {
+		c.Name = DefaultName
+	}
+	sort.SliceStable(c.Requests, func(i, j int) bool {
+		a, b := c.Requests[i], c.Requests[j]
+		if a.Folder != b.Folder {
+			return a.Folder < b.Folder
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return slices.Index(specMethods, a.Method) < slices.Index(specMethods, b.Method)
+	})
+	return c, nil
+}
+
+// folders 按出现顺序返回分组名（不含根部）
+func (c *collection) folders() []string {
+	var names []string
+	for _, req := range c.Requests {
+		if req.Folder != "" && !slices.Contains(names, req.Folder) {
+			names = append(names, req.Folder)
+		}
+	}
+	return names
+}
+
+// convertPath 把路径参数转换为 :name 写法，described 中有说明的参数带上说明
+func convertPath(path string, described map[string]*param) (string, []*param) {
+	var params []*param
+	converted := pathParamPattern.ReplaceAllStringFunc(path, func(match string) string {
+		name := pathParamPattern.FindStringSubmatch(match)[1]
+		p := &param{Name: name, Required: true}
+		if d, ok := described[name]; ok {
+			p.Value, p.Description = d.Value, d.Description
+		}
+		params = append(params, p)
+		return ":" + name
+	})
+	return converted, params
+}
+
+// stableID 由内容生成稳定的短 ID
+func stableID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stableUUID 由内容生成稳定的 UUID 形式 ID（Postman 要求 _postman_id 为 UUID）
+func stableUUID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	id := hex.EncodeToString(sum[:16])
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
+
+// sortedKeys 按字典序返回 map 的键
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// firstLine 取文本的第一行
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+	return s
+}
+
+// marshalIndent 以两个空格缩进编码，导入工具与人工阅读都更友好
+func marshalIndent(v any) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
@@ -0,0 +1,169 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collection\handler.go
+ * @Description: 接口集合下载 - 每次请求重新加载规范与路由，未配置 BaseURL 时以下载请求的地址作为 baseUrl 初始值
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package collection
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-swagger/loader"
+)
+
+// DefaultPath 下载接口默认路径，网关接入时默认位于 Swagger UI 路径下
+const DefaultPath = "/swagger/collections"
+
+// 可下载的文件
+const (
+	FilePostman            = "postman.json"
+	FilePostmanEnvironment = "postman_environment.json"
+	FileInsomnia           = "insomnia.json"
+)
+
+// Files 可下载的全部文件
+var Files = []string{FilePostman, FilePostmanEnvironment, FileInsomnia}
+
+// HandlerConfig 下载接口配置
+type HandlerConfig struct {
+	Config
+	Path      string      // 接口路径，默认 /swagger/collections（网关接入时为 Swagger UI 路径下的 /collections）
+	SpecFiles []string    // 规范文件，为空时由 SpecSource 提供（网关按 Swagger 配置加载，启用聚合时为聚合规范）
+	Routes    RouteSource // 规范之外的路由；网关构建器未设置时收录通过 RegisterHTTPRoute / RegisterHandler 注册的路由
+}
+
+// SpecSource 每次导出时提供最新的规范
+type SpecSource func() ([]map[string]any, error)
+
+// RouteSource 每次导出时提供规范之外的路由
+type RouteSource func() []Route
+
+// Handler 接口集合下载接口
+type Handler struct {
+	config HandlerConfig
+	source SpecSource
+}
+
+// NewHandler 创建下载接口，配置了 SpecFiles 时忽略 source
+func NewHandler(cfg HandlerConfig, source SpecSource) (*Handler, error) {
+	cfg.Path = strings.TrimSuffix(cfg.Path, "/")
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if len(cfg.SpecFiles) > 0 {
+		source = specFileSource(cfg.SpecFiles)
+	}
+	if source == nil && cfg.Routes == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "collection: no spec files, spec source or routes")
+	}
+	return &Handler{config: cfg, source: source}, nil
+}
+
+// specFileSource 每次重新读取规范文件
+func specFileSource(files []string) SpecSource {
+	return func() ([]map[string]any, error) {
+		specs := make([]map[string]any, 0, len(files))
+		for _, file := range files {
+			spec, err := loader.LoadSpecFromPath(file)
+			if err != nil {
+				return nil, fmt.Errorf("load %s: %w", file, err)
+			}
+			specs = append(specs, spec)
+		}
+		return specs, nil
+	}
+}
+
+// AdminPath 接口路径
+func (h *Handler) AdminPath() string {
+	return h.config.Path
+}
+
+// ServeHTTP 下载接口：
+//
+//	GET {path}/                          可下载的文件列表
+//	GET {path}/postman.json              Postman v2.1 集合
+//	GET {path}/postman_environment.json  Postman 环境（baseUrl、authToken）
+//	GET {path}/insomnia.json             Insomnia v4 导出
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, h.config.Path), "/")
+	if name == "" {
+		downloads := make([]string, 0, len(Files))
+		for _, file := range Files {
+			downloads = append(downloads, h.config.Path+"/"+file)
+		}
+		response.WriteJSONResponse(w, http.StatusOK, map[string]any{"downloads": downloads})
+		return
+	}
+
+	cfg := h.config.Config
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = requestBaseURL(r)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	switch name {
+	case FilePostmanEnvironment:
+		data, err = PostmanEnvironment(cfg)
+	case FilePostman, FileInsomnia:
+		var specs []map[string]any
+		if h.source != nil {
+			if specs, err = h.source(); err != nil {
+				response.WriteServiceUnavailableResult(w, "collection: load spec: "+err.Error())
+				return
+			}
+		}
+		var routes []Route
+		if h.config.Routes != nil {
+			routes = h.config.Routes()
+		}
+		if name == FilePostman {
+			data, err = Postman(cfg, routes, specs...)
+		} else {
+			data, err = Insomnia(cfg, routes, specs...)
+		}
+	default:
+		response.WriteNotFoundResult(w, "unknown collection: "+name)
+		return
+	}
+	if err != nil {
+		response.WriteInternalServerErrorResult(w, err.Error())
+		return
+	}
+
+	w.Header().Set(constants.HeaderContentType, "application/json; charset=utf-8")
+	w.Header().Set(constants.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// requestBaseURL 由下载请求推导网关地址，经反向代理时以 X-Forwarded-Proto 为准
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,193 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collection\insomnia.go
+ * @Description: Insomnia 导出 - v4 导出格式：工作区、携带 baseUrl / authToken 的基础环境、按标签的请求分组与请求，
+ *               资源 ID 由内容生成，重复导入时覆盖而不是新增
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package collection
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+)
+
+// insomniaExportSource 导出来源标识
+const insomniaExportSource = "go-rpc-gateway"
+
+// insomniaExport Insomnia v4 导出文件
+type insomniaExport struct {
+	Type         string `json:"_type"`
+	ExportFormat int    `json:"__export_format"`
+	ExportDate   string `json:"__export_date"`
+	ExportSource string `json:"__export_source"`
+	Resources    []any  `json:"resources"`
+}
+
+// insomniaResource 资源公共字段
+type insomniaResource struct {
+	ID       string `json:"_id"`
+	Type     string `json:"_type"`
+	ParentID string `json:"parentId"`
+	Name     string `json:"name"`
+}
+
+// insomniaWorkspace 工作区
+type insomniaWorkspace struct {
+	insomniaResource
+	Description string `json:"description"`
+	Scope       string `json:"scope"`
+}
+
+// insomniaEnvironment 环境
+type insomniaEnvironment struct {
+	insomniaResource
+	Data map[string]string `json:"data"`
+}
+
+// insomniaGroup 请求分组
+type insomniaGroup struct {
+	insomniaResource
+	Environment map[string]string `json:"environment"`
+}
+
+// insomniaRequest 请求
+type insomniaRequest struct {
+	insomniaResource
+	Method         string         `json:"method"`
+	URL            string         `json:"url"`
+	Description    string         `json:"description"`
+	Headers        []insomniaPair `json:"headers"`
+	Parameters     []insomniaPair `json:"parameters"`
+	PathParameters []insomniaPair `json:"pathParameters,omitempty"`
+	Body           map[string]any `json:"body"`
+	Authentication insomniaAuth   `json:"authentication"`
+}
+
+// insomniaPair 名值对
+type insomniaPair struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Type        string `json:"type,omitempty"` // 表单字段类型，file 为文件
+}
+
+// insomniaAuth 请求认证
+type insomniaAuth struct {
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	AddTo string `json:"addTo,omitempty"`
+}
+
+// Insomnia 生成 Insomnia v4 导出文件
+func Insomnia(cfg Config, routes []Route, specs ...map[string]any) ([]byte, error) {
+	c, err := build(cfg, routes, specs...)
+	if err != nil {
+		return nil, err
+	}
+	workspaceID := "wrk_" + stableID("insomnia:"+c.Name)
+	resources := []any{
+		insomniaWorkspace{
+			insomniaResource: insomniaResource{ID: workspaceID, Type: "workspace", Name: c.Name},
+			Description:      c.Description,
+			Scope:            "collection",
+		},
+		insomniaEnvironment{
+			insomniaResource: insomniaResource{ID: "env_" + stableID("insomnia-environment:"+c.Name), Type: "environment", ParentID: workspaceID, Name: "Base Environment"},
+			Data:             map[string]string{VariableBaseURL: cfg.BaseURL, VariableAuthToken: ""},
+		},
+	}
+
+	groups := make(map[string]string)
+	for _, name := range c.folders() {
+		id := "fld_" + stableID("insomnia-folder:"+c.Name+":"+name)
+		groups[name] = id
+		resources = append(resources, insomniaGroup{
+			insomniaResource: insomniaResource{ID: id, Type: "request_group", ParentID: workspaceID, Name: name},
+			Environment:      map[string]string{},
+		})
+	}
+	auth := insomniaAuthFor(cfg)
+	for _, req := range c.Requests {
+		parent := workspaceID
+		if id, ok := groups[req.Folder]; ok {
+			parent = id
+		}
+		resources = append(resources, insomniaRequestFor(req, parent, auth))
+	}
+
+	return marshalIndent(insomniaExport{
+		Type:         "export",
+		ExportFormat: 4,
+		ExportDate:   time.Now().UTC().Format(time.RFC3339),
+		ExportSource: insomniaExportSource,
+		Resources:    resources,
+	})
+}
+
+// insomniaVar Insomnia 模板中的环境变量引用
+func insomniaVar(name string) string {
+	return "{{ _." + name + " }}"
+}
+
+// insomniaAuthFor 请求认证，Insomnia 没有集合级认证，每个请求各自引用 authToken
+func insomniaAuthFor(cfg Config) insomniaAuth {
+	token := insomniaVar(VariableAuthToken)
+	if cfg.AuthHeader == "" || strings.EqualFold(cfg.AuthHeader, constants.HeaderAuthorization) {
+		return insomniaAuth{Type: "bearer", Token: token}
+	}
+	return insomniaAuth{Type: "apikey", Key: cfg.AuthHeader, Value: token, AddTo: "header"}
+}
+
+// insomniaRequestFor 转换请求
+func insomniaRequestFor(req *request, parent string, auth insomniaAuth) insomniaRequest {
+	out := insomniaRequest{
+		insomniaResource: insomniaResource{ID: "req_" + req.ID, Type: "request", ParentID: parent, Name: req.Name},
+		Method:           req.Method,
+		URL:              insomniaVar(VariableBaseURL) + req.Path,
+		Description:      req.Description,
+		Headers:          []insomniaPair{},
+		Parameters:       []insomniaPair{},
+		Body:             map[string]any{},
+		Authentication:   auth,
+	}
+	for _, p := range req.PathParams {
+		out.PathParameters = append(out.PathParameters, insomniaPair{Name: p.Name, Value: p.Value, Description: p.Description})
+	}
+	for _, p := range req.Query {
+		out.Parameters = append(out.Parameters, insomniaPair{Name: p.Name, Value: p.Value, Description: p.Description, Disabled: !p.Required})
+	}
+	for _, p := range req.Headers {
+		out.Headers = append(out.Headers, insomniaPair{Name: p.Name, Value: p.Value, Description: p.Description, Disabled: !p.Required})
+	}
+	if req.Body == nil {
+		return out
+	}
+
+	out.Body["mimeType"] = req.Body.Mime
+	if req.Body.Fields == nil {
+		out.Headers = append(out.Headers, insomniaPair{Name: constants.HeaderContentType, Value: req.Body.Mime})
+		out.Body["text"] = req.Body.Text
+		return out
+	}
+	params := make([]insomniaPair, 0, len(req.Body.Fields))
+	for _, f := range req.Body.Fields {
+		pair := insomniaPair{Name: f.Name, Value: f.Value, Description: f.Description, Disabled: !f.Required}
+		if f.File {
+			pair.Type = "file"
+		}
+		params = append(params, pair)
+	}
+	out.Body["params"] = params
+	return out
+}
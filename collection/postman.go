@@ -0,0 +1,225 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collection\postman.go
+ * @Description: Postman 导出 - v2.1 集合（按标签分文件夹，集合级认证引用 {{authToken}}）与对应的环境文件
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package collection
+
+import (
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+)
+
+// PostmanSchema Postman 集合 v2.1 的 schema 地址
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanCollection Postman 集合
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []*postmanItem    `json:"item"`
+	Auth     *postmanAuth      `json:"auth,omitempty"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+}
+
+// postmanInfo 集合信息
+type postmanInfo struct {
+	PostmanID   string `json:"_postman_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      string `json:"schema"`
+}
+
+// postmanItem 文件夹（Item 非空）或请求（Request 非空）
+type postmanItem struct {
+	ID      string          `json:"id,omitempty"`
+	Name    string          `json:"name"`
+	Item    []*postmanItem  `json:"item,omitempty"`
+	Request *postmanRequest `json:"request,omitempty"`
+}
+
+// postmanRequest 请求
+type postmanRequest struct {
+	Method      string            `json:"method"`
+	Header      []postmanKeyValue `json:"header"`
+	Body        *postmanBody      `json:"body,omitempty"`
+	URL         postmanURL        `json:"url"`
+	Description string            `json:"description,omitempty"`
+}
+
+// postmanURL 请求地址
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     []string          `json:"host"`
+	Path     []string          `json:"path"`
+	Query    []postmanKeyValue `json:"query,omitempty"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+}
+
+// postmanBody 请求体
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	FormData   []postmanKeyValue `json:"formdata,omitempty"`
+	URLEncoded []postmanKeyValue `json:"urlencoded,omitempty"`
+	Options    map[string]any    `json:"options,omitempty"`
+}
+
+// postmanAuth 认证
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanKeyValue `json:"bearer,omitempty"`
+	APIKey []postmanKeyValue `json:"apikey,omitempty"`
+}
+
+// postmanKeyValue Postman 中通用的键值项
+type postmanKeyValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Enabled     *bool  `json:"enabled,omitempty"` // 仅环境文件使用
+}
+
+// postmanEnvironment Postman 环境
+type postmanEnvironment struct {
+	ID    string            `json:"id"`
+	Name  string            `json:"name"`
+	Scope string            `json:"_postman_variable_scope"`
+	Value []postmanKeyValue `json:"values"`
+}
+
+// Postman 生成 Postman v2.1 集合
+func Postman(cfg Config, routes []Route, specs ...map[string]any) ([]byte, error) {
+	c, err := build(cfg, routes, specs...)
+	if err != nil {
+		return nil, err
+	}
+	out := postmanCollection{
+		Info: postmanInfo{
+			PostmanID:   stableUUID("postman:" + c.Name),
+			Name:        c.Name,
+			Description: c.Description,
+			Schema:      PostmanSchema,
+		},
+		Auth: postmanAuthFor(cfg),
+		Variable: []postmanKeyValue{
+			{Key: VariableBaseURL, Value: cfg.BaseURL, Type: "string"},
+			{Key: VariableAuthToken, Value: "", Type: "string"},
+		},
+	}
+
+	folders := make(map[string]*postmanItem)
+	for _, name := range c.folders() {
+		folder := &postmanItem{Name: name}
+		folders[name] = folder
+		out.Item = append(out.Item, folder)
+	}
+	for _, req := range c.Requests {
+		item := &postmanItem{ID: req.ID, Name: req.Name, Request: postmanRequestFor(req)}
+		if folder, ok := folders[req.Folder]; ok {
+			folder.Item = append(folder.Item, item)
+		} else {
+			out.Item = append(out.Item, item)
+		}
+	}
+	return marshalIndent(out)
+}
+
+// PostmanEnvironment 生成与集合配套的 Postman 环境，authToken 标记为 secret
+func PostmanEnvironment(cfg Config) ([]byte, error) {
+	name := cfg.Name
+	if name == "" {
+		name = DefaultName
+	}
+	enabled := true
+	return marshalIndent(postmanEnvironment{
+		ID:    stableUUID("postman-environment:" + name),
+		Name:  name,
+		Scope: "environment",
+		Value: []postmanKeyValue{
+			{Key: VariableBaseURL, Value: cfg.BaseURL, Type: "default", Enabled: &enabled},
+			{Key: VariableAuthToken, Value: "", Type: "secret", Enabled: &enabled},
+		},
+	})
+}
+
+// postmanAuthFor 集合级认证，请求继承
+func postmanAuthFor(cfg Config) *postmanAuth {
+	token := "{{" + VariableAuthToken + "}}"
+	if cfg.AuthHeader == "" || strings.EqualFold(cfg.AuthHeader, constants.HeaderAuthorization) {
+		return &postmanAuth{Type: "bearer", Bearer: []postmanKeyValue{{Key: "token", Value: token, Type: "string"}}}
+	}
+	return &postmanAuth{Type: "apikey", APIKey: []postmanKeyValue{
+		{Key: "key", Value: cfg.AuthHeader, Type: "string"},
+		{Key: "value", Value: token, Type: "string"},
+		{Key: "in", Value: "header", Type: "string"},
+	}}
+}
+
+// postmanRequestFor 转换请求
+func postmanRequestFor(req *request) *postmanRequest {
+	host := "{{" + VariableBaseURL + "}}"
+	out := &postmanRequest{
+		Method:      req.Method,
+		Header:      []postmanKeyValue{},
+		Description: req.Description,
+		URL: postmanURL{
+			Host: []string{host},
+			Path: strings.Split(strings.TrimPrefix(req.Path, "/"), "/"),
+		},
+	}
+
+	raw := host + req.Path
+	var query []string
+	for _, p := range req.Query {
+		out.URL.Query = append(out.URL.Query, postmanKeyValue{Key: p.Name, Value: p.Value, Description: p.Description, Disabled: !p.Required})
+		if p.Required {
+			query = append(query, p.Name+"="+p.Value)
+		}
+	}
+	if len(query) > 0 {
+		raw += "?" + strings.Join(query, "&")
+	}
+	out.URL.Raw = raw
+	for _, p := range req.PathParams {
+		out.URL.Variable = append(out.URL.Variable, postmanKeyValue{Key: p.Name, Value: p.Value, Description: p.Description})
+	}
+	for _, p := range req.Headers {
+		out.Header = append(out.Header, postmanKeyValue{Key: p.Name, Value: p.Value, Type: "text", Description: p.Description, Disabled: !p.Required})
+	}
+
+	if req.Body == nil {
+		return out
+	}
+	switch req.Body.Mime {
+	case mimeMultipart, mimeForm:
+		fields := make([]postmanKeyValue, 0, len(req.Body.Fields))
+		for _, f := range req.Body.Fields {
+			kv := postmanKeyValue{Key: f.Name, Value: f.Value, Type: "text", Description: f.Description, Disabled: !f.Required}
+			if f.File {
+				kv.Type = "file"
+			}
+			fields = append(fields, kv)
+		}
+		if req.Body.Mime == mimeForm {
+			out.Body = &postmanBody{Mode: "urlencoded", URLEncoded: fields}
+		} else {
+			out.Body = &postmanBody{Mode: "formdata", FormData: fields}
+		}
+	default:
+		out.Header = append(out.Header, postmanKeyValue{Key: constants.HeaderContentType, Value: req.Body.Mime, Type: "text"})
+		out.Body = &postmanBody{Mode: "raw", Raw: req.Body.Text}
+		if req.Body.Mime == mimeJSON {
+			out.Body.Options = map[string]any{"raw": map[string]string{"language": "json"}}
+		}
+	}
+	return out
+}
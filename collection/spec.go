@@ -0,0 +1,385 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collection\spec.go
+ * @Description: 规范读取 - 解析操作的参数与请求体，Swagger 2.0 的 body / formData 参数与 OpenAPI 3 的 requestBody
+ *               统一为请求体；示例值优先取 example / default / enum，否则按类型与格式生成占位值
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package collection
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxExampleDepth 示例生成的最大嵌套深度，避免深层或递归模型生成过大的请求体
+const maxExampleDepth = 6
+
+// specReader 单个规范的读取状态
+type specReader struct {
+	spec     map[string]any
+	basePath string // Swagger 2.0 的 basePath 或 OpenAPI 3 首个相对 servers.url
+	consumes []string
+}
+
+// newSpecReader 创建规范读取器
+func newSpecReader(spec map[string]any) *specReader {
+	s := &specReader{spec: spec, consumes: stringList(spec["consumes"])}
+	if basePath, _ := spec["basePath"].(string); basePath != "/" {
+		s.basePath = strings.TrimSuffix(basePath, "/")
+	}
+	if servers, _ := spec["servers"].([]any); len(servers) > 0 {
+		server, _ := servers[0].(map[string]any)
+		if url, _ := server["url"].(string); strings.HasPrefix(url, "/") {
+			s.basePath = strings.TrimSuffix(url, "/")
+		}
+	}
+	return s
+}
+
+// request 由操作生成请求，路径级参数与操作级参数合并，后者覆盖前者
+func (s *specReader) request(method, path string, item, op map[string]any) *request {
+	summary, _ := op["summary"].(string)
+	description, _ := op["description"].(string)
+	operationID, _ := op["operationId"].(string)
+	req := &request{
+		Name:        firstLine(summary),
+		Description: strings.TrimSpace(description),
+		Method:      method,
+	}
+	if req.Description == "" {
+		req.Description = strings.TrimSpace(summary)
+	}
+	if req.Name == "" {
+		req.Name = operationID
+	}
+	if req.Name == "" {
+		req.Name = method + " " + path
+	}
+	if tags := stringList(op["tags"]); len(tags) > 0 {
+		req.Folder = tags[0]
+	}
+
+	var (
+		pathParams = make(map[string]*param)
+		formFields []*param
+		bodySchema map[string]any
+	)
+	params := make(map[string]map[string]any)
+	var order []string
+	for _, list := range []any{item["parameters"], op["parameters"]} {
+		raw, _ := list.([]any)
+		for _, entry := range raw {
+			p := s.resolve(entry)
+			name, _ := p["name"].(string)
+			in, _ := p["in"].(string)
+			key := in + ":" + name
+			if _, ok := params[key]; !ok {
+				order = append(order, key)
+			}
+			params[key] = p
+		}
+	}
+	for _, key := range order {
+		p := params[key]
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		required, _ := p["required"].(bool)
+		desc, _ := p["description"].(string)
+		out := &param{Name: name, Required: required || in == "path", Description: firstLine(desc)}
+		schema := p
+		if nested, ok := p["schema"].(map[string]any); ok {
+			schema = nested
+		}
+		if in != "body" {
+			out.Value = s.scalarExample(schema)
+		}
+		switch in {
+		case "path":
+			pathParams[name] = out
+		case "query":
+			req.Query = append(req.Query, out)
+		case "header":
+			if !strings.EqualFold(name, "Authorization") {
+				req.Headers = append(req.Headers, out)
+			}
+		case "formData":
+			out.File = schema["type"] == "file"
+			if out.File {
+				out.Value = ""
+			}
+			formFields = append(formFields, out)
+		case "body":
+			bodySchema = schema
+		}
+	}
+	req.Path, req.PathParams = convertPath(path, pathParams)
+
+	switch {
+	case bodySchema != nil:
+		req.Body = &body{Mime: mimeJSON, Text: s.jsonExample(bodySchema)}
+	case len(formFields) > 0:
+		mime := mimeForm
+		consumes := stringList(op["consumes"])
+		if len(consumes) == 0 {
+			consumes = s.consumes
+		}
+		for _, f := range formFields {
+			if f.File || slices.Contains(consumes, mimeMultipart) {
+				mime = mimeMultipart
+			}
+		}
+		req.Body = &body{Mime: mime, Fields: formFields}
+	default:
+		req.Body = s.requestBody(op["requestBody"])
+	}
+	return req
+}
+
+// requestBody OpenAPI 3 的 requestBody，优先 JSON，其次表单，否则取第一个媒体类型的空文本
+func (s *specReader) requestBody(raw any) *body {
+	rb := s.resolve(raw)
+	content, _ := rb["content"].(map[string]any)
+	if len(content) == 0 {
+		return nil
+	}
+	for _, mime := range sortedKeys(content) {
+		if strings.Contains(mime, "json") {
+			media, _ := content[mime].(map[string]any)
+			if example, ok := media["example"]; ok {
+				return &body{Mime: mimeJSON, Text: indentJSON(example)}
+			}
+			schema, _ := media["schema"].(map[string]any)
+			return &body{Mime: mimeJSON, Text: s.jsonExample(schema)}
+		}
+	}
+	for _, mime := range []string{mimeMultipart, mimeForm} {
+		media, ok := content[mime].(map[string]any)
+		if !ok {
+			continue
+		}
+		schema := s.resolve(media["schema"])
+		required := stringList(schema["required"])
+		props, _ := schema["properties"].(map[string]any)
+		b := &body{Mime: mime}
+		for _, name := range sortedKeys(props) {
+			prop := s.resolve(props[name])
+			desc, _ := prop["description"].(string)
+			field := &param{Name: name, Required: slices.Contains(required, name), Description: firstLine(desc)}
+			if format, _ := prop["format"].(string); format == "binary" {
+				field.File = true
+			} else {
+				field.Value = s.scalarExample(prop)
+			}
+			b.Fields = append(b.Fields, field)
+		}
+		return b
+	}
+	return &body{Mime: sortedKeys(content)[0]}
+}
+
+// resolve 解析本地 $ref（#/definitions/...、#/components/...），无法解析时返回原对象
+func (s *specReader) resolve(raw any) map[string]any {
+	m, _ := raw.(map[string]any)
+	for range maxExampleDepth {
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return m
+		}
+		var node any = s.spec
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			parent, _ := node.(map[string]any)
+			node = parent[part]
+		}
+		next, ok := node.(map[string]any)
+		if !ok {
+			return m
+		}
+		m = next
+	}
+	return m
+}
+
+// jsonExample 由 schema 生成缩进后的 JSON 示例
+func (s *specReader) jsonExample(schema map[string]any) string {
+	if schema == nil {
+		return ""
+	}
+	return indentJSON(s.example(schema, 0, nil))
+}
+
+// example 由 schema 生成示例值，seen 记录当前链路上的 $ref 以截断递归模型
+func (s *specReader) example(raw map[string]any, depth int, seen []string) any {
+	if ref, ok := raw["$ref"].(string); ok {
+		if slices.Contains(seen, ref) {
+			return map[string]any{}
+		}
+		seen = append(seen, ref)
+	}
+	schema := s.resolve(raw)
+	if v, ok := schema["example"]; ok {
+		return v
+	}
+	if v, ok := schema["default"]; ok {
+		return v
+	}
+	if enum, _ := schema["enum"].([]any); len(enum) > 0 {
+		return enum[0]
+	}
+	if depth >= maxExampleDepth {
+		return nil
+	}
+
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		parts, _ := schema[key].([]any)
+		if len(parts) == 0 {
+			continue
+		}
+		if key != "allOf" {
+			part, _ := parts[0].(map[string]any)
+			return s.example(part, depth+1, seen)
+		}
+		merged := make(map[string]any)
+		for _, p := range parts {
+			part, _ := p.(map[string]any)
+			if obj, ok := s.example(part, depth+1, seen).(map[string]any); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		obj := make(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		for name, prop := range props {
+			p, _ := prop.(map[string]any)
+			obj[name] = s.example(p, depth+1, seen)
+		}
+		if extra, ok := schema["additionalProperties"].(map[string]any); ok && len(props) == 0 {
+			obj["key"] = s.example(extra, depth+1, seen)
+		}
+		return obj
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		if items == nil {
+			return []any{}
+		}
+		return []any{s.example(items, depth+1, seen)}
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return false
+	default:
+		return formatExample(schema)
+	}
+}
+
+// scalarExample 查询参数、路径参数等标量的示例文本
+func (s *specReader) scalarExample(schema map[string]any) string {
+	schema = s.resolve(schema)
+	for _, key := range []string{"example", "x-example", "default"} {
+		if v, ok := schema[key]; ok {
+			return scalarText(v)
+		}
+	}
+	if enum, _ := schema["enum"].([]any); len(enum) > 0 {
+		return scalarText(enum[0])
+	}
+	if items, ok := schema["items"].(map[string]any); ok && schemaType(schema) == "array" {
+		return s.scalarExample(items)
+	}
+	return ""
+}
+
+// schemaType schema 的类型，未声明类型但有属性时视为对象；OpenAPI 3.1 的类型数组取第一个非 null 类型
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, v := range t {
+			if name, _ := v.(string); name != "null" {
+				return name
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// formatExample 字符串按格式生成占位值
+func formatExample(schema map[string]any) any {
+	if schemaType(schema) != "string" && schemaType(schema) != "" {
+		return nil
+	}
+	switch format, _ := schema["format"].(string); format {
+	case "date-time":
+		return "2026-01-01T00:00:00Z"
+	case "date":
+		return "2026-01-01"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "int64", "uint64":
+		return "0" // protobuf JSON 映射中 64 位整数以字符串表示
+	default:
+		return ""
+	}
+}
+
+// scalarText 标量的文本形式
+func scalarText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		data, _ := json.Marshal(t)
+		return string(data)
+	}
+}
+
+// indentJSON 缩进编码示例值
+func indentJSON(v any) string {
+	data, err := marshalIndent(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// stringList 把 []any 中的字符串取出
+func stringList(raw any) []string {
+	list, _ := raw.([]any)
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\collections.go
+ * @Description: Gateway 接口集合接入 - 导出时在规范之外收录手动注册的 HTTP 路由与 protoc-gen-gateway 登记的路由
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/collection"
+)
+
+// initCollectionExport 注册接口集合下载，未指定 Routes 时使用网关已登记的路由
+func (g *Gateway) initCollectionExport(cfg *collection.HandlerConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	exportCfg := *cfg
+	if exportCfg.Routes == nil {
+		exportCfg.Routes = g.collectionRoutes
+	}
+	return g.Server.SetCollectionExport(&exportCfg)
+}
+
+// collectionRoutes 手动注册的 HTTP 路由与 proto 路由元信息；与规范重复的路由在导出时以规范为准
+// 子树路由（以 / 结尾）没有确定的请求地址，不收录；未带方法前缀的路由取已登记的方法，没有登记时按 GET 导出
+func (g *Gateway) collectionRoutes() []collection.Route {
+	routes := make([]collection.Route, 0, len(g.registeredHTTPRoutes)+len(g.routeInfos))
+	for _, route := range g.routeInfos {
+		routes = append(routes, collection.Route{
+			Method:  route.HTTPMethod,
+			Path:    route.Pattern,
+			Summary: route.Summary,
+			Tags:    []string{route.Service},
+		})
+	}
+	for _, pattern := range g.registeredHTTPRoutes {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") || (strings.HasSuffix(path, "/") && path != "/") {
+			continue
+		}
+		methods := []string{method}
+		if method == "" {
+			methods = methods[:0]
+			for _, m := range g.Server.AllowedMethods(path) {
+				if m != http.MethodHead && m != http.MethodOptions {
+					methods = append(methods, m)
+				}
+			}
+			if len(methods) == 0 {
+				methods = append(methods, http.MethodGet)
+			}
+		}
+		for _, m := range methods {
+			routes = append(routes, collection.Route{Method: m, Path: path})
+		}
+	}
+	return routes
+}
@@ -0,0 +1,102 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\config_history.go
+ * @Description: Gateway 配置版本历史接入 - 启动与每次热更新成功后登记配置文件内容；
+ *               回滚时原子替换配置文件并手动触发重载，与编辑配置文件走同一套热更新回调
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kamalyes/go-rpc-gateway/confighistory"
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// initConfigHistory 创建配置版本历史并登记启动时的配置，未指定命名空间时使用网关名称
+func (g *Gateway) initConfigHistory(cfg *confighistory.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	historyCfg := *cfg
+	if historyCfg.Namespace == "" && g.gatewayConfig != nil {
+		historyCfg.Namespace = g.gatewayConfig.Name
+	}
+	history, err := confighistory.New(historyCfg, g.applyConfigSnapshot)
+	if err != nil {
+		return err
+	}
+	g.configHistory = history
+	g.recordConfigVersion(g.Context(), confighistory.SourceStartup)
+	g.Server.SetConfigHistory(history)
+	return nil
+}
+
+// recordConfigVersion 登记当前配置文件内容，失败只记录日志，不影响已生效的配置
+func (g *Gateway) recordConfigVersion(ctx context.Context, source string) {
+	if g.configHistory == nil {
+		return
+	}
+	path := g.configManager.GetViper().ConfigFileUsed()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		global.LOGGER.WarnKV("读取配置文件失败，未记录配置版本", "path", path, "error", err)
+		return
+	}
+	version, created, err := g.configHistory.Record(ctx, content, filepath.Ext(path), source)
+	if err != nil {
+		global.LOGGER.WarnKV("记录配置版本失败", "path", path, "error", err)
+		return
+	}
+	if created {
+		global.LOGGER.InfoKV("配置版本已记录",
+			"version", version.Version,
+			"checksum", version.Checksum,
+			"source", version.Source)
+	}
+}
+
+// applyConfigSnapshot 以临时文件加重命名的方式替换配置文件（符号链接写入其指向的文件），再触发重载；
+// 重载回调异步执行，使用脱离请求的上下文，避免请求结束后回调被取消
+func (g *Gateway) applyConfigSnapshot(ctx context.Context, content []byte) error {
+	path := g.configManager.GetViper().ConfigFileUsed()
+	if path == "" {
+		return fmt.Errorf("no config file in use")
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return g.configManager.GetHotReloader().Reload(context.WithoutCancel(ctx))
+}
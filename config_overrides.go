@@ -0,0 +1,41 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\config_overrides.go
+ * @Description: 配置覆盖接入 - 环境变量与 --set 参数在合并默认值之后写入配置，
+ *               默认值合并会把 false 与 0 还原为默认值，先合并再覆盖才能让覆盖关闭功能；热更新时重新套用；
+ *               对应不到配置项的环境变量逐个告警，便于发现拼写错误与同前缀的无关变量
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/overrides"
+)
+
+// applyConfigOverrides 把覆盖写入 config 并刷新派生字段；只记录键与来源，值可能是密钥，不写日志
+func applyConfigOverrides(config *gwconfig.Gateway, list []overrides.Override) error {
+	if config == nil || len(list) == 0 {
+		return nil
+	}
+	result, err := overrides.Apply(config, list)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+	refreshGatewayDerivedFields(config)
+
+	for _, o := range result.Applied {
+		global.LOGGER.InfoKV("配置项已覆盖", "key", o.Key, "source", o.Source.String(), "origin", o.Origin)
+	}
+	for _, o := range result.Ignored {
+		global.LOGGER.WarnKV("环境变量对应的配置项不存在，已忽略", "origin", o.Origin, "key", o.Key)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\confighistory\diff.go
+ * @Description: 版本比较 - 把配置文件展开为点分键（列表元素带下标）逐项比较，
+ *               密钥类配置项只报告是否变化，不输出值
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package confighistory
+
+import (
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/redact"
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedValue 密钥类配置项的占位值
+const RedactedValue = redact.Mask
+
+// errUnsupportedFormat 只能展开 YAML 与 JSON 配置文件，其他格式仍可回滚
+var errUnsupportedFormat = stderrors.New("unsupported config format")
+
+// Change 一项配置变化
+type Change struct {
+	Key  string `json:"key"`
+	From any    `json:"from,omitempty"` // 新增的配置项没有 from
+	To   any    `json:"to,omitempty"`   // 删除的配置项没有 to
+}
+
+// Diff 两个版本的差异
+type Diff struct {
+	From    *Version `json:"from"`
+	To      *Version `json:"to"`
+	Added   []Change `json:"added"`
+	Removed []Change `json:"removed"`
+	Changed []Change `json:"changed"`
+}
+
+// Empty 两个版本的配置项完全一致（格式、注释等差异不计）
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// leaf 展开后的配置项，secret 为真时输出占位值
+type leaf struct {
+	value  any
+	secret bool
+}
+
+// output 输出用的值
+func (l leaf) output() any {
+	if l.secret {
+		return RedactedValue
+	}
+	return l.value
+}
+
+// Settings 展开后的配置项（密钥已脱敏），供查看单个版本
+func Settings(content []byte, format string) (map[string]any, error) {
+	leaves, err := parseSettings(content, format)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(leaves))
+	for key, l := range leaves {
+		out[key] = l.output()
+	}
+	return out, nil
+}
+
+// Compare 比较两个版本的配置文件，结果按配置键排序
+func Compare(from *Version, fromContent []byte, to *Version, toContent []byte) (*Diff, error) {
+	before, err := parseSettings(fromContent, from.Format)
+	if err != nil {
+		return nil, fmt.Errorf("config version %d: %w", from.Version, err)
+	}
+	after, err := parseSettings(toContent, to.Format)
+	if err != nil {
+		return nil, fmt.Errorf("config version %d: %w", to.Version, err)
+	}
+
+	diff := &Diff{From: from, To: to, Added: []Change{}, Removed: []Change{}, Changed: []Change{}}
+	for key, b := range before {
+		a, ok := after[key]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, Change{Key: key, From: b.output()})
+		case !reflect.DeepEqual(a.value, b.value):
+			diff.Changed = append(diff.Changed, Change{Key: key, From: b.output(), To: a.output()})
+		}
+	}
+	for key, a := range after {
+		if _, ok := before[key]; !ok {
+			diff.Added = append(diff.Added, Change{Key: key, To: a.output()})
+		}
+	}
+	for _, list := range [][]Change{diff.Added, diff.Removed, diff.Changed} {
+		slices.SortFunc(list, func(x, y Change) int { return strings.Compare(x.Key, y.Key) })
+	}
+	return diff, nil
+}
+
+// parseSettings 解析配置文件并展开为点分键；YAML 解析器同样接受 JSON
+func parseSettings(content []byte, format string) (map[string]leaf, error) {
+	switch format {
+	case "yaml", "yml", "json":
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedFormat, format)
+	}
+	var root map[string]any
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	leaves := map[string]leaf{}
+	flatten(leaves, "", root, false)
+	return leaves, nil
+}
+
+// flatten 展开嵌套的 map 与列表；配置键不区分大小写，统一转为小写，与配置加载一致
+func flatten(out map[string]leaf, key string, node any, secret bool) {
+	switch v := node.(type) {
+	case map[string]any:
+		if len(v) == 0 && key != "" {
+			out[key] = leaf{value: v, secret: secret}
+			return
+		}
+		for k, child := range v {
+			name := strings.ToLower(k)
+			childKey := name
+			if key != "" {
+				childKey = key + "." + name
+			}
+			flatten(out, childKey, child, secret || redact.IsSecretKey(name))
+		}
+	case []any:
+		if len(v) == 0 {
+			out[key] = leaf{value: v, secret: secret}
+			return
+		}
+		for i, child := range v {
+			flatten(out, fmt.Sprintf("%s[%d]", key, i), child, secret)
+		}
+	default:
+		out[key] = leaf{value: v, secret: secret}
+	}
+}
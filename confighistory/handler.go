@@ -0,0 +1,169 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\confighistory\handler.go
+ * @Description: 配置版本管理接口 - 列出版本、查看展开后的配置项、比较两个版本与回滚；
+ *               配置文件原文可能含密钥，接口不返回原文
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package confighistory
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// versionCurrent 路径与查询参数中表示当前生效版本
+const versionCurrent = "current"
+
+// AdminHandler 管理接口，调用方需已认证并具备配置的角色：
+//
+//	GET  {path}                              版本列表与当前生效版本
+//	GET  {path}/{version}                    版本元信息与展开后的配置项，version 为 current 时取当前版本
+//	GET  {path}/diff?from={v}&to={v}         比较两个版本，to 缺省为当前版本
+//	POST {path}/{version}/rollback           回滚到指定版本，热更新异步生效，返回 202
+func (h *History) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p, _ := middleware.PrincipalFromContext(req.Context())
+		if p.IsAnonymous() {
+			response.WriteAppError(w, errors.ErrUnauthorized)
+			return
+		}
+		if !p.HasAnyRole(h.config.Roles...) {
+			response.WriteAppError(w, errors.ErrForbidden)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, h.config.Path), "/"), "/")
+		target, action := parts[0], strings.Join(parts[1:], "/")
+		switch {
+		case target == "" && req.Method == http.MethodGet:
+			h.writeList(w, req)
+		case target == "diff" && action == "" && req.Method == http.MethodGet:
+			h.writeDiff(w, req)
+		case target != "" && target != "diff" && action == "" && req.Method == http.MethodGet:
+			h.writeVersion(w, req, target)
+		case target != "" && action == "rollback" && req.Method == http.MethodPost:
+			version, appErr := h.resolve(req, target)
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			rolled, appErr := h.Rollback(req.Context(), version, p.Subject)
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusAccepted, map[string]any{
+				"target":  rolled,
+				"message": "config written back, hot reload in progress",
+			})
+		case target == "" || action == "" || action == "rollback":
+			response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		default:
+			response.WriteNotFoundResult(w, "unknown config history endpoint")
+		}
+	}
+}
+
+// writeList 写出版本列表
+func (h *History) writeList(w http.ResponseWriter, req *http.Request) {
+	versions, err := h.Versions(req.Context())
+	if err != nil {
+		response.WriteAppErrorf(w, errors.ErrCodeServiceUnavailable, "config history store: %v", err)
+		return
+	}
+	out := map[string]any{"namespace": h.config.Namespace, "versions": versions}
+	if n := len(versions); n > 0 {
+		out["current"] = versions[n-1].Version
+	}
+	response.WriteJSONResponse(w, http.StatusOK, out)
+}
+
+// writeVersion 写出版本元信息与展开后的配置项，无法展开的格式只返回元信息
+func (h *History) writeVersion(w http.ResponseWriter, req *http.Request, target string) {
+	version, appErr := h.resolve(req, target)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	v, content, appErr := h.Get(req.Context(), version)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	settings, err := Settings(content, v.Format)
+	if err != nil && !stderrors.Is(err, errUnsupportedFormat) {
+		response.WriteAppErrorf(w, errors.ErrCodeInvalidConfiguration, "config version %d cannot be parsed: %v", version, err)
+		return
+	}
+	response.WriteJSONResponse(w, http.StatusOK, map[string]any{"version": v, "settings": settings})
+}
+
+// writeDiff 写出两个版本的差异
+func (h *History) writeDiff(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("from") == "" {
+		response.WriteAppErrorf(w, errors.ErrCodeMissingParameter, "query parameter from is required")
+		return
+	}
+	to := query.Get("to")
+	if to == "" {
+		to = versionCurrent
+	}
+	fromVersion, appErr := h.resolve(req, query.Get("from"))
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	toVersion, appErr := h.resolve(req, to)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	from, fromContent, appErr := h.Get(req.Context(), fromVersion)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	next, toContent, appErr := h.Get(req.Context(), toVersion)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	diff, err := Compare(from, fromContent, next, toContent)
+	if err != nil {
+		response.WriteAppErrorf(w, errors.ErrCodeInvalidParameter, "cannot diff config versions: %v", err)
+		return
+	}
+	response.WriteJSONResponse(w, http.StatusOK, diff)
+}
+
+// resolve 解析版本号，current 表示当前生效版本
+func (h *History) resolve(req *http.Request, s string) (int, *errors.AppError) {
+	if s != versionCurrent {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid config version %q", s)
+		}
+		return n, nil
+	}
+	current, err := h.Current(req.Context())
+	if err != nil {
+		return 0, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "config history store: %v", err)
+	}
+	if current == nil {
+		return 0, errors.NewError(errors.ErrCodeNotFound, "no config version recorded yet")
+	}
+	return current.Version, nil
+}
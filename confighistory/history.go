@@ -0,0 +1,358 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\confighistory\history.go
+ * @Description: 配置版本历史 - 每次成功生效的配置文件保存为一个版本（内容、SHA-256 校验和、生效时间与来源），
+ *               内容未变化的重载不产生新版本；回滚把历史版本交给网关写回配置文件并走热更新流程
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package confighistory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+)
+
+// 默认值
+const (
+	DefaultPath        = "/admin/config/versions"
+	DefaultMaxVersions = 20
+	DefaultNamespace   = "default"
+)
+
+// 状态存储键
+const (
+	indexStorePrefix   = "gateway:config:versions:" // + namespace，版本元信息列表
+	contentStorePrefix = "gateway:config:content:"  // + namespace:version，配置文件内容
+	lockStorePrefix    = "gateway:config:lock:"     // + namespace
+	lockTTL            = 10 * time.Second
+	lockAttempts       = 5
+	lockRetryDelay     = 200 * time.Millisecond
+)
+
+// 版本来源
+const (
+	SourceStartup  = "startup"  // 启动时加载
+	SourceReload   = "reload"   // 配置文件变更触发的热更新
+	SourceRollback = "rollback" // 通过管理接口回滚
+)
+
+// Config 配置版本历史
+type Config struct {
+	Path  string   // 管理接口路径，默认 /admin/config/versions
+	Roles []string // 调用方需具备其中任一角色，为空时只要求已认证
+	// Namespace 状态存储中的命名空间，网关接入时默认取网关名称；共用状态存储的多个实例各自回滚本地配置文件，
+	// 配置不一致的实例应使用不同的命名空间
+	Namespace   string
+	MaxVersions int // 保留的版本数，默认 20，超出时删除最旧的版本
+}
+
+// Version 一个配置版本
+type Version struct {
+	Version    int       `json:"version"`
+	Checksum   string    `json:"checksum"` // 配置文件内容的 SHA-256
+	Format     string    `json:"format"`   // 配置文件格式（扩展名），如 yaml、json
+	Size       int       `json:"size"`
+	Source     string    `json:"source"`                // startup、reload、rollback
+	RollbackOf int       `json:"rollback_of,omitempty"` // 回滚时的目标版本
+	AppliedBy  string    `json:"applied_by,omitempty"`  // 回滚的操作人
+	AppliedAt  time.Time `json:"applied_at"`
+}
+
+// Applier 把配置文件内容写回并触发热更新，回滚时调用；热更新回调成功后应调用 Record 登记新版本
+type Applier func(ctx context.Context, content []byte) error
+
+// History 配置版本历史
+type History struct {
+	config Config
+	apply  Applier
+
+	mu       sync.Mutex
+	versions []Version        // 未初始化状态存储时的版本列表
+	contents map[int][]byte   // 未初始化状态存储时的版本内容
+	pending  *pendingRollback // 已写回、等待热更新登记的回滚
+}
+
+// pendingRollback 回滚写回后由下一次登记认领，登记的内容与回滚目标一致时记为回滚版本
+type pendingRollback struct {
+	checksum string
+	target   int
+	by       string
+}
+
+// New 创建配置版本历史
+func New(cfg Config, apply Applier) (*History, error) {
+	if apply == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "config history: applier is required")
+	}
+	cfg.Path = strings.TrimSuffix(cfg.Path, "/")
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "config history: path %q must start with /", cfg.Path)
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+	if cfg.MaxVersions <= 0 {
+		cfg.MaxVersions = DefaultMaxVersions
+	}
+	return &History{config: cfg, apply: apply, contents: map[int][]byte{}}, nil
+}
+
+// AdminPath 管理接口路径
+func (h *History) AdminPath() string {
+	return h.config.Path
+}
+
+// Namespace 状态存储中的命名空间
+func (h *History) Namespace() string {
+	return h.config.Namespace
+}
+
+// Checksum 配置文件内容的 SHA-256
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record 登记已生效的配置文件内容，与最新版本内容相同时不产生新版本，返回最新版本与是否新增
+func (h *History) Record(ctx context.Context, content []byte, format, source string) (*Version, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	unlock, err := h.lock(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	versions, err := h.loadIndex(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	checksum := Checksum(content)
+	if n := len(versions); n > 0 && versions[n-1].Checksum == checksum {
+		h.claimPending(checksum)
+		return &versions[n-1], false, nil
+	}
+
+	next := Version{
+		Version:   1,
+		Checksum:  checksum,
+		Format:    strings.ToLower(strings.TrimPrefix(format, ".")),
+		Size:      len(content),
+		Source:    source,
+		AppliedAt: time.Now().UTC(),
+	}
+	if n := len(versions); n > 0 {
+		next.Version = versions[n-1].Version + 1
+	}
+	if p := h.claimPending(checksum); p != nil {
+		next.Source, next.RollbackOf, next.AppliedBy = SourceRollback, p.target, p.by
+	}
+
+	versions = append(versions, next)
+	var pruned []Version
+	if over := len(versions) - h.config.MaxVersions; over > 0 {
+		pruned, versions = versions[:over], versions[over:]
+	}
+	if err := h.save(ctx, next.Version, content, versions, pruned); err != nil {
+		return nil, false, err
+	}
+	return &next, true, nil
+}
+
+// claimPending 认领与内容一致的回滚；内容不一致说明回滚之后配置文件又被修改，回滚标记作废
+func (h *History) claimPending(checksum string) *pendingRollback {
+	p := h.pending
+	h.pending = nil
+	if p == nil || p.checksum != checksum {
+		return nil
+	}
+	return p
+}
+
+// Versions 全部版本，按版本号升序
+func (h *History) Versions(ctx context.Context) ([]Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.loadIndex(ctx)
+}
+
+// Current 当前生效的版本，尚无版本时返回 nil
+func (h *History) Current(ctx context.Context) (*Version, error) {
+	versions, err := h.Versions(ctx)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[len(versions)-1], nil
+}
+
+// Get 指定版本的元信息与配置文件内容
+func (h *History) Get(ctx context.Context, version int) (*Version, []byte, *errors.AppError) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	versions, err := h.loadIndex(ctx)
+	if err != nil {
+		return nil, nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "config history store: %v", err)
+	}
+	i := slices.IndexFunc(versions, func(v Version) bool { return v.Version == version })
+	if i < 0 {
+		return nil, nil, errors.NewErrorf(errors.ErrCodeNotFound, "config version %d not found", version)
+	}
+	content, err := h.loadContent(ctx, version)
+	if err != nil {
+		return nil, nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "config history store: %v", err)
+	}
+	if Checksum(content) != versions[i].Checksum {
+		return nil, nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "config version %d is corrupt: checksum mismatch", version)
+	}
+	return &versions[i], content, nil
+}
+
+// Rollback 把指定版本写回配置文件并触发热更新；热更新异步执行，生效后登记为新的回滚版本
+func (h *History) Rollback(ctx context.Context, version int, by string) (*Version, *errors.AppError) {
+	target, content, appErr := h.Get(ctx, version)
+	if appErr != nil {
+		return nil, appErr
+	}
+	current, err := h.Current(ctx)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "config history store: %v", err)
+	}
+	if current != nil && current.Checksum == target.Checksum {
+		return nil, errors.NewErrorf(errors.ErrCodeConflict, "config version %d is already active (v%d)", version, current.Version)
+	}
+	if _, err := parseSettings(content, target.Format); err != nil && !stderrors.Is(err, errUnsupportedFormat) {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "config version %d cannot be parsed: %v", version, err)
+	}
+
+	h.mu.Lock()
+	h.pending = &pendingRollback{checksum: target.Checksum, target: version, by: by}
+	h.mu.Unlock()
+	if err := h.apply(ctx, content); err != nil {
+		h.mu.Lock()
+		h.pending = nil
+		h.mu.Unlock()
+		return nil, errors.NewErrorf(errors.ErrCodeOperationFailed, "rollback to config version %d: %v", version, err)
+	}
+	global.LOGGER.InfoKV("配置已回滚，等待热更新生效",
+		"namespace", h.config.Namespace,
+		"version", version,
+		"checksum", target.Checksum,
+		"by", by)
+	return target, nil
+}
+
+// lock 跨实例串行化同一命名空间的登记，未初始化状态存储时只靠本进程的互斥锁
+func (h *History) lock(ctx context.Context) (func(), error) {
+	if global.STORE == nil {
+		return func() {}, nil
+	}
+	key := lockStorePrefix + h.config.Namespace
+	// 多个实例同时启动时会同时登记同一份配置，稍等片刻即可拿到锁并按校验和去重
+	for attempt := 0; ; attempt++ {
+		ok, err := global.STORE.SetNX(ctx, key, "1", lockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() { _ = global.STORE.Del(context.WithoutCancel(ctx), key) }, nil
+		}
+		if attempt == lockAttempts-1 {
+			return nil, fmt.Errorf("config history %s is being modified by another instance", h.config.Namespace)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+}
+
+// loadIndex 读取版本列表，优先读状态存储
+func (h *History) loadIndex(ctx context.Context) ([]Version, error) {
+	if global.STORE == nil {
+		return slices.Clone(h.versions), nil
+	}
+	raw, err := global.STORE.Get(ctx, indexStorePrefix+h.config.Namespace)
+	if stderrors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	if err := json.Unmarshal([]byte(raw), &versions); err != nil {
+		return nil, fmt.Errorf("corrupt config version index: %v", err)
+	}
+	return versions, nil
+}
+
+// loadContent 读取版本内容
+func (h *History) loadContent(ctx context.Context, version int) ([]byte, error) {
+	if global.STORE == nil {
+		content, ok := h.contents[version]
+		if !ok {
+			return nil, fmt.Errorf("content of config version %d is missing", version)
+		}
+		return content, nil
+	}
+	raw, err := global.STORE.Get(ctx, h.contentKey(version))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw), nil
+}
+
+// save 先写内容再写索引，索引中的版本总能读到内容；被淘汰的版本在索引更新后删除
+func (h *History) save(ctx context.Context, version int, content []byte, versions, pruned []Version) error {
+	if global.STORE == nil {
+		h.contents[version] = content
+		for _, v := range pruned {
+			delete(h.contents, v.Version)
+		}
+		h.versions = versions
+		return nil
+	}
+	if err := global.STORE.Set(ctx, h.contentKey(version), string(content), 0); err != nil {
+		return err
+	}
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+	if err := global.STORE.Set(ctx, indexStorePrefix+h.config.Namespace, string(data), 0); err != nil {
+		return err
+	}
+	if len(pruned) > 0 {
+		keys := make([]string, 0, len(pruned))
+		for _, v := range pruned {
+			keys = append(keys, h.contentKey(v.Version))
+		}
+		_ = global.STORE.Del(ctx, keys...)
+	}
+	return nil
+}
+
+// contentKey 版本内容的存储键
+func (h *History) contentKey(version int) string {
+	return fmt.Sprintf("%s%s:%d", contentStorePrefix, h.config.Namespace, version)
+}
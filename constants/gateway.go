@@ -18,4 +18,8 @@ const (
 	DefaultMetricsPath = "/metrics"
 	DefaultDebugPath   = "/debug"
 	PProfBasePath      = "/debug/pprof"
+	ConfigKeyRoutes    = "routes"   // 声明式路由配置段（配置文件顶层）
+	ConfigKeyScripts   = "scripts"  // 脚本钩子配置段（配置文件顶层）
+	ConfigKeySearch    = "search"   // 检索服务配置段（配置文件顶层）
+	ConfigKeyFeatures  = "features" // 按环境的功能开关矩阵（配置文件顶层）
 )
@@ -14,22 +14,53 @@ package constants
 // HTTP 头部常量
 const (
 	// 标准请求头
-	HeaderContentType     = "Content-Type"
-	HeaderContentLanguage = "Content-Language"
-	HeaderContentLength   = "Content-Length"
-	HeaderAuthorization   = "Authorization"
-	HeaderUserAgent       = "User-Agent"
-	HeaderAccept          = "Accept"
-	HeaderAcceptEncoding  = "Accept-Encoding"
-	HeaderAcceptLanguage  = "Accept-Language"
-	HeaderCacheControl    = "Cache-Control"
-	HeaderConnection      = "Connection"
+	HeaderContentType        = "Content-Type"
+	HeaderContentLanguage    = "Content-Language"
+	HeaderContentLength      = "Content-Length"
+	HeaderAuthorization      = "Authorization"
+	HeaderUserAgent          = "User-Agent"
+	HeaderAccept             = "Accept"
+	HeaderAcceptEncoding     = "Accept-Encoding"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderAcceptLanguage     = "Accept-Language"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderConnection         = "Connection"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderETag               = "ETag"
+	HeaderIfNoneMatch        = "If-None-Match"
+	HeaderIfMatch            = "If-Match"
+	HeaderLocation           = "Location"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderTrailer            = "Trailer"
+
+	// CDN 缓存相关头部
+	HeaderSurrogateControl = "Surrogate-Control"
+	HeaderSurrogateKey     = "Surrogate-Key"
+	HeaderCacheTag         = "Cache-Tag"
+	HeaderCDNCacheControl  = "CDN-Cache-Control"
+	HeaderFastlyKey        = "Fastly-Key"
+	HeaderFastlySoftPurge  = "Fastly-Soft-Purge"
 
 	// 自定义请求头
 	HeaderXRequestID      = "X-Request-Id"
 	HeaderXTraceID        = "X-Trace-Id"
 	HeaderXForwardedFor   = "X-Forwarded-For"
 	HeaderWWWAuthenticate = "WWW-Authenticate"
+	HeaderXBuildVersion   = "X-Build-Version"
+	HeaderXBuildCommit    = "X-Build-Commit"
+	HeaderXTimeoutMs      = "X-Timeout-Ms"
+	HeaderGRPCTimeout     = "Grpc-Timeout"
+	HeaderXMediaCache     = "X-Media-Cache"
+	HeaderXJobCallback    = "X-Job-Callback"
+	HeaderXJobSignature   = "X-Job-Signature"
+	HeaderXExportStatus   = "X-Export-Status"
+	HeaderXDegraded       = "X-Gateway-Degraded"
+
+	// Webhook 投递头部
+	HeaderXWebhookID        = "X-Webhook-Id"
+	HeaderXWebhookEvent     = "X-Webhook-Event"
+	HeaderXWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderXWebhookSignature = "X-Webhook-Signature"
 
 	// 安全相关头部
 	HeaderXFrameOptions           = "X-Frame-Options"
@@ -46,8 +77,22 @@ const (
 	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
 	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
 	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
 	HeaderOrigin                        = "Origin"
+	HeaderVary                          = "Vary"
+	HeaderAllow                         = "Allow"
 
 	// CSRF 相关头部
 	HeaderXCSRFToken = "X-CSRF-Token"
 )
+
+// 响应内容类型（httpx 未提供的部分）
+const (
+	ContentTypeProtobuf  = "application/protobuf"
+	ContentTypeXProtobuf = "application/x-protobuf"
+	ContentTypeMsgPack   = "application/msgpack"
+	ContentTypeXMsgPack  = "application/x-msgpack"
+	ContentTypeZip       = "application/zip"
+)
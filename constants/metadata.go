@@ -51,6 +51,12 @@ const (
 	MetadataPushToken      = "x-push-token"
 	MetadataToken          = "x-token"
 	MetadataAcceptLanguage = "accept-language"
+	MetadataBuildVersion   = "x-build-version"
+	MetadataBuildCommit    = "x-build-commit"
+	MetadataGatewayLimited = "x-gateway-ratelimited" // 网关转发的调用已在 HTTP 层限流（值为进程内令牌）
+	MetadataPrincipalRoles = "x-principal-roles"     // 身份角色，逗号分隔
+	MetadataPrincipalScope = "x-principal-scopes"    // 身份授权范围，空格分隔（与 OAuth2 scope 一致）
+	MetadataPrincipalAuth  = "x-principal-auth-type" // 身份的认证方式
 )
 
 // ============================================================================
@@ -60,6 +66,7 @@ const (
 // 上下文相关字段
 const (
 	LogFieldTraceID       = "trace_id"
+	LogFieldSpanID        = "span_id"
 	LogFieldRequestID     = "request_id"
 	LogFieldID            = "id"
 	LogFieldUserID        = "user_id"
@@ -112,6 +119,7 @@ const (
 	LogFieldLatency        = "latency_ms"
 	LogFieldClientStream   = "client_stream"
 	LogFieldServerStream   = "server_stream"
+	LogFieldClientCanceled = "client_canceled"
 )
 
 // 性能和状态相关字段
@@ -139,6 +147,7 @@ const (
 	LogMsgGRPCRequestError   = "gRPC Request Error"
 	LogMsgGRPCStream         = "gRPC Stream"
 	LogMsgGRPCStreamError    = "gRPC Stream Error"
+	LogMsgClientCanceled     = "Client Canceled"
 	LogMsgPanicRecovered     = "PANIC Recovered"
 	LogMsgWriteResponseError = "写入panic响应失败"
 )
@@ -147,3 +156,10 @@ const (
 const (
 	MsgInternalError = "服务器内部错误"
 )
+
+// 错误消息国际化 key（对应 locales 中的翻译）
+const (
+	MsgKeyNotFound         = "error.not_found"
+	MsgKeyMethodNotAllowed = "error.method_not_allowed"
+	MsgKeyInternalError    = "error.internal"
+)
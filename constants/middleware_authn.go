@@ -0,0 +1,45 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\constants\middleware_authn.go
+ * @Description: 认证中间件与统一身份（Principal）相关常量
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package constants
+
+// 认证错误信息
+const (
+	AuthnErrorUnauthenticated = "Authentication required"
+	AuthnErrorInvalid         = "Invalid credentials"
+	AuthnErrorForbidden       = "Principal is not allowed to access this resource"
+	AuthnErrorUnavailable     = "Authentication service unavailable"
+)
+
+// 认证错误代码
+const (
+	AuthnErrorCodeUnauthenticated = "AUTHN_REQUIRED"
+	AuthnErrorCodeInvalid         = "AUTHN_INVALID"
+	AuthnErrorCodeForbidden       = "AUTHN_FORBIDDEN"
+	AuthnErrorCodeUnavailable     = "AUTHN_UNAVAILABLE"
+)
+
+// 认证结果（指标标签）
+const (
+	AuthnResultAuthenticated = "authenticated"
+	AuthnResultAnonymous     = "anonymous"
+	AuthnResultInvalid       = "invalid"
+	AuthnResultForbidden     = "forbidden"
+	AuthnResultUnavailable   = "unavailable"
+)
+
+// 令牌内省结果（指标标签）
+const (
+	IntrospectionResultActive      = "active"
+	IntrospectionResultInactive    = "inactive"
+	IntrospectionResultUnavailable = "unavailable"
+	IntrospectionResultStale       = "stale"
+)
@@ -0,0 +1,31 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\constants\middleware_authz.go
+ * @Description: 外部授权中间件相关常量
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package constants
+
+// 外部授权错误信息
+const (
+	AuthzErrorDenied      = "Request denied by authorization policy"
+	AuthzErrorUnavailable = "Authorization service unavailable"
+)
+
+// 外部授权错误代码
+const (
+	AuthzErrorCodeDenied      = "AUTHZ_DENIED"
+	AuthzErrorCodeUnavailable = "AUTHZ_UNAVAILABLE"
+)
+
+// 外部授权决策结果（指标标签）
+const (
+	AuthzResultAllow = "allow"
+	AuthzResultDeny  = "deny"
+	AuthzResultError = "error"
+)
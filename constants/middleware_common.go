@@ -28,3 +28,33 @@ var MiddlewareDefaultSkipUserAgents = []string{
 var MiddlewareDefaultSkipMethods = []string{
 	"OPTIONS",
 }
+
+// ============================================================================
+// 声明式路由
+// ============================================================================
+
+// 声明式路由转发错误
+const (
+	DeclarativeRouteErrorUpstream     = "Upstream service unavailable"
+	DeclarativeRouteErrorCodeUpstream = "UPSTREAM_UNAVAILABLE"
+)
+
+// 上游失败分类的错误标识（写在 Result.error 的前缀，连接类失败沿用 UPSTREAM_UNAVAILABLE）
+const (
+	UpstreamErrorCodeConnectRefused = "UPSTREAM_CONNECT_REFUSED"
+	UpstreamErrorCodeDNS            = "UPSTREAM_DNS_FAILED"
+	UpstreamErrorCodeTLS            = "UPSTREAM_TLS_FAILED"
+	UpstreamErrorCodeTimeout        = "UPSTREAM_TIMEOUT"
+	UpstreamErrorCodeServer         = "UPSTREAM_SERVER_ERROR"
+	UpstreamErrorCodeMalformed      = "UPSTREAM_MALFORMED_RESPONSE"
+)
+
+// ============================================================================
+// 脚本钩子
+// ============================================================================
+
+// 脚本执行失败错误
+const (
+	ScriptErrorMessage = "Script hook failed"
+	ScriptErrorCode    = "SCRIPT_ERROR"
+)
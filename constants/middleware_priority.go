@@ -0,0 +1,33 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\constants\middleware_priority.go
+ * @Description: 优先级并发限制中间件相关常量
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package constants
+
+// 优先级并发限制错误信息
+const (
+	PriorityErrorShed         = "Server is saturated, request shed"
+	PriorityErrorQueueTimeout = "Server is saturated, request timed out in queue"
+)
+
+// 优先级并发限制错误代码
+const (
+	PriorityErrorCodeShed         = "OVERLOADED_SHED"
+	PriorityErrorCodeQueueTimeout = "OVERLOADED_QUEUE_TIMEOUT"
+)
+
+// 优先级并发限制结果（指标标签）
+const (
+	PriorityResultAdmitted = "admitted" // 未排队直接执行
+	PriorityResultQueued   = "queued"   // 排队后执行
+	PriorityResultShed     = "shed"     // 队列已满被拒绝
+	PriorityResultTimeout  = "timeout"  // 排队超时
+	PriorityResultCanceled = "canceled" // 排队期间客户端断开
+)
@@ -0,0 +1,22 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\constants\middleware_ratelimit.go
+ * @Description: 限流中间件相关常量
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package constants
+
+// 限流判定结果（指标标签）
+const (
+	RateLimitResultAllowed  = "allowed"  // 放行
+	RateLimitResultRejected = "rejected" // 额度不足被拒绝
+	RateLimitResultBanned   = "banned"   // 限流 key 处于封禁期
+)
+
+// RateLimitRouteOther 无法归类到路由时的指标标签
+const RateLimitRouteOther = "other"
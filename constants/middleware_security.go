@@ -188,3 +188,13 @@ const (
 	LogMsgCSRFValidationFailed = "CSRF token failed"
 	LogMsgIPAccessDenied       = "Protected Area: IP access denied"
 )
+
+// 请求头大小限制
+const (
+	HeaderLimitErrorCode = "HEADER_TOO_LARGE"
+
+	HeaderLimitCount = "count" // 条数
+	HeaderLimitName  = "name"  // 单个名称
+	HeaderLimitValue = "value" // 单个值
+	HeaderLimitTotal = "total" // 总字节
+)
@@ -35,3 +35,9 @@ const (
 	SignatureErrorCodeBodyReadFailed   = "BODY_READ_FAILED"
 	SignatureErrorCodeGenerateFailed   = "SIGNATURE_GENERATE_FAILED"
 )
+
+// 签名 URL 错误代码
+const (
+	SignedURLErrorCodeInvalid = "SIGNED_URL_INVALID"
+	SignedURLErrorCodeExpired = "SIGNED_URL_EXPIRED"
+)
@@ -115,6 +115,19 @@ func GetReflectionRegistry(serviceName string) []ReflectionServiceInfo {
 	return reflectionRegistry.services[serviceName]
 }
 
+// ReflectionServiceNames 获取全部客户端通过 reflection 发现的完整服务名
+func ReflectionServiceNames() []string {
+	reflectionRegistry.mu.RLock()
+	defer reflectionRegistry.mu.RUnlock()
+	var names []string
+	for _, services := range reflectionRegistry.services {
+		for _, svc := range services {
+			names = append(names, svc.ServiceName)
+		}
+	}
+	return names
+}
+
 // GetRoutes 获取所有已注册的 HTTP 路由
 func GetRoutes() []HTTPRoute {
 	routeRegistry.mu.RLock()
@@ -292,12 +292,20 @@ func buildDialOptions(clientCfg *gwconfig.GRPCClient, serviceName string, creds
 	// 添加 Context 传播拦截器（确保 trace_id 在服务调用链中传递）
 	dialOpts = append(dialOpts,
 		grpc.WithChainUnaryInterceptor(
-			middleware.UnaryClientRequestContextInterceptor(), // RequestContext 传播
+			middleware.UnaryClientRequestContextInterceptor(),      // RequestContext 传播
+			middleware.UnaryClientBulkheadInterceptor(serviceName), // 舱壁隔离（按服务名独占并发槽位）
+			middleware.UnaryClientIdentityInterceptor(serviceName), // 出站身份传递（按服务名选择配置）
 			UnaryClientHealthInterceptor(serviceName, healthChecker),
+			middleware.UnaryClientUpstreamTimingInterceptor(),           // 上游耗时计入 HTTP 请求指标
+			middleware.UnaryClientUpstreamErrorInterceptor(serviceName), // 上游失败分类（最内层，只看到真实调用的错误）
 		),
 		grpc.WithChainStreamInterceptor(
-			middleware.StreamClientRequestContextInterceptor(), // Stream RequestContext 传播
+			middleware.StreamClientRequestContextInterceptor(),      // Stream RequestContext 传播
+			middleware.StreamClientBulkheadInterceptor(serviceName), // Stream 舱壁隔离
+			middleware.StreamClientIdentityInterceptor(serviceName), // Stream 出站身份传递
 			StreamClientHealthInterceptor(serviceName, healthChecker),
+			middleware.StreamClientUpstreamTimingInterceptor(),           // Stream 上游耗时
+			middleware.StreamClientUpstreamErrorInterceptor(serviceName), // Stream 上游失败分类
 		),
 	)
 
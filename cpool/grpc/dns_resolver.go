@@ -0,0 +1,567 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cpool\grpc\dns_resolver.go
+ * @Description: DNS 上游解析 - 以主机名指定的上游按记录 TTL 刷新 A/AAAA/SRV 解析结果，
+ *               解析失败时沿用最近一次成功的结果并退避重试，提供解析指标
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package grpc
+
+import (
+	"bufio"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gwglobal "github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/net/dns/dnsmessage"
+	"google.golang.org/grpc/resolver"
+)
+
+// DNSResolverScheme DNS 上游解析的 target scheme，如 gwdns:///user-service:9000、
+// gwdns:///_grpc._tcp.user-service.default.svc.cluster.local（SRV 记录提供端口）
+const DNSResolverScheme = "gwdns"
+
+const (
+	DefaultDNSMinTTL        = 5 * time.Second
+	DefaultDNSMaxTTL        = 5 * time.Minute
+	DefaultDNSTimeout       = 2 * time.Second
+	DefaultDNSRetryInterval = time.Second
+	dnsResolvConf           = "/etc/resolv.conf"
+	dnsMaxUDPSize           = 512 // 未使用 EDNS0 时的 UDP 响应上限，超出时服务器置截断标志
+)
+
+// 解析结果（指标标签）
+const (
+	dnsResultSuccess = "success"
+	dnsResultStale   = "stale"   // 解析失败，沿用最近一次成功的结果
+	dnsResultFailure = "failure" // 解析失败且没有可用的历史结果
+)
+
+// DNS 解析指标
+var (
+	dnsResolutionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_dns_resolutions_total",
+		Help: "Total number of upstream DNS resolutions",
+	}, []string{"host", "result"})
+
+	dnsAddressesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_dns_addresses",
+		Help: "Number of addresses currently resolved for an upstream host",
+	}, []string{"host"})
+)
+
+// DNSResolverConfig DNS 上游解析配置
+// 刷新间隔取记录中最小的 TTL，并限制在 [MinTTL, MaxTTL] 之间；解析失败时从 RetryInterval 开始指数退避，最长 MaxTTL
+type DNSResolverConfig struct {
+	Nameservers   []string      // DNS 服务器 host:port，默认读取 /etc/resolv.conf
+	MinTTL        time.Duration // 最短刷新间隔（同时限制连接失败触发的重新解析频率），默认 5s
+	MaxTTL        time.Duration // 最长刷新间隔，默认 5m
+	Timeout       time.Duration // 单次查询超时，默认 2s
+	RetryInterval time.Duration // 解析失败后的首次重试间隔，默认 1s
+}
+
+// dnsDefault 当前 DNS 解析配置
+var dnsDefault atomic.Pointer[DNSResolverConfig]
+
+// SetDNSResolverConfig 设置 DNS 上游解析配置，对之后建立的通道生效
+func SetDNSResolverConfig(cfg DNSResolverConfig) error {
+	if cfg.MinTTL < 0 || cfg.MaxTTL < 0 || cfg.Timeout < 0 || cfg.RetryInterval < 0 {
+		return fmt.Errorf("invalid dns resolver config: durations must not be negative")
+	}
+	if cfg.MinTTL > 0 && cfg.MaxTTL > 0 && cfg.MinTTL > cfg.MaxTTL {
+		return fmt.Errorf("invalid dns resolver config: min ttl %v exceeds max ttl %v", cfg.MinTTL, cfg.MaxTTL)
+	}
+	for _, ns := range cfg.Nameservers {
+		if _, _, err := net.SplitHostPort(ns); err != nil {
+			return fmt.Errorf("invalid dns nameserver %q: %w", ns, err)
+		}
+	}
+	dnsDefault.Store(&cfg)
+	return nil
+}
+
+// currentDNSResolverConfig 当前配置（补齐默认值）
+func currentDNSResolverConfig() DNSResolverConfig {
+	var cfg DNSResolverConfig
+	if c := dnsDefault.Load(); c != nil {
+		cfg = *c
+	}
+	if cfg.MinTTL == 0 {
+		cfg.MinTTL = DefaultDNSMinTTL
+	}
+	if cfg.MaxTTL == 0 {
+		cfg.MaxTTL = max(DefaultDNSMaxTTL, cfg.MinTTL)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultDNSTimeout
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = DefaultDNSRetryInterval
+	}
+	return cfg
+}
+
+func init() {
+	resolver.Register(dnsResolverBuilder{})
+}
+
+// dnsResolverBuilder DNS 上游解析构建器
+type dnsResolverBuilder struct{}
+
+// Scheme 实现 resolver.Builder
+func (dnsResolverBuilder) Scheme() string {
+	return DNSResolverScheme
+}
+
+// Build 实现 resolver.Builder
+func (dnsResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	endpoint := strings.TrimPrefix(target.Endpoint(), "/")
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		if !strings.HasPrefix(endpoint, "_") {
+			return nil, fmt.Errorf("gwdns target %q requires host:port or an SRV name", endpoint)
+		}
+		host, port = endpoint, ""
+	}
+	if host == "" {
+		return nil, fmt.Errorf("gwdns target %q has an empty host", endpoint)
+	}
+
+	// IP 地址无需解析
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return &staticResolver{}, cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: net.JoinHostPort(ip.String(), port)}}})
+	}
+
+	cfg := currentDNSResolverConfig()
+	client, err := newDNSClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		cc:      cc,
+		host:    host,
+		port:    port,
+		config:  cfg,
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		refresh: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// staticResolver IP 地址目标的空解析器
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (staticResolver) Close()                                {}
+
+// dnsResolver 单个目标的 DNS 解析器
+type dnsResolver struct {
+	cc     resolver.ClientConn
+	host   string
+	port   string // 为空时使用 SRV 记录中的端口
+	config DNSResolverConfig
+	client *dnsClient
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	refresh chan struct{}
+	wg      sync.WaitGroup
+
+	lastGood []resolver.Address // 最近一次成功解析的地址
+}
+
+// ResolveNow 实现 resolver.Resolver，连接失败时由 gRPC 调用，刷新频率受 MinTTL 限制
+func (r *dnsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Close 实现 resolver.Resolver
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+	dnsAddressesGauge.DeleteLabelValues(r.host)
+}
+
+// watch 按 TTL 循环解析，失败时退避重试
+func (r *dnsResolver) watch() {
+	defer r.wg.Done()
+	failures := 0
+	for {
+		resolvedAt := time.Now()
+		wait := r.resolve(&failures)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-r.refresh:
+			timer.Stop()
+			// 连接失败触发的重新解析至少间隔 MinTTL
+			if early := r.config.MinTTL - time.Since(resolvedAt); early > 0 {
+				select {
+				case <-r.ctx.Done():
+					return
+				case <-time.After(early):
+				}
+			}
+		}
+	}
+}
+
+// resolve 解析一次并更新通道状态，返回距下次解析的间隔
+func (r *dnsResolver) resolve(failures *int) time.Duration {
+	ctx, cancel := context.WithTimeout(r.ctx, 4*r.config.Timeout) // 覆盖 A / AAAA 与 search 域的多次查询
+	addrs, ttl, err := r.lookup(ctx)
+	cancel()
+	if r.ctx.Err() != nil {
+		return 0
+	}
+
+	if err != nil {
+		*failures++
+		backoff := min(r.config.RetryInterval<<min(*failures-1, 16), r.config.MaxTTL)
+		if r.lastGood != nil {
+			dnsResolutionsTotal.WithLabelValues(r.host, dnsResultStale).Inc()
+			gwglobal.LOGGER.WarnKV("⚠️  DNS 解析失败，沿用最近一次结果", "host", r.host, "addresses", len(r.lastGood), "retry_in", backoff, "error", err)
+		} else {
+			dnsResolutionsTotal.WithLabelValues(r.host, dnsResultFailure).Inc()
+			gwglobal.LOGGER.WarnKV("⚠️  DNS 解析失败", "host", r.host, "retry_in", backoff, "error", err)
+			r.cc.ReportError(err)
+		}
+		return backoff
+	}
+
+	*failures = 0
+	dnsResolutionsTotal.WithLabelValues(r.host, dnsResultSuccess).Inc()
+	dnsAddressesGauge.WithLabelValues(r.host).Set(float64(len(addrs)))
+	if !sameAddresses(addrs, r.lastGood) {
+		// 地址集合变化时打乱顺序，pick_first 客户端也能分散到不同实例
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+		if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+			gwglobal.LOGGER.WarnKV("⚠️  更新 DNS 解析结果失败", "host", r.host, "error", err)
+		}
+		gwglobal.LOGGER.DebugKV("🔎 DNS 解析结果更新", "host", r.host, "addresses", len(addrs), "ttl", ttl)
+		r.lastGood = addrs
+	}
+	return min(max(ttl, r.config.MinTTL), r.config.MaxTTL)
+}
+
+// lookup 解析目标：SRV 名称先取 SRV 记录再解析各 target，否则解析 A / AAAA；返回地址与最小 TTL
+func (r *dnsResolver) lookup(ctx context.Context) ([]resolver.Address, time.Duration, error) {
+	if r.port != "" {
+		ips, ttl, err := r.client.lookupIP(ctx, r.host)
+		if err != nil {
+			return nil, 0, err
+		}
+		addrs := make([]resolver.Address, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(ip.String(), r.port)})
+		}
+		return addrs, ttl, nil
+	}
+
+	srvs, ttl, err := r.client.lookupSRV(ctx, r.host)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		addrs   []resolver.Address
+		lastErr error
+	)
+	for _, srv := range srvs {
+		ips, ipTTL, err := r.client.lookupIP(ctx, srv.target)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ttl = min(ttl, ipTTL)
+		for _, ip := range ips {
+			addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(ip.String(), strconv.Itoa(int(srv.port)))})
+		}
+	}
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses for SRV targets of %s", r.host)
+		}
+		return nil, 0, lastErr
+	}
+	return addrs, ttl, nil
+}
+
+// sameAddresses 两组地址是否相同（忽略顺序）
+func sameAddresses(a, b []resolver.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, addr := range a {
+		set[addr.Addr] = true
+	}
+	for _, addr := range b {
+		if !set[addr.Addr] {
+			return false
+		}
+	}
+	return true
+}
+
+// errDNSNoRecords 域名存在但没有请求类型的记录，或域名不存在
+var errDNSNoRecords = stderrors.New("no dns records")
+
+// dnsClient 直接查询 DNS 服务器的客户端（标准库解析不提供 TTL）
+type dnsClient struct {
+	servers []string
+	search  []string
+	ndots   int
+	timeout time.Duration
+}
+
+// srvTarget SRV 记录
+type srvTarget struct {
+	target string
+	port   uint16
+}
+
+// newDNSClient 按配置与 /etc/resolv.conf 创建客户端
+func newDNSClient(cfg DNSResolverConfig) (*dnsClient, error) {
+	c := &dnsClient{servers: cfg.Nameservers, ndots: 1, timeout: cfg.Timeout}
+	if f, err := os.Open(dnsResolvConf); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "nameserver":
+				if len(cfg.Nameservers) == 0 {
+					c.servers = append(c.servers, net.JoinHostPort(fields[1], "53"))
+				}
+			case "search", "domain":
+				c.search = fields[1:]
+			case "options":
+				for _, opt := range fields[1:] {
+					if n, ok := strings.CutPrefix(opt, "ndots:"); ok {
+						c.ndots, _ = strconv.Atoi(n)
+					}
+				}
+			}
+		}
+		_ = f.Close()
+	}
+	if len(c.servers) == 0 {
+		return nil, fmt.Errorf("no dns nameservers configured and none found in %s", dnsResolvConf)
+	}
+	return c, nil
+}
+
+// candidates 按 search / ndots 规则生成待查询的完整域名
+func (c *dnsClient) candidates(name string) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	absolute := name + "."
+	var names []string
+	if strings.Count(name, ".") >= c.ndots {
+		names = append(names, absolute)
+	}
+	for _, domain := range c.search {
+		names = append(names, name+"."+strings.Trim(domain, ".")+".")
+	}
+	if !slices.Contains(names, absolute) {
+		names = append(names, absolute)
+	}
+	return names
+}
+
+// lookupIP 解析 A 与 AAAA 记录
+func (c *dnsClient) lookupIP(ctx context.Context, name string) ([]netip.Addr, time.Duration, error) {
+	var lastErr error
+	for _, fqdn := range c.candidates(name) {
+		var (
+			ips []netip.Addr
+			ttl = time.Duration(-1)
+		)
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			answers, err := c.query(ctx, fqdn, qtype)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for _, rr := range answers {
+				switch body := rr.Body.(type) {
+				case *dnsmessage.AResource:
+					ips = append(ips, netip.AddrFrom4(body.A))
+				case *dnsmessage.AAAAResource:
+					ips = append(ips, netip.AddrFrom16(body.AAAA))
+				default:
+					continue
+				}
+				ttl = minTTL(ttl, rr.Header.TTL)
+			}
+		}
+		if len(ips) > 0 {
+			return ips, ttl, nil
+		}
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%s: %w", name, errDNSNoRecords)
+	}
+	return nil, 0, lastErr
+}
+
+// lookupSRV 解析 SRV 记录
+func (c *dnsClient) lookupSRV(ctx context.Context, name string) ([]srvTarget, time.Duration, error) {
+	var lastErr error
+	for _, fqdn := range c.candidates(name) {
+		answers, err := c.query(ctx, fqdn, dnsmessage.TypeSRV)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var (
+			targets []srvTarget
+			ttl     = time.Duration(-1)
+		)
+		for _, rr := range answers {
+			if body, ok := rr.Body.(*dnsmessage.SRVResource); ok {
+				targets = append(targets, srvTarget{target: body.Target.String(), port: body.Port})
+				ttl = minTTL(ttl, rr.Header.TTL)
+			}
+		}
+		if len(targets) > 0 {
+			return targets, ttl, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%s: %w", name, errDNSNoRecords)
+	}
+	return nil, 0, lastErr
+}
+
+// minTTL 较小的 TTL，current < 0 表示尚无记录
+func minTTL(current time.Duration, ttl uint32) time.Duration {
+	d := time.Duration(ttl) * time.Second
+	if current < 0 || d < current {
+		return d
+	}
+	return current
+}
+
+// query 依次向各 DNS 服务器查询，UDP 响应被截断时改用 TCP；域名不存在或没有记录返回空结果
+func (c *dnsClient) query(ctx context.Context, fqdn string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range c.servers {
+		resp, err := c.exchange(ctx, "udp", server, packed)
+		if err == nil && resp.Truncated {
+			resp, err = c.exchange(ctx, "tcp", server, packed)
+		}
+		if err == nil && resp.ID != msg.ID {
+			err = fmt.Errorf("dns response id mismatch from %s", server)
+		}
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		switch resp.RCode {
+		case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+			return resp.Answers, nil
+		default:
+			lastErr = fmt.Errorf("dns server %s returned %s for %s", server, resp.RCode, fqdn)
+		}
+	}
+	return nil, lastErr
+}
+
+// exchange 发送一次查询；TCP 报文带两字节长度前缀
+func (c *dnsClient) exchange(ctx context.Context, network, server string, packed []byte) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	buf := make([]byte, dnsMaxUDPSize)
+	var n int
+	if network == "tcp" {
+		req := append([]byte{byte(len(packed) >> 8), byte(len(packed))}, packed...)
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, int(length[0])<<8|int(length[1]))
+		if n, err = io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return nil, err
+		}
+		if n, err = conn.Read(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		return nil, fmt.Errorf("invalid dns response from %s: %w", server, err)
+	}
+	return &resp, nil
+}
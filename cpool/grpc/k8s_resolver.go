@@ -0,0 +1,481 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cpool\grpc\k8s_resolver.go
+ * @Description: Kubernetes 服务发现 - 通过 K8s API 列出并 watch Service 的 EndpointSlice，
+ *               就绪的 Pod 地址实时推送给负载均衡器，适用于 headless Service 与需要绕过 kube-proxy 的场景
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gwglobal "github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/resolver"
+)
+
+// K8sResolverScheme Kubernetes 服务发现的 target scheme：
+// k8s:///service.namespace:port，port 为端口号或 EndpointSlice 中的端口名，省略 namespace 时使用 Pod 所在命名空间
+const K8sResolverScheme = "k8s"
+
+const (
+	DefaultK8sTokenFile      = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultK8sCAFile         = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultK8sNamespaceFile  = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	DefaultK8sResyncInterval = 5 * time.Minute
+	DefaultK8sRetryInterval  = time.Second
+	k8sMaxRetryInterval      = 30 * time.Second
+)
+
+// Kubernetes 服务发现指标
+var (
+	k8sEndpointsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_k8s_endpoints",
+		Help: "Number of ready endpoints discovered from Kubernetes EndpointSlices",
+	}, []string{"service"})
+
+	k8sWatchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_k8s_watch_errors_total",
+		Help: "Total number of Kubernetes EndpointSlice list/watch failures",
+	}, []string{"service"})
+)
+
+// K8sDiscoveryConfig Kubernetes 服务发现配置，默认使用 Pod 内的 ServiceAccount
+// ServiceAccount 需要 discovery.k8s.io/endpointslices 的 list 与 watch 权限
+type K8sDiscoveryConfig struct {
+	APIServer       string        // API Server 地址，默认 https://$KUBERNETES_SERVICE_HOST:$KUBERNETES_SERVICE_PORT
+	TokenFile       string        // Bearer token 文件（每次请求重新读取以支持轮换）
+	CAFile          string        // API Server CA 证书
+	Namespace       string        // target 未指定命名空间时使用，默认读取 ServiceAccount 命名空间
+	ResyncInterval  time.Duration // watch 连接的最长持续时间，到期后重新 list，默认 5m
+	IncludeNotReady bool          // 同时使用未就绪（但 serving）的端点
+	InsecureSkipTLS bool          // 跳过 API Server 证书校验（仅用于调试）
+	RequestTimeout  time.Duration // list 请求超时，默认 10s
+	HTTPClient      *http.Client  // 自定义 HTTP 客户端（测试或集群外访问），设置后忽略 CAFile / InsecureSkipTLS
+}
+
+// k8sDefault 当前 Kubernetes 服务发现配置
+var k8sDefault atomic.Pointer[K8sDiscoveryConfig]
+
+// SetK8sDiscoveryConfig 设置 Kubernetes 服务发现配置，对之后建立的通道生效
+func SetK8sDiscoveryConfig(cfg K8sDiscoveryConfig) error {
+	if cfg.ResyncInterval < 0 || cfg.RequestTimeout < 0 {
+		return fmt.Errorf("invalid k8s discovery config: durations must not be negative")
+	}
+	if cfg.APIServer != "" {
+		if u, err := url.Parse(cfg.APIServer); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid k8s api server %q", cfg.APIServer)
+		}
+	}
+	k8sDefault.Store(&cfg)
+	return nil
+}
+
+func init() {
+	resolver.Register(k8sResolverBuilder{})
+}
+
+// k8sResolverBuilder Kubernetes 服务发现构建器
+type k8sResolverBuilder struct{}
+
+// Scheme 实现 resolver.Builder
+func (k8sResolverBuilder) Scheme() string {
+	return K8sResolverScheme
+}
+
+// Build 实现 resolver.Builder
+func (k8sResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var cfg K8sDiscoveryConfig
+	if c := k8sDefault.Load(); c != nil {
+		cfg = *c
+	}
+	client, err := newK8sClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimPrefix(target.Endpoint(), "/")
+	hostPart, port, err := net.SplitHostPort(endpoint)
+	if err != nil || port == "" {
+		return nil, fmt.Errorf("k8s target %q requires service[.namespace]:port", endpoint)
+	}
+	service, namespace, _ := strings.Cut(hostPart, ".")
+	namespace, _, _ = strings.Cut(namespace, ".") // 允许 service.namespace.svc.cluster.local
+	if namespace == "" {
+		namespace = client.namespace
+	}
+	if service == "" || namespace == "" {
+		return nil, fmt.Errorf("k8s target %q requires a service name and namespace", endpoint)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &k8sResolver{
+		cc:        cc,
+		client:    client,
+		service:   service,
+		namespace: namespace,
+		port:      port,
+		label:     namespace + "/" + service,
+		ctx:       ctx,
+		cancel:    cancel,
+		slices:    make(map[string]k8sEndpointSlice),
+		refresh:   make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// k8sClient 最小化的 K8s API 客户端
+type k8sClient struct {
+	config    K8sDiscoveryConfig
+	base      string
+	namespace string
+	http      *http.Client
+}
+
+// newK8sClient 按配置与 ServiceAccount 创建客户端
+func newK8sClient(cfg K8sDiscoveryConfig) (*k8sClient, error) {
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = DefaultK8sTokenFile
+	}
+	if cfg.CAFile == "" {
+		cfg.CAFile = DefaultK8sCAFile
+	}
+	if cfg.ResyncInterval == 0 {
+		cfg.ResyncInterval = DefaultK8sResyncInterval
+	}
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = 10 * time.Second
+	}
+
+	base := cfg.APIServer
+	if base == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("k8s api server is not configured and KUBERNETES_SERVICE_HOST is not set")
+		}
+		base = "https://" + net.JoinHostPort(host, port)
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		if data, err := os.ReadFile(DefaultK8sNamespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipTLS}
+		if !cfg.InsecureSkipTLS {
+			ca, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read k8s ca file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates in k8s ca file %s", cfg.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}}
+	}
+	return &k8sClient{config: cfg, base: strings.TrimSuffix(base, "/"), namespace: namespace, http: client}, nil
+}
+
+// get 发送带 ServiceAccount token 的 GET 请求
+func (c *k8sClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token, err := os.ReadFile(c.config.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		resp.Body.Close()
+		return nil, &k8sStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
+
+// k8sStatusError API Server 返回的非 200 响应
+type k8sStatusError struct {
+	code int
+	body string
+}
+
+func (e *k8sStatusError) Error() string {
+	return fmt.Sprintf("k8s api returned %d: %s", e.code, e.body)
+}
+
+// k8sEndpointSlice EndpointSlice（只解析服务发现需要的字段）
+type k8sEndpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	AddressType string `json:"addressType"`
+	Endpoints   []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready       *bool `json:"ready"`
+			Serving     *bool `json:"serving"`
+			Terminating *bool `json:"terminating"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name *string `json:"name"`
+		Port *int32  `json:"port"`
+	} `json:"ports"`
+}
+
+// k8sEndpointSliceList EndpointSlice 列表
+type k8sEndpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []k8sEndpointSlice `json:"items"`
+}
+
+// k8sWatchEvent watch 事件
+type k8sWatchEvent struct {
+	Type   string          `json:"type"` // ADDED / MODIFIED / DELETED / BOOKMARK / ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// k8sResolver 单个 Service 的服务发现
+type k8sResolver struct {
+	cc        resolver.ClientConn
+	client    *k8sClient
+	service   string
+	namespace string
+	port      string // 端口号或端口名
+	label     string // 指标标签 namespace/service
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	refresh chan struct{}
+
+	slices    map[string]k8sEndpointSlice // 名称 -> EndpointSlice
+	published []resolver.Address          // 最近一次推送的地址
+	synced    bool                        // 是否成功 list 过
+}
+
+// ResolveNow 实现 resolver.Resolver；watch 已实时推送，这里只在 watch 中断重试期间提前重新 list
+func (r *k8sResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Close 实现 resolver.Resolver
+func (r *k8sResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+	k8sEndpointsGauge.DeleteLabelValues(r.label)
+}
+
+// run list 后持续 watch，出错时退避后重新 list；失败期间保留最近一次推送的地址
+func (r *k8sResolver) run() {
+	defer r.wg.Done()
+	retry := DefaultK8sRetryInterval
+	for r.ctx.Err() == nil {
+		resourceVersion, err := r.list()
+		if err == nil {
+			retry = DefaultK8sRetryInterval
+			err = r.watch(resourceVersion)
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue // watch 正常到期，重新 list
+		}
+
+		k8sWatchErrorsTotal.WithLabelValues(r.label).Inc()
+		gwglobal.LOGGER.WarnKV("⚠️  K8s 服务发现失败", "service", r.label, "retry_in", retry, "addresses", len(r.published), "error", err)
+		if !r.synced {
+			r.cc.ReportError(err)
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(retry):
+		case <-r.refresh:
+		}
+		retry = min(retry*2, k8sMaxRetryInterval)
+	}
+}
+
+// slicesPath EndpointSlice API 路径
+func (r *k8sResolver) slicesPath() string {
+	return "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(r.namespace) + "/endpointslices"
+}
+
+// selector 选择 Service 的 EndpointSlice
+func (r *k8sResolver) selector() string {
+	return "kubernetes.io/service-name=" + r.service
+}
+
+// list 全量拉取 EndpointSlice 并推送地址，返回列表的 resourceVersion
+func (r *k8sResolver) list() (string, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.client.config.RequestTimeout)
+	defer cancel()
+	resp, err := r.client.get(ctx, r.slicesPath(), url.Values{"labelSelector": {r.selector()}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list k8sEndpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("decode endpointslice list: %w", err)
+	}
+	r.slices = make(map[string]k8sEndpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		r.slices[slice.Metadata.Name] = slice
+	}
+	r.synced = true
+	r.publish()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch 从 resourceVersion 开始 watch，ResyncInterval 到期时正常返回；resourceVersion 过期（410）时返回错误以重新 list
+func (r *k8sResolver) watch(resourceVersion string) error {
+	timeout := int(r.client.config.ResyncInterval / time.Second)
+	resp, err := r.client.get(r.ctx, r.slicesPath(), url.Values{
+		"labelSelector":       {r.selector()},
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(max(timeout, 1))},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var event k8sWatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("decode watch event: %w", err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			var slice k8sEndpointSlice
+			if err := json.Unmarshal(event.Object, &slice); err != nil {
+				return fmt.Errorf("decode endpointslice: %w", err)
+			}
+			if event.Type == "DELETED" {
+				delete(r.slices, slice.Metadata.Name)
+			} else {
+				r.slices[slice.Metadata.Name] = slice
+			}
+			r.publish()
+		case "ERROR":
+			return fmt.Errorf("watch error event: %s", event.Object)
+		}
+	}
+	if err := scanner.Err(); err != nil && r.ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// publish 汇总全部 EndpointSlice 的可用地址，变化时推送给负载均衡器
+func (r *k8sResolver) publish() {
+	var addrs []resolver.Address
+	seen := make(map[string]bool)
+	for _, slice := range r.slices {
+		if slice.AddressType == "FQDN" {
+			continue
+		}
+		port, ok := r.slicePort(slice)
+		if !ok {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if !r.usable(ep.Conditions.Ready, ep.Conditions.Serving) {
+				continue
+			}
+			for _, ip := range ep.Addresses {
+				addr := net.JoinHostPort(ip, port)
+				if !seen[addr] {
+					seen[addr] = true
+					addrs = append(addrs, resolver.Address{Addr: addr})
+				}
+			}
+		}
+	}
+	slices.SortFunc(addrs, func(a, b resolver.Address) int { return strings.Compare(a.Addr, b.Addr) })
+	k8sEndpointsGauge.WithLabelValues(r.label).Set(float64(len(addrs)))
+
+	if r.published != nil && slices.EqualFunc(addrs, r.published, func(a, b resolver.Address) bool { return a.Addr == b.Addr }) {
+		return
+	}
+	r.published = addrs
+	if len(addrs) == 0 {
+		gwglobal.LOGGER.WarnKV("⚠️  K8s 服务没有可用端点", "service", r.label)
+	} else {
+		gwglobal.LOGGER.DebugKV("🔎 K8s 服务端点更新", "service", r.label, "endpoints", len(addrs))
+	}
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		gwglobal.LOGGER.DebugKV("更新 K8s 服务端点失败", "service", r.label, "error", err)
+	}
+}
+
+// slicePort 端口号直接使用；端口名在 EndpointSlice 的端口列表中查找
+func (r *k8sResolver) slicePort(slice k8sEndpointSlice) (string, bool) {
+	if _, err := strconv.Atoi(r.port); err == nil {
+		return r.port, true
+	}
+	for _, p := range slice.Ports {
+		if p.Name != nil && *p.Name == r.port && p.Port != nil {
+			return strconv.Itoa(int(*p.Port)), true
+		}
+	}
+	return "", false
+}
+
+// usable 端点是否可用：ready 为空视为就绪；IncludeNotReady 时 serving 的端点也可用
+func (r *k8sResolver) usable(ready, serving *bool) bool {
+	if ready == nil || *ready {
+		return true
+	}
+	return r.client.config.IncludeNotReady && serving != nil && *serving
+}
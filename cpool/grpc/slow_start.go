@@ -0,0 +1,278 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cpool\grpc\slow_start.go
+ * @Description: 慢启动负载均衡 - 服务发现新增（或重新就绪）的上游实例在预热窗口内逐步提升流量权重，
+ *               避免冷缓存、未预热的实例一上线就承担全部负载
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gwglobal "github.com/kamalyes/go-rpc-gateway/global"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// SlowStartBalancerName 慢启动轮询负载均衡策略名，配置 load-balance-policy 使用
+const SlowStartBalancerName = "slow_start_round_robin"
+
+const (
+	DefaultSlowStartWindow     = 30 * time.Second // 默认预热窗口
+	DefaultSlowStartMinWeight  = 0.1              // 默认预热开始时的最低权重（相对完全预热的实例）
+	DefaultSlowStartAggression = 1.0              // 默认线性提升
+)
+
+// SlowStartConfig 慢启动配置
+// 预热中实例的相对权重 = max(MinWeight, (已就绪时长 / Window) ^ (1 / Aggression))，
+// 通道首次解析到的实例不预热，之后服务发现新增或从故障恢复为就绪的实例进入预热
+type SlowStartConfig struct {
+	Window     time.Duration `json:"window"`     // 预热窗口，默认 30s
+	MinWeight  float64       `json:"minWeight"`  // 最低权重（0~1），默认 0.1
+	Aggression float64       `json:"aggression"` // 提升曲线，>1 前期提升更快，<1 前期更保守，默认 1
+}
+
+// slowStartDefault 未在 service config 中指定参数的通道使用的配置
+var slowStartDefault atomic.Pointer[SlowStartConfig]
+
+// SetSlowStartConfig 设置默认慢启动配置，对之后重建的负载均衡选择器生效
+func SetSlowStartConfig(cfg SlowStartConfig) error {
+	normalized, err := cfg.normalize()
+	if err != nil {
+		return err
+	}
+	slowStartDefault.Store(&normalized)
+	return nil
+}
+
+// normalize 校验并补齐默认值
+func (c SlowStartConfig) normalize() (SlowStartConfig, error) {
+	if c.Window < 0 || c.MinWeight < 0 || c.MinWeight > 1 || c.Aggression < 0 {
+		return c, fmt.Errorf("invalid slow start config: window=%v min_weight=%v aggression=%v", c.Window, c.MinWeight, c.Aggression)
+	}
+	if c.Window == 0 {
+		c.Window = DefaultSlowStartWindow
+	}
+	if c.MinWeight == 0 {
+		c.MinWeight = DefaultSlowStartMinWeight
+	}
+	if c.Aggression == 0 {
+		c.Aggression = DefaultSlowStartAggression
+	}
+	return c, nil
+}
+
+// currentSlowStartConfig 当前默认配置
+func currentSlowStartConfig() SlowStartConfig {
+	if cfg := slowStartDefault.Load(); cfg != nil {
+		return *cfg
+	}
+	cfg, _ := SlowStartConfig{}.normalize()
+	return cfg
+}
+
+func init() {
+	balancer.Register(slowStartBuilder{})
+}
+
+// slowStartLBConfig service config 中的策略参数，如
+// {"loadBalancingConfig":[{"slow_start_round_robin":{"window":"60s","minWeight":0.05}}]}
+type slowStartLBConfig struct {
+	serviceconfig.LoadBalancingConfig
+	config SlowStartConfig
+}
+
+// slowStartBuilder 慢启动负载均衡构建器，每个通道独立记录实例的就绪时间
+type slowStartBuilder struct{}
+
+// Name 实现 balancer.Builder
+func (slowStartBuilder) Name() string {
+	return SlowStartBalancerName
+}
+
+// Build 实现 balancer.Builder
+func (slowStartBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &slowStartPickerBuilder{readySince: make(map[string]time.Time), initial: make(map[string]bool), created: time.Now()}
+	return &slowStartBalancer{
+		Balancer: base.NewBalancerBuilder(SlowStartBalancerName, pb, base.Config{HealthCheck: true}).Build(cc, opts),
+		pb:       pb,
+	}
+}
+
+// ParseConfig 实现 balancer.ConfigParser
+func (slowStartBuilder) ParseConfig(raw json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	var parsed struct {
+		Window     string  `json:"window"`
+		MinWeight  float64 `json:"minWeight"`
+		Aggression float64 `json:"aggression"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid slow start config: %w", err)
+		}
+	}
+	cfg := SlowStartConfig{MinWeight: parsed.MinWeight, Aggression: parsed.Aggression}
+	if parsed.Window != "" {
+		window, err := time.ParseDuration(parsed.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slow start window %q: %w", parsed.Window, err)
+		}
+		cfg.Window = window
+	}
+	if cfg == (SlowStartConfig{}) {
+		return &slowStartLBConfig{}, nil // 未指定参数时使用默认配置
+	}
+	normalized, err := cfg.normalize()
+	if err != nil {
+		return nil, err
+	}
+	return &slowStartLBConfig{config: normalized}, nil
+}
+
+// slowStartBalancer 在 base 负载均衡器之上接收 service config 参数
+type slowStartBalancer struct {
+	balancer.Balancer
+	pb *slowStartPickerBuilder
+}
+
+// UpdateClientConnState 记录通道级参数与首次解析到的实例后交给 base 负载均衡器
+func (b *slowStartBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	cfg, _ := s.BalancerConfig.(*slowStartLBConfig)
+	b.pb.update(cfg, s.ResolverState.Addresses)
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+// slowStartPickerBuilder 按就绪实例集合生成选择器，记录每个实例开始就绪的时间
+type slowStartPickerBuilder struct {
+	mu         sync.Mutex
+	config     *SlowStartConfig     // 通道级参数，nil 使用默认配置
+	readySince map[string]time.Time // 地址 -> 开始就绪时间，零值表示无需预热
+	initial    map[string]bool      // 首次解析到、尚未就绪的实例，就绪时不预热
+	resolved   bool                 // 是否已收到过解析结果
+	created    time.Time
+}
+
+// update 记录通道级参数；首次解析结果中的实例视为通道建立时已存在
+func (pb *slowStartPickerBuilder) update(cfg *slowStartLBConfig, addrs []resolver.Address) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	pb.config = nil
+	if cfg != nil && cfg.config.Window > 0 {
+		pb.config = &cfg.config
+	}
+	if !pb.resolved {
+		for _, addr := range addrs {
+			pb.initial[addr.Addr] = true
+		}
+		pb.resolved = true
+	}
+}
+
+// Build 实现 base.PickerBuilder
+func (pb *slowStartPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	cfg := currentSlowStartConfig()
+	if pb.config != nil {
+		cfg = *pb.config
+	}
+
+	now := time.Now()
+	ready := make(map[string]bool, len(info.ReadySCs))
+	p := &slowStartPicker{config: cfg, subConns: make([]slowStartSubConn, 0, len(info.ReadySCs))}
+	for sc, scInfo := range info.ReadySCs {
+		addr := scInfo.Address.Addr
+		ready[addr] = true
+		since, known := pb.readySince[addr]
+		if !known {
+			// 首次解析到的实例在通道建立后一个预热窗口内就绪时不预热
+			if !pb.initial[addr] || now.Sub(pb.created) > cfg.Window {
+				since = now
+				gwglobal.LOGGER.InfoKV("🐢 上游实例进入慢启动", "address", addr, "window", cfg.Window)
+			}
+			delete(pb.initial, addr)
+			pb.readySince[addr] = since
+		}
+		p.subConns = append(p.subConns, slowStartSubConn{sc: sc, since: since})
+		if !since.IsZero() && since.Add(cfg.Window).After(p.warmUntil) {
+			p.warmUntil = since.Add(cfg.Window)
+		}
+	}
+	// 不再就绪的实例移除记录，恢复就绪后重新预热
+	for addr := range pb.readySince {
+		if !ready[addr] {
+			delete(pb.readySince, addr)
+		}
+	}
+	p.next.Store(rand.Uint32())
+	return p
+}
+
+// slowStartSubConn 就绪实例
+type slowStartSubConn struct {
+	sc    balancer.SubConn
+	since time.Time // 零值表示无需预热
+}
+
+// slowStartPicker 预热期间按权重随机选择，全部预热完成后退化为轮询
+type slowStartPicker struct {
+	config    SlowStartConfig
+	subConns  []slowStartSubConn
+	warmUntil time.Time // 最后一个实例完成预热的时间
+	next      atomic.Uint32
+}
+
+// Pick 实现 balancer.Picker
+func (p *slowStartPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	now := time.Now()
+	if len(p.subConns) == 1 || !now.Before(p.warmUntil) {
+		idx := p.next.Add(1) % uint32(len(p.subConns))
+		return balancer.PickResult{SubConn: p.subConns[idx].sc}, nil
+	}
+
+	weights := make([]float64, len(p.subConns))
+	var total float64
+	for i, s := range p.subConns {
+		weights[i] = p.weight(s.since, now)
+		total += weights[i]
+	}
+	target := rand.Float64() * total
+	for i, w := range weights {
+		if target < w {
+			return balancer.PickResult{SubConn: p.subConns[i].sc}, nil
+		}
+		target -= w
+	}
+	return balancer.PickResult{SubConn: p.subConns[len(p.subConns)-1].sc}, nil
+}
+
+// weight 实例当前的相对权重
+func (p *slowStartPicker) weight(since, now time.Time) float64 {
+	if since.IsZero() {
+		return 1
+	}
+	elapsed := now.Sub(since)
+	if elapsed >= p.config.Window {
+		return 1
+	}
+	factor := math.Pow(math.Max(float64(elapsed), 1)/float64(p.config.Window), 1/p.config.Aggression)
+	return math.Max(p.config.MinWeight, factor)
+}
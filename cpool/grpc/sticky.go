@@ -0,0 +1,136 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\cpool\grpc\sticky.go
+ * @Description: 会话保持负载均衡 - 有状态的旧服务需要同一客户端始终落到同一实例：按请求中的亲和信息选择实例，
+ *               固定的实例下线后改派到其余就绪实例并由会话保持中间件写回新的亲和 Cookie
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package grpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// StickyBalancerName 会话保持负载均衡策略名，配置 load-balance-policy 使用
+const StickyBalancerName = "sticky_round_robin"
+
+// 选择结果（指标标签）
+const (
+	stickyResultHit      = "hit"      // 亲和 Cookie 指向的实例仍就绪
+	stickyResultAssigned = "assigned" // 新客户端，轮询分配
+	stickyResultFailover = "failover" // 亲和 Cookie 指向的实例已不可用，改派
+	stickyResultHashed   = "hashed"   // 按业务会话 Cookie 哈希
+)
+
+// stickyPicksTotal 会话保持选择次数（注册到默认 Registry）
+var stickyPicksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_sticky_session_picks_total",
+	Help: "Total number of sticky session instance picks by result",
+}, []string{"result"})
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(StickyBalancerName, stickyPickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+// StickyInstanceID 实例标识：地址 SHA-256 摘要的前 16 位十六进制，各网关副本一致且不暴露内网地址
+func StickyInstanceID(addr string) string {
+	sum := sha256.Sum256([]byte(addr))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stickyPickerBuilder 按就绪实例集合生成选择器
+type stickyPickerBuilder struct{}
+
+// Build 实现 base.PickerBuilder
+func (stickyPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &stickyPicker{byID: make(map[string]*stickyInstance, len(info.ReadySCs))}
+	for sc, scInfo := range info.ReadySCs {
+		p.instances = append(p.instances, &stickyInstance{id: StickyInstanceID(scInfo.Address.Addr), sc: sc})
+	}
+	sort.Slice(p.instances, func(i, j int) bool { return p.instances[i].id < p.instances[j].id })
+	for _, inst := range p.instances {
+		p.byID[inst.id] = inst
+	}
+	p.next.Store(rand.Uint32())
+	return p
+}
+
+// stickyInstance 就绪实例
+type stickyInstance struct {
+	id string
+	sc balancer.SubConn
+}
+
+// stickyPicker 有亲和信息时按亲和选择，否则轮询
+type stickyPicker struct {
+	instances []*stickyInstance
+	byID      map[string]*stickyInstance
+	next      atomic.Uint32
+}
+
+// Pick 实现 balancer.Picker
+func (p *stickyPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	session := middleware.StickySessionFromContext(info.Ctx)
+	if session == nil {
+		return balancer.PickResult{SubConn: p.roundRobin().sc}, nil
+	}
+
+	var inst *stickyInstance
+	result := stickyResultAssigned
+	switch key := session.Key(); {
+	case session.Hash():
+		inst, result = p.rendezvous(key), stickyResultHashed
+	case key != "":
+		var ok bool
+		if inst, ok = p.byID[key]; ok {
+			result = stickyResultHit
+		} else {
+			inst, result = p.roundRobin(), stickyResultFailover
+		}
+	default:
+		inst = p.roundRobin()
+	}
+	session.Assign(inst.id)
+	stickyPicksTotal.WithLabelValues(result).Inc()
+	return balancer.PickResult{SubConn: inst.sc}, nil
+}
+
+// roundRobin 轮询选择
+func (p *stickyPicker) roundRobin() *stickyInstance {
+	return p.instances[p.next.Add(1)%uint32(len(p.instances))]
+}
+
+// rendezvous 最高随机权重哈希：实例增减时只有落在该实例上的会话会迁移
+func (p *stickyPicker) rendezvous(key string) *stickyInstance {
+	var best *stickyInstance
+	var bestScore uint64
+	for _, inst := range p.instances {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(inst.id))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = inst, score
+		}
+	}
+	return best
+}
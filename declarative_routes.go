@@ -0,0 +1,29 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\declarative_routes.go
+ * @Description: 声明式路由加载 - 启动与配置热更新时读取配置文件顶层 routes 段
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/server"
+)
+
+// applyDeclarativeRoutes 解析 routes 段并替换服务器上的声明式路由，raw 为 nil（未配置）时关闭
+func applyDeclarativeRoutes(srv *server.Server, raw any) error {
+	if raw == nil {
+		return srv.SetDeclarativeRoutes(nil)
+	}
+	cfg, err := middleware.ParseDeclarativeRoutes(raw)
+	if err != nil {
+		return err
+	}
+	return srv.SetDeclarativeRoutes(cfg)
+}
@@ -64,6 +64,15 @@ const (
 	ErrCodeGRPCTimeout          ErrorCode = 6004
 	ErrCodeGRPCCanceled         ErrorCode = 6005
 
+	// 上游错误 (6100-6199)
+	ErrCodeUpstreamConnectRefused    ErrorCode = 6101
+	ErrCodeUpstreamDNSFailed         ErrorCode = 6102
+	ErrCodeUpstreamTLSFailed         ErrorCode = 6103
+	ErrCodeUpstreamTimeout           ErrorCode = 6104
+	ErrCodeUpstreamServerError       ErrorCode = 6105
+	ErrCodeUpstreamMalformedResponse ErrorCode = 6106
+	ErrCodeUpstreamConnectionFailed  ErrorCode = 6107
+
 	// 健康检查错误 (7000-7999)
 	ErrCodeHealthCheckFailed      ErrorCode = 7001
 	ErrCodeHealthCheckTimeout     ErrorCode = 7002
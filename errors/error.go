@@ -62,6 +62,14 @@ var errorMessages = map[ErrorCode]string{
 	ErrCodeSwaggerNotFound:        "Swagger JSON not found",
 	ErrCodeSwaggerLoadFailed:      "Failed to load Swagger",
 	ErrCodeSwaggerRenderFailed:    "Failed to render Swagger UI",
+	// 上游错误
+	ErrCodeUpstreamConnectRefused:    "Upstream connection refused",
+	ErrCodeUpstreamDNSFailed:         "Upstream DNS resolution failed",
+	ErrCodeUpstreamTLSFailed:         "Upstream TLS handshake failed",
+	ErrCodeUpstreamTimeout:           "Upstream timeout",
+	ErrCodeUpstreamServerError:       "Upstream server error",
+	ErrCodeUpstreamMalformedResponse: "Upstream returned a malformed response",
+	ErrCodeUpstreamConnectionFailed:  "Upstream connection failed",
 	// JWT和认证扩展
 	ErrCodeTokenMalformed:        "Token格式错误",
 	ErrCodeTokenNotValidYet:      "Token尚未激活",
@@ -150,6 +158,14 @@ var httpStatusMapping = map[ErrorCode]int{
 	ErrCodeSwaggerNotFound:        http.StatusNotFound,
 	ErrCodeSwaggerLoadFailed:      http.StatusInternalServerError,
 	ErrCodeSwaggerRenderFailed:    http.StatusInternalServerError,
+	// 上游错误
+	ErrCodeUpstreamConnectRefused:    http.StatusBadGateway,
+	ErrCodeUpstreamDNSFailed:         http.StatusBadGateway,
+	ErrCodeUpstreamTLSFailed:         http.StatusBadGateway,
+	ErrCodeUpstreamTimeout:           http.StatusGatewayTimeout,
+	ErrCodeUpstreamServerError:       http.StatusBadGateway,
+	ErrCodeUpstreamMalformedResponse: http.StatusBadGateway,
+	ErrCodeUpstreamConnectionFailed:  http.StatusBadGateway,
 	// JWT和认证扩展
 	ErrCodeTokenMalformed:        http.StatusUnauthorized,
 	ErrCodeTokenNotValidYet:      http.StatusUnauthorized,
@@ -238,6 +254,14 @@ var statusCodeMapping = map[ErrorCode]commonapis.StatusCode{
 	ErrCodeSwaggerNotFound:        commonapis.StatusCode_NotFound,
 	ErrCodeSwaggerLoadFailed:      commonapis.StatusCode_Internal,
 	ErrCodeSwaggerRenderFailed:    commonapis.StatusCode_Internal,
+	// 上游错误
+	ErrCodeUpstreamConnectRefused:    commonapis.StatusCode_Unavailable,
+	ErrCodeUpstreamDNSFailed:         commonapis.StatusCode_Unavailable,
+	ErrCodeUpstreamTLSFailed:         commonapis.StatusCode_Unavailable,
+	ErrCodeUpstreamTimeout:           commonapis.StatusCode_DeadlineExceeded,
+	ErrCodeUpstreamServerError:       commonapis.StatusCode_Internal,
+	ErrCodeUpstreamMalformedResponse: commonapis.StatusCode_Internal,
+	ErrCodeUpstreamConnectionFailed:  commonapis.StatusCode_Unavailable,
 	// JWT和认证扩展
 	ErrCodeTokenMalformed:        commonapis.StatusCode_Unauthenticated,
 	ErrCodeTokenNotValidYet:      commonapis.StatusCode_Unauthenticated,
@@ -551,6 +575,17 @@ var (
 	ErrGRPCCanceled         = NewError(ErrCodeGRPCCanceled, "")
 )
 
+// 上游错误
+var (
+	ErrUpstreamConnectRefused    = NewError(ErrCodeUpstreamConnectRefused, "")
+	ErrUpstreamDNSFailed         = NewError(ErrCodeUpstreamDNSFailed, "")
+	ErrUpstreamTLSFailed         = NewError(ErrCodeUpstreamTLSFailed, "")
+	ErrUpstreamTimeout           = NewError(ErrCodeUpstreamTimeout, "")
+	ErrUpstreamServerError       = NewError(ErrCodeUpstreamServerError, "")
+	ErrUpstreamMalformedResponse = NewError(ErrCodeUpstreamMalformedResponse, "")
+	ErrUpstreamConnectionFailed  = NewError(ErrCodeUpstreamConnectionFailed, "")
+)
+
 // 健康检查错误
 var (
 	ErrHealthCheckFailed      = NewError(ErrCodeHealthCheckFailed, "")
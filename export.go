@@ -0,0 +1,74 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\export.go
+ * @Description: 流式导出入口 - 业务处理器把查询结果逐行写成 CSV / XLSX 下载，与 RegisterImport 对应
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/exporter"
+)
+
+// StreamCSV 以默认选项把 rows 写成 CSV 下载
+//
+// 使用示例:
+//
+//	gw.RegisterHTTPRoute("/api/v1/users/export", func(w http.ResponseWriter, r *http.Request) {
+//		_, err := gateway.StreamCSV(w, []string{"ID", "姓名", "邮箱"}, func(ctx context.Context, emit func([]any) error) error {
+//			rows, err := global.DB.WithContext(ctx).Model(&User{}).Rows()
+//			if err != nil {
+//				return err
+//			}
+//			defer rows.Close()
+//			for rows.Next() {
+//				var u User
+//				if err := global.DB.ScanRows(rows, &u); err != nil {
+//					return err
+//				}
+//				if err := emit([]any{u.ID, u.Name, u.Email}); err != nil {
+//					return err
+//				}
+//			}
+//			return rows.Err()
+//		})
+//		if err != nil {
+//			global.LOGGER.WarnKV("导出失败", "error", err)
+//		}
+//	})
+func StreamCSV(w http.ResponseWriter, columns []string, rows exporter.RowIterator) (*exporter.Result, error) {
+	return exporter.StreamCSV(w, columns, rows, exporter.Options{})
+}
+
+// StreamCSVWith 按选项把 rows 写成 CSV 下载，可设置取消上下文、文件名、行数上限与带宽上限
+func StreamCSVWith(w http.ResponseWriter, columns []string, rows exporter.RowIterator, opts exporter.Options) (*exporter.Result, error) {
+	return exporter.StreamCSV(w, columns, rows, opts)
+}
+
+// StreamXLSX 以默认选项把 rows 写成 XLSX 下载
+func StreamXLSX(w http.ResponseWriter, columns []string, rows exporter.RowIterator) (*exporter.Result, error) {
+	return exporter.StreamXLSX(w, columns, rows, exporter.Options{})
+}
+
+// StreamXLSXWith 按选项把 rows 写成 XLSX 下载
+//
+// 使用示例:
+//
+//	var exportBandwidth = exporter.NewBandwidth(5 << 20) // 该路由所有导出合计 5MiB/s
+//
+//	gateway.StreamXLSXWith(w, columns, rows, exporter.Options{
+//		Context:   r.Context(),
+//		Filename:  "orders-2026-10.xlsx",
+//		MaxRows:   500000,
+//		Bandwidth: exportBandwidth,
+//	})
+func StreamXLSXWith(w http.ResponseWriter, columns []string, rows exporter.RowIterator, opts exporter.Options) (*exporter.Result, error) {
+	return exporter.StreamXLSX(w, columns, rows, opts)
+}
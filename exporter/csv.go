@@ -0,0 +1,132 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\exporter\csv.go
+ * @Description: CSV 流式导出与单元格取值 - 指针、driver.Valuer 先取底层值，文本默认转义公式前缀
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package exporter
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+)
+
+// bufferSize 格式层缓冲大小，未超出前迭代器出错仍可返回普通错误响应
+const bufferSize = 32 << 10
+
+// utf8BOM 让 Excel 按 UTF-8 打开 CSV
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// StreamCSV 把 rows 逐行写成 CSV 下载响应，columns 为表头（为空时不写表头）。
+// 写出任何内容前出错时撤销下载响应头并返回错误，调用方可正常返回错误响应；之后出错时连接上的文件不完整，
+// 尾部头 X-Export-Status 为 aborted
+func StreamCSV(w http.ResponseWriter, columns []string, rows RowIterator, opts Options) (*Result, error) {
+	s := newStream(w, FormatCSV, httpx.ContentTypeTextCSVCharacterUTF8, opts)
+	bw := bufio.NewWriterSize(s, bufferSize)
+	if !s.opts.NoBOM {
+		_, _ = bw.Write(utf8BOM)
+	}
+	cw := csv.NewWriter(bw)
+	if len(columns) > 0 {
+		_ = cw.Write(columns)
+	}
+
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	record := make([]string, 0, len(columns))
+	write := func(row []any) error {
+		record = record[:0]
+		for _, v := range row {
+			record = append(record, s.text(v))
+		}
+		return cw.Write(record)
+	}
+	return s.run(rows, s.opts.MaxRows, write, flush, flush)
+}
+
+// text 单元格文本
+func (s *stream) text(v any) string {
+	v = underlying(v)
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if !s.opts.KeepFormulas && x != "" && isFormulaPrefix(x[0]) {
+			return "'" + x
+		}
+		return x
+	case []byte:
+		return s.text(string(x))
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(s.opts.TimeLayout)
+	case bool:
+		return strconv.FormatBool(x)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case fmt.Stringer:
+		return s.text(x.String())
+	}
+	return fmt.Sprint(v)
+}
+
+// underlying 解引用指针并展开 driver.Valuer（如 sql.NullString、gorm.DeletedAt），nil 指针与 NULL 返回 nil
+func underlying(v any) any {
+	for v != nil {
+		if valuer, ok := v.(driver.Valuer); ok {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				return nil
+			}
+			value, err := valuer.Value()
+			if err != nil {
+				return nil
+			}
+			if _, again := value.(driver.Valuer); again {
+				return value
+			}
+			v = value
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Pointer {
+			return v
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		v = rv.Elem().Interface()
+	}
+	return nil
+}
+
+// isFormulaPrefix 表格软件会当作公式解析的首字符
+func isFormulaPrefix(c byte) bool {
+	switch c {
+	case '=', '+', '-', '@', '\t', '\r':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\exporter\exporter.go
+ * @Description: 流式导出 - 行迭代器逐行写出 CSV / XLSX，分块传输、按间隔刷新；慢客户端通过阻塞写入形成背压，
+ *               支持取消、行数上限与按路由共享的带宽上限
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package exporter 提供与批量导入对应的流式导出：数据不在内存中整体物化，边查询边写出
+package exporter
+
+import (
+	"context"
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 支持的导出格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// 导出结束状态，写在 X-Export-Status 尾部头中
+const (
+	StatusComplete  = "complete"  // 全部行已写出
+	StatusTruncated = "truncated" // 达到 MaxRows，之后的行被丢弃
+	StatusAborted   = "aborted"   // 迭代器出错、客户端断开或被取消，文件不完整
+)
+
+// 默认值
+const (
+	DefaultFlushInterval = time.Second
+	DefaultWriteTimeout  = 30 * time.Second
+	DefaultTimeLayout    = "2006-01-02 15:04:05"
+)
+
+// 节流单次写出的字节范围
+const (
+	minThrottleChunk = 1 << 10
+	maxThrottleChunk = 32 << 10
+)
+
+// ErrRowLimit 行数达到 MaxRows 时 emit 返回的错误，迭代器应就此停止
+var ErrRowLimit = stderrors.New("export row limit reached")
+
+// 导出指标
+var (
+	exportStreams = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_export_streams_total",
+		Help: "Total number of export streams by format and final status",
+	}, []string{"format", "status"})
+	exportRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_export_rows_total",
+		Help: "Total number of exported rows by format",
+	}, []string{"format"})
+	exportBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_export_bytes_total",
+		Help: "Total number of bytes written by export streams by format",
+	}, []string{"format"})
+)
+
+// RowIterator 行迭代器：逐行调用 emit 直到数据读完；emit 返回错误（客户端断开、达到行数上限、被取消）时应停止并原样返回。
+// ctx 在导出结束或中止时取消，迭代器内的数据库查询应使用它
+type RowIterator func(ctx context.Context, emit func(row []any) error) error
+
+// Options 导出选项
+type Options struct {
+	Context        context.Context // 取消导出的上下文，通常为 r.Context()，为空时仅在写出失败时中止
+	Filename       string          // 下载文件名（Content-Disposition），为空时为 export.csv / export.xlsx
+	SheetName      string          // XLSX 工作表名，默认 Sheet1
+	MaxRows        int64           // 数据行数上限，0 不限制；XLSX 不超过单表上限 1048575
+	BytesPerSecond int64           // 单个响应的带宽上限，0 不限制
+	Bandwidth      *Bandwidth      // 按路由共享的带宽上限，同一路由的并发导出共用，通过 NewBandwidth 创建
+	FlushInterval  time.Duration   // 刷新到客户端的间隔，默认 1s
+	WriteTimeout   time.Duration   // 每次刷新后顺延的写超时，避免长时间导出被服务端写超时中断，默认 30s
+	TimeLayout     string          // CSV 中 time.Time 的格式，默认 2006-01-02 15:04:05
+	NoBOM          bool            // CSV 不写 UTF-8 BOM（默认写入以便 Excel 正确识别编码）
+	KeepFormulas   bool            // 不转义以 = + - @ 开头的文本（默认加 ' 前缀防止公式注入）
+}
+
+// withDefaults 填充默认值
+func (o Options) withDefaults(format string) Options {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	if o.Filename == "" {
+		o.Filename = "export." + format
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = DefaultWriteTimeout
+	}
+	if o.TimeLayout == "" {
+		o.TimeLayout = DefaultTimeLayout
+	}
+	return o
+}
+
+// Result 导出统计
+type Result struct {
+	Rows   int64  `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	Status string `json:"status"`
+}
+
+// Bandwidth 带宽限制器（令牌桶），可在多个导出流之间共享，并发安全
+type Bandwidth struct {
+	rate  float64 // 字节 / 秒
+	chunk int     // 单次写出的字节数，保证等待粒度约为 50ms
+	mu    sync.Mutex
+	next  time.Time // 下一字节可写出的时间
+}
+
+// NewBandwidth 创建带宽限制器，bytesPerSecond <= 0 时返回 nil（不限制）
+func NewBandwidth(bytesPerSecond int64) *Bandwidth {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	chunk := int(bytesPerSecond / 20)
+	chunk = max(minThrottleChunk, min(chunk, maxThrottleChunk))
+	return &Bandwidth{rate: float64(bytesPerSecond), chunk: chunk}
+}
+
+// reserve 预留 n 字节，返回写出前需要等待的时长；空闲时允许 100ms 的突发
+func (b *Bandwidth) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if earliest := now.Add(-100 * time.Millisecond); b.next.Before(earliest) {
+		b.next = earliest
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(time.Duration(float64(n) / b.rate * float64(time.Second)))
+	return max(wait, 0)
+}
+
+// stream 一次导出的 HTTP 输出：首次写出时提交响应头，按带宽限制分块写出并统计字节数
+type stream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	format string
+	opts   Options
+	limits []*Bandwidth
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	committed bool
+	lastFlush time.Time
+	result    Result
+	err       error // emit 中遇到的第一个错误，迭代器吞掉错误时仍以它为准
+}
+
+// newStream 设置下载响应头（尚未提交）
+func newStream(w http.ResponseWriter, format, contentType string, opts Options) *stream {
+	opts = opts.withDefaults(format)
+	s := &stream{w: w, rc: http.NewResponseController(w), format: format, opts: opts, lastFlush: time.Now()}
+	s.ctx, s.cancel = context.WithCancel(opts.Context)
+	for _, b := range []*Bandwidth{opts.Bandwidth, NewBandwidth(opts.BytesPerSecond)} {
+		if b != nil {
+			s.limits = append(s.limits, b)
+		}
+	}
+
+	header := w.Header()
+	header.Set(constants.HeaderContentType, contentType)
+	header.Set(constants.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": opts.Filename}))
+	header.Set(constants.HeaderCacheControl, "no-store")
+	header.Set(constants.HeaderXContentTypeOptions, "nosniff")
+	header.Set(constants.HeaderTrailer, constants.HeaderXExportStatus)
+	header.Del(constants.HeaderContentLength)
+	return s
+}
+
+// Write 实现 io.Writer：按带宽限制分块写出，等待期间响应取消
+func (s *stream) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		for _, b := range s.limits {
+			n = min(n, b.chunk)
+		}
+		for _, b := range s.limits {
+			if wait := b.reserve(n); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-s.ctx.Done():
+					timer.Stop()
+					return written, context.Cause(s.ctx)
+				}
+			}
+		}
+		if !s.committed {
+			s.committed = true
+			s.extendDeadline()
+		}
+		m, err := s.w.Write(p[:n])
+		written += m
+		s.result.Bytes += int64(m)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// extendDeadline 顺延写超时
+func (s *stream) extendDeadline() {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(s.opts.WriteTimeout))
+}
+
+// run 驱动迭代器：write 写出一行，flush 把格式层缓冲写到 stream，finish 写出文件尾
+func (s *stream) run(rows RowIterator, maxRows int64, write func(row []any) error, flush, finish func() error) (*Result, error) {
+	defer s.cancel()
+
+	emit := func(row []any) error {
+		if s.err != nil {
+			return s.err
+		}
+		if err := s.ctx.Err(); err != nil {
+			s.err = context.Cause(s.ctx)
+			return s.err
+		}
+		if maxRows > 0 && s.result.Rows >= maxRows {
+			s.result.Status = StatusTruncated
+			s.err = ErrRowLimit
+			return s.err
+		}
+		if err := write(row); err != nil {
+			s.err = err
+			return err
+		}
+		s.result.Rows++
+		if time.Since(s.lastFlush) >= s.opts.FlushInterval {
+			if err := s.flush(flush); err != nil {
+				s.err = err
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := rows(s.ctx, emit)
+	if s.err != nil {
+		err = s.err
+	}
+	if stderrors.Is(err, ErrRowLimit) {
+		err = nil
+	}
+	if err == nil {
+		err = finish()
+	}
+	if err == nil {
+		err = s.flush(func() error { return nil })
+	}
+	if err == nil && s.result.Status == "" {
+		s.result.Status = StatusComplete
+	}
+	if err != nil {
+		s.result.Status = StatusAborted
+	}
+
+	exportStreams.WithLabelValues(s.format, s.result.Status).Inc()
+	exportRows.WithLabelValues(s.format).Add(float64(s.result.Rows))
+	exportBytes.WithLabelValues(s.format).Add(float64(s.result.Bytes))
+
+	if !s.committed {
+		// 尚未写出任何内容时撤销下载响应头，调用方仍可返回普通错误响应
+		for _, h := range []string{constants.HeaderContentType, constants.HeaderContentDisposition, constants.HeaderTrailer} {
+			s.w.Header().Del(h)
+		}
+		return &s.result, err
+	}
+	s.w.Header().Set(constants.HeaderXExportStatus, s.result.Status)
+	return &s.result, err
+}
+
+// flush 把格式层缓冲写出并推送给客户端
+func (s *stream) flush(flush func() error) error {
+	if err := flush(); err != nil {
+		return err
+	}
+	s.lastFlush = time.Now()
+	if !s.committed {
+		return nil
+	}
+	s.extendDeadline()
+	if err := s.rc.Flush(); err != nil && !stderrors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\exporter\xlsx.go
+ * @Description: XLSX 流式写入 - 固定部件先写出，工作表逐行以内联字符串写入 zip 条目，不构建共享字符串表，
+ *               内存占用与行数无关
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package exporter
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentTypeXLSX XLSX 文件的 MIME 类型
+const ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// MaxXLSXRows 单个工作表的行数上限（含表头）
+const MaxXLSXRows = 1048576
+
+// 单元格样式，对应 styles.xml 中 cellXfs 的下标
+const (
+	styleDateTime = 1
+	styleHeader   = 2
+)
+
+// xlsxEpoch Excel 日期序列号的起点
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxParts 工作表之外的固定部件
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs><cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles></styleSheet>`},
+}
+
+// XLSXWriter 单工作表 XLSX 流式写入器，也可脱离 HTTP 写入文件或对象存储
+type XLSXWriter struct {
+	zw     *zip.Writer
+	flater *flate.Writer
+	sheet  *bufio.Writer
+	rows   int
+	closed bool
+}
+
+// NewXLSXWriter 创建写入器并写出固定部件，columns 非空时写入加粗并冻结的表头行
+func NewXLSXWriter(w io.Writer, sheetName string, columns []string) (*XLSXWriter, error) {
+	x := &XLSXWriter{zw: zip.NewWriter(w)}
+	x.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		fw, err := flate.NewWriter(out, flate.DefaultCompression)
+		x.flater = fw
+		return fw, err
+	})
+
+	parts := append(xlsxParts[:len(xlsxParts):len(xlsxParts)], struct{ name, body string }{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + escapeXML(sheetTitle(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`})
+	for _, part := range parts {
+		pw, err := x.zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	sw, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x.sheet = bufio.NewWriterSize(sw, bufferSize)
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(columns) > 0 {
+		x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	x.sheet.WriteString(`<sheetData>`)
+	if len(columns) > 0 {
+		header := make([]any, len(columns))
+		for i, c := range columns {
+			header[i] = c
+		}
+		if err := x.writeRow(header, styleHeader); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// WriteRow 写入一行，nil 为空单元格，time.Time 写为日期序列号并应用日期格式
+func (x *XLSXWriter) WriteRow(row []any) error {
+	return x.writeRow(row, 0)
+}
+
+// writeRow 写入一行，style 非 0 时应用于所有单元格
+func (x *XLSXWriter) writeRow(row []any, style int) error {
+	if x.closed {
+		return fmt.Errorf("xlsx writer is closed")
+	}
+	if x.rows >= MaxXLSXRows {
+		return fmt.Errorf("xlsx sheet cannot hold more than %d rows", MaxXLSXRows)
+	}
+	x.rows++
+	line := strconv.Itoa(x.rows)
+	x.sheet.WriteString(`<row r="` + line + `">`)
+	for i, v := range row {
+		x.writeCell(columnName(i)+line, underlying(v), style)
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+// writeCell 按值类型写入单元格
+func (x *XLSXWriter) writeCell(ref string, v any, style int) {
+	attrs := `r="` + ref + `"`
+	if style != 0 {
+		attrs += ` s="` + strconv.Itoa(style) + `"`
+	}
+	switch val := v.(type) {
+	case nil:
+		return
+	case bool:
+		b := "0"
+		if val {
+			b = "1"
+		}
+		x.sheet.WriteString(`<c ` + attrs + ` t="b"><v>` + b + `</v></c>`)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		x.sheet.WriteString(`<c ` + attrs + `><v>` + fmt.Sprint(val) + `</v></c>`)
+	case float32:
+		x.writeCell(ref, float64(val), style)
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			x.writeCell(ref, strconv.FormatFloat(val, 'g', -1, 64), style)
+			return
+		}
+		x.sheet.WriteString(`<c ` + attrs + `><v>` + strconv.FormatFloat(val, 'g', -1, 64) + `</v></c>`)
+	case time.Time:
+		if val.IsZero() {
+			return
+		}
+		if style == 0 {
+			attrs += ` s="` + strconv.Itoa(styleDateTime) + `"`
+		}
+		x.sheet.WriteString(`<c ` + attrs + `><v>` + strconv.FormatFloat(excelSerial(val), 'f', -1, 64) + `</v></c>`)
+	default:
+		var text string
+		switch s := val.(type) {
+		case string:
+			text = s
+		case []byte:
+			text = string(s)
+		case fmt.Stringer:
+			text = s.String()
+		default:
+			text = fmt.Sprint(s)
+		}
+		x.sheet.WriteString(`<c ` + attrs + ` t="inlineStr"><is><t xml:space="preserve">` + escapeXML(text) + `</t></is></c>`)
+	}
+}
+
+// Rows 已写入的行数（含表头）
+func (x *XLSXWriter) Rows() int {
+	return x.rows
+}
+
+// Flush 把已写入的行压缩并写到底层 Writer
+func (x *XLSXWriter) Flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	if x.flater != nil {
+		if err := x.flater.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.zw.Flush()
+}
+
+// Close 写出工作表结尾与 zip 目录，不关闭底层 Writer
+func (x *XLSXWriter) Close() error {
+	if x.closed {
+		return nil
+	}
+	x.closed = true
+	if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// StreamXLSX 把 rows 逐行写成 XLSX 下载响应，错误处理与 StreamCSV 一致
+func StreamXLSX(w http.ResponseWriter, columns []string, rows RowIterator, opts Options) (*Result, error) {
+	s := newStream(w, FormatXLSX, ContentTypeXLSX, opts)
+	x, err := NewXLSXWriter(s, s.opts.SheetName, columns)
+	if err != nil {
+		return s.run(func(context.Context, func([]any) error) error { return err }, 0, nil, nil, nil)
+	}
+	maxRows := int64(MaxXLSXRows - x.Rows())
+	if s.opts.MaxRows > 0 {
+		maxRows = min(maxRows, s.opts.MaxRows)
+	}
+	return s.run(rows, maxRows, x.WriteRow, x.Flush, x.Close)
+}
+
+// excelSerial 时间转为 Excel 日期序列号（按时间自身时区的挂钟时间）
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(xlsxEpoch).Seconds() / 86400
+}
+
+// columnName 列序号（从 0 开始）转为列字母，如 0 → A、27 → AB
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetTitle 工作表名：去掉不允许的字符，最长 31 个字符
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+// escapeXML 转义 XML 文本，非法控制字符替换为 U+FFFD
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,46 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\features.go
+ * @Description: 功能开关矩阵加载 - 启动与配置热更新时读取配置文件顶层 features 段，按当前环境关闭 Swagger 与 PProf
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// applyFeatures 解析 features 段（override 非 nil 时优先）并按当前环境生效，
+// 矩阵关闭的 Swagger、PProf 在 config 上置为关闭；矩阵只能关闭功能，不会开启配置中关闭的功能
+func applyFeatures(config *gwconfig.Gateway, override *middleware.FeaturesConfig, raw any) error {
+	cfg := override
+	if cfg == nil && raw != nil {
+		parsed, err := middleware.ParseFeatures(raw)
+		if err != nil {
+			return err
+		}
+		cfg = parsed
+	}
+	env := string(global.GetEnvironment())
+	if err := middleware.SetFeatures(cfg, env); err != nil {
+		return err
+	}
+
+	if config.Swagger != nil && config.Swagger.Enabled && !middleware.FeatureEnabled(middleware.FeatureSwagger) {
+		config.Swagger.Enabled = false
+		global.LOGGER.InfoKV("功能开关已关闭 Swagger", "environment", env)
+	}
+	if config.Middleware != nil && config.Middleware.PProf != nil && config.Middleware.PProf.Enabled &&
+		!middleware.FeatureEnabled(middleware.FeaturePProf) {
+		config.Middleware.PProf.Enabled = false
+		global.LOGGER.InfoKV("功能开关已关闭 PProf", "environment", env)
+	}
+	return nil
+}
@@ -16,11 +16,14 @@ package gateway
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -28,16 +31,30 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	goconfig "github.com/kamalyes/go-config"
 	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/collection"
+	"github.com/kamalyes/go-rpc-gateway/confighistory"
+	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/cpool"
 	grpcpool "github.com/kamalyes/go-rpc-gateway/cpool/grpc"
 	"github.com/kamalyes/go-rpc-gateway/errors"
 	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/importer"
+	"github.com/kamalyes/go-rpc-gateway/leader"
 	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/overrides"
+	"github.com/kamalyes/go-rpc-gateway/redact"
+	"github.com/kamalyes/go-rpc-gateway/remoteconfig"
+	"github.com/kamalyes/go-rpc-gateway/resource"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/sdkgen"
+	"github.com/kamalyes/go-rpc-gateway/search"
 	"github.com/kamalyes/go-rpc-gateway/server"
+	"github.com/kamalyes/go-rpc-gateway/store"
 	"github.com/kamalyes/go-toolbox/pkg/safe"
 	"github.com/minio/minio-go/v7"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"gorm.io/gorm"
 )
 
@@ -56,6 +73,17 @@ type Gateway struct {
 	gatewayHandlerRegistrars  []ServerHandlerRegisterFunc
 	proxyHandlerRegistrations []proxyHandlerRegistration
 	httpRouteRegistrations    []httpRouteRegistration
+	routeInfos                []RouteInfo                // protoc-gen-gateway 登记的路由元信息
+	endpoints                 *server.EndpointCollector  // proto 路由端点收集器
+	elector                   *leader.Elector            // 后台任务选主器，经 getElector 读取
+	electorOnce               sync.Once                  // 未通过构建器创建时只延迟创建一次选主器
+	localConn                 *grpc.ClientConn           // 连接本进程 gRPC 服务的共享连接，按需创建
+	searchClient              *search.Client             // 检索客户端，配置 search 后创建
+	features                  *middleware.FeaturesConfig // 构建器设置的功能开关矩阵，热更新时优先于配置文件
+	configOverrides           []overrides.Override       // 环境变量与 --set 覆盖，热更新后重新套用
+	configHistory             *confighistory.History     // 配置版本历史，WithConfigHistory 后创建
+	remoteConfig              *remoteconfig.Client       // 远程配置客户端，WithRemoteConfig 后创建
+	configManagerStop         sync.Once                  // 配置管理器只停止一次，关闭钩子与 Stop 兜底共用
 }
 
 // GatewayBuilder Gateway构建器 - 支持链式调用
@@ -72,7 +100,77 @@ type GatewayBuilder struct {
 	useCustomPrefix        bool
 	silent                 bool // 是否静默启动
 	grpcGatewayMiddlewares []runtime.Middleware
-	ctx                    context.Context // 用户提供的上下文
+	embeddedStoreOptions   *store.MemoryOptions                   // 内嵌状态存储配置（Redis 不可用时生效）
+	customStore            store.Store                            // 自定义状态存储后端
+	leaderConfig           *leader.Config                         // 后台任务选主配置
+	shutdownConfig         *server.ShutdownConfig                 // 分阶段优雅关闭配置
+	corsOptions            *middleware.CORSOptions                // CORS 路由组覆盖与演练模式
+	autoMethodConfig       *server.AutoMethodConfig               // OPTIONS/HEAD 自动处理配置
+	grpcHealthConfig       *server.GRPCHealthConfig               // gRPC 健康检查服务配置
+	grpcTuningConfig       *server.GRPCTuningConfig               // gRPC Server 调优配置
+	httpTuningConfig       *server.HTTPTuningConfig               // HTTP Server 调优配置
+	connLimitConfig        *server.ConnLimitConfig                // 连接级限制配置
+	slowlorisConfig        *server.SlowlorisConfig                // 慢速攻击防护配置
+	accessLogConfig        *middleware.AccessLogConfig            // 访问日志多路输出配置
+	routeLogConfig         *middleware.RouteLogConfig             // 按路由日志覆盖配置
+	jsonBackend            string                                 // JSON 编解码后端（std/jsoniter/sonic）
+	middlewares            []middleware.ChainEntry                // 自定义 HTTP 中间件
+	middlewareAdminPath    string                                 // 中间件顺序查询接口路径
+	gatewayHeaderConfig    *server.GatewayHeaderConfig            // grpc-gateway 请求头/元数据/查询参数映射规则
+	streamConfig           *server.StreamConfig                   // 服务端流式 RPC 输出配置
+	inProcessGRPC          *server.InProcessGRPCConfig            // 进程内 gRPC 通道配置
+	portFallback           *server.PortFallbackConfig             // 端口被占用时的回退策略
+	bannerOptions          *server.BannerOptions                  // 启动横幅模板、纯文本输出与启动报告
+	buildInfoConfig        *server.BuildInfoConfig                // 构建信息查询接口与响应头
+	performancePath        *string                                // 运行时性能查询接口路径
+	watchdog               *server.WatchdogConfig                 // 自监控看门狗
+	deadlineConfig         *server.DeadlineConfig                 // 请求截止时间传递与上限
+	clientCancel           *middleware.ClientCancelConfig         // 客户端断开的日志处理
+	headerLimit            *middleware.HeaderLimitConfig          // 请求头条数与大小限制
+	requestDecompression   *middleware.RequestDecompressionConfig // 请求体解压
+	authn                  *middleware.AuthenticationConfig       // 统一认证
+	streamLimit            *middleware.StreamLimitConfig          // SSE / WebSocket 流式连接限制
+	broadcast              *middleware.BroadcastConfig            // SSE / WebSocket 广播推送
+	rateLimitPenalty       *middleware.RateLimitPenaltyConfig     // 限流升级处罚
+	rateLimitCosts         *middleware.RateLimitCostConfig        // 限流请求权重
+	rateLimitState         *middleware.RateLimitStateConfig       // 限流状态观测
+	rateLimitKeys          *middleware.RateLimitKeyConfig         // 限流 key 模板
+	identityPropagation    *middleware.IdentityPropagationConfig  // 出站身份传递
+	bulkheads              *middleware.BulkheadConfig             // 上游舱壁隔离
+	extAuthz               *middleware.ExtAuthzConfig             // 外部授权
+	casbin                 *middleware.CasbinConfig               // Casbin 授权
+	multipart              *middleware.MultipartConfig            // multipart 表单策略
+	uploadScan             *middleware.UploadScanConfig           // 上传文件病毒扫描
+	media                  *middleware.MediaConfig                // 媒体处理路由
+	jobs                   *middleware.JobsConfig                 // 异步任务
+	webhooks               *middleware.WebhooksConfig             // 出站 Webhook 投递
+	schedules              *middleware.SchedulesConfig            // 定时调用
+	leakDetector           *middleware.LeakDetectorConfig         // 协程泄漏检测
+	descriptors            *middleware.DescriptorsConfig          // 服务描述符查询接口
+	sdkGenerator           *sdkgen.HandlerConfig                  // 客户端 SDK 下载接口
+	portal                 *middleware.PortalConfig               // 开发者门户
+	collections            *collection.HandlerConfig              // Postman / Insomnia 接口集合下载
+	configHistory          *confighistory.Config                  // 配置版本历史与回滚
+	searchBackend          *search.Config                         // Elasticsearch / OpenSearch 检索
+	signedURL              *middleware.SignedURLConfig            // 签名 URL
+	openAPIValidation      *middleware.OpenAPIValidationConfig    // OpenAPI 正向校验
+	schemaRegistry         *middleware.SchemaRegistryConfig       // JSON Schema 注册表
+	slowStart              *grpcpool.SlowStartConfig              // 上游实例慢启动
+	stickySession          *middleware.StickySessionConfig        // 会话保持
+	responseBuffering      *middleware.ResponseBufferingConfig    // 响应缓冲策略
+	priorityLimit          *middleware.PriorityLimitConfig        // 优先级并发限制
+	fallbacks              *middleware.FallbackConfig             // 降级响应
+	dnsResolver            *grpcpool.DNSResolverConfig            // DNS 上游解析
+	k8sDiscovery           *grpcpool.K8sDiscoveryConfig           // Kubernetes EndpointSlice 服务发现
+	cacheControl           *middleware.CacheControlConfig         // CDN 缓存指令
+	metricsPathLabels      *middleware.PathLabelConfig            // 指标路径标签
+	stageTiming            *middleware.StageTimingConfig          // 中间件分阶段计时
+	features               *middleware.FeaturesConfig             // 按环境的功能开关矩阵
+	overrideConfig         overrides.Config                       // 配置覆盖来源
+	overrideList           []overrides.Override                   // Build 时收集的配置覆盖
+	redaction              *redact.Config                         // 日志与响应中的密钥脱敏规则
+	remoteConfig           *remoteconfig.Config                   // 远程配置源
+	ctx                    context.Context                        // 用户提供的上下文
 }
 
 // ServiceRegisterFunc gRPC服务注册函数类型
@@ -189,6 +287,442 @@ func (b *GatewayBuilder) WithGrpcGatewayMiddleware(mw runtime.Middleware) *Gatew
 	return b
 }
 
+// WithEmbeddedStore 设置内嵌状态存储配置
+// 未配置 Redis 时，限流、防重放等中间件状态保存在内嵌存储中；配置 SnapshotPath 后会定期落盘，重启可恢复
+func (b *GatewayBuilder) WithEmbeddedStore(opts *store.MemoryOptions) *GatewayBuilder {
+	b.embeddedStoreOptions = opts
+	return b
+}
+
+// WithStore 设置自定义状态存储后端（如 DynamoDB、etcd 等）
+// 设置后限流、防重放等中间件不再使用 Redis / 内嵌存储，而是读写该后端
+func (b *GatewayBuilder) WithStore(s store.Store) *GatewayBuilder {
+	b.customStore = s
+	return b
+}
+
+// WithLeaderElection 设置后台任务选主配置（租约时长、续期间隔、节点标识等）
+func (b *GatewayBuilder) WithLeaderElection(cfg *leader.Config) *GatewayBuilder {
+	b.leaderConfig = cfg
+	return b
+}
+
+// WithCORSOptions 设置 CORS 路由组覆盖与演练模式
+func (b *GatewayBuilder) WithCORSOptions(opts *middleware.CORSOptions) *GatewayBuilder {
+	b.corsOptions = opts
+	return b
+}
+
+// WithAutoMethods 设置 OPTIONS/HEAD 自动处理配置（默认均开启）
+func (b *GatewayBuilder) WithAutoMethods(cfg *server.AutoMethodConfig) *GatewayBuilder {
+	b.autoMethodConfig = cfg
+	return b
+}
+
+// WithGRPCHealth 设置 gRPC 健康检查服务配置（探测间隔、服务依赖），启用健康检查时自动注册
+func (b *GatewayBuilder) WithGRPCHealth(cfg *server.GRPCHealthConfig) *GatewayBuilder {
+	b.grpcHealthConfig = cfg
+	return b
+}
+
+// WithGRPCTuning 设置 gRPC Server 调优配置（keepalive 强制策略、连接寿命、并发流、按服务消息大小与压缩）
+func (b *GatewayBuilder) WithGRPCTuning(cfg *server.GRPCTuningConfig) *GatewayBuilder {
+	b.grpcTuningConfig = cfg
+	return b
+}
+
+// WithHTTPTuning 设置 HTTP Server 调优配置（读写/空闲超时、请求头大小、keep-alive）
+func (b *GatewayBuilder) WithHTTPTuning(cfg *server.HTTPTuningConfig) *GatewayBuilder {
+	b.httpTuningConfig = cfg
+	return b
+}
+
+// WithConnLimits 设置连接级限制（最大连接数、单 IP 连接数、超限拒绝或排队）
+func (b *GatewayBuilder) WithConnLimits(cfg *server.ConnLimitConfig) *GatewayBuilder {
+	b.connLimitConfig = cfg
+	return b
+}
+
+// WithSlowlorisProtection 设置慢速攻击防护（严格请求头时限、请求体最低速率、违规 IP 临时封禁）
+func (b *GatewayBuilder) WithSlowlorisProtection(cfg *server.SlowlorisConfig) *GatewayBuilder {
+	b.slowlorisConfig = cfg
+	return b
+}
+
+// WithAccessLogSinks 设置访问日志多路输出（轮转文件、syslog、Loki、Kafka）
+func (b *GatewayBuilder) WithAccessLogSinks(cfg *middleware.AccessLogConfig) *GatewayBuilder {
+	b.accessLogConfig = cfg
+	return b
+}
+
+// WithRouteLogOverrides 设置按路由的日志级别与脱敏覆盖规则（AdminPath 非空时可运行时调整）
+func (b *GatewayBuilder) WithRouteLogOverrides(cfg *middleware.RouteLogConfig) *GatewayBuilder {
+	b.routeLogConfig = cfg
+	return b
+}
+
+// WithJSONBackend 设置响应等热路径使用的 JSON 后端（std/jsoniter，sonic 需 -tags sonic 构建）
+func (b *GatewayBuilder) WithJSONBackend(name string) *GatewayBuilder {
+	b.jsonBackend = name
+	return b
+}
+
+// WithMiddleware 添加自定义 HTTP 中间件，按 priority 与内置中间件统一排序（见 middleware.Priority* 常量，可多次调用）
+func (b *GatewayBuilder) WithMiddleware(name string, priority int, mw middleware.MiddlewareFunc) *GatewayBuilder {
+	b.middlewares = append(b.middlewares, middleware.ChainEntry{Name: name, Priority: priority, Middleware: mw})
+	return b
+}
+
+// WithMiddlewareAdmin 注册中间件执行顺序查询接口，path 为空时使用 /admin/middleware
+func (b *GatewayBuilder) WithMiddlewareAdmin(path string) *GatewayBuilder {
+	if path == "" {
+		path = middleware.DefaultMiddlewareAdminPath
+	}
+	b.middlewareAdminPath = path
+	return b
+}
+
+// WithGatewayHeaders 设置 grpc-gateway 映射规则（请求头转发允许名单、metadata → 响应头、查询参数解析选项）
+func (b *GatewayBuilder) WithGatewayHeaders(cfg *server.GatewayHeaderConfig) *GatewayBuilder {
+	b.gatewayHeaderConfig = cfg
+	return b
+}
+
+// WithStreamConfig 设置服务端流式 RPC 的 HTTP 输出（Content-Type、逐条刷新、心跳、写超时顺延），支持按路由覆盖
+func (b *GatewayBuilder) WithStreamConfig(cfg *server.StreamConfig) *GatewayBuilder {
+	b.streamConfig = cfg
+	return b
+}
+
+// WithInProcessGRPC 设置进程内 gRPC 通道：RegisterGatewayConnHandler 调用本进程服务时走内存连接而非 localhost TCP
+func (b *GatewayBuilder) WithInProcessGRPC(cfg server.InProcessGRPCConfig) *GatewayBuilder {
+	b.inProcessGRPC = &cfg
+	return b
+}
+
+// WithPortFallback 端口被占用时依次尝试后续端口（开发模式），实际端口会打印在启动信息中
+func (b *GatewayBuilder) WithPortFallback(cfg server.PortFallbackConfig) *GatewayBuilder {
+	b.portFallback = &cfg
+	return b
+}
+
+// WithBannerOptions 设置启动横幅模板、纯文本输出（无 emoji/表格）与 JSON 启动报告输出位置
+func (b *GatewayBuilder) WithBannerOptions(opts server.BannerOptions) *GatewayBuilder {
+	b.bannerOptions = &opts
+	return b
+}
+
+// WithBuildInfoEndpoint 注册构建信息查询接口（默认 /admin/info），可选在 HTTP 响应头与 gRPC 响应元数据中附带版本
+// 构建信息本身通过 gateway.SetBuildInfo 注入
+func (b *GatewayBuilder) WithBuildInfoEndpoint(cfg server.BuildInfoConfig) *GatewayBuilder {
+	b.buildInfoConfig = &cfg
+	return b
+}
+
+// WithPerformanceEndpoint 注册运行时性能查询接口（path 为空时为 /admin/performance），
+// 返回堆内存、GC 停顿、协程、线程、文件描述符与运行时长，与 gateway_runtime_* 指标同源
+func (b *GatewayBuilder) WithPerformanceEndpoint(path string) *GatewayBuilder {
+	b.performancePath = &path
+	return b
+}
+
+// WithWatchdog 设置自监控看门狗：资源超限时自动保存 heap/goroutine profile，可选受控重启
+func (b *GatewayBuilder) WithWatchdog(cfg server.WatchdogConfig) *GatewayBuilder {
+	b.watchdog = &cfg
+	return b
+}
+
+// WithDeadlines 设置请求截止时间：可信调用方可通过 X-Timeout-Ms / Grpc-Timeout 指定超时，统一按上限截断
+func (b *GatewayBuilder) WithDeadlines(cfg server.DeadlineConfig) *GatewayBuilder {
+	b.deadlineConfig = &cfg
+	return b
+}
+
+// WithClientCancel 设置客户端断开请求的处理：这类请求按 499 / Canceled 记录为警告并单独计数，可选不记录日志
+func (b *GatewayBuilder) WithClientCancel(cfg middleware.ClientCancelConfig) *GatewayBuilder {
+	b.clientCancel = &cfg
+	return b
+}
+
+// WithHeaderLimit 设置请求头 / gRPC metadata 条数与大小限制，超限返回 431 / RESOURCE_EXHAUSTED 并指明超限的头
+func (b *GatewayBuilder) WithHeaderLimit(cfg middleware.HeaderLimitConfig) *GatewayBuilder {
+	b.headerLimit = &cfg
+	return b
+}
+
+// WithRequestDecompression 设置 gzip 请求体解压：指定路由上透明解压，解压后大小或压缩比超限返回 413
+func (b *GatewayBuilder) WithRequestDecompression(cfg middleware.RequestDecompressionConfig) *GatewayBuilder {
+	b.requestDecompression = &cfg
+	return b
+}
+
+// WithAuthentication 设置统一认证：HTTP 与 gRPC 共用认证器产生 Principal，按规则校验角色、授权范围与租户
+func (b *GatewayBuilder) WithAuthentication(cfg middleware.AuthenticationConfig) *GatewayBuilder {
+	b.authn = &cfg
+	return b
+}
+
+// WithStreamLimit 设置 SSE / WebSocket 流式连接限制：按用户与 IP 限制同时在线数、空闲超时断开，关闭时向每个连接发送关闭消息
+func (b *GatewayBuilder) WithStreamLimit(cfg middleware.StreamLimitConfig) *GatewayBuilder {
+	b.streamLimit = &cfg
+	return b
+}
+
+// WithRateLimitPenalty 设置限流升级处罚：反复超限的限流 key 按规则 BlockDuration 封禁，再犯时封禁时长按倍数（默认 2）延长，
+// 封禁记录保存在状态存储中，管理接口默认 /admin/ratelimit/bans
+func (b *GatewayBuilder) WithRateLimitPenalty(cfg middleware.RateLimitPenaltyConfig) *GatewayBuilder {
+	b.rateLimitPenalty = &cfg
+	return b
+}
+
+// WithRateLimitCosts 设置限流请求权重：路由声明每次请求消耗的额度，处理器可通过 middleware.SetRateLimitCost /
+// AddRateLimitCost 按负载调整，超出预扣的部分在处理完成后补扣
+func (b *GatewayBuilder) WithRateLimitCosts(cfg middleware.RateLimitCostConfig) *GatewayBuilder {
+	b.rateLimitCosts = &cfg
+	return b
+}
+
+// WithRateLimitState 设置限流状态观测：按路由统计放行 / 拒绝并导出被拒绝最多的 key 为 Prometheus 指标，
+// 管理接口（默认 /admin/ratelimit/state）分页查看各限流 key 的剩余额度
+func (b *GatewayBuilder) WithRateLimitState(cfg middleware.RateLimitStateConfig) *GatewayBuilder {
+	b.rateLimitState = &cfg
+	return b
+}
+
+// WithRateLimitKeys 设置限流 key 模板：按 JWT 声明、请求头、Cookie、查询参数或 JSON 请求体字段组合限流 key
+// （如按租户或 API Key 限流），占位符加 |hash 后只保留摘要
+func (b *GatewayBuilder) WithRateLimitKeys(cfg middleware.RateLimitKeyConfig) *GatewayBuilder {
+	b.rateLimitKeys = &cfg
+	return b
+}
+
+// WithBroadcast 设置广播推送：客户端以 SSE 或 WebSocket 订阅主题（默认 /events），Gateway.Broadcast 与管理接口（默认 /admin/broadcast）按主题、用户、租户推送
+func (b *GatewayBuilder) WithBroadcast(cfg middleware.BroadcastConfig) *GatewayBuilder {
+	b.broadcast = &cfg
+	return b
+}
+
+// WithIdentityPropagation 设置出站身份传递：按上游原样转发令牌、签发内部 JWT 或进行 OAuth2 令牌交换
+func (b *GatewayBuilder) WithIdentityPropagation(cfg middleware.IdentityPropagationConfig) *GatewayBuilder {
+	b.identityPropagation = &cfg
+	return b
+}
+
+// WithBulkheads 设置上游舱壁隔离：每个上游（gRPC 服务 / endpoint / 声明式路由上游）独占并发槽位与有界等待队列，
+// 慢上游占满自己的槽位后新调用立即失败，不会拖垮其他上游
+func (b *GatewayBuilder) WithBulkheads(cfg middleware.BulkheadConfig) *GatewayBuilder {
+	b.bulkheads = &cfg
+	return b
+}
+
+// WithExtAuthz 设置外部授权：请求交给 OPA / Envoy ext_authz 兼容服务等授权器判定，支持决策缓存与故障放行
+func (b *GatewayBuilder) WithExtAuthz(cfg middleware.ExtAuthzConfig) *GatewayBuilder {
+	b.extAuthz = &cfg
+	return b
+}
+
+// WithCasbin 设置 Casbin 授权：路由映射为 (obj, act) 交给 casbin 判定，业务代码可调用 gateway.Enforce
+func (b *GatewayBuilder) WithCasbin(cfg middleware.CasbinConfig) *GatewayBuilder {
+	b.casbin = &cfg
+	return b
+}
+
+// WithMultipart 设置 multipart 表单策略：按路由限制文件个数、大小与类型，处理器通过 middleware.UploadedFilesFromContext 取得校验过的文件
+func (b *GatewayBuilder) WithMultipart(cfg middleware.MultipartConfig) *GatewayBuilder {
+	b.multipart = &cfg
+	return b
+}
+
+// WithUploadScan 设置上传文件病毒扫描：UploadedFile.SaveToMinIO 保存前交给 ClamAV / ICAP 扫描，检出时拒绝或转存隔离桶
+func (b *GatewayBuilder) WithUploadScan(cfg middleware.UploadScanConfig) *GatewayBuilder {
+	b.uploadScan = &cfg
+	return b
+}
+
+// WithMedia 设置媒体处理路由：/media/{bucket}/{key}?w=&h=&fit=&crop=&fmt=&q= 从 MinIO 读取图片变换后返回，结果缓存在本地磁盘
+func (b *GatewayBuilder) WithMedia(cfg middleware.MediaConfig) *GatewayBuilder {
+	b.media = &cfg
+	return b
+}
+
+// WithJobs 设置异步任务：通过 Gateway.RegisterJob 注册的路由 POST 后返回 202 与任务 ID，GET /jobs/{id} 查询进度与结果
+func (b *GatewayBuilder) WithJobs(cfg middleware.JobsConfig) *GatewayBuilder {
+	b.jobs = &cfg
+	return b
+}
+
+// WithWebhooks 设置出站 Webhook 投递：Gateway.PublishWebhook 发布的事件按类型投递给订阅方，失败指数退避重试，管理接口默认 /admin/webhooks
+func (b *GatewayBuilder) WithWebhooks(cfg middleware.WebhooksConfig) *GatewayBuilder {
+	b.webhooks = &cfg
+	return b
+}
+
+// WithLeakDetector 设置协程泄漏检测：定期采集协程栈，持续增长的栈通过日志、指标与报告接口（默认 /debug/leaks）报告
+func (b *GatewayBuilder) WithLeakDetector(cfg middleware.LeakDetectorConfig) *GatewayBuilder {
+	b.leakDetector = &cfg
+	return b
+}
+
+// WithDescriptors 注册服务描述符查询接口（默认 /admin/descriptors）：以 FileDescriptorSet 导出已注册服务及其依赖，
+// 调用方需已认证，可按 Roles 限定角色
+func (b *GatewayBuilder) WithDescriptors(cfg middleware.DescriptorsConfig) *GatewayBuilder {
+	b.descriptors = &cfg
+	return b
+}
+
+// WithSDKGenerator 注册客户端 SDK 下载接口（默认 /admin/sdk）：每次请求按（聚合后的）OpenAPI 规范
+// 重新生成 TypeScript / Go 客户端并以 zip 返回，如 /admin/sdk/typescript.zip；模板可通过 TemplateDir 覆盖，
+// 接口需由认证 / 授权中间件保护
+func (b *GatewayBuilder) WithSDKGenerator(cfg sdkgen.HandlerConfig) *GatewayBuilder {
+	b.sdkGenerator = &cfg
+	return b
+}
+
+// WithPortal 注册开发者门户（默认 /portal）：基于 Swagger 聚合的服务目录与可调试的服务文档，
+// 配置 Keys 时提供 API Key 自助申请、吊销与按天用量看板；门户要求已认证的调用方，Keys 需同时加入认证器链
+func (b *GatewayBuilder) WithPortal(cfg middleware.PortalConfig) *GatewayBuilder {
+	b.portal = &cfg
+	return b
+}
+
+// WithConfigHistory 记录每次生效的配置文件版本（校验和、生效时间与来源），
+// 在 /admin/config/versions 提供版本列表、比较与回滚，回滚通过热更新生效
+func (b *GatewayBuilder) WithConfigHistory(cfg confighistory.Config) *GatewayBuilder {
+	b.configHistory = &cfg
+	return b
+}
+
+// WithCollectionExport 注册 Postman / Insomnia 接口集合下载（默认位于 Swagger UI 路径下的 /collections）：
+// 由（聚合后的）OpenAPI 规范与手动注册的路由生成，baseUrl 与 authToken 为环境变量，Swagger 服务列表页提供下载入口
+func (b *GatewayBuilder) WithCollectionExport(cfg collection.HandlerConfig) *GatewayBuilder {
+	b.collections = &cfg
+	return b
+}
+
+// WithSchedules 设置定时调用：按 Cron 表达式以配置的身份调用内部路由或上游 URL，管理接口默认 /admin/schedules
+func (b *GatewayBuilder) WithSchedules(cfg middleware.SchedulesConfig) *GatewayBuilder {
+	b.schedules = &cfg
+	return b
+}
+
+// WithSearch 设置 Elasticsearch / OpenSearch 检索，优先于配置文件 search 段；通过 Gateway.RegisterSearchRoute 注册检索路由
+func (b *GatewayBuilder) WithSearch(cfg search.Config) *GatewayBuilder {
+	b.searchBackend = &cfg
+	return b
+}
+
+// WithOpenAPIValidation 设置 OpenAPI 正向校验：只放行与已加载（聚合）规范匹配的请求，未声明的路径、方法、参数返回 404 / 405 / 400
+func (b *GatewayBuilder) WithOpenAPIValidation(cfg middleware.OpenAPIValidationConfig) *GatewayBuilder {
+	b.openAPIValidation = &cfg
+	return b
+}
+
+// WithSchemaRegistry 启用 JSON Schema 注册表（管理接口默认 /admin/schemas）：服务为路由登记请求体 Schema 的版本，
+// 网关以最新版本校验 JSON 请求体；登记新版本时做向后兼容检查，不兼容的变更需 force
+func (b *GatewayBuilder) WithSchemaRegistry(cfg middleware.SchemaRegistryConfig) *GatewayBuilder {
+	b.schemaRegistry = &cfg
+	return b
+}
+
+// WithSignedURL 设置签名 URL：允许的路由可凭带过期时间与签名的临时链接访问，无需完整认证
+func (b *GatewayBuilder) WithSignedURL(cfg middleware.SignedURLConfig) *GatewayBuilder {
+	b.signedURL = &cfg
+	return b
+}
+
+// WithSlowStart 设置上游实例慢启动的默认参数，对负载均衡策略为 slow_start_round_robin 的 gRPC 客户端生效
+func (b *GatewayBuilder) WithSlowStart(cfg grpcpool.SlowStartConfig) *GatewayBuilder {
+	b.slowStart = &cfg
+	return b
+}
+
+// WithStickySession 设置会话保持：亲和 Cookie 把同一客户端固定到同一上游实例，对负载均衡策略为 sticky_round_robin 的 gRPC 客户端生效
+func (b *GatewayBuilder) WithStickySession(cfg middleware.StickySessionConfig) *GatewayBuilder {
+	b.stickySession = &cfg
+	return b
+}
+
+// WithResponseBuffering 设置按路由的响应缓冲策略：buffered 路由限定缓冲上限，streaming 路由禁止任何中间件缓冲响应体
+func (b *GatewayBuilder) WithResponseBuffering(cfg middleware.ResponseBufferingConfig) *GatewayBuilder {
+	b.responseBuffering = &cfg
+	return b
+}
+
+// WithPriorityLimit 设置优先级并发限制：并发饱和时按类别有界排队，高优先级请求先获得释放的槽位
+func (b *GatewayBuilder) WithPriorityLimit(cfg middleware.PriorityLimitConfig) *GatewayBuilder {
+	b.priorityLimit = &cfg
+	return b
+}
+
+// WithFallbacks 设置降级响应：路由返回 5xx 或熔断器打开时返回最近一次成功响应的缓存、转发备用上游或返回静态响应
+func (b *GatewayBuilder) WithFallbacks(cfg middleware.FallbackConfig) *GatewayBuilder {
+	b.fallbacks = &cfg
+	return b
+}
+
+// WithDNSResolver 设置 gwdns:/// 上游的 DNS 解析参数（DNS 服务器、TTL 上下限、失败重试间隔）
+func (b *GatewayBuilder) WithDNSResolver(cfg grpcpool.DNSResolverConfig) *GatewayBuilder {
+	b.dnsResolver = &cfg
+	return b
+}
+
+// WithK8sDiscovery 设置 k8s:/// 上游的 Kubernetes 服务发现参数（API Server、凭证、是否使用未就绪端点）
+func (b *GatewayBuilder) WithK8sDiscovery(cfg grpcpool.K8sDiscoveryConfig) *GatewayBuilder {
+	b.k8sDiscovery = &cfg
+	return b
+}
+
+// WithCacheControl 设置按路由附加的 CDN 缓存指令（Cache-Control、Surrogate-Control、surrogate key）与清除目标
+func (b *GatewayBuilder) WithCacheControl(cfg middleware.CacheControlConfig) *GatewayBuilder {
+	b.cacheControl = &cfg
+	return b
+}
+
+// WithMetricsPathLabels 设置指标 path 标签的模板、代理前缀映射与未匹配路径的基数上限
+func (b *GatewayBuilder) WithMetricsPathLabels(cfg middleware.PathLabelConfig) *GatewayBuilder {
+	b.metricsPathLabels = &cfg
+	return b
+}
+
+// WithStageTiming 设置中间件分阶段计时：按中间件生成子 span 或 span 事件，慢请求输出各阶段耗时日志
+func (b *GatewayBuilder) WithStageTiming(cfg middleware.StageTimingConfig) *GatewayBuilder {
+	b.stageTiming = &cfg
+	return b
+}
+
+// WithFeatures 设置按环境的功能开关矩阵，优先于配置文件 features 段；chaos、mock 不能在生产环境启用
+func (b *GatewayBuilder) WithFeatures(cfg middleware.FeaturesConfig) *GatewayBuilder {
+	b.features = &cfg
+	return b
+}
+
+// WithConfigOverrides 设置配置覆盖来源：环境变量、命令行参数与代码指定的键值；
+// 未调用时不做任何覆盖，环境变量需设置 Env 或在 Args 中传入 --env-overrides 才会读取
+func (b *GatewayBuilder) WithConfigOverrides(cfg overrides.Config) *GatewayBuilder {
+	b.overrideConfig = cfg
+	return b
+}
+
+// WithRedaction 设置日志与 HTTP 响应中配置密钥的脱敏规则（路径、自定义规则、豁免路径等），
+// 未调用时按 sensitive 标签与 go-config 配置结构体内的键名脱敏
+func (b *GatewayBuilder) WithRedaction(cfg redact.Config) *GatewayBuilder {
+	b.redaction = &cfg
+	return b
+}
+
+// WithRemoteConfig 从 HTTP(S) 地址或 S3/MinIO 对象加载配置，优先于 WithConfigPath 等本地配置来源；
+// 配置拉取到本地缓存文件后按普通配置文件加载，后台按 ETag 轮询并在内容变化时热更新
+func (b *GatewayBuilder) WithRemoteConfig(cfg remoteconfig.Config) *GatewayBuilder {
+	b.remoteConfig = &cfg
+	return b
+}
+
+// WithShutdownConfig 设置分阶段优雅关闭配置（排空超时、PreStop 等待等）
+func (b *GatewayBuilder) WithShutdownConfig(cfg *server.ShutdownConfig) *GatewayBuilder {
+	b.shutdownConfig = cfg
+	return b
+}
+
 // Build 构建Gateway (不启动)
 func (b *GatewayBuilder) Build() (*Gateway, error) {
 	// 首先初始化一个临时 logger，用于记录配置加载过程
@@ -196,91 +730,492 @@ func (b *GatewayBuilder) Build() (*Gateway, error) {
 		return nil, errors.NewError(errors.ErrCodeInitializationError, errors.FormatInitError("日志器", err))
 	}
 
-	// 创建配置实例：先放入默认值，再让配置文件覆盖
-	// 这样嵌套的数据库配置不会在后续初始化时退回到框架默认库名
-	config := gwconfig.Default()
+	// 脱敏规则先于配置加载生效，加载过程中输出的配置同样脱敏
+	if b.redaction != nil {
+		redactor, err := redact.New(*b.redaction)
+		if err != nil {
+			return nil, err
+		}
+		redact.SetDefault(redactor)
+	}
+
+	// 先收集覆盖，--set 格式错误时不必再加载配置文件
+	overrideList, err := overrides.Collect(b.overrideConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+	b.overrideList = overrideList
+
+	// 远程配置先拉取到本地缓存文件，再按普通配置文件加载
+	var remote *remoteconfig.Client
+	if b.remoteConfig != nil {
+		if remote, err = b.loadRemoteConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 创建配置实例：先放入默认值，再让配置文件覆盖
+	// 这样嵌套的数据库配置不会在后续初始化时退回到框架默认库名
+	config := gwconfig.Default()
+
+	// 使用go-config创建并启动配置管理器
+	var manager *goconfig.IntegratedConfigManager
+
+	switch {
+	case remote != nil:
+		// 远程配置的本地缓存
+		manager, err = goconfig.NewManager(config).
+			WithConfigPath(remote.Path()).
+			WithEnvironment(b.environment).
+			WithHotReload(b.hotReloadConfig).
+			WithContext(b.contextOptions).
+			BuildAndStart()
+
+	case b.usePattern:
+		// 使用模式匹配
+		manager, err = goconfig.NewManager(config).
+			WithSearchPath(b.searchPath).
+			WithPattern(b.pattern).
+			WithEnvironment(b.environment).
+			WithHotReload(b.hotReloadConfig).
+			WithContext(b.contextOptions).
+			BuildAndStart()
+
+	case b.useCustomPrefix:
+		// 使用自定义前缀发现
+		manager, err = goconfig.NewManager(config).
+			WithSearchPath(b.searchPath).
+			WithPrefix(b.configPrefix).
+			WithEnvironment(b.environment).
+			WithHotReload(b.hotReloadConfig).
+			WithContext(b.contextOptions).
+			BuildAndStart()
+
+	case b.autoDiscovery:
+		// 自动发现
+		manager, err = goconfig.NewManager(config).
+			WithSearchPath(b.searchPath).
+			WithEnvironment(b.environment).
+			WithHotReload(b.hotReloadConfig).
+			WithContext(b.contextOptions).
+			BuildAndStart()
+
+	case b.configPath != "":
+		// 直接使用指定路径
+		manager, err = goconfig.NewManager(config).
+			WithConfigPath(b.configPath).
+			WithEnvironment(b.environment).
+			WithHotReload(b.hotReloadConfig).
+			WithContext(b.contextOptions).
+			BuildAndStart()
+
+	default:
+		return nil, errors.ErrInvalidConfiguration
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+
+	// go-config 初始加载使用 viper 默认反序列化；这里再走一次带弱类型和
+	// kebab-case 兼容的反序列化，确保 db-name、max-open-conns 等字段完整覆盖默认值
+	if err := goconfig.UnmarshalWithFlexibleNaming(manager.GetViper(), config); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	}
+
+	if err := b.initializeGlobalState(manager, &config); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInitializationError)
+	}
+
+	// 功能开关矩阵需在创建服务器前生效，Swagger 与 PProf 按当前环境关闭
+	if err := applyFeatures(config, b.features, manager.GetViper().Get(constants.ConfigKeyFeatures)); err != nil {
+		return nil, err
+	}
+
+	srv, err := server.NewServer()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeServerCreationFailed)
+	}
+
+	// 添加构建器中配置的 gRPC-Gateway 中间件
+	for _, mw := range b.grpcGatewayMiddlewares {
+		srv.AddGrpcGatewayMiddleware(mw)
+	}
+
+	if b.shutdownConfig != nil {
+		srv.SetShutdownConfig(b.shutdownConfig)
+	}
+
+	if b.autoMethodConfig != nil {
+		srv.SetAutoMethodConfig(b.autoMethodConfig)
+	}
+
+	if b.grpcHealthConfig != nil {
+		srv.SetGRPCHealthConfig(b.grpcHealthConfig)
+	}
+
+	if b.httpTuningConfig != nil {
+		srv.SetHTTPTuningConfig(b.httpTuningConfig)
+	}
+
+	if b.connLimitConfig != nil {
+		srv.SetConnLimitConfig(b.connLimitConfig)
+	}
+
+	if b.slowlorisConfig != nil {
+		srv.SetSlowlorisConfig(b.slowlorisConfig)
+	}
+
+	if b.accessLogConfig != nil {
+		if err := middleware.SetAccessLogSinks(b.accessLogConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.routeLogConfig != nil {
+		if err := srv.SetRouteLogConfig(b.routeLogConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.jsonBackend != "" {
+		if err := response.SetJSONBackend(b.jsonBackend); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.grpcTuningConfig != nil {
+		if err := srv.SetGRPCTuningConfig(b.grpcTuningConfig); err != nil {
+			return nil, errors.Wrap(err, errors.ErrCodeServerCreationFailed)
+		}
+	}
+
+	if b.corsOptions != nil {
+		if manager := srv.GetMiddlewareManager(); manager != nil {
+			manager.SetCORSOptions(b.corsOptions)
+		}
+	}
+
+	for _, e := range b.middlewares {
+		srv.UseMiddleware(e.Name, e.Priority, e.Middleware)
+	}
+
+	if b.middlewareAdminPath != "" {
+		srv.SetMiddlewareAdminPath(b.middlewareAdminPath)
+	}
+
+	if b.gatewayHeaderConfig != nil {
+		if err := srv.SetGatewayHeaderConfig(b.gatewayHeaderConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.streamConfig != nil {
+		if err := srv.SetStreamConfig(b.streamConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.inProcessGRPC != nil {
+		if err := srv.SetInProcessGRPC(*b.inProcessGRPC); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.portFallback != nil {
+		srv.SetPortFallback(*b.portFallback)
+	}
+
+	if b.bannerOptions != nil {
+		srv.SetBannerOptions(*b.bannerOptions)
+	}
+
+	if b.buildInfoConfig != nil {
+		srv.SetBuildInfoConfig(*b.buildInfoConfig)
+	}
+
+	if b.performancePath != nil {
+		srv.SetPerformanceEndpoint(*b.performancePath)
+	}
+
+	if b.watchdog != nil {
+		if err := srv.SetWatchdog(b.watchdog); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.deadlineConfig != nil {
+		if err := srv.SetDeadlineConfig(b.deadlineConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.clientCancel != nil {
+		middleware.SetClientCancelConfig(b.clientCancel)
+	}
+
+	if b.headerLimit != nil {
+		if err := srv.SetHeaderLimit(b.headerLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.requestDecompression != nil {
+		if err := srv.SetRequestDecompression(b.requestDecompression); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.authn != nil {
+		if err := srv.SetAuthentication(b.authn); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.streamLimit != nil {
+		if err := srv.SetStreamLimit(b.streamLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.broadcast != nil {
+		if err := srv.SetBroadcast(b.broadcast); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.rateLimitPenalty != nil {
+		if err := srv.SetRateLimitPenalty(b.rateLimitPenalty); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.rateLimitCosts != nil {
+		if err := srv.SetRateLimitCosts(b.rateLimitCosts); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.rateLimitState != nil {
+		if err := srv.SetRateLimitState(b.rateLimitState); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.rateLimitKeys != nil {
+		if err := srv.SetRateLimitKeys(b.rateLimitKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.identityPropagation != nil {
+		if err := middleware.SetIdentityPropagation(b.identityPropagation); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.bulkheads != nil {
+		if err := middleware.SetBulkheads(b.bulkheads); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.extAuthz != nil {
+		if err := srv.SetExtAuthz(b.extAuthz); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.casbin != nil {
+		if err := srv.SetCasbin(b.casbin); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.multipart != nil {
+		if err := srv.SetMultipart(b.multipart); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.uploadScan != nil {
+		if err := middleware.SetUploadScan(b.uploadScan); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.media != nil {
+		if err := srv.SetMedia(b.media); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.jobs != nil {
+		if err := srv.SetJobs(b.jobs); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.webhooks != nil {
+		if err := srv.SetWebhooks(b.webhooks); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.schedules != nil {
+		if err := srv.SetSchedules(b.schedules); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.leakDetector != nil {
+		if err := srv.SetLeakDetector(b.leakDetector); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.descriptors != nil {
+		if err := srv.SetDescriptors(b.descriptors); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.sdkGenerator != nil {
+		if err := srv.SetSDKGenerator(b.sdkGenerator); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.portal != nil {
+		if err := srv.SetPortal(b.portal); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.openAPIValidation != nil {
+		if err := srv.SetOpenAPIValidation(b.openAPIValidation); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.schemaRegistry != nil {
+		if err := srv.SetSchemaRegistry(b.schemaRegistry); err != nil {
+			return nil, err
+		}
+	}
 
-	// 使用go-config创建并启动配置管理器
-	var manager *goconfig.IntegratedConfigManager
-	var err error
+	if b.signedURL != nil {
+		if err := srv.SetSignedURL(b.signedURL); err != nil {
+			return nil, err
+		}
+	}
 
-	switch {
-	case b.usePattern:
-		// 使用模式匹配
-		manager, err = goconfig.NewManager(config).
-			WithSearchPath(b.searchPath).
-			WithPattern(b.pattern).
-			WithEnvironment(b.environment).
-			WithHotReload(b.hotReloadConfig).
-			WithContext(b.contextOptions).
-			BuildAndStart()
+	if b.slowStart != nil {
+		if err := grpcpool.SetSlowStartConfig(*b.slowStart); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "%v", err)
+		}
+	}
 
-	case b.useCustomPrefix:
-		// 使用自定义前缀发现
-		manager, err = goconfig.NewManager(config).
-			WithSearchPath(b.searchPath).
-			WithPrefix(b.configPrefix).
-			WithEnvironment(b.environment).
-			WithHotReload(b.hotReloadConfig).
-			WithContext(b.contextOptions).
-			BuildAndStart()
+	if b.responseBuffering != nil {
+		if err := srv.SetResponseBuffering(b.responseBuffering); err != nil {
+			return nil, err
+		}
+	}
 
-	case b.autoDiscovery:
-		// 自动发现
-		manager, err = goconfig.NewManager(config).
-			WithSearchPath(b.searchPath).
-			WithEnvironment(b.environment).
-			WithHotReload(b.hotReloadConfig).
-			WithContext(b.contextOptions).
-			BuildAndStart()
+	if b.stickySession != nil {
+		if err := srv.SetStickySession(b.stickySession); err != nil {
+			return nil, err
+		}
+	}
 
-	case b.configPath != "":
-		// 直接使用指定路径
-		manager, err = goconfig.NewManager(config).
-			WithConfigPath(b.configPath).
-			WithEnvironment(b.environment).
-			WithHotReload(b.hotReloadConfig).
-			WithContext(b.contextOptions).
-			BuildAndStart()
+	if b.priorityLimit != nil {
+		if err := srv.SetPriorityLimit(b.priorityLimit); err != nil {
+			return nil, err
+		}
+	}
 
-	default:
-		return nil, errors.ErrInvalidConfiguration
+	if b.fallbacks != nil {
+		if err := srv.SetFallbacks(b.fallbacks); err != nil {
+			return nil, err
+		}
 	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	if b.dnsResolver != nil {
+		if err := grpcpool.SetDNSResolverConfig(*b.dnsResolver); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "%v", err)
+		}
 	}
 
-	// go-config 初始加载使用 viper 默认反序列化；这里再走一次带弱类型和
-	// kebab-case 兼容的反序列化，确保 db-name、max-open-conns 等字段完整覆盖默认值
-	if err := goconfig.UnmarshalWithFlexibleNaming(manager.GetViper(), config); err != nil {
-		return nil, errors.Wrap(err, errors.ErrCodeInvalidConfiguration)
+	if b.k8sDiscovery != nil {
+		if err := grpcpool.SetK8sDiscoveryConfig(*b.k8sDiscovery); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "%v", err)
+		}
 	}
 
-	if err := b.initializeGlobalState(manager, &config); err != nil {
-		return nil, errors.Wrap(err, errors.ErrCodeInitializationError)
+	if b.cacheControl != nil {
+		if err := srv.SetCacheControl(b.cacheControl); err != nil {
+			return nil, err
+		}
 	}
 
-	srv, err := server.NewServer()
-	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrCodeServerCreationFailed)
+	if b.metricsPathLabels != nil {
+		if err := srv.SetMetricsPathLabels(*b.metricsPathLabels); err != nil {
+			return nil, err
+		}
 	}
 
-	// 添加构建器中配置的 gRPC-Gateway 中间件
-	for _, mw := range b.grpcGatewayMiddlewares {
-		srv.AddGrpcGatewayMiddleware(mw)
+	if b.stageTiming != nil {
+		if err := srv.SetStageTiming(b.stageTiming); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyDeclarativeRoutes(srv, manager.GetViper().Get(constants.ConfigKeyRoutes)); err != nil {
+		return nil, err
+	}
+
+	if err := applyScriptHooks(srv, manager.GetViper().Get(constants.ConfigKeyScripts)); err != nil {
+		return nil, err
+	}
+
+	searchBackend := b.searchBackend
+	if raw := manager.GetViper().Get(constants.ConfigKeySearch); searchBackend == nil && raw != nil {
+		if searchBackend, err = search.ParseConfig(raw); err != nil {
+			return nil, err
+		}
 	}
 
 	gateway := &Gateway{
-		Server:        srv,
-		configManager: manager,
-		gatewayConfig: config,
-		ctx:           b.ctx,
+		Server:          srv,
+		configManager:   manager,
+		gatewayConfig:   config,
+		ctx:             b.ctx,
+		elector:         leader.NewElector(global.STORE, b.leaderConfig),
+		features:        b.features,
+		configOverrides: b.overrideList,
+	}
+
+	if err := gateway.initSearch(searchBackend); err != nil {
+		return nil, err
+	}
+
+	if err := gateway.initCollectionExport(b.collections); err != nil {
+		return nil, err
+	}
+
+	if err := gateway.initConfigHistory(b.configHistory); err != nil {
+		return nil, err
 	}
 
 	// 注册配置变更回调
 	gateway.RegisterConfigCallbacks()
 
+	// 回调注册后再开始轮询，远程配置的变更能触发全部热更新回调
+	gateway.startRemoteConfig(remote)
+
+	// 注册关闭阶段钩子
+	gateway.registerShutdownHooks()
+
 	return gateway, nil
 }
 
@@ -318,6 +1253,7 @@ func (b *GatewayBuilder) MustBuildAndStart(ctx ...context.Context) *Gateway {
 func mergeGatewayConfigWithDefaults(config *gwconfig.Gateway) *gwconfig.Gateway {
 	merged := safe.MergeWithDefaults(config, gwconfig.Default())
 	refreshGatewayDerivedFields(merged)
+	global.ApplyBuildInfo(merged)
 	return merged
 }
 
@@ -344,9 +1280,18 @@ func (b *GatewayBuilder) initializeGlobalState(manager *goconfig.IntegratedConfi
 	// 使用 safe.MergeWithDefaults 合并默认配置
 	*config = mergeGatewayConfigWithDefaults(*config)
 
+	// 环境变量与 --set 覆盖优先于配置文件与默认值
+	if err := applyConfigOverrides(*config, b.overrideList); err != nil {
+		return err
+	}
+
 	// 设置全局变量
 	global.CONFIG_MANAGER = manager
 	global.GATEWAY = *config
+	global.STORE_OPTIONS = b.embeddedStoreOptions
+	if b.customStore != nil {
+		global.STORE = b.customStore
+	}
 
 	// 初始化全局上下文
 	global.CTX, global.CANCEL = context.WithCancel(context.Background())
@@ -371,6 +1316,10 @@ func (b *GatewayBuilder) registerGlobalConfigCallbacks(manager *goconfig.Integra
 		if newConfig, ok := event.NewValue.(*gwconfig.Gateway); ok {
 			// 合并默认配置
 			newConfig = mergeGatewayConfigWithDefaults(newConfig)
+			if err := applyConfigOverrides(newConfig, b.overrideList); err != nil {
+				global.LOGGER.ErrorContext(b.Context(), "❌ 套用配置覆盖失败: %v", err)
+				return err
+			}
 			global.LOGGER.InfoContext(b.Context(), "📋 配置已更新: %s", newConfig.Name)
 			global.GATEWAY = newConfig
 
@@ -464,6 +1413,9 @@ func (g *Gateway) RegisterProxyHandler(registerFunc HandlerRegisterFunc, endpoin
 	if len(opts) == 0 {
 		opts = g.Server.GetDialOptions()
 	}
+	opts = append(slices.Clip(opts), middleware.BulkheadDialOptions(endpoint)...)
+	opts = append(opts, middleware.IdentityDialOptions(endpoint)...)
+	opts = append(opts, middleware.UpstreamErrorDialOptions(endpoint)...)
 
 	gwMux := g.GetGatewayMux()
 	if err := registerFunc(g.Context(), gwMux, endpoint, opts); err != nil {
@@ -482,6 +1434,32 @@ func (g *Gateway) RegisterProxyHandler(registerFunc HandlerRegisterFunc, endpoin
 	return nil
 }
 
+// NewUpstreamPool 创建独立的 gRPC-Gateway 代理处理器作为蓝绿切换的一组上游
+// 与 RegisterProxyHandler 相同的注册函数，指向不同的 endpoint 即得到 blue / green 两组，
+// 交给 SetCutover 后由管理接口切换
+// 使用示例:
+//
+//	blue, _ := g.NewUpstreamPool(orderpb.RegisterOrderServiceHandlerFromEndpoint, "order-blue:50051")
+//	green, _ := g.NewUpstreamPool(orderpb.RegisterOrderServiceHandlerFromEndpoint, "order-green:50051")
+//	g.SetCutover(&middleware.CutoverConfig{Routes: []middleware.CutoverRoute{{Prefix: "/api/v1/orders", Blue: blue, Green: green}}})
+func (g *Gateway) NewUpstreamPool(registerFunc HandlerRegisterFunc, endpoint string, dialOpts ...grpc.DialOption) (http.Handler, error) {
+	opts := dialOpts
+	if len(opts) == 0 {
+		opts = g.Server.GetDialOptions()
+	}
+	opts = append(slices.Clip(opts), middleware.BulkheadDialOptions(endpoint)...)
+	opts = append(opts, middleware.IdentityDialOptions(endpoint)...)
+	opts = append(opts, middleware.UpstreamErrorDialOptions(endpoint)...)
+
+	mux := g.Server.NewGatewayMux()
+	if err := registerFunc(g.Context(), mux, endpoint, opts); err != nil {
+		global.LOGGER.ErrorContext(g.Context(), "❌ 创建上游组失败: endpoint=%s, error=%v", endpoint, err)
+		return nil, err
+	}
+	g.registeredGatewayHandlers = append(g.registeredGatewayHandlers, "gRPC-Gateway-Upstream@"+endpoint)
+	return mux, nil
+}
+
 // RegisterProxyHandlerByServiceName 通过服务名注册gRPC-Gateway代理处理器
 // 从网关配置的 grpc.clients 中查找服务端点并构建完整的 dial options，无需手动指定
 // 同一服务名共享一个 gRPC 连接，多个 handler 复用同一连接
@@ -554,6 +1532,79 @@ func (g *Gateway) RegisterProxyHandlerByServiceName(serviceName string, register
 	return nil
 }
 
+// RegisterGatewayConnHandler 基于 *grpc.ClientConn 注册 protoc-gen-grpc-gateway 生成的 RegisterXxxServiceHandler
+// serviceName 为空时连接本进程的 gRPC 服务，请求会经过 gRPC 服务端拦截器链；否则等同于 RegisterProxyHandlerByServiceName，
+// 从 grpc.clients 配置中查找远程服务端点。路由与其它 gRPC-Gateway 处理器共用同一个 mux 及中间件链，重建网关时自动重放
+// 使用示例:
+//
+//	g.RegisterGatewayConnHandler("", func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+//	    return pb.RegisterUserServiceHandler(ctx, mux, conn)
+//	})
+func (g *Gateway) RegisterGatewayConnHandler(serviceName string, registerFunc ConnHandlerRegisterFunc) error {
+	if serviceName != "" {
+		return g.RegisterProxyHandlerByServiceName(serviceName, registerFunc)
+	}
+
+	conn, err := g.localGRPCConn()
+	if err != nil {
+		return err
+	}
+
+	gwMux := g.GetGatewayMux()
+	if err := registerFunc(g.Context(), gwMux, conn); err != nil {
+		global.LOGGER.ErrorContext(g.Context(), "❌ 注册gRPC-Gateway处理器失败(本进程连接): error=%v", err)
+		return err
+	}
+
+	g.proxyHandlerRegistrations = append(g.proxyHandlerRegistrations, proxyHandlerRegistration{
+		connRegisterFunc: registerFunc,
+		conn:             conn,
+	})
+	g.registeredGatewayHandlers = append(g.registeredGatewayHandlers, "gRPC-Gateway-Local@"+conn.Target())
+
+	global.LOGGER.DebugContext(g.Context(), "✅ gRPC-Gateway处理器注册成功(本进程连接): target=%s", conn.Target())
+	return nil
+}
+
+// localGRPCTarget 经 TCP 连接本进程 gRPC 服务时使用的 target（实际地址由 dialer 决定）
+const localGRPCTarget = "passthrough:///local-grpc"
+
+// localGRPCConn 获取连接本进程 gRPC 服务的共享连接
+// 启用进程内通道时走内存连接，否则经 TCP 连接监听地址（通配地址改为回环地址）
+func (g *Gateway) localGRPCConn() (*grpc.ClientConn, error) {
+	if g.localConn != nil {
+		return g.localConn, nil
+	}
+
+	// 进程内通道：经内存监听器直连 gRPC 服务，不依赖 TCP 端口
+	if g.Server.InProcessGRPCEnabled() {
+		conn, err := grpc.NewClient(server.InProcessTarget, g.Server.InProcessDialOptions()...)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "failed to create in-process gRPC client: %v", err)
+		}
+		g.localConn = conn
+		global.LOGGER.DebugContext(g.Context(), "创建本进程 gRPC 连接: target=%s", server.InProcessTarget)
+		return conn, nil
+	}
+
+	if g.gatewayConfig == nil || g.gatewayConfig.GRPC == nil || g.gatewayConfig.GRPC.Server == nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "gRPC server config is required to dial the in-process gRPC server")
+	}
+
+	// 地址在每次建连时计算，端口回退或热重载改端口后仍连接到实际监听端口
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", g.Server.LocalGRPCAddress())
+	}
+	opts := append(g.Server.GetDialOptions(), grpc.WithContextDialer(dialer))
+	conn, err := grpc.NewClient(localGRPCTarget, opts...)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "failed to create in-process gRPC client: %v", err)
+	}
+	g.localConn = conn
+	global.LOGGER.DebugContext(g.Context(), "创建本进程 gRPC 连接: address=%s", g.Server.LocalGRPCAddress())
+	return conn, nil
+}
+
 // GetGRPCEndpoint 获取gRPC客户端端点地址
 func (g *Gateway) GetGRPCEndpoint(serviceName string) (string, bool) {
 	if g.gatewayConfig == nil || g.gatewayConfig.GRPC == nil || g.gatewayConfig.GRPC.Clients == nil {
@@ -596,6 +1647,121 @@ func (g *Gateway) RegisterHTTPRoute(pattern string, handlerFunc http.HandlerFunc
 	global.LOGGER.DebugContext(g.Context(), "✅ HTTP路由注册成功: pattern=%s", pattern)
 }
 
+// RegisterJob 注册异步任务路由，需先通过 WithJobs 或 Server.SetJobs 启用任务队列
+//
+// 使用示例:
+//
+//	gw.RegisterJob("/api/v1/reports/export", func(ctx context.Context, job *middleware.Job) (*middleware.JobResult, error) {
+//		_ = job.Progress(ctx, 50, "querying")
+//		return &middleware.JobResult{File: csv, FileName: "report.csv", ContentType: "text/csv", Size: -1}, nil
+//	})
+func (g *Gateway) RegisterJob(pattern string, fn middleware.JobFunc) {
+	g.RegisterHTTPRoute(pattern, g.Server.JobHandler(fn))
+}
+
+// PublishWebhook 发布事件，投递给订阅了 eventType 的 Webhook，返回事件 ID；需先通过 WithWebhooks 启用
+func (g *Gateway) PublishWebhook(eventType string, data any) (string, error) {
+	webhooks := g.Server.GetWebhooks()
+	if webhooks == nil {
+		return "", errors.NewError(errors.ErrCodeServiceUnavailable, "webhooks are not configured")
+	}
+	id, appErr := webhooks.Publish(eventType, data)
+	if appErr != nil {
+		return "", appErr
+	}
+	return id, nil
+}
+
+// FileDescriptorSet 导出已注册服务及其依赖的描述符，services 为空时导出全部；
+// 未通过 WithDescriptors 启用时按默认来源（本进程注册与 reflection 发现的服务）导出
+func (g *Gateway) FileDescriptorSet(services ...string) (*descriptorpb.FileDescriptorSet, error) {
+	descriptors := g.Server.GetDescriptors()
+	if descriptors == nil {
+		var err error
+		if descriptors, err = middleware.NewDescriptors(middleware.DescriptorsConfig{}, g.Server.RegisteredServiceNames); err != nil {
+			return nil, err
+		}
+	}
+	set, appErr := descriptors.FileDescriptorSet(services...)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return set, nil
+}
+
+// Broadcast 向订阅了 topic 的在线 SSE / WebSocket 客户端推送消息，topic 为空时推送给全部客户端，
+// filters 进一步按用户与租户筛选；需先通过 WithBroadcast 启用
+func (g *Gateway) Broadcast(topic string, message any, filters ...middleware.BroadcastFilter) (middleware.BroadcastResult, error) {
+	broadcaster := g.Server.GetBroadcaster()
+	if broadcaster == nil {
+		return middleware.BroadcastResult{}, errors.NewError(errors.ErrCodeServiceUnavailable, "broadcast is not configured")
+	}
+	var filter middleware.BroadcastFilter
+	for _, f := range filters {
+		filter.UserIDs = append(filter.UserIDs, f.UserIDs...)
+		filter.Tenants = append(filter.Tenants, f.Tenants...)
+	}
+	result, appErr := broadcaster.Publish(topic, message, filter)
+	if appErr != nil {
+		return result, appErr
+	}
+	return result, nil
+}
+
+// RegisterResource 为 GORM 模型注册 CRUD 路由（列表、详情、创建、替换、局部更新、删除），使用 global.DB
+//
+// 使用示例:
+//
+//	gw.RegisterResource("/api/v1/products", &Product{})
+//	// GET /api/v1/products?page=2&size=20&sort=-price&price__gte=10
+func (g *Gateway) RegisterResource(pattern string, model any) error {
+	return g.RegisterResourceWith(pattern, model, resource.Config{})
+}
+
+// RegisterResourceWith 按配置注册 CRUD 路由，可指定 PB 类型、可过滤 / 排序列、只读及数据范围
+//
+// 使用示例:
+//
+//	gw.RegisterResourceWith("/api/v1/products", &Product{}, resource.Config{
+//		PB:       &pb.Product{},
+//		Sortable: []string{"price", "created_at"},
+//		Scope: func(r *http.Request, db *gorm.DB) *gorm.DB {
+//			return db.Where("tenant_id = ?", r.Header.Get("X-Tenant-ID"))
+//		},
+//	})
+func (g *Gateway) RegisterResourceWith(pattern string, model any, cfg resource.Config) error {
+	res, err := resource.New(pattern, model, cfg)
+	if err != nil {
+		return err
+	}
+	g.RegisterHTTPRoute(res.Prefix(), res.ServeHTTP)
+	g.RegisterHTTPRoute(res.Prefix()+"/", res.ServeHTTP)
+	return nil
+}
+
+// RegisterImport 注册批量导入路由：POST 上传 CSV / XLSX 导入 model，GET {pattern}/template 下载模板，
+// GET {pattern}/reports/{id} 下载拒绝行报告
+//
+// 使用示例:
+//
+//	gw.RegisterImport("/api/v1/users/import", &User{}, importer.Config{
+//		BatchSize: 1000,
+//		BeforeInsert: func(r *http.Request, model any) error {
+//			model.(*User).TenantID = r.Header.Get("X-Tenant-ID")
+//			return nil
+//		},
+//	})
+//	// curl -F file=@users.xlsx "https://host/api/v1/users/import?dry_run=true"
+func (g *Gateway) RegisterImport(pattern string, model any, cfg importer.Config) error {
+	im, err := importer.New(pattern, model, cfg)
+	if err != nil {
+		return err
+	}
+	g.RegisterHTTPRoute(im.Prefix(), im.ServeHTTP)
+	g.RegisterHTTPRoute(im.Prefix()+"/", im.ServeHTTP)
+	return nil
+}
+
 // RegisterHTTPRoutes 批量注册HTTP路由
 func (g *Gateway) RegisterHTTPRoutes(routes map[string]http.HandlerFunc) {
 	for pattern, handler := range routes {
@@ -761,6 +1927,24 @@ func (g *Gateway) SetDynamicRateLimitProvider(provider middleware.DynamicRateLim
 	}
 }
 
+// SetCORSOptions 设置 CORS 路由组覆盖与演练模式（运行中立即生效）
+func (g *Gateway) SetCORSOptions(opts *middleware.CORSOptions) {
+	if manager := g.Server.GetMiddlewareManager(); manager != nil {
+		manager.SetCORSOptions(opts)
+		global.LOGGER.InfoContext(g.Context(), "✅ 已设置 CORS 路由组配置")
+	}
+}
+
+// UseMiddleware 添加自定义 HTTP 中间件并重新编译中间件链，对之后的请求立即生效
+func (g *Gateway) UseMiddleware(name string, priority int, mw middleware.MiddlewareFunc) {
+	g.Server.UseMiddleware(name, priority, mw)
+}
+
+// MiddlewareOrder 返回当前生效的 HTTP 中间件执行顺序
+func (g *Gateway) MiddlewareOrder() []middleware.ChainEntry {
+	return g.Server.MiddlewareOrder()
+}
+
 // Context 获取 Gateway 的上下文
 func (g *Gateway) Context() context.Context {
 	if g.ctx == nil {
@@ -842,22 +2026,82 @@ func (g *Gateway) StartWithBanner() error {
 func (g *Gateway) Stop() error {
 	global.LOGGER.InfoContext(g.Context(), "🛑 开始停止网关服务...")
 
-	// 先停止服务器
-	if err := g.Server.Stop(); err != nil {
+	// 按阶段停止服务器，选主器与配置管理器通过关闭钩子在对应阶段停止
+	err := g.Server.Stop()
+
+	// 服务器未运行（启动失败或重复 Stop）时不会执行关闭钩子，这里兜底停止；各停止操作均幂等
+	g.stopElector()
+	g.stopRemoteConfig()
+	g.stopConfigManager()
+
+	if err != nil {
 		global.LOGGER.ErrorContext(g.Context(), "❌ 停止服务器失败: error=%v", err)
 		return err
 	}
-	global.LOGGER.InfoContext(g.Context(), "✅ 服务器已停止")
 
-	// 再停止配置管理器
-	if g.configManager != nil {
+	global.LOGGER.InfoContext(g.Context(), "✅ 网关服务已完全停止")
+	return nil
+}
+
+// registerShutdownHooks 注册网关级关闭钩子
+func (g *Gateway) registerShutdownHooks() {
+	// 后台任务阶段：停止选主并释放租约，便于其他副本尽快接管
+	g.Server.OnShutdown(server.PhaseBackground, "leader-elector", func(ctx context.Context) error {
+		g.stopElector()
+		return nil
+	})
+
+	// 后台任务阶段：停止远程配置轮询，避免关闭过程中触发重载
+	g.Server.OnShutdown(server.PhaseBackground, "remote-config", func(ctx context.Context) error {
+		g.stopRemoteConfig()
+		return nil
+	})
+
+	// 基础设施阶段：关闭本进程 gRPC 连接（HTTP 请求已在排空阶段完成）
+	g.Server.OnShutdown(server.PhaseInfra, "local-grpc-conn", func(ctx context.Context) error {
+		if g.localConn != nil {
+			return g.localConn.Close()
+		}
+		return nil
+	})
+
+	// 基础设施阶段：释放检索客户端的空闲连接
+	g.Server.OnShutdown(server.PhaseInfra, "search-client", func(ctx context.Context) error {
+		if g.searchClient != nil {
+			g.searchClient.Close()
+		}
+		return nil
+	})
+
+	// 基础设施阶段：停止配置管理器
+	g.Server.OnShutdown(server.PhaseInfra, "config-manager", func(ctx context.Context) error {
+		g.stopConfigManager()
+		return nil
+	})
+}
+
+// stopElector 停止选主并释放租约，可重复调用；停止后 RunIfLeader 不再参与选主
+func (g *Gateway) stopElector() {
+	g.getElector().Stop()
+}
+
+// stopRemoteConfig 停止远程配置轮询，可重复调用
+func (g *Gateway) stopRemoteConfig() {
+	if g.remoteConfig != nil {
+		g.remoteConfig.Stop()
+	}
+}
+
+// stopConfigManager 停止配置管理器，只执行一次
+func (g *Gateway) stopConfigManager() {
+	if g.configManager == nil {
+		return
+	}
+	g.configManagerStop.Do(func() {
 		global.LOGGER.InfoContext(g.Context(), "停止配置管理器...")
 		g.configManager.Stop()
 		global.LOGGER.InfoContext(g.Context(), "✅ 配置管理器已停止")
-	}
-
-	global.LOGGER.InfoContext(g.Context(), "✅ 网关服务已完全停止")
-	return nil
+	})
 }
 
 // PrintStartupInfo 打印启动信息
@@ -922,6 +2166,15 @@ func (g *Gateway) PrintAPIRegistrationSummary() {
 		global.LOGGER.InfoContext(g.Context(), "  (无注册路由)")
 	}
 
+	// proto 路由统计
+	if len(g.routeInfos) > 0 {
+		global.LOGGER.InfoMsg("")
+		global.LOGGER.InfoContext(g.Context(), "🧭 Proto Routes: %d", len(g.routeInfos))
+		for i, route := range g.routeInfos {
+			global.LOGGER.InfoContext(g.Context(), "  %d. %s %s -> %s", i+1, route.HTTPMethod, route.Pattern, route.FullMethod)
+		}
+	}
+
 	// 总计
 	totalAPIs := len(g.registeredGRPCServices) + len(g.registeredGatewayHandlers) + len(g.registeredHTTPRoutes)
 	global.LOGGER.InfoMsg("")
@@ -985,7 +2238,14 @@ func (g *Gateway) RegisterConfigCallbacks() {
 		}
 
 		newConfig = mergeGatewayConfigWithDefaults(newConfig)
-		return g.applyReloadedConfig(ctx, newConfig)
+		if err := applyConfigOverrides(newConfig, g.configOverrides); err != nil {
+			return err
+		}
+		if err := g.applyReloadedConfig(ctx, newConfig); err != nil {
+			return err
+		}
+		g.recordConfigVersion(ctx, confighistory.SourceReload)
+		return nil
 	}, goconfig.CallbackOptions{
 		ID:       "gateway_runtime_config_handler",
 		Types:    []goconfig.CallbackType{goconfig.CallbackTypeConfigChanged},
@@ -1006,6 +2266,22 @@ func (g *Gateway) RegisterConfigCallbacks() {
 func (g *Gateway) applyReloadedConfig(ctx context.Context, newConfig *gwconfig.Gateway) error {
 	oldConfig := g.Server.GetConfig()
 
+	// 功能开关矩阵先于声明式路由生效，路由据此启用或跳过所属功能的规则
+	if err := applyFeatures(newConfig, g.features, g.configManager.GetViper().Get(constants.ConfigKeyFeatures)); err != nil {
+		global.LOGGER.ErrorContext(ctx, "功能开关配置无效，保留当前开关: %v", err)
+		return err
+	}
+
+	// 声明式路由与脚本钩子校验失败时保留当前生效的版本并中止本次重载
+	if err := applyDeclarativeRoutes(g.Server, g.configManager.GetViper().Get(constants.ConfigKeyRoutes)); err != nil {
+		global.LOGGER.ErrorContext(ctx, "声明式路由配置无效，保留当前路由: %v", err)
+		return err
+	}
+	if err := applyScriptHooks(g.Server, g.configManager.GetViper().Get(constants.ConfigKeyScripts)); err != nil {
+		global.LOGGER.ErrorContext(ctx, "脚本钩子配置无效，保留当前脚本: %v", err)
+		return err
+	}
+
 	global.LOGGER.InfoContext(g.Context(), errors.FormatConfigUpdateInfo(newConfig.Name))
 	g.gatewayConfig = newConfig
 	global.GATEWAY = newConfig
@@ -0,0 +1,200 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\gatewayctx\gatewayctx.go
+ * @Description: 请求上下文标准字段 - 请求ID、用户、租户、语言、客户端IP、认证声明与截止时间的类型化读写，
+ *               与 middleware.RequestContextMiddleware / gRPC 拦截器写入的字段共用同一套 key
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gatewayctx
+
+import (
+	"context"
+	"time"
+
+	goi18n "github.com/kamalyes/go-i18n"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-toolbox/pkg/contextx"
+)
+
+// Key 类型化的上下文 key，替代字符串 key，避免不同组件之间的 key 冲突与类型断言
+//
+// 使用示例:
+//
+//	var OrderKey = gatewayctx.NewKey[*Order]("order")
+//	ctx = OrderKey.With(ctx, order)
+//	order, ok := OrderKey.Get(ctx)
+type Key[T any] struct {
+	name string
+}
+
+// NewKey 创建类型化 key，name 仅用于调试输出，key 按指针地址区分
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// With 写入值
+func (k *Key[T]) With(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, k, value)
+}
+
+// Get 读取值，未设置时 ok 为 false
+func (k *Key[T]) Get(ctx context.Context) (T, bool) {
+	var zero T
+	if ctx == nil {
+		return zero, false
+	}
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// Value 读取值，未设置时返回零值
+func (k *Key[T]) Value(ctx context.Context) T {
+	v, _ := k.Get(ctx)
+	return v
+}
+
+// String 返回 key 名称
+func (k *Key[T]) String() string {
+	return "gatewayctx." + k.name
+}
+
+// fieldSyncs 标准字段写入后的同步回调
+var fieldSyncs []func(ctx context.Context, key, value string)
+
+// OnFieldSet 注册标准字段写入回调，key 为 constants.MetadataXxx；仅在 init 阶段调用
+// middleware 包借此让 RequestCommonMeta 与上下文中的字段保持一致
+func OnFieldSet(fn func(ctx context.Context, key, value string)) {
+	fieldSyncs = append(fieldSyncs, fn)
+}
+
+// withField 写入标准字段并通知同步回调
+func withField(ctx context.Context, key, value string) context.Context {
+	ctx = contextx.WithValue(ctx, key, value)
+	for _, fn := range fieldSyncs {
+		fn(ctx, key, value)
+	}
+	return ctx
+}
+
+// field 读取标准字段
+func field(ctx context.Context, key string) string {
+	return contextx.GetValue[string](ctx, key)
+}
+
+// RequestID 请求ID
+func RequestID(ctx context.Context) string {
+	return field(ctx, constants.MetadataRequestID)
+}
+
+// WithRequestID 设置请求ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return withField(ctx, constants.MetadataRequestID, requestID)
+}
+
+// TraceID 链路追踪ID
+func TraceID(ctx context.Context) string {
+	return field(ctx, constants.MetadataTraceID)
+}
+
+// WithTraceID 设置链路追踪ID
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return withField(ctx, constants.MetadataTraceID, traceID)
+}
+
+// UserID 用户ID
+func UserID(ctx context.Context) string {
+	return field(ctx, constants.MetadataUserID)
+}
+
+// WithUserID 设置用户ID
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return withField(ctx, constants.MetadataUserID, userID)
+}
+
+// TenantID 租户ID
+func TenantID(ctx context.Context) string {
+	return field(ctx, constants.MetadataTenantID)
+}
+
+// WithTenantID 设置租户ID
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return withField(ctx, constants.MetadataTenantID, tenantID)
+}
+
+// TenantCode 租户编码
+func TenantCode(ctx context.Context) string {
+	return field(ctx, constants.MetadataTenantCode)
+}
+
+// WithTenantCode 设置租户编码
+func WithTenantCode(ctx context.Context, tenantCode string) context.Context {
+	return withField(ctx, constants.MetadataTenantCode, tenantCode)
+}
+
+// ClientIP 客户端IP
+func ClientIP(ctx context.Context) string {
+	return field(ctx, constants.MetadataIPAddress)
+}
+
+// WithClientIP 设置客户端IP
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return withField(ctx, constants.MetadataIPAddress, ip)
+}
+
+// Language 请求语言：优先取 i18n 中间件解析后的语言，否则取原始 Accept-Language
+func Language(ctx context.Context) string {
+	if ctx != nil && goi18n.FromContext(ctx) != nil {
+		return goi18n.GetLanguage(ctx)
+	}
+	return field(ctx, constants.MetadataAcceptLanguage)
+}
+
+// WithLanguage 设置请求语言，同时切换 i18n 上下文的翻译语言
+func WithLanguage(ctx context.Context, language string) context.Context {
+	ctx = goi18n.SetLanguage(ctx, language)
+	return withField(ctx, constants.MetadataAcceptLanguage, language)
+}
+
+// claimsKey 认证声明的上下文 key
+type claimsKey struct{}
+
+// WithAuthClaims 设置认证声明（JWT claims、API Key 主体等），类型由认证中间件决定
+func WithAuthClaims(ctx context.Context, claims any) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// AuthClaims 读取认证声明，未设置或类型不符时 ok 为 false
+//
+// 使用示例:
+//
+//	claims, ok := gatewayctx.AuthClaims[*jwt.RegisteredClaims](ctx)
+func AuthClaims[T any](ctx context.Context) (T, bool) {
+	var zero T
+	if ctx == nil {
+		return zero, false
+	}
+	claims, ok := ctx.Value(claimsKey{}).(T)
+	return claims, ok
+}
+
+// Deadline 请求截止时间
+func Deadline(ctx context.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	return ctx.Deadline()
+}
+
+// Remaining 距截止时间的剩余时长，未设置截止时间时 ok 为 false，已过期时返回 0
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := Deadline(ctx)
+	if !ok {
+		return 0, false
+	}
+	return max(time.Until(deadline), 0), true
+}
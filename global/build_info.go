@@ -0,0 +1,66 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\global\build_info.go
+ * @Description: 构建信息 - 通过 -ldflags 注入的版本、提交与构建时间，覆盖配置文件中的同名字段
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package global
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// BuildInfo 构建信息
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfo 注入的构建信息
+var buildInfo atomic.Pointer[BuildInfo]
+
+// SetBuildInfo 设置构建信息，需在创建网关之前调用；为空的字段沿用配置文件中的值
+func SetBuildInfo(info BuildInfo) {
+	buildInfo.Store(&info)
+	ApplyBuildInfo(GATEWAY)
+}
+
+// GetBuildInfo 返回当前构建信息：注入值优先，未注入的字段取网关配置
+func GetBuildInfo() BuildInfo {
+	var info BuildInfo
+	if injected := buildInfo.Load(); injected != nil {
+		info = *injected
+	}
+	if cfg := GATEWAY; cfg != nil {
+		info.Version = mathx.IfEmpty(info.Version, cfg.Version)
+		info.Commit = mathx.IfEmpty(info.Commit, cfg.GitCommit)
+		info.BuildTime = mathx.IfEmpty(info.BuildTime, cfg.BuildTime)
+		info.GoVersion = mathx.IfEmpty(info.GoVersion, cfg.GoVersion)
+	}
+	info.GoVersion = mathx.IfEmpty(info.GoVersion, runtime.Version())
+	return info
+}
+
+// ApplyBuildInfo 将注入的构建信息写入网关配置，使 Banner、健康检查等读取配置的位置显示真实构建信息
+// 配置加载与热重载后都会调用
+func ApplyBuildInfo(cfg *gwconfig.Gateway) {
+	injected := buildInfo.Load()
+	if cfg == nil || injected == nil {
+		return
+	}
+	cfg.Version = mathx.IfEmpty(injected.Version, cfg.Version)
+	cfg.GitCommit = mathx.IfEmpty(injected.Commit, cfg.GitCommit)
+	cfg.BuildTime = mathx.IfEmpty(injected.BuildTime, cfg.BuildTime)
+	cfg.GoVersion = mathx.IfEmpty(injected.GoVersion, cfg.GoVersion)
+}
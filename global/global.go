@@ -24,6 +24,7 @@ import (
 	"github.com/kamalyes/go-natsx"
 	"github.com/kamalyes/go-rpc-gateway/cpool"
 	natsclient "github.com/kamalyes/go-rpc-gateway/cpool/nats"
+	"github.com/kamalyes/go-rpc-gateway/store"
 	"github.com/kamalyes/go-toolbox/pkg/desensitize"
 	gowsc "github.com/kamalyes/go-wsc"
 	"github.com/minio/minio-go/v7"
@@ -33,7 +34,7 @@ import (
 
 var (
 	GATEWAY        *gwconfig.Gateway                         // 网关配置
-	LOGGER         logger.ILogger                            // 日志器
+	LOGGER         Logger                                    // 日志器（支持 LOGGER.Ctx(ctx) 关联链路字段）
 	POOL_MANAGER   *cpool.Manager                            // 连接池管理器（所有连接的唯一管理者）
 	CONFIG_MANAGER *goconfig.IntegratedConfigManager         // 统一配置管理器
 	CTX            context.Context                           // 全局上下文
@@ -44,6 +45,8 @@ var (
 	DB             *gorm.DB                                  // 数据库连接（便捷引用，实际由 PoolManager 管理）
 	REDIS          *redis.Client                             // Redis连接（便捷引用，实际由 PoolManager 管理）
 	MinIO          *minio.Client                             // MinIO连接（便捷引用，实际由 PoolManager 管理）
+	STORE          store.Store                               // 中间件状态存储（Redis 不可用时为内嵌存储）
+	STORE_OPTIONS  *store.MemoryOptions                      // 内嵌存储配置（nil 时使用默认配置）
 	DATAMASKER     *desensitize.DataMasker                   // 数据脱敏器
 	GPerFix        string                            = "gw_" // 全局表前缀
 )
@@ -62,7 +65,7 @@ func EnsureLoggerInitialized() error {
 	}
 
 	// 将新创建的 logger 赋值给全局变量
-	LOGGER = newLogger
+	LOGGER = WrapLogger(newLogger)
 	LOG = newLogger // 兼容别名
 	return nil
 }
@@ -86,6 +89,13 @@ func CleanupGlobal() {
 		}
 	}
 
+	// 关闭中间件状态存储（内嵌存储会在此时写入快照）
+	if STORE != nil {
+		if err := STORE.Close(); err != nil {
+			LOGGER.InfoContext(ctx, "❌ 关闭状态存储失败: %v", err)
+		}
+	}
+
 	// 停止配置管理器
 	if CONFIG_MANAGER != nil {
 		if err := CONFIG_MANAGER.Stop(); err != nil {
@@ -100,6 +110,7 @@ func CleanupGlobal() {
 	CONFIG_MANAGER = nil
 	POOL_MANAGER = nil
 	REDIS = nil
+	STORE = nil
 	DB = nil
 	MinIO = nil
 	Node = nil
@@ -143,6 +154,11 @@ func GetRedis() *redis.Client {
 	return REDIS
 }
 
+// GetStore 获取中间件状态存储
+func GetStore() store.Store {
+	return STORE
+}
+
 // GetMinIO 获取MinIO连接
 func GetMinIO() *minio.Client {
 	return MinIO
@@ -21,6 +21,7 @@ import (
 	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
 	"github.com/kamalyes/go-logger"
 	"github.com/kamalyes/go-rpc-gateway/cpool"
+	"github.com/kamalyes/go-rpc-gateway/store"
 	"github.com/kamalyes/go-toolbox/pkg/mathx"
 )
 
@@ -193,7 +194,7 @@ func (i *LoggerInitializer) HealthCheck() error { return nil }
 func (i *LoggerInitializer) Initialize(ctx context.Context, cfg *gwconfig.Gateway) error {
 	isFirstInit := LOGGER == nil
 
-	LOGGER = cfg.Middleware.Logging.ToLoggerInstance()
+	LOGGER = WrapLogger(cfg.Middleware.Logging.ToLoggerInstance())
 	LOG = LOGGER // 兼容别名
 
 	// 根据初始化状态输出不同日志
@@ -295,6 +296,50 @@ func (i *PoolManagerInitializer) HealthCheck() error {
 	return nil
 }
 
+// StoreInitializer 中间件状态存储初始化器
+// 优先使用自定义后端；其次 Redis 可用时使用 Redis，否则使用内嵌存储，保证单节点部署下限流、防重放等功能完整可用
+type StoreInitializer struct{}
+
+func (i *StoreInitializer) Name() string  { return "Store" }
+func (i *StoreInitializer) Priority() int { return 15 }
+
+func (i *StoreInitializer) Initialize(ctx context.Context, cfg *gwconfig.Gateway) error {
+	if STORE != nil {
+		LOGGER.InfoContext(ctx, "✅ 状态存储使用自定义后端: %T", STORE)
+		return nil
+	}
+
+	if REDIS != nil {
+		STORE = store.NewRedisStore(REDIS)
+		LOGGER.InfoContext(ctx, "✅ 状态存储使用 Redis")
+		return nil
+	}
+
+	memStore, err := store.NewMemoryStore(STORE_OPTIONS)
+	if err != nil {
+		return fmt.Errorf("初始化内嵌状态存储失败: %w", err)
+	}
+	STORE = memStore
+	LOGGER.InfoContext(ctx, "✅ Redis 未配置，状态存储降级为内嵌存储")
+	return nil
+}
+
+func (i *StoreInitializer) Cleanup() error {
+	if STORE == nil {
+		return nil
+	}
+	err := STORE.Close()
+	STORE = nil
+	return err
+}
+
+func (i *StoreInitializer) HealthCheck() error {
+	if STORE == nil {
+		return fmt.Errorf("状态存储未初始化")
+	}
+	return nil
+}
+
 // ContextInitializer 全局上下文初始化器
 type ContextInitializer struct{}
 
@@ -326,6 +371,7 @@ func GetDefaultInitializerChain() *InitializerChain {
 	chain.Register(&ContextInitializer{})
 	chain.Register(&SnowflakeInitializer{})
 	chain.Register(&PoolManagerInitializer{})
+	chain.Register(&StoreInitializer{})
 
 	return chain
 }
@@ -0,0 +1,138 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\global\logger_ctx.go
+ * @Description: 日志与链路关联 - 请求上下文中的日志自动携带 trace_id/span_id/request_id，
+ *               提供 LOGGER.Ctx(ctx) 获取带关联字段的日志器
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package global
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kamalyes/go-logger"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-toolbox/pkg/contextx"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// Logger 全局日志器，在 ILogger 基础上提供按请求上下文关联链路字段的能力
+type Logger interface {
+	logger.ILogger
+	// Ctx 返回携带 trace_id/span_id/request_id 字段的日志器，上下文中没有任何关联字段时返回自身
+	Ctx(ctx context.Context) logger.ILogger
+}
+
+// ctxLogger 为 ILogger 增加 Ctx 方法
+type ctxLogger struct {
+	logger.ILogger
+}
+
+// contextExtractorSetter 支持自定义上下文提取器的日志器（go-logger 的 *Logger）
+type contextExtractorSetter interface {
+	SetContextExtractor(extractor logger.ContextExtractor)
+}
+
+// WrapLogger 包装日志器：安装链路字段提取器，使 XxxContext/XxxContextKV 日志自动带上关联字段，并提供 Ctx 方法
+func WrapLogger(l logger.ILogger) Logger {
+	if l == nil {
+		return nil
+	}
+	if wrapped, ok := l.(Logger); ok {
+		return wrapped
+	}
+	if setter, ok := l.(contextExtractorSetter); ok {
+		setter.SetContextExtractor(correlationPrefix)
+	}
+	return &ctxLogger{ILogger: l}
+}
+
+// Ctx 返回携带链路关联字段的日志器
+func (l *ctxLogger) Ctx(ctx context.Context) logger.ILogger {
+	fields := CorrelationFields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return &ctxLogger{ILogger: l.ILogger.WithFields(fields)}
+}
+
+// CorrelationIDs 从上下文提取链路关联 ID
+// trace_id 优先使用网关请求上下文中的值（与访问日志一致），其次是 OpenTelemetry span 与 gRPC 入站 metadata；
+// span_id 只来自 OpenTelemetry span
+func CorrelationIDs(ctx context.Context) (traceID, spanID, requestID string) {
+	if ctx == nil {
+		return "", "", ""
+	}
+
+	traceID = contextx.GetValue[string](ctx, constants.MetadataTraceID)
+	requestID = contextx.GetValue[string](ctx, constants.MetadataRequestID)
+
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		spanID = spanCtx.SpanID().String()
+		if traceID == "" {
+			traceID = spanCtx.TraceID().String()
+		}
+	}
+
+	if traceID == "" || requestID == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(constants.MetadataTraceID); traceID == "" && len(values) > 0 {
+				traceID = values[0]
+			}
+			if values := md.Get(constants.MetadataRequestID); requestID == "" && len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+	}
+	return traceID, spanID, requestID
+}
+
+// CorrelationFields 以日志字段形式返回链路关联 ID（空值不返回）
+func CorrelationFields(ctx context.Context) map[string]any {
+	traceID, spanID, requestID := CorrelationIDs(ctx)
+	fields := make(map[string]any, 3)
+	if traceID != "" {
+		fields[constants.LogFieldTraceID] = traceID
+	}
+	if spanID != "" {
+		fields[constants.LogFieldSpanID] = spanID
+	}
+	if requestID != "" {
+		fields[constants.LogFieldRequestID] = requestID
+	}
+	return fields
+}
+
+// correlationPrefix 上下文日志的消息前缀，格式与 go-logger 默认提取器一致：[trace_id=xxx span_id=xxx request_id=xxx]
+func correlationPrefix(ctx context.Context) string {
+	traceID, spanID, requestID := CorrelationIDs(ctx)
+	if traceID == "" && spanID == "" && requestID == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('[')
+	appendPair := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if sb.Len() > 1 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(value)
+	}
+	appendPair(constants.LogFieldTraceID, traceID)
+	appendPair(constants.LogFieldSpanID, spanID)
+	appendPair(constants.LogFieldRequestID, requestID)
+	sb.WriteString("] ")
+	return sb.String()
+}
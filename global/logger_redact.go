@@ -0,0 +1,215 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\global\logger_redact.go
+ * @Description: 日志脱敏 - LOGGER 的格式化参数、键值对与字段在写出前经过 redact 包处理，
+ *               配置结构体中的密钥不会以明文出现在日志中；WithXxx 派生的日志器同样脱敏
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package global
+
+import (
+	"context"
+
+	"github.com/kamalyes/go-logger"
+	"github.com/kamalyes/go-rpc-gateway/redact"
+)
+
+// redactFields 脱敏字段
+func redactFields(fields map[string]interface{}) map[string]interface{} {
+	return redact.Fields(fields)
+}
+
+// redactArgs 脱敏格式化参数与键值对
+func redactArgs(values []interface{}) []interface{} {
+	return redact.Values(values)
+}
+
+// Debug 等格式化日志：参数脱敏后写出
+func (l *ctxLogger) Debug(format string, a ...interface{}) {
+	l.ILogger.Debug(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Info(format string, a ...interface{}) {
+	l.ILogger.Info(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Warn(format string, a ...interface{}) {
+	l.ILogger.Warn(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Error(format string, a ...interface{}) {
+	l.ILogger.Error(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Fatal(format string, a ...interface{}) {
+	l.ILogger.Fatal(format, redactArgs(a)...)
+}
+
+// Debugf 等格式化日志
+func (l *ctxLogger) Debugf(format string, a ...interface{}) {
+	l.ILogger.Debugf(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Infof(format string, a ...interface{}) {
+	l.ILogger.Infof(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Warnf(format string, a ...interface{}) {
+	l.ILogger.Warnf(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Errorf(format string, a ...interface{}) {
+	l.ILogger.Errorf(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Fatalf(format string, a ...interface{}) {
+	l.ILogger.Fatalf(format, redactArgs(a)...)
+}
+
+// DebugReturn 等记录日志并返回 error
+func (l *ctxLogger) DebugReturn(format string, a ...interface{}) error {
+	return l.ILogger.DebugReturn(format, redactArgs(a)...)
+}
+func (l *ctxLogger) InfoReturn(format string, a ...interface{}) error {
+	return l.ILogger.InfoReturn(format, redactArgs(a)...)
+}
+func (l *ctxLogger) WarnReturn(format string, a ...interface{}) error {
+	return l.ILogger.WarnReturn(format, redactArgs(a)...)
+}
+func (l *ctxLogger) ErrorReturn(format string, a ...interface{}) error {
+	return l.ILogger.ErrorReturn(format, redactArgs(a)...)
+}
+
+// DebugCtxReturn 等带上下文记录日志并返回 error
+func (l *ctxLogger) DebugCtxReturn(ctx context.Context, format string, a ...interface{}) error {
+	return l.ILogger.DebugCtxReturn(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) InfoCtxReturn(ctx context.Context, format string, a ...interface{}) error {
+	return l.ILogger.InfoCtxReturn(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) WarnCtxReturn(ctx context.Context, format string, a ...interface{}) error {
+	return l.ILogger.WarnCtxReturn(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) ErrorCtxReturn(ctx context.Context, format string, a ...interface{}) error {
+	return l.ILogger.ErrorCtxReturn(ctx, format, redactArgs(a)...)
+}
+
+// DebugKVReturn 等键值对日志并返回 error，值脱敏
+func (l *ctxLogger) DebugKVReturn(msg string, kv ...interface{}) error {
+	return l.ILogger.DebugKVReturn(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) InfoKVReturn(msg string, kv ...interface{}) error {
+	return l.ILogger.InfoKVReturn(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) WarnKVReturn(msg string, kv ...interface{}) error {
+	return l.ILogger.WarnKVReturn(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) ErrorKVReturn(msg string, kv ...interface{}) error {
+	return l.ILogger.ErrorKVReturn(msg, redactArgs(kv)...)
+}
+
+// DebugContext 等带上下文的格式化日志
+func (l *ctxLogger) DebugContext(ctx context.Context, format string, a ...interface{}) {
+	l.ILogger.DebugContext(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) InfoContext(ctx context.Context, format string, a ...interface{}) {
+	l.ILogger.InfoContext(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) WarnContext(ctx context.Context, format string, a ...interface{}) {
+	l.ILogger.WarnContext(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) ErrorContext(ctx context.Context, format string, a ...interface{}) {
+	l.ILogger.ErrorContext(ctx, format, redactArgs(a)...)
+}
+func (l *ctxLogger) FatalContext(ctx context.Context, format string, a ...interface{}) {
+	l.ILogger.FatalContext(ctx, format, redactArgs(a)...)
+}
+
+// DebugKV 等键值对日志，值脱敏
+func (l *ctxLogger) DebugKV(msg string, kv ...interface{}) {
+	l.ILogger.DebugKV(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) InfoKV(msg string, kv ...interface{}) {
+	l.ILogger.InfoKV(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) WarnKV(msg string, kv ...interface{}) {
+	l.ILogger.WarnKV(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) ErrorKV(msg string, kv ...interface{}) {
+	l.ILogger.ErrorKV(msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) FatalKV(msg string, kv ...interface{}) {
+	l.ILogger.FatalKV(msg, redactArgs(kv)...)
+}
+
+// DebugWithFields 等字段日志，字段脱敏
+func (l *ctxLogger) DebugWithFields(msg string, fields map[string]interface{}) {
+	l.ILogger.DebugWithFields(msg, redactFields(fields))
+}
+func (l *ctxLogger) InfoWithFields(msg string, fields map[string]interface{}) {
+	l.ILogger.InfoWithFields(msg, redactFields(fields))
+}
+func (l *ctxLogger) WarnWithFields(msg string, fields map[string]interface{}) {
+	l.ILogger.WarnWithFields(msg, redactFields(fields))
+}
+func (l *ctxLogger) ErrorWithFields(msg string, fields map[string]interface{}) {
+	l.ILogger.ErrorWithFields(msg, redactFields(fields))
+}
+func (l *ctxLogger) FatalWithFields(msg string, fields map[string]interface{}) {
+	l.ILogger.FatalWithFields(msg, redactFields(fields))
+}
+
+// DebugContextKV 等带上下文的键值对日志
+func (l *ctxLogger) DebugContextKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.ILogger.DebugContextKV(ctx, msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) InfoContextKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.ILogger.InfoContextKV(ctx, msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) WarnContextKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.ILogger.WarnContextKV(ctx, msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) ErrorContextKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.ILogger.ErrorContextKV(ctx, msg, redactArgs(kv)...)
+}
+func (l *ctxLogger) FatalContextKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.ILogger.FatalContextKV(ctx, msg, redactArgs(kv)...)
+}
+
+// LogKV 指定级别的键值对日志
+func (l *ctxLogger) LogKV(level logger.LogLevel, msg string, kv ...interface{}) {
+	l.ILogger.LogKV(level, msg, redactArgs(kv)...)
+}
+
+// LogWithFields 指定级别的字段日志
+func (l *ctxLogger) LogWithFields(level logger.LogLevel, msg string, fields map[string]interface{}) {
+	l.ILogger.LogWithFields(level, msg, redactFields(fields))
+}
+
+// Print 等兼容标准 log 包的输出
+func (l *ctxLogger) Print(a ...interface{}) {
+	l.ILogger.Print(redactArgs(a)...)
+}
+func (l *ctxLogger) Printf(format string, a ...interface{}) {
+	l.ILogger.Printf(format, redactArgs(a)...)
+}
+func (l *ctxLogger) Println(a ...interface{}) {
+	l.ILogger.Println(redactArgs(a)...)
+}
+
+// WithField 派生的日志器同样脱敏
+func (l *ctxLogger) WithField(key string, value interface{}) logger.ILogger {
+	return &ctxLogger{ILogger: l.ILogger.WithField(key, redact.Value(value))}
+}
+
+// WithFields 派生的日志器同样脱敏
+func (l *ctxLogger) WithFields(fields map[string]interface{}) logger.ILogger {
+	return &ctxLogger{ILogger: l.ILogger.WithFields(redactFields(fields))}
+}
+
+// WithError 派生的日志器同样脱敏
+func (l *ctxLogger) WithError(err error) logger.ILogger {
+	return &ctxLogger{ILogger: l.ILogger.WithError(err)}
+}
+
+// WithContext 派生的日志器同样脱敏
+func (l *ctxLogger) WithContext(ctx context.Context) logger.ILogger {
+	return &ctxLogger{ILogger: l.ILogger.WithContext(ctx)}
+}
@@ -0,0 +1,614 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\importer\importer.go
+ * @Description: 批量导入 - 上传的 CSV / XLSX 逐行转换为模型并校验，合格行按批次在事务中写库，
+ *               不合格行汇总为可下载的校验报告
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package importer 为 GORM 模型生成批量导入接口：流式解析 CSV / XLSX、按结构体标签校验、分批事务写入，
+// 被拒绝的行连同原因生成 CSV 报告供下载
+package importer
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 默认值
+const (
+	DefaultFileField     = "file"
+	DefaultBatchSize     = 500
+	DefaultMaxRows       = 100000
+	DefaultMaxFileBytes  = 32 << 20
+	DefaultMaxErrors     = 100
+	DefaultMaxReportRows = 10000
+	DefaultReportTTL     = 24 * time.Hour
+)
+
+// 导入请求的查询参数
+const (
+	QueryDryRun = "dry_run" // true 时只校验并在事务中试写，最终回滚
+	QuerySheet  = "sheet"   // XLSX 工作表名，覆盖 Config.Sheet
+)
+
+// 子路径
+const (
+	templatePath = "/template"
+	reportsPath  = "/reports/"
+)
+
+// errRollback 试运行或整体模式出现拒绝行时用于回滚事务
+var errRollback = stderrors.New("import rolled back")
+
+// 导入指标
+var (
+	importRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_import_rows_total",
+		Help: "Total number of imported rows by route and result",
+	}, []string{"route", "result"})
+	importDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_import_duration_seconds",
+		Help:    "Bulk import request duration in seconds",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"route"})
+)
+
+// Config 导入配置
+type Config struct {
+	DB            *gorm.DB                               // 数据库连接，为空时在请求时使用 global.GetDB()
+	FileField     string                                 // 上传文件的表单字段，默认 file
+	Comma         rune                                   // CSV 分隔符，默认逗号
+	Sheet         string                                 // XLSX 工作表名，默认第一个
+	BatchSize     int                                    // 每批写入行数，默认 500
+	MaxRows       int                                    // 单次导入的数据行数上限，默认 100000，超出部分不处理并在结果中标记 truncated
+	MaxFileBytes  int64                                  // 上传文件大小上限，默认 32MiB
+	AllOrNothing  bool                                   // 任一行被拒绝时整体回滚，默认只写入合格行
+	BeforeInsert  func(r *http.Request, model any) error // 每行校验通过后、写库前调用，可填充租户、创建人等字段，返回错误时拒绝该行
+	MaxErrors     int                                    // 响应中内联返回的拒绝行数，默认 100，完整明细见报告
+	MaxReportRows int                                    // 报告保留的拒绝行数，默认 10000
+	ReportTTL     time.Duration                          // 报告保留时长，默认 24h，存于 global.STORE
+}
+
+// Importer 一个模型的导入处理器：
+//
+//	POST {prefix}                 上传文件导入（multipart 字段 file）
+//	GET  {prefix}/template        下载 CSV 模板（仅表头）
+//	GET  {prefix}/reports/{id}    下载校验报告
+type Importer struct {
+	prefix    string
+	config    Config
+	modelType reflect.Type
+	policy    *middleware.MultipartPolicy
+
+	once      sync.Once
+	schemaErr error
+	schema    *schema.Schema
+	columns   []*column
+}
+
+// Result 导入结果
+type Result struct {
+	Total          int        `json:"total"`                     // 处理的数据行数（不含表头与空行）
+	Inserted       int        `json:"inserted"`                  // 写入行数，试运行时为可写入行数
+	Rejected       int        `json:"rejected"`                  // 拒绝行数
+	DryRun         bool       `json:"dry_run,omitempty"`         // 试运行，未提交
+	RolledBack     bool       `json:"rolled_back,omitempty"`     // AllOrNothing 模式下因存在拒绝行而整体回滚
+	Truncated      bool       `json:"truncated,omitempty"`       // 超出 MaxRows，之后的行未处理
+	IgnoredColumns []string   `json:"ignored_columns,omitempty"` // 未匹配到字段的表头
+	Errors         []RowError `json:"errors,omitempty"`          // 前 MaxErrors 条拒绝明细
+	ReportURL      string     `json:"report_url,omitempty"`      // 校验报告下载地址，存在拒绝行时返回
+}
+
+// RowError 被拒绝的行
+type RowError struct {
+	Row    int      `json:"row"` // 文件中的行号，与 Excel 中显示的一致
+	Errors []string `json:"errors"`
+}
+
+// New 创建导入处理器，model 为模型指针（如 &User{}）
+func New(prefix string, model any, cfg Config) (*Importer, error) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	t := reflect.TypeOf(model)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "import %s model must be a pointer to struct, got %T", prefix, model)
+	}
+	if cfg.FileField == "" {
+		cfg.FileField = DefaultFileField
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = DefaultMaxRows
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if cfg.MaxErrors <= 0 {
+		cfg.MaxErrors = DefaultMaxErrors
+	}
+	if cfg.MaxReportRows <= 0 {
+		cfg.MaxReportRows = DefaultMaxReportRows
+	}
+	if cfg.ReportTTL <= 0 {
+		cfg.ReportTTL = DefaultReportTTL
+	}
+	policy, err := middleware.NewMultipartPolicy(middleware.MultipartRule{
+		MaxFiles:      1,
+		MaxFileBytes:  cfg.MaxFileBytes,
+		MaxTotalBytes: cfg.MaxFileBytes + 1<<20,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Importer{prefix: prefix, config: cfg, modelType: t.Elem(), policy: policy}, nil
+}
+
+// Prefix 导入路径前缀
+func (im *Importer) Prefix() string {
+	return im.prefix
+}
+
+// ServeHTTP 按方法与路径分发
+func (im *Importer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	response.Handle(im.handle, nil).ServeHTTP(w, req)
+}
+
+// handle 分发请求
+func (im *Importer) handle(c *response.Context) error {
+	sub := strings.TrimPrefix(c.Request.URL.Path, im.prefix)
+	method := c.Request.Method
+	switch {
+	case (sub == "" || sub == "/") && method == http.MethodPost:
+		return im.importFile(c)
+	case sub == templatePath && (method == http.MethodGet || method == http.MethodHead):
+		return im.template(c)
+	case strings.HasPrefix(sub, reportsPath) && (method == http.MethodGet || method == http.MethodHead):
+		return im.download(c, strings.TrimPrefix(sub, reportsPath))
+	case sub == "" || sub == "/" || sub == templatePath || strings.HasPrefix(sub, reportsPath):
+		return errors.NewErrorf(errors.ErrCodeMethodNotAllowed, "method %s is not allowed", method)
+	}
+	return errors.NewError(errors.ErrCodeNotFound, "resource not found")
+}
+
+// db 请求使用的连接，首次使用时解析模型结构
+func (im *Importer) db(req *http.Request) (*gorm.DB, *errors.AppError) {
+	db := im.config.DB
+	if db == nil {
+		db = global.GetDB()
+	}
+	if db == nil {
+		return nil, errors.NewError(errors.ErrCodeServiceUnavailable, "database is not initialized")
+	}
+	im.once.Do(func() {
+		stmt := &gorm.Statement{DB: db}
+		if im.schemaErr = stmt.Parse(reflect.New(im.modelType).Interface()); im.schemaErr == nil {
+			im.schema = stmt.Schema
+			im.columns = columnsOf(stmt.Schema)
+		}
+	})
+	if im.schemaErr != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "import %s: %v", im.prefix, im.schemaErr)
+	}
+	return db.WithContext(req.Context()), nil
+}
+
+// template 下载只含表头的 CSV 模板
+func (im *Importer) template(c *response.Context) error {
+	if _, appErr := im.db(c.Request); appErr != nil {
+		return appErr
+	}
+	headers := make([]string, 0, len(im.columns))
+	for _, col := range im.columns {
+		headers = append(headers, col.name)
+	}
+	return writeCSV(c.Writer, im.schema.Table+"-template.csv", [][]string{headers})
+}
+
+// importFile 解析上传文件并导入
+func (im *Importer) importFile(c *response.Context) error {
+	start := time.Now()
+	defer func() { importDuration.WithLabelValues(im.prefix).Observe(time.Since(start).Seconds()) }()
+
+	db, appErr := im.db(c.Request)
+	if appErr != nil {
+		return appErr
+	}
+	files, appErr := im.policy.Parse(c.Writer, c.Request)
+	if appErr != nil {
+		return appErr
+	}
+	defer func() { _ = c.Request.MultipartForm.RemoveAll() }()
+
+	var upload *middleware.UploadedFile
+	for _, f := range files {
+		if f.Field == im.config.FileField {
+			upload = f
+		}
+	}
+	if upload == nil {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "form field %q with the file to import is required", im.config.FileField)
+	}
+	file, err := upload.Open()
+	if err != nil {
+		return errors.NewErrorf(errors.ErrCodeBadRequest, "open uploaded file: %v", err)
+	}
+	defer file.Close()
+
+	rows, format, appErr := im.open(c.Request, upload, file)
+	if appErr != nil {
+		return appErr
+	}
+	if closer, ok := rows.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Request.URL.Query().Get(QueryDryRun))
+	s := &session{im: im, req: c.Request, rows: rows, result: &Result{DryRun: dryRun}}
+	if appErr := s.readHeader(); appErr != nil {
+		return appErr
+	}
+	if im.config.AllOrNothing || dryRun {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			s.tx, s.atomic = tx, true
+			if err := s.process(); err != nil {
+				return err
+			}
+			if dryRun || s.result.Rejected > 0 {
+				return errRollback
+			}
+			return nil
+		})
+		if stderrors.Is(err, errRollback) {
+			err = nil
+			s.result.RolledBack = !dryRun
+		}
+	} else {
+		s.tx = db
+		err = s.process()
+	}
+	if err != nil {
+		return toAppError(err)
+	}
+
+	result := s.result
+	if result.RolledBack {
+		result.Inserted = 0
+	}
+	if !dryRun && !result.RolledBack {
+		importRows.WithLabelValues(im.prefix, "inserted").Add(float64(result.Inserted))
+	}
+	importRows.WithLabelValues(im.prefix, "rejected").Add(float64(result.Rejected))
+	if result.Rejected > 0 {
+		id, err := im.saveReport(c.Context(), s.report(), upload.Filename)
+		if err != nil {
+			global.LOGGER.WarnKV("导入校验报告保存失败", "route", im.prefix, "error", err)
+		} else {
+			result.ReportURL = im.prefix + reportsPath + id
+		}
+	}
+	global.LOGGER.InfoKV("批量导入完成", "route", im.prefix, "format", format, "file", upload.Filename,
+		"total", result.Total, "inserted", result.Inserted, "rejected", result.Rejected, "dry_run", dryRun)
+	return c.Respond(result)
+}
+
+// open 按扩展名与嗅探类型选择读取器
+func (im *Importer) open(req *http.Request, upload *middleware.UploadedFile, file io.ReaderAt) (rowReader, string, *errors.AppError) {
+	format := strings.TrimPrefix(upload.Ext(), ".")
+	if format != FormatCSV && format != FormatXLSX {
+		switch upload.DetectedType {
+		case "application/zip":
+			format = FormatXLSX
+		case "text/plain", "text/csv":
+			format = FormatCSV
+		default:
+			return nil, "", errors.NewErrorf(errors.ErrCodeInvalidContentType, "file %q is not a CSV or XLSX file", upload.Filename)
+		}
+	}
+
+	if format == FormatXLSX {
+		sheet := im.config.Sheet
+		if s := req.URL.Query().Get(QuerySheet); s != "" {
+			sheet = s
+		}
+		rows, err := newXLSXRows(file, upload.Size, sheet)
+		if err != nil {
+			return nil, "", errors.NewErrorf(errors.ErrCodeBadRequest, "%v", err)
+		}
+		return rows, format, nil
+	}
+	rows, err := newCSVRows(io.NewSectionReader(file, 0, upload.Size), im.config.Comma)
+	if err != nil {
+		return nil, "", errors.NewErrorf(errors.ErrCodeBadRequest, "%v", err)
+	}
+	return rows, format, nil
+}
+
+// pending 已通过校验、等待写库的行
+type pending struct {
+	line  int
+	cells []string
+	model any
+}
+
+// rejected 被拒绝的行
+type rejected struct {
+	line   int
+	cells  []string
+	errors []string
+}
+
+// session 单次导入的状态
+type session struct {
+	im     *Importer
+	req    *http.Request
+	rows   rowReader
+	tx     *gorm.DB
+	atomic bool // 整个导入在同一事务内，批次失败时回滚到保存点
+
+	headers  []string
+	mapping  []*column // 与 headers 一一对应，未匹配的列为 nil
+	batch    []pending
+	rejects  []rejected
+	result   *Result
+	dbLogged bool
+}
+
+// readHeader 读取第一个非空行作为表头，校验必填列是否齐全
+func (s *session) readHeader() *errors.AppError {
+	for {
+		_, cells, err := s.rows.Next()
+		if err == io.EOF {
+			return errors.NewError(errors.ErrCodeInvalidParameter, "file is empty")
+		}
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeBadRequest, "read header: %v", err)
+		}
+		if !blank(cells) {
+			s.headers = cells
+			break
+		}
+	}
+
+	seen := make(map[*column]string, len(s.headers))
+	matched := 0
+	for _, h := range s.headers {
+		var hit *column
+		for _, col := range s.im.columns {
+			if col.matches(h) {
+				hit = col
+				break
+			}
+		}
+		if hit == nil {
+			if strings.TrimSpace(h) != "" {
+				s.result.IgnoredColumns = append(s.result.IgnoredColumns, h)
+			}
+		} else if prev, dup := seen[hit]; dup {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "columns %q and %q both map to %s", prev, h, hit.name)
+		} else {
+			seen[hit] = h
+			matched++
+		}
+		s.mapping = append(s.mapping, hit)
+	}
+	if matched == 0 {
+		return errors.NewError(errors.ErrCodeInvalidParameter, "no column in the header matches the import template")
+	}
+	var missing []string
+	for _, col := range s.im.columns {
+		if _, ok := seen[col]; !ok && col.required {
+			missing = append(missing, col.name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "required columns are missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// process 逐行转换、校验并分批写入
+func (s *session) process() error {
+	ctx := s.req.Context()
+	for {
+		line, cells, err := s.rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeBadRequest, "read file: %v", err)
+		}
+		if blank(cells) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return errors.NewErrorf(errors.ErrCodeGatewayTimeout, "import aborted: %v", ctx.Err())
+		}
+		if s.result.Total >= s.im.config.MaxRows {
+			s.result.Truncated = true
+			break
+		}
+		s.result.Total++
+
+		model, problems := s.bind(ctx, cells)
+		if len(problems) > 0 {
+			s.reject(line, cells, problems...)
+			continue
+		}
+		s.batch = append(s.batch, pending{line: line, cells: cells, model: model})
+		if len(s.batch) >= s.im.config.BatchSize {
+			s.flush()
+		}
+	}
+	s.flush()
+	return nil
+}
+
+// bind 单元格写入新模型并校验，返回问题列表
+func (s *session) bind(ctx context.Context, cells []string) (any, []string) {
+	model := reflect.New(s.im.modelType)
+	var problems []string
+	for i, col := range s.mapping {
+		if col == nil || i >= len(cells) {
+			continue
+		}
+		if err := setValue(col.field.ReflectValueOf(ctx, model.Elem()), cells[i]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.headers[i], err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	if err := middleware.ValidateStruct(model.Interface()); err != nil {
+		return nil, []string{message(err)}
+	}
+	if hook := s.im.config.BeforeInsert; hook != nil {
+		if err := hook(s.req, model.Interface()); err != nil {
+			return nil, []string{message(err)}
+		}
+	}
+	return model.Interface(), nil
+}
+
+// flush 写入当前批次；批次失败时逐行重试，定位并拒绝出错的行
+func (s *session) flush() {
+	if len(s.batch) == 0 {
+		return
+	}
+	batch := s.batch
+	s.batch = s.batch[:0:0]
+
+	models := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(s.im.modelType)), 0, len(batch))
+	for _, p := range batch {
+		models = reflect.Append(models, reflect.ValueOf(p.model))
+	}
+	if err := s.exec(func(tx *gorm.DB) error { return tx.Create(models.Interface()).Error }); err == nil {
+		s.result.Inserted += len(batch)
+		return
+	}
+	for _, p := range batch {
+		if err := s.exec(func(tx *gorm.DB) error { return tx.Create(p.model).Error }); err != nil {
+			s.reject(p.line, p.cells, s.dbMessage(err))
+			continue
+		}
+		s.result.Inserted++
+	}
+}
+
+// exec 在独立事务（默认）或保存点（整体事务模式）内执行写入
+func (s *session) exec(fn func(tx *gorm.DB) error) error {
+	if !s.atomic {
+		return s.tx.Transaction(fn)
+	}
+	const savepoint = "import_batch"
+	if err := s.tx.SavePoint(savepoint).Error; err != nil {
+		return err
+	}
+	if err := fn(s.tx); err != nil {
+		_ = s.tx.RollbackTo(savepoint).Error
+		return err
+	}
+	return nil
+}
+
+// reject 记录拒绝行，超出报告上限的只计数
+func (s *session) reject(line int, cells []string, problems ...string) {
+	s.result.Rejected++
+	if len(s.result.Errors) < s.im.config.MaxErrors {
+		s.result.Errors = append(s.result.Errors, RowError{Row: line, Errors: problems})
+	}
+	if len(s.rejects) < s.im.config.MaxReportRows {
+		s.rejects = append(s.rejects, rejected{line: line, cells: cells, errors: problems})
+	}
+}
+
+// dbMessage 数据库错误对应的拒绝原因，首个错误写日志便于排查
+func (s *session) dbMessage(err error) string {
+	if !s.dbLogged {
+		s.dbLogged = true
+		global.LOGGER.WarnKV("导入行写库失败", "route", s.im.prefix, "error", err)
+	}
+	if stderrors.Is(err, gorm.ErrDuplicatedKey) {
+		return "duplicate record"
+	}
+	return "database error: " + err.Error()
+}
+
+// report 校验报告内容：行号 + 原始列 + 原因
+func (s *session) report() [][]string {
+	out := make([][]string, 0, len(s.rejects)+1)
+	out = append(out, append(append([]string{"row"}, s.headers...), "errors"))
+	for _, rej := range s.rejects {
+		record := make([]string, 0, len(s.headers)+2)
+		record = append(record, strconv.Itoa(rej.line))
+		for i := range s.headers {
+			cell := ""
+			if i < len(rej.cells) {
+				cell = rej.cells[i]
+			}
+			record = append(record, cell)
+		}
+		out = append(out, append(record, strings.Join(rej.errors, "; ")))
+	}
+	return out
+}
+
+// blank 整行为空
+func blank(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// message 错误文本，AppError 只取消息部分
+func message(err error) string {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) && appErr.GetMessage() != "" {
+		return appErr.GetMessage()
+	}
+	return err.Error()
+}
+
+// toAppError 非 AppError 按数据库错误处理
+func toAppError(err error) *errors.AppError {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+	return errors.NewErrorf(errors.ErrCodeInternalServerError, "database error: %v", err)
+}
+
+// reportFilename 报告下载文件名
+func reportFilename(upload string) string {
+	base := strings.TrimSuffix(path.Base(upload), path.Ext(upload))
+	if base == "" || base == "." || base == "/" {
+		base = "import"
+	}
+	return base + "-rejected.csv"
+}
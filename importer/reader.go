@@ -0,0 +1,330 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\importer\reader.go
+ * @Description: 上传文件逐行读取 - CSV 基于 encoding/csv，XLSX 直接解析 OOXML（共享字符串 + 工作表流式读取），
+ *               不整体载入工作表，行号与 Excel 中显示的一致
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 支持的文件格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// maxSharedStrings 共享字符串表解压后的大小上限，防止压缩炸弹
+const maxSharedStrings = 64 << 20
+
+// utf8BOM Excel 导出 CSV 时常带的字节序标记
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// rowReader 逐行读取单元格，读完返回 io.EOF；line 为文件中的行号（从 1 开始）
+type rowReader interface {
+	Next() (line int, cells []string, err error)
+}
+
+// csvRows CSV 行读取器
+type csvRows struct {
+	r *csv.Reader
+}
+
+// newCSVRows 创建 CSV 读取器，去掉开头的 BOM，允许各行列数不同
+func newCSVRows(src io.Reader, comma rune) (*csvRows, error) {
+	br := bufio.NewReader(src)
+	if head, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+	r := csv.NewReader(br)
+	r.FieldsPerRecord = -1
+	if comma != 0 {
+		r.Comma = comma
+	}
+	return &csvRows{r: r}, nil
+}
+
+// Next 读取下一行
+func (c *csvRows) Next() (int, []string, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return 0, nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+	return line, record, nil
+}
+
+// xlsxRows XLSX 工作表行读取器
+type xlsxRows struct {
+	sheet   io.ReadCloser
+	decoder *xml.Decoder
+	strings []string
+	line    int
+}
+
+// newXLSXRows 打开工作簿中名为 sheet 的工作表，sheet 为空时取第一个
+func newXLSXRows(src io.ReaderAt, size int64, sheet string) (*xlsxRows, error) {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid xlsx file: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	target, err := sheetPath(files, sheet)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := readSharedStrings(files["xl/sharedStrings.xml"])
+	if err != nil {
+		return nil, err
+	}
+	f, ok := files[target]
+	if !ok {
+		return nil, fmt.Errorf("xlsx worksheet %s not found", target)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRows{sheet: rc, decoder: xml.NewDecoder(rc), strings: shared}, nil
+}
+
+// sheetPath 通过 workbook.xml 与其关系文件找到工作表在包内的路径
+func sheetPath(files map[string]*zip.File, name string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXML(files["xl/workbook.xml"], &workbook); err != nil {
+		return "", fmt.Errorf("read xlsx workbook: %w", err)
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXML(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return "", fmt.Errorf("read xlsx relationships: %w", err)
+	}
+
+	for _, s := range workbook.Sheets {
+		if name != "" && s.Name != name {
+			continue
+		}
+		for _, rel := range rels.Items {
+			if rel.ID != s.RID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+		return "", fmt.Errorf("xlsx sheet %q has no worksheet part", s.Name)
+	}
+	if name != "" {
+		return "", fmt.Errorf("xlsx sheet %q not found", name)
+	}
+	return "", stderrors.New("xlsx workbook has no sheets")
+}
+
+// decodeXML 解码包内的 XML 文件
+func decodeXML(f *zip.File, v any) error {
+	if f == nil {
+		return stderrors.New("part is missing")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// readSharedStrings 读取共享字符串表，富文本条目按顺序拼接各段文本（忽略注音）
+func readSharedStrings(f *zip.File) ([]string, error) {
+	if f == nil {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		out     []string
+		current strings.Builder
+		inText  bool
+		skip    int // 处于 <rPh> 注音段内
+	)
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxSharedStrings))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read xlsx shared strings: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "rPh":
+				skip++
+			case "t":
+				inText = skip == 0
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				out = append(out, current.String())
+			case "rPh":
+				skip--
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// Next 读取下一个 <row>，按单元格引用把值放到对应列，中间缺失的单元格补空串
+func (x *xlsxRows) Next() (int, []string, error) {
+	var (
+		cells   []string
+		inRow   bool
+		col     int
+		kind    string
+		value   strings.Builder
+		inValue bool
+	)
+	for {
+		tok, err := x.decoder.Token()
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("read xlsx worksheet: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				inRow, cells, col = true, cells[:0], 0
+				x.line++
+				if r := attr(t, "r"); r != "" {
+					if n, err := strconv.Atoi(r); err == nil {
+						x.line = n
+					}
+				}
+			case "c":
+				kind = attr(t, "t")
+				value.Reset()
+				if ref := attr(t, "r"); ref != "" {
+					if idx, ok := columnIndex(ref); ok {
+						col = idx
+					}
+				}
+			case "v", "t":
+				inValue = inRow
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text, err := x.cellText(kind, value.String())
+				if err != nil {
+					return 0, nil, fmt.Errorf("row %d: %w", x.line, err)
+				}
+				for len(cells) < col {
+					cells = append(cells, "")
+				}
+				cells = append(cells, text)
+				col++
+			case "row":
+				return x.line, cells, nil
+			case "sheetData":
+				return 0, nil, io.EOF
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+// Close 关闭工作表
+func (x *xlsxRows) Close() error {
+	return x.sheet.Close()
+}
+
+// cellText 按单元格类型取文本：s 为共享字符串索引，b 为布尔，其余（数字、公式字符串、内联字符串）取原文
+func (x *xlsxRows) cellText(kind, raw string) (string, error) {
+	switch kind {
+	case "s":
+		idx, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || idx < 0 || idx >= len(x.strings) {
+			return "", fmt.Errorf("invalid shared string index %q", raw)
+		}
+		return x.strings[idx], nil
+	case "b":
+		return strconv.FormatBool(strings.TrimSpace(raw) == "1"), nil
+	}
+	return raw, nil
+}
+
+// attr 读取属性
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// columnIndex 单元格引用（如 AB12）的列序号，从 0 开始
+func columnIndex(ref string) (int, bool) {
+	idx := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		idx = idx*26 + int(ch-'A'+1)
+		n++
+	}
+	return idx - 1, n > 0
+}
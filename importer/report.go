@@ -0,0 +1,120 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\importer\report.go
+ * @Description: 校验报告 - 拒绝行连同原因写成 CSV 存入 global.STORE，按 ID 下载，
+ *               有上传者的报告只对同一主体可见
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+)
+
+// reportKeyPrefix 报告存储键前缀
+const reportKeyPrefix = "gateway:import:report:"
+
+// reportRecord 报告存储格式
+type reportRecord struct {
+	Route    string `json:"route"`
+	Owner    string `json:"owner,omitempty"`
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+}
+
+// saveReport 保存报告，返回报告 ID
+func (im *Importer) saveReport(ctx context.Context, records [][]string, upload string) (string, error) {
+	if global.STORE == nil {
+		return "", stderrors.New("store is not initialized")
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(records); err != nil {
+		return "", err
+	}
+	record := reportRecord{Route: im.prefix, Filename: reportFilename(upload), Content: buf.String()}
+	if p, ok := middleware.PrincipalFromContext(ctx); ok && !p.IsAnonymous() {
+		record.Owner = p.Subject
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	id := newReportID()
+	return id, global.STORE.Set(ctx, reportKeyPrefix+id, string(data), im.config.ReportTTL)
+}
+
+// download 下载校验报告
+func (im *Importer) download(c *response.Context, id string) error {
+	if global.STORE == nil || id == "" || strings.Contains(id, "/") {
+		return errors.NewError(errors.ErrCodeNotFound, "report not found")
+	}
+	raw, err := global.STORE.Get(c.Context(), reportKeyPrefix+id)
+	if err != nil {
+		return errors.NewError(errors.ErrCodeNotFound, "report not found")
+	}
+	var record reportRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil || record.Route != im.prefix || !reportVisible(c.Context(), record.Owner) {
+		return errors.NewError(errors.ErrCodeNotFound, "report not found")
+	}
+	writeRaw(c.Writer, record.Filename, []byte(record.Content))
+	return nil
+}
+
+// reportVisible 有上传者的报告只对同一主体可见
+func reportVisible(ctx context.Context, owner string) bool {
+	if owner == "" {
+		return true
+	}
+	p, ok := middleware.PrincipalFromContext(ctx)
+	return ok && p != nil && p.Subject == owner
+}
+
+// writeCSV 以附件形式输出 CSV
+func writeCSV(w http.ResponseWriter, filename string, records [][]string) error {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err := cw.WriteAll(records); err != nil {
+		return errors.NewErrorf(errors.ErrCodeInternalServerError, "encode csv: %v", err)
+	}
+	writeRaw(w, filename, buf.Bytes())
+	return nil
+}
+
+// writeRaw 输出 CSV 附件，带 BOM 以便 Excel 正确识别 UTF-8
+func writeRaw(w http.ResponseWriter, filename string, content []byte) {
+	header := w.Header()
+	header.Set(constants.HeaderContentType, httpx.ContentTypeTextCSVCharacterUTF8)
+	header.Set(constants.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(utf8BOM)
+	_, _ = w.Write(content)
+}
+
+// newReportID 生成 128 位随机报告 ID
+func newReportID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
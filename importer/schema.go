@@ -0,0 +1,222 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\importer\schema.go
+ * @Description: 表头与模型字段映射、单元格文本到字段类型的转换（兼容 Excel 的数字与日期序列号）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package importer
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TagName 列名标签，如 `import:"手机号"`，"-" 表示不参与导入
+const TagName = "import"
+
+// excelEpoch Excel 日期序列号的起点（1900 日期系统，已计入 1900-02-29 的历史误差）
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+
+// timeLayouts 日期时间列接受的格式
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// column 可导入的字段
+type column struct {
+	name     string // 模板中的列名
+	aliases  []string
+	field    *schema.Field
+	required bool // validate 标签含 required，表头缺少该列时直接拒绝整个文件
+}
+
+// columnsOf 模型中可导入的字段：跳过自增主键、自动维护的时间列、软删除列、只读字段与 import:"-"
+func columnsOf(s *schema.Schema) []*column {
+	var cols []*column
+	for _, f := range s.Fields {
+		tag := f.Tag.Get(TagName)
+		switch {
+		case tag == "-", f.DBName == "", !f.Creatable, !convertible(f.FieldType),
+			f.PrimaryKey && f.AutoIncrement, f.AutoCreateTime > 0, f.AutoUpdateTime > 0,
+			f.FieldType == reflect.TypeOf(gorm.DeletedAt{}):
+			continue
+		}
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			jsonName = ""
+		}
+		name := tag
+		if name == "" {
+			name = jsonName
+		}
+		if name == "" {
+			name = f.DBName
+		}
+		col := &column{name: name, aliases: []string{name, jsonName, f.Name, f.DBName}, field: f}
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				col.required = true
+			}
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// matches 表头是否对应该字段：列名、JSON 名或字段名，忽略大小写、下划线、连字符与空格
+func (c *column) matches(header string) bool {
+	h := normalizeHeader(header)
+	for _, alias := range c.aliases {
+		if alias != "" && normalizeHeader(alias) == h {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHeader 表头归一化
+func normalizeHeader(s string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+// convertible 是否支持从单元格文本转换
+func convertible(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(scannerType) || t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return convertible(t.Elem())
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setValue 把单元格文本写入字段，空文本保留零值
+func setValue(v reflect.Value, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), text); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := parseTime(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+	if s, ok := v.Addr().Interface().(sql.Scanner); ok {
+		return s.Scan(text)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := parseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(integerText(text), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", text)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(integerText(text), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", text)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// integerText Excel 把整数存成 12.0 或 1.2E+3 时还原为整数文本，带小数部分的保持原样以便报错
+func integerText(text string) string {
+	if !strings.ContainsAny(text, ".eE") {
+		return text
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) >= 1<<53 {
+		return text
+	}
+	return strconv.FormatFloat(f, 'f', 0, 64)
+}
+
+// parseBool 接受 true/false、1/0、yes/no、是/否
+func parseBool(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "true", "1", "yes", "y", "是":
+		return true, nil
+	case "false", "0", "no", "n", "否":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", text)
+}
+
+// parseTime 按常见格式解析（无时区的按本地时区），纯数字视为 Excel 日期序列号
+func parseTime(text string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(text, 64); err == nil && serial > 0 && serial < 2958466 {
+		days := math.Floor(serial)
+		seconds := math.Round((serial - days) * 86400)
+		return excelEpoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", text)
+}
@@ -0,0 +1,62 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\leader.go
+ * @Description: Gateway 后台任务选主 - 多副本部署时保证后台任务只在一个节点运行
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/leader"
+)
+
+// RunIfLeader 参与指定后台任务的选主，当前节点成为 leader 后运行 task
+// 失去 leader 身份或网关停止时 task 的 ctx 会被取消
+// 使用示例:
+//
+//	gw.RunIfLeader("outbox-relay", func(ctx context.Context) error {
+//	    ticker := time.NewTicker(time.Second)
+//	    defer ticker.Stop()
+//	    for {
+//	        select {
+//	        case <-ctx.Done():
+//	            return nil
+//	        case <-ticker.C:
+//	            relayOutbox(ctx)
+//	        }
+//	    }
+//	})
+func (g *Gateway) RunIfLeader(name string, task leader.TaskFunc) error {
+	return g.getElector().RunIfLeader(g.Context(), name, task)
+}
+
+// OnLeadershipChange 注册 leader 身份变更回调
+func (g *Gateway) OnLeadershipChange(cb leader.ChangeCallback) {
+	g.getElector().OnLeadershipChange(cb)
+}
+
+// IsLeader 当前节点是否为指定后台任务的 leader
+func (g *Gateway) IsLeader(name string) bool {
+	return g.getElector().IsLeader(name)
+}
+
+// GetElector 获取后台任务选主器
+func (g *Gateway) GetElector() *leader.Elector {
+	return g.getElector()
+}
+
+// getElector 获取选主器，未通过构建器创建时使用默认配置延迟创建；并发调用只创建一个
+func (g *Gateway) getElector() *leader.Elector {
+	g.electorOnce.Do(func() {
+		if g.elector == nil {
+			g.elector = leader.NewElector(global.STORE, nil)
+		}
+	})
+	return g.elector
+}
@@ -0,0 +1,311 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\leader\elector.go
+ * @Description: 多副本后台任务选主 - 基于状态存储租约，保证同名任务同一时刻只在一个节点运行
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package leader 提供基于租约的选主能力，用于 Swagger 刷新、Outbox 转发、清理任务等
+// 只需在单个副本上运行的后台任务。租约存放在 store.Store 中：配置 Redis 时跨副本生效，
+// 使用内嵌存储时当前节点始终为 leader
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+)
+
+// Config 选主配置
+type Config struct {
+	KeyPrefix     string        // 租约 key 前缀
+	NodeID        string        // 当前节点标识，为空时使用 hostname-pid
+	LeaseTTL      time.Duration // 租约有效期
+	RenewInterval time.Duration // leader 续期间隔，需小于 LeaseTTL
+	RetryInterval time.Duration // follower 抢占间隔，任务失败后的重试间隔
+}
+
+// DefaultConfig 默认选主配置
+func DefaultConfig() *Config {
+	return &Config{
+		KeyPrefix:     "gateway:leader:",
+		LeaseTTL:      15 * time.Second,
+		RenewInterval: 5 * time.Second,
+		RetryInterval: 5 * time.Second,
+	}
+}
+
+// ChangeCallback leader 身份变更回调
+type ChangeCallback func(name string, isLeader bool)
+
+// TaskFunc 需要在 leader 上运行的任务
+// ctx 在失去 leader 身份或选主器停止时取消；返回 nil 表示任务结束并释放租约，返回错误会在重试间隔后重新执行
+type TaskFunc func(ctx context.Context) error
+
+// Elector 选主器
+type Elector struct {
+	store  store.Store
+	config Config
+
+	mu        sync.RWMutex
+	campaigns map[string]*campaign
+	callbacks []ChangeCallback
+	stopped   bool
+	wg        sync.WaitGroup
+}
+
+// campaign 单个任务的选主状态
+type campaign struct {
+	name    string
+	key     string
+	task    TaskFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	leading bool
+}
+
+// NewElector 创建选主器
+func NewElector(s store.Store, cfg *Config) *Elector {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	config := *cfg
+	defaults := DefaultConfig()
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaults.KeyPrefix
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = defaults.LeaseTTL
+	}
+	if config.RenewInterval <= 0 || config.RenewInterval >= config.LeaseTTL {
+		config.RenewInterval = config.LeaseTTL / 3
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaults.RetryInterval
+	}
+	if config.NodeID == "" {
+		config.NodeID = defaultNodeID()
+	}
+
+	return &Elector{
+		store:     s,
+		config:    config,
+		campaigns: make(map[string]*campaign),
+	}
+}
+
+// NodeID 返回当前节点标识
+func (e *Elector) NodeID() string {
+	return e.config.NodeID
+}
+
+// OnLeadershipChange 注册 leader 身份变更回调
+func (e *Elector) OnLeadershipChange(cb ChangeCallback) {
+	if cb == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.callbacks = append(e.callbacks, cb)
+}
+
+// IsLeader 当前节点是否为指定任务的 leader
+func (e *Elector) IsLeader(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	c, ok := e.campaigns[name]
+	return ok && c.leading
+}
+
+// RunIfLeader 参与指定任务的选主，成为 leader 后运行 task
+// 同名任务在当前节点只能注册一次；方法立即返回，选主与任务在后台执行
+func (e *Elector) RunIfLeader(ctx context.Context, name string, task TaskFunc) error {
+	if e.store == nil {
+		return errors.New("leader: store not available")
+	}
+	if name == "" || task == nil {
+		return errors.New("leader: name and task are required")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return errors.New("leader: elector stopped")
+	}
+	if _, exists := e.campaigns[name]; exists {
+		return fmt.Errorf("leader: task %q already registered", name)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	campaignCtx, cancel := context.WithCancel(ctx)
+	c := &campaign{
+		name:   name,
+		key:    e.config.KeyPrefix + name,
+		task:   task,
+		ctx:    campaignCtx,
+		cancel: cancel,
+	}
+	e.campaigns[name] = c
+
+	e.wg.Add(1)
+	go e.runCampaign(c)
+	return nil
+}
+
+// Stop 停止所有选主并释放已持有的租约
+func (e *Elector) Stop() {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	e.stopped = true
+	for _, c := range e.campaigns {
+		c.cancel()
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+}
+
+// runCampaign 选主循环：follower 定期抢占，leader 定期续期，续期失败即让出身份
+func (e *Elector) runCampaign(c *campaign) {
+	defer e.wg.Done()
+
+	var (
+		taskCancel context.CancelFunc
+		taskDone   chan struct{}
+	)
+	stepDown := func() {
+		if taskCancel != nil {
+			taskCancel()
+			<-taskDone
+			taskCancel, taskDone = nil, nil
+		}
+		e.setLeading(c, false)
+	}
+
+	for {
+		acquired, err := acquireOrRenew(c.ctx, e.store, c.key, e.config.NodeID, e.config.LeaseTTL)
+		if err != nil && c.ctx.Err() == nil {
+			global.LOGGER.WarnKV("选主租约操作失败", "task", c.name, "node", e.config.NodeID, "error", err)
+		}
+
+		switch {
+		case acquired && taskCancel == nil:
+			var taskCtx context.Context
+			taskCtx, taskCancel = context.WithCancel(c.ctx)
+			taskDone = make(chan struct{})
+			e.setLeading(c, true)
+			go e.runTask(c, taskCtx, taskDone)
+		case !acquired && taskCancel != nil:
+			stepDown()
+		}
+
+		interval := e.config.RetryInterval
+		if taskCancel != nil {
+			interval = e.config.RenewInterval
+		}
+
+		select {
+		case <-c.ctx.Done():
+			wasLeading := taskCancel != nil
+			stepDown()
+			e.finishCampaign(c, wasLeading)
+			return
+		case <-taskDone:
+			// 任务正常结束：结束本次选主并释放租约
+			taskCancel, taskDone = nil, nil
+			e.setLeading(c, false)
+			e.finishCampaign(c, true)
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// finishCampaign 结束选主，持有租约时主动释放，便于其他节点尽快接管
+func (e *Elector) finishCampaign(c *campaign, held bool) {
+	c.cancel()
+	if held {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if err := release(releaseCtx, e.store, c.key, e.config.NodeID); err != nil {
+			global.LOGGER.WarnKV("释放选主租约失败", "task", c.name, "node", e.config.NodeID, "error", err)
+		}
+		cancel()
+	}
+	e.removeCampaign(c)
+}
+
+// runTask 在 leader 身份下运行任务，失败后按重试间隔重新执行
+func (e *Elector) runTask(c *campaign, ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		err := c.task(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			global.LOGGER.InfoKV("leader 任务已完成", "task", c.name, "node", e.config.NodeID)
+			return
+		}
+		global.LOGGER.WarnKV("leader 任务执行失败，稍后重试", "task", c.name, "node", e.config.NodeID, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.config.RetryInterval):
+		}
+	}
+}
+
+// setLeading 更新 leader 身份并触发回调
+func (e *Elector) setLeading(c *campaign, leading bool) {
+	e.mu.Lock()
+	if c.leading == leading {
+		e.mu.Unlock()
+		return
+	}
+	c.leading = leading
+	callbacks := append([]ChangeCallback(nil), e.callbacks...)
+	e.mu.Unlock()
+
+	if leading {
+		global.LOGGER.InfoKV("👑 成为 leader", "task", c.name, "node", e.config.NodeID)
+	} else {
+		global.LOGGER.InfoKV("失去 leader 身份", "task", c.name, "node", e.config.NodeID)
+	}
+	for _, cb := range callbacks {
+		cb(c.name, leading)
+	}
+}
+
+// removeCampaign 移除已结束的选主，允许同名任务重新注册
+func (e *Elector) removeCampaign(c *campaign) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.campaigns[c.name] == c {
+		delete(e.campaigns, c.name)
+	}
+}
+
+// defaultNodeID 生成默认节点标识
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
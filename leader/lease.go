@@ -0,0 +1,84 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\leader\lease.go
+ * @Description: 基于状态存储的租约 - SETNX 抢占、持有者校验后续期与释放
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/store"
+)
+
+// renewScript 持有者校验后续期，返回 1 表示续期成功
+var renewScript = store.NewScript(`
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		return 1
+	end
+	return 0
+`, func(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	owner, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	if owner != store.ArgString(args[0]) {
+		return int64(0), nil
+	}
+	ttl, err := store.ArgInt64(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Expire(ctx, keys[0], time.Duration(ttl)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+})
+
+// releaseScript 持有者校验后释放，避免误删其他节点的租约
+var releaseScript = store.NewScript(`
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`, func(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	owner, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	if owner != store.ArgString(args[0]) {
+		return int64(0), nil
+	}
+	if err := cmd.Del(ctx, keys[0]); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+})
+
+// acquireOrRenew 抢占租约，已持有时续期
+func acquireOrRenew(ctx context.Context, s store.Store, key, nodeID string, ttl time.Duration) (bool, error) {
+	ok, err := s.SetNX(ctx, key, nodeID, ttl)
+	if err != nil || ok {
+		return ok, err
+	}
+	result, err := s.Eval(ctx, renewScript, []string{key}, nodeID, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.(int64)
+	return n == 1, nil
+}
+
+// release 释放租约
+func release(ctx context.Context, s store.Store, key, nodeID string) error {
+	_, err := s.Eval(ctx, releaseScript, []string{key}, nodeID)
+	return err
+}
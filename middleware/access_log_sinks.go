@@ -0,0 +1,391 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\access_log_sinks.go
+ * @Description: 访问日志多路输出 - 在全局日志之外同时写入多个 sink（轮转文件、syslog、Loki、Kafka），
+ *               每个 sink 独立的格式、级别、缓冲与批量刷新，写入失败或缓冲满时丢弃并计数，不阻塞请求
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// AccessLogSinkType sink 类型
+type AccessLogSinkType string
+
+const (
+	AccessLogSinkFile   AccessLogSinkType = "file"
+	AccessLogSinkSyslog AccessLogSinkType = "syslog"
+	AccessLogSinkLoki   AccessLogSinkType = "loki"
+	AccessLogSinkKafka  AccessLogSinkType = "kafka"
+	AccessLogSinkCustom AccessLogSinkType = "custom"
+)
+
+// AccessLogFormat 日志行格式
+type AccessLogFormat string
+
+const (
+	AccessLogFormatJSON AccessLogFormat = "json" // 单行 JSON
+	AccessLogFormatText AccessLogFormat = "text" // logfmt（key=value）
+)
+
+// sink 默认参数
+const (
+	defaultAccessLogBufferSize    = 4096
+	defaultAccessLogBatchSize     = 100
+	defaultAccessLogFlushInterval = time.Second
+)
+
+// 访问日志 sink 指标
+var (
+	accessLogWrittenCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_access_log_written_total",
+		Help: "Total number of access log entries written per sink",
+	}, []string{"sink"})
+
+	accessLogDroppedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_access_log_dropped_total",
+		Help: "Total number of access log entries dropped per sink",
+	}, []string{"sink", "reason"})
+)
+
+// AccessLogConfig 访问日志多路输出配置（对应 middleware.access_log）
+type AccessLogConfig struct {
+	Sinks []*AccessLogSinkConfig `json:"sinks" yaml:"sinks" mapstructure:"sinks"`
+}
+
+// AccessLogSinkConfig 单个 sink 配置
+type AccessLogSinkConfig struct {
+	Name          string            `json:"name" yaml:"name" mapstructure:"name"`                               // sink 名称（指标标签），默认取类型
+	Type          AccessLogSinkType `json:"type" yaml:"type" mapstructure:"type"`                               // sink 类型
+	Format        AccessLogFormat   `json:"format" yaml:"format" mapstructure:"format"`                         // 日志行格式，默认 json
+	Level         string            `json:"level" yaml:"level" mapstructure:"level"`                            // 最低级别（info/warn/error），默认 info
+	BufferSize    int               `json:"buffer_size" yaml:"buffer-size" mapstructure:"buffer-size"`          // 异步缓冲条数，满时丢弃
+	BatchSize     int               `json:"batch_size" yaml:"batch-size" mapstructure:"batch-size"`             // 单批最大条数
+	FlushInterval time.Duration     `json:"flush_interval" yaml:"flush-interval" mapstructure:"flush-interval"` // 未满批时的刷新间隔
+
+	File   *FileSinkConfig   `json:"file,omitempty" yaml:"file,omitempty" mapstructure:"file"`
+	Syslog *SyslogSinkConfig `json:"syslog,omitempty" yaml:"syslog,omitempty" mapstructure:"syslog"`
+	Loki   *LokiSinkConfig   `json:"loki,omitempty" yaml:"loki,omitempty" mapstructure:"loki"`
+	Kafka  *KafkaSinkConfig  `json:"kafka,omitempty" yaml:"kafka,omitempty" mapstructure:"kafka"`
+	Custom AccessLogSink     `json:"-" yaml:"-" mapstructure:"-"` // Type 为 custom 时使用
+}
+
+// AccessLogEntry 访问日志条目
+type AccessLogEntry struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Fields  []any // key/value 交替
+}
+
+// Field 获取字段值
+func (e *AccessLogEntry) Field(key string) (any, bool) {
+	for i := 0; i+1 < len(e.Fields); i += 2 {
+		if k, ok := e.Fields[i].(string); ok && k == key {
+			return e.Fields[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// AccessLogSink 访问日志输出端，WriteBatch 由单个 goroutine 串行调用
+type AccessLogSink interface {
+	WriteBatch(entries []*AccessLogEntry) error
+	Close() error
+}
+
+// accessLogLevels 级别排序
+var accessLogLevels = map[string]int{
+	constants.LogLevelDebug: 0,
+	constants.LogLevelInfo:  1,
+	constants.LogLevelWarn:  2,
+	constants.LogLevelError: 3,
+}
+
+// accessLogSinks 当前生效的 sink 集合
+var accessLogSinks atomic.Pointer[[]*sinkRunner]
+
+// sinkRunner 单个 sink 的异步写入器
+type sinkRunner struct {
+	name          string
+	minLevel      int
+	sink          AccessLogSink
+	ch            chan *AccessLogEntry
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// SetAccessLogSinks 设置访问日志 sink，替换并关闭已有 sink；cfg 为 nil 或无 sink 时仅关闭
+func SetAccessLogSinks(cfg *AccessLogConfig) error {
+	var runners []*sinkRunner
+	if cfg != nil {
+		for i, sc := range cfg.Sinks {
+			if sc == nil {
+				continue
+			}
+			runner, err := newSinkRunner(sc)
+			if err != nil {
+				for _, r := range runners {
+					r.close()
+				}
+				return errors.NewErrorf(errors.ErrCodeInvalidParameter, "access log sink #%d (%s): %v", i, sc.Type, err)
+			}
+			runners = append(runners, runner)
+		}
+	}
+
+	var previous *[]*sinkRunner
+	if len(runners) > 0 {
+		previous = accessLogSinks.Swap(&runners)
+	} else {
+		previous = accessLogSinks.Swap(nil)
+	}
+	if previous != nil {
+		for _, r := range *previous {
+			r.close()
+		}
+	}
+
+	for _, r := range runners {
+		global.LOGGER.InfoKV("访问日志 sink 已启用", "sink", r.name, "batch_size", r.batchSize,
+			"flush_interval", r.flushInterval.String())
+	}
+	return nil
+}
+
+// CloseAccessLogSinks 刷新并关闭所有访问日志 sink
+func CloseAccessLogSinks() {
+	_ = SetAccessLogSinks(nil)
+}
+
+// newSinkRunner 按配置创建 sink 并启动写入 goroutine
+func newSinkRunner(cfg *AccessLogSinkConfig) (*sinkRunner, error) {
+	format := cfg.Format
+	if format == "" {
+		format = AccessLogFormatJSON
+	}
+	if format != AccessLogFormatJSON && format != AccessLogFormatText {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	level := cfg.Level
+	if level == "" {
+		level = constants.LogLevelInfo
+	}
+	minLevel, ok := accessLogLevels[strings.ToLower(level)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported level %q", cfg.Level)
+	}
+
+	var (
+		sink AccessLogSink
+		err  error
+	)
+	switch cfg.Type {
+	case AccessLogSinkFile:
+		sink, err = newFileSink(cfg.File, format)
+	case AccessLogSinkSyslog:
+		sink, err = newSyslogSink(cfg.Syslog, format)
+	case AccessLogSinkLoki:
+		sink, err = newLokiSink(cfg.Loki, format)
+	case AccessLogSinkKafka:
+		sink, err = newKafkaSink(cfg.Kafka, format)
+	case AccessLogSinkCustom:
+		if cfg.Custom == nil {
+			err = fmt.Errorf("custom sink is nil")
+		}
+		sink = cfg.Custom
+	default:
+		err = fmt.Errorf("unsupported sink type %q", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = string(cfg.Type)
+	}
+	runner := &sinkRunner{
+		name:          name,
+		minLevel:      minLevel,
+		sink:          sink,
+		ch:            make(chan *AccessLogEntry, positiveOr(cfg.BufferSize, defaultAccessLogBufferSize)),
+		batchSize:     positiveOr(cfg.BatchSize, defaultAccessLogBatchSize),
+		flushInterval: cfg.FlushInterval,
+		done:          make(chan struct{}),
+	}
+	if runner.flushInterval <= 0 {
+		runner.flushInterval = defaultAccessLogFlushInterval
+	}
+	go runner.run()
+	return runner, nil
+}
+
+// positiveOr 非正数时返回默认值
+func positiveOr(value, def int) int {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+// dispatchAccessLog 将日志条目投递到所有级别匹配的 sink（非阻塞）
+func dispatchAccessLog(level, message string, fields []any) {
+	runners := accessLogSinks.Load()
+	if runners == nil {
+		return
+	}
+
+	entry := &AccessLogEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+		Fields:  fields,
+	}
+	rank := accessLogLevels[level]
+	for _, r := range *runners {
+		if rank < r.minLevel {
+			continue
+		}
+		select {
+		case r.ch <- entry:
+		default:
+			accessLogDroppedCounter.WithLabelValues(r.name, "buffer_full").Inc()
+		}
+	}
+}
+
+// run 按批量大小或刷新间隔写入 sink，通道关闭时写完剩余条目
+func (r *sinkRunner) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*AccessLogEntry, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.sink.WriteBatch(batch); err != nil {
+			accessLogDroppedCounter.WithLabelValues(r.name, "write_error").Add(float64(len(batch)))
+			global.LOGGER.WithError(err).WarnKV("访问日志 sink 写入失败", "sink", r.name, "entries", len(batch))
+		} else {
+			accessLogWrittenCounter.WithLabelValues(r.name).Add(float64(len(batch)))
+		}
+		batch = make([]*AccessLogEntry, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case entry, ok := <-r.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// close 停止接收、写完缓冲并关闭 sink
+func (r *sinkRunner) close() {
+	r.closeOnce.Do(func() {
+		close(r.ch)
+		<-r.done
+		if err := r.sink.Close(); err != nil {
+			global.LOGGER.WithError(err).WarnKV("访问日志 sink 关闭失败", "sink", r.name)
+		}
+	})
+}
+
+// formatAccessLog 按格式序列化日志条目（不含换行）
+func formatAccessLog(entry *AccessLogEntry, format AccessLogFormat) []byte {
+	if format == AccessLogFormatText {
+		return formatAccessLogText(entry)
+	}
+	return formatAccessLogJSON(entry)
+}
+
+// formatAccessLogJSON 单行 JSON，time/level/msg 在前，字段按 key 排序
+func formatAccessLogJSON(entry *AccessLogEntry) []byte {
+	record := make(map[string]any, len(entry.Fields)/2+3)
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		if key, ok := entry.Fields[i].(string); ok {
+			record[key] = entry.Fields[i+1]
+		}
+	}
+	record["time"] = entry.Time.Format(time.RFC3339Nano)
+	record["level"] = entry.Level
+	record["msg"] = entry.Message
+
+	data, err := response.MarshalJSON(record)
+	if err != nil {
+		return []byte(strconv.Quote(entry.Message))
+	}
+	return data
+}
+
+// formatAccessLogText logfmt 格式
+func formatAccessLogText(entry *AccessLogEntry) []byte {
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(entry.Time.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(entry.Level)
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(entry.Message))
+
+	keys := make([]int, 0, len(entry.Fields)/2)
+	for i := 0; i+1 < len(entry.Fields); i += 2 {
+		if _, ok := entry.Fields[i].(string); ok {
+			keys = append(keys, i)
+		}
+	}
+	sort.SliceStable(keys, func(a, c int) bool {
+		return entry.Fields[keys[a]].(string) < entry.Fields[keys[c]].(string)
+	})
+	for _, i := range keys {
+		b.WriteByte(' ')
+		b.WriteString(entry.Fields[i].(string))
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(fmt.Sprint(entry.Fields[i+1])))
+	}
+	return []byte(b.String())
+}
+
+// logfmtValue 含空白、引号或等号的值加引号
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
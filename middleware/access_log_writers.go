@@ -0,0 +1,428 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\access_log_writers.go
+ * @Description: 访问日志内置 sink - 按大小/时间轮转的文件、RFC 5424 syslog、Loki push API、Kafka
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// ============================================================================
+// 轮转文件
+// ============================================================================
+
+// FileSinkConfig 文件 sink 配置
+type FileSinkConfig struct {
+	Path           string        `json:"path" yaml:"path" mapstructure:"path"`                                  // 日志文件路径
+	MaxSizeMB      int           `json:"max_size_mb" yaml:"max-size-mb" mapstructure:"max-size-mb"`             // 单文件大小上限（MB），<=0 不按大小轮转
+	RotateInterval time.Duration `json:"rotate_interval" yaml:"rotate-interval" mapstructure:"rotate-interval"` // 按时间轮转间隔，<=0 不按时间轮转
+	MaxBackups     int           `json:"max_backups" yaml:"max-backups" mapstructure:"max-backups"`             // 保留的历史文件数，<=0 全部保留
+}
+
+// fileSink 按大小/时间轮转的文件 sink
+type fileSink struct {
+	cfg      FileSinkConfig
+	format   AccessLogFormat
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// newFileSink 创建文件 sink
+func newFileSink(cfg *FileSinkConfig, format AccessLogFormat) (*fileSink, error) {
+	if cfg == nil || cfg.Path == "" {
+		return nil, fmt.Errorf("file sink requires path")
+	}
+	sink := &fileSink{cfg: *cfg, format: format}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// open 打开（追加）日志文件
+func (s *fileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+// WriteBatch 写入一批日志，写入前检查是否需要轮转
+func (s *fileSink) WriteBatch(entries []*AccessLogEntry) error {
+	buf := response.AcquireBuffer()
+	defer response.ReleaseBuffer(buf)
+	for _, entry := range entries {
+		buf.Write(formatAccessLog(entry, s.format))
+		buf.WriteByte('\n')
+	}
+
+	if s.shouldRotate(int64(buf.Len())) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	return err
+}
+
+// shouldRotate 判断是否需要轮转
+func (s *fileSink) shouldRotate(incoming int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.cfg.MaxSizeMB > 0 && s.size+incoming > int64(s.cfg.MaxSizeMB)<<20 {
+		return true
+	}
+	return s.cfg.RotateInterval > 0 && time.Since(s.openedAt) >= s.cfg.RotateInterval
+}
+
+// rotate 重命名当前文件为带时间戳的备份并打开新文件，超出保留数量的旧备份被删除
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	backup := s.cfg.Path + "." + time.Now().Format("20060102-150405.000")
+	if err := os.Rename(s.cfg.Path, backup); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	s.pruneBackups()
+	return nil
+}
+
+// pruneBackups 删除超出保留数量的旧备份
+func (s *fileSink) pruneBackups() {
+	if s.cfg.MaxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(s.cfg.Path + ".*")
+	if err != nil || len(backups) <= s.cfg.MaxBackups {
+		return
+	}
+	sort.Strings(backups) // 时间戳后缀按字典序即时间序
+	for _, path := range backups[:len(backups)-s.cfg.MaxBackups] {
+		_ = os.Remove(path)
+	}
+}
+
+// Close 关闭文件
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// ============================================================================
+// Syslog（RFC 5424）
+// ============================================================================
+
+// SyslogSinkConfig syslog sink 配置
+type SyslogSinkConfig struct {
+	Network  string `json:"network" yaml:"network" mapstructure:"network"`    // udp / tcp / unix / unixgram，默认 udp
+	Address  string `json:"address" yaml:"address" mapstructure:"address"`    // 如 127.0.0.1:514、/dev/log
+	Facility int    `json:"facility" yaml:"facility" mapstructure:"facility"` // syslog facility，默认 16（local0）
+	Tag      string `json:"tag" yaml:"tag" mapstructure:"tag"`                // APP-NAME，默认 go-rpc-gateway
+}
+
+// syslog severity
+var syslogSeverities = map[string]int{
+	constants.LogLevelError: 3,
+	constants.LogLevelWarn:  4,
+	constants.LogLevelInfo:  6,
+	constants.LogLevelDebug: 7,
+}
+
+// syslogSink syslog sink（TCP 使用 octet-counting 分帧，连接断开后下一批重连）
+type syslogSink struct {
+	cfg      SyslogSinkConfig
+	format   AccessLogFormat
+	hostname string
+	conn     net.Conn
+}
+
+// newSyslogSink 创建 syslog sink
+func newSyslogSink(cfg *SyslogSinkConfig, format AccessLogFormat) (*syslogSink, error) {
+	if cfg == nil || cfg.Address == "" {
+		return nil, fmt.Errorf("syslog sink requires address")
+	}
+	sink := &syslogSink{cfg: *cfg, format: format}
+	if sink.cfg.Network == "" {
+		sink.cfg.Network = "udp"
+	}
+	if sink.cfg.Facility <= 0 {
+		sink.cfg.Facility = 16
+	}
+	if sink.cfg.Tag == "" {
+		sink.cfg.Tag = "go-rpc-gateway"
+	}
+	sink.hostname, _ = os.Hostname()
+	if err := sink.dial(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// dial 建立连接
+func (s *syslogSink) dial() error {
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// WriteBatch 逐条发送 syslog 消息
+func (s *syslogSink) WriteBatch(entries []*AccessLogEntry) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	stream := s.cfg.Network == "tcp" || s.cfg.Network == "tcp4" || s.cfg.Network == "tcp6" || s.cfg.Network == "unix"
+	for _, entry := range entries {
+		msg := s.message(entry)
+		if stream {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// message 构建 RFC 5424 消息
+func (s *syslogSink) message(entry *AccessLogEntry) []byte {
+	severity, ok := syslogSeverities[entry.Level]
+	if !ok {
+		severity = 6
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ",
+		s.cfg.Facility*8+severity,
+		entry.Time.Format(time.RFC3339Nano),
+		nilValue(s.hostname),
+		s.cfg.Tag,
+		os.Getpid())
+	return append([]byte(header), formatAccessLog(entry, s.format)...)
+}
+
+// nilValue 空值使用 RFC 5424 NILVALUE
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Close 关闭连接
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// ============================================================================
+// Loki push API
+// ============================================================================
+
+// LokiSinkConfig Loki sink 配置
+type LokiSinkConfig struct {
+	URL      string            `json:"url" yaml:"url" mapstructure:"url"`                   // 如 http://loki:3100/loki/api/v1/push
+	Labels   map[string]string `json:"labels" yaml:"labels" mapstructure:"labels"`          // 流标签（level 标签自动添加）
+	TenantID string            `json:"tenant_id" yaml:"tenant-id" mapstructure:"tenant-id"` // X-Scope-OrgID
+	Timeout  time.Duration     `json:"timeout" yaml:"timeout" mapstructure:"timeout"`       // 推送超时，默认 5s
+	Headers  map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`       // 额外请求头（如 Authorization）
+}
+
+// lokiSink Loki sink
+type lokiSink struct {
+	cfg    LokiSinkConfig
+	format AccessLogFormat
+	client *http.Client
+}
+
+// newLokiSink 创建 Loki sink
+func newLokiSink(cfg *LokiSinkConfig, format AccessLogFormat) (*lokiSink, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, fmt.Errorf("loki sink requires url")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &lokiSink{cfg: *cfg, format: format, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// lokiStream Loki 推送流
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// WriteBatch 按级别分流后推送
+func (s *lokiSink) WriteBatch(entries []*AccessLogEntry) error {
+	streams := make(map[string]*lokiStream)
+	order := make([]string, 0, 3)
+	for _, entry := range entries {
+		stream, ok := streams[entry.Level]
+		if !ok {
+			labels := make(map[string]string, len(s.cfg.Labels)+1)
+			for k, v := range s.cfg.Labels {
+				labels[k] = v
+			}
+			labels["level"] = entry.Level
+			stream = &lokiStream{Stream: labels}
+			streams[entry.Level] = stream
+			order = append(order, entry.Level)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			string(formatAccessLog(entry, s.format)),
+		})
+	}
+
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{Streams: make([]*lokiStream, 0, len(order))}
+	for _, level := range order {
+		payload.Streams = append(payload.Streams, streams[level])
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(constants.HeaderContentType, "application/json")
+	if s.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.TenantID)
+	}
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("loki push failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close 关闭空闲连接
+func (s *lokiSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// ============================================================================
+// Kafka
+// ============================================================================
+
+// KafkaMessage Kafka 消息
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer Kafka 生产者（由业务方基于 sarama / kafka-go 等客户端实现）
+type KafkaProducer interface {
+	SendMessages(ctx context.Context, topic string, messages []KafkaMessage) error
+	Close() error
+}
+
+// KafkaSinkConfig Kafka sink 配置
+type KafkaSinkConfig struct {
+	Topic    string        `json:"topic" yaml:"topic" mapstructure:"topic"`       // 目标 topic
+	Timeout  time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"` // 单批发送超时，默认 5s
+	Producer KafkaProducer `json:"-" yaml:"-" mapstructure:"-"`                   // 生产者实现
+}
+
+// kafkaSink Kafka sink，消息 key 取 trace_id 以保证同一链路落在同一分区
+type kafkaSink struct {
+	cfg    KafkaSinkConfig
+	format AccessLogFormat
+}
+
+// newKafkaSink 创建 Kafka sink
+func newKafkaSink(cfg *KafkaSinkConfig, format AccessLogFormat) (*kafkaSink, error) {
+	if cfg == nil || cfg.Topic == "" {
+		return nil, fmt.Errorf("kafka sink requires topic")
+	}
+	if cfg.Producer == nil {
+		return nil, fmt.Errorf("kafka sink requires producer")
+	}
+	sink := &kafkaSink{cfg: *cfg, format: format}
+	if sink.cfg.Timeout <= 0 {
+		sink.cfg.Timeout = 5 * time.Second
+	}
+	return sink, nil
+}
+
+// WriteBatch 批量发送
+func (s *kafkaSink) WriteBatch(entries []*AccessLogEntry) error {
+	messages := make([]KafkaMessage, 0, len(entries))
+	for _, entry := range entries {
+		var key []byte
+		if traceID, ok := entry.Field(constants.LogFieldTraceID); ok {
+			key = []byte(fmt.Sprint(traceID))
+		}
+		messages = append(messages, KafkaMessage{Key: key, Value: formatAccessLog(entry, s.format)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	return s.cfg.Producer.SendMessages(ctx, s.cfg.Topic, messages)
+}
+
+// Close 关闭生产者
+func (s *kafkaSink) Close() error {
+	return s.cfg.Producer.Close()
+}
@@ -0,0 +1,413 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\api_keys.go
+ * @Description: API Key - 网关签发的调用凭证：只保存密钥哈希，记录保存在状态存储（Redis 或内嵌存储），
+ *               作为认证器接入统一认证链，按天统计每个 Key 的调用量供开发者门户展示
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// API Key 默认值
+const (
+	DefaultAPIKeyHeader         = "X-API-Key"
+	DefaultAPIKeyPrefix         = "gk"
+	DefaultAPIKeyMaxPerOwner    = 10
+	DefaultAPIKeyUsageRetention = 30 * 24 * time.Hour
+	apiKeyStorePrefix           = "gateway:apikey:"
+	apiKeyOwnerStorePrefix      = "gateway:apikey:owner:"
+	apiKeyUsageStorePrefix      = "gateway:apikey:usage:"
+	apiKeyLastUsedStorePrefix   = "gateway:apikey:last:"
+	apiKeyDateLayout            = "20060102"
+)
+
+// PrincipalAttrAPIKeyID 通过 API Key 认证时 Principal.Attributes 中的 Key ID
+const PrincipalAttrAPIKeyID = "api_key_id"
+
+// APIKeysConfig API Key 配置
+type APIKeysConfig struct {
+	Header         string        // 携带 Key 的请求头，默认 X-API-Key
+	Prefix         string        // 明文 Key 前缀，默认 gk，便于密钥扫描工具识别泄漏
+	MaxPerOwner    int           // 每个主体最多持有的有效 Key 数，默认 10
+	DefaultTTL     time.Duration // 申请时未指定有效期时使用，0 表示永不过期
+	MaxTTL         time.Duration // 允许申请的最长有效期，0 表示不限制
+	Scopes         []string      // 允许自助申请的授权范围，申请的范围必须是其子集
+	Roles          []string      // 通过 Key 认证的身份统一具备的角色，如 api_client，便于访问规则区分 Key 调用
+	UsageRetention time.Duration // 按天用量的保留时长，默认 30 天
+}
+
+// APIKey Key 元数据（不含密钥）
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner"` // 申请者 Principal.Subject
+	Tenant    string     `json:"tenant,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	Hint      string     `json:"hint"` // 明文 Key 的前几位，用于辨认
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRequest 申请 Key
+type APIKeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []string      `json:"scopes,omitempty"`
+	TTL    time.Duration `json:"-"`
+	// TTLSeconds JSON 请求中的有效期（秒），0 使用默认有效期
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+}
+
+// APIKeyUsage Key 用量
+type APIKeyUsage struct {
+	ID         string             `json:"id"`
+	Total      int64              `json:"total"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty"`
+	Daily      []APIKeyDailyUsage `json:"daily"` // 按日期升序，UTC
+}
+
+// APIKeyDailyUsage 单日用量
+type APIKeyDailyUsage struct {
+	Date     string `json:"date"` // YYYY-MM-DD
+	Requests int64  `json:"requests"`
+}
+
+// APIKeys API Key 管理与认证
+type APIKeys struct {
+	config APIKeysConfig
+	header string
+	mu     sync.Mutex // 串行化本实例对主体索引的读改写
+}
+
+// apiKeyRecord 存储格式：元数据与密钥哈希
+type apiKeyRecord struct {
+	*APIKey
+	Hash string `json:"hash"`
+}
+
+// apiKeyAuthTotal API Key 认证结果数（注册到默认 Registry）
+var apiKeyAuthTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_api_key_auth_total",
+	Help: "Total number of API key authentication attempts by result",
+}, []string{"result"})
+
+// NewAPIKeys 创建 API Key 管理器
+func NewAPIKeys(cfg APIKeysConfig) (*APIKeys, error) {
+	if cfg.Header == "" {
+		cfg.Header = DefaultAPIKeyHeader
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultAPIKeyPrefix
+	}
+	if strings.Contains(cfg.Prefix, "_") {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "api keys: prefix %q must not contain '_'", cfg.Prefix)
+	}
+	if cfg.MaxPerOwner <= 0 {
+		cfg.MaxPerOwner = DefaultAPIKeyMaxPerOwner
+	}
+	if cfg.UsageRetention <= 0 {
+		cfg.UsageRetention = DefaultAPIKeyUsageRetention
+	}
+	if cfg.MaxTTL > 0 && (cfg.DefaultTTL <= 0 || cfg.DefaultTTL > cfg.MaxTTL) {
+		cfg.DefaultTTL = cfg.MaxTTL
+	}
+	return &APIKeys{config: cfg, header: strings.ToLower(cfg.Header)}, nil
+}
+
+// Header 携带 Key 的请求头
+func (k *APIKeys) Header() string {
+	return k.config.Header
+}
+
+// Scopes 允许自助申请的授权范围
+func (k *APIKeys) Scopes() []string {
+	return k.config.Scopes
+}
+
+// Authenticate 实现 Authenticator：未携带 Key 交给下一个认证器，Key 无效、已吊销或过期返回错误，
+// 状态存储不可用返回 ErrAuthnUnavailable
+func (k *APIKeys) Authenticate(ctx context.Context, req *AuthzRequest) (*Principal, error) {
+	raw := strings.TrimSpace(req.Headers[k.header])
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := k.verify(ctx, raw)
+	if err != nil {
+		result := "invalid"
+		if stderrors.Is(err, ErrAuthnUnavailable) {
+			result = "unavailable"
+		}
+		apiKeyAuthTotal.WithLabelValues(result).Inc()
+		return nil, err
+	}
+	apiKeyAuthTotal.WithLabelValues("authenticated").Inc()
+	k.recordUsage(ctx, key.ID)
+	return &Principal{
+		Subject:    key.Owner,
+		Roles:      slices.Clone(k.config.Roles),
+		Scopes:     slices.Clone(key.Scopes),
+		Tenant:     key.Tenant,
+		AuthType:   PrincipalAuthAPIKey,
+		Attributes: map[string]string{PrincipalAttrAPIKeyID: key.ID},
+	}, nil
+}
+
+// verify 校验明文 Key
+func (k *APIKeys) verify(ctx context.Context, raw string) (*APIKey, error) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != k.config.Prefix || parts[1] == "" || parts[2] == "" {
+		return nil, stderrors.New("malformed api key")
+	}
+	record, err := k.load(ctx, parts[1])
+	if stderrors.Is(err, store.ErrNotFound) {
+		return nil, stderrors.New("unknown api key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthnUnavailable, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(record.Hash), []byte(apiKeyHash(parts[2]))) != 1 {
+		return nil, stderrors.New("unknown api key")
+	}
+	if record.RevokedAt != nil {
+		return nil, stderrors.New("api key revoked")
+	}
+	if record.ExpiresAt != nil && time.Now().After(*record.ExpiresAt) {
+		return nil, stderrors.New("api key expired")
+	}
+	return record.APIKey, nil
+}
+
+// Create 为主体签发 Key，返回只出现这一次的明文 Key
+func (k *APIKeys) Create(ctx context.Context, owner *Principal, req APIKeyRequest) (string, *APIKey, *errors.AppError) {
+	if owner.IsAnonymous() {
+		return "", nil, errors.ErrUnauthorized
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		return "", nil, errors.NewError(errors.ErrCodeInvalidParameter, "api key name is required (at most 64 characters)")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(k.config.Scopes, scope) {
+			return "", nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "scope %q cannot be requested", scope)
+		}
+	}
+	if req.TTL == 0 && req.TTLSeconds > 0 {
+		req.TTL = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if req.TTL <= 0 {
+		req.TTL = k.config.DefaultTTL
+	}
+	if k.config.MaxTTL > 0 && req.TTL > k.config.MaxTTL {
+		return "", nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "api key ttl exceeds %s", k.config.MaxTTL)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys, err := k.List(ctx, owner.Subject)
+	if err != nil {
+		return "", nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "api key store: %v", err)
+	}
+	if active := slices.DeleteFunc(keys, func(key *APIKey) bool { return !key.active() }); len(active) >= k.config.MaxPerOwner {
+		return "", nil, errors.NewErrorf(errors.ErrCodeBadRequest, "at most %d active api keys per owner, revoke one first", k.config.MaxPerOwner)
+	}
+
+	id, secret := randomHex(8), randomHex(24)
+	plain := k.config.Prefix + "_" + id + "_" + secret
+	key := &APIKey{
+		ID:        id,
+		Name:      req.Name,
+		Owner:     owner.Subject,
+		Tenant:    owner.Tenant,
+		Scopes:    req.Scopes,
+		Hint:      plain[:len(k.config.Prefix)+1+len(id)+4],
+		CreatedAt: time.Now().UTC(),
+	}
+	if req.TTL > 0 {
+		expires := key.CreatedAt.Add(req.TTL)
+		key.ExpiresAt = &expires
+	}
+	if err := k.save(ctx, &apiKeyRecord{APIKey: key, Hash: apiKeyHash(secret)}); err != nil {
+		return "", nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "api key store: %v", err)
+	}
+	if err := k.saveIndex(ctx, owner.Subject, append(k.ownerIDs(ctx, owner.Subject), id)); err != nil {
+		return "", nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "api key store: %v", err)
+	}
+	global.LOGGER.InfoContextKV(ctx, "API Key 已签发", "id", id, "owner", owner.Subject, "scopes", req.Scopes)
+	return plain, key, nil
+}
+
+// List 主体持有的 Key（含已吊销与已过期），按创建时间排序
+func (k *APIKeys) List(ctx context.Context, owner string) ([]*APIKey, error) {
+	if global.STORE == nil {
+		return nil, stderrors.New("store is not initialized")
+	}
+	var keys []*APIKey
+	for _, id := range k.ownerIDs(ctx, owner) {
+		record, err := k.load(ctx, id)
+		if stderrors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, record.APIKey)
+	}
+	slices.SortFunc(keys, func(a, b *APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return keys, nil
+}
+
+// Revoke 吊销主体自己的 Key
+func (k *APIKeys) Revoke(ctx context.Context, owner, id string) *errors.AppError {
+	record, err := k.load(ctx, id)
+	if stderrors.Is(err, store.ErrNotFound) || (err == nil && record.Owner != owner) {
+		return errors.NewErrorf(errors.ErrCodeNotFound, "api key %s not found", id)
+	}
+	if err != nil {
+		return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "api key store: %v", err)
+	}
+	if record.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	record.RevokedAt = &now
+	if err := k.save(ctx, record); err != nil {
+		return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "api key store: %v", err)
+	}
+	global.LOGGER.InfoContextKV(ctx, "API Key 已吊销", "id", id, "owner", owner)
+	return nil
+}
+
+// Usage 最近 days 天（含当天）的按天用量
+func (k *APIKeys) Usage(ctx context.Context, id string, days int) (*APIKeyUsage, error) {
+	if global.STORE == nil {
+		return nil, stderrors.New("store is not initialized")
+	}
+	usage := &APIKeyUsage{ID: id, Daily: make([]APIKeyDailyUsage, 0, days)}
+	today := time.Now().UTC()
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		var requests int64
+		raw, err := global.STORE.Get(ctx, apiKeyUsageStorePrefix+id+":"+day.Format(apiKeyDateLayout))
+		if err == nil {
+			_, _ = fmt.Sscan(raw, &requests)
+		} else if !stderrors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		usage.Total += requests
+		usage.Daily = append(usage.Daily, APIKeyDailyUsage{Date: day.Format(time.DateOnly), Requests: requests})
+	}
+	if raw, err := global.STORE.Get(ctx, apiKeyLastUsedStorePrefix+id); err == nil {
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			usage.LastUsedAt = &t
+		}
+	}
+	return usage, nil
+}
+
+// recordUsage 累加当天用量并记录最近使用时间，失败只影响统计
+func (k *APIKeys) recordUsage(ctx context.Context, id string) {
+	now := time.Now().UTC()
+	key := apiKeyUsageStorePrefix + id + ":" + now.Format(apiKeyDateLayout)
+	if n, err := global.STORE.Incr(ctx, key); err == nil && n == 1 {
+		_ = global.STORE.Expire(ctx, key, k.config.UsageRetention)
+	}
+	_ = global.STORE.Set(ctx, apiKeyLastUsedStorePrefix+id, now.Format(time.RFC3339), k.config.UsageRetention)
+}
+
+// active 未吊销且未过期
+func (key *APIKey) active() bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || time.Now().Before(*key.ExpiresAt))
+}
+
+// load 读取 Key 记录
+func (k *APIKeys) load(ctx context.Context, id string) (*apiKeyRecord, error) {
+	if global.STORE == nil {
+		return nil, stderrors.New("store is not initialized")
+	}
+	raw, err := global.STORE.Get(ctx, apiKeyStorePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	var record apiKeyRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil || record.APIKey == nil {
+		return nil, fmt.Errorf("corrupt api key record %s", id)
+	}
+	return &record, nil
+}
+
+// save 写入 Key 记录；已过期的 Key 保留到用量过期后再由存储清理
+func (k *APIKeys) save(ctx context.Context, record *apiKeyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if record.ExpiresAt != nil {
+		ttl = time.Until(*record.ExpiresAt) + k.config.UsageRetention
+	}
+	return global.STORE.Set(ctx, apiKeyStorePrefix+record.ID, string(data), ttl)
+}
+
+// ownerIDs 主体索引中的 Key ID
+func (k *APIKeys) ownerIDs(ctx context.Context, owner string) []string {
+	raw, err := global.STORE.Get(ctx, apiKeyOwnerStorePrefix+owner)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	_ = json.Unmarshal([]byte(raw), &ids)
+	return ids
+}
+
+// saveIndex 写入主体索引，顺带剔除已被存储清理的 Key
+func (k *APIKeys) saveIndex(ctx context.Context, owner string, ids []string) error {
+	live := ids[:0]
+	for _, id := range ids {
+		if _, err := k.load(ctx, id); !stderrors.Is(err, store.ErrNotFound) {
+			live = append(live, id)
+		}
+	}
+	data, err := json.Marshal(live)
+	if err != nil {
+		return err
+	}
+	return global.STORE.Set(ctx, apiKeyOwnerStorePrefix+owner, string(data), 0)
+}
+
+// apiKeyHash 密钥的 SHA-256
+func apiKeyHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex n 字节随机数的十六进制
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
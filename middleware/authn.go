@@ -0,0 +1,364 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\authn.go
+ * @Description: 统一认证 - 认证器链产生 Principal，按路由规则校验角色 / 授权范围 / 租户；
+ *               HTTP 中间件与 gRPC 拦截器共用同一份认证器与规则，策略只写一次
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator 认证器：识别请求凭证并返回身份
+// 返回 (nil, nil) 表示请求未携带本认证器识别的凭证，交给下一个认证器；返回错误表示凭证无效，请求被拒绝
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *AuthzRequest) (*Principal, error)
+}
+
+// ErrAuthnUnavailable 认证器依赖的身份服务不可用；认证器返回包装了此错误的错误时响应 503 / Unavailable 而非 401
+var ErrAuthnUnavailable = stderrors.New("authentication service unavailable")
+
+// AuthenticatorFunc 函数形式的认证器
+type AuthenticatorFunc func(ctx context.Context, req *AuthzRequest) (*Principal, error)
+
+// Authenticate 实现 Authenticator
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, req *AuthzRequest) (*Principal, error) {
+	return f(ctx, req)
+}
+
+// PrincipalRule 访问规则，命中的请求要求身份同时满足配置的各项条件
+type PrincipalRule struct {
+	Paths     []string                                   // HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+	Methods   []string                                   // HTTP 方法，为空匹配全部；gRPC 调用按 POST 匹配
+	Anonymous bool                                       // 允许匿名访问（其余条件不再检查）
+	Roles     []string                                   // 具备任一角色
+	Scopes    []string                                   // 具备全部授权范围
+	Tenants   []string                                   // 租户属于其一
+	Check     func(p *Principal, req *AuthzRequest) bool // 自定义条件
+}
+
+// AuthnEvent 认证与规则校验结果（审计）
+type AuthnEvent struct {
+	Time      time.Time  `json:"time"`
+	Protocol  string     `json:"protocol"`
+	Method    string     `json:"method"`
+	Path      string     `json:"path"`
+	ClientIP  string     `json:"client_ip"`
+	Principal *Principal `json:"principal,omitempty"`
+	Result    string     `json:"result"`          // authenticated / anonymous / invalid / forbidden / unavailable
+	Error     string     `json:"error,omitempty"` // 认证器返回的错误
+}
+
+// AuthenticationConfig 统一认证配置
+type AuthenticationConfig struct {
+	Authenticators []Authenticator // 按顺序尝试，首个返回身份的认证器生效
+
+	Rules          []PrincipalRule // 访问规则，多条命中时取靠前的一条
+	AllowAnonymous bool            // 未命中规则的请求允许匿名；默认要求认证
+	SkipPaths      []string        // 不做认证的 HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+
+	Audit func(ctx context.Context, event AuthnEvent) // 审计回调，在请求协程中同步执行
+}
+
+// Authentication 编译后的统一认证处理器
+type Authentication struct {
+	config AuthenticationConfig
+	rules  *RouteTable
+	skip   *RouteTable
+}
+
+// authnDenial 认证或规则校验失败
+type authnDenial struct {
+	status  int
+	code    string
+	message string
+}
+
+// authnResultsTotal 认证结果数（注册到默认 Registry）
+var authnResultsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_authn_results_total",
+	Help: "Total number of authentication results",
+}, []string{"protocol", "result"})
+
+// NewAuthentication 校验配置并创建统一认证处理器
+func NewAuthentication(cfg AuthenticationConfig) (*Authentication, error) {
+	if len(cfg.Authenticators) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "authentication requires at least one authenticator")
+	}
+	for i, a := range cfg.Authenticators {
+		if a == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "authenticator %d is nil", i)
+		}
+	}
+
+	var rules []RoutePattern
+	for i := range cfg.Rules {
+		rule := cfg.Rules[i]
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "principal rule %d has no paths", i)
+		}
+		for _, path := range rule.Paths {
+			rules = append(rules, authnPattern(path, rule.Methods, &rule))
+		}
+	}
+	skip := make([]RoutePattern, 0, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip = append(skip, authnPattern(path, nil, true))
+	}
+	return &Authentication{config: cfg, rules: NewRouteTable(rules), skip: NewRouteTable(skip)}, nil
+}
+
+// authnPattern 路径（以 * 结尾为前缀）转为路由规则
+func authnPattern(path string, methods []string, value any) RoutePattern {
+	p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Methods: methods, Value: value}
+	if prefix, ok := strings.CutSuffix(path, "*"); ok {
+		p.Kind, p.Pattern = RouteMatchPrefix, prefix
+	}
+	return p
+}
+
+// authenticate 认证请求并校验访问规则，返回身份（匿名时为 nil）与失败原因
+func (a *Authentication) authenticate(ctx context.Context, req *AuthzRequest) (*Principal, *authnDenial) {
+	var principal *Principal
+	var authErr error
+	if p, ok := internalPrincipal(ctx); ok {
+		// 进程内合成请求（如定时调用）直接使用其身份，仍需通过访问规则
+		principal = p
+	} else {
+		for _, authenticator := range a.config.Authenticators {
+			principal, authErr = authenticator.Authenticate(ctx, req)
+			if authErr != nil || principal != nil {
+				break
+			}
+		}
+	}
+	if principal != nil && principal.AuthType == "" {
+		principal.AuthType = PrincipalAuthCustom
+	}
+
+	var denial *authnDenial
+	result := constants.AuthnResultAuthenticated
+	switch {
+	case stderrors.Is(authErr, ErrAuthnUnavailable):
+		principal = nil
+		result = constants.AuthnResultUnavailable
+		denial = &authnDenial{status: http.StatusServiceUnavailable, code: constants.AuthnErrorCodeUnavailable, message: constants.AuthnErrorUnavailable}
+	case authErr != nil:
+		principal = nil
+		result = constants.AuthnResultInvalid
+		denial = &authnDenial{status: http.StatusUnauthorized, code: constants.AuthnErrorCodeInvalid, message: constants.AuthnErrorInvalid}
+	case !a.permit(principal, req):
+		if principal.IsAnonymous() {
+			result = constants.AuthnResultAnonymous
+			denial = &authnDenial{status: http.StatusUnauthorized, code: constants.AuthnErrorCodeUnauthenticated, message: constants.AuthnErrorUnauthenticated}
+		} else {
+			result = constants.AuthnResultForbidden
+			denial = &authnDenial{status: http.StatusForbidden, code: constants.AuthnErrorCodeForbidden, message: constants.AuthnErrorForbidden}
+		}
+	case principal.IsAnonymous():
+		result = constants.AuthnResultAnonymous
+	}
+
+	authnResultsTotal.WithLabelValues(req.Protocol, result).Inc()
+	if a.config.Audit != nil {
+		event := AuthnEvent{
+			Time:      time.Now(),
+			Protocol:  req.Protocol,
+			Method:    req.Method,
+			Path:      req.Path,
+			ClientIP:  req.ClientIP,
+			Principal: principal,
+			Result:    result,
+		}
+		if authErr != nil {
+			event.Error = authErr.Error()
+		}
+		a.config.Audit(ctx, event)
+	}
+	if authErr != nil && global.LOGGER != nil {
+		global.LOGGER.DebugContextKV(ctx, "authentication failed", "path", req.Path, "error", authErr.Error())
+	}
+	return principal, denial
+}
+
+// permit 按访问规则判断身份是否允许访问
+func (a *Authentication) permit(p *Principal, req *AuthzRequest) bool {
+	value, ok := a.rules.Match(req.Method, req.Path)
+	if !ok {
+		return a.config.AllowAnonymous || !p.IsAnonymous()
+	}
+	rule := value.(*PrincipalRule)
+	if rule.Anonymous {
+		return true
+	}
+	if p.IsAnonymous() {
+		return false
+	}
+	if !p.HasAnyRole(rule.Roles...) || !p.HasScopes(rule.Scopes...) {
+		return false
+	}
+	if len(rule.Tenants) > 0 && !slices.Contains(rule.Tenants, p.Tenant) {
+		return false
+	}
+	return rule.Check == nil || rule.Check(p, req)
+}
+
+// Skip 请求是否跳过认证
+func (a *Authentication) Skip(method, path string) bool {
+	_, skip := a.skip.Match(method, path)
+	return skip
+}
+
+// HTTPMiddleware 返回 HTTP 中间件
+func (a *Authentication) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Handle(w, r, next)
+	})
+}
+
+// Handle 认证 HTTP 请求：认证器读取原始请求头后剔除其中的身份头，认证通过后把身份写入上下文与转发头
+func (a *Authentication) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	skip := a.Skip(r.Method, r.URL.Path)
+	var req *AuthzRequest
+	if !skip {
+		req = NewHTTPAuthzRequest(r)
+	}
+	for _, name := range principalHeaders {
+		r.Header.Del(name)
+	}
+	if skip {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	principal, denial := a.authenticate(r.Context(), req)
+	if denial != nil {
+		response.WriteErrorResponseWithCode(w, denial.status, denial.code, denial.message)
+		return
+	}
+	if principal == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+	PrincipalToHeader(principal, r.Header)
+	next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+}
+
+// UnaryServerInterceptor gRPC 一元调用认证拦截器
+func (a *Authentication) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return a.Unary
+}
+
+// StreamServerInterceptor gRPC 流式调用认证拦截器
+func (a *Authentication) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return a.Stream
+}
+
+// Unary 认证 gRPC 一元调用
+func (a *Authentication) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticateGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream 认证 gRPC 流式调用（建立流时认证一次）
+func (a *Authentication) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticateGRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	if ctx == ss.Context() {
+		return handler(srv, ss)
+	}
+	return handler(srv, &authzServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticateGRPC 认证 gRPC 调用：metadata 中的身份键替换为认证结果，身份写入上下文
+func (a *Authentication) authenticateGRPC(ctx context.Context, fullMethod string) (context.Context, error) {
+	if a.Skip(http.MethodPost, fullMethod) {
+		return ctx, nil
+	}
+	// 认证器读取原始 metadata（可信上游传递的身份由 MetadataAuthenticator 识别）
+	principal, denial := a.authenticate(ctx, NewGRPCAuthzRequest(ctx, fullMethod))
+	if denial != nil {
+		return ctx, status.Error(authzGRPCCode(denial.status), denial.message)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	for _, name := range principalHeaders {
+		md.Delete(name)
+	}
+	if principal == nil {
+		return metadata.NewIncomingContext(ctx, md), nil
+	}
+	for key, values := range PrincipalToMetadata(principal) {
+		md[key] = values
+	}
+	return WithPrincipal(metadata.NewIncomingContext(ctx, md), principal), nil
+}
+
+// NewMetadataAuthenticator 信任来自指定网段的调用方（如上游网关）通过 x-user-id / x-principal-* 传递的身份，
+// 按连接的对端地址判断，不读取 X-Forwarded-For；其余调用方交给下一个认证器
+func NewMetadataAuthenticator(trustedCIDRs []string) (Authenticator, error) {
+	prefixes := make([]netip.Prefix, 0, len(trustedCIDRs))
+	for _, cidr := range trustedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid trusted cidr %q", cidr)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return AuthenticatorFunc(func(ctx context.Context, req *AuthzRequest) (*Principal, error) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return nil, nil
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return nil, nil
+		}
+		addr = addr.Unmap()
+		if !slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+			return nil, nil
+		}
+		p, ok := principalFromLookup(func(name string) string { return req.Headers[name] })
+		if !ok {
+			return nil, nil
+		}
+		// AuthzRequest 中的多值以逗号连接，取首个值
+		p.Subject, _, _ = strings.Cut(p.Subject, ",")
+		p.Tenant, _, _ = strings.Cut(p.Tenant, ",")
+		return p, nil
+	}), nil
+}
@@ -45,6 +45,7 @@ func BreakerMiddleware(config *gobreaker.CircuitBreaker) func(http.Handler) http
 			// 获取断路器
 			breaker := manager.GetBreaker(r.URL.Path)
 			if !breaker.Allow() {
+				markCircuitOpen(r.Context())
 				http.Error(w, "Service Unavailable - Circuit Breaker Open", http.StatusServiceUnavailable)
 				return
 			}
@@ -75,3 +76,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,429 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\broadcast.go
+ * @Description: 广播推送 - 客户端通过同一订阅接口以 SSE 或 WebSocket 连接并订阅主题，
+ *               gw.Broadcast 与管理接口按主题、用户、租户筛选推送消息并统计投递结果，
+ *               用于运维通知与浏览器缓存失效推送；队列满的慢客户端丢弃消息而不阻塞发送方
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 广播默认值
+const (
+	DefaultBroadcastPath         = "/events"
+	DefaultBroadcastAdminPath    = "/admin/broadcast"
+	DefaultBroadcastBufferSize   = 64
+	DefaultBroadcastPingInterval = 30 * time.Second
+	broadcastWriteTimeout        = 10 * time.Second
+	broadcastAdminBodyBytes      = 64 << 10
+)
+
+// 广播订阅者的连接方式（指标 transport 标签）
+const (
+	BroadcastTransportSSE       = "sse"
+	BroadcastTransportWebSocket = "websocket"
+)
+
+// 广播指标
+var (
+	broadcastSubscribersGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_broadcast_subscribers",
+		Help: "Number of connected broadcast subscribers",
+	}, []string{"transport"})
+
+	broadcastMessagesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_broadcast_messages_total",
+		Help: "Total number of messages broadcast to streaming clients",
+	})
+
+	broadcastDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_broadcast_deliveries_total",
+		Help: "Total number of broadcast deliveries by result (queued or dropped because the subscriber queue was full)",
+	}, []string{"transport", "result"})
+)
+
+// BroadcastConfig 广播推送配置
+type BroadcastConfig struct {
+	Path         string        // 订阅接口，默认 /events；?topic=a&topic=b 或 ?topic=a,b 订阅主题
+	AdminPath    string        // 管理推送接口，默认 /admin/broadcast，需由认证 / 授权中间件保护
+	Topics       []string      // 允许订阅的主题，为空不限制；以 * 结尾为前缀匹配
+	BufferSize   int           // 每个订阅者的待发送队列长度，默认 64，满时丢弃新消息
+	PingInterval time.Duration // SSE 注释行 / WebSocket ping 的间隔，默认 30s
+}
+
+// BroadcastFilter 广播筛选条件，字段之间为与、字段内为或，空字段不筛选
+type BroadcastFilter struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// BroadcastResult 一次广播的投递结果
+type BroadcastResult struct {
+	ID        string `json:"id"`
+	Matched   int    `json:"matched"`   // 命中筛选条件的订阅者
+	Delivered int    `json:"delivered"` // 已放入发送队列
+	Dropped   int    `json:"dropped"`   // 队列已满而丢弃
+}
+
+// broadcastEnvelope 发送给客户端的消息
+type broadcastEnvelope struct {
+	ID    string          `json:"id"`
+	Topic string          `json:"topic,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// broadcastSubscriber 一个已连接的订阅者
+type broadcastSubscriber struct {
+	transport string
+	user      string
+	tenant    string
+	topics    []string
+	queue     chan *broadcastEnvelope
+}
+
+// accepts 订阅者是否接收该消息：不带主题的消息发给全部订阅者
+func (s *broadcastSubscriber) accepts(topic string, filter BroadcastFilter) bool {
+	if topic != "" && !slices.Contains(s.topics, topic) {
+		return false
+	}
+	if len(filter.UserIDs) > 0 && !slices.Contains(filter.UserIDs, s.user) {
+		return false
+	}
+	return len(filter.Tenants) == 0 || slices.Contains(filter.Tenants, s.tenant)
+}
+
+// Broadcaster 广播推送器
+type Broadcaster struct {
+	config   BroadcastConfig
+	upgrader websocket.Upgrader
+	seq      atomic.Uint64
+
+	mu          sync.RWMutex
+	subscribers map[*broadcastSubscriber]struct{}
+	closed      bool
+	done        chan struct{}
+}
+
+// NewBroadcaster 校验配置并创建广播推送器
+func NewBroadcaster(cfg BroadcastConfig) (*Broadcaster, error) {
+	if cfg.BufferSize < 0 || cfg.PingInterval < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "broadcast: buffer size and ping interval must not be negative")
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultBroadcastPath
+	}
+	if cfg.AdminPath == "" {
+		cfg.AdminPath = DefaultBroadcastAdminPath
+	}
+	if cfg.Path == cfg.AdminPath {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "broadcast: subscribe path and admin path must differ (%s)", cfg.Path)
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = DefaultBroadcastBufferSize
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = DefaultBroadcastPingInterval
+	}
+	return &Broadcaster{
+		config:      cfg,
+		subscribers: make(map[*broadcastSubscriber]struct{}),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// Path 订阅接口路径
+func (b *Broadcaster) Path() string {
+	return b.config.Path
+}
+
+// AdminPath 管理推送接口路径
+func (b *Broadcaster) AdminPath() string {
+	return b.config.AdminPath
+}
+
+// Subscribers 当前订阅者数量
+func (b *Broadcaster) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Publish 向匹配主题与筛选条件的订阅者推送消息，topic 为空时发给全部订阅者；
+// data 为 json.RawMessage 时校验后发送，其余值编码为 JSON
+func (b *Broadcaster) Publish(topic string, data any, filter BroadcastFilter) (BroadcastResult, *errors.AppError) {
+	if strings.ContainsAny(topic, "\r\n") {
+		return BroadcastResult{}, errors.NewError(errors.ErrCodeInvalidParameter, "broadcast: topic must not contain line breaks")
+	}
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return BroadcastResult{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "broadcast: encode message: %v", err)
+		}
+		raw = encoded
+	}
+	// SSE 的 data 行不能跨行，压缩为单行
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return BroadcastResult{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "broadcast: message is not valid JSON: %v", err)
+	}
+	raw = compact.Bytes()
+	msg := &broadcastEnvelope{ID: strconv.FormatUint(b.seq.Add(1), 10), Topic: topic, Data: raw}
+	result := BroadcastResult{ID: msg.ID}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return result, errors.NewError(errors.ErrCodeServiceUnavailable, "broadcast: broadcaster is closed")
+	}
+	for sub := range b.subscribers {
+		if !sub.accepts(topic, filter) {
+			continue
+		}
+		result.Matched++
+		select {
+		case sub.queue <- msg:
+			result.Delivered++
+			broadcastDeliveriesTotal.WithLabelValues(sub.transport, "queued").Inc()
+		default:
+			result.Dropped++
+			broadcastDeliveriesTotal.WithLabelValues(sub.transport, "dropped").Inc()
+		}
+	}
+	broadcastMessagesTotal.Inc()
+	return result, nil
+}
+
+// Close 断开全部订阅者，之后的订阅与推送被拒绝
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+// subscribe 登记订阅者，推送器已关闭时返回 nil
+func (b *Broadcaster) subscribe(r *http.Request, transport string, topics []string) *broadcastSubscriber {
+	sub := &broadcastSubscriber{transport: transport, topics: topics, queue: make(chan *broadcastEnvelope, b.config.BufferSize)}
+	if p := contextPrincipal(r.Context()); p != nil {
+		sub.user, sub.tenant = p.Subject, p.Tenant
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.subscribers[sub] = struct{}{}
+	broadcastSubscribersGauge.WithLabelValues(transport).Inc()
+	return sub
+}
+
+// unsubscribe 注销订阅者
+func (b *Broadcaster) unsubscribe(sub *broadcastSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+	broadcastSubscribersGauge.WithLabelValues(sub.transport).Dec()
+}
+
+// topics 解析并校验请求订阅的主题
+func (b *Broadcaster) topics(r *http.Request) ([]string, string) {
+	var topics []string
+	for _, value := range r.URL.Query()["topic"] {
+		for _, topic := range strings.Split(value, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" && !slices.Contains(topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	if len(b.config.Topics) == 0 {
+		return topics, ""
+	}
+	for _, topic := range topics {
+		if !webhookEventMatch(b.config.Topics, topic) {
+			return nil, topic
+		}
+	}
+	return topics, ""
+}
+
+// SubscribeHandler 订阅接口：WebSocket 升级请求按 WebSocket 推送 JSON 文本帧，其余请求按 SSE 推送
+// （event 为主题，data 为消息 JSON）
+func (b *Broadcaster) SubscribeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "broadcast subscribe requires GET")
+			return
+		}
+		topics, denied := b.topics(r)
+		if denied != "" {
+			response.WriteForbiddenResult(w, fmt.Sprintf("topic %q is not allowed", denied))
+			return
+		}
+		if websocket.IsWebSocketUpgrade(r) {
+			b.serveWebSocket(w, r, topics)
+			return
+		}
+		b.serveSSE(w, r, topics)
+	}
+}
+
+// serveSSE 以 SSE 推送，直到客户端断开或推送器关闭
+func (b *Broadcaster) serveSSE(w http.ResponseWriter, r *http.Request, topics []string) {
+	rc := http.NewResponseController(w)
+	sub := b.subscribe(r, BroadcastTransportSSE, topics)
+	if sub == nil {
+		response.WriteServiceUnavailableResult(w, "broadcast is shutting down")
+		return
+	}
+	defer b.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	ping := time.NewTicker(b.config.PingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-b.done:
+			_, _ = w.Write([]byte("event: close\ndata: server is shutting down\n\n"))
+			_ = rc.Flush()
+			return
+		case <-ping.C:
+			_, err = w.Write([]byte(": ping\n\n"))
+		case msg := <-sub.queue:
+			event := msg.Topic
+			if event == "" {
+				event = "message"
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, event, msg.Data)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// serveWebSocket 以 WebSocket 推送；读协程只处理控制帧，客户端发来的数据帧被忽略
+func (b *Broadcaster) serveWebSocket(w http.ResponseWriter, r *http.Request, topics []string) {
+	conn, err := b.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已写出错误响应
+		global.LOGGER.DebugKV("broadcast websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	sub := b.subscribe(r, BroadcastTransportWebSocket, topics)
+	if sub == nil {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "broadcast is shutting down"), time.Now().Add(time.Second))
+		return
+	}
+	defer b.unsubscribe(sub)
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(b.config.PingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-gone:
+			return
+		case <-b.done:
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"), time.Now().Add(time.Second))
+			return
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(broadcastWriteTimeout))
+		case msg := <-sub.queue:
+			_ = conn.SetWriteDeadline(time.Now().Add(broadcastWriteTimeout))
+			err = conn.WriteJSON(msg)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// broadcastRequest 管理推送接口请求体
+type broadcastRequest struct {
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+	BroadcastFilter
+}
+
+// AdminHandler 管理推送接口：
+//
+//	GET  {path}  订阅者数量
+//	POST {path}  {"topic":"cache","data":{...},"user_ids":[],"tenants":[]}，返回投递结果
+func (b *Broadcaster) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, map[string]any{"subscribers": b.Subscribers()})
+		case http.MethodPost:
+			var req broadcastRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, broadcastAdminBodyBytes)).Decode(&req); err != nil {
+				response.WriteBadRequestResult(w, "invalid broadcast request: "+err.Error())
+				return
+			}
+			if len(req.Data) == 0 {
+				response.WriteBadRequestResult(w, "broadcast data is required")
+				return
+			}
+			result, appErr := b.Publish(req.Topic, req.Data, req.BroadcastFilter)
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, result)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "broadcast admin supports GET and POST")
+		}
+	}
+}
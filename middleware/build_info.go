@@ -0,0 +1,78 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\build_info.go
+ * @Description: 构建信息对外暴露 - 查询接口、响应头与 build_info 指标
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultBuildInfoPath 构建信息查询接口默认路径
+const DefaultBuildInfoPath = "/admin/info"
+
+// BuildInfoResponse 构建信息查询接口响应
+type BuildInfoResponse struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	global.BuildInfo
+	StartedAt string `json:"started_at"`
+	Uptime    string `json:"uptime"`
+}
+
+// BuildInfoHandler 构建信息查询接口，startedAt 为服务启动时间
+func BuildInfoHandler(startedAt time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := BuildInfoResponse{
+			BuildInfo: global.GetBuildInfo(),
+			StartedAt: startedAt.Format(time.RFC3339),
+			Uptime:    time.Since(startedAt).Truncate(time.Second).String(),
+		}
+		if cfg := global.GATEWAY; cfg != nil {
+			resp.Name, resp.Environment = cfg.Name, cfg.Environment
+		}
+		response.WriteJSONResponse(w, http.StatusOK, resp)
+	}
+}
+
+// BuildInfoHeaderMiddleware 在响应头中附带版本与提交，便于灰度发布时确认请求落在哪个版本
+func BuildInfoHeaderMiddleware() MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := global.GetBuildInfo()
+			if info.Version != "" {
+				w.Header().Set(constants.HeaderXBuildVersion, info.Version)
+			}
+			if info.Commit != "" {
+				w.Header().Set(constants.HeaderXBuildCommit, info.Commit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// registerBuildInfoMetric 注册 gateway_build_info 指标（值恒为 1，构建信息体现在标签上）
+func registerBuildInfoMetric(registry *prometheus.Registry) {
+	info := global.GetBuildInfo()
+	promauto.With(registry).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_build_info",
+			Help: "Build information of the running gateway, value is always 1",
+		},
+		[]string{"version", "commit", "build_time", "go_version"},
+	).WithLabelValues(info.Version, info.Commit, info.BuildTime, info.GoVersion).Set(1)
+}
@@ -0,0 +1,309 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\bulkhead.go
+ * @Description: 上游舱壁隔离 - 每个上游独占一组并发槽位与有界等待队列，槽位占满且队列已满或等待超时时立即拒绝，
+ *               慢上游只会耗尽自己的槽位，不会占满网关的连接与协程拖垮其他上游；
+ *               gRPC 上游通过客户端拦截器、声明式路由上游通过 Transport 接入
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultBulkheadQueueTimeout 排队等待槽位的默认上限
+const DefaultBulkheadQueueTimeout = 100 * time.Millisecond
+
+// 舱壁拒绝原因（指标标签）
+const (
+	bulkheadRejectQueueFull = "queue_full"
+	bulkheadRejectTimeout   = "timeout"
+)
+
+// ErrBulkheadFull 上游的并发槽位已满，调用被舱壁拒绝
+var ErrBulkheadFull = stderrors.New("upstream bulkhead full")
+
+// 舱壁指标（注册到默认 Registry）
+var (
+	bulkheadInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_bulkhead_inflight",
+		Help: "Number of upstream calls currently holding a bulkhead slot",
+	}, []string{"upstream"})
+
+	bulkheadQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_bulkhead_queued",
+		Help: "Number of upstream calls waiting for a bulkhead slot",
+	}, []string{"upstream"})
+
+	bulkheadRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_bulkhead_rejected_total",
+		Help: "Total number of upstream calls rejected by the bulkhead by reason",
+	}, []string{"upstream", "reason"})
+)
+
+// BulkheadLimit 单个上游的舱壁限制
+type BulkheadLimit struct {
+	MaxConcurrent int           `json:"max_concurrent" yaml:"max-concurrent" mapstructure:"max-concurrent"` // 同时进行的调用数上限，0 不限制
+	MaxQueue      int           `json:"max_queue" yaml:"max-queue" mapstructure:"max-queue"`                // 等待槽位的调用数上限，0 不排队（槽位占满立即拒绝）
+	QueueTimeout  time.Duration `json:"queue_timeout" yaml:"queue-timeout" mapstructure:"queue-timeout"`    // 排队等待上限，默认 100ms
+}
+
+// BulkheadConfig 上游舱壁配置
+type BulkheadConfig struct {
+	// Default 未单独配置的上游各自使用一组这样的槽位（不同上游之间仍然隔离），MaxConcurrent 为 0 时不限制
+	Default BulkheadLimit `json:"default" yaml:"default" mapstructure:"default"`
+	// Upstreams 键为服务名（grpc.clients）、RegisterProxyHandler / NewUpstreamPool 的 endpoint 或声明式路由的上游名
+	Upstreams map[string]BulkheadLimit `json:"upstreams" yaml:"upstreams" mapstructure:"upstreams"`
+}
+
+// Bulkheads 编译后的上游舱壁
+type Bulkheads struct {
+	fallback  BulkheadLimit
+	upstreams map[string]*bulkhead
+	derived   sync.Map // 按 Default 创建的上游舱壁，键为上游名
+}
+
+// bulkhead 单个上游的槽位与等待队列
+type bulkhead struct {
+	name   string
+	limit  BulkheadLimit
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+// bulkheads 当前生效的舱壁，未设置时不限制
+var bulkheads atomic.Pointer[Bulkheads]
+
+// NewBulkheads 校验配置并创建舱壁
+func NewBulkheads(cfg BulkheadConfig) (*Bulkheads, error) {
+	fallback, err := normalizeBulkheadLimit("default", cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bulkheads{fallback: fallback, upstreams: make(map[string]*bulkhead, len(cfg.Upstreams))}
+	for name, limit := range cfg.Upstreams {
+		if limit, err = normalizeBulkheadLimit(name, limit); err != nil {
+			return nil, err
+		}
+		b.upstreams[name] = newBulkhead(name, limit)
+	}
+	return b, nil
+}
+
+// normalizeBulkheadLimit 校验并补齐默认值
+func normalizeBulkheadLimit(name string, limit BulkheadLimit) (BulkheadLimit, error) {
+	if limit.MaxConcurrent < 0 || limit.MaxQueue < 0 || limit.QueueTimeout < 0 {
+		return limit, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "bulkhead %q: limits must not be negative", name)
+	}
+	if limit.QueueTimeout == 0 {
+		limit.QueueTimeout = DefaultBulkheadQueueTimeout
+	}
+	return limit, nil
+}
+
+// newBulkhead 创建槽位，MaxConcurrent 为 0 时不限制
+func newBulkhead(name string, limit BulkheadLimit) *bulkhead {
+	b := &bulkhead{name: name, limit: limit}
+	if limit.MaxConcurrent > 0 {
+		b.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return b
+}
+
+// SetBulkheads 设置上游舱壁，nil 关闭；对之后的上游调用立即生效，进行中的调用仍归还到原槽位
+func SetBulkheads(cfg *BulkheadConfig) error {
+	if cfg == nil {
+		bulkheads.Store(nil)
+		return nil
+	}
+	b, err := NewBulkheads(*cfg)
+	if err != nil {
+		return err
+	}
+	bulkheads.Store(b)
+	return nil
+}
+
+// get 上游的舱壁，未单独配置时按 Default 创建
+func (b *Bulkheads) get(upstream string) *bulkhead {
+	if h, ok := b.upstreams[upstream]; ok {
+		return h
+	}
+	if h, ok := b.derived.Load(upstream); ok {
+		return h.(*bulkhead)
+	}
+	h, _ := b.derived.LoadOrStore(upstream, newBulkhead(upstream, b.fallback))
+	return h.(*bulkhead)
+}
+
+// Acquire 占用上游的一个槽位，返回的 release 必须调用且只能调用一次；
+// 槽位占满时按 MaxQueue / QueueTimeout 排队，仍无槽位时返回 ErrBulkheadFull
+func (b *Bulkheads) Acquire(ctx context.Context, upstream string) (func(), error) {
+	return b.get(upstream).acquire(ctx)
+}
+
+// AcquireBulkhead 占用当前生效舱壁中上游的一个槽位，未设置舱壁时直接放行
+func AcquireBulkhead(ctx context.Context, upstream string) (func(), error) {
+	b := bulkheads.Load()
+	if b == nil {
+		return func() {}, nil
+	}
+	return b.Acquire(ctx, upstream)
+}
+
+// acquire 占用槽位
+func (h *bulkhead) acquire(ctx context.Context) (func(), error) {
+	if h.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case h.slots <- struct{}{}:
+		return h.holder(), nil
+	default:
+	}
+
+	if h.queued.Add(1) > int64(h.limit.MaxQueue) {
+		h.queued.Add(-1)
+		bulkheadRejected.WithLabelValues(h.name, bulkheadRejectQueueFull).Inc()
+		return nil, ErrBulkheadFull
+	}
+	bulkheadQueued.WithLabelValues(h.name).Inc()
+	defer func() {
+		h.queued.Add(-1)
+		bulkheadQueued.WithLabelValues(h.name).Dec()
+	}()
+
+	timer := time.NewTimer(h.limit.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case h.slots <- struct{}{}:
+		return h.holder(), nil
+	case <-timer.C:
+		bulkheadRejected.WithLabelValues(h.name, bulkheadRejectTimeout).Inc()
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// holder 记录占用并返回只生效一次的归还函数
+func (h *bulkhead) holder() func() {
+	inflight := bulkheadInflight.WithLabelValues(h.name)
+	inflight.Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-h.slots
+			inflight.Dec()
+		})
+	}
+}
+
+// bulkheadStatus 舱壁拒绝转换为 ResourceExhausted，排队期间 ctx 结束转换为对应的 Canceled / DeadlineExceeded
+func bulkheadStatus(upstream string, err error) error {
+	if stderrors.Is(err, ErrBulkheadFull) {
+		return status.Errorf(codes.ResourceExhausted, "upstream %s is saturated", upstream)
+	}
+	return status.FromContextError(err).Err()
+}
+
+// UnaryClientBulkheadInterceptor gRPC 客户端一元调用舱壁拦截器，upstream 为选择配置的上游名
+func UnaryClientBulkheadInterceptor(upstream string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		release, err := AcquireBulkhead(ctx, upstream)
+		if err != nil {
+			return bulkheadStatus(upstream, err)
+		}
+		defer release()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientBulkheadInterceptor gRPC 客户端流式调用舱壁拦截器：流结束（接收出错 / EOF）或 ctx 结束时归还槽位
+func StreamClientBulkheadInterceptor(upstream string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		release, err := AcquireBulkhead(ctx, upstream)
+		if err != nil {
+			return nil, bulkheadStatus(upstream, err)
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		stop := context.AfterFunc(stream.Context(), release)
+		return &bulkheadClientStream{ClientStream: stream, release: func() {
+			stop()
+			release()
+		}}, nil
+	}
+}
+
+// bulkheadClientStream 接收结束时归还槽位的 ClientStream
+type bulkheadClientStream struct {
+	grpc.ClientStream
+	release func()
+}
+
+func (s *bulkheadClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.release()
+	}
+	return err
+}
+
+// BulkheadDialOptions 上游连接的舱壁 dial options
+func BulkheadDialOptions(upstream string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientBulkheadInterceptor(upstream)),
+		grpc.WithChainStreamInterceptor(StreamClientBulkheadInterceptor(upstream)),
+	}
+}
+
+// bulkheadRoundTrip 声明式路由上游请求占用槽位，响应体关闭时归还
+func bulkheadRoundTrip(upstream string, r *http.Request, roundTrip func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	release, err := AcquireBulkhead(r.Context(), upstream)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", upstream, err)
+	}
+	resp, err := roundTrip(r)
+	// 协议升级的响应体需保持 io.ReadWriteCloser，不包装，升级后的长连接不占用槽位
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		release()
+		return resp, err
+	}
+	resp.Body = &bulkheadBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// bulkheadBody 关闭时归还槽位的响应体
+type bulkheadBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *bulkheadBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,348 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\cache_control.go
+ * @Description: CDN 缓存指令 - 按路由为响应设置 Cache-Control、Surrogate-Control 与 surrogate key，
+ *               数据变更时通过 Purge 让 CDN 按 key 或 URL 失效缓存
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	DefaultCDNPurgeTimeout = 10 * time.Second
+	DefaultCDNPurgePath    = "/admin/cdn/purge"
+)
+
+// cacheableStatuses 默认附加缓存指令的状态码（RFC 9110 中默认可缓存的状态码）
+var cacheableStatuses = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusPartialContent,
+	http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+	http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone, http.StatusRequestURITooLong,
+	http.StatusNotImplemented,
+}
+
+// CDN 清除指标
+var cdnPurgesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_cdn_purges_total",
+	Help: "Total number of CDN purge requests by provider and result",
+}, []string{"provider", "result"})
+
+// CacheRule 单条路由的缓存指令
+type CacheRule struct {
+	Paths            []string                       // 路径，以 * 结尾表示前缀匹配
+	Methods          []string                       // 生效的方法，默认 GET、HEAD
+	CacheControl     string                         // 浏览器与共享缓存的 Cache-Control，如 "public, max-age=60"
+	SurrogateControl string                         // 仅 CDN 使用的 Surrogate-Control（Fastly / Akamai），CDN 会在返回前移除
+	CDNCacheControl  string                         // 仅 CDN 使用的 CDN-Cache-Control（Cloudflare 等，RFC 9213）
+	SurrogateKeys    []string                       // 固定的 surrogate key
+	KeyFunc          func(r *http.Request) []string // 按请求生成 surrogate key，如资源 ID
+	Vary             []string                       // 追加的 Vary 头
+	Statuses         []int                          // 附加指令的状态码，默认 RFC 9110 可缓存状态码
+	Override         bool                           // 上游已设置 Cache-Control 时仍覆盖，默认保留上游的值
+}
+
+// cacheRule 编译后的缓存规则
+type cacheRule struct {
+	CacheRule
+	statuses map[int]struct{}
+}
+
+// CacheControlConfig CDN 缓存指令配置
+type CacheControlConfig struct {
+	Rules              []CacheRule
+	SurrogateKeyHeader string        // surrogate key 响应头，默认 Surrogate-Key（Fastly），Cloudflare 使用 Cache-Tag
+	Purgers            []CDNPurger   // 清除请求发送到的 CDN
+	PurgeTimeout       time.Duration // 单次清除超时，默认 10s
+	PurgePath          string        // 清除接口路径，为空不注册；接口本身需由认证 / 授权中间件保护
+}
+
+// CDNPurgeRequest 清除请求，按 surrogate key 或完整 URL 清除
+type CDNPurgeRequest struct {
+	Keys []string `json:"keys,omitempty"`
+	URLs []string `json:"urls,omitempty"`
+}
+
+// CDNPurgeResult 单个 CDN 的清除结果
+type CDNPurgeResult struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CDNPurger CDN 清除接口
+type CDNPurger interface {
+	Name() string
+	Purge(ctx context.Context, req CDNPurgeRequest) error
+}
+
+// CacheDirectives 按路由附加缓存指令并向 CDN 发送清除请求
+type CacheDirectives struct {
+	config CacheControlConfig
+	routes *RouteTable
+}
+
+// currentCacheDirectives 供 PurgeCDN 使用的当前实例
+var currentCacheDirectives atomic.Pointer[CacheDirectives]
+
+// NewCacheDirectives 校验配置并创建缓存指令
+func NewCacheDirectives(cfg CacheControlConfig) (*CacheDirectives, error) {
+	if cfg.PurgeTimeout < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "cdn purge timeout must not be negative")
+	}
+	if cfg.PurgeTimeout == 0 {
+		cfg.PurgeTimeout = DefaultCDNPurgeTimeout
+	}
+	if cfg.SurrogateKeyHeader == "" {
+		cfg.SurrogateKeyHeader = constants.HeaderSurrogateKey
+	}
+
+	var patterns []RoutePattern
+	for i := range cfg.Rules {
+		rule := &cacheRule{CacheRule: cfg.Rules[i]}
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cache rule %d has no paths", i)
+		}
+		if rule.CacheControl == "" && rule.SurrogateControl == "" && rule.CDNCacheControl == "" &&
+			len(rule.SurrogateKeys) == 0 && rule.KeyFunc == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cache rule for %v sets no directives", rule.Paths)
+		}
+		methods := rule.Methods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet, http.MethodHead}
+		}
+		statuses := rule.Statuses
+		if len(statuses) == 0 {
+			statuses = cacheableStatuses
+		}
+		rule.statuses = make(map[int]struct{}, len(statuses))
+		for _, code := range statuses {
+			rule.statuses[code] = struct{}{}
+		}
+		for _, path := range rule.Paths {
+			p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Methods: methods, Value: rule}
+			if prefix, ok := strings.CutSuffix(path, "*"); ok {
+				p.Kind, p.Pattern = RouteMatchPrefix, prefix
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	return &CacheDirectives{config: cfg, routes: NewRouteTable(patterns)}, nil
+}
+
+// SetCurrentCacheDirectives 设置 PurgeCDN 使用的实例，nil 清除
+func SetCurrentCacheDirectives(c *CacheDirectives) {
+	currentCacheDirectives.Store(c)
+}
+
+// PurgeCDN 使用当前配置的 CDN 清除缓存，未配置时返回错误
+func PurgeCDN(ctx context.Context, req CDNPurgeRequest) ([]CDNPurgeResult, error) {
+	c := currentCacheDirectives.Load()
+	if c == nil {
+		return nil, errors.NewError(errors.ErrCodeServiceUnavailable, "cdn cache control is not configured")
+	}
+	return c.Purge(ctx, req)
+}
+
+// Handle 命中规则的请求在写响应头时附加缓存指令，未命中时原样放行
+func (c *CacheDirectives) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	value, ok := c.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	cw := &cacheHeaderWriter{ResponseWriter: w, apply: func(status int) {
+		c.apply(value.(*cacheRule), w.Header(), r, status)
+	}}
+	next.ServeHTTP(cw, r)
+}
+
+// HTTPMiddleware 缓存指令中间件
+func (c *CacheDirectives) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Handle(w, r, next)
+	})
+}
+
+// apply 按状态码写入缓存指令；上游已给出 Cache-Control 时除非 Override 否则保留，surrogate key 与上游的合并
+func (c *CacheDirectives) apply(rule *cacheRule, h http.Header, r *http.Request, status int) {
+	if _, ok := rule.statuses[status]; !ok {
+		return
+	}
+	setDirective := func(name, value string) {
+		if value != "" && (rule.Override || h.Get(name) == "") {
+			h.Set(name, value)
+		}
+	}
+	setDirective(constants.HeaderCacheControl, rule.CacheControl)
+	setDirective(constants.HeaderSurrogateControl, rule.SurrogateControl)
+	setDirective(constants.HeaderCDNCacheControl, rule.CDNCacheControl)
+
+	keys := slices.Clone(rule.SurrogateKeys)
+	if rule.KeyFunc != nil {
+		keys = append(keys, rule.KeyFunc(r)...)
+	}
+	if len(keys) > 0 {
+		c.addSurrogateKeys(h, keys)
+	}
+	for _, v := range rule.Vary {
+		if !slices.ContainsFunc(h.Values(constants.HeaderVary), func(existing string) bool {
+			return strings.EqualFold(existing, v)
+		}) {
+			h.Add(constants.HeaderVary, v)
+		}
+	}
+}
+
+// addSurrogateKeys 合并 surrogate key：Surrogate-Key 以空格分隔，Cache-Tag 以逗号分隔
+func (c *CacheDirectives) addSurrogateKeys(h http.Header, keys []string) {
+	name := c.config.SurrogateKeyHeader
+	sep := " "
+	if strings.EqualFold(name, constants.HeaderCacheTag) {
+		sep = ","
+	}
+	existing := strings.FieldsFunc(h.Get(name), func(r rune) bool { return r == ' ' || r == ',' })
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key != "" && !slices.Contains(existing, key) {
+			existing = append(existing, key)
+		}
+	}
+	if len(existing) > 0 {
+		h.Set(name, strings.Join(existing, sep))
+	}
+}
+
+// Purge 并发向全部 CDN 发送清除请求，返回各 CDN 的结果；任一失败时同时返回错误
+func (c *CacheDirectives) Purge(ctx context.Context, req CDNPurgeRequest) ([]CDNPurgeResult, error) {
+	req.Keys = slices.DeleteFunc(slices.Clone(req.Keys), func(s string) bool { return strings.TrimSpace(s) == "" })
+	req.URLs = slices.DeleteFunc(slices.Clone(req.URLs), func(s string) bool { return strings.TrimSpace(s) == "" })
+	if len(req.Keys) == 0 && len(req.URLs) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "cdn purge requires keys or urls")
+	}
+	if len(c.config.Purgers) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "no cdn purgers configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.PurgeTimeout)
+	defer cancel()
+
+	results := make([]CDNPurgeResult, len(c.config.Purgers))
+	var wg sync.WaitGroup
+	for i, purger := range c.config.Purgers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Provider = purger.Name()
+			if err := purger.Purge(ctx, req); err != nil {
+				results[i].Error = err.Error()
+				cdnPurgesTotal.WithLabelValues(purger.Name(), "failure").Inc()
+				global.LOGGER.WarnKV("CDN 缓存清除失败", "provider", purger.Name(), "keys", req.Keys, "urls", req.URLs, "error", err)
+				return
+			}
+			cdnPurgesTotal.WithLabelValues(purger.Name(), "success").Inc()
+		}()
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, res := range results {
+		if res.Error != "" {
+			failed = append(failed, res.Provider+": "+res.Error)
+		}
+	}
+	global.LOGGER.InfoKV("CDN 缓存清除", "keys", req.Keys, "urls", req.URLs, "providers", len(results), "failed", len(failed))
+	if len(failed) > 0 {
+		return results, errors.NewErrorf(errors.ErrCodeOperationFailed, "cdn purge failed: %s", strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+// PurgeHandler 清除接口：POST 请求体 CDNPurgeRequest，全部成功返回 200，部分失败返回 502 与各 CDN 结果
+func (c *CacheDirectives) PurgeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set(constants.HeaderAllow, http.MethodPost)
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "use POST to purge cdn cache")
+			return
+		}
+		var req CDNPurgeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			response.WriteBadRequestResult(w, "invalid purge request: "+err.Error())
+			return
+		}
+		results, err := c.Purge(r.Context(), req)
+		switch {
+		case err != nil && results == nil:
+			response.WriteBadRequestResult(w, err.Error())
+		case err != nil:
+			response.WriteJSONResponse(w, http.StatusBadGateway, results)
+		default:
+			response.WriteJSONResponse(w, http.StatusOK, results)
+		}
+	}
+}
+
+// cacheHeaderWriter 在写出响应头前附加缓存指令
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	apply       func(status int)
+	wroteHeader bool
+}
+
+// WriteHeader 附加缓存指令后写出状态码
+func (w *cacheHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		w.apply(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write 未显式写状态码时按 200 附加
+func (w *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 支持流式响应
+func (w *cacheHeaderWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack 支持 WebSocket 升级
+func (w *cacheHeaderWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *cacheHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,208 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\cache_key.go
+ * @Description: 缓存键规范化 - 查询参数排序、噪声参数（utm_* 等）剔除、主机名小写与默认端口去除、请求头名称规范化，
+ *               语义相同的请求得到相同的键，供决策缓存、幂等与响应缓存使用
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultCacheKeyNoiseParams 常见的不影响响应内容的跟踪参数
+var DefaultCacheKeyNoiseParams = []string{"utm_*", "gclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga", "spm"}
+
+// CacheKeyConfig 缓存键规范化配置，零值只对查询参数排序
+// 参数名匹配区分大小写，以 * 开头或结尾表示后缀 / 前缀匹配
+type CacheKeyConfig struct {
+	IgnoreParams []string // 剔除的查询参数，如 DefaultCacheKeyNoiseParams
+	KeepParams   []string // 非空时只保留这些查询参数（在 IgnoreParams 之后生效）
+	Headers      []string // 参与键的请求头，名称大小写不敏感
+	IncludeHost  bool     // 键中包含主机名（小写，去除默认端口与末尾的点）
+	IgnoreMethod bool     // 键中不包含方法（HEAD 与 GET 共用缓存时使用）
+}
+
+// CacheKeyInput 构建缓存键的请求属性
+type CacheKeyInput struct {
+	Method   string
+	Scheme   string // 用于识别默认端口，为空按 http 处理
+	Host     string
+	Path     string
+	RawQuery string
+	Header   func(name string) string // 读取请求头，参数为规范化后的名称，nil 表示无请求头
+}
+
+// CacheKeyBuilder 编译后的缓存键构建器，可并发使用
+type CacheKeyBuilder struct {
+	config  CacheKeyConfig
+	ignore  paramMatcher
+	keep    paramMatcher
+	headers []string // 规范化并排序后的请求头名称
+}
+
+// paramMatcher 查询参数名匹配
+type paramMatcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+	suffixes []string
+}
+
+// newParamMatcher 编译参数名列表
+func newParamMatcher(names []string) paramMatcher {
+	m := paramMatcher{exact: make(map[string]struct{}, len(names))}
+	for _, name := range names {
+		switch {
+		case name == "":
+		case strings.HasSuffix(name, "*"):
+			m.prefixes = append(m.prefixes, strings.TrimSuffix(name, "*"))
+		case strings.HasPrefix(name, "*"):
+			m.suffixes = append(m.suffixes, strings.TrimPrefix(name, "*"))
+		default:
+			m.exact[name] = struct{}{}
+		}
+	}
+	return m
+}
+
+// empty 是否未配置
+func (m *paramMatcher) empty() bool {
+	return len(m.exact) == 0 && len(m.prefixes) == 0 && len(m.suffixes) == 0
+}
+
+// match 参数名是否命中
+func (m *paramMatcher) match(name string) bool {
+	if _, ok := m.exact[name]; ok {
+		return true
+	}
+	for _, p := range m.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	for _, s := range m.suffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCacheKeyBuilder 创建缓存键构建器
+func NewCacheKeyBuilder(cfg CacheKeyConfig) *CacheKeyBuilder {
+	b := &CacheKeyBuilder{
+		config: cfg,
+		ignore: newParamMatcher(cfg.IgnoreParams),
+		keep:   newParamMatcher(cfg.KeepParams),
+	}
+	for _, name := range cfg.Headers {
+		if name = strings.TrimSpace(name); name != "" {
+			b.headers = append(b.headers, http.CanonicalHeaderKey(name))
+		}
+	}
+	slices.Sort(b.headers)
+	b.headers = slices.Compact(b.headers)
+	return b
+}
+
+// Key 按规范化规则构建缓存键，格式为 "METHOD host/path?query" 后接每个请求头一行 "Name: value"
+func (b *CacheKeyBuilder) Key(in CacheKeyInput) string {
+	var sb strings.Builder
+	if !b.config.IgnoreMethod {
+		sb.WriteString(strings.ToUpper(in.Method))
+		sb.WriteByte(' ')
+	}
+	if b.config.IncludeHost {
+		sb.WriteString(NormalizeHost(in.Host, in.Scheme))
+	}
+	path := in.Path
+	if path == "" {
+		path = "/"
+	}
+	sb.WriteString(path)
+	if query := b.NormalizeQuery(in.RawQuery); query != "" {
+		sb.WriteByte('?')
+		sb.WriteString(query)
+	}
+	for _, name := range b.headers {
+		value := ""
+		if in.Header != nil {
+			value = strings.TrimSpace(in.Header(name))
+		}
+		sb.WriteByte('\n')
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+// RequestKey 使用 HTTP 请求构建缓存键，多值请求头以逗号连接
+func (b *CacheKeyBuilder) RequestKey(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return b.Key(CacheKeyInput{
+		Method:   r.Method,
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+		Header: func(name string) string {
+			return strings.Join(r.Header.Values(name), ",")
+		},
+	})
+}
+
+// HashedKey 缓存键的 SHA-256 摘要（十六进制），适合作为外部存储的键
+func (b *CacheKeyBuilder) HashedKey(in CacheKeyInput) string {
+	sum := sha256.Sum256([]byte(b.Key(in)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeQuery 剔除噪声参数后按参数名排序并重新编码；同名参数保留原有顺序（重复字段的顺序可能有意义）
+func (b *CacheKeyBuilder) NormalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, _ := url.ParseQuery(rawQuery) // 无法解析的片段丢弃，其余参数照常参与
+	for name := range values {
+		if b.ignore.match(name) || (!b.keep.empty() && !b.keep.match(name)) {
+			delete(values, name)
+		}
+	}
+	return values.Encode()
+}
+
+// NormalizeHost 主机名小写，去除末尾的点与 scheme 的默认端口
+func NormalizeHost(host, scheme string) string {
+	host = strings.ToLower(host)
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return strings.TrimSuffix(host, ".")
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	if (port == "80" && scheme != "https") || (port == "443" && scheme == "https") {
+		port = ""
+	}
+	if port == "" {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]"
+		}
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
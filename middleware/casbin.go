@@ -0,0 +1,379 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\casbin.go
+ * @Description: Casbin 授权适配 - 路由映射为 (obj, act) 后交给 casbin 执行器判定，业务代码可直接调用
+ *               CasbinEnforce；支持策略定时重新加载与策略增删查管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCasbinAdminPath Casbin 策略管理接口默认路径
+const DefaultCasbinAdminPath = "/admin/casbin"
+
+// CasbinAnonymous 未识别用户时的 casbin subject
+const CasbinAnonymous = "anonymous"
+
+// CasbinEnforcer 网关使用的 casbin 执行器方法
+// casbin v2.100+ 的 *casbin.SyncedEnforcer / *casbin.SyncedCachedEnforcer 直接满足；
+// 策略从文件加载时使用 casbin.NewSyncedEnforcer("model.conf", "policy.csv")，
+// 从数据库加载时使用 gorm-adapter：gormadapter.NewAdapterByDB(global.DB)
+type CasbinEnforcer interface {
+	Enforce(rvals ...any) (bool, error)
+	LoadPolicy() error
+	GetPolicy() ([][]string, error)
+	GetGroupingPolicy() ([][]string, error)
+	AddPolicy(params ...any) (bool, error)
+	RemovePolicy(params ...any) (bool, error)
+	AddGroupingPolicy(params ...any) (bool, error)
+	RemoveGroupingPolicy(params ...any) (bool, error)
+}
+
+// CasbinRoute 路由到 casbin (obj, act) 的映射
+type CasbinRoute struct {
+	Method string // HTTP 方法，为空匹配全部；gRPC 调用按 POST 匹配
+	Path   string // HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+	Object string // casbin obj，为空时使用请求路径
+	Action string // casbin act，为空时使用请求方法
+}
+
+// CasbinConfig Casbin 授权配置
+type CasbinConfig struct {
+	Enforcer CasbinEnforcer // casbin 执行器，必填
+
+	Routes          []CasbinRoute // 路由映射，多条命中时取靠前的一条
+	EnforceUnmapped bool          // 未映射的路由也做授权（obj 为路径，act 为方法）；默认放行
+	SkipPaths       []string      // 不做授权的路径或 gRPC 方法，以 * 结尾表示前缀匹配
+
+	Subject func(ctx context.Context) string // 获取 subject，默认请求上下文中的用户 ID，为空时为 anonymous
+	Domain  func(ctx context.Context) string // 获取 domain（多租户模型 sub, dom, obj, act），为空不传 domain
+
+	ReloadInterval time.Duration // 定时重新加载策略（多实例共享数据库策略时使用），0 不重新加载
+	AdminPath      string        // 策略管理接口路径，为空不注册；接口本身需由认证 / 授权中间件保护
+}
+
+// casbinTarget 路由映射结果
+type casbinTarget struct {
+	object string
+	action string
+}
+
+// Casbin 编译后的 Casbin 授权处理器
+type Casbin struct {
+	config    CasbinConfig
+	routes    *RouteTable
+	skip      *RouteTable
+	mu        sync.Mutex // 串行化 LoadPolicy 与策略修改
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// currentCasbin 供 CasbinEnforce 使用的当前 Casbin 处理器
+var currentCasbin atomic.Pointer[Casbin]
+
+// NewCasbin 校验配置并创建 Casbin 授权处理器
+func NewCasbin(cfg CasbinConfig) (*Casbin, error) {
+	if cfg.Enforcer == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "casbin requires an enforcer")
+	}
+	if cfg.ReloadInterval < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "casbin reload interval must not be negative")
+	}
+	if cfg.Subject == nil {
+		cfg.Subject = defaultCasbinSubject
+	}
+
+	routes := make([]RoutePattern, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		if route.Path == "" {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "casbin route path is required")
+		}
+		p := RoutePattern{Kind: RouteMatchExact, Pattern: route.Path, Value: casbinTarget{object: route.Object, action: route.Action}}
+		if route.Method != "" {
+			p.Methods = []string{route.Method}
+		}
+		if prefix, ok := strings.CutSuffix(route.Path, "*"); ok {
+			p.Kind, p.Pattern = RouteMatchPrefix, prefix
+		}
+		routes = append(routes, p)
+	}
+	skip := make([]RoutePattern, 0, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Value: true}
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			p.Kind, p.Pattern = RouteMatchPrefix, prefix
+		}
+		skip = append(skip, p)
+	}
+
+	return &Casbin{config: cfg, routes: NewRouteTable(routes), skip: NewRouteTable(skip)}, nil
+}
+
+// defaultCasbinSubject 请求上下文中的用户 ID
+func defaultCasbinSubject(ctx context.Context) string {
+	if userID := GetUserID(ctx); userID != "" {
+		return userID
+	}
+	return CasbinAnonymous
+}
+
+// SetCurrentCasbin 设置 CasbinEnforce 使用的处理器，nil 清除
+func SetCurrentCasbin(c *Casbin) {
+	currentCasbin.Store(c)
+}
+
+// CasbinEnforce 以请求上下文中的 subject（及 domain）判定 (obj, act)，未配置 Casbin 时返回错误
+func CasbinEnforce(ctx context.Context, obj, act string) (bool, error) {
+	c := currentCasbin.Load()
+	if c == nil {
+		return false, errors.NewError(errors.ErrCodeServiceUnavailable, "casbin is not configured")
+	}
+	return c.Enforce(ctx, obj, act)
+}
+
+// Enforce 以请求上下文中的 subject（及 domain）判定 (obj, act)
+func (c *Casbin) Enforce(ctx context.Context, obj, act string) (bool, error) {
+	sub := c.config.Subject(ctx)
+	if c.config.Domain != nil {
+		return c.config.Enforcer.Enforce(sub, c.config.Domain(ctx), obj, act)
+	}
+	return c.config.Enforcer.Enforce(sub, obj, act)
+}
+
+// resolve 映射请求到 (obj, act)，ok 为 false 表示不需要授权
+func (c *Casbin) resolve(method, path string) (obj, act string, ok bool) {
+	if _, skip := c.skip.Match(method, path); skip {
+		return "", "", false
+	}
+	value, matched := c.routes.Match(method, path)
+	if !matched {
+		if !c.config.EnforceUnmapped {
+			return "", "", false
+		}
+		return path, method, true
+	}
+	target := value.(casbinTarget)
+	obj, act = target.object, target.action
+	if obj == "" {
+		obj = path
+	}
+	if act == "" {
+		act = method
+	}
+	return obj, act, true
+}
+
+// Handle HTTP 请求授权，拒绝返回 403，执行器出错返回 500；签名 URL 请求不做判定
+func (c *Casbin) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	obj, act, ok := c.resolve(r.Method, r.URL.Path)
+	if !ok || IsSignedURLRequest(r.Context()) {
+		next.ServeHTTP(w, r)
+		return
+	}
+	allowed, err := c.Enforce(r.Context(), obj, act)
+	if err != nil {
+		global.LOGGER.WarnContextKV(r.Context(), "casbin enforce failed", "obj", obj, "act", act, "error", err)
+		response.WriteAppError(w, errors.NewError(errors.ErrCodeInternalServerError, "authorization check failed"))
+		return
+	}
+	if !allowed {
+		response.WriteErrorResponseWithCode(w, http.StatusForbidden, constants.AuthzErrorCodeDenied, constants.AuthzErrorDenied)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// HTTPMiddleware HTTP Casbin 授权中间件
+func (c *Casbin) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Handle(w, r, next)
+	})
+}
+
+// checkGRPC gRPC 调用授权，按 POST + 完整方法名映射
+func (c *Casbin) checkGRPC(ctx context.Context, fullMethod string) error {
+	obj, act, ok := c.resolve(http.MethodPost, fullMethod)
+	if !ok {
+		return nil
+	}
+	allowed, err := c.Enforce(ctx, obj, act)
+	if err != nil {
+		global.LOGGER.WarnContextKV(ctx, "casbin enforce failed", "obj", obj, "act", act, "error", err)
+		return status.Error(codes.Internal, "authorization check failed")
+	}
+	if !allowed {
+		return status.Error(codes.PermissionDenied, constants.AuthzErrorDenied)
+	}
+	return nil
+}
+
+// Unary gRPC 一元调用授权
+func (c *Casbin) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := c.checkGRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream gRPC 流式调用授权（建立流时判定一次）
+func (c *Casbin) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := c.checkGRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// ReloadPolicy 从存储重新加载策略
+func (c *Casbin) ReloadPolicy() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config.Enforcer.LoadPolicy()
+}
+
+// StartReload 按 ReloadInterval 定时重新加载策略，ctx 结束或 Close 后停止
+func (c *Casbin) StartReload(ctx context.Context) {
+	if c.config.ReloadInterval <= 0 {
+		return
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(c.config.ReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.ReloadPolicy(); err != nil {
+					global.LOGGER.WarnKV("casbin 策略重新加载失败", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Close 停止定时重新加载
+func (c *Casbin) Close() {
+	c.closeOnce.Do(func() {
+		if c.cancel != nil {
+			c.cancel()
+		}
+	})
+}
+
+// CasbinPolicyRequest 策略管理接口请求体
+type CasbinPolicyRequest struct {
+	Type string   `json:"type"` // p（权限策略，默认）或 g（角色继承）
+	Rule []string `json:"rule"` // 策略字段，如 ["alice", "/v1/orders", "GET"]
+}
+
+// CasbinPoliciesResponse 策略查询结果
+type CasbinPoliciesResponse struct {
+	Policies         [][]string `json:"policies"`
+	GroupingPolicies [][]string `json:"grouping_policies"`
+}
+
+// AdminHandler 策略管理接口：
+// GET {path}/policies 查询，POST 添加，DELETE 删除（请求体 CasbinPolicyRequest），POST {path}/reload 重新加载
+// 使用带 AutoSave 的 adapter 时修改会同步写入存储
+func (c *Casbin) AdminHandler(path string) http.HandlerFunc {
+	path = strings.TrimSuffix(path, "/")
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == path+"/reload" && r.Method == http.MethodPost:
+			if err := c.ReloadPolicy(); err != nil {
+				response.WriteAppError(w, errors.NewErrorf(errors.ErrCodeInternalServerError, "reload casbin policy: %v", err))
+				return
+			}
+			response.WriteSuccessResult(w, "casbin policy reloaded")
+		case r.URL.Path == path+"/policies" && r.Method == http.MethodGet:
+			c.writePolicies(w)
+		case r.URL.Path == path+"/policies" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+			c.modifyPolicy(w, r)
+		default:
+			response.WriteNotFoundResult(w, "unknown casbin admin endpoint")
+		}
+	}
+}
+
+// writePolicies 输出全部策略
+func (c *Casbin) writePolicies(w http.ResponseWriter) {
+	c.mu.Lock()
+	policies, err := c.config.Enforcer.GetPolicy()
+	var grouping [][]string
+	if err == nil {
+		grouping, err = c.config.Enforcer.GetGroupingPolicy()
+	}
+	c.mu.Unlock()
+	if err != nil {
+		response.WriteAppError(w, errors.NewErrorf(errors.ErrCodeInternalServerError, "list casbin policy: %v", err))
+		return
+	}
+	response.WriteJSONResponse(w, http.StatusOK, CasbinPoliciesResponse{Policies: policies, GroupingPolicies: grouping})
+}
+
+// modifyPolicy 添加或删除一条策略
+func (c *Casbin) modifyPolicy(w http.ResponseWriter, r *http.Request) {
+	var req CasbinPolicyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		response.WriteBadRequestResult(w, "invalid casbin policy request: "+err.Error())
+		return
+	}
+	if len(req.Rule) == 0 {
+		response.WriteBadRequestResult(w, "casbin policy rule is required")
+		return
+	}
+	params := make([]any, len(req.Rule))
+	for i, field := range req.Rule {
+		params[i] = field
+	}
+
+	enforcer := c.config.Enforcer
+	var op func(...any) (bool, error)
+	switch {
+	case req.Type == "g" && r.Method == http.MethodPost:
+		op = enforcer.AddGroupingPolicy
+	case req.Type == "g":
+		op = enforcer.RemoveGroupingPolicy
+	case req.Type != "" && req.Type != "p":
+		response.WriteBadRequestResult(w, "casbin policy type must be p or g")
+		return
+	case r.Method == http.MethodPost:
+		op = enforcer.AddPolicy
+	default:
+		op = enforcer.RemovePolicy
+	}
+
+	c.mu.Lock()
+	changed, err := op(params...)
+	c.mu.Unlock()
+	if err != nil {
+		response.WriteAppError(w, errors.NewErrorf(errors.ErrCodeInternalServerError, "update casbin policy: %v", err))
+		return
+	}
+	global.LOGGER.InfoContextKV(r.Context(), "casbin 策略已修改",
+		"method", r.Method, "type", req.Type, "rule", req.Rule, "changed", changed)
+	response.WriteJSONResponse(w, http.StatusOK, map[string]bool{"changed": changed})
+}
@@ -0,0 +1,248 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\cdn_purgers.go
+ * @Description: 内置 CDN 清除实现 - Fastly（surrogate key / URL）、Cloudflare（Cache-Tag / 文件）与通用 webhook
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+const (
+	DefaultFastlyEndpoint     = "https://api.fastly.com"
+	DefaultCloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+	fastlyMaxKeysPerRequest     = 256 // Fastly 单次批量清除的 key 上限
+	cloudflareMaxTagsPerRequest = 100
+	cloudflareMaxURLsPerRequest = 30
+	cdnMaxResponseBody          = 16 << 10
+)
+
+// FastlyPurgerConfig Fastly 清除配置
+type FastlyPurgerConfig struct {
+	ServiceID string       // Fastly 服务 ID
+	Token     string       // API token（需要 purge_select 权限）
+	SoftPurge bool         // 软清除：标记过期而非删除，回源失败时仍可返回旧内容
+	Endpoint  string       // API 地址，默认 https://api.fastly.com
+	Client    *http.Client // HTTP 客户端，默认 http.DefaultClient（超时由 PurgeTimeout 控制）
+}
+
+// CloudflarePurgerConfig Cloudflare 清除配置，按 key 清除需要响应头使用 Cache-Tag
+type CloudflarePurgerConfig struct {
+	ZoneID   string       // Zone ID
+	Token    string       // API token（需要 Cache Purge 权限）
+	Endpoint string       // API 地址，默认 https://api.cloudflare.com/client/v4
+	Client   *http.Client // HTTP 客户端，默认 http.DefaultClient
+}
+
+// WebhookPurgerConfig 通用 webhook 清除配置：以 JSON 发送 CDNPurgeRequest，2xx 视为成功
+type WebhookPurgerConfig struct {
+	Name    string            // 指标与日志中的名称，默认 webhook
+	URL     string            // webhook 地址
+	Headers map[string]string // 附加请求头（如认证）
+	Client  *http.Client      // HTTP 客户端，默认 http.DefaultClient
+}
+
+// fastlyPurger Fastly 清除
+type fastlyPurger struct {
+	config FastlyPurgerConfig
+}
+
+// NewFastlyPurger 创建 Fastly 清除器：key 通过 /service/{id}/purge 批量清除，URL 逐个清除
+func NewFastlyPurger(cfg FastlyPurgerConfig) (CDNPurger, error) {
+	if cfg.ServiceID == "" || cfg.Token == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "fastly purger requires service id and token")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultFastlyEndpoint
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &fastlyPurger{config: cfg}, nil
+}
+
+// Name 实现 CDNPurger
+func (p *fastlyPurger) Name() string {
+	return "fastly"
+}
+
+// Purge 实现 CDNPurger
+func (p *fastlyPurger) Purge(ctx context.Context, req CDNPurgeRequest) error {
+	headers := map[string]string{constants.HeaderFastlyKey: p.config.Token}
+	if p.config.SoftPurge {
+		headers[constants.HeaderFastlySoftPurge] = "1"
+	}
+	for _, keys := range chunkStrings(req.Keys, fastlyMaxKeysPerRequest) {
+		batch := map[string]string{constants.HeaderSurrogateKey: strings.Join(keys, " ")}
+		for k, v := range headers {
+			batch[k] = v
+		}
+		endpoint := p.config.Endpoint + "/service/" + url.PathEscape(p.config.ServiceID) + "/purge"
+		if _, err := cdnPost(ctx, p.config.Client, endpoint, batch, nil); err != nil {
+			return err
+		}
+	}
+	for _, rawURL := range req.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "fastly purge requires absolute url, got %q", rawURL)
+		}
+		endpoint := p.config.Endpoint + "/purge/" + u.Host + u.EscapedPath()
+		if u.RawQuery != "" {
+			endpoint += "?" + u.RawQuery
+		}
+		if _, err := cdnPost(ctx, p.config.Client, endpoint, headers, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloudflarePurger Cloudflare 清除
+type cloudflarePurger struct {
+	config CloudflarePurgerConfig
+}
+
+// NewCloudflarePurger 创建 Cloudflare 清除器：key 按 Cache-Tag 清除，URL 按文件清除
+func NewCloudflarePurger(cfg CloudflarePurgerConfig) (CDNPurger, error) {
+	if cfg.ZoneID == "" || cfg.Token == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "cloudflare purger requires zone id and token")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultCloudflareEndpoint
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &cloudflarePurger{config: cfg}, nil
+}
+
+// Name 实现 CDNPurger
+func (p *cloudflarePurger) Name() string {
+	return "cloudflare"
+}
+
+// Purge 实现 CDNPurger；Cloudflare 单次请求只能使用一种清除方式，tag 与文件分别发送
+func (p *cloudflarePurger) Purge(ctx context.Context, req CDNPurgeRequest) error {
+	endpoint := p.config.Endpoint + "/zones/" + url.PathEscape(p.config.ZoneID) + "/purge_cache"
+	headers := map[string]string{
+		constants.HeaderAuthorization: "Bearer " + p.config.Token,
+		constants.HeaderContentType:   "application/json",
+	}
+	send := func(body map[string][]string) error {
+		payload, _ := json.Marshal(body)
+		respBody, err := cdnPost(ctx, p.config.Client, endpoint, headers, payload)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Success bool `json:"success"`
+			Errors  []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(respBody, &result); err == nil && !result.Success {
+			msgs := make([]string, 0, len(result.Errors))
+			for _, e := range result.Errors {
+				msgs = append(msgs, e.Message)
+			}
+			return errors.NewErrorf(errors.ErrCodeOperationFailed, "cloudflare purge rejected: %s", strings.Join(msgs, "; "))
+		}
+		return nil
+	}
+	for _, tags := range chunkStrings(req.Keys, cloudflareMaxTagsPerRequest) {
+		if err := send(map[string][]string{"tags": tags}); err != nil {
+			return err
+		}
+	}
+	for _, files := range chunkStrings(req.URLs, cloudflareMaxURLsPerRequest) {
+		if err := send(map[string][]string{"files": files}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookPurger 通用 webhook 清除
+type webhookPurger struct {
+	config WebhookPurgerConfig
+}
+
+// NewWebhookPurger 创建通用 webhook 清除器，适用于自建缓存层或其他 CDN 的适配服务
+func NewWebhookPurger(cfg WebhookPurgerConfig) (CDNPurger, error) {
+	if u, err := url.Parse(cfg.URL); err != nil || u.Host == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid cdn purge webhook url %q", cfg.URL)
+	}
+	if cfg.Name == "" {
+		cfg.Name = "webhook"
+	}
+	return &webhookPurger{config: cfg}, nil
+}
+
+// Name 实现 CDNPurger
+func (p *webhookPurger) Name() string {
+	return p.config.Name
+}
+
+// Purge 实现 CDNPurger
+func (p *webhookPurger) Purge(ctx context.Context, req CDNPurgeRequest) error {
+	headers := map[string]string{constants.HeaderContentType: "application/json"}
+	for k, v := range p.config.Headers {
+		headers[k] = v
+	}
+	payload, _ := json.Marshal(req)
+	_, err := cdnPost(ctx, p.config.Client, p.config.URL, headers, payload)
+	return err
+}
+
+// cdnPost 发送 POST 请求，非 2xx 返回包含响应片段的错误
+func cdnPost(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body []byte) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(constants.HeaderAccept, "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, cdnMaxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("purge request returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// chunkStrings 按大小切分
+func chunkStrings(items []string, size int) [][]string {
+	var chunks [][]string
+	for len(items) > size {
+		chunks = append(chunks, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		chunks = append(chunks, items)
+	}
+	return chunks
+}
@@ -0,0 +1,206 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\chain.go
+ * @Description: 中间件链预编译 - 按优先级声明中间件顺序，启动（及配置重载）时一次性包装成最终处理器，
+ *               请求路径上只有一次原子读取，并可查询生效顺序
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// 内置中间件优先级，数值越小越靠外层（越先执行）；自定义中间件可选用间隔值插入其间
+const (
+	PriorityRecovery       = 100
+	PriorityTracing        = 200
+	PriorityRequestContext = 300
+	PriorityBuffering      = 350
+	PriorityLogging        = 400
+	PriorityI18n           = 500
+	PriorityMetrics        = 600
+	PriorityCacheControl   = 650
+	PriorityHeaderLimit    = 680
+	PriorityRateLimit      = 700
+	PriorityConcurrency    = 750
+	PriorityFallback       = 780
+	PriorityBreaker        = 800
+	PrioritySecurity       = 900
+	PriorityCORS           = 1000
+	PriorityOpenAPI        = 1030
+	PrioritySignedURL      = 1050
+	PrioritySignature      = 1100
+	PriorityDecompression  = 1120
+	PriorityAuthn          = 1150
+	PrioritySchema         = 1160
+	PriorityStreamLimit    = 1170
+	PriorityExtAuthz       = 1200
+	PriorityCasbin         = 1300
+	PriorityMultipart      = 1320
+	PriorityScriptHooks    = 1350
+	PriorityRouting        = 1400
+	PriorityDeclarative    = 1450
+	PriorityStickySession  = 1500
+	PriorityDefault        = 2000 // 自定义中间件默认位于全部内置中间件之后
+)
+
+// 内置中间件名称
+const (
+	MiddlewareRecovery       = "recovery"
+	MiddlewareTracing        = "tracing"
+	MiddlewareRequestContext = "request_context"
+	MiddlewareBuffering      = "response_buffering"
+	MiddlewareLogging        = "logging"
+	MiddlewareI18n           = "i18n"
+	MiddlewareMetrics        = "metrics"
+	MiddlewareCacheControl   = "cache_control"
+	MiddlewareHeaderLimit    = "header_limit"
+	MiddlewareRateLimit      = "ratelimit"
+	MiddlewarePriorityLimit  = "priority_limit"
+	MiddlewareFallback       = "fallback"
+	MiddlewareBreaker        = "breaker"
+	MiddlewareCSP            = "csp"
+	MiddlewareCORS           = "cors"
+	MiddlewareOpenAPI        = "openapi_validation"
+	MiddlewareTimestamp      = "timestamp"
+	MiddlewareNonce          = "nonce"
+	MiddlewareSignature      = "signature"
+	MiddlewareSignedURL      = "signed_url"
+	MiddlewareDecompression  = "request_decompression"
+	MiddlewareAuthn          = "authn"
+	MiddlewareSchema         = "json_schema"
+	MiddlewareStreamLimit    = "stream_limit"
+	MiddlewareExtAuthz       = "ext_authz"
+	MiddlewareCasbin         = "casbin"
+	MiddlewareMultipart      = "multipart"
+	MiddlewareScriptHooks    = "script_hooks"
+	MiddlewareRouting        = "request_routing"
+	MiddlewareDeclarative    = "declarative_routes"
+	MiddlewareStickySession  = "sticky_session"
+)
+
+// DefaultMiddlewareAdminPath 中间件顺序查询接口默认路径
+const DefaultMiddlewareAdminPath = "/admin/middleware"
+
+// ChainEntry 中间件链中的一项
+type ChainEntry struct {
+	Name       string         `json:"name"`
+	Priority   int            `json:"priority"`
+	Builtin    bool           `json:"builtin"`
+	Middleware MiddlewareFunc `json:"-"`
+}
+
+// Chain 按优先级排序的中间件链，优先级相同时保持添加顺序
+type Chain struct {
+	entries []ChainEntry
+	timing  *StageTimingConfig // 分阶段计时，为空时不计时
+}
+
+// NewChain 创建中间件链
+func NewChain(entries ...ChainEntry) *Chain {
+	c := &Chain{}
+	for _, e := range entries {
+		c.add(e)
+	}
+	return c
+}
+
+// Use 添加中间件
+func (c *Chain) Use(name string, priority int, mw MiddlewareFunc) *Chain {
+	c.add(ChainEntry{Name: name, Priority: priority, Middleware: mw})
+	return c
+}
+
+// add 按优先级插入（相同优先级排在已有项之后）
+func (c *Chain) add(e ChainEntry) {
+	if e.Middleware == nil {
+		return
+	}
+	i := sort.Search(len(c.entries), func(i int) bool { return c.entries[i].Priority > e.Priority })
+	c.entries = append(c.entries, ChainEntry{})
+	copy(c.entries[i+1:], c.entries[i:])
+	c.entries[i] = e
+}
+
+// Entries 按执行顺序返回中间件项
+func (c *Chain) Entries() []ChainEntry {
+	return append([]ChainEntry(nil), c.entries...)
+}
+
+// Middlewares 按执行顺序返回中间件函数
+func (c *Chain) Middlewares() []MiddlewareFunc {
+	middlewares := make([]MiddlewareFunc, len(c.entries))
+	for i, e := range c.entries {
+		middlewares[i] = e.Middleware
+	}
+	return middlewares
+}
+
+// WithStageTiming 启用分阶段计时（子 span / span 事件、慢请求日志），cfg 为空时关闭
+func (c *Chain) WithStageTiming(cfg *StageTimingConfig) *Chain {
+	c.timing = cfg
+	return c
+}
+
+// Then 将中间件链包装到 handler 上，返回最终处理器
+func (c *Chain) Then(handler http.Handler) http.Handler {
+	if c.timing.enabled() {
+		return c.timing.wrap(handler, c.entries)
+	}
+	return ApplyMiddlewares(handler, c.Middlewares()...)
+}
+
+// CompiledChain 预编译的中间件链处理器
+// Compile 时一次性完成包装并原子替换，ServeHTTP 只读取已编译的处理器
+type CompiledChain struct {
+	final   http.Handler
+	handler atomic.Pointer[http.Handler]
+	entries atomic.Pointer[[]ChainEntry]
+}
+
+// NewCompiledChain 创建预编译中间件链，final 为链末端的处理器
+func NewCompiledChain(final http.Handler) *CompiledChain {
+	c := &CompiledChain{final: final}
+	c.handler.Store(&final)
+	c.entries.Store(&[]ChainEntry{})
+	return c
+}
+
+// Compile 重新编译中间件链，对之后的请求立即生效
+func (c *CompiledChain) Compile(chain *Chain) {
+	handler := c.final
+	entries := []ChainEntry{}
+	if chain != nil {
+		handler = chain.Then(c.final)
+		entries = chain.Entries()
+	}
+	c.handler.Store(&handler)
+	c.entries.Store(&entries)
+}
+
+// ServeHTTP 执行已编译的中间件链
+func (c *CompiledChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*c.handler.Load()).ServeHTTP(w, r)
+}
+
+// Order 返回当前生效的中间件顺序
+func (c *CompiledChain) Order() []ChainEntry {
+	return append([]ChainEntry(nil), *c.entries.Load()...)
+}
+
+// MiddlewareOrderHandler 管理接口：返回当前生效的中间件执行顺序
+func MiddlewareOrderHandler(order func() []ChainEntry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.WriteJSONResponse(w, http.StatusOK, order())
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\client_cancel.go
+ * @Description: 客户端断开识别 - 请求上下文被取消（客户端关闭连接 / gRPC 调用方取消）与服务端错误分开统计，
+ *               HTTP 按 499 记录日志与指标，可选不记录这类请求的日志
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusClientClosedRequest 客户端在响应写出前断开（沿用 nginx 的 499 约定）
+const StatusClientClosedRequest = 499
+
+// ClientCancelConfig 客户端断开处理配置
+type ClientCancelConfig struct {
+	SuppressLog bool // 不记录客户端断开的请求日志（仍计入指标）
+}
+
+// clientCancelConfig 当前生效的客户端断开处理配置
+var clientCancelConfig atomic.Pointer[ClientCancelConfig]
+
+// clientCanceledCounter 客户端断开的请求数（注册到默认 Registry）
+var clientCanceledCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_client_canceled_requests_total",
+	Help: "Total number of requests abandoned by the client before a response was sent",
+}, []string{"protocol"})
+
+// SetClientCancelConfig 设置客户端断开处理配置，nil 恢复默认（记录为警告日志）
+func SetClientCancelConfig(cfg *ClientCancelConfig) {
+	clientCancelConfig.Store(cfg)
+}
+
+// IsClientCanceled 请求是否因客户端断开或取消而结束（截止时间到期不算）
+func IsClientCanceled(ctx context.Context, err error) bool {
+	if ctx != nil && stderrors.Is(ctx.Err(), context.Canceled) {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+	return stderrors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled
+}
+
+// RecordClientCanceled 计入一次客户端断开，protocol 为 http / grpc
+func RecordClientCanceled(protocol string) {
+	clientCanceledCounter.WithLabelValues(protocol).Inc()
+}
+
+// suppressClientCancelLog 是否不记录客户端断开的请求日志
+func suppressClientCancelLog() bool {
+	cfg := clientCancelConfig.Load()
+	return cfg != nil && cfg.SuppressLog
+}
+
+// statusText 指标中的状态文本，补充 net/http 未定义的 499
+func statusText(code int) string {
+	if code == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(code)
+}
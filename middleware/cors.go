@@ -0,0 +1,367 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\cors.go
+ * @Description: CORS 中间件 - 路由组级覆盖、通配/正则来源匹配、凭证安全、预检缓存与 Vary 处理、演练模式
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-config/pkg/cors"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// corsRegexPrefix 正则来源前缀，如 "regex:^https://(a|b)\.example\.com$"
+const corsRegexPrefix = "regex:"
+
+// CORSRouteGroup 路由组级 CORS 覆盖
+type CORSRouteGroup struct {
+	Name         string     // 路由组名称（用于日志）
+	PathPrefixes []string   // 匹配的路径前缀，多个路由组命中时取最长前缀
+	Config       *cors.Cors // 该路由组的完整 CORS 配置，Enabled=false 表示该路由组不处理跨域
+}
+
+// CORSOptions CORS 扩展配置
+type CORSOptions struct {
+	Groups []CORSRouteGroup // 路由组覆盖
+	DryRun bool             // 演练模式：按允许处理所有跨域请求，仅记录将被拦截的请求
+}
+
+// corsPolicy 预编译的 CORS 策略
+type corsPolicy struct {
+	name            string
+	enabled         bool
+	allowAllOrigins bool
+	origins         map[string]struct{}
+	patterns        []*regexp.Regexp
+	allowAllMethods bool
+	methods         map[string]struct{}
+	methodsValue    string
+	allowAllHeaders bool
+	headers         map[string]struct{}
+	headersValue    string
+	exposedValue    string
+	credentials     bool
+	maxAge          string
+}
+
+// corsRouter 按路径选择 CORS 策略
+type corsRouter struct {
+	global *corsPolicy
+	routes *RouteTable // 路由组前缀，按前缀长度降序编译
+	dryRun bool
+}
+
+// corsHandler 可热更新的 CORS 处理器
+type corsHandler struct {
+	router atomic.Pointer[corsRouter]
+}
+
+// CORSMiddleware CORS 中间件
+func CORSMiddleware(corsConfig *cors.Cors) HTTPMiddleware {
+	return CORSMiddlewareWithOptions(corsConfig, nil)
+}
+
+// CORSMiddlewareWithOptions 支持路由组覆盖与演练模式的 CORS 中间件
+func CORSMiddlewareWithOptions(corsConfig *cors.Cors, opts *CORSOptions) HTTPMiddleware {
+	return newCORSHandler(corsConfig, opts).middleware
+}
+
+// newCORSHandler 创建 CORS 处理器
+func newCORSHandler(corsConfig *cors.Cors, opts *CORSOptions) *corsHandler {
+	h := &corsHandler{}
+	h.update(corsConfig, opts)
+	return h
+}
+
+// update 重新编译 CORS 策略，对已构建的中间件链立即生效
+func (h *corsHandler) update(corsConfig *cors.Cors, opts *CORSOptions) {
+	router := &corsRouter{global: compileCORSPolicy("global", corsConfig)}
+	if opts != nil {
+		router.dryRun = opts.DryRun
+		var patterns []RoutePattern
+		for _, group := range opts.Groups {
+			policy := compileCORSPolicy(group.Name, group.Config)
+			for _, prefix := range group.PathPrefixes {
+				if prefix != "" {
+					patterns = append(patterns, RoutePattern{Kind: RouteMatchPrefix, Pattern: prefix, Value: policy})
+				}
+			}
+		}
+		sort.SliceStable(patterns, func(i, j int) bool {
+			return len(patterns[i].Pattern) > len(patterns[j].Pattern)
+		})
+		router.routes = NewRouteTable(patterns)
+	}
+	h.router.Store(router)
+}
+
+// middleware HTTP 中间件
+func (h *corsHandler) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router := h.router.Load()
+		policy := router.match(r.URL.Path)
+		if policy == nil || !policy.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if isPreflight(r) {
+			policy.handlePreflight(w, r, router.dryRun)
+			return
+		}
+
+		// 非预检请求（包括普通 OPTIONS）交给后续处理器，由路由层返回 Allow
+		policy.handleActual(w, r, router.dryRun)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// match 按最长前缀选择策略，未命中时使用全局策略
+func (r *corsRouter) match(path string) *corsPolicy {
+	if policy, ok := r.routes.Match("", path); ok {
+		return policy.(*corsPolicy)
+	}
+	return r.global
+}
+
+// compileCORSPolicy 预编译 CORS 配置
+func compileCORSPolicy(name string, cfg *cors.Cors) *corsPolicy {
+	if cfg == nil {
+		return nil
+	}
+
+	p := &corsPolicy{
+		name:            name,
+		enabled:         cfg.Enabled,
+		allowAllOrigins: cfg.AllowedAllOrigins,
+		origins:         make(map[string]struct{}),
+		allowAllMethods: cfg.AllowedAllMethods,
+		methods:         make(map[string]struct{}),
+		headers:         make(map[string]struct{}),
+		credentials:     cfg.AllowCredentials,
+		maxAge:          cfg.MaxAge,
+		exposedValue:    strings.Join(cfg.ExposedHeaders, ", "),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == "*":
+			p.allowAllOrigins = true
+		case strings.HasPrefix(origin, corsRegexPrefix):
+			re, err := regexp.Compile(strings.TrimPrefix(origin, corsRegexPrefix))
+			if err != nil {
+				global.LOGGER.WarnKV("CORS 来源正则无效，已忽略", "group", name, "origin", origin, "error", err)
+				continue
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(origin, "*"):
+			p.patterns = append(p.patterns, wildcardOriginPattern(origin))
+		default:
+			p.origins[strings.ToLower(origin)] = struct{}{}
+		}
+	}
+
+	for _, method := range cfg.AllowedMethods {
+		if method == "*" {
+			p.allowAllMethods = true
+			continue
+		}
+		p.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	p.methodsValue = strings.Join(cfg.AllowedMethods, ", ")
+
+	mergedHeaders := mathx.SliceUnion(cors.Default().AllowedHeaders, cfg.AllowedHeaders)
+	for _, header := range mergedHeaders {
+		if header == "*" {
+			p.allowAllHeaders = true
+			continue
+		}
+		p.headers[strings.ToLower(header)] = struct{}{}
+	}
+	p.headersValue = strings.Join(mergedHeaders, ", ")
+
+	if p.allowAllOrigins && p.credentials {
+		global.LOGGER.WarnKV("CORS 通配来源不返回 Allow-Credentials，如需携带凭证请显式列出来源", "group", name)
+	}
+	return p
+}
+
+// wildcardOriginPattern 将 https://*.example.com 形式的通配来源转换为正则，* 仅匹配域名标签
+func wildcardOriginPattern(origin string) *regexp.Regexp {
+	parts := strings.Split(origin, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(strings.ToLower(part))
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, `[a-z0-9-]+(?:\.[a-z0-9-]+)*`) + "$")
+}
+
+// isPreflight 是否为 CORS 预检请求
+func isPreflight(r *http.Request) bool {
+	return r.Method == constants.HTTPMethodOptions &&
+		r.Header.Get(constants.HeaderOrigin) != "" &&
+		r.Header.Get(constants.HeaderAccessControlRequestMethod) != ""
+}
+
+// matchOrigin 匹配来源，wildcard 表示通过 "*" 命中
+func (p *corsPolicy) matchOrigin(origin string) (allowed, wildcard bool) {
+	lower := strings.ToLower(origin)
+	if _, ok := p.origins[lower]; ok {
+		return true, false
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(lower) {
+			return true, false
+		}
+	}
+	return p.allowAllOrigins, p.allowAllOrigins
+}
+
+// setAllowOrigin 设置允许的来源与凭证头
+// 通配来源返回 "*" 且不返回 Allow-Credentials，避免任意站点携带凭证访问
+func (p *corsPolicy) setAllowOrigin(h http.Header, origin string, wildcard bool) {
+	if wildcard {
+		h.Set(constants.HeaderAccessControlAllowOrigin, "*")
+		return
+	}
+	h.Set(constants.HeaderAccessControlAllowOrigin, origin)
+	if p.credentials {
+		h.Set(constants.HeaderAccessControlAllowCredentials, constants.CORSCredentialsTrue)
+	}
+}
+
+// handleActual 处理实际请求的 CORS 头
+func (p *corsPolicy) handleActual(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	h := w.Header()
+	if !p.allowAllOrigins || len(p.origins) > 0 || len(p.patterns) > 0 {
+		addVary(h, constants.HeaderOrigin)
+	}
+
+	origin := r.Header.Get(constants.HeaderOrigin)
+	if origin == "" {
+		return
+	}
+
+	allowed, wildcard := p.matchOrigin(origin)
+	if !allowed {
+		if !dryRun {
+			return
+		}
+		p.logDryRun(r, origin, "origin not allowed")
+	}
+
+	p.setAllowOrigin(h, origin, wildcard)
+	if p.exposedValue != "" {
+		h.Set(constants.HeaderAccessControlExposeHeaders, p.exposedValue)
+	}
+}
+
+// handlePreflight 处理预检请求
+func (p *corsPolicy) handlePreflight(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	h := w.Header()
+	addVary(h, constants.HeaderOrigin,
+		constants.HeaderAccessControlRequestMethod,
+		constants.HeaderAccessControlRequestHeaders)
+
+	origin := r.Header.Get(constants.HeaderOrigin)
+	allowed, wildcard := p.matchOrigin(origin)
+	reason := ""
+	switch {
+	case !allowed:
+		reason = "origin not allowed"
+	case !p.methodAllowed(r.Header.Get(constants.HeaderAccessControlRequestMethod)):
+		reason = "method not allowed"
+	case !p.headersAllowed(r.Header.Get(constants.HeaderAccessControlRequestHeaders)):
+		reason = "headers not allowed"
+	}
+
+	if reason != "" {
+		if !dryRun {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		p.logDryRun(r, origin, reason)
+	}
+
+	p.setAllowOrigin(h, origin, wildcard)
+	if p.allowAllMethods {
+		h.Set(constants.HeaderAccessControlAllowMethods, r.Header.Get(constants.HeaderAccessControlRequestMethod))
+	} else if p.methodsValue != "" {
+		h.Set(constants.HeaderAccessControlAllowMethods, p.methodsValue)
+	}
+	if requested := r.Header.Get(constants.HeaderAccessControlRequestHeaders); p.allowAllHeaders && requested != "" {
+		h.Set(constants.HeaderAccessControlAllowHeaders, requested)
+	} else if p.headersValue != "" {
+		h.Set(constants.HeaderAccessControlAllowHeaders, p.headersValue)
+	}
+	if p.maxAge != "" {
+		h.Set(constants.HeaderAccessControlMaxAge, p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// methodAllowed 预检请求方法是否允许
+func (p *corsPolicy) methodAllowed(method string) bool {
+	if p.allowAllMethods {
+		return true
+	}
+	_, ok := p.methods[strings.ToUpper(method)]
+	return ok
+}
+
+// headersAllowed 预检请求头是否全部允许
+func (p *corsPolicy) headersAllowed(requested string) bool {
+	if p.allowAllHeaders || requested == "" {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		if _, ok := p.headers[header]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// logDryRun 演练模式下记录将被拦截的请求
+func (p *corsPolicy) logDryRun(r *http.Request, origin, reason string) {
+	global.LOGGER.WarnKV("CORS 演练模式: 请求将被拦截",
+		"group", p.name,
+		"origin", origin,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"reason", reason)
+}
+
+// addVary 追加 Vary 头，已存在的值不重复添加
+func addVary(h http.Header, values ...string) {
+	existing := make(map[string]struct{})
+	for _, line := range h.Values(constants.HeaderVary) {
+		for _, v := range strings.Split(line, ",") {
+			existing[strings.ToLower(strings.TrimSpace(v))] = struct{}{}
+		}
+	}
+	for _, v := range values {
+		if _, ok := existing[strings.ToLower(v)]; ok {
+			continue
+		}
+		h.Add(constants.HeaderVary, v)
+		existing[strings.ToLower(v)] = struct{}{}
+	}
+}
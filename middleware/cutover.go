@@ -0,0 +1,437 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\cutover.go
+ * @Description: 蓝绿切换控制器 - 按路由前缀在 blue / green 两组上游之间原子切换全部流量，
+ *               切换记入审计日志，切换后观察窗口内新上游错误率超过阈值时自动回滚
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// CutoverColor 上游组
+type CutoverColor string
+
+const (
+	CutoverBlue  CutoverColor = "blue"
+	CutoverGreen CutoverColor = "green"
+)
+
+// 切换触发方式
+const (
+	CutoverTriggerManual   = "manual"
+	CutoverTriggerRollback = "auto_rollback"
+)
+
+const (
+	DefaultCutoverWindow      = 5 * time.Minute
+	DefaultCutoverMinRequests = 20
+	DefaultCutoverHistorySize = 100
+	DefaultCutoverAdminPath   = "/admin/cutover"
+)
+
+// CutoverRoute 参与蓝绿切换的路由前缀
+type CutoverRoute struct {
+	Name   string       // 路由名，管理接口按名称切换，默认取 Prefix
+	Prefix string       // 路径前缀，匹配 Prefix 本身及其子路径；多个前缀重叠时最长者优先
+	Blue   http.Handler // blue 上游（通常为 Gateway.NewUpstreamPool 创建的 grpc-gateway 处理器）
+	Green  http.Handler // green 上游
+	Active CutoverColor // 初始生效的上游，默认 blue
+}
+
+// CutoverRollbackConfig 自动回滚配置
+type CutoverRollbackConfig struct {
+	ErrorRate   float64       // 错误率阈值（0~1，5xx 计为错误），0 关闭自动回滚
+	Window      time.Duration // 切换后的观察窗口，默认 5 分钟
+	MinRequests int64         // 窗口内达到该请求数后才判定错误率，默认 20
+}
+
+// CutoverConfig 蓝绿切换配置
+type CutoverConfig struct {
+	Routes      []CutoverRoute
+	Rollback    CutoverRollbackConfig
+	AdminPath   string                   // 管理接口路径，为空不注册；接口本身需由认证 / 授权中间件保护
+	HistorySize int                      // 保留的切换记录条数，默认 100
+	OnSwitch    func(event CutoverEvent) // 切换回调（审计上报、告警），在切换协程中同步执行
+}
+
+// CutoverEvent 切换记录
+type CutoverEvent struct {
+	Time      time.Time    `json:"time"`
+	Route     string       `json:"route"`
+	Prefix    string       `json:"prefix"`
+	From      CutoverColor `json:"from"`
+	To        CutoverColor `json:"to"`
+	Trigger   string       `json:"trigger"`            // manual / auto_rollback
+	Operator  string       `json:"operator,omitempty"` // 手动切换的操作人
+	Reason    string       `json:"reason,omitempty"`
+	Requests  int64        `json:"requests,omitempty"`   // 自动回滚时观察窗口内的请求数
+	Errors    int64        `json:"errors,omitempty"`     // 自动回滚时观察窗口内的错误数
+	ErrorRate float64      `json:"error_rate,omitempty"` // 自动回滚时的错误率
+}
+
+// CutoverRouteStatus 路由当前状态
+type CutoverRouteStatus struct {
+	Route      string       `json:"route"`
+	Prefix     string       `json:"prefix"`
+	Active     CutoverColor `json:"active"`
+	Previous   CutoverColor `json:"previous,omitempty"`
+	SwitchedAt time.Time    `json:"switched_at,omitzero"`
+	Watching   bool         `json:"watching"` // 是否处于自动回滚观察窗口内
+	WatchUntil time.Time    `json:"watch_until,omitzero"`
+	Requests   int64        `json:"requests"` // 本次切换以来经过观察的请求数
+	Errors     int64        `json:"errors"`
+}
+
+// CutoverStatusResponse 管理接口状态响应
+type CutoverStatusResponse struct {
+	Routes []CutoverRouteStatus `json:"routes"`
+	Events []CutoverEvent       `json:"events"`
+}
+
+// CutoverSwitchRequest 管理接口切换请求
+type CutoverSwitchRequest struct {
+	Route    string       `json:"route"`
+	To       CutoverColor `json:"to,omitempty"` // 为空时切换到另一组
+	Operator string       `json:"operator,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+// cutoverSwitchesTotal 切换次数（注册到默认 Registry）
+var cutoverSwitchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_cutover_switches_total",
+	Help: "Total number of blue/green cutover switches",
+}, []string{"route", "to", "trigger"})
+
+// cutoverState 一次切换后的路由状态，切换时整体替换
+type cutoverState struct {
+	active     CutoverColor
+	previous   CutoverColor
+	switchedAt time.Time
+	watchUntil time.Time // 零值表示不观察
+	requests   atomic.Int64
+	errors     atomic.Int64
+}
+
+// cutoverRoute 编译后的路由
+type cutoverRoute struct {
+	name   string
+	prefix string
+	pools  map[CutoverColor]http.Handler
+	state  atomic.Pointer[cutoverState]
+}
+
+// Cutover 蓝绿切换控制器
+type Cutover struct {
+	config CutoverConfig
+	routes []*cutoverRoute // 按前缀长度降序
+	byName map[string]*cutoverRoute
+
+	mu      sync.Mutex // 串行化切换并保护 history
+	history []CutoverEvent
+}
+
+// NewCutover 校验配置并创建蓝绿切换控制器
+func NewCutover(cfg CutoverConfig) (*Cutover, error) {
+	if len(cfg.Routes) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "cutover requires at least one route")
+	}
+	if cfg.Rollback.ErrorRate < 0 || cfg.Rollback.ErrorRate > 1 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cutover rollback error rate %v must be between 0 and 1", cfg.Rollback.ErrorRate)
+	}
+	if cfg.Rollback.Window < 0 || cfg.Rollback.MinRequests < 0 || cfg.HistorySize < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "cutover rollback window, min requests and history size must not be negative")
+	}
+	if cfg.Rollback.Window == 0 {
+		cfg.Rollback.Window = DefaultCutoverWindow
+	}
+	if cfg.Rollback.MinRequests == 0 {
+		cfg.Rollback.MinRequests = DefaultCutoverMinRequests
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = DefaultCutoverHistorySize
+	}
+
+	c := &Cutover{config: cfg, byName: make(map[string]*cutoverRoute, len(cfg.Routes))}
+	prefixes := make(map[string]bool, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		prefix := strings.TrimSuffix(route.Prefix, "/")
+		if !strings.HasPrefix(route.Prefix, "/") || prefix == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cutover prefix %q must start with / and not be the root", route.Prefix)
+		}
+		if route.Blue == nil || route.Green == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cutover route %q requires both blue and green upstreams", route.Prefix)
+		}
+		name := route.Name
+		if name == "" {
+			name = prefix
+		}
+		if c.byName[name] != nil || prefixes[prefix] {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "duplicate cutover route %q", name)
+		}
+		active := route.Active
+		if active == "" {
+			active = CutoverBlue
+		}
+		if !active.valid() {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cutover route %q has unknown active color %q", name, active)
+		}
+
+		r := &cutoverRoute{
+			name:   name,
+			prefix: prefix,
+			pools:  map[CutoverColor]http.Handler{CutoverBlue: route.Blue, CutoverGreen: route.Green},
+		}
+		r.state.Store(&cutoverState{active: active})
+		c.routes = append(c.routes, r)
+		c.byName[name] = r
+		prefixes[prefix] = true
+	}
+	sort.SliceStable(c.routes, func(i, j int) bool { return len(c.routes[i].prefix) > len(c.routes[j].prefix) })
+	return c, nil
+}
+
+// valid 是否为已知的上游组
+func (color CutoverColor) valid() bool {
+	return color == CutoverBlue || color == CutoverGreen
+}
+
+// other 另一组上游
+func (color CutoverColor) other() CutoverColor {
+	if color == CutoverBlue {
+		return CutoverGreen
+	}
+	return CutoverBlue
+}
+
+// Prefixes 全部路由前缀（不含末尾 /）
+func (c *Cutover) Prefixes() []string {
+	prefixes := make([]string, 0, len(c.routes))
+	for _, r := range c.routes {
+		prefixes = append(prefixes, r.prefix)
+	}
+	return prefixes
+}
+
+// match 按最长前缀查找路由
+func (c *Cutover) match(path string) *cutoverRoute {
+	for _, r := range c.routes {
+		if path == r.prefix || strings.HasPrefix(path, r.prefix+"/") {
+			return r
+		}
+	}
+	return nil
+}
+
+// Handle 将请求交给路由当前生效的上游，未匹配任何路由时交给 fallback
+func (c *Cutover) Handle(w http.ResponseWriter, r *http.Request, fallback http.Handler) {
+	route := c.match(r.URL.Path)
+	if route == nil {
+		fallback.ServeHTTP(w, r)
+		return
+	}
+
+	st := route.state.Load()
+	pool := route.pools[st.active]
+	if st.watchUntil.IsZero() || time.Now().After(st.watchUntil) {
+		pool.ServeHTTP(w, r)
+		return
+	}
+
+	rw := NewResponseWriter(w)
+	defer rw.Release()
+	pool.ServeHTTP(rw, r)
+	c.observe(route, st, rw.IsServerError())
+}
+
+// observe 记录观察窗口内的请求结果，错误率超过阈值时回滚到切换前的上游
+func (c *Cutover) observe(route *cutoverRoute, st *cutoverState, failed bool) {
+	requests := st.requests.Add(1)
+	errCount := st.errors.Load()
+	if failed {
+		errCount = st.errors.Add(1)
+	}
+	if requests < c.config.Rollback.MinRequests {
+		return
+	}
+	rate := float64(errCount) / float64(requests)
+	if rate <= c.config.Rollback.ErrorRate {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if route.state.Load() != st {
+		return // 已被其他请求回滚或人工切换
+	}
+	c.switchLocked(route, st.previous, CutoverEvent{
+		Trigger:   CutoverTriggerRollback,
+		Reason:    "error rate exceeded threshold",
+		Requests:  requests,
+		Errors:    errCount,
+		ErrorRate: rate,
+	})
+}
+
+// Switch 将路由切换到指定上游（to 为空时切换到另一组），返回切换记录
+// 开启自动回滚时，切换后进入观察窗口
+func (c *Cutover) Switch(name string, to CutoverColor, operator, reason string) (CutoverEvent, error) {
+	event, appErr := c.switchRoute(name, to, operator, reason)
+	if appErr != nil {
+		return event, appErr
+	}
+	return event, nil
+}
+
+// switchRoute 手动切换
+func (c *Cutover) switchRoute(name string, to CutoverColor, operator, reason string) (CutoverEvent, *errors.AppError) {
+	route := c.byName[name]
+	if route == nil {
+		return CutoverEvent{}, errors.NewErrorf(errors.ErrCodeNotFound, "cutover route %q not found", name)
+	}
+	if to != "" && !to.valid() {
+		return CutoverEvent{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unknown cutover color %q", to)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := route.state.Load()
+	if to == "" {
+		to = st.active.other()
+	}
+	if to == st.active {
+		return CutoverEvent{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cutover route %q is already on %s", name, to)
+	}
+	return c.switchLocked(route, to, CutoverEvent{Trigger: CutoverTriggerManual, Operator: operator, Reason: reason}), nil
+}
+
+// switchLocked 替换路由状态并记录审计日志，调用方持有 c.mu
+func (c *Cutover) switchLocked(route *cutoverRoute, to CutoverColor, event CutoverEvent) CutoverEvent {
+	now := time.Now()
+	prev := route.state.Load()
+	next := &cutoverState{active: to, previous: prev.active, switchedAt: now}
+	if event.Trigger == CutoverTriggerManual && c.config.Rollback.ErrorRate > 0 {
+		next.watchUntil = now.Add(c.config.Rollback.Window)
+	}
+	route.state.Store(next)
+
+	event.Time, event.Route, event.Prefix, event.From, event.To = now, route.name, route.prefix, prev.active, to
+	c.history = append(c.history, event)
+	if len(c.history) > c.config.HistorySize {
+		c.history = append(c.history[:0:0], c.history[len(c.history)-c.config.HistorySize:]...)
+	}
+	cutoverSwitchesTotal.WithLabelValues(route.name, string(to), event.Trigger).Inc()
+
+	if global.LOGGER != nil {
+		kv := []any{
+			"audit", true,
+			"route", event.Route,
+			"prefix", event.Prefix,
+			"from", event.From,
+			"to", event.To,
+			"trigger", event.Trigger,
+			"operator", event.Operator,
+			"reason", event.Reason,
+		}
+		if event.Trigger == CutoverTriggerRollback {
+			kv = append(kv, "requests", event.Requests, "errors", event.Errors, "error_rate", event.ErrorRate)
+			global.LOGGER.WarnKV("⏪ 蓝绿切换自动回滚", kv...)
+		} else {
+			global.LOGGER.InfoKV("🔀 蓝绿切换", kv...)
+		}
+	}
+	if c.config.OnSwitch != nil {
+		c.config.OnSwitch(event)
+	}
+	return event
+}
+
+// Status 全部路由的当前状态，按路由名排序
+func (c *Cutover) Status() []CutoverRouteStatus {
+	now := time.Now()
+	statuses := make([]CutoverRouteStatus, 0, len(c.routes))
+	for _, r := range c.routes {
+		st := r.state.Load()
+		statuses = append(statuses, CutoverRouteStatus{
+			Route:      r.name,
+			Prefix:     r.prefix,
+			Active:     st.active,
+			Previous:   st.previous,
+			SwitchedAt: st.switchedAt,
+			Watching:   !st.watchUntil.IsZero() && now.Before(st.watchUntil),
+			WatchUntil: st.watchUntil,
+			Requests:   st.requests.Load(),
+			Errors:     st.errors.Load(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Route < statuses[j].Route })
+	return statuses
+}
+
+// History 切换记录（按时间先后）
+func (c *Cutover) History() []CutoverEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CutoverEvent(nil), c.history...)
+}
+
+// AdminHandler 管理接口：GET {path} 查询状态与切换记录，POST {path}/switch 切换（请求体 CutoverSwitchRequest）
+// 未指定操作人时取请求上下文中的用户 ID，再退化为客户端 IP
+func (c *Cutover) AdminHandler(path string) http.HandlerFunc {
+	path = strings.TrimSuffix(path, "/")
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case (r.URL.Path == path || r.URL.Path == path+"/") && r.Method == http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, CutoverStatusResponse{Routes: c.Status(), Events: c.History()})
+		case r.URL.Path == path+"/switch" && r.Method == http.MethodPost:
+			c.handleSwitch(w, r)
+		default:
+			response.WriteNotFoundResult(w, "unknown cutover admin endpoint")
+		}
+	}
+}
+
+// handleSwitch 处理切换请求
+func (c *Cutover) handleSwitch(w http.ResponseWriter, r *http.Request) {
+	var req CutoverSwitchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		response.WriteBadRequestResult(w, "invalid cutover request: "+err.Error())
+		return
+	}
+	if req.Route == "" {
+		response.WriteBadRequestResult(w, "cutover route is required")
+		return
+	}
+	if req.Operator == "" {
+		if req.Operator = GetUserID(r.Context()); req.Operator == "" {
+			req.Operator = netx.GetClientIP(r)
+		}
+	}
+
+	event, appErr := c.switchRoute(req.Route, req.To, req.Operator, req.Reason)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	response.WriteJSONResponse(w, http.StatusOK, event)
+}
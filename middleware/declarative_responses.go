@@ -0,0 +1,199 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\declarative_responses.go
+ * @Description: 声明式路由的本地响应 - 静态 / 模板响应（维护页、mock 接口）与重定向（旧地址迁移），
+ *               不访问任何上游
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+)
+
+// responseTemplate text/template 与 html/template 的公共执行接口
+type responseTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// ResponseTemplateData 响应模板可引用的请求变量，如 {{.Path}}、{{.Query.Get "id"}}、{{.Header.Get "User-Agent"}}
+type ResponseTemplateData struct {
+	Method    string
+	Path      string
+	RawQuery  string
+	Query     url.Values
+	Host      string
+	ClientIP  string
+	RequestID string
+	Header    http.Header
+	Principal *Principal
+	Now       time.Time
+}
+
+// staticHandler 静态响应，配置了 Template 时按请求渲染响应体
+type staticHandler struct {
+	route    string
+	status   int
+	headers  http.Header
+	body     []byte
+	template responseTemplate
+}
+
+// newStaticHandler 校验配置，预先编码 JSON 响应体或解析模板
+func newStaticHandler(route string, cfg *StaticResponse) (*staticHandler, error) {
+	h := &staticHandler{route: route, status: cfg.Status, headers: http.Header{}, body: []byte(cfg.Body)}
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	if h.status < 100 || h.status > 599 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q has invalid response status %d", route, cfg.Status)
+	}
+	bodySources := 0
+	for _, set := range []bool{cfg.Body != "", cfg.Template != "", cfg.JSON != nil} {
+		if set {
+			bodySources++
+		}
+	}
+	if bodySources > 1 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q response must set at most one of body, template or json", route)
+	}
+	if cfg.JSON != nil {
+		body, err := json.Marshal(cfg.JSON)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q response json: %v", route, err)
+		}
+		h.body = body
+		h.headers.Set(constants.HeaderContentType, "application/json")
+	}
+	for name, value := range cfg.Headers {
+		h.headers.Set(name, value)
+	}
+	if cfg.Template != "" {
+		tmpl, err := parseResponseTemplate(route, cfg.Template, h.headers.Get(constants.HeaderContentType))
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q response template: %v", route, err)
+		}
+		h.template = tmpl
+	}
+	return h, nil
+}
+
+// parseResponseTemplate Content-Type 为 HTML 时使用 html/template 转义请求变量，其余按纯文本渲染
+func parseResponseTemplate(name, text, contentType string) (responseTemplate, error) {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return htmltemplate.New(name).Option("missingkey=zero").Parse(text)
+	}
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// ServeHTTP 写出静态响应，HEAD 请求只返回响应头；模板渲染失败返回 500
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := h.body
+	if h.template != nil {
+		var buf bytes.Buffer
+		if err := h.template.Execute(&buf, newResponseTemplateData(r)); err != nil {
+			global.LOGGER.WarnContext(r.Context(), "声明式路由模板渲染失败: route=%s, error=%v", h.route, err)
+			response.WriteErrorResponse(w, errors.ErrInternalServerError)
+			return
+		}
+		body = buf.Bytes()
+	}
+	for name, values := range h.headers {
+		w.Header()[name] = values
+	}
+	if h.template != nil {
+		w.Header().Set(constants.HeaderContentLength, strconv.Itoa(len(body)))
+	}
+	w.WriteHeader(h.status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// newResponseTemplateData 从请求提取模板变量
+func newResponseTemplateData(r *http.Request) *ResponseTemplateData {
+	principal, _ := PrincipalFromContext(r.Context())
+	return &ResponseTemplateData{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RawQuery:  r.URL.RawQuery,
+		Query:     r.URL.Query(),
+		Host:      r.Host,
+		ClientIP:  netx.GetClientIP(r),
+		RequestID: GetRequestCommonMeta(r.Context()).RequestID,
+		Header:    r.Header,
+		Principal: principal,
+		Now:       time.Now(),
+	}
+}
+
+// redirectHandler 重定向
+type redirectHandler struct {
+	status    int
+	regex     *regexp.Regexp
+	to        string
+	dropQuery bool
+}
+
+// newRedirectHandler 校验重定向状态码、正则与目标地址
+func newRedirectHandler(route string, cfg *RouteRedirect) (*redirectHandler, error) {
+	h := &redirectHandler{status: cfg.Status, to: cfg.To, dropQuery: cfg.DropQuery}
+	if h.status == 0 {
+		h.status = http.StatusFound
+	}
+	switch h.status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q has invalid redirect status %d", route, cfg.Status)
+	}
+	if cfg.To == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q redirect requires to", route)
+	}
+	if cfg.Regex != "" {
+		regex, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q redirect regex: %v", route, err)
+		}
+		h.regex = regex
+	}
+	return h, nil
+}
+
+// ServeHTTP 计算目标地址并重定向；未显式丢弃时原查询串追加到目标地址
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	location := h.to
+	if h.regex != nil {
+		if match := h.regex.FindStringSubmatchIndex(r.URL.Path); match != nil {
+			location = string(h.regex.ExpandString(nil, h.to, r.URL.Path, match))
+		}
+	}
+	if !h.dropQuery && r.URL.RawQuery != "" {
+		separator := "?"
+		if strings.Contains(location, "?") {
+			separator = "&"
+		}
+		location += separator + r.URL.RawQuery
+	}
+	http.Redirect(w, r, location, h.status)
+}
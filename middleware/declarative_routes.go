@@ -0,0 +1,510 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\declarative_routes.go
+ * @Description: 声明式路由 - 由配置文件 routes 段定义路径 / 方法到 HTTP 上游、静态响应或重定向的映射，
+ *               每条路由可单独配置认证、限流、缓存与请求 / 响应改写，无需编写 Go 代码
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v3"
+)
+
+// DeclarativeRoutesConfig 声明式路由配置（对应配置文件顶层 routes 段）
+type DeclarativeRoutesConfig struct {
+	Upstreams []DeclarativeUpstream `json:"upstreams" yaml:"upstreams" mapstructure:"upstreams"`
+	Rules     []DeclarativeRoute    `json:"rules" yaml:"rules" mapstructure:"rules"` // 按声明顺序匹配，先声明者优先
+	// RewriteTestPath 改写试算接口路径（如 /admin/rewrite-test），为空不注册；接口本身需由认证 / 授权中间件保护
+	RewriteTestPath string `json:"rewrite_test_path" yaml:"rewrite-test-path" mapstructure:"rewrite-test-path"`
+}
+
+// DeclarativeUpstream HTTP 上游
+type DeclarativeUpstream struct {
+	Name    string           `json:"name" yaml:"name" mapstructure:"name"`                              // 上游名称，供路由引用
+	URL     string           `json:"url" yaml:"url" mapstructure:"url"`                                 // 上游地址，如 http://user-svc:8080，带路径时作为转发前缀
+	Timeout time.Duration    `json:"timeout" yaml:"timeout" mapstructure:"timeout"`                     // 单次请求超时，0 不限制
+	Rewrite *UpstreamRewrite `json:"rewrite,omitempty" yaml:"rewrite,omitempty" mapstructure:"rewrite"` // 转发到该上游前的路径与查询串改写
+	TLS     *UpstreamTLS     `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:"tls"`             // https 上游的 TLS 客户端配置
+	Dial    *UpstreamDial    `json:"dial,omitempty" yaml:"dial,omitempty" mapstructure:"dial"`          // 建连超时、keepalive 与连接池
+}
+
+// DeclarativeRoute 单条路由：转发到 Upstream、返回 Response 或按 Redirect 重定向，三者必选其一
+type DeclarativeRoute struct {
+	Name      string                `json:"name" yaml:"name" mapstructure:"name"`                                 // 路由名（指标标签），默认 route-<序号>
+	Paths     []string              `json:"paths" yaml:"paths" mapstructure:"paths"`                              // 路径，以 * 结尾表示前缀匹配
+	Methods   []string              `json:"methods" yaml:"methods" mapstructure:"methods"`                        // HTTP 方法，为空匹配全部
+	Upstream  string                `json:"upstream,omitempty" yaml:"upstream,omitempty" mapstructure:"upstream"` // 上游名称
+	Response  *StaticResponse       `json:"response,omitempty" yaml:"response,omitempty" mapstructure:"response"` // 静态 / mock 响应
+	Redirect  *RouteRedirect        `json:"redirect,omitempty" yaml:"redirect,omitempty" mapstructure:"redirect"` // 重定向
+	Auth      *RouteAuthPolicy      `json:"auth,omitempty" yaml:"auth,omitempty" mapstructure:"auth"`             // 认证要求，读取 Authentication 产生的身份
+	RateLimit *RouteRateLimitPolicy `json:"rate_limit,omitempty" yaml:"rate-limit,omitempty" mapstructure:"rate-limit"`
+	Cache     *RouteCachePolicy     `json:"cache,omitempty" yaml:"cache,omitempty" mapstructure:"cache"`
+	Transform *RouteTransformPolicy `json:"transform,omitempty" yaml:"transform,omitempty" mapstructure:"transform"`
+	Feature   string                `json:"feature,omitempty" yaml:"feature,omitempty" mapstructure:"feature"` // 所属功能（如 mock），该功能在当前环境关闭时路由不生效
+}
+
+// StaticResponse 静态响应，Body、Template 与 JSON 三选一
+type StaticResponse struct {
+	Status   int               `json:"status" yaml:"status" mapstructure:"status"`       // 状态码，默认 200
+	Headers  map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`    // 响应头
+	Body     string            `json:"body" yaml:"body" mapstructure:"body"`             // 原样返回的响应体
+	Template string            `json:"template" yaml:"template" mapstructure:"template"` // Go 模板响应体，可引用请求变量；Content-Type 含 html 时按 HTML 转义
+	JSON     any               `json:"json" yaml:"json" mapstructure:"json"`             // 编码为 JSON 返回，默认 Content-Type 为 application/json
+}
+
+// RouteRedirect 重定向规则
+type RouteRedirect struct {
+	Status    int    `json:"status" yaml:"status" mapstructure:"status"`             // 301 / 302 / 303 / 307 / 308，默认 302
+	Regex     string `json:"regex" yaml:"regex" mapstructure:"regex"`                // 匹配请求路径的正则，不匹配时交给后续处理器；为空时 To 为固定地址
+	To        string `json:"to" yaml:"to" mapstructure:"to"`                         // 目标地址，可用 $1、${name} 引用 Regex 的分组
+	DropQuery bool   `json:"drop_query" yaml:"drop-query" mapstructure:"drop-query"` // 不保留原查询串，默认追加到目标地址
+}
+
+// RouteAuthPolicy 路由认证要求；配置了角色或授权范围时隐含 Required
+type RouteAuthPolicy struct {
+	Required bool     `json:"required" yaml:"required" mapstructure:"required"` // 拒绝匿名请求
+	Roles    []string `json:"roles" yaml:"roles" mapstructure:"roles"`          // 具备任一角色
+	Scopes   []string `json:"scopes" yaml:"scopes" mapstructure:"scopes"`       // 具备全部授权范围
+}
+
+// RouteRateLimitPolicy 路由限流（令牌桶）
+type RouteRateLimitPolicy struct {
+	RequestsPerSecond int             `json:"requests_per_second" yaml:"requests-per-second" mapstructure:"requests-per-second"`
+	BurstSize         int             `json:"burst_size" yaml:"burst-size" mapstructure:"burst-size"` // 默认等于 RequestsPerSecond
+	Scope             ratelimit.Scope `json:"scope" yaml:"scope" mapstructure:"scope"`                // global / per-ip / per-user，默认 per-ip
+}
+
+// RouteCachePolicy 路由缓存指令，语义同 CacheRule，仅对 GET / HEAD 的可缓存状态码生效
+type RouteCachePolicy struct {
+	CacheControl     string   `json:"cache_control" yaml:"cache-control" mapstructure:"cache-control"`
+	SurrogateControl string   `json:"surrogate_control" yaml:"surrogate-control" mapstructure:"surrogate-control"`
+	CDNCacheControl  string   `json:"cdn_cache_control" yaml:"cdn-cache-control" mapstructure:"cdn-cache-control"`
+	SurrogateKeys    []string `json:"surrogate_keys" yaml:"surrogate-keys" mapstructure:"surrogate-keys"`
+	Vary             []string `json:"vary" yaml:"vary" mapstructure:"vary"`
+	Override         bool     `json:"override" yaml:"override" mapstructure:"override"`
+}
+
+// RouteTransformPolicy 请求路径与请求 / 响应头改写
+type RouteTransformPolicy struct {
+	StripPrefix     string          `json:"strip_prefix" yaml:"strip-prefix" mapstructure:"strip-prefix"` // 转发前去掉的路径前缀
+	AddPrefix       string          `json:"add_prefix" yaml:"add-prefix" mapstructure:"add-prefix"`       // 转发前追加的路径前缀（在 StripPrefix 之后）
+	RequestHeaders  HeaderTransform `json:"request_headers" yaml:"request-headers" mapstructure:"request-headers"`
+	ResponseHeaders HeaderTransform `json:"response_headers" yaml:"response-headers" mapstructure:"response-headers"`
+}
+
+// HeaderTransform 请求头 / 响应头改写，先删除后设置
+type HeaderTransform struct {
+	Set    map[string]string `json:"set" yaml:"set" mapstructure:"set"`
+	Remove []string          `json:"remove" yaml:"remove" mapstructure:"remove"`
+}
+
+// DeclarativeRouter 编译后的声明式路由
+type DeclarativeRouter struct {
+	routes    *RouteTable
+	upstreams map[string]*routeUpstream
+	limiter   *TokenBucketLimiter
+	count     int
+}
+
+// routeUpstream 校验后的上游
+type routeUpstream struct {
+	DeclarativeUpstream
+	target    *url.URL
+	rewrite   *upstreamRewriter
+	transport *upstreamTransport
+}
+
+// declarativeRoute 编译后的单条路由
+type declarativeRoute struct {
+	name      string
+	auth      *RouteAuthPolicy
+	rateLimit *ratelimit.LimitRule
+	scope     ratelimit.Scope
+	result    string         // 交给 handler 时记录的指标结果
+	upstream  *routeUpstream // 转发路由的上游
+	transform *RouteTransformPolicy
+	match     func(path string) bool // 路径的附加条件，不满足时交给后续处理器
+	handler   http.Handler
+}
+
+// declarativeRouteRequests 声明式路由请求数（注册到默认 Registry）
+var declarativeRouteRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_declarative_route_requests_total",
+	Help: "Total number of requests handled by declarative routes by route and result",
+}, []string{"route", "result"})
+
+// 声明式路由处理结果（指标标签）
+const (
+	declarativeResultProxied         = "proxied"
+	declarativeResultStatic          = "static"
+	declarativeResultRedirected      = "redirected"
+	declarativeResultUnauthenticated = "unauthenticated"
+	declarativeResultForbidden       = "forbidden"
+	declarativeResultRateLimited     = "rate_limited"
+	declarativeResultUpstreamError   = "upstream_error"
+)
+
+// ParseDeclarativeRoutes 把配置文件中 routes 段的原始值解析为配置，未知字段视为错误
+func ParseDeclarativeRoutes(raw any) (*DeclarativeRoutesConfig, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "routes: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	cfg := &DeclarativeRoutesConfig{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "routes: %v", err)
+	}
+	return cfg, nil
+}
+
+// NewDeclarativeRouter 校验配置并编译路由
+func NewDeclarativeRouter(cfg DeclarativeRoutesConfig) (*DeclarativeRouter, error) {
+	upstreams := make(map[string]*routeUpstream, len(cfg.Upstreams))
+	for i := range cfg.Upstreams {
+		u := cfg.Upstreams[i]
+		if u.Name == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: upstream %d has no name", i)
+		}
+		if _, ok := upstreams[u.Name]; ok {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: duplicate upstream %q", u.Name)
+		}
+		upstream, err := newRouteUpstream(u)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: %v", err)
+		}
+		upstreams[u.Name] = upstream
+	}
+
+	var patterns []RoutePattern
+	active := 0
+	for i := range cfg.Rules {
+		rule := cfg.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("route-%d", i)
+		}
+		route, err := compileDeclarativeRoute(rule, upstreams)
+		if err != nil {
+			return nil, err
+		}
+		// 功能关闭的路由仍需通过校验，切换环境时不会暴露配置错误
+		if rule.Feature != "" && !FeatureEnabled(rule.Feature) {
+			continue
+		}
+		active++
+		for _, path := range rule.Paths {
+			patterns = append(patterns, authnPattern(path, rule.Methods, route))
+		}
+	}
+	return &DeclarativeRouter{
+		routes:    NewRouteTable(patterns),
+		upstreams: upstreams,
+		limiter:   NewTokenBucketLimiter(nil),
+		count:     active,
+	}, nil
+}
+
+// newRouteUpstream 校验上游地址并创建改写器与连接池
+func newRouteUpstream(u DeclarativeUpstream) (*routeUpstream, error) {
+	target, err := url.Parse(u.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("upstream %q has invalid url %q", u.Name, u.URL)
+	}
+	if u.Timeout < 0 {
+		return nil, fmt.Errorf("upstream %q timeout must not be negative", u.Name)
+	}
+	upstream := &routeUpstream{DeclarativeUpstream: u, target: target}
+	if u.Rewrite != nil {
+		if upstream.rewrite, err = newUpstreamRewriter(*u.Rewrite); err != nil {
+			return nil, fmt.Errorf("upstream %q rewrite: %v", u.Name, err)
+		}
+	}
+	if u.TLS != nil && target.Scheme != "https" {
+		return nil, fmt.Errorf("upstream %q sets tls but url is not https", u.Name)
+	}
+	if upstream.transport, err = newUpstreamTransport(u.Name, u.Dial, u.TLS); err != nil {
+		return nil, fmt.Errorf("upstream %q: %v", u.Name, err)
+	}
+	return upstream, nil
+}
+
+// compileDeclarativeRoute 校验单条路由并组装处理器：缓存指令包裹改写，改写包裹上游转发或静态响应
+func compileDeclarativeRoute(rule DeclarativeRoute, upstreams map[string]*routeUpstream) (*declarativeRoute, error) {
+	if len(rule.Paths) == 0 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q has no paths", rule.Name)
+	}
+	for _, path := range rule.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q path %q must start with /", rule.Name, path)
+		}
+	}
+	targetCount := 0
+	for _, set := range []bool{rule.Upstream != "", rule.Response != nil, rule.Redirect != nil} {
+		if set {
+			targetCount++
+		}
+	}
+	if targetCount != 1 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q must set exactly one of upstream, response or redirect", rule.Name)
+	}
+
+	route := &declarativeRoute{name: rule.Name, auth: rule.Auth, transform: rule.Transform}
+	if rule.Auth != nil && (len(rule.Auth.Roles) > 0 || len(rule.Auth.Scopes) > 0) {
+		route.auth = &RouteAuthPolicy{Required: true, Roles: rule.Auth.Roles, Scopes: rule.Auth.Scopes}
+	}
+
+	if limit := rule.RateLimit; limit != nil {
+		if limit.RequestsPerSecond <= 0 || limit.BurstSize < 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q rate limit must be positive", rule.Name)
+		}
+		burst := limit.BurstSize
+		if burst == 0 {
+			burst = limit.RequestsPerSecond
+		}
+		route.rateLimit = &ratelimit.LimitRule{RequestsPerSecond: limit.RequestsPerSecond, BurstSize: burst}
+		switch limit.Scope {
+		case "":
+			route.scope = ratelimit.ScopePerIP
+		case ratelimit.ScopeGlobal, ratelimit.ScopePerIP, ratelimit.ScopePerUser:
+			route.scope = limit.Scope
+		default:
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q has unsupported rate limit scope %q", rule.Name, limit.Scope)
+		}
+	}
+
+	var handler http.Handler
+	switch {
+	case rule.Upstream != "":
+		upstream, ok := upstreams[rule.Upstream]
+		if !ok {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q references unknown upstream %q", rule.Name, rule.Upstream)
+		}
+		handler = newRouteProxy(rule.Name, upstream)
+		route.result, route.upstream = declarativeResultProxied, upstream
+	case rule.Response != nil:
+		static, err := newStaticHandler(rule.Name, rule.Response)
+		if err != nil {
+			return nil, err
+		}
+		handler = static
+		route.result = declarativeResultStatic
+	default:
+		if t := rule.Transform; t != nil && (t.StripPrefix != "" || t.AddPrefix != "") {
+			// 重定向按原始路径匹配与展开，路径改写会使二者不一致
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q redirect cannot be combined with path transform", rule.Name)
+		}
+		redirect, err := newRedirectHandler(rule.Name, rule.Redirect)
+		if err != nil {
+			return nil, err
+		}
+		handler = redirect
+		route.result = declarativeResultRedirected
+		if redirect.regex != nil {
+			route.match = redirect.regex.MatchString
+		}
+	}
+
+	if t := rule.Transform; t != nil {
+		if t.StripPrefix != "" && !strings.HasPrefix(t.StripPrefix, "/") {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q strip-prefix must start with /", rule.Name)
+		}
+		if t.AddPrefix != "" && !strings.HasPrefix(t.AddPrefix, "/") {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q add-prefix must start with /", rule.Name)
+		}
+		handler = t.wrap(handler)
+	}
+
+	if c := rule.Cache; c != nil {
+		cache, err := NewCacheDirectives(CacheControlConfig{Rules: []CacheRule{{
+			Paths:            rule.Paths,
+			CacheControl:     c.CacheControl,
+			SurrogateControl: c.SurrogateControl,
+			CDNCacheControl:  c.CDNCacheControl,
+			SurrogateKeys:    c.SurrogateKeys,
+			Vary:             c.Vary,
+			Override:         c.Override,
+		}}})
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routes: route %q: %v", rule.Name, err)
+		}
+		handler = cache.HTTPMiddleware(handler)
+	}
+
+	route.handler = handler
+	return route, nil
+}
+
+// newRouteProxy 创建转发到上游的反向代理，先按上游的改写规则改写路径与查询串；转发错误按 ClassifyUpstreamError 分类返回
+func newRouteProxy(route string, upstream *routeUpstream) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Transport: upstream.transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if upstream.rewrite != nil {
+				upstream.rewrite.apply(pr.Out.URL, false)
+			}
+			pr.SetURL(upstream.target)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			declarativeRouteRequests.WithLabelValues(route, declarativeResultUpstreamError).Inc()
+			if stderrors.Is(err, context.Canceled) && r.Context().Err() != nil {
+				return // 客户端已断开
+			}
+			if stderrors.Is(err, ErrBulkheadFull) {
+				global.LOGGER.WarnContext(r.Context(), "声明式路由转发失败: route=%s, upstream=%s, error=%v", route, upstream.Name, err)
+				response.WriteErrorResponse(w, errors.ErrServiceUnavailable)
+				return
+			}
+			class := ClassifyUpstreamError(err)
+			recordUpstreamError(upstream.Name, class)
+			global.LOGGER.WarnContext(r.Context(), "声明式路由转发失败: route=%s, upstream=%s, class=%s, error=%v", route, upstream.Name, class, err)
+			WriteUpstreamError(w, class)
+		},
+		// 上游返回的 5xx 原样透传，只计入上游失败指标
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				recordUpstreamError(upstream.Name, UpstreamErrorServer)
+			}
+			return nil
+		},
+	}
+	if upstream.Timeout <= 0 {
+		return proxy
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), upstream.Timeout)
+		defer cancel()
+		proxy.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// wrap 改写请求路径与请求头，并在写出响应头前改写响应头
+func (t *RouteTransformPolicy) wrap(next http.Handler) http.Handler {
+	rewriteResponse := len(t.ResponseHeaders.Set) > 0 || len(t.ResponseHeaders.Remove) > 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		t.rewritePath(r.URL)
+		t.RequestHeaders.apply(r.Header)
+		if rewriteResponse {
+			w = &cacheHeaderWriter{ResponseWriter: w, apply: func(int) {
+				t.ResponseHeaders.apply(w.Header())
+			}}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rewritePath 先去掉 StripPrefix 再追加 AddPrefix，二者都未配置时不改动
+func (t *RouteTransformPolicy) rewritePath(u *url.URL) {
+	if t.StripPrefix == "" && t.AddPrefix == "" {
+		return
+	}
+	path := t.AddPrefix + strings.TrimPrefix(u.Path, t.StripPrefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u.Path, u.RawPath = path, ""
+}
+
+// apply 删除并设置头
+func (h HeaderTransform) apply(header http.Header) {
+	for _, name := range h.Remove {
+		header.Del(name)
+	}
+	for name, value := range h.Set {
+		header.Set(name, value)
+	}
+}
+
+// Len 生效的路由条数（不含所属功能关闭的路由）
+func (d *DeclarativeRouter) Len() int {
+	return d.count
+}
+
+// Handle 命中路由时按认证、限流要求处理后转发或返回静态响应，未命中时交给 next
+func (d *DeclarativeRouter) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	value, ok := d.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	route := value.(*declarativeRoute)
+	if route.match != nil && !route.match(r.URL.Path) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if auth := route.auth; auth != nil && auth.Required {
+		principal := contextPrincipal(r.Context())
+		if principal.IsAnonymous() {
+			declarativeRouteRequests.WithLabelValues(route.name, declarativeResultUnauthenticated).Inc()
+			response.WriteErrorResponseWithCode(w, http.StatusUnauthorized, constants.AuthnErrorCodeUnauthenticated, constants.AuthnErrorUnauthenticated)
+			return
+		}
+		if !principal.HasAnyRole(auth.Roles...) || !principal.HasScopes(auth.Scopes...) {
+			declarativeRouteRequests.WithLabelValues(route.name, declarativeResultForbidden).Inc()
+			response.WriteErrorResponseWithCode(w, http.StatusForbidden, constants.AuthnErrorCodeForbidden, constants.AuthnErrorForbidden)
+			return
+		}
+	}
+
+	if route.rateLimit != nil {
+		allowed, err := d.limiter.Allow(r.Context(), d.rateLimitKey(r, route), route.rateLimit)
+		if err != nil {
+			response.WriteAppError(w, errors.NewError(errors.ErrCodeInternalServerError, err.Error()))
+			return
+		}
+		if !allowed {
+			declarativeRouteRequests.WithLabelValues(route.name, declarativeResultRateLimited).Inc()
+			response.WriteErrorResponse(w, errors.ErrRateLimitExceeded)
+			return
+		}
+	}
+
+	declarativeRouteRequests.WithLabelValues(route.name, route.result).Inc()
+	route.handler.ServeHTTP(w, r)
+}
+
+// HTTPMiddleware 声明式路由中间件
+func (d *DeclarativeRouter) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.Handle(w, r, next)
+	})
+}
+
+// rateLimitKey 按作用域生成限流 key，各路由的计数相互独立
+func (d *DeclarativeRouter) rateLimitKey(r *http.Request, route *declarativeRoute) string {
+	switch route.scope {
+	case ratelimit.ScopeGlobal:
+		return fmt.Sprintf(keyFormatRoute, route.name)
+	case ratelimit.ScopePerUser:
+		return fmt.Sprintf(keyFormatRouteUser, route.name, GetRequestCommonMeta(r.Context()).UserID)
+	default:
+		return fmt.Sprintf(keyFormatRouteIP, route.name, netx.GetClientIP(r))
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\descriptors.go
+ * @Description: 服务描述符注册表 - 以 FileDescriptorSet 导出已注册服务及其依赖的 proto 描述，
+ *               供 Mock 生成、文档门户、JSON-RPC 桥接等内部工具直接读取 RPC 定义而不必分发 .proto 文件；
+ *               管理接口要求已认证的调用方（可再限定角色）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DefaultDescriptorsPath 描述符查询接口默认路径
+const DefaultDescriptorsPath = "/admin/descriptors"
+
+// DescriptorsConfig 描述符查询接口配置
+type DescriptorsConfig struct {
+	Path  string   // 接口路径，默认 /admin/descriptors
+	Roles []string // 调用方需具备其中任一角色，为空时只要求已认证；身份由认证中间件写入
+	// Services 额外暴露的服务（完整服务名，支持 path.Match 通配，如 "km.order.*"），在全部已链接的 proto 中匹配；
+	// 默认只暴露本进程 gRPC Server 注册与 reflection 发现的服务，RegisterProxyHandler 转发的服务需在此列出
+	Services []string
+}
+
+// DescriptorSource 列出已注册服务的完整服务名
+type DescriptorSource func() []string
+
+// Descriptors 服务描述符注册表
+type Descriptors struct {
+	config DescriptorsConfig
+	source DescriptorSource
+	files  *protoregistry.Files
+}
+
+// DescriptorService 服务摘要
+type DescriptorService struct {
+	Name    string             `json:"name"`
+	File    string             `json:"file"`
+	Methods []DescriptorMethod `json:"methods"`
+}
+
+// DescriptorMethod 方法摘要
+type DescriptorMethod struct {
+	Name            string `json:"name"`
+	Input           string `json:"input"`
+	Output          string `json:"output"`
+	ClientStreaming bool   `json:"client_streaming,omitempty"`
+	ServerStreaming bool   `json:"server_streaming,omitempty"`
+}
+
+// NewDescriptors 创建描述符注册表，source 为空时只使用 Services 匹配的服务
+func NewDescriptors(cfg DescriptorsConfig, source DescriptorSource) (*Descriptors, error) {
+	cfg.Path = strings.TrimSuffix(cfg.Path, "/")
+	if cfg.Path == "" {
+		cfg.Path = DefaultDescriptorsPath
+	}
+	for _, pattern := range cfg.Services {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "descriptors: invalid service pattern %q", pattern)
+		}
+	}
+	return &Descriptors{config: cfg, source: source, files: protoregistry.GlobalFiles}, nil
+}
+
+// AdminPath 管理接口路径
+func (d *Descriptors) AdminPath() string {
+	return d.config.Path
+}
+
+// ServiceNames 暴露的服务名（已排序），只包含能在注册表中找到描述的服务
+func (d *Descriptors) ServiceNames() []string {
+	var names []string
+	if d.source != nil {
+		for _, name := range d.source() {
+			if _, ok := d.service(name); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(d.config.Services) > 0 {
+		d.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			services := fd.Services()
+			for i := range services.Len() {
+				name := string(services.Get(i).FullName())
+				if slices.ContainsFunc(d.config.Services, func(pattern string) bool {
+					matched, _ := path.Match(pattern, name)
+					return matched
+				}) {
+					names = append(names, name)
+				}
+			}
+			return true
+		})
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// service 查找服务描述
+func (d *Descriptors) service(name string) (protoreflect.ServiceDescriptor, bool) {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, false
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	return sd, ok
+}
+
+// FileDescriptorSet 导出服务所在文件及其全部依赖（依赖在前），services 为空时导出全部暴露的服务
+func (d *Descriptors) FileDescriptorSet(services ...string) (*descriptorpb.FileDescriptorSet, *errors.AppError) {
+	exposed := d.ServiceNames()
+	if len(services) == 0 {
+		services = exposed
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	for _, name := range services {
+		sd, ok := d.service(name)
+		if !ok || !slices.Contains(exposed, name) {
+			return nil, errors.NewErrorf(errors.ErrCodeNotFound, "service %s is not registered", name)
+		}
+		appendDescriptorFile(set, sd.ParentFile(), seen)
+	}
+	return set, nil
+}
+
+// appendDescriptorFile 先追加依赖再追加文件本身，未解析到的占位依赖跳过
+func appendDescriptorFile(set *descriptorpb.FileDescriptorSet, fd protoreflect.FileDescriptor, seen map[string]bool) {
+	if fd.IsPlaceholder() || seen[fd.Path()] {
+		return
+	}
+	seen[fd.Path()] = true
+	imports := fd.Imports()
+	for i := range imports.Len() {
+		appendDescriptorFile(set, imports.Get(i).FileDescriptor, seen)
+	}
+	set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+}
+
+// Services 暴露的服务与方法摘要
+func (d *Descriptors) Services() []DescriptorService {
+	names := d.ServiceNames()
+	result := make([]DescriptorService, 0, len(names))
+	for _, name := range names {
+		sd, _ := d.service(name)
+		svc := DescriptorService{Name: name, File: sd.ParentFile().Path()}
+		methods := sd.Methods()
+		for i := range methods.Len() {
+			m := methods.Get(i)
+			svc.Methods = append(svc.Methods, DescriptorMethod{
+				Name:            string(m.Name()),
+				Input:           string(m.Input().FullName()),
+				Output:          string(m.Output().FullName()),
+				ClientStreaming: m.IsStreamingClient(),
+				ServerStreaming: m.IsStreamingServer(),
+			})
+		}
+		result = append(result, svc)
+	}
+	return result
+}
+
+// AdminHandler 描述符管理接口：
+//
+//	GET {path}            FileDescriptorSet，?service= 可重复，只导出指定服务及其依赖；
+//	                      Accept 为 application/x-protobuf 或 ?format=binary 时返回二进制，否则返回 protojson
+//	GET {path}/services   服务与方法列表
+func (d *Descriptors) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := contextPrincipal(r.Context())
+		if p.IsAnonymous() {
+			response.WriteAppError(w, errors.ErrUnauthorized)
+			return
+		}
+		if !p.HasAnyRole(d.config.Roles...) {
+			response.WriteAppError(w, errors.ErrForbidden)
+			return
+		}
+		if r.Method != http.MethodGet {
+			response.WriteAppError(w, errors.ErrMethodNotAllowed)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, d.config.Path) {
+		case "", "/":
+			d.writeFileDescriptorSet(w, r)
+		case "/services":
+			response.WriteJSONResponse(w, http.StatusOK, d.Services())
+		default:
+			response.WriteNotFoundResult(w, "unknown descriptors endpoint")
+		}
+	}
+}
+
+// writeFileDescriptorSet 按内容协商写出 FileDescriptorSet
+func (d *Descriptors) writeFileDescriptorSet(w http.ResponseWriter, r *http.Request) {
+	set, appErr := d.FileDescriptorSet(r.URL.Query()["service"]...)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+
+	binary := r.URL.Query().Get("format") == "binary" ||
+		strings.Contains(r.Header.Get(constants.HeaderAccept), constants.ContentTypeXProtobuf) ||
+		strings.Contains(r.Header.Get(constants.HeaderAccept), constants.ContentTypeProtobuf)
+	var (
+		body        []byte
+		err         error
+		contentType = httpx.ContentTypeApplicationJSON
+	)
+	if binary {
+		body, err = proto.Marshal(set)
+		contentType = constants.ContentTypeXProtobuf
+	} else {
+		body, err = protojson.Marshal(set)
+	}
+	if err != nil {
+		response.WriteInternalServerErrorResult(w, err.Error())
+		return
+	}
+	w.Header().Set(constants.HeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,549 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ext_authz.go
+ * @Description: 外部授权中间件 - 请求交给可插拔的授权器（OPA / Envoy ext_authz 兼容服务 / 自定义）判定，
+ *               支持决策缓存、授权服务故障时放行或拒绝（fail-open / fail-closed）与决策日志
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultExtAuthzTimeout         = time.Second // 默认单次授权调用超时
+	DefaultExtAuthzCacheMaxEntries = 10000       // 默认决策缓存条目上限
+)
+
+// 授权请求协议
+const (
+	AuthzProtocolHTTP = "http"
+	AuthzProtocolGRPC = "grpc"
+)
+
+// AuthzRequest 交给授权器判定的请求属性（同时作为 OPA 策略的 input）
+type AuthzRequest struct {
+	Protocol   string            `json:"protocol"`        // http / grpc
+	Method     string            `json:"method"`          // HTTP 方法，gRPC 为 POST
+	Path       string            `json:"path"`            // HTTP 路径，gRPC 为完整方法名 /pkg.Service/Method
+	Query      string            `json:"query,omitempty"` // 原始查询字符串
+	Host       string            `json:"host"`
+	Scheme     string            `json:"scheme"`
+	Headers    map[string]string `json:"headers"` // 小写请求头（gRPC 为 metadata），多值以逗号连接
+	ClientIP   string            `json:"client_ip"`
+	RemoteAddr string            `json:"remote_addr"`
+	Principal  *Principal        `json:"principal,omitempty"` // 认证中间件已识别的身份
+}
+
+// AuthzDecision 授权决策
+type AuthzDecision struct {
+	Allowed         bool              `json:"allowed"`
+	Status          int               `json:"status,omitempty"`           // 拒绝时返回的 HTTP 状态码，默认 403（gRPC 映射为对应状态码）
+	Reason          string            `json:"reason,omitempty"`           // 拒绝原因，作为错误信息返回给调用方
+	Body            string            `json:"body,omitempty"`             // 拒绝时原样返回的 HTTP 响应体（ext_authz 服务的响应），为空时使用网关统一错误格式
+	Headers         map[string]string `json:"headers,omitempty"`          // 拒绝时附加的响应头
+	UpstreamHeaders map[string]string `json:"upstream_headers,omitempty"` // 放行时追加到请求上的头（如解析出的用户 ID），gRPC 追加到 metadata
+	Principal       *Principal        `json:"principal,omitempty"`        // 放行时授权服务识别出的身份，写入请求上下文并转发
+}
+
+// Authorizer 授权器
+type Authorizer interface {
+	// Authorize 判定请求；返回错误表示授权服务不可用，按 FailOpen 处理
+	Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error)
+}
+
+// AuthorizerFunc 函数形式的授权器
+type AuthorizerFunc func(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error)
+
+// Authorize 实现 Authorizer
+func (f AuthorizerFunc) Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+	return f(ctx, req)
+}
+
+// AuthzDecisionLog 决策日志
+type AuthzDecisionLog struct {
+	Time     time.Time     `json:"time"`
+	Protocol string        `json:"protocol"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	ClientIP string        `json:"client_ip"`
+	Allowed  bool          `json:"allowed"`
+	Status   int           `json:"status,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+	Cached   bool          `json:"cached"`
+	Error    string        `json:"error,omitempty"` // 授权服务调用失败原因（此时 Allowed 取决于 FailOpen）
+	Duration time.Duration `json:"duration"`
+}
+
+// ExtAuthzConfig 外部授权配置
+type ExtAuthzConfig struct {
+	Authorizer Authorizer // 授权器，必填
+
+	Timeout       time.Duration // 单次授权调用超时，默认 1s
+	FailOpen      bool          // 授权服务不可用（超时、连接失败）时放行；默认拒绝
+	FailureStatus int           // 授权服务不可用且拒绝时返回的 HTTP 状态码，默认 403
+
+	CacheTTL        time.Duration // 决策缓存时间，0 不缓存；授权服务调用失败的结果不缓存
+	CacheMaxEntries int           // 缓存条目上限，默认 10000
+	CacheKeyHeaders []string      // 参与缓存键的请求头（与协议、方法、路径、规范化后的查询参数组合），默认 Authorization
+	CacheKeyIgnore  []string      // 不参与缓存键的查询参数（如 DefaultCacheKeyNoiseParams），支持 * 前后缀匹配
+
+	SkipPaths []string // 不做授权的 HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+
+	DecisionLog bool                   // 记录每次决策的日志
+	OnDecision  func(AuthzDecisionLog) // 决策回调（审计、上报），在请求协程中同步执行
+}
+
+// authzDecisionsTotal 授权决策数（注册到默认 Registry）
+var authzDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_authz_decisions_total",
+	Help: "Total number of external authorization decisions",
+}, []string{"protocol", "result", "cached"})
+
+// ExtAuthz 编译后的外部授权处理器
+type ExtAuthz struct {
+	config ExtAuthzConfig
+	skip   *RouteTable
+	cache  *authzCache
+	keys   *CacheKeyBuilder
+}
+
+// NewExtAuthz 校验配置并创建外部授权处理器
+func NewExtAuthz(cfg ExtAuthzConfig) (*ExtAuthz, error) {
+	if cfg.Authorizer == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "ext authz requires an authorizer")
+	}
+	if cfg.Timeout < 0 || cfg.CacheTTL < 0 || cfg.CacheMaxEntries < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "ext authz timeout and cache settings must not be negative")
+	}
+	if cfg.FailureStatus != 0 && (cfg.FailureStatus < 400 || cfg.FailureStatus > 599) {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "ext authz failure status %d is not an error status", cfg.FailureStatus)
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultExtAuthzTimeout
+	}
+	if cfg.FailureStatus == 0 {
+		cfg.FailureStatus = http.StatusForbidden
+	}
+	if cfg.CacheMaxEntries == 0 {
+		cfg.CacheMaxEntries = DefaultExtAuthzCacheMaxEntries
+	}
+	if len(cfg.CacheKeyHeaders) == 0 {
+		cfg.CacheKeyHeaders = []string{constants.HeaderAuthorization}
+	}
+
+	a := &ExtAuthz{config: cfg}
+	patterns := make([]RoutePattern, 0, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Value: true}
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			p.Kind, p.Pattern = RouteMatchPrefix, prefix
+		}
+		patterns = append(patterns, p)
+	}
+	a.skip = NewRouteTable(patterns)
+	if cfg.CacheTTL > 0 {
+		a.cache = newAuthzCache(cfg.CacheTTL, cfg.CacheMaxEntries)
+		a.keys = NewCacheKeyBuilder(CacheKeyConfig{IgnoreParams: cfg.CacheKeyIgnore, Headers: cfg.CacheKeyHeaders})
+	}
+	return a, nil
+}
+
+// Check 判定请求，授权服务不可用时按 FailOpen 生成决策；返回值不为 nil
+func (a *ExtAuthz) Check(ctx context.Context, req *AuthzRequest) *AuthzDecision {
+	start := time.Now()
+	var key string
+	if a.cache != nil {
+		key = a.cacheKey(req)
+		if decision, ok := a.cache.get(key, start); ok {
+			a.record(ctx, req, decision, true, nil, time.Since(start))
+			return decision
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	decision, err := a.config.Authorizer.Authorize(callCtx, req)
+	cancel()
+	if err == nil && decision == nil {
+		err = stderrors.New("authorizer returned no decision")
+	}
+	if err != nil {
+		decision = &AuthzDecision{
+			Allowed: a.config.FailOpen,
+			Status:  a.config.FailureStatus,
+			Reason:  constants.AuthzErrorUnavailable,
+		}
+		a.record(ctx, req, decision, false, err, time.Since(start))
+		return decision
+	}
+
+	if !decision.Allowed && decision.Status == 0 {
+		decision.Status = http.StatusForbidden
+	}
+	if decision.Principal != nil && decision.Principal.AuthType == "" {
+		decision.Principal.AuthType = PrincipalAuthExtAuthz
+	}
+	if a.cache != nil {
+		a.cache.set(key, decision, time.Now())
+	}
+	a.record(ctx, req, decision, false, nil, time.Since(start))
+	return decision
+}
+
+// cacheKey 缓存键：协议、已识别的身份与规范化后的方法、路径、查询参数和指定请求头
+func (a *ExtAuthz) cacheKey(req *AuthzRequest) string {
+	prefix := req.Protocol
+	if req.Principal != nil {
+		prefix += "\x00" + req.Principal.Subject + "\x00" + req.Principal.Tenant
+	}
+	return prefix + "\x00" + a.keys.Key(CacheKeyInput{
+		Method:   req.Method,
+		Path:     req.Path,
+		RawQuery: req.Query,
+		Header: func(name string) string {
+			return req.Headers[strings.ToLower(name)]
+		},
+	})
+}
+
+// record 记录指标、决策日志与回调
+func (a *ExtAuthz) record(ctx context.Context, req *AuthzRequest, decision *AuthzDecision, cached bool, err error, duration time.Duration) {
+	result := constants.AuthzResultAllow
+	switch {
+	case err != nil:
+		result = constants.AuthzResultError
+	case !decision.Allowed:
+		result = constants.AuthzResultDeny
+	}
+	authzDecisionsTotal.WithLabelValues(req.Protocol, result, boolLabel(cached)).Inc()
+
+	if !a.config.DecisionLog && a.config.OnDecision == nil {
+		return
+	}
+	entry := AuthzDecisionLog{
+		Time:     time.Now(),
+		Protocol: req.Protocol,
+		Method:   req.Method,
+		Path:     req.Path,
+		ClientIP: req.ClientIP,
+		Allowed:  decision.Allowed,
+		Reason:   decision.Reason,
+		Cached:   cached,
+		Duration: duration,
+	}
+	if !decision.Allowed {
+		entry.Status = decision.Status
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if a.config.DecisionLog && global.LOGGER != nil {
+		global.LOGGER.InfoContextKV(ctx, "authz decision",
+			"protocol", entry.Protocol,
+			"method", entry.Method,
+			"path", entry.Path,
+			"client_ip", entry.ClientIP,
+			"allowed", entry.Allowed,
+			"status", entry.Status,
+			"reason", entry.Reason,
+			"cached", entry.Cached,
+			"error", entry.Error,
+			"duration_ms", duration.Milliseconds())
+	}
+	if a.config.OnDecision != nil {
+		a.config.OnDecision(entry)
+	}
+}
+
+// boolLabel 指标布尔标签
+func boolLabel(v bool) string {
+	if v {
+		return "true"
+	}
+	return "false"
+}
+
+// HTTPMiddleware HTTP 外部授权中间件
+func (a *ExtAuthz) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Handle(w, r, next)
+	})
+}
+
+// Handle 判定 HTTP 请求，放行时追加 UpstreamHeaders 后交给 next；签名 URL 请求不做判定
+func (a *ExtAuthz) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if a.Skip(r.Method, r.URL.Path) || IsSignedURLRequest(r.Context()) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	decision := a.Check(r.Context(), NewHTTPAuthzRequest(r))
+	if !decision.Allowed {
+		writeAuthzDenied(w, decision)
+		return
+	}
+	for name, value := range decision.UpstreamHeaders {
+		r.Header.Set(name, value)
+	}
+	if decision.Principal != nil {
+		for _, name := range principalHeaders {
+			r.Header.Del(name)
+		}
+		PrincipalToHeader(decision.Principal, r.Header)
+		r = r.WithContext(WithPrincipal(r.Context(), decision.Principal))
+	}
+	next.ServeHTTP(w, r)
+}
+
+// Skip 请求是否跳过授权
+func (a *ExtAuthz) Skip(method, path string) bool {
+	_, skip := a.skip.Match(method, path)
+	return skip
+}
+
+// writeAuthzDenied 写出拒绝响应：授权服务给出响应体时原样返回，否则使用统一错误格式
+func writeAuthzDenied(w http.ResponseWriter, decision *AuthzDecision) {
+	for name, value := range decision.Headers {
+		w.Header().Set(name, value)
+	}
+	if decision.Body != "" {
+		w.WriteHeader(decision.Status)
+		_, _ = w.Write([]byte(decision.Body))
+		return
+	}
+
+	code := constants.AuthzErrorCodeDenied
+	if decision.Reason == constants.AuthzErrorUnavailable {
+		code = constants.AuthzErrorCodeUnavailable
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = constants.AuthzErrorDenied
+	}
+	response.WriteErrorResponseWithCode(w, decision.Status, code, reason)
+}
+
+// UnaryServerInterceptor gRPC 一元调用外部授权拦截器
+func (a *ExtAuthz) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return a.Unary
+}
+
+// StreamServerInterceptor gRPC 流式调用外部授权拦截器
+func (a *ExtAuthz) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return a.Stream
+}
+
+// Unary 判定 gRPC 一元调用
+func (a *ExtAuthz) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if a.Skip(http.MethodPost, info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.checkGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream 判定 gRPC 流式调用（建立流时判定一次）
+func (a *ExtAuthz) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if a.Skip(http.MethodPost, info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := a.checkGRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	if ctx == ss.Context() {
+		return handler(srv, ss)
+	}
+	return handler(srv, &authzServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// checkGRPC 判定 gRPC 调用，放行时返回追加了 UpstreamHeaders 的上下文
+func (a *ExtAuthz) checkGRPC(ctx context.Context, fullMethod string) (context.Context, error) {
+	decision := a.Check(ctx, NewGRPCAuthzRequest(ctx, fullMethod))
+	if !decision.Allowed {
+		reason := decision.Reason
+		if reason == "" {
+			reason = constants.AuthzErrorDenied
+		}
+		return ctx, status.Error(authzGRPCCode(decision.Status), reason)
+	}
+	if len(decision.UpstreamHeaders) == 0 && decision.Principal == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	for name, value := range decision.UpstreamHeaders {
+		md.Set(name, value)
+	}
+	if decision.Principal == nil {
+		return metadata.NewIncomingContext(ctx, md), nil
+	}
+	for _, name := range principalHeaders {
+		md.Delete(name)
+	}
+	for key, values := range PrincipalToMetadata(decision.Principal) {
+		md[key] = values
+	}
+	return WithPrincipal(metadata.NewIncomingContext(ctx, md), decision.Principal), nil
+}
+
+// authzServerStream 替换上下文的 ServerStream
+type authzServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 返回追加了授权头的上下文
+func (s *authzServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authzGRPCCode 拒绝状态码映射为 gRPC 状态码
+func authzGRPCCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.PermissionDenied
+	}
+}
+
+// NewHTTPAuthzRequest 由 HTTP 请求生成授权请求
+func NewHTTPAuthzRequest(r *http.Request) *AuthzRequest {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return &AuthzRequest{
+		Protocol:   AuthzProtocolHTTP,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Host:       r.Host,
+		Scheme:     scheme,
+		Headers:    headers,
+		ClientIP:   netx.GetClientIP(r),
+		RemoteAddr: r.RemoteAddr,
+		Principal:  contextPrincipal(r.Context()),
+	}
+}
+
+// NewGRPCAuthzRequest 由 gRPC 调用上下文生成授权请求
+func NewGRPCAuthzRequest(ctx context.Context, fullMethod string) *AuthzRequest {
+	req := &AuthzRequest{
+		Protocol:  AuthzProtocolGRPC,
+		Method:    http.MethodPost,
+		Path:      fullMethod,
+		Scheme:    "http",
+		Headers:   make(map[string]string),
+		Principal: contextPrincipal(ctx),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for name, values := range md {
+			req.Headers[name] = strings.Join(values, ",")
+		}
+		req.Host = req.Headers[":authority"]
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			req.ClientIP = host
+		}
+		if p.AuthInfo != nil && p.AuthInfo.AuthType() == "tls" {
+			req.Scheme = "https"
+		}
+	}
+	return req
+}
+
+// authzCache 决策缓存
+type authzCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]authzCacheEntry
+}
+
+// authzCacheEntry 缓存条目
+type authzCacheEntry struct {
+	decision *AuthzDecision
+	expires  time.Time
+}
+
+// newAuthzCache 创建决策缓存
+func newAuthzCache(ttl time.Duration, maxEntries int) *authzCache {
+	return &authzCache{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]authzCacheEntry)}
+}
+
+// get 读取未过期的决策
+func (c *authzCache) get(key string, now time.Time) (*AuthzDecision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.decision, true
+}
+
+// set 写入决策，达到上限时先清理过期条目，仍然满时随机淘汰
+func (c *authzCache) set(key string, decision *AuthzDecision, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = authzCacheEntry{decision: decision, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,259 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ext_authz_clients.go
+ * @Description: 内置授权器 - Envoy HTTP ext_authz 兼容服务、OPA REST API 与内嵌 OPA（rego）策略求值
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// authzMaxResponseBody 授权服务响应体读取上限
+const authzMaxResponseBody = 64 << 10
+
+// HTTPExtAuthzConfig Envoy HTTP ext_authz 兼容服务配置
+// 检查请求沿用原请求的方法与路径（不含请求体）；2xx 放行，5xx 视为服务不可用，其余状态拒绝并原样返回给调用方
+type HTTPExtAuthzConfig struct {
+	URL                    string       // 授权服务地址，如 http://authz:9000
+	PathPrefix             string       // 检查请求路径前缀，拼接在原路径之前
+	AllowedHeaders         []string     // 转发给授权服务的请求头，为空转发全部
+	AllowedUpstreamHeaders []string     // 放行时从授权响应复制到上游请求的头
+	AllowedClientHeaders   []string     // 拒绝时从授权响应复制给调用方的头，为空复制全部
+	Client                 *http.Client // HTTP 客户端，默认 http.DefaultClient（超时由 ExtAuthzConfig.Timeout 控制）
+}
+
+// httpExtAuthz Envoy HTTP ext_authz 兼容授权器
+type httpExtAuthz struct {
+	config HTTPExtAuthzConfig
+	base   *url.URL
+}
+
+// NewHTTPExtAuthz 创建 Envoy HTTP ext_authz 兼容授权器
+func NewHTTPExtAuthz(cfg HTTPExtAuthzConfig) (Authorizer, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid ext authz url %q", cfg.URL)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &httpExtAuthz{config: cfg, base: base}, nil
+}
+
+// Authorize 实现 Authorizer
+func (a *httpExtAuthz) Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+	target := *a.base
+	target.Path = strings.TrimSuffix(a.base.Path, "/") + a.config.PathPrefix + req.Path
+	target.RawQuery = req.Query
+
+	checkReq, err := http.NewRequestWithContext(ctx, req.Method, target.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.config.AllowedHeaders) == 0 {
+		for name, value := range req.Headers {
+			if !strings.HasPrefix(name, ":") {
+				checkReq.Header.Set(name, value)
+			}
+		}
+	} else {
+		for _, name := range a.config.AllowedHeaders {
+			if value, ok := req.Headers[strings.ToLower(name)]; ok {
+				checkReq.Header.Set(name, value)
+			}
+		}
+	}
+	checkReq.Host = req.Host
+	if req.ClientIP != "" {
+		checkReq.Header.Set(constants.HeaderXForwardedFor, req.ClientIP)
+	}
+
+	resp, err := a.config.Client.Do(checkReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, authzMaxResponseBody))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return &AuthzDecision{Allowed: true, UpstreamHeaders: pickHeaders(resp.Header, a.config.AllowedUpstreamHeaders, false)}, nil
+	case resp.StatusCode >= 500:
+		return nil, fmt.Errorf("ext authz service returned %d", resp.StatusCode)
+	default:
+		return &AuthzDecision{
+			Status:  resp.StatusCode,
+			Reason:  http.StatusText(resp.StatusCode),
+			Body:    string(body),
+			Headers: pickHeaders(resp.Header, a.config.AllowedClientHeaders, true),
+		}, nil
+	}
+}
+
+// pickHeaders 按名单复制响应头，名单为空时 all 决定是否复制全部
+func pickHeaders(header http.Header, names []string, all bool) map[string]string {
+	picked := make(map[string]string)
+	if len(names) == 0 {
+		if all {
+			for name := range header {
+				if name != constants.HeaderContentLength {
+					picked[name] = header.Get(name)
+				}
+			}
+		}
+		return picked
+	}
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			picked[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return picked
+}
+
+// OPAConfig OPA REST API 配置（OPA 以 sidecar / 独立服务运行）
+type OPAConfig struct {
+	URL    string       // OPA 服务地址，如 http://127.0.0.1:8181
+	Path   string       // 决策文档路径，如 httpapi/authz，请求 POST /v1/data/httpapi/authz
+	Client *http.Client // HTTP 客户端，默认 http.DefaultClient
+}
+
+// opaAuthorizer OPA REST API 授权器
+type opaAuthorizer struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOPAAuthorizer 创建 OPA REST API 授权器，input 为 AuthzRequest
+func NewOPAAuthorizer(cfg OPAConfig) (Authorizer, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid OPA url %q", cfg.URL)
+	}
+	policy := strings.Trim(strings.ReplaceAll(cfg.Path, ".", "/"), "/")
+	if policy == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "OPA decision path is required")
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &opaAuthorizer{endpoint: strings.TrimSuffix(base.String(), "/") + "/v1/data/" + policy, client: client}, nil
+}
+
+// Authorize 实现 Authorizer
+func (a *opaAuthorizer) Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+	payload, err := json.Marshal(map[string]any{"input": req})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(constants.HeaderContentType, "application/json")
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OPA returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Result any `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, authzMaxResponseBody)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode OPA response: %w", err)
+	}
+	return DecisionFromPolicyResult(result.Result)
+}
+
+// RegoEvalFunc 内嵌 OPA 策略求值函数，通常封装 rego.PreparedEvalQuery，返回决策表达式的值
+type RegoEvalFunc func(ctx context.Context, input map[string]any) (any, error)
+
+// NewEmbeddedOPAAuthorizer 创建内嵌 OPA 策略授权器
+// 网关不直接依赖 OPA，由调用方加载 rego 策略包后传入求值函数，input 与 OPA REST 方式一致
+func NewEmbeddedOPAAuthorizer(eval RegoEvalFunc) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		var input map[string]any
+		if err := json.Unmarshal(data, &input); err != nil {
+			return nil, err
+		}
+		value, err := eval(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return DecisionFromPolicyResult(value)
+	})
+}
+
+// DecisionFromPolicyResult 解析策略结果：布尔值表示是否放行；对象支持 allow / allowed、status、reason、body、
+// headers、upstream_headers 字段；未定义（nil）视为拒绝
+func DecisionFromPolicyResult(result any) (*AuthzDecision, error) {
+	switch v := result.(type) {
+	case nil:
+		return &AuthzDecision{Reason: constants.AuthzErrorDenied}, nil
+	case bool:
+		return &AuthzDecision{Allowed: v}, nil
+	case map[string]any:
+		decision := &AuthzDecision{}
+		if allowed, ok := v["allowed"].(bool); ok {
+			decision.Allowed = allowed
+		} else if allow, ok := v["allow"].(bool); ok {
+			decision.Allowed = allow
+		}
+		switch code := v["status"].(type) {
+		case float64:
+			decision.Status = int(code)
+		case json.Number:
+			n, _ := code.Int64()
+			decision.Status = int(n)
+		}
+		decision.Reason, _ = v["reason"].(string)
+		decision.Body, _ = v["body"].(string)
+		decision.Headers = stringMap(v["headers"])
+		decision.UpstreamHeaders = stringMap(v["upstream_headers"])
+		return decision, nil
+	default:
+		return nil, fmt.Errorf("unsupported policy result type %T", result)
+	}
+}
+
+// stringMap 将策略结果中的对象转换为字符串映射，非字符串值忽略
+func stringMap(v any) map[string]string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for key, value := range m {
+		if s, ok := value.(string); ok {
+			out[key] = s
+		}
+	}
+	return out
+}
@@ -0,0 +1,306 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ext_authz_grpc.go
+ * @Description: Envoy gRPC ext_authz 兼容授权器 - 调用 envoy.service.auth.v3.Authorization/Check，
+ *               CheckRequest / CheckResponse 按 wire 格式直接编解码，不引入 go-control-plane 依赖
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// extAuthzCheckMethod Envoy 外部授权服务的 Check 方法
+const extAuthzCheckMethod = "/envoy.service.auth.v3.Authorization/Check"
+
+// GRPCExtAuthzConfig Envoy gRPC ext_authz 兼容服务配置
+type GRPCExtAuthzConfig struct {
+	Conn              grpc.ClientConnInterface // 已建立的连接（TLS、负载均衡等由调用方配置），优先于 Target
+	Target            string                   // 授权服务地址，未提供 Conn 时以明文连接
+	ContextExtensions map[string]string        // 随 CheckRequest 发送的 context_extensions
+}
+
+// grpcExtAuthz Envoy gRPC ext_authz 兼容授权器
+type grpcExtAuthz struct {
+	conn       grpc.ClientConnInterface
+	extensions map[string]string
+}
+
+// NewGRPCExtAuthz 创建 Envoy gRPC ext_authz 兼容授权器
+func NewGRPCExtAuthz(cfg GRPCExtAuthzConfig) (Authorizer, error) {
+	conn := cfg.Conn
+	if conn == nil {
+		if cfg.Target == "" {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "grpc ext authz requires a connection or target")
+		}
+		cc, err := grpc.NewClient(cfg.Target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid grpc ext authz target %q: %v", cfg.Target, err)
+		}
+		conn = cc
+	}
+	return &grpcExtAuthz{conn: conn, extensions: cfg.ContextExtensions}, nil
+}
+
+// Authorize 实现 Authorizer
+func (a *grpcExtAuthz) Authorize(ctx context.Context, req *AuthzRequest) (*AuthzDecision, error) {
+	var out rawMessage
+	if err := a.conn.Invoke(ctx, extAuthzCheckMethod, rawMessage(encodeCheckRequest(req, a.extensions)), &out, grpc.ForceCodec(rawCodec{})); err != nil {
+		return nil, err
+	}
+	return decodeCheckResponse(out)
+}
+
+// rawMessage 已编码的 protobuf 消息
+type rawMessage []byte
+
+// rawCodec 直接收发已编码字节的编解码器
+type rawCodec struct{}
+
+// Marshal 实现 encoding.Codec
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: unexpected message type %T", v)
+	}
+	return msg, nil
+}
+
+// Unmarshal 实现 encoding.Codec
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("raw codec: unexpected message type %T", v)
+	}
+	*msg = append((*msg)[:0], data...)
+	return nil
+}
+
+// Name 实现 encoding.Codec
+func (rawCodec) Name() string { return "proto" }
+
+// encodeCheckRequest 编码 CheckRequest{attributes: AttributeContext}
+func encodeCheckRequest(req *AuthzRequest, extensions map[string]string) []byte {
+	headers := make(map[string]string, len(req.Headers)+3)
+	for name, value := range req.Headers {
+		headers[name] = value
+	}
+	path := req.Path
+	if req.Query != "" {
+		path += "?" + req.Query
+	}
+	headers[":method"], headers[":path"], headers[":authority"] = req.Method, path, req.Host
+
+	// AttributeContext.HttpRequest
+	var httpReq []byte
+	httpReq = appendString(httpReq, 1, req.Headers["x-request-id"])
+	httpReq = appendString(httpReq, 2, req.Method)
+	httpReq = appendStringMap(httpReq, 3, headers)
+	httpReq = appendString(httpReq, 4, path)
+	httpReq = appendString(httpReq, 5, req.Host)
+	httpReq = appendString(httpReq, 6, req.Scheme)
+	httpReq = appendString(httpReq, 7, req.Query)
+	httpReq = appendString(httpReq, 10, mapProtocol(req.Protocol))
+
+	// AttributeContext.Request{http = 2}
+	request := protowire.AppendTag(nil, 2, protowire.BytesType)
+	request = protowire.AppendBytes(request, httpReq)
+
+	var attrs []byte
+	if source := encodePeer(req.RemoteAddr); len(source) > 0 {
+		attrs = protowire.AppendTag(attrs, 1, protowire.BytesType)
+		attrs = protowire.AppendBytes(attrs, source)
+	}
+	attrs = protowire.AppendTag(attrs, 4, protowire.BytesType)
+	attrs = protowire.AppendBytes(attrs, request)
+	attrs = appendStringMap(attrs, 10, extensions)
+
+	msg := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(msg, attrs)
+}
+
+// mapProtocol HTTP 协议版本（gRPC 调用为 HTTP/2）
+func mapProtocol(protocol string) string {
+	if protocol == AuthzProtocolGRPC {
+		return "HTTP/2"
+	}
+	return "HTTP/1.1"
+}
+
+// encodePeer 编码 AttributeContext.Peer{address: Address{socket_address: SocketAddress{address, port_value}}}
+func encodePeer(remoteAddr string) []byte {
+	host, port, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return nil
+	}
+	var socket []byte
+	socket = appendString(socket, 2, host)
+	if p, err := strconv.ParseUint(port, 10, 32); err == nil {
+		socket = protowire.AppendTag(socket, 3, protowire.VarintType)
+		socket = protowire.AppendVarint(socket, p)
+	}
+	address := protowire.AppendTag(nil, 1, protowire.BytesType)
+	address = protowire.AppendBytes(address, socket)
+	peerMsg := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(peerMsg, address)
+}
+
+// appendString 追加非空字符串字段
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendStringMap 追加 map<string, string> 字段
+func appendStringMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	for key, value := range m {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, value)
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// decodeCheckResponse 解码 CheckResponse：status.code 为 OK 时放行，
+// denied_response 提供拒绝状态码、响应头与响应体，ok_response 提供追加到上游的请求头
+func decodeCheckResponse(data []byte) (*AuthzDecision, error) {
+	var (
+		code     uint64
+		message  string
+		decision = &AuthzDecision{}
+	)
+	err := walkFields(data, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1: // google.rpc.Status
+			return walkFields(value, func(num protowire.Number, value []byte, varint uint64) error {
+				switch num {
+				case 1:
+					code = varint
+				case 2:
+					message = string(value)
+				}
+				return nil
+			})
+		case 2: // DeniedHttpResponse
+			return walkFields(value, func(num protowire.Number, value []byte, varint uint64) error {
+				switch num {
+				case 1: // HttpStatus{code = 1}
+					return walkFields(value, func(num protowire.Number, _ []byte, varint uint64) error {
+						if num == 1 {
+							decision.Status = int(varint)
+						}
+						return nil
+					})
+				case 2:
+					return decodeHeaderOption(value, &decision.Headers)
+				case 3:
+					decision.Body = string(value)
+				}
+				return nil
+			})
+		case 3: // OkHttpResponse
+			return walkFields(value, func(num protowire.Number, value []byte, _ uint64) error {
+				if num == 2 {
+					return decodeHeaderOption(value, &decision.UpstreamHeaders)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decode ext authz response: %w", err)
+	}
+
+	decision.Allowed = codes.Code(code) == codes.OK
+	if !decision.Allowed {
+		decision.Reason = message
+		if decision.Status == 0 {
+			decision.Status = http.StatusForbidden
+		}
+	}
+	return decision, nil
+}
+
+// decodeHeaderOption 解码 HeaderValueOption{header: HeaderValue{key, value}}
+func decodeHeaderOption(data []byte, headers *map[string]string) error {
+	return walkFields(data, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != 1 {
+			return nil
+		}
+		var key, val string
+		err := walkFields(value, func(num protowire.Number, value []byte, _ uint64) error {
+			switch num {
+			case 1:
+				key = string(value)
+			case 2:
+				val = string(value)
+			}
+			return nil
+		})
+		if err != nil || key == "" {
+			return err
+		}
+		if *headers == nil {
+			*headers = make(map[string]string)
+		}
+		(*headers)[key] = val
+		return nil
+	})
+}
+
+// walkFields 遍历消息字段，长度前缀字段传入内容，varint 字段传入数值，其余类型跳过
+func walkFields(data []byte, fn func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var (
+			value  []byte
+			varint uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := fn(num, value, varint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
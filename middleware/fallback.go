@@ -0,0 +1,523 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\fallback.go
+ * @Description: 降级响应 - 路由返回 5xx 或熔断器打开时，依次尝试最近一次成功响应的缓存、备用上游与静态响应，
+ *               错误响应先在内存中暂存，降级成功时丢弃，所有降级都不可用时原样返回；
+ *               降级响应带 X-Gateway-Degraded 头并计入 gateway_degraded_responses_total
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 降级响应默认值
+const (
+	DefaultFallbackMaxBodyBytes  = 1 << 20
+	DefaultFallbackCacheMaxBytes = 64 << 20
+	DefaultFallbackCacheTTL      = 10 * time.Minute
+)
+
+// DefaultFallbackStatuses 默认触发降级的状态码
+var DefaultFallbackStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 降级来源（指标标签与 X-Gateway-Degraded 的值）
+const (
+	fallbackSourceCache    = "cache"
+	fallbackSourceUpstream = "upstream"
+	fallbackSourceStatic   = "static"
+	fallbackSourceNone     = "none" // 没有可用的降级响应，原样返回错误
+)
+
+// 降级原因（指标标签）
+const (
+	fallbackReasonError       = "error"
+	fallbackReasonCircuitOpen = "circuit_open"
+)
+
+// fallbackCachedHeaders 缓存成功响应时保留的响应头
+var fallbackCachedHeaders = []string{
+	constants.HeaderContentType,
+	constants.HeaderContentEncoding,
+	constants.HeaderContentLanguage,
+	constants.HeaderContentDisposition,
+}
+
+// fallbackResetHeaders 写出降级响应前删除的、属于原错误响应的响应头
+var fallbackResetHeaders = []string{
+	constants.HeaderContentType,
+	constants.HeaderContentLength,
+	constants.HeaderContentEncoding,
+	constants.HeaderContentLanguage,
+	constants.HeaderContentDisposition,
+	constants.HeaderETag,
+	"Content-Range",
+	"Last-Modified",
+	"Set-Cookie",
+}
+
+// degradedResponses 降级响应数（注册到默认 Registry）
+var degradedResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_degraded_responses_total",
+	Help: "Total number of failed responses handled by fallback rules by rule, reason and source",
+}, []string{"rule", "reason", "source"})
+
+// FallbackConfig 降级响应配置
+type FallbackConfig struct {
+	Rules []FallbackRule `json:"rules" yaml:"rules" mapstructure:"rules"` // 按声明顺序匹配，先声明者优先
+	// MaxBodyBytes 暂存错误响应、缓存成功响应与重放请求体（转发备用上游）的大小上限，默认 1MB
+	MaxBodyBytes int `json:"max_body_bytes" yaml:"max-body-bytes" mapstructure:"max-body-bytes"`
+	// CacheMaxBytes 成功响应缓存的总大小，超出时淘汰最久未使用的响应，默认 64MB
+	CacheMaxBytes int64 `json:"cache_max_bytes" yaml:"cache-max-bytes" mapstructure:"cache-max-bytes"`
+}
+
+// FallbackRule 单条降级规则：按 Cache > Upstream > Response 的顺序尝试，至少配置其一
+type FallbackRule struct {
+	Name     string               `json:"name" yaml:"name" mapstructure:"name"`             // 规则名（指标标签），默认 fallback-<序号>
+	Paths    []string             `json:"paths" yaml:"paths" mapstructure:"paths"`          // 路径 glob
+	Methods  []string             `json:"methods" yaml:"methods" mapstructure:"methods"`    // HTTP 方法，为空匹配全部
+	Statuses []int                `json:"statuses" yaml:"statuses" mapstructure:"statuses"` // 触发降级的状态码，默认 500/502/503/504
+	Cache    *FallbackCachePolicy `json:"cache,omitempty" yaml:"cache,omitempty" mapstructure:"cache"`
+	Upstream *DeclarativeUpstream `json:"upstream,omitempty" yaml:"upstream,omitempty" mapstructure:"upstream"` // 备用上游
+	Response *StaticResponse      `json:"response,omitempty" yaml:"response,omitempty" mapstructure:"response"` // 静态 / 模板响应
+}
+
+// FallbackCachePolicy 缓存最近一次成功的 GET 响应，失败时返回
+type FallbackCachePolicy struct {
+	TTL          time.Duration `json:"ttl" yaml:"ttl" mapstructure:"ttl"`                               // 成功响应可用于降级的时长，默认 10m
+	IgnoreParams []string      `json:"ignore_params" yaml:"ignore-params" mapstructure:"ignore-params"` // 不参与缓存键的查询参数
+	// Vary 参与缓存键的请求头；携带 Authorization 或 Cookie 的请求只有在此列出对应请求头时才缓存，避免把个人数据返回给其他用户
+	Vary []string `json:"vary" yaml:"vary" mapstructure:"vary"`
+}
+
+// Fallbacks 编译后的降级规则
+type Fallbacks struct {
+	routes  *RouteTable
+	maxBody int
+	cache   *fallbackCache
+}
+
+// fallbackRule 编译后的单条规则
+type fallbackRule struct {
+	name     string
+	statuses []int
+	ttl      time.Duration
+	keys     *CacheKeyBuilder // 为 nil 时不缓存
+	vary     []string         // 规范化后的 Vary 请求头
+	proxy    http.Handler     // 备用上游
+	static   *staticHandler
+}
+
+// NewFallbacks 校验配置并编译规则
+func NewFallbacks(cfg FallbackConfig) (*Fallbacks, error) {
+	if cfg.MaxBodyBytes < 0 || cfg.CacheMaxBytes < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "fallback: body and cache limits must not be negative")
+	}
+	f := &Fallbacks{maxBody: cfg.MaxBodyBytes}
+	if f.maxBody == 0 {
+		f.maxBody = DefaultFallbackMaxBodyBytes
+	}
+	cacheMaxBytes := cfg.CacheMaxBytes
+	if cacheMaxBytes == 0 {
+		cacheMaxBytes = DefaultFallbackCacheMaxBytes
+	}
+	f.cache = newFallbackCache(cacheMaxBytes)
+
+	var patterns []RoutePattern
+	for i := range cfg.Rules {
+		rule := cfg.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("fallback-%d", i)
+		}
+		compiled, err := compileFallbackRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range rule.Paths {
+			patterns = append(patterns, RoutePattern{Kind: RouteMatchGlob, Pattern: path, Methods: rule.Methods, Value: compiled})
+		}
+	}
+	f.routes = NewRouteTable(patterns)
+	return f, nil
+}
+
+// compileFallbackRule 校验单条规则
+func compileFallbackRule(rule FallbackRule) (*fallbackRule, error) {
+	if len(rule.Paths) == 0 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "fallback: rule %q has no paths", rule.Name)
+	}
+	if rule.Cache == nil && rule.Upstream == nil && rule.Response == nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "fallback: rule %q must set cache, upstream or response", rule.Name)
+	}
+	compiled := &fallbackRule{name: rule.Name, statuses: rule.Statuses}
+	if len(compiled.statuses) == 0 {
+		compiled.statuses = DefaultFallbackStatuses
+	}
+	for _, status := range compiled.statuses {
+		if status < 400 || status > 599 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "fallback: rule %q has invalid status %d", rule.Name, status)
+		}
+	}
+
+	if c := rule.Cache; c != nil {
+		if c.TTL < 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "fallback: rule %q cache ttl must not be negative", rule.Name)
+		}
+		compiled.ttl = c.TTL
+		if compiled.ttl == 0 {
+			compiled.ttl = DefaultFallbackCacheTTL
+		}
+		compiled.keys = NewCacheKeyBuilder(CacheKeyConfig{IgnoreParams: c.IgnoreParams, Headers: c.Vary})
+		for _, name := range c.Vary {
+			compiled.vary = append(compiled.vary, http.CanonicalHeaderKey(name))
+		}
+	}
+	if rule.Upstream != nil {
+		upstream, err := newRouteUpstream(*rule.Upstream)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "fallback: rule %q: %v", rule.Name, err)
+		}
+		compiled.proxy = newRouteProxy(rule.Name, upstream)
+	}
+	if rule.Response != nil {
+		static, err := newStaticHandler(rule.Name, rule.Response)
+		if err != nil {
+			return nil, err
+		}
+		compiled.static = static
+	}
+	return compiled, nil
+}
+
+// failed 状态码是否触发降级
+func (r *fallbackRule) failed(status int) bool {
+	return slices.Contains(r.statuses, status)
+}
+
+// cacheable 请求是否可以读写成功响应缓存：只缓存 GET，携带凭证的请求需在 Vary 中列出凭证请求头
+func (r *fallbackRule) cacheable(req *http.Request) bool {
+	if r.keys == nil || req.Method != http.MethodGet {
+		return false
+	}
+	for _, name := range []string{constants.HeaderAuthorization, "Cookie"} {
+		if req.Header.Get(name) != "" && !slices.Contains(r.vary, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey 规则内的缓存键
+func (r *fallbackRule) cacheKey(req *http.Request) string {
+	return r.name + "\x00" + r.keys.RequestKey(req)
+}
+
+// fallbackSlotKey 请求上下文中降级槽位的键
+type fallbackSlotKey struct{}
+
+// fallbackSlot 内层中间件向降级中间件报告的状态
+type fallbackSlot struct {
+	circuitOpen atomic.Bool
+}
+
+// markCircuitOpen 熔断器拒绝请求时调用，降级原因记为 circuit_open
+func markCircuitOpen(ctx context.Context) {
+	if slot, ok := ctx.Value(fallbackSlotKey{}).(*fallbackSlot); ok {
+		slot.circuitOpen.Store(true)
+	}
+}
+
+// Handle 命中规则时暂存错误响应并尝试降级，未命中或 streaming 路由直接交给 next
+func (f *Fallbacks) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	value, ok := f.routes.Match(r.Method, r.URL.Path)
+	if !ok || r.Header.Get("Upgrade") != "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	limit, ok := ResponseBufferLimit(r.Context(), f.maxBody)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	rule := value.(*fallbackRule)
+
+	var body []byte
+	replayable := true
+	if rule.proxy != nil && r.Body != nil && r.Body != http.NoBody {
+		body, replayable = f.bufferRequestBody(r, limit)
+	}
+
+	slot := &fallbackSlot{}
+	r = r.WithContext(context.WithValue(r.Context(), fallbackSlotKey{}, slot))
+	fw := &fallbackWriter{ResponseWriter: w, failed: rule.failed, limit: limit, capture: rule.cacheable(r)}
+	next.ServeHTTP(fw, r)
+
+	if !fw.holding {
+		if fw.captured != nil {
+			f.cache.put(rule.cacheKey(r), fw.status, w.Header(), fw.captured.Bytes(), rule.ttl)
+		}
+		return
+	}
+
+	reason := fallbackReasonError
+	if slot.circuitOpen.Load() {
+		reason = fallbackReasonCircuitOpen
+	}
+	original := w.Header().Clone()
+	source := f.degrade(w, r, rule, body, replayable)
+	degradedResponses.WithLabelValues(rule.name, reason, source).Inc()
+	if source == fallbackSourceNone {
+		header := w.Header()
+		clear(header)
+		maps.Copy(header, original)
+		fw.commit()
+	}
+}
+
+// degrade 依次尝试缓存、备用上游与静态响应，返回实际使用的来源
+func (f *Fallbacks) degrade(w http.ResponseWriter, r *http.Request, rule *fallbackRule, body []byte, replayable bool) string {
+	if rule.cacheable(r) {
+		if entry := f.cache.get(rule.cacheKey(r)); entry != nil {
+			resetFallbackHeaders(w.Header(), fallbackSourceCache)
+			for name, values := range entry.header {
+				w.Header()[name] = slices.Clone(values)
+			}
+			w.WriteHeader(entry.status)
+			_, _ = w.Write(entry.body)
+			return fallbackSourceCache
+		}
+	}
+
+	if rule.proxy != nil && replayable {
+		req := r.Clone(r.Context())
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resetFallbackHeaders(w.Header(), fallbackSourceUpstream)
+		// 备用上游的任何 5xx 都视为失败，继续尝试静态响应
+		uw := &fallbackWriter{ResponseWriter: w, limit: f.maxBody, failed: func(status int) bool {
+			return status >= http.StatusInternalServerError || rule.failed(status)
+		}}
+		rule.proxy.ServeHTTP(uw, req)
+		if !uw.holding {
+			return fallbackSourceUpstream
+		}
+	}
+
+	if rule.static != nil {
+		resetFallbackHeaders(w.Header(), fallbackSourceStatic)
+		rule.static.ServeHTTP(w, r)
+		return fallbackSourceStatic
+	}
+	return fallbackSourceNone
+}
+
+// bufferRequestBody 读取不超过 limit 的请求体供备用上游重放，超过上限时放回已读部分且不可重放
+func (f *Fallbacks) bufferRequestBody(r *http.Request, limit int) ([]byte, bool) {
+	buf, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	if err != nil || len(buf) > limit {
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		return nil, false
+	}
+	r.Body = &replayBody{Reader: bytes.NewReader(buf), Closer: r.Body}
+	return buf, true
+}
+
+// resetFallbackHeaders 删除原错误响应的内容相关响应头，标记降级来源并禁止缓存降级响应
+func resetFallbackHeaders(h http.Header, source string) {
+	for _, name := range fallbackResetHeaders {
+		h.Del(name)
+	}
+	h.Set(constants.HeaderXDegraded, source)
+	h.Set(constants.HeaderCacheControl, "no-store")
+}
+
+// HTTPMiddleware 降级响应中间件
+func (f *Fallbacks) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.Handle(w, r, next)
+	})
+}
+
+// fallbackWriter 状态码触发降级时暂存响应（超过上限时原样写出，不再降级），
+// 其余响应直接写出，capture 为 true 时同时复制成功响应体用于缓存
+type fallbackWriter struct {
+	http.ResponseWriter
+	failed      func(status int) bool // 状态码是否触发降级
+	limit       int
+	capture     bool
+	status      int
+	wroteHeader bool
+	holding     bool          // 暂存中的错误响应
+	held        bytes.Buffer  // 暂存的错误响应体
+	captured    *bytes.Buffer // 复制的成功响应体，超过上限或不可缓存时为 nil
+}
+
+// WriteHeader 触发降级的状态码先暂存，1xx 直接写出
+func (w *fallbackWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status, w.wroteHeader = status, true
+	if w.failed(status) {
+		w.holding = true
+		return
+	}
+	if w.capture && status >= http.StatusOK && status < http.StatusMultipleChoices && w.storable() {
+		w.captured = &bytes.Buffer{}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// storable 成功响应是否可以缓存：不含 Set-Cookie、未声明 private / no-store、不是事件流
+func (w *fallbackWriter) storable() bool {
+	h := w.Header()
+	cacheControl := strings.ToLower(h.Get(constants.HeaderCacheControl))
+	return h.Get("Set-Cookie") == "" &&
+		!strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private") &&
+		!strings.HasPrefix(h.Get(constants.HeaderContentType), "text/event-stream")
+}
+
+// Write 暂存或写出响应体
+func (w *fallbackWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.holding {
+		if w.held.Len()+len(b) <= w.limit {
+			return w.held.Write(b)
+		}
+		w.commit()
+	}
+	if w.captured != nil {
+		if w.captured.Len()+len(b) <= w.limit {
+			w.captured.Write(b)
+		} else {
+			w.captured = nil
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// commit 放弃降级，写出暂存的错误响应
+func (w *fallbackWriter) commit() {
+	w.holding = false
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.held.Bytes())
+	w.held.Reset()
+}
+
+// Flush 暂存期间不刷出
+func (w *fallbackWriter) Flush() {
+	if w.holding {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *fallbackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// fallbackCache 成功响应缓存，按总大小 LRU 淘汰
+type fallbackCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List // 元素为 *fallbackEntry，队首最近使用
+	index    map[string]*list.Element
+}
+
+// fallbackEntry 缓存的成功响应
+type fallbackEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// newFallbackCache 创建缓存
+func newFallbackCache(maxBytes int64) *fallbackCache {
+	return &fallbackCache{maxBytes: maxBytes, lru: list.New(), index: make(map[string]*list.Element)}
+}
+
+// get 读取未过期的响应
+func (c *fallbackCache) get(key string) *fallbackEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.index[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*fallbackEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+// put 保存响应（只保留内容相关的响应头），超出总大小时淘汰最久未使用的响应
+func (c *fallbackCache) put(key string, status int, header http.Header, body []byte, ttl time.Duration) {
+	entry := &fallbackEntry{key: key, status: status, header: http.Header{}, body: bytes.Clone(body), expires: time.Now().Add(ttl)}
+	for _, name := range fallbackCachedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			entry.header[name] = slices.Clone(values)
+		}
+	}
+	if int64(len(entry.body)) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[key]; ok {
+		c.remove(elem)
+	}
+	c.index[key] = c.lru.PushFront(entry)
+	c.size += int64(len(entry.body))
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove 删除缓存项（调用方持有锁）
+func (c *fallbackCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*fallbackEntry)
+	delete(c.index, entry.key)
+	c.size -= int64(len(entry.body))
+}
@@ -0,0 +1,188 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\features.go
+ * @Description: 按环境的功能开关矩阵 - 配置文件顶层 features 段声明每个功能允许启用的环境，
+ *               启动与热更新时校验：chaos、mock 等守护功能在任何生产环境都不能启用，未列出时默认关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"gopkg.in/yaml.v3"
+)
+
+// 内置功能名
+const (
+	FeatureSwagger        = "swagger"         // Swagger 文档与 UI
+	FeaturePProf          = "pprof"           // /debug/pprof 性能分析
+	FeatureDebugEndpoints = "debug-endpoints" // 诊断接口：/debug/leaks、/admin/performance、/admin/middleware
+	FeatureChaos          = "chaos"           // 故障注入（守护功能）
+	FeatureMock           = "mock"            // mock 响应，声明式路由以 feature: mock 标记（守护功能）
+)
+
+// FeatureAllEnvironments 矩阵中表示全部环境的通配符，守护功能不能使用
+const FeatureAllEnvironments = "*"
+
+// DefaultProductionEnvironment 未配置生产环境名单时视为生产的环境
+const DefaultProductionEnvironment = "production"
+
+// defaultGuardedFeatures 始终受守护的功能：只能显式按环境启用，且不能在生产环境启用
+var defaultGuardedFeatures = []string{FeatureChaos, FeatureMock}
+
+// FeaturesConfig 功能开关矩阵
+type FeaturesConfig struct {
+	// Production 视为生产的环境（如 production、prod-cn），production 始终包含在内
+	Production []string `json:"production" yaml:"production" mapstructure:"production"`
+	// Guarded 额外的守护功能，chaos 与 mock 始终是守护功能
+	Guarded []string `json:"guarded" yaml:"guarded" mapstructure:"guarded"`
+	// Matrix 功能 → 允许启用的环境，* 表示全部环境；列出的功能只在这些环境启用，
+	// 未列出的普通功能不受限制，未列出的守护功能一律关闭
+	Matrix map[string][]string `json:"matrix" yaml:"matrix" mapstructure:"matrix"`
+}
+
+// ParseFeatures 把配置文件中 features 段的原始值解析为配置，未知字段视为错误
+func ParseFeatures(raw any) (*FeaturesConfig, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "features: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	cfg := &FeaturesConfig{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "features: %v", err)
+	}
+	return cfg, nil
+}
+
+// productionEnvironments 生产环境集合（含默认的 production）
+func (c FeaturesConfig) productionEnvironments() map[string]bool {
+	envs := map[string]bool{DefaultProductionEnvironment: true}
+	for _, env := range c.Production {
+		envs[strings.TrimSpace(env)] = true
+	}
+	return envs
+}
+
+// IsProduction 环境是否属于生产环境
+func (c FeaturesConfig) IsProduction(environment string) bool {
+	return c.productionEnvironments()[environment]
+}
+
+// guarded 是否为守护功能
+func (c FeaturesConfig) guarded(name string) bool {
+	return slices.Contains(defaultGuardedFeatures, name) || slices.Contains(c.Guarded, name)
+}
+
+// validate 校验矩阵：环境名不能为空，守护功能不能使用通配符或列出任何生产环境
+func (c FeaturesConfig) validate() error {
+	production := c.productionEnvironments()
+	if production[""] {
+		return errors.NewError(errors.ErrCodeInvalidConfiguration, "features: production environment name must not be empty")
+	}
+	for _, name := range c.Guarded {
+		if strings.TrimSpace(name) == "" {
+			return errors.NewError(errors.ErrCodeInvalidConfiguration, "features: guarded feature name must not be empty")
+		}
+	}
+
+	names := make([]string, 0, len(c.Matrix))
+	for name := range c.Matrix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return errors.NewError(errors.ErrCodeInvalidConfiguration, "features: feature name must not be empty")
+		}
+		for _, env := range c.Matrix[name] {
+			env = strings.TrimSpace(env)
+			switch {
+			case env == "":
+				return errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "features: %s lists an empty environment", name)
+			case !c.guarded(name):
+			case env == FeatureAllEnvironments:
+				return errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "features: guarded feature %s must list environments explicitly, not %q", name, FeatureAllEnvironments)
+			case production[env]:
+				return errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "features: guarded feature %s must not be enabled in production environment %q", name, env)
+			}
+		}
+	}
+	return nil
+}
+
+// featureState 当前环境下生效的功能开关
+type featureState struct {
+	config      FeaturesConfig
+	environment string
+	production  bool
+}
+
+// features 当前生效的功能开关，未配置时为 nil（普通功能开启、守护功能关闭）
+var features atomic.Pointer[featureState]
+
+// SetFeatures 校验矩阵并按当前环境生效；cfg 为 nil 时清除矩阵。
+// 当前环境属于生产环境时，守护功能即使出现在矩阵中也保持关闭
+func SetFeatures(cfg *FeaturesConfig, environment string) error {
+	if cfg == nil {
+		features.Store(nil)
+		return nil
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	features.Store(&featureState{
+		config:      *cfg,
+		environment: environment,
+		production:  cfg.IsProduction(environment),
+	})
+	return nil
+}
+
+// FeatureEnabled 功能在当前环境是否启用；业务代码可据此开关自定义功能
+func FeatureEnabled(name string) bool {
+	state := features.Load()
+	if state == nil {
+		return !slices.Contains(defaultGuardedFeatures, name)
+	}
+	guarded := state.config.guarded(name)
+	if guarded && state.production {
+		return false
+	}
+	envs, ok := state.config.Matrix[name]
+	if !ok {
+		return !guarded
+	}
+	for _, env := range envs {
+		env = strings.TrimSpace(env)
+		if env == state.environment || env == FeatureAllEnvironments {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireFeature 功能在当前环境关闭时返回 404，开关随配置热更新生效
+func RequireFeature(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !FeatureEnabled(name) {
+			response.WriteNotFoundResult(w, "not found")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,457 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\goroutine_leaks.go
+ * @Description: 协程泄漏检测 - 后台定期采集全部协程栈，按调用栈与创建位置聚合，
+ *               连续多次采样数量只增不减且增长超过阈值的栈视为疑似泄漏，通过日志、指标与 /debug/leaks 报告
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 协程泄漏检测默认值
+const (
+	DefaultLeakAdminPath   = "/debug/leaks"
+	DefaultLeakInterval    = time.Minute
+	DefaultLeakWindow      = 5
+	DefaultLeakMinGrowth   = 10
+	DefaultLeakMaxReported = 20
+	leakStackDepth         = 16 // 参与聚合的栈帧数，更深的帧不区分
+)
+
+// LeakDetectorConfig 协程泄漏检测配置
+type LeakDetectorConfig struct {
+	Interval    time.Duration // 采样间隔，默认 1m；每次采样会短暂停止全部协程，协程数很多时不宜过短
+	Window      int           // 判定持续增长所需的连续采样次数（含当前），默认 5
+	MinGrowth   int           // 窗口内最少增长的协程数，默认 10
+	MaxReported int           // 报告与指标中的栈数量上限，默认 20
+	Ignore      []string      // 栈中任一函数名包含这些片段的协程不参与检测（如按负载伸缩的 worker 池）
+	AdminPath   string        // 报告接口路径，默认 /debug/leaks；接口本身需由认证 / 授权中间件保护
+}
+
+// GoroutineGroup 调用栈与创建位置相同的一组协程
+type GoroutineGroup struct {
+	CreatedBy string        `json:"created_by"`      // 创建位置：函数与文件:行
+	Top       string        `json:"top"`             // 栈顶函数
+	State     string        `json:"state"`           // 最常见的状态，如 chan receive、select、IO wait
+	Count     int           `json:"count"`           // 当前数量
+	MaxWait   time.Duration `json:"max_wait"`        // 最长阻塞时长，运行时以分钟计，不足 1 分钟为 0
+	Trend     []int         `json:"trend,omitempty"` // 最近几次采样的数量，旧 → 新
+	Stack     []string      `json:"stack"`           // 函数与文件:行，最多 16 帧
+}
+
+// LeakReport 最近一次采样的报告
+type LeakReport struct {
+	SampledAt  time.Time        `json:"sampled_at,omitzero"`
+	Samples    int              `json:"samples"`    // 已完成的采样次数
+	Goroutines int              `json:"goroutines"` // 协程总数（含忽略的）
+	Suspects   []GoroutineGroup `json:"suspects"`   // 疑似泄漏，按窗口内增长量排序
+	Largest    []GoroutineGroup `json:"largest"`    // 数量最多的栈，窗口未满时也可用于排查
+}
+
+// leakHistory 一个栈在最近几次采样中的数量
+type leakHistory struct {
+	group  GoroutineGroup
+	counts []int
+	logged int // 上次日志报告时的数量，数量翻倍后再次报告
+}
+
+// LeakDetector 协程泄漏检测器
+type LeakDetector struct {
+	config LeakDetectorConfig
+
+	mu      sync.Mutex
+	history map[string]*leakHistory
+	report  LeakReport
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// 协程泄漏指标（注册到默认 Registry）
+var (
+	leakSuspects = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_goroutine_leak_suspects",
+		Help: "Number of goroutine stacks whose count has grown monotonically across the detection window",
+	})
+
+	leakGoroutines = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_goroutine_leak_goroutines",
+		Help: "Current number of goroutines in suspected leaking stacks by creation site",
+	}, []string{"created_by"})
+)
+
+// NewLeakDetector 校验配置并创建检测器；调用 Start 后开始采样
+func NewLeakDetector(cfg LeakDetectorConfig) (*LeakDetector, error) {
+	if cfg.Interval < 0 || cfg.Window < 0 || cfg.MinGrowth < 0 || cfg.MaxReported < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "leak detector settings must not be negative")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultLeakInterval
+	}
+	if cfg.Window == 0 {
+		cfg.Window = DefaultLeakWindow
+	}
+	if cfg.Window < 2 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "leak detector window must be at least 2 samples")
+	}
+	if cfg.MinGrowth == 0 {
+		cfg.MinGrowth = DefaultLeakMinGrowth
+	}
+	if cfg.MaxReported == 0 {
+		cfg.MaxReported = DefaultLeakMaxReported
+	}
+	if cfg.AdminPath == "" {
+		cfg.AdminPath = DefaultLeakAdminPath
+	}
+	cfg.AdminPath = strings.TrimSuffix(cfg.AdminPath, "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LeakDetector{
+		config:  cfg,
+		history: make(map[string]*leakHistory),
+		report:  LeakReport{Suspects: []GoroutineGroup{}, Largest: []GoroutineGroup{}},
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// AdminPath 报告接口路径
+func (d *LeakDetector) AdminPath() string {
+	return d.config.AdminPath
+}
+
+// Start 启动采样协程，立即采样一次
+func (d *LeakDetector) Start() {
+	d.wg.Add(1)
+	go d.loop()
+}
+
+// Stop 停止采样并等待采样协程退出
+func (d *LeakDetector) Stop(ctx context.Context) {
+	d.cancel()
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Report 最近一次采样的报告
+func (d *LeakDetector) Report() LeakReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.report
+}
+
+// AdminHandler 报告接口：GET 返回最近一次采样的报告
+func (d *LeakDetector) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			response.WriteAppError(w, errors.NewError(errors.ErrCodeMethodNotAllowed, "leak report only supports GET"))
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, d.Report())
+	}
+}
+
+// loop 按间隔采样
+func (d *LeakDetector) loop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+	for {
+		d.Sample()
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample 立即采样一次并更新报告（通常由后台协程调用）
+func (d *LeakDetector) Sample() {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		global.LOGGER.WarnKV("协程栈采集失败", "error", err)
+		return
+	}
+	d.observe(parseGoroutineDump(buf.Bytes(), d.config.Ignore), time.Now())
+}
+
+// observe 合并一次采样：更新各栈的数量趋势，找出疑似泄漏的栈
+func (d *LeakDetector) observe(dump goroutineDump, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	window := d.config.Window
+	for key, h := range d.history {
+		if _, ok := dump.groups[key]; !ok {
+			h.counts = append(h.counts, 0)
+		}
+	}
+	for key, g := range dump.groups {
+		h, ok := d.history[key]
+		if !ok {
+			h = &leakHistory{}
+			d.history[key] = h
+		}
+		h.group = *g
+		h.counts = append(h.counts, g.Count)
+	}
+
+	var suspects, all []*leakHistory
+	for key, h := range d.history {
+		if len(h.counts) > window {
+			h.counts = h.counts[len(h.counts)-window:]
+		}
+		if h.counts[len(h.counts)-1] == 0 {
+			if allZero(h.counts) {
+				delete(d.history, key)
+			}
+			continue
+		}
+		h.group.Count = h.counts[len(h.counts)-1]
+		h.group.Trend = append([]int(nil), h.counts...)
+		all = append(all, h)
+		if len(h.counts) == window && growing(h.counts, d.config.MinGrowth) {
+			suspects = append(suspects, h)
+		} else {
+			h.logged = 0
+		}
+	}
+
+	sort.Slice(suspects, func(i, j int) bool {
+		gi, gj := growth(suspects[i].counts), growth(suspects[j].counts)
+		if gi != gj {
+			return gi > gj
+		}
+		return suspects[i].group.Count > suspects[j].group.Count
+	})
+	sort.Slice(all, func(i, j int) bool { return all[i].group.Count > all[j].group.Count })
+
+	leakSuspects.Set(float64(len(suspects)))
+	leakGoroutines.Reset()
+	report := LeakReport{
+		SampledAt:  now,
+		Samples:    d.report.Samples + 1,
+		Goroutines: dump.total,
+		Suspects:   make([]GoroutineGroup, 0, min(len(suspects), d.config.MaxReported)),
+		Largest:    make([]GoroutineGroup, 0, min(len(all), d.config.MaxReported)),
+	}
+	for i, h := range suspects {
+		if i < d.config.MaxReported {
+			report.Suspects = append(report.Suspects, h.group)
+			leakGoroutines.WithLabelValues(h.group.CreatedBy).Add(float64(h.group.Count))
+		}
+		// 新出现的嫌疑栈或数量较上次报告翻倍时记录日志
+		if h.logged == 0 || h.group.Count >= 2*h.logged {
+			h.logged = h.group.Count
+			global.LOGGER.WarnKV("疑似协程泄漏",
+				"created_by", h.group.CreatedBy,
+				"top", h.group.Top,
+				"state", h.group.State,
+				"count", h.group.Count,
+				"trend", h.group.Trend)
+		}
+	}
+	for _, h := range all[:min(len(all), d.config.MaxReported)] {
+		report.Largest = append(report.Largest, h.group)
+	}
+	d.report = report
+}
+
+// growing 数量只增不减且总增长达到 minGrowth
+func growing(counts []int, minGrowth int) bool {
+	for i := 1; i < len(counts); i++ {
+		if counts[i] < counts[i-1] {
+			return false
+		}
+	}
+	return growth(counts) >= minGrowth
+}
+
+// growth 窗口内的增长量
+func growth(counts []int) int {
+	return counts[len(counts)-1] - counts[0]
+}
+
+// allZero 是否全部为 0
+func allZero(counts []int) bool {
+	for _, c := range counts {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// goroutineDump 一次采样按栈聚合的结果
+type goroutineDump struct {
+	total  int
+	groups map[string]*GoroutineGroup
+}
+
+// parseGoroutineDump 解析 debug=2 格式的协程栈（与 panic 输出相同），按栈帧函数与创建位置聚合；
+// 文件行号不参与聚合，同一函数内不同位置阻塞的协程归为一组
+func parseGoroutineDump(data []byte, ignore []string) goroutineDump {
+	dump := goroutineDump{groups: make(map[string]*GoroutineGroup)}
+	states := make(map[string]map[string]int)
+
+	flush := func(g *goroutineRecord) {
+		if g == nil {
+			return
+		}
+		dump.total++
+		for _, fn := range g.funcs {
+			for _, pattern := range ignore {
+				if pattern != "" && strings.Contains(fn, pattern) {
+					return
+				}
+			}
+		}
+		key := strings.Join(g.funcs, "\n") + "\ncreated by " + g.createdBy
+		group, ok := dump.groups[key]
+		if !ok {
+			group = &GoroutineGroup{CreatedBy: g.createdBy, Stack: g.stack}
+			if len(g.funcs) > 0 {
+				group.Top = g.funcs[0]
+			}
+			dump.groups[key] = group
+			states[key] = make(map[string]int)
+		}
+		group.Count++
+		group.MaxWait = max(group.MaxWait, g.wait)
+		states[key][g.state]++
+	}
+
+	var current *goroutineRecord
+	var pending string // 等待位置行的函数行
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, ":"):
+			flush(current)
+			current = newGoroutineRecord(line)
+			pending = ""
+		case current == nil || line == "":
+		case strings.HasPrefix(line, "\t"):
+			location := strings.TrimSpace(line)
+			if i := strings.LastIndex(location, " +0x"); i >= 0 {
+				location = location[:i]
+			}
+			switch {
+			case strings.HasPrefix(pending, "created by "):
+				current.createdBy = strings.TrimPrefix(pending, "created by ") + " " + location
+			case pending != "" && len(current.funcs) < leakStackDepth:
+				current.funcs = append(current.funcs, pending)
+				current.stack = append(current.stack, pending+" "+location)
+			}
+			pending = ""
+		case strings.HasPrefix(line, "created by "):
+			fn := line
+			if i := strings.Index(fn, " in goroutine "); i >= 0 {
+				fn = fn[:i]
+			}
+			pending = fn
+		case strings.HasPrefix(line, "..."):
+			pending = ""
+		default:
+			pending = stripFrameArgs(line)
+		}
+	}
+	flush(current)
+
+	for key, group := range dump.groups {
+		best := 0
+		for state, n := range states[key] {
+			if n > best || (n == best && state < group.State) {
+				group.State, best = state, n
+			}
+		}
+		if group.CreatedBy == "" {
+			group.CreatedBy = "main"
+		}
+	}
+	return dump
+}
+
+// goroutineRecord 单个协程
+type goroutineRecord struct {
+	state     string
+	wait      time.Duration
+	funcs     []string
+	stack     []string
+	createdBy string
+}
+
+// newGoroutineRecord 解析协程头，如 goroutine 18 [chan receive, 5 minutes]:
+func newGoroutineRecord(header string) *goroutineRecord {
+	g := &goroutineRecord{}
+	start, end := strings.Index(header, "["), strings.LastIndex(header, "]")
+	if start < 0 || end < start {
+		return g
+	}
+	for i, part := range strings.Split(header[start+1:end], ", ") {
+		if i == 0 {
+			g.state = part
+			continue
+		}
+		if minutes, ok := strings.CutSuffix(part, " minutes"); ok {
+			if n, err := strconv.Atoi(minutes); err == nil {
+				g.wait = time.Duration(n) * time.Minute
+			}
+		}
+	}
+	return g
+}
+
+// stripFrameArgs 去掉函数行末尾的参数，如 net/http.(*conn).serve(0xc000123456, {0x1, 0x2}) → net/http.(*conn).serve
+func stripFrameArgs(line string) string {
+	if !strings.HasSuffix(line, ")") {
+		return line
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
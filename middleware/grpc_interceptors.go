@@ -63,10 +63,7 @@ func (im *InterceptorManager) ServerOptions() []grpc.ServerOption {
 
 	// Exemplar 提取器（关联 trace 和 metrics）
 	exemplarFromContext := func(ctx context.Context) prometheus.Labels {
-		if span := trace.SpanContextFromContext(ctx); span.IsSampled() {
-			return prometheus.Labels{TraceIDKey: span.TraceID().String()}
-		}
-		return nil
+		return ExemplarFromContext(ctx)
 	}
 
 	return []grpc.ServerOption{
@@ -124,10 +121,7 @@ func (im *InterceptorManager) ClientDialOptions() []grpc.DialOption {
 	loggingInterceptor := interceptorLogger(im.logger)
 
 	exemplarFromContext := func(ctx context.Context) prometheus.Labels {
-		if span := trace.SpanContextFromContext(ctx); span.IsSampled() {
-			return prometheus.Labels{TraceIDKey: span.TraceID().String()}
-		}
-		return nil
+		return ExemplarFromContext(ctx)
 	}
 
 	return []grpc.DialOption{
@@ -0,0 +1,207 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\header_limit.go
+ * @Description: 请求头 / gRPC metadata 条数与大小限制 - 超限时返回 431 / RESOURCE_EXHAUSTED，
+ *               错误信息指明超限的头、限制项与实际大小
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HeaderLimitConfig 请求头限制配置，零值字段不限制
+// 多值头按每个值计一条；总字节为全部名称与值的长度之和
+type HeaderLimitConfig struct {
+	MaxCount      int            // 条数上限
+	MaxNameBytes  int            // 单个名称字节上限
+	MaxValueBytes int            // 单个值字节上限
+	MaxTotalBytes int            // 总字节上限
+	Limits        map[string]int // 按名称覆盖单值上限（不区分大小写），如 cookie 放宽、x-user-id 收紧
+}
+
+// HeaderLimiter 请求头限制器
+type HeaderLimiter struct {
+	config HeaderLimitConfig
+	limits map[string]int
+}
+
+// HeaderLimitViolation 超限详情
+type HeaderLimitViolation struct {
+	Header string // 超限的头（条数超限时为空）
+	Limit  string // count / name / value / total
+	Max    int
+	Actual int
+}
+
+// headerLimitRejectionsTotal 超限拒绝数（注册到默认 Registry）
+var headerLimitRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_header_limit_rejections_total",
+	Help: "Total number of requests rejected for exceeding header or metadata limits",
+}, []string{"protocol", "limit"})
+
+// NewHeaderLimiter 校验配置并创建请求头限制器
+func NewHeaderLimiter(cfg HeaderLimitConfig) (*HeaderLimiter, error) {
+	if cfg.MaxCount < 0 || cfg.MaxNameBytes < 0 || cfg.MaxValueBytes < 0 || cfg.MaxTotalBytes < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "header limits must not be negative")
+	}
+	limits := make(map[string]int, len(cfg.Limits))
+	for name, limit := range cfg.Limits {
+		if limit <= 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "header limit for %q must be positive", name)
+		}
+		limits[strings.ToLower(name)] = limit
+	}
+	return &HeaderLimiter{config: cfg, limits: limits}, nil
+}
+
+// Error 实现 error，消息可直接返回给调用方
+func (v *HeaderLimitViolation) Error() string {
+	switch v.Limit {
+	case constants.HeaderLimitCount:
+		return fmt.Sprintf("too many request headers: %d exceeds limit %d", v.Actual, v.Max)
+	case constants.HeaderLimitName:
+		return fmt.Sprintf("request header name %q is %d bytes, exceeds limit %d", v.Header, v.Actual, v.Max)
+	case constants.HeaderLimitValue:
+		return fmt.Sprintf("request header %q value is %d bytes, exceeds limit %d", v.Header, v.Actual, v.Max)
+	default:
+		return fmt.Sprintf("request headers total %d bytes, exceeds limit %d (reached at %q)", v.Actual, v.Max, v.Header)
+	}
+}
+
+// CheckHeader 检查 HTTP 请求头，Host 不在 Header 中不计入
+func (l *HeaderLimiter) CheckHeader(h http.Header) *HeaderLimitViolation {
+	return l.check(func(visit func(name, value string) *HeaderLimitViolation) *HeaderLimitViolation {
+		for name, values := range h {
+			for _, value := range values {
+				if v := visit(name, value); v != nil {
+					return v
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// CheckMetadata 检查 gRPC metadata，跳过 :authority 等伪头
+func (l *HeaderLimiter) CheckMetadata(md metadata.MD) *HeaderLimitViolation {
+	return l.check(func(visit func(name, value string) *HeaderLimitViolation) *HeaderLimitViolation {
+		for name, values := range md {
+			if strings.HasPrefix(name, ":") {
+				continue
+			}
+			for _, value := range values {
+				if v := visit(name, value); v != nil {
+					return v
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// check 逐条检查，单个头超限优先于条数与总量报告
+func (l *HeaderLimiter) check(each func(visit func(name, value string) *HeaderLimitViolation) *HeaderLimitViolation) *HeaderLimitViolation {
+	count, total := 0, 0
+	var aggregate *HeaderLimitViolation
+	v := each(func(name, value string) *HeaderLimitViolation {
+		if l.config.MaxNameBytes > 0 && len(name) > l.config.MaxNameBytes {
+			return &HeaderLimitViolation{Header: name, Limit: constants.HeaderLimitName, Max: l.config.MaxNameBytes, Actual: len(name)}
+		}
+		maxValue := l.config.MaxValueBytes
+		if limit, ok := l.limits[strings.ToLower(name)]; ok {
+			maxValue = limit
+		}
+		if maxValue > 0 && len(value) > maxValue {
+			return &HeaderLimitViolation{Header: name, Limit: constants.HeaderLimitValue, Max: maxValue, Actual: len(value)}
+		}
+		count++
+		total += len(name) + len(value)
+		if aggregate == nil && l.config.MaxTotalBytes > 0 && total > l.config.MaxTotalBytes {
+			aggregate = &HeaderLimitViolation{Header: name, Limit: constants.HeaderLimitTotal, Max: l.config.MaxTotalBytes}
+		}
+		return nil
+	})
+	if v != nil {
+		return v
+	}
+	if l.config.MaxCount > 0 && count > l.config.MaxCount {
+		return &HeaderLimitViolation{Limit: constants.HeaderLimitCount, Max: l.config.MaxCount, Actual: count}
+	}
+	if aggregate != nil {
+		aggregate.Actual = total
+	}
+	return aggregate
+}
+
+// HTTPMiddleware 返回 HTTP 中间件
+func (l *HeaderLimiter) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Handle(w, r, next)
+	})
+}
+
+// Handle 检查 HTTP 请求头，超限返回 431
+func (l *HeaderLimiter) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if v := l.CheckHeader(r.Header); v != nil {
+		headerLimitRejectionsTotal.WithLabelValues("http", v.Limit).Inc()
+		response.WriteErrorResponseWithCode(w, http.StatusRequestHeaderFieldsTooLarge, constants.HeaderLimitErrorCode, v.Error())
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// UnaryServerInterceptor gRPC 一元调用 metadata 限制拦截器
+func (l *HeaderLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return l.Unary
+}
+
+// StreamServerInterceptor gRPC 流式调用 metadata 限制拦截器
+func (l *HeaderLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return l.Stream
+}
+
+// Unary 检查一元调用 metadata
+func (l *HeaderLimiter) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := l.checkGRPC(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream 检查流式调用 metadata（建立流时检查一次）
+func (l *HeaderLimiter) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.checkGRPC(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// checkGRPC 超限返回 ResourceExhausted
+func (l *HeaderLimiter) checkGRPC(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := l.CheckMetadata(md); v != nil {
+		headerLimitRejectionsTotal.WithLabelValues("grpc", v.Limit).Inc()
+		return status.Error(codes.ResourceExhausted, v.Error())
+	}
+	return nil
+}
@@ -13,12 +13,14 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
@@ -269,6 +271,7 @@ func (m *MySQLChecker) Check(ctx context.Context) HealthStatus {
 
 // HealthManager 健康检查管理器
 type HealthManager struct {
+	mu        sync.RWMutex
 	checkers  []HealthChecker
 	startTime time.Time
 }
@@ -281,8 +284,16 @@ func NewHealthManager() *HealthManager {
 	}
 }
 
-// RegisterChecker 注册健康检查器
+// RegisterChecker 注册健康检查器，同名检查器被替换
 func (h *HealthManager) RegisterChecker(checker HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, existing := range h.checkers {
+		if existing.Name() == checker.Name() {
+			h.checkers[i] = checker
+			return
+		}
+	}
 	h.checkers = append(h.checkers, checker)
 }
 
@@ -312,7 +323,11 @@ func (h *HealthManager) Check(ctx context.Context, detailed bool) HealthCheckRes
 	// 执行所有检查器
 	overallStatus := "ok"
 
-	for _, checker := range h.checkers {
+	h.mu.RLock()
+	checkers := slices.Clone(h.checkers)
+	h.mu.RUnlock()
+
+	for _, checker := range checkers {
 		status := checker.Check(ctx)
 		result.Checks[checker.Name()] = status
 
@@ -351,7 +366,7 @@ func (h *HealthManager) HTTPHandler() http.HandlerFunc {
 			w.WriteHeader(http.StatusServiceUnavailable)
 		}
 
-		if err := json.NewEncoder(w).Encode(result); err != nil {
+		if err := response.WriteJSON(w, result); err != nil {
 			global.LOGGER.ErrorKV("Failed to encode health check response", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		}
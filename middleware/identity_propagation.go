@@ -0,0 +1,267 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\identity_propagation.go
+ * @Description: 出站身份传递 - 网关调用上游 gRPC 服务时按上游选择令牌：原样转发、签发内部 JWT 或 OAuth2 令牌交换，
+ *               可同时写入身份头（x-user-id / x-tenant-id / x-principal-*）供上游直接读取调用方身份
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 出站令牌传递方式
+const (
+	IdentityModeNone          = "none"           // 不向上游发送令牌
+	IdentityModePassthrough   = "passthrough"    // 原样转发调用方令牌
+	IdentityModeInternalJWT   = "internal_jwt"   // 网关按 Principal 签发内部 JWT
+	IdentityModeTokenExchange = "token_exchange" // OAuth2 令牌交换（RFC 8693）
+)
+
+// UpstreamIdentity 单个上游的身份传递配置，Mode 为空时不改动出站 metadata
+type UpstreamIdentity struct {
+	Mode        string               // 令牌传递方式
+	Impersonate bool                 // 写入身份头，上游无需解析令牌即可读取调用方身份
+	JWT         *InternalJWTConfig   // internal_jwt 签发配置
+	Exchange    *TokenExchangeConfig // token_exchange 交换配置
+}
+
+// IdentityPropagationConfig 出站身份传递配置
+type IdentityPropagationConfig struct {
+	Default   UpstreamIdentity            // 未单独配置的上游
+	Upstreams map[string]UpstreamIdentity // 键为服务名（grpc.clients）或 RegisterProxyHandler / NewUpstreamPool 的 endpoint
+}
+
+// IdentityPropagator 编译后的出站身份传递器
+type IdentityPropagator struct {
+	fallback  *upstreamIdentity
+	upstreams map[string]*upstreamIdentity
+}
+
+// upstreamIdentity 已创建签发器 / 交换客户端的上游配置
+type upstreamIdentity struct {
+	UpstreamIdentity
+	minter    *jwtMinter
+	exchanger *tokenExchanger
+}
+
+// identityPropagator 当前生效的传递器，未设置时出站拦截器不做处理
+var identityPropagator atomic.Pointer[IdentityPropagator]
+
+// identityPropagationTotal 出站身份传递次数（注册到默认 Registry）
+var identityPropagationTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_identity_propagation_total",
+	Help: "Total number of outgoing calls by identity propagation mode and result",
+}, []string{"upstream", "mode", "result"})
+
+// NewIdentityPropagator 校验配置并创建出站身份传递器
+func NewIdentityPropagator(cfg IdentityPropagationConfig) (*IdentityPropagator, error) {
+	fallback, err := newUpstreamIdentity("", cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+	p := &IdentityPropagator{fallback: fallback, upstreams: make(map[string]*upstreamIdentity, len(cfg.Upstreams))}
+	for name, upstream := range cfg.Upstreams {
+		u, err := newUpstreamIdentity(name, upstream)
+		if err != nil {
+			return nil, err
+		}
+		p.upstreams[name] = u
+	}
+	return p, nil
+}
+
+// newUpstreamIdentity 按传递方式校验并创建上游配置
+func newUpstreamIdentity(name string, cfg UpstreamIdentity) (*upstreamIdentity, error) {
+	u := &upstreamIdentity{UpstreamIdentity: cfg}
+	switch cfg.Mode {
+	case "", IdentityModeNone, IdentityModePassthrough:
+	case IdentityModeInternalJWT:
+		if cfg.JWT == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "upstream %q: internal_jwt requires jwt config", name)
+		}
+		minter, err := newJWTMinter(*cfg.JWT)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "upstream %q: %v", name, err)
+		}
+		u.minter = minter
+	case IdentityModeTokenExchange:
+		if cfg.Exchange == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "upstream %q: token_exchange requires exchange config", name)
+		}
+		exchanger, err := newTokenExchanger(*cfg.Exchange)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "upstream %q: %v", name, err)
+		}
+		u.exchanger = exchanger
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "upstream %q: unknown identity mode %q", name, cfg.Mode)
+	}
+	return u, nil
+}
+
+// SetIdentityPropagation 设置出站身份传递，nil 关闭；对之后的出站调用立即生效
+func SetIdentityPropagation(cfg *IdentityPropagationConfig) error {
+	if cfg == nil {
+		identityPropagator.Store(nil)
+		return nil
+	}
+	p, err := NewIdentityPropagator(*cfg)
+	if err != nil {
+		return err
+	}
+	identityPropagator.Store(p)
+	return nil
+}
+
+// UnaryClientIdentityInterceptor gRPC 客户端一元调用身份传递拦截器，upstream 为选择配置的上游名
+// 需位于 RequestContext 传播拦截器之后，以覆盖其写入的 authorization
+func UnaryClientIdentityInterceptor(upstream string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := propagateIdentity(ctx, upstream)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientIdentityInterceptor gRPC 客户端流式调用身份传递拦截器
+func StreamClientIdentityInterceptor(upstream string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := propagateIdentity(ctx, upstream)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// IdentityDialOptions 上游连接的身份传递 dial options
+func IdentityDialOptions(upstream string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientIdentityInterceptor(upstream)),
+		grpc.WithChainStreamInterceptor(StreamClientIdentityInterceptor(upstream)),
+	}
+}
+
+// propagateIdentity 按当前配置改写出站 metadata
+func propagateIdentity(ctx context.Context, upstream string) (context.Context, error) {
+	p := identityPropagator.Load()
+	if p == nil {
+		return ctx, nil
+	}
+	return p.Apply(ctx, upstream)
+}
+
+// Apply 按上游配置改写出站 metadata 中的 authorization 与身份头；令牌签发或交换失败返回 Unavailable
+func (p *IdentityPropagator) Apply(ctx context.Context, upstream string) (context.Context, error) {
+	u, ok := p.upstreams[upstream]
+	if !ok {
+		u = p.fallback
+	}
+	if u.Mode == "" && !u.Impersonate {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	principal := contextPrincipal(ctx)
+
+	if u.Mode != "" {
+		original := outgoingAuthorization(ctx, md)
+		md.Delete(constants.MetadataAuthorization)
+
+		token, err := u.token(ctx, upstream, original, principal)
+		result := "ok"
+		switch {
+		case err != nil:
+			result = "error"
+		case token == "":
+			result = "empty"
+		}
+		identityPropagationTotal.WithLabelValues(upstream, u.Mode, result).Inc()
+		if err != nil {
+			return ctx, status.Errorf(codes.Unavailable, "identity propagation to %s failed: %v", upstream, err)
+		}
+		if token != "" {
+			md.Set(constants.MetadataAuthorization, token)
+		}
+	}
+
+	if u.Impersonate {
+		// 身份头只来自认证结果，匿名调用时也清除客户端带来的同名头
+		md.Delete(constants.MetadataUserID)
+		md.Delete(constants.MetadataTenantID)
+		for _, name := range principalHeaders {
+			md.Delete(name)
+		}
+		for key, values := range PrincipalToMetadata(principal) {
+			md[key] = values
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// token 按传递方式生成出站 authorization 值，为空表示不发送
+func (u *upstreamIdentity) token(ctx context.Context, upstream, original string, principal *Principal) (string, error) {
+	switch u.Mode {
+	case IdentityModePassthrough:
+		return original, nil
+	case IdentityModeInternalJWT:
+		if principal.IsAnonymous() {
+			return "", nil
+		}
+		token, err := u.minter.Mint(principal, upstream)
+		if err != nil {
+			return "", err
+		}
+		return constants.AuthSchemeBearer + " " + token, nil
+	case IdentityModeTokenExchange:
+		subjectToken := bearerToken(&AuthzRequest{Headers: map[string]string{strings.ToLower(constants.HeaderAuthorization): original}})
+		if subjectToken == "" {
+			return "", nil
+		}
+		token, err := u.exchanger.Exchange(ctx, subjectToken)
+		if err != nil {
+			return "", err
+		}
+		return constants.AuthSchemeBearer + " " + token, nil
+	}
+	return "", nil
+}
+
+// outgoingAuthorization 调用方的原始 authorization：出站 metadata（grpc-gateway 转发或 RequestContext 注入）优先，
+// 其次为 gRPC 入站 metadata
+func outgoingAuthorization(ctx context.Context, md metadata.MD) string {
+	for _, value := range md.Get(constants.MetadataAuthorization) {
+		if value != "" {
+			return value
+		}
+	}
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range incoming.Get(constants.MetadataAuthorization) {
+			if value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,356 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\identity_tokens.go
+ * @Description: 出站令牌 - 网关签发的内部 JWT（HS256 / RS256 / ES256 / EdDSA）与 OAuth2 令牌交换客户端（RFC 8693），
+ *               均按身份缓存到临近过期
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+)
+
+// 出站令牌默认值
+const (
+	defaultInternalJWTTTL       = 5 * time.Minute
+	defaultTokenExchangeTimeout = 2 * time.Second
+	defaultOutboundTokenEntries = 10000
+	tokenExchangeMaxResponse    = 64 << 10
+	tokenExchangeGrantType      = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken        = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// InternalJWTConfig 网关签发内部 JWT 的配置，HMACKey 与 Signer 二选一
+type InternalJWTConfig struct {
+	Issuer   string        // iss
+	Audience string        // aud，默认上游名
+	TTL      time.Duration // 有效期，默认 5m
+	KeyID    string        // 头部 kid
+
+	HMACKey []byte        // HS256 密钥
+	Signer  crypto.Signer // RS256（*rsa.PrivateKey）、ES256（P-256 *ecdsa.PrivateKey）或 EdDSA（ed25519.PrivateKey）
+
+	Claims []string // 从 Principal 选取的声明：roles、scope、tenant、auth_type 或 Attributes 中的键；sub 始终写入
+}
+
+// TokenExchangeConfig OAuth2 令牌交换配置（RFC 8693），调用方令牌作为 subject_token
+type TokenExchangeConfig struct {
+	Endpoint           string       // 令牌端点
+	ClientID           string       // 客户端凭证（HTTP Basic 认证）
+	ClientSecret       string       // 客户端密钥
+	Audience           string       // audience
+	Resource           string       // resource
+	Scope              string       // scope，空格分隔
+	RequestedTokenType string       // requested_token_type，为空由授权服务决定
+	Client             *http.Client // HTTP 客户端，默认 http.DefaultClient
+	Timeout            time.Duration
+	CacheMaxEntries    int // 交换结果缓存上限，默认 10000
+}
+
+// jwtMinter 内部 JWT 签发器
+type jwtMinter struct {
+	config InternalJWTConfig
+	alg    string
+	cache  *outboundTokenCache
+}
+
+// tokenExchanger 令牌交换客户端
+type tokenExchanger struct {
+	config TokenExchangeConfig
+	cache  *outboundTokenCache
+}
+
+// outboundTokenCache 出站令牌缓存，条目在 refreshAt 之后不再使用
+type outboundTokenCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]outboundToken
+}
+
+// outboundToken 缓存的出站令牌
+type outboundToken struct {
+	token     string
+	refreshAt time.Time
+}
+
+// newJWTMinter 校验签名密钥并创建签发器
+func newJWTMinter(cfg InternalJWTConfig) (*jwtMinter, error) {
+	var alg string
+	switch key := cfg.Signer.(type) {
+	case nil:
+		if len(cfg.HMACKey) == 0 {
+			return nil, fmt.Errorf("internal jwt requires hmac key or signer")
+		}
+		alg = "HS256"
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("internal jwt supports only P-256 ecdsa keys")
+		}
+		alg = "ES256"
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("unsupported internal jwt signer %T", cfg.Signer)
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultInternalJWTTTL
+	}
+	return &jwtMinter{config: cfg, alg: alg, cache: newOutboundTokenCache(defaultOutboundTokenEntries)}, nil
+}
+
+// Mint 为身份签发访问 upstream 的 JWT；同一身份在有效期的前 80% 内复用
+func (m *jwtMinter) Mint(p *Principal, upstream string) (string, error) {
+	audience := m.config.Audience
+	if audience == "" {
+		audience = upstream
+	}
+	claims := map[string]any{"sub": p.Subject}
+	if m.config.Issuer != "" {
+		claims["iss"] = m.config.Issuer
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	for _, name := range m.config.Claims {
+		switch name {
+		case "roles":
+			if len(p.Roles) > 0 {
+				claims[name] = p.Roles
+			}
+		case "scope":
+			if len(p.Scopes) > 0 {
+				claims[name] = strings.Join(p.Scopes, " ")
+			}
+		case "tenant":
+			if p.Tenant != "" {
+				claims[name] = p.Tenant
+			}
+		case "auth_type":
+			if p.AuthType != "" {
+				claims[name] = p.AuthType
+			}
+		default:
+			if value, ok := p.Attributes[name]; ok {
+				claims[name] = value
+			}
+		}
+	}
+
+	// 缓存键由不含时间字段的声明决定
+	identity, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	key := string(identity)
+	now := time.Now()
+	if token, ok := m.cache.get(key, now); ok {
+		return token, nil
+	}
+
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(m.config.TTL).Unix()
+	jti := make([]byte, 16)
+	_, _ = rand.Read(jti)
+	claims["jti"] = hex.EncodeToString(jti)
+
+	token, err := m.sign(claims)
+	if err != nil {
+		return "", err
+	}
+	m.cache.set(key, token, now.Add(m.config.TTL*4/5), now)
+	return token, nil
+}
+
+// sign 生成紧凑序列化的 JWS
+func (m *jwtMinter) sign(claims map[string]any) (string, error) {
+	header := map[string]string{"alg": m.alg, "typ": "JWT"}
+	if m.config.KeyID != "" {
+		header["kid"] = m.config.KeyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	digest := sha256.Sum256([]byte(signingInput))
+	switch key := m.config.Signer.(type) {
+	case nil:
+		mac := hmac.New(sha256.New, m.config.HMACKey)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, key, digest[:])
+		if signErr != nil {
+			return "", signErr
+		}
+		// JWS 的 ECDSA 签名为定长 r || s
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signingInput))
+	}
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// newTokenExchanger 校验令牌端点并创建交换客户端
+func newTokenExchanger(cfg TokenExchangeConfig) (*tokenExchanger, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid token exchange endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTokenExchangeTimeout
+	}
+	if cfg.CacheMaxEntries <= 0 {
+		cfg.CacheMaxEntries = defaultOutboundTokenEntries
+	}
+	return &tokenExchanger{config: cfg, cache: newOutboundTokenCache(cfg.CacheMaxEntries)}, nil
+}
+
+// Exchange 以调用方令牌换取上游令牌；结果按 expires_in 缓存，提前 20% 刷新
+func (e *tokenExchanger) Exchange(ctx context.Context, subjectToken string) (string, error) {
+	key := introspectionKey(subjectToken)
+	now := time.Now()
+	if token, ok := e.cache.get(key, now); ok {
+		return token, nil
+	}
+
+	form := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {tokenTypeAccessToken},
+	}
+	for name, value := range map[string]string{
+		"audience":             e.config.Audience,
+		"resource":             e.config.Resource,
+		"scope":                e.config.Scope,
+		"requested_token_type": e.config.RequestedTokenType,
+	} {
+		if value != "" {
+			form.Set(name, value)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(constants.HeaderContentType, "application/x-www-form-urlencoded")
+	req.Header.Set(constants.HeaderAccept, "application/json")
+	if e.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(e.config.ClientID), url.QueryEscape(e.config.ClientSecret))
+	}
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, tokenExchangeMaxResponse))
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("token exchange returned status %d with invalid body: %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("token exchange returned status %d: %s %s", resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+	if result.ExpiresIn > 0 {
+		ttl := time.Duration(result.ExpiresIn) * time.Second
+		e.cache.set(key, result.AccessToken, now.Add(ttl*4/5), now)
+	}
+	return result.AccessToken, nil
+}
+
+// newOutboundTokenCache 创建出站令牌缓存
+func newOutboundTokenCache(maxEntries int) *outboundTokenCache {
+	return &outboundTokenCache{maxEntries: maxEntries, entries: make(map[string]outboundToken)}
+}
+
+// get 读取未到刷新时间的令牌
+func (c *outboundTokenCache) get(key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(entry.refreshAt) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.token, true
+}
+
+// set 写入令牌，达到上限时先清理到期条目，仍然满时随机淘汰
+func (c *outboundTokenCache) set(key, token string, refreshAt, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.refreshAt) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = outboundToken{token: token, refreshAt: refreshAt}
+}
@@ -0,0 +1,417 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\introspection.go
+ * @Description: 令牌内省认证器（RFC 7662）- 不透明访问令牌交给 IdP 内省端点校验；
+ *               有效结果按 exp 缓存、无效令牌短期负缓存，IdP 故障时全体请求共享退避窗口，
+ *               可在宽限期内沿用最近一次有效结果
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// PrincipalAuthIntrospection 内省认证器产生的身份的认证方式
+const PrincipalAuthIntrospection = "introspection"
+
+// 内省默认值
+const (
+	defaultIntrospectionTimeout     = 2 * time.Second
+	defaultIntrospectionCacheTTL    = 5 * time.Minute
+	defaultIntrospectionNegativeTTL = 30 * time.Second
+	defaultIntrospectionMaxEntries  = 10000
+	defaultIntrospectionBackoff     = time.Second
+	defaultIntrospectionBackoffMax  = 30 * time.Second
+	introspectionMaxResponseBody    = 64 << 10
+)
+
+// IntrospectionConfig 令牌内省配置
+type IntrospectionConfig struct {
+	Endpoint      string       // 内省端点，如 https://idp.example.com/oauth2/introspect
+	ClientID      string       // 客户端凭证（HTTP Basic 认证）
+	ClientSecret  string       // 客户端密钥
+	TokenTypeHint string       // token_type_hint，默认 access_token
+	Client        *http.Client // HTTP 客户端，默认 http.DefaultClient
+	Timeout       time.Duration
+
+	CacheTTL        time.Duration // 有效令牌的最长缓存时间（不超过 exp），默认 5m
+	NegativeTTL     time.Duration // 无效令牌的缓存时间，默认 30s；小于 0 不缓存
+	CacheMaxEntries int           // 缓存条目上限，默认 10000
+
+	BackoffInitial time.Duration // IdP 调用失败后的首次退避，默认 1s，此后逐次翻倍
+	BackoffMax     time.Duration // 退避上限，默认 30s
+	GracePeriod    time.Duration // IdP 不可用时，缓存过期后仍沿用最近一次有效结果的时长（不超过 exp）；0 不启用
+
+	RolesClaim  string // 角色声明名，默认 roles（字符串数组或空格分隔字符串）
+	TenantClaim string // 租户声明名，为空不读取
+
+	TokenFromRequest func(req *AuthzRequest) string                        // 读取令牌，默认 Authorization: Bearer
+	MapPrincipal     func(resp *IntrospectionResponse) (*Principal, error) // 自定义身份映射
+}
+
+// IntrospectionResponse 内省响应（RFC 7662 第 2.2 节）
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       any    `json:"aud,omitempty"` // 字符串或字符串数组
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+
+	Claims map[string]any `json:"-"` // 响应中的全部字段，含 IdP 扩展声明
+}
+
+// TokenIntrospector 令牌内省认证器
+type TokenIntrospector struct {
+	config IntrospectionConfig
+
+	mu      sync.Mutex
+	entries map[string]*introspectionEntry
+	calls   map[string]*introspectionCall
+
+	failures     int       // 连续失败次数
+	backoffUntil time.Time // 退避窗口结束时间，窗口内不调用 IdP
+}
+
+// introspectionEntry 缓存的内省结果
+type introspectionEntry struct {
+	resp       *IntrospectionResponse
+	expires    time.Time // 缓存到期
+	staleUntil time.Time // IdP 不可用时可沿用到此时
+}
+
+// introspectionCall 进行中的内省调用，同一令牌的并发请求共享结果
+type introspectionCall struct {
+	done chan struct{}
+	resp *IntrospectionResponse
+	err  error
+}
+
+// introspectionTotal 内省结果数（注册到默认 Registry）
+var introspectionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_token_introspection_total",
+	Help: "Total number of token introspection results",
+}, []string{"result", "cached"})
+
+// NewTokenIntrospector 校验配置并创建令牌内省认证器
+func NewTokenIntrospector(cfg IntrospectionConfig) (*TokenIntrospector, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid introspection endpoint %q", cfg.Endpoint)
+	}
+	if cfg.TokenTypeHint == "" {
+		cfg.TokenTypeHint = "access_token"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultIntrospectionTimeout
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defaultIntrospectionCacheTTL
+	}
+	if cfg.NegativeTTL == 0 {
+		cfg.NegativeTTL = defaultIntrospectionNegativeTTL
+	}
+	if cfg.CacheMaxEntries <= 0 {
+		cfg.CacheMaxEntries = defaultIntrospectionMaxEntries
+	}
+	if cfg.BackoffInitial <= 0 {
+		cfg.BackoffInitial = defaultIntrospectionBackoff
+	}
+	if cfg.BackoffMax < cfg.BackoffInitial {
+		cfg.BackoffMax = max(defaultIntrospectionBackoffMax, cfg.BackoffInitial)
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.TokenFromRequest == nil {
+		cfg.TokenFromRequest = bearerToken
+	}
+	return &TokenIntrospector{
+		config:  cfg,
+		entries: make(map[string]*introspectionEntry),
+		calls:   make(map[string]*introspectionCall),
+	}, nil
+}
+
+// Authenticate 实现 Authenticator：未携带令牌交给下一个认证器，令牌无效返回错误，IdP 不可用返回 ErrAuthnUnavailable
+func (t *TokenIntrospector) Authenticate(ctx context.Context, req *AuthzRequest) (*Principal, error) {
+	token := t.config.TokenFromRequest(req)
+	if token == "" {
+		return nil, nil
+	}
+	resp, err := t.Introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := introspectionValid(resp, time.Now()); err != nil {
+		return nil, err
+	}
+	if t.config.MapPrincipal != nil {
+		return t.config.MapPrincipal(resp)
+	}
+	return t.principal(resp), nil
+}
+
+// Introspect 内省令牌：优先读取缓存，同一令牌的并发调用合并为一次；
+// 返回的响应可能是 active=false，调用方需自行检查（Authenticate 已处理）
+func (t *TokenIntrospector) Introspect(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	key := introspectionKey(token)
+	now := time.Now()
+
+	t.mu.Lock()
+	entry := t.entries[key]
+	if entry != nil && now.Before(entry.expires) {
+		t.mu.Unlock()
+		introspectionTotal.WithLabelValues(introspectionResult(entry.resp), "true").Inc()
+		return entry.resp, nil
+	}
+	if now.Before(t.backoffUntil) {
+		err := fmt.Errorf("%w: introspection backing off after %d failures", ErrAuthnUnavailable, t.failures)
+		t.mu.Unlock()
+		return t.fallback(entry, now, err)
+	}
+	call, inflight := t.calls[key]
+	if !inflight {
+		call = &introspectionCall{done: make(chan struct{})}
+		t.calls[key] = call
+	}
+	t.mu.Unlock()
+
+	if !inflight {
+		// 调用不随单个请求取消，合并进来的其他请求仍需要结果
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), t.config.Timeout)
+		call.resp, call.err = t.fetch(callCtx, token)
+		cancel()
+		t.complete(key, call, time.Now())
+		close(call.done)
+	} else {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if call.err != nil {
+		return t.fallback(entry, time.Now(), call.err)
+	}
+	introspectionTotal.WithLabelValues(introspectionResult(call.resp), "false").Inc()
+	return call.resp, nil
+}
+
+// complete 记录调用结果：成功时写入缓存并清除退避，失败时延长共享退避窗口
+func (t *TokenIntrospector) complete(key string, call *introspectionCall, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.calls, key)
+
+	if call.err != nil {
+		t.failures++
+		backoff := t.config.BackoffInitial << min(t.failures-1, 16)
+		if backoff <= 0 || backoff > t.config.BackoffMax {
+			backoff = t.config.BackoffMax
+		}
+		t.backoffUntil = now.Add(backoff)
+		return
+	}
+	t.failures, t.backoffUntil = 0, time.Time{}
+
+	resp := call.resp
+	var entry *introspectionEntry
+	if resp.Active {
+		expires := now.Add(t.config.CacheTTL)
+		staleUntil := expires.Add(t.config.GracePeriod)
+		if resp.Exp > 0 {
+			exp := time.Unix(resp.Exp, 0)
+			if exp.Before(expires) {
+				expires = exp
+			}
+			if exp.Before(staleUntil) {
+				staleUntil = exp
+			}
+		}
+		entry = &introspectionEntry{resp: resp, expires: expires, staleUntil: staleUntil}
+	} else if t.config.NegativeTTL > 0 {
+		expires := now.Add(t.config.NegativeTTL)
+		entry = &introspectionEntry{resp: resp, expires: expires, staleUntil: expires}
+	}
+	if entry == nil || !now.Before(entry.expires) {
+		delete(t.entries, key)
+		return
+	}
+	t.store(key, entry, now)
+}
+
+// store 写入缓存，达到上限时先清理已无法使用的条目，仍然满时随机淘汰
+func (t *TokenIntrospector) store(key string, entry *introspectionEntry, now time.Time) {
+	if _, exists := t.entries[key]; !exists && len(t.entries) >= t.config.CacheMaxEntries {
+		for k, e := range t.entries {
+			if !now.Before(e.staleUntil) {
+				delete(t.entries, k)
+			}
+		}
+		for k := range t.entries {
+			if len(t.entries) < t.config.CacheMaxEntries {
+				break
+			}
+			delete(t.entries, k)
+		}
+	}
+	t.entries[key] = entry
+}
+
+// fallback IdP 不可用时在宽限期内沿用最近一次有效结果，否则返回不可用错误
+func (t *TokenIntrospector) fallback(entry *introspectionEntry, now time.Time, err error) (*IntrospectionResponse, error) {
+	if entry != nil && entry.resp.Active && now.Before(entry.staleUntil) {
+		introspectionTotal.WithLabelValues(constants.IntrospectionResultStale, "true").Inc()
+		return entry.resp, nil
+	}
+	introspectionTotal.WithLabelValues(constants.IntrospectionResultUnavailable, "false").Inc()
+	return nil, err
+}
+
+// fetch 调用内省端点；网络错误、非 200 响应与无法解析的响应体均视为 IdP 不可用
+func (t *TokenIntrospector) fetch(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {t.config.TokenTypeHint}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(constants.HeaderContentType, "application/x-www-form-urlencoded")
+	req.Header.Set(constants.HeaderAccept, "application/json")
+	if t.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(t.config.ClientID), url.QueryEscape(t.config.ClientSecret))
+	}
+
+	resp, err := t.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthnUnavailable, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, introspectionMaxResponseBody))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthnUnavailable, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: introspection endpoint returned status %d", ErrAuthnUnavailable, resp.StatusCode)
+	}
+
+	result := &IntrospectionResponse{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("%w: invalid introspection response: %v", ErrAuthnUnavailable, err)
+	}
+	if err := json.Unmarshal(body, &result.Claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid introspection response: %v", ErrAuthnUnavailable, err)
+	}
+	return result, nil
+}
+
+// principal 默认身份映射：主体依次取 sub、username、client_id，scope 按空格切分
+func (t *TokenIntrospector) principal(resp *IntrospectionResponse) *Principal {
+	p := &Principal{
+		Subject:    resp.Sub,
+		Scopes:     strings.Fields(resp.Scope),
+		Roles:      claimStrings(resp.Claims[t.config.RolesClaim]),
+		AuthType:   PrincipalAuthIntrospection,
+		Attributes: make(map[string]string),
+	}
+	if p.Subject == "" {
+		p.Subject = resp.Username
+	}
+	if p.Subject == "" {
+		p.Subject = resp.ClientID
+	}
+	if t.config.TenantClaim != "" {
+		p.Tenant, _ = resp.Claims[t.config.TenantClaim].(string)
+	}
+	for name, value := range map[string]string{"client_id": resp.ClientID, "username": resp.Username, "iss": resp.Iss, "jti": resp.Jti} {
+		if value != "" {
+			p.Attributes[name] = value
+		}
+	}
+	return p
+}
+
+// introspectionValid 检查令牌是否有效：active 且处于 nbf 与 exp 之间
+func introspectionValid(resp *IntrospectionResponse, now time.Time) error {
+	switch {
+	case !resp.Active:
+		return stderrors.New("token is not active")
+	case resp.Nbf > 0 && now.Before(time.Unix(resp.Nbf, 0)):
+		return fmt.Errorf("token is not valid before %s", time.Unix(resp.Nbf, 0).UTC().Format(time.RFC3339))
+	case resp.Exp > 0 && !now.Before(time.Unix(resp.Exp, 0)):
+		return fmt.Errorf("token expired at %s", time.Unix(resp.Exp, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// introspectionResult 内省响应对应的指标结果
+func introspectionResult(resp *IntrospectionResponse) string {
+	if resp.Active {
+		return constants.IntrospectionResultActive
+	}
+	return constants.IntrospectionResultInactive
+}
+
+// introspectionKey 缓存键为令牌摘要，内存中不保留原始令牌
+func introspectionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken 读取 Authorization 头中的 Bearer 令牌
+func bearerToken(req *AuthzRequest) string {
+	scheme, token, ok := strings.Cut(req.Headers[strings.ToLower(constants.HeaderAuthorization)], " ")
+	if !ok || !strings.EqualFold(scheme, constants.AuthSchemeBearer) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// claimStrings 声明值转为字符串列表：字符串数组或空格分隔的字符串
+func claimStrings(v any) []string {
+	switch value := v.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		out := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,548 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\jobs.go
+ * @Description: 异步任务 - 长耗时请求 POST 后立即返回 202 与任务 ID，由后台 worker 执行处理函数，
+ *               状态与进度写入状态存储（Redis 或内嵌存储）供任意实例查询，结果可内联、存入 MinIO 以预签名 URL 下载或通过 webhook 推送
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 任务状态
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// 异步任务默认值
+const (
+	DefaultJobsPath           = "/jobs/"
+	DefaultJobWorkers         = 4
+	DefaultJobQueueSize       = 100
+	DefaultJobTimeout         = 30 * time.Minute
+	DefaultJobRetention       = 24 * time.Hour
+	DefaultJobMaxBodyBytes    = 1 << 20
+	DefaultJobPresignExpiry   = time.Hour
+	DefaultJobWebhookTimeout  = 10 * time.Second
+	jobKeyPrefix              = "gateway:job:"
+	jobResultKeyPrefix        = "jobs/"
+	jobWebhookSignaturePrefix = "sha256="
+	jobRetryAfterSeconds      = 5 // 队列满时建议的重试间隔
+)
+
+// JobsConfig 异步任务配置
+type JobsConfig struct {
+	Path           string        // 状态查询前缀，默认 /jobs/，GET {Path}{id}
+	Workers        int           // 并发执行的任务数，默认 4
+	QueueSize      int           // 等待队列长度，默认 100，队列满时提交返回 503
+	Timeout        time.Duration // 单个任务执行超时，默认 30m
+	Retention      time.Duration // 任务状态保留时长，默认 24h
+	MaxBodyBytes   int64         // 提交请求体上限，默认 1MiB（请求体随任务保存在内存中）
+	ResultBucket   string        // 文件结果存放的 MinIO 桶，对象键为 jobs/{id}/{文件名}
+	PresignExpiry  time.Duration // 结果预签名 URL 有效期，默认 1h，每次查询重新签发
+	WebhookHosts   []string      // 允许作为回调地址的主机名，为空时不接受回调
+	WebhookSecret  string        // 回调请求体的 HMAC-SHA256 签名密钥，为空不签名
+	WebhookTimeout time.Duration // 回调请求超时，默认 10s
+}
+
+// JobRequest 提交任务时的请求快照，处理函数在请求结束后执行，不能再读取原始请求
+type JobRequest struct {
+	Method    string
+	Path      string
+	RawQuery  string
+	Header    http.Header
+	Body      []byte
+	Principal *Principal
+}
+
+// JobResult 任务结果，Data 内联在状态中；File 不为空时上传到 ResultBucket，查询时返回预签名 URL
+type JobResult struct {
+	Data        any
+	File        io.Reader
+	FileName    string
+	ContentType string
+	Size        int64 // 文件大小，未知时为 -1
+}
+
+// JobFunc 任务处理函数，ctx 在超时或网关关闭时取消
+type JobFunc func(ctx context.Context, job *Job) (*JobResult, error)
+
+// JobState 任务状态，以 JSON 保存在状态存储中
+type JobState struct {
+	ID           string          `json:"id"`
+	Status       string          `json:"status"`
+	Progress     int             `json:"progress"`
+	Message      string          `json:"message,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	ResultURL    string          `json:"result_url,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+	Owner        string          `json:"owner,omitempty"`         // 提交者 Principal.Subject，只有本人可以查询
+	ResultObject string          `json:"result_object,omitempty"` // 结果文件对象键
+	Callback     string          `json:"-"`
+}
+
+// Job 执行中的任务
+type Job struct {
+	ID      string
+	Request *JobRequest
+
+	fn      JobFunc
+	manager *JobManager
+	state   *JobState
+}
+
+// JobManager 任务队列与 worker
+type JobManager struct {
+	config JobsConfig
+	hosts  map[string]struct{}
+	queue  chan *Job
+	client *http.Client
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.RWMutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// 异步任务指标（注册到默认 Registry）
+var (
+	jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_jobs_total",
+		Help: "Total number of async jobs by final status (rejected when the queue is full)",
+	}, []string{"status"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_job_duration_seconds",
+		Help:    "Execution time of async jobs by final status",
+		Buckets: []float64{.1, .5, 1, 5, 10, 30, 60, 300, 900, 1800},
+	}, []string{"status"})
+
+	jobsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_jobs_queued",
+		Help: "Number of async jobs waiting for a worker",
+	})
+)
+
+// NewJobManager 校验配置并启动 worker
+func NewJobManager(cfg JobsConfig) (*JobManager, error) {
+	if cfg.Path == "" {
+		cfg.Path = DefaultJobsPath
+	}
+	if !strings.HasSuffix(cfg.Path, "/") {
+		cfg.Path += "/"
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultJobWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultJobQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultJobTimeout
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultJobRetention
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultJobMaxBodyBytes
+	}
+	if cfg.PresignExpiry <= 0 {
+		cfg.PresignExpiry = DefaultJobPresignExpiry
+	}
+	if cfg.WebhookTimeout <= 0 {
+		cfg.WebhookTimeout = DefaultJobWebhookTimeout
+	}
+	if cfg.Retention < cfg.Timeout {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "job retention must not be shorter than job timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &JobManager{
+		config: cfg,
+		hosts:  make(map[string]struct{}, len(cfg.WebhookHosts)),
+		queue:  make(chan *Job, cfg.QueueSize),
+		client: &http.Client{Timeout: cfg.WebhookTimeout},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, h := range cfg.WebhookHosts {
+		m.hosts[strings.ToLower(h)] = struct{}{}
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m, nil
+}
+
+// Path 状态查询前缀
+func (m *JobManager) Path() string {
+	return m.config.Path
+}
+
+// Submit 把请求转为任务：读取请求体、保存 pending 状态并入队，返回 202 与任务 ID
+// 请求头 X-Job-Callback 指定回调地址，主机名必须在 WebhookHosts 中
+func (m *JobManager) Submit(w http.ResponseWriter, r *http.Request, fn JobFunc) {
+	if r.Method != http.MethodPost {
+		response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "use POST to create a job")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.config.MaxBodyBytes))
+	if err != nil {
+		response.WriteErrorResponse(w, errors.ErrRequestTooLarge)
+		return
+	}
+	callback := r.Header.Get(constants.HeaderXJobCallback)
+	if callback != "" {
+		if err := m.checkCallback(callback); err != nil {
+			response.WriteBadRequestResult(w, err.Error())
+			return
+		}
+	}
+	if global.STORE == nil {
+		response.WriteServiceUnavailableResult(w, "job store is not initialized")
+		return
+	}
+
+	req := &JobRequest{Method: r.Method, Path: r.URL.Path, RawQuery: r.URL.RawQuery, Header: r.Header.Clone(), Body: body}
+	state := &JobState{ID: newJobID(), Status: JobStatusPending, CreatedAt: time.Now(), Callback: callback}
+	if p, ok := PrincipalFromContext(r.Context()); ok && p != nil {
+		req.Principal = p
+		state.Owner = p.Subject
+	}
+	job := &Job{ID: state.ID, Request: req, fn: fn, manager: m, state: state}
+	if err := m.save(r.Context(), state); err != nil {
+		response.WriteServiceUnavailableResult(w, "save job: "+err.Error())
+		return
+	}
+
+	m.mu.RLock()
+	accepted := !m.closing
+	if accepted {
+		select {
+		case m.queue <- job:
+			jobsQueued.Inc()
+		default:
+			accepted = false
+		}
+	}
+	m.mu.RUnlock()
+	if !accepted {
+		jobsTotal.WithLabelValues("rejected").Inc()
+		_ = global.STORE.Del(r.Context(), jobKeyPrefix+state.ID)
+		w.Header().Set(constants.HeaderRetryAfter, strconv.Itoa(jobRetryAfterSeconds))
+		response.WriteServiceUnavailableResult(w, "job queue is full")
+		return
+	}
+
+	statusURL := m.config.Path + state.ID
+	w.Header().Set(constants.HeaderLocation, statusURL)
+	response.WriteJSONResponse(w, http.StatusAccepted, map[string]string{
+		"job_id":     state.ID,
+		"status":     state.Status,
+		"status_url": statusURL,
+	})
+}
+
+// Handler 把处理函数包装为提交任务的 HTTP 处理器
+func (m *JobManager) Handler(fn JobFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Submit(w, r, fn)
+	})
+}
+
+// StatusHandler GET {Path}{id} 返回任务状态，结果文件返回新签发的预签名 URL；他人的任务返回 404
+func (m *JobManager) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "use GET to query a job")
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, m.config.Path)
+		state, err := m.load(r.Context(), id)
+		if err != nil || !jobVisible(r.Context(), state) {
+			response.WriteNotFoundResult(w, "job not found")
+			return
+		}
+		if state.ResultObject != "" {
+			state.ResultURL, err = m.presign(r.Context(), state.ResultObject)
+			if err != nil {
+				global.LOGGER.WarnKV("任务结果签名失败", "job", id, "error", err)
+			}
+		}
+		response.WriteJSONResponse(w, http.StatusOK, state)
+	}
+}
+
+// Shutdown 停止接收新任务，等待执行中的任务结束；ctx 结束时取消剩余任务，排队中的任务记为失败
+func (m *JobManager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return
+	}
+	m.closing = true
+	close(m.queue)
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		m.cancel()
+		<-done
+	}
+	m.cancel()
+}
+
+// Progress 更新任务进度（0-100）与说明
+func (j *Job) Progress(ctx context.Context, percent int, message string) error {
+	j.state.Progress = min(max(percent, 0), 100)
+	j.state.Message = message
+	return j.manager.save(ctx, j.state)
+}
+
+// worker 依次执行队列中的任务，关闭时把剩余任务记为失败
+func (m *JobManager) worker() {
+	defer m.wg.Done()
+	for job := range m.queue {
+		jobsQueued.Dec()
+		if m.ctx.Err() != nil {
+			m.finish(job, nil, stderrors.New("gateway is shutting down"), time.Now())
+			continue
+		}
+		m.run(job)
+	}
+}
+
+// run 执行单个任务
+func (m *JobManager) run(job *Job) {
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	job.state.Status = JobStatusRunning
+	job.state.StartedAt = &start
+	if err := m.save(ctx, job.state); err != nil {
+		global.LOGGER.WarnKV("任务状态保存失败", "job", job.ID, "error", err)
+	}
+
+	result, err := func() (result *JobResult, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("job panicked: %v", p)
+			}
+		}()
+		return job.fn(ctx, job)
+	}()
+	if err == nil && result != nil {
+		err = m.storeResult(ctx, job.state, result)
+	}
+	m.finish(job, result, err, start)
+}
+
+// storeResult 内联数据结果，上传文件结果
+func (m *JobManager) storeResult(ctx context.Context, state *JobState, result *JobResult) error {
+	if result.Data != nil {
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			return fmt.Errorf("encode job result: %w", err)
+		}
+		state.Result = data
+	}
+	if result.File == nil {
+		return nil
+	}
+	client := global.GetMinIO()
+	if client == nil || m.config.ResultBucket == "" {
+		return stderrors.New("job file result requires minio and result bucket")
+	}
+	size := result.Size
+	if size == 0 {
+		size = -1
+	}
+	name := path.Base(result.FileName)
+	if name == "." || name == "/" {
+		name = "result"
+	}
+	key := jobResultKeyPrefix + state.ID + "/" + name
+	if _, err := client.PutObject(ctx, m.config.ResultBucket, key, result.File, size, minio.PutObjectOptions{ContentType: result.ContentType}); err != nil {
+		return fmt.Errorf("upload job result: %w", err)
+	}
+	state.ResultObject = key
+	return nil
+}
+
+// finish 记录最终状态并发送回调
+func (m *JobManager) finish(job *Job, result *JobResult, err error, start time.Time) {
+	now := time.Now()
+	state := job.state
+	state.FinishedAt = &now
+	if err != nil {
+		state.Status, state.Error = JobStatusFailed, err.Error()
+	} else {
+		state.Status, state.Progress = JobStatusSucceeded, 100
+	}
+	jobsTotal.WithLabelValues(state.Status).Inc()
+	jobDuration.WithLabelValues(state.Status).Observe(now.Sub(start).Seconds())
+
+	// 任务 ctx 可能已取消，最终状态与回调使用独立的超时
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.WebhookTimeout)
+	defer cancel()
+	if err := m.save(ctx, state); err != nil {
+		global.LOGGER.WarnKV("任务状态保存失败", "job", job.ID, "error", err)
+	}
+	if state.Callback != "" {
+		m.deliver(ctx, state)
+	}
+}
+
+// deliver 向回调地址 POST 最终状态，带签名头 X-Job-Signature: sha256=<hex>
+func (m *JobManager) deliver(ctx context.Context, state *JobState) {
+	payload := *state
+	if payload.ResultObject != "" {
+		payload.ResultURL, _ = m.presign(ctx, payload.ResultObject)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, state.Callback, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set(constants.HeaderContentType, "application/json")
+	if m.config.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(m.config.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(constants.HeaderXJobSignature, jobWebhookSignaturePrefix+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		global.LOGGER.WarnKV("任务回调失败", "job", state.ID, "callback", state.Callback, "error", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		global.LOGGER.WarnKV("任务回调返回非 2xx", "job", state.ID, "callback", state.Callback, "status", resp.StatusCode)
+	}
+}
+
+// checkCallback 回调地址必须是 http(s) 且主机名在白名单中，防止被用来探测内网
+func (m *JobManager) checkCallback(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid job callback %q", raw)
+	}
+	if _, ok := m.hosts[strings.ToLower(u.Hostname())]; !ok {
+		return fmt.Errorf("job callback host %q is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// presign 签发结果文件下载地址
+func (m *JobManager) presign(ctx context.Context, key string) (string, error) {
+	client := global.GetMinIO()
+	if client == nil {
+		return "", stderrors.New("minio client is not initialized")
+	}
+	u, err := client.PresignedGetObject(ctx, m.config.ResultBucket, key, m.config.PresignExpiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// save 写入任务状态
+func (m *JobManager) save(ctx context.Context, state *JobState) error {
+	if global.STORE == nil {
+		return stderrors.New("job store is not initialized")
+	}
+	data, err := json.Marshal(jobRecord{JobState: state, Callback: state.Callback})
+	if err != nil {
+		return err
+	}
+	return global.STORE.Set(ctx, jobKeyPrefix+state.ID, string(data), m.config.Retention)
+}
+
+// load 读取任务状态
+func (m *JobManager) load(ctx context.Context, id string) (*JobState, error) {
+	if global.STORE == nil || id == "" || strings.Contains(id, "/") {
+		return nil, store.ErrNotFound
+	}
+	raw, err := global.STORE.Get(ctx, jobKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	var record jobRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return nil, err
+	}
+	record.JobState.Callback = record.Callback
+	return record.JobState, nil
+}
+
+// jobRecord 存储格式：回调地址随状态保存，但不出现在查询响应中
+type jobRecord struct {
+	*JobState
+	Callback string `json:"callback,omitempty"`
+}
+
+// jobVisible 有提交者的任务只对同一主体可见
+func jobVisible(ctx context.Context, state *JobState) bool {
+	if state.Owner == "" {
+		return true
+	}
+	p, ok := PrincipalFromContext(ctx)
+	return ok && p != nil && p.Subject == state.Owner
+}
+
+// newJobID 生成 128 位随机任务 ID
+func newJobID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
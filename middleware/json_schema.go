@@ -0,0 +1,664 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\json_schema.go
+ * @Description: JSON Schema 子集 - 编译并校验 draft-07 / 2020-12 常用关键字：type、properties、required、
+ *               additionalProperties、items、enum、const、数值与长度边界、pattern、format、allOf / anyOf / oneOf
+ *               以及文档内的 $ref；另提供新旧版本的向后兼容检查，供 Schema 注册表使用
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonSchemaMaxErrors 单次校验最多收集的错误数
+const jsonSchemaMaxErrors = 10
+
+// jsonSchemaTypes 合法的类型名
+var jsonSchemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// jsonSchema 编译后的 Schema 节点，编译后只读
+type jsonSchema struct {
+	raw        any // 原始定义，组合关键字的兼容检查按原文比较
+	ref        *jsonSchema
+	types      []string
+	properties map[string]*jsonSchema
+	required   []string
+	additional *jsonSchema // additionalProperties 为 Schema 时
+	closed     bool        // additionalProperties: false
+	items      *jsonSchema
+	enum       []any
+	constVal   any
+	hasConst   bool
+	minimum    *float64
+	maximum    *float64
+	exclMin    *float64
+	exclMax    *float64
+	minLength  *int
+	maxLength  *int
+	minItems   *int
+	maxItems   *int
+	pattern    *regexp.Regexp
+	format     string
+	allOf      []*jsonSchema
+	anyOf      []*jsonSchema
+	oneOf      []*jsonSchema
+	never      bool // false Schema，任何值都不合法
+}
+
+// jsonSchemaCompiler 编译上下文，$ref 按 JSON Pointer 缓存以支持递归定义
+type jsonSchemaCompiler struct {
+	root any
+	refs map[string]*jsonSchema
+}
+
+// compileJSONSchema 编译 Schema 文档
+func compileJSONSchema(data []byte) (*jsonSchema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %v", err)
+	}
+	c := &jsonSchemaCompiler{root: root, refs: make(map[string]*jsonSchema)}
+	return c.compile(root, "#")
+}
+
+// compile 编译一个节点，location 用于错误信息
+func (c *jsonSchemaCompiler) compile(node any, location string) (*jsonSchema, error) {
+	switch v := node.(type) {
+	case bool:
+		return &jsonSchema{raw: v, never: !v}, nil
+	case map[string]any:
+		return c.compileObject(v, location)
+	default:
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", location)
+	}
+}
+
+// compileObject 编译对象形式的节点
+func (c *jsonSchemaCompiler) compileObject(obj map[string]any, location string) (*jsonSchema, error) {
+	s := &jsonSchema{raw: obj}
+	if ref, ok := obj["$ref"].(string); ok {
+		target, err := c.resolve(ref, location)
+		if err != nil {
+			return nil, err
+		}
+		s.ref = target
+	}
+
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, item := range t {
+			name, _ := item.(string)
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s/type: must be a string or an array of strings", location)
+	}
+	for _, t := range s.types {
+		if !slices.Contains(jsonSchemaTypes, t) {
+			return nil, fmt.Errorf("%s/type: unknown type %q", location, t)
+		}
+	}
+
+	if props, ok := obj["properties"].(map[string]any); ok {
+		s.properties = make(map[string]*jsonSchema, len(props))
+		for name, prop := range props {
+			compiled, err := c.compile(prop, location+"/properties/"+jsonPointerEscape(name))
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = compiled
+		}
+	}
+	if req, ok := obj["required"].([]any); ok {
+		for _, item := range req {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/required: must be an array of strings", location)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	switch add := obj["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.closed = !add
+	default:
+		compiled, err := c.compile(add, location+"/additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		s.additional = compiled
+	}
+	if items, ok := obj["items"]; ok {
+		compiled, err := c.compile(items, location+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.items = compiled
+	}
+
+	if enum, ok := obj["enum"].([]any); ok {
+		s.enum = enum
+	}
+	s.constVal, s.hasConst = obj["const"]
+
+	var err error
+	for key, dst := range map[string]**float64{"minimum": &s.minimum, "maximum": &s.maximum, "exclusiveMinimum": &s.exclMin, "exclusiveMaximum": &s.exclMax} {
+		if *dst, err = jsonSchemaNumber(obj, key, location); err != nil {
+			return nil, err
+		}
+	}
+	for key, dst := range map[string]**int{"minLength": &s.minLength, "maxLength": &s.maxLength, "minItems": &s.minItems, "maxItems": &s.maxItems} {
+		if *dst, err = jsonSchemaCount(obj, key, location); err != nil {
+			return nil, err
+		}
+	}
+	if pattern, ok := obj["pattern"].(string); ok {
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %v", location, err)
+		}
+	}
+	s.format, _ = obj["format"].(string)
+
+	for key, dst := range map[string]*[]*jsonSchema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		list, ok := obj[key].([]any)
+		if !ok {
+			continue
+		}
+		for i, item := range list {
+			compiled, err := c.compile(item, location+"/"+key+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			*dst = append(*dst, compiled)
+		}
+	}
+	return s, nil
+}
+
+// resolve 解析文档内的 $ref（#、#/definitions/X、#/$defs/X 等 JSON Pointer），不支持外部引用
+func (c *jsonSchemaCompiler) resolve(ref, location string) (*jsonSchema, error) {
+	if s, ok := c.refs[ref]; ok {
+		return s, nil
+	}
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("%s/$ref: only local references are supported, got %q", location, ref)
+	}
+	node := c.root
+	if ref != "#" {
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			obj, ok := node.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s/$ref: %q cannot be resolved", location, ref)
+			}
+			if node, ok = obj[token]; !ok {
+				return nil, fmt.Errorf("%s/$ref: %q cannot be resolved", location, ref)
+			}
+		}
+	}
+	// 先占位再编译，递归引用指向同一节点
+	placeholder := &jsonSchema{}
+	c.refs[ref] = placeholder
+	compiled, err := c.compile(node, ref)
+	if err != nil {
+		return nil, err
+	}
+	*placeholder = *compiled
+	return placeholder, nil
+}
+
+// jsonSchemaNumber 读取数值关键字
+func jsonSchemaNumber(obj map[string]any, key, location string) (*float64, error) {
+	raw, ok := obj[key]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := raw.(float64)
+	if !ok {
+		// draft-04 的布尔形式 exclusiveMinimum / exclusiveMaximum 不支持
+		return nil, fmt.Errorf("%s/%s: must be a number", location, key)
+	}
+	return &n, nil
+}
+
+// jsonSchemaCount 读取非负整数关键字
+func jsonSchemaCount(obj map[string]any, key, location string) (*int, error) {
+	raw, ok := obj[key]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := raw.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s/%s: must be a non-negative integer", location, key)
+	}
+	i := int(n)
+	return &i, nil
+}
+
+// jsonPointerEscape 转义 JSON Pointer 中的 ~ 与 /
+func jsonPointerEscape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// validate 校验已解码的 JSON 值（数字为 json.Number），返回带 JSON Pointer 位置的错误信息
+func (s *jsonSchema) validate(value any) []string {
+	var errs []string
+	s.check(value, "", &errs)
+	return errs
+}
+
+// check 递归校验，错误数达到上限后停止
+func (s *jsonSchema) check(value any, at string, errs *[]string) {
+	if len(*errs) >= jsonSchemaMaxErrors {
+		return
+	}
+	fail := func(format string, args ...any) {
+		if len(*errs) < jsonSchemaMaxErrors {
+			where := at
+			if where == "" {
+				where = "/"
+			}
+			*errs = append(*errs, where+": "+fmt.Sprintf(format, args...))
+		}
+	}
+	if s.never {
+		fail("value is not allowed")
+		return
+	}
+	if s.ref != nil {
+		s.ref.check(value, at, errs)
+	}
+
+	kind := jsonValueType(value)
+	if len(s.types) > 0 && !slices.Contains(s.types, kind) && !(kind == "integer" && slices.Contains(s.types, "number")) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), kind)
+		return
+	}
+	if len(s.enum) > 0 && !slices.ContainsFunc(s.enum, func(e any) bool { return jsonEqual(e, value) }) {
+		fail("value is not one of the allowed values")
+	}
+	if s.hasConst && !jsonEqual(s.constVal, value) {
+		fail("value must be %s", jsonText(s.constVal))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		s.checkNumber(v, fail)
+	case string:
+		s.checkString(v, fail)
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("array must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("array must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.check(item, at+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(v)) { // 排序使错误信息顺序稳定
+			child := at + "/" + jsonPointerEscape(name)
+			if prop, ok := s.properties[name]; ok {
+				prop.check(v[name], child, errs)
+			} else if s.additional != nil {
+				s.additional.check(v[name], child, errs)
+			} else if s.closed {
+				fail("unknown property %q", name)
+			}
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.check(value, at, errs)
+	}
+	if len(s.anyOf) > 0 && !slices.ContainsFunc(s.anyOf, func(sub *jsonSchema) bool { return len(sub.validate(value)) == 0 }) {
+		fail("value does not match any of the allowed schemas")
+	}
+	if len(s.oneOf) > 0 {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if len(sub.validate(value)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("value must match exactly one schema, matched %d", matched)
+		}
+	}
+}
+
+// checkNumber 数值边界
+func (s *jsonSchema) checkNumber(n json.Number, fail func(string, ...any)) {
+	f, err := n.Float64()
+	if err != nil {
+		fail("number %s is out of range", n)
+		return
+	}
+	if s.minimum != nil && f < *s.minimum {
+		fail("must be >= %v", *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		fail("must be <= %v", *s.maximum)
+	}
+	if s.exclMin != nil && f <= *s.exclMin {
+		fail("must be > %v", *s.exclMin)
+	}
+	if s.exclMax != nil && f >= *s.exclMax {
+		fail("must be < %v", *s.exclMax)
+	}
+}
+
+// checkString 长度、正则与格式
+func (s *jsonSchema) checkString(str string, fail func(string, ...any)) {
+	n := utf8.RuneCountInString(str)
+	if s.minLength != nil && n < *s.minLength {
+		fail("string must be at least %d characters", *s.minLength)
+	}
+	if s.maxLength != nil && n > *s.maxLength {
+		fail("string must be at most %d characters", *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		fail("string does not match pattern %s", s.pattern)
+	}
+	if s.format != "" && !jsonFormatValid(s.format, str) {
+		fail("string is not a valid %s", s.format)
+	}
+}
+
+// jsonFormatValid 校验常用 format，未知格式视为合法
+func jsonFormatValid(format, str string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, str)
+	case "date":
+		_, err = time.Parse(time.DateOnly, str)
+	case "time":
+		_, err = time.Parse("15:04:05Z07:00", str)
+	case "email":
+		var addr *mail.Address
+		if addr, err = mail.ParseAddress(str); err == nil && addr.Address != str {
+			return false
+		}
+	case "uri":
+		var u *url.URL
+		if u, err = url.Parse(str); err == nil && u.Scheme == "" {
+			return false
+		}
+	case "uuid":
+		return len(str) == 36 && uuidPattern.MatchString(str)
+	}
+	return err == nil
+}
+
+// uuidPattern UUID 文本形式
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// jsonValueType 值的 JSON Schema 类型，整数值返回 integer
+func jsonValueType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// jsonEqual 比较两个 JSON 值，数字按数值比较（Schema 中为 float64，请求体中为 json.Number）
+func jsonEqual(a, b any) bool {
+	if fa, ok := jsonFloat(a); ok {
+		fb, ok := jsonFloat(b)
+		return ok && fa == fb
+	}
+	switch av := a.(type) {
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// jsonFloat 数字转 float64
+func jsonFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// jsonText 值的 JSON 文本，用于错误信息
+func jsonText(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// jsonSchemaPair 兼容检查中已比较过的节点对，避免递归定义死循环
+type jsonSchemaPair struct{ prev, next *jsonSchema }
+
+// jsonSchemaCompat 向后兼容检查：按旧版本合法的请求体在新版本下仍应合法，返回不兼容项（带 JSON Pointer 位置）
+type jsonSchemaCompat struct {
+	issues []string
+	seen   map[jsonSchemaPair]bool
+}
+
+// checkJSONSchemaCompat 比较新旧版本，返回不兼容项，为空表示兼容
+func checkJSONSchemaCompat(prev, next *jsonSchema) []string {
+	c := &jsonSchemaCompat{seen: make(map[jsonSchemaPair]bool)}
+	c.compare(prev, next, "")
+	return c.issues
+}
+
+// report 记录不兼容项
+func (c *jsonSchemaCompat) report(at, format string, args ...any) {
+	if at == "" {
+		at = "/"
+	}
+	c.issues = append(c.issues, at+": "+fmt.Sprintf(format, args...))
+}
+
+// compare 递归比较两个节点
+func (c *jsonSchemaCompat) compare(prev, next *jsonSchema, at string) {
+	for prev.ref != nil && prev.raw != nil && len(prev.raw.(map[string]any)) == 1 {
+		prev = prev.ref
+	}
+	for next.ref != nil && next.raw != nil && len(next.raw.(map[string]any)) == 1 {
+		next = next.ref
+	}
+	pair := jsonSchemaPair{prev, next}
+	if c.seen[pair] {
+		return
+	}
+	c.seen[pair] = true
+
+	if next.never && !prev.never {
+		c.report(at, "schema no longer accepts any value")
+		return
+	}
+	if prev.never {
+		return
+	}
+	if prev.ref != nil || next.ref != nil || len(prev.allOf)+len(prev.anyOf)+len(prev.oneOf)+len(next.allOf)+len(next.anyOf)+len(next.oneOf) > 0 {
+		// 组合与引用旁带其他关键字时无法逐项推导，只接受原样不变
+		if !reflect.DeepEqual(prev.raw, next.raw) {
+			c.report(at, "composite schema ($ref / allOf / anyOf / oneOf) changed")
+		}
+		return
+	}
+
+	c.compareTypes(prev, next, at)
+	c.compareValues(prev, next, at)
+	c.compareBounds(prev, next, at)
+
+	for _, name := range next.required {
+		if !slices.Contains(prev.required, name) {
+			c.report(at, "property %q became required", name)
+		}
+	}
+	if next.closed && !prev.closed {
+		c.report(at, "additional properties are no longer allowed")
+	}
+	for _, name := range slices.Sorted(maps.Keys(prev.properties)) {
+		child := at + "/" + jsonPointerEscape(name)
+		switch prop, ok := next.properties[name]; {
+		case ok:
+			c.compare(prev.properties[name], prop, child)
+		case next.additional != nil:
+			c.compare(prev.properties[name], next.additional, child)
+		case next.closed:
+			c.report(child, "property was removed and additional properties are not allowed")
+		}
+	}
+	if prev.additional != nil && next.additional != nil {
+		c.compare(prev.additional, next.additional, at+"/additionalProperties")
+	}
+	if prev.items != nil && next.items != nil {
+		c.compare(prev.items, next.items, at+"/items")
+	} else if next.items != nil {
+		c.compare(&jsonSchema{}, next.items, at+"/items")
+	}
+}
+
+// compareTypes 新版本须接受旧版本的全部类型，integer 放宽为 number 视为兼容
+func (c *jsonSchemaCompat) compareTypes(prev, next *jsonSchema, at string) {
+	if len(next.types) == 0 {
+		return
+	}
+	if len(prev.types) == 0 {
+		c.report(at, "type restricted to %s", strings.Join(next.types, ", "))
+		return
+	}
+	for _, t := range prev.types {
+		if !slices.Contains(next.types, t) && !(t == "integer" && slices.Contains(next.types, "number")) {
+			c.report(at, "type changed from %s to %s", strings.Join(prev.types, ", "), strings.Join(next.types, ", "))
+			return
+		}
+	}
+}
+
+// compareValues 枚举值只能增加，const 不能新增或改变
+func (c *jsonSchemaCompat) compareValues(prev, next *jsonSchema, at string) {
+	if len(next.enum) > 0 {
+		if len(prev.enum) == 0 {
+			c.report(at, "enum restriction added")
+		}
+		for _, v := range prev.enum {
+			if !slices.ContainsFunc(next.enum, func(e any) bool { return jsonEqual(e, v) }) {
+				c.report(at, "enum value %s was removed", jsonText(v))
+			}
+		}
+	}
+	if next.hasConst && (!prev.hasConst || !jsonEqual(prev.constVal, next.constVal)) {
+		c.report(at, "const changed to %s", jsonText(next.constVal))
+	}
+	if next.pattern != nil && (prev.pattern == nil || prev.pattern.String() != next.pattern.String()) {
+		c.report(at, "pattern changed to %s", next.pattern)
+	}
+	if next.format != "" && next.format != prev.format {
+		c.report(at, "format changed to %s", next.format)
+	}
+}
+
+// compareBounds 下界不能提高、上界不能降低
+func (c *jsonSchemaCompat) compareBounds(prev, next *jsonSchema, at string) {
+	lower := func(name string, o, n *float64) {
+		if n != nil && (o == nil || *n > *o) {
+			c.report(at, "%s tightened to %v", name, *n)
+		}
+	}
+	upper := func(name string, o, n *float64) {
+		if n != nil && (o == nil || *n < *o) {
+			c.report(at, "%s tightened to %v", name, *n)
+		}
+	}
+	lower("minimum", prev.minimum, next.minimum)
+	lower("exclusiveMinimum", prev.exclMin, next.exclMin)
+	upper("maximum", prev.maximum, next.maximum)
+	upper("exclusiveMaximum", prev.exclMax, next.exclMax)
+	lower("minLength", intFloat(prev.minLength), intFloat(next.minLength))
+	upper("maxLength", intFloat(prev.maxLength), intFloat(next.maxLength))
+	lower("minItems", intFloat(prev.minItems), intFloat(next.minItems))
+	upper("maxItems", intFloat(prev.maxItems), intFloat(next.maxItems))
+}
+
+// intFloat 计数关键字转为 float64 便于统一比较
+func intFloat(n *int) *float64 {
+	if n == nil {
+		return nil
+	}
+	f := float64(*n)
+	return &f
+}
@@ -0,0 +1,326 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\log_overrides.go
+ * @Description: 按路由覆盖日志级别与脱敏规则 - 如登录接口从不记录请求体、排查中的路由开启详细日志，
+ *               支持通过管理接口运行时增删，无需重启
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/desensitize"
+)
+
+// LogLevelOff 关闭该路由的请求日志
+const LogLevelOff = "off"
+
+// RouteLogOverride 单个路由的日志覆盖规则
+type RouteLogOverride struct {
+	Pattern       string    `json:"pattern"`                  // HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+	Methods       []string  `json:"methods,omitempty"`        // HTTP 方法，为空匹配全部（gRPC 忽略）
+	Level         string    `json:"level,omitempty"`          // 最低记录级别 debug/info/warn/error/off；debug 同时开启请求/响应体记录
+	RequestBody   *bool     `json:"request_body,omitempty"`   // 强制开启/关闭请求体记录，优先于 Level
+	ResponseBody  *bool     `json:"response_body,omitempty"`  // 强制开启/关闭响应体记录，优先于 Level
+	SensitiveKeys []string  `json:"sensitive_keys,omitempty"` // 在全局脱敏字段基础上追加的字段
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`     // 到期后自动失效（排查场景），零值永久有效
+
+	masker *desensitize.DataMasker
+}
+
+// RouteLogConfig 按路由日志覆盖配置
+type RouteLogConfig struct {
+	Overrides []*RouteLogOverride // 初始规则
+	AdminPath string              // 管理接口路径（如 /debug/log-overrides），为空不注册
+}
+
+// routeLogTable 按匹配优先级排序的规则表（精确匹配在前，前缀越长越优先）
+type routeLogTable struct {
+	overrides []*RouteLogOverride
+}
+
+var (
+	routeLogOverrides atomic.Pointer[routeLogTable]
+	routeLogMu        sync.Mutex                          // 串行化写操作
+	routeLogRoutes    = NewRouteSet("log-overrides", nil) // HTTP 路由匹配（共享路由表）
+)
+
+// SetRouteLogOverrides 替换全部按路由日志覆盖规则
+func SetRouteLogOverrides(overrides []*RouteLogOverride) error {
+	routeLogMu.Lock()
+	defer routeLogMu.Unlock()
+
+	prepared := make([]*RouteLogOverride, 0, len(overrides))
+	for _, ov := range overrides {
+		if ov == nil {
+			continue
+		}
+		if err := ov.prepare(); err != nil {
+			return err
+		}
+		prepared = append(prepared, ov)
+	}
+	storeRouteLogOverrides(prepared)
+	return nil
+}
+
+// UpsertRouteLogOverride 新增或替换（按 Pattern）一条规则
+func UpsertRouteLogOverride(override *RouteLogOverride) error {
+	if override == nil {
+		return errors.NewError(errors.ErrCodeInvalidParameter, "override is nil")
+	}
+	if err := override.prepare(); err != nil {
+		return err
+	}
+
+	routeLogMu.Lock()
+	defer routeLogMu.Unlock()
+
+	current := RouteLogOverrides()
+	next := make([]*RouteLogOverride, 0, len(current)+1)
+	for _, ov := range current {
+		if ov.Pattern != override.Pattern {
+			next = append(next, ov)
+		}
+	}
+	storeRouteLogOverrides(append(next, override))
+	global.LOGGER.InfoKV("路由日志覆盖规则已更新", "pattern", override.Pattern, "level", override.Level,
+		"expires_at", override.ExpiresAt)
+	return nil
+}
+
+// DeleteRouteLogOverride 删除规则，返回是否存在
+func DeleteRouteLogOverride(pattern string) bool {
+	routeLogMu.Lock()
+	defer routeLogMu.Unlock()
+
+	current := RouteLogOverrides()
+	next := make([]*RouteLogOverride, 0, len(current))
+	for _, ov := range current {
+		if ov.Pattern != pattern {
+			next = append(next, ov)
+		}
+	}
+	if len(next) == len(current) {
+		return false
+	}
+	storeRouteLogOverrides(next)
+	global.LOGGER.InfoKV("路由日志覆盖规则已删除", "pattern", pattern)
+	return true
+}
+
+// RouteLogOverrides 返回当前规则（含已过期未清理的规则）
+func RouteLogOverrides() []*RouteLogOverride {
+	table := routeLogOverrides.Load()
+	if table == nil {
+		return nil
+	}
+	return append([]*RouteLogOverride(nil), table.overrides...)
+}
+
+// storeRouteLogOverrides 按匹配优先级排序后发布
+func storeRouteLogOverrides(overrides []*RouteLogOverride) {
+	sort.SliceStable(overrides, func(i, j int) bool {
+		pi, pj := overrides[i].isPrefix(), overrides[j].isPrefix()
+		if pi != pj {
+			return !pi
+		}
+		return len(overrides[i].Pattern) > len(overrides[j].Pattern)
+	})
+	routeLogOverrides.Store(&routeLogTable{overrides: overrides})
+
+	patterns := make([]RoutePattern, len(overrides))
+	for i, ov := range overrides {
+		patterns[i] = RoutePattern{Kind: RouteMatchExact, Pattern: ov.Pattern, Methods: ov.Methods, Value: ov}
+		if ov.isPrefix() {
+			patterns[i].Kind = RouteMatchPrefix
+			patterns[i].Pattern = strings.TrimSuffix(ov.Pattern, "*")
+		}
+	}
+	routeLogRoutes.Update(patterns)
+}
+
+// prepare 校验规则并构建路由专属脱敏器
+func (ov *RouteLogOverride) prepare() error {
+	if ov.Pattern == "" {
+		return errors.NewError(errors.ErrCodeInvalidParameter, "override pattern is required")
+	}
+	ov.Level = strings.ToLower(ov.Level)
+	if _, ok := accessLogLevels[ov.Level]; !ok && ov.Level != "" && ov.Level != LogLevelOff {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "unsupported log level %q", ov.Level)
+	}
+	for i, m := range ov.Methods {
+		ov.Methods[i] = strings.ToUpper(m)
+	}
+
+	ov.masker = nil
+	if len(ov.SensitiveKeys) > 0 {
+		cfg := getLoggingConfig()
+		keys := append(append([]string(nil), cfg.SensitiveKeys...), ov.SensitiveKeys...)
+		ov.masker = desensitize.NewMasker(&desensitize.MaskerConfig{
+			SensitiveKeys: keys,
+			SensitiveMask: cfg.SensitiveMask,
+			MaxBodySize:   cfg.MaxBodySize,
+		})
+	}
+	return nil
+}
+
+// isPrefix 是否为前缀规则
+func (ov *RouteLogOverride) isPrefix() bool {
+	return strings.HasSuffix(ov.Pattern, "*")
+}
+
+// match 匹配路径与方法
+func (ov *RouteLogOverride) match(method, path string, now time.Time) bool {
+	if !ov.ExpiresAt.IsZero() && now.After(ov.ExpiresAt) {
+		return false
+	}
+	if ov.isPrefix() {
+		if !strings.HasPrefix(path, strings.TrimSuffix(ov.Pattern, "*")) {
+			return false
+		}
+	} else if path != ov.Pattern {
+		return false
+	}
+	if method == "" || len(ov.Methods) == 0 {
+		return true
+	}
+	for _, m := range ov.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRouteLogOverride 查找路由的覆盖规则，gRPC 调用 method 传空
+func matchRouteLogOverride(method, path string) *RouteLogOverride {
+	table := routeLogOverrides.Load()
+	if table == nil || len(table.overrides) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, ov := range table.overrides {
+		if ov.match(method, path, now) {
+			return ov
+		}
+	}
+	return nil
+}
+
+// matchHTTPRouteLogOverride 查找 HTTP 请求的覆盖规则，复用请求级路由分类结果
+// 命中的规则已过期时逐条查找次优规则
+func matchHTTPRouteLogOverride(r *http.Request) *RouteLogOverride {
+	value, ok := routeLogRoutes.Match(r)
+	if !ok {
+		return nil
+	}
+	if ov := value.(*RouteLogOverride); ov.ExpiresAt.IsZero() || !time.Now().After(ov.ExpiresAt) {
+		return ov
+	}
+	return matchRouteLogOverride(r.Method, r.URL.Path)
+}
+
+// captureRequestBody 是否记录请求体
+func (ov *RouteLogOverride) captureRequestBody(def bool) bool {
+	switch {
+	case ov == nil:
+		return def
+	case ov.RequestBody != nil:
+		return *ov.RequestBody
+	case ov.Level == constants.LogLevelDebug:
+		return true
+	}
+	return def
+}
+
+// captureResponseBody 是否记录响应体
+func (ov *RouteLogOverride) captureResponseBody(def bool) bool {
+	switch {
+	case ov == nil:
+		return def
+	case ov.ResponseBody != nil:
+		return *ov.ResponseBody
+	case ov.Level == constants.LogLevelDebug:
+		return true
+	}
+	return def
+}
+
+// allows 按覆盖级别判断是否记录
+func (ov *RouteLogOverride) allows(level string) bool {
+	if ov == nil || ov.Level == "" {
+		return true
+	}
+	if ov.Level == LogLevelOff {
+		return false
+	}
+	return accessLogLevels[level] >= accessLogLevels[ov.Level]
+}
+
+// dataMasker 路由专属脱敏器，未配置时使用全局脱敏器
+func (ov *RouteLogOverride) dataMasker() *desensitize.DataMasker {
+	if ov != nil && ov.masker != nil {
+		return ov.masker
+	}
+	return global.DATAMASKER
+}
+
+// RouteLogOverridesHandler 管理接口：GET 列出规则，PUT/POST 新增或替换（可带 ?ttl=30m），DELETE ?pattern= 删除
+func RouteLogOverridesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, RouteLogOverrides())
+
+		case http.MethodPut, http.MethodPost:
+			var override RouteLogOverride
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&override); err != nil {
+				response.WriteBadRequestResult(w, err.Error())
+				return
+			}
+			if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+				d, err := time.ParseDuration(ttl)
+				if err != nil || d <= 0 {
+					response.WriteBadRequestResult(w, "invalid ttl: "+ttl)
+					return
+				}
+				override.ExpiresAt = time.Now().Add(d)
+			}
+			if err := UpsertRouteLogOverride(&override); err != nil {
+				response.WriteBadRequestResult(w, err.Error())
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, &override)
+
+		case http.MethodDelete:
+			pattern := r.URL.Query().Get("pattern")
+			if !DeleteRouteLogOverride(pattern) {
+				response.WriteNotFoundResult(w, "override not found: "+pattern)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set(constants.HeaderAllow, "GET, PUT, POST, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
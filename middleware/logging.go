@@ -22,6 +22,7 @@ import (
 	"github.com/kamalyes/go-config/pkg/logging"
 	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
 	"github.com/kamalyes/go-toolbox/pkg/mathx"
 	"github.com/kamalyes/go-toolbox/pkg/netx"
 	"google.golang.org/grpc"
@@ -75,9 +76,11 @@ func (lf *LogFields) AddValue(key string, value any) *LogFields {
 // AddRequestContext 添加请求上下文信息
 func (lf *LogFields) AddRequestContext(ctx context.Context) *LogFields {
 	requestCommonMeta := GetRequestCommonMeta(ctx)
+	_, spanID, _ := global.CorrelationIDs(ctx)
 
 	return lf.
 		Add(constants.LogFieldTraceID, requestCommonMeta.TraceID).
+		Add(constants.LogFieldSpanID, spanID).
 		Add(constants.LogFieldRequestID, requestCommonMeta.RequestID).
 		Add(constants.LogFieldAuthorization, requestCommonMeta.Authorization).
 		Add(constants.LogFieldID, requestCommonMeta.ID).
@@ -119,6 +122,7 @@ func (rl *RequestLogger) Log(level string, message string, fields *LogFields) {
 	}
 
 	fieldList := fields.Build()
+	dispatchAccessLog(level, message, fieldList)
 
 	switch level {
 	case "info":
@@ -197,12 +201,13 @@ func LoggingMiddleware() HTTPMiddleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ctx := r.Context()
+			override := matchHTTPRouteLogOverride(r)
 
-			// 跳过路径检查
-			if isSkipPath(r.URL.Path) {
+			// 跳过路径检查（配置了路由覆盖规则时以覆盖规则为准）
+			if override == nil && isSkipPath(r.URL.Path) {
 				wrapped := NewResponseWriter(w)
 				next.ServeHTTP(wrapped, r)
-				if wrapped.StatusCode() >= 400 {
+				if wrapped.StatusCode() >= 400 || IsClientCanceled(ctx, nil) {
 					logHTTPError(ctx, r, wrapped, time.Since(start))
 				}
 				wrapped.Release()
@@ -210,23 +215,30 @@ func LoggingMiddleware() HTTPMiddleware {
 			}
 
 			// 捕获请求体
+			// 请求体读入池化缓冲区，日志记录完成后归还
 			var reqBody []byte
-			if shouldCaptureRequest() && r.Body != nil {
-				var err error
-				reqBody, err = io.ReadAll(r.Body)
+			if override.captureRequestBody(shouldCaptureRequest()) && r.Body != nil {
+				buf := response.AcquireBuffer()
+				defer response.ReleaseBuffer(buf)
+				_, err := buf.ReadFrom(r.Body)
+				reqBody = buf.Bytes()
 				if err != nil && global.LOGGER != nil {
 					global.LOGGER.ErrorContextKV(ctx, "❌ Failed to read request body",
 						"path", r.URL.Path,
 						"method", r.Method,
 						"error", err)
 				}
-				r.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+				r.Body = io.NopCloser(bytes.NewReader(reqBody))
 			}
 
 			// 包装响应
 			wrapped := NewResponseWriter(w)
-			if shouldCaptureResponse() {
-				wrapped.EnableBodyCapture()
+			if override.captureResponseBody(shouldCaptureResponse()) {
+				// streaming 路由不捕获响应体，buffered 路由按其上限截断
+				if limit, ok := ResponseBufferLimit(ctx, 0); ok {
+					wrapped.EnableBodyCaptureFor(isLoggableContentType)
+					wrapped.LimitBodyCapture(limit)
+				}
 			}
 			defer wrapped.Release()
 
@@ -234,20 +246,40 @@ func LoggingMiddleware() HTTPMiddleware {
 			next.ServeHTTP(wrapped, r)
 
 			// 记录日志
-			logHTTPRequest(ctx, r, wrapped, time.Since(start), config, reqBody)
+			logHTTPRequest(ctx, r, wrapped, time.Since(start), config, reqBody, override)
 		})
 	}
 }
 
 // logHTTPRequest 记录 HTTP 请求
-func logHTTPRequest(ctx context.Context, r *http.Request, rw *ResponseWriter, duration time.Duration, config *logging.Logging, reqBody []byte) {
+func logHTTPRequest(ctx context.Context, r *http.Request, rw *ResponseWriter, duration time.Duration, config *logging.Logging, reqBody []byte, override *RouteLogOverride) {
+	// 客户端已断开：按 499 记录为警告，不计为服务端错误
+	statusCode := rw.StatusCode()
+	canceled := IsClientCanceled(ctx, nil)
+	if canceled {
+		if suppressClientCancelLog() {
+			return
+		}
+		statusCode = StatusClientClosedRequest
+	}
+
+	level := constants.LogLevelInfo
+	if statusCode >= 500 {
+		level = constants.LogLevelError
+	} else if statusCode >= 400 {
+		level = constants.LogLevelWarn
+	}
+	if !override.allows(level) {
+		return
+	}
+
 	logger := NewRequestLogger(ctx)
-	masker := global.DATAMASKER
+	masker := override.dataMasker()
 
 	fields := NewLogFields().
 		Add(constants.LogFieldMethod, r.Method).
 		Add(constants.LogFieldPath, r.URL.Path).
-		AddValue(constants.LogFieldStatus, rw.StatusCode()).
+		AddValue(constants.LogFieldStatus, statusCode).
 		AddValue(constants.LogFieldBytes, rw.BytesWritten()).
 		AddValue(constants.LogFieldDuration, duration.Milliseconds()).
 		Add(constants.LogFieldIP, netx.GetClientIP(r)).
@@ -256,7 +288,7 @@ func logHTTPRequest(ctx context.Context, r *http.Request, rw *ResponseWriter, du
 		AddRequestContext(ctx)
 
 	// 请求参数
-	if config.EnableRequest && r.URL.RawQuery != "" {
+	if override.captureRequestBody(config.EnableRequest) && r.URL.RawQuery != "" {
 		fields.Add(constants.LogFieldQuery, r.URL.RawQuery)
 	}
 
@@ -270,15 +302,16 @@ func logHTTPRequest(ctx context.Context, r *http.Request, rw *ResponseWriter, du
 		fields.Add(constants.LogFieldResponse, masker.Mask(respBody))
 	}
 
-	level := constants.LogLevelInfo
-	message := "🚀 " + constants.LogMsgHTTPRequest
-	if rw.StatusCode() >= 500 {
-		level = constants.LogLevelError
+	var message string
+	switch {
+	case canceled:
+		fields.AddValue(constants.LogFieldClientCanceled, true)
+		message = "🔌 " + constants.LogMsgClientCanceled
+	case level == constants.LogLevelError:
 		message = "❌ " + constants.LogMsgHTTPRequest
-	} else if rw.StatusCode() >= 400 {
-		level = constants.LogLevelWarn
+	case level == constants.LogLevelWarn:
 		message = "⚠️ " + constants.LogMsgHTTPRequest
-	} else {
+	default:
 		message = "✅ " + constants.LogMsgHTTPRequest
 	}
 
@@ -287,12 +320,24 @@ func logHTTPRequest(ctx context.Context, r *http.Request, rw *ResponseWriter, du
 
 // logHTTPError 记录跳过路径的错误 🚫
 func logHTTPError(ctx context.Context, r *http.Request, rw *ResponseWriter, duration time.Duration) {
+	statusCode := rw.StatusCode()
+	canceled := IsClientCanceled(ctx, nil)
+	if canceled {
+		if suppressClientCancelLog() {
+			return
+		}
+		statusCode = StatusClientClosedRequest
+	}
+
 	logger := NewRequestLogger(ctx)
 	fields := NewLogFields().
 		Add(constants.LogFieldPath, r.URL.Path).
-		AddValue(constants.LogFieldStatus, rw.StatusCode()).
+		AddValue(constants.LogFieldStatus, statusCode).
 		AddValue(constants.LogFieldDuration, duration.Milliseconds()).
 		AddRequestContext(ctx)
+	if canceled {
+		fields.AddValue(constants.LogFieldClientCanceled, true)
+	}
 
 	logger.Log(constants.LogLevelWarn, "⚠️ "+constants.LogMsgHTTPRequestSkip, fields)
 }
@@ -327,9 +372,25 @@ func logGRPCUnary(ctx context.Context, method string, req, resp any, err error,
 		return
 	}
 
+	override := matchRouteLogOverride("", method)
+	canceled := err != nil && IsClientCanceled(ctx, err)
+	if canceled && suppressClientCancelLog() {
+		return
+	}
+	level := constants.LogLevelInfo
+	if canceled {
+		level = constants.LogLevelWarn
+	} else if err != nil {
+		level = constants.LogLevelError
+	}
+	if !override.allows(level) {
+		return
+	}
+
 	config := getLoggingConfig()
 	logger := NewRequestLogger(ctx)
-	masker := global.DATAMASKER
+	masker := override.dataMasker()
+	captureReq := override.captureRequestBody(shouldCaptureRequest())
 
 	fields := NewLogFields().
 		Add(constants.LogFieldMethod, method).
@@ -340,16 +401,21 @@ func logGRPCUnary(ctx context.Context, method string, req, resp any, err error,
 	if err != nil {
 		st, _ := status.FromError(err)
 		fields.Add(constants.LogFieldStatus, st.Code().String()).Add(constants.LogFieldError, st.Message())
-		if shouldCaptureRequest() && req != nil {
+		if captureReq && req != nil {
 			fields.Add(constants.LogFieldRequest, masker.Mask(marshalProto(req)))
 		}
+		if canceled {
+			fields.AddValue(constants.LogFieldClientCanceled, true)
+			logger.Log(level, "🔌 "+constants.LogMsgClientCanceled, fields)
+			return
+		}
 		logger.Log(constants.LogLevelError, "❌ "+constants.LogMsgGRPCRequestError, fields)
 	} else {
 		fields.Add(constants.LogFieldStatus, "OK")
-		if shouldCaptureRequest() && req != nil {
+		if captureReq && req != nil {
 			fields.Add(constants.LogFieldRequest, masker.Mask(marshalProto(req)))
 		}
-		if shouldCaptureResponse() && resp != nil {
+		if override.captureResponseBody(shouldCaptureResponse()) && resp != nil {
 			fields.Add(constants.LogFieldResponse, masker.Mask(marshalProto(resp)))
 		}
 		logger.Log(constants.LogLevelInfo, "✅ "+constants.LogMsgGRPCRequest, fields)
@@ -362,6 +428,20 @@ func logGRPCStream(ctx context.Context, info *grpc.StreamServerInfo, err error,
 		return
 	}
 
+	canceled := err != nil && IsClientCanceled(ctx, err)
+	if canceled && suppressClientCancelLog() {
+		return
+	}
+	level := constants.LogLevelInfo
+	if canceled {
+		level = constants.LogLevelWarn
+	} else if err != nil {
+		level = constants.LogLevelError
+	}
+	if !matchRouteLogOverride("", info.FullMethod).allows(level) {
+		return
+	}
+
 	config := getLoggingConfig()
 	logger := NewRequestLogger(ctx)
 	fields := NewLogFields().
@@ -375,6 +455,11 @@ func logGRPCStream(ctx context.Context, info *grpc.StreamServerInfo, err error,
 	if err != nil {
 		st, _ := status.FromError(err)
 		fields.Add(constants.LogFieldStatus, st.Code().String()).Add(constants.LogFieldError, st.Message())
+		if canceled {
+			fields.AddValue(constants.LogFieldClientCanceled, true)
+			logger.Log(level, "🔌 "+constants.LogMsgClientCanceled, fields)
+			return
+		}
 		logger.Log(constants.LogLevelError, "❌ "+constants.LogMsgGRPCStreamError, fields)
 	} else {
 		fields.Add(constants.LogFieldStatus, "OK")
@@ -11,7 +11,9 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
@@ -21,6 +23,7 @@ import (
 	swaggerMiddleware "github.com/kamalyes/go-swagger"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // Manager 中间件管理器 - 使用 go-config 的 middleware 配置
@@ -29,18 +32,38 @@ type Manager struct {
 	metricsManager         *MetricsManager
 	tracingManager         *TracingManager
 	rateLimiter            RateLimiter
+	rateLimiters           *rateLimiterSet  // HTTP 中间件与 gRPC 拦截器共用的限流器（按策略）
+	rateLimitShared        *rateLimitShared // 运行时设置的限流扩展，配置重载后新旧管理器共用
 	dynamicRateLimit       DynamicRateLimitProvider
 	dynamicSignature       DynamicSignatureProvider
 	i18nManager            *I18nManager
 	pbValidationMiddleware *PBValidationMiddleware
 	swaggerMiddleware      *swaggerMiddleware.Middleware
+	corsHandler            *corsHandler
+	corsOptions            *CORSOptions
+	recoveryHandler        RecoveryHandlerFunc
+	customMiddlewares      []ChainEntry // 通过 Use 添加的自定义中间件
+	pathLabels             *PathLabelConfig
+	pathRouteResolver      func(r *http.Request) string
+	stageTiming            *StageTimingConfig // 中间件分阶段计时
+}
+
+// rateLimitShared 运行时设置的限流扩展；单独分配，UpdateConfig 时交给新管理器，
+// 已创建的中间件持有其中的原子指针，重载后继续生效
+type rateLimitShared struct {
+	penalty atomic.Pointer[RateLimitPenalty]
+	costs   atomic.Pointer[RateLimitCosts]
+	stats   atomic.Pointer[RateLimitStats]
+	keys    atomic.Pointer[RateLimitKeys]
 }
 
 // NewManager 创建中间件管理器 - 使用全局 GATEWAY 配置
 func NewManager(cfg *gwconfig.Gateway) (*Manager, error) {
 	var err error
 	manager := &Manager{
-		cfg: cfg,
+		cfg:             cfg,
+		corsHandler:     newCORSHandler(cfg.CORS, nil),
+		rateLimitShared: &rateLimitShared{},
 	}
 
 	// 初始化监控管理器（使用 monitoring 配置）
@@ -86,14 +109,18 @@ func NewManager(cfg *gwconfig.Gateway) (*Manager, error) {
 		case ratelimit.StrategyTokenBucket:
 			manager.rateLimiter = NewTokenBucketLimiter(cfg.RateLimit)
 		case ratelimit.StrategySlidingWindow:
-			if global.REDIS != nil {
+			if global.STORE != nil {
+				// 滑动窗口基于状态存储实现（Redis 或内嵌存储）
 				manager.rateLimiter = NewSlidingWindowLimiter(cfg.RateLimit)
 			} else {
 				manager.rateLimiter = NewTokenBucketLimiter(cfg.RateLimit) // 降级到令牌桶
-				global.LOGGER.Warn("Redis不可用，限流器降级为令牌桶模式")
+				global.LOGGER.Warn("状态存储不可用，限流器降级为令牌桶模式")
 			}
 		case ratelimit.StrategyFixedWindow:
 			manager.rateLimiter = NewFixedWindowLimiter(cfg.RateLimit)
+		case ratelimit.StrategyLeakyBucket, StrategyGCRA:
+			// 漏桶与 GCRA 同样基于状态存储，不可用时降级到令牌桶
+			manager.rateLimiter = newRateLimiter(cfg.RateLimit, cfg.RateLimit.Strategy)
 		default:
 			manager.rateLimiter = NewTokenBucketLimiter(cfg.RateLimit)
 		}
@@ -105,6 +132,7 @@ func NewManager(cfg *gwconfig.Gateway) (*Manager, error) {
 		}
 		global.LOGGER.Info("限流器已初始化 [strategy=%s, rps=%d, burst=%d, enabled=%v]",
 			cfg.RateLimit.Strategy, rps, burst, true)
+		manager.rateLimiters = newRateLimiterSet(cfg.RateLimit, manager.rateLimiter)
 	}
 
 	return manager, nil
@@ -119,6 +147,12 @@ func (m *Manager) UpdateConfig(cfg *gwconfig.Gateway) error {
 
 	dynamicRateLimit := m.dynamicRateLimit
 	dynamicSignature := m.dynamicSignature
+	corsOptions := m.corsOptions
+	recoveryHandler := m.recoveryHandler
+	customMiddlewares := m.customMiddlewares
+	pathLabels := m.pathLabels
+	pathRouteResolver := m.pathRouteResolver
+	stageTiming := m.stageTiming
 
 	if m.swaggerMiddleware != nil && cfg != nil && cfg.Swagger != nil {
 		if err := m.swaggerMiddleware.UpdateConfig(cfg.Swagger); err != nil {
@@ -133,10 +167,53 @@ func (m *Manager) UpdateConfig(cfg *gwconfig.Gateway) error {
 
 	next.dynamicRateLimit = dynamicRateLimit
 	next.dynamicSignature = dynamicSignature
+	next.SetCORSOptions(corsOptions)
+	next.recoveryHandler = recoveryHandler
+	next.customMiddlewares = customMiddlewares
+	if pathLabels != nil {
+		if err := next.SetPathLabels(*pathLabels); err != nil {
+			return err
+		}
+	}
+	next.SetPathRouteResolver(pathRouteResolver)
+	next.stageTiming = stageTiming
+	next.rateLimitShared = m.rateLimitShared
 	*m = *next
 	return nil
 }
 
+// SetPathLabels 设置指标路径标签规则（模板、代理前缀映射与基数上限），配置重载后保留
+func (m *Manager) SetPathLabels(cfg PathLabelConfig) error {
+	if labeler := m.metricsManager.PathLabeler(); labeler != nil {
+		if err := labeler.Configure(cfg); err != nil {
+			return err
+		}
+	}
+	m.pathLabels = &cfg
+	return nil
+}
+
+// SetPathRouteResolver 设置指标路径标签的路由模板解析函数，配置重载后保留
+func (m *Manager) SetPathRouteResolver(fn func(r *http.Request) string) {
+	m.pathRouteResolver = fn
+	if labeler := m.metricsManager.PathLabeler(); labeler != nil {
+		labeler.SetRouteResolver(fn)
+	}
+}
+
+// SetStageTiming 设置中间件分阶段计时，cfg 为空时关闭，配置重载后保留；需重新编译中间件链后生效
+func (m *Manager) SetStageTiming(cfg *StageTimingConfig) error {
+	if cfg != nil {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		copied := *cfg
+		cfg = &copied
+	}
+	m.stageTiming = cfg
+	return nil
+}
+
 // HTTPMetricsMiddleware HTTP 监控中间件
 func (m *Manager) HTTPMetricsMiddleware() MiddlewareFunc {
 	return HTTPMetricsMiddleware(m.metricsManager)
@@ -175,14 +252,32 @@ func (m *Manager) GRPCGatewayStructTagValidatorMiddleware() runtime.Middleware {
 	return StructTagValidatorGatewayMiddleware()
 }
 
+// GRPCGatewayPathPatternMiddleware grpc-gateway 路由模板回填中间件（指标路径标签自动识别）
+func (m *Manager) GRPCGatewayPathPatternMiddleware() runtime.Middleware {
+	return PathPatternCaptureMiddleware()
+}
+
 // CORSMiddleware CORS 中间件
 func (m *Manager) CORSMiddleware() MiddlewareFunc {
-	return MiddlewareFunc(CORSMiddleware(m.cfg.CORS))
+	return MiddlewareFunc(m.corsHandler.middleware)
+}
+
+// SetCORSOptions 设置 CORS 路由组覆盖与演练模式，对已构建的中间件链立即生效
+func (m *Manager) SetCORSOptions(opts *CORSOptions) {
+	m.corsOptions = opts
+	m.corsHandler.update(m.cfg.CORS, opts)
 }
 
 // RecoveryMiddleware 恢复中间件
 func (m *Manager) RecoveryMiddleware() MiddlewareFunc {
-	return MiddlewareFunc(RecoveryMiddleware(m.cfg.Middleware.Recovery))
+	return MiddlewareFunc(RecoveryMiddlewareWithHandler(m.cfg.Middleware.Recovery, func() RecoveryHandlerFunc {
+		return m.recoveryHandler
+	}))
+}
+
+// SetRecoveryHandler 设置自定义 panic 恢复处理器（500 响应），nil 恢复默认
+func (m *Manager) SetRecoveryHandler(handler RecoveryHandlerFunc) {
+	m.recoveryHandler = handler
 }
 
 // RequestContextMiddlewareFunc 统一的请求上下文中间件
@@ -198,7 +293,47 @@ func (m *Manager) SCPMiddleware() MiddlewareFunc {
 
 // RateLimitMiddleware 限流中间件
 func (m *Manager) RateLimitMiddleware() MiddlewareFunc {
-	return MiddlewareFunc(newRateLimitMiddleware(m.cfg.RateLimit, m.rateLimiter, m.dynamicRateLimit).Middleware())
+	return MiddlewareFunc(m.rateLimitHandler().Middleware())
+}
+
+// GRPCRateLimitUnaryInterceptor gRPC 一元调用限流拦截器，未启用限流时返回 nil
+func (m *Manager) GRPCRateLimitUnaryInterceptor() grpc.UnaryServerInterceptor {
+	if !m.cfg.RateLimit.Enabled || m.rateLimiter == nil {
+		return nil
+	}
+	return m.rateLimitHandler().UnaryServerInterceptor()
+}
+
+// GRPCRateLimitStreamInterceptor gRPC 流式调用限流拦截器，未启用限流时返回 nil
+func (m *Manager) GRPCRateLimitStreamInterceptor() grpc.StreamServerInterceptor {
+	if !m.cfg.RateLimit.Enabled || m.rateLimiter == nil {
+		return nil
+	}
+	return m.rateLimitHandler().StreamServerInterceptor()
+}
+
+// GRPCGatewayRateLimitAnnotator grpc-gateway metadata 注解，标记已在 HTTP 层限流的转发调用，未启用限流时返回 nil
+func (m *Manager) GRPCGatewayRateLimitAnnotator() func(context.Context, *http.Request) metadata.MD {
+	if !m.cfg.RateLimit.Enabled || m.rateLimiter == nil {
+		return nil
+	}
+	return GatewayLimitedAnnotator
+}
+
+// rateLimitHandler 创建限流处理器，共用同一组限流器，HTTP 与 gRPC 的计数互通
+func (m *Manager) rateLimitHandler() *rateLimitMiddleware {
+	e := newRateLimitMiddleware(m.cfg.RateLimit, m.rateLimiter, m.dynamicRateLimit)
+	if m.rateLimiters != nil {
+		e.limiters = m.rateLimiters
+	}
+	e.penalty = &m.rateLimitShared.penalty
+	e.costs = &m.rateLimitShared.costs
+	e.stats = &m.rateLimitShared.stats
+	e.keys = &m.rateLimitShared.keys
+	if labeler := m.metricsManager.PathLabeler(); labeler != nil {
+		e.pathLabel = labeler.Label
+	}
+	return e
 }
 
 // LoggingMiddleware HTTP日志中间件
@@ -221,6 +356,36 @@ func (m *Manager) SetDynamicRateLimitProvider(provider DynamicRateLimitProvider)
 	m.dynamicRateLimit = provider
 }
 
+// SetRateLimitPenalty 设置限流升级处罚，nil 关闭；已创建的 HTTP 中间件与 gRPC 拦截器立即生效
+func (m *Manager) SetRateLimitPenalty(penalty *RateLimitPenalty) {
+	m.rateLimitShared.penalty.Store(penalty)
+}
+
+// RateLimitPenalty 当前生效的限流升级处罚，未启用时返回 nil
+func (m *Manager) RateLimitPenalty() *RateLimitPenalty {
+	return m.rateLimitShared.penalty.Load()
+}
+
+// SetRateLimitCosts 设置限流请求权重，nil 时每个请求消耗 1 个额度
+func (m *Manager) SetRateLimitCosts(costs *RateLimitCosts) {
+	m.rateLimitShared.costs.Store(costs)
+}
+
+// SetRateLimitStats 设置限流状态跟踪器，nil 关闭；已创建的 HTTP 中间件与 gRPC 拦截器立即生效
+func (m *Manager) SetRateLimitStats(stats *RateLimitStats) {
+	m.rateLimitShared.stats.Store(stats)
+}
+
+// RateLimitStats 当前生效的限流状态跟踪器，未启用时返回 nil
+func (m *Manager) RateLimitStats() *RateLimitStats {
+	return m.rateLimitShared.stats.Load()
+}
+
+// SetRateLimitKeys 设置限流 key 模板，nil 时按 IP / 用户生成 key
+func (m *Manager) SetRateLimitKeys(keys *RateLimitKeys) {
+	m.rateLimitShared.keys.Store(keys)
+}
+
 // TimestampMiddleware 时间戳验证中间件
 func (m *Manager) TimestampMiddleware() MiddlewareFunc {
 	return MiddlewareFunc(TimestampMiddleware(m.cfg.Middleware.Signature))
@@ -289,70 +454,88 @@ func (m *Manager) GetSwaggerPaths() []string {
 	return m.swaggerMiddleware.GetSwaggerPaths()
 }
 
-// GetMiddlewares 获取中间件链（完全基于配置驱动）
-func (m *Manager) GetMiddlewares() []MiddlewareFunc {
-	var middlewares []MiddlewareFunc
+// Use 添加自定义中间件，按 priority 与内置中间件统一排序（见 Priority* 常量），配置更新后保留
+// 已编译的中间件链需重新编译后生效（Server.UseMiddleware 会自动完成）
+func (m *Manager) Use(name string, priority int, mw MiddlewareFunc) {
+	if mw == nil {
+		return
+	}
+	m.customMiddlewares = append(m.customMiddlewares, ChainEntry{Name: name, Priority: priority, Middleware: mw})
+}
 
-	// 1. Recovery 中间件（始终启用，最先执行）
-	middlewares = append(middlewares, m.RecoveryMiddleware())
+// Chain 按配置构建中间件链（内置中间件 + 自定义中间件）
+func (m *Manager) Chain() *Chain {
+	chain := NewChain()
+	builtin := func(name string, priority int, mw MiddlewareFunc) {
+		chain.add(ChainEntry{Name: name, Priority: priority, Builtin: true, Middleware: mw})
+	}
+
+	// Recovery 中间件（始终启用，最先执行）
+	builtin(MiddlewareRecovery, PriorityRecovery, m.RecoveryMiddleware())
+
+	// 链路追踪中间件（根据配置，置于 Context/日志/监控之前，使其能关联 trace_id/span_id 与 Exemplar）
+	if m.cfg.Middleware.Tracing.Enabled && m.tracingManager != nil {
+		builtin(MiddlewareTracing, PriorityTracing, m.HTTPTracingMiddleware())
+	}
 
-	// 2. Context 追踪中间件（始终启用）
-	middlewares = append(middlewares, m.RequestContextMiddlewareFunc())
+	// Context 追踪中间件（始终启用）
+	builtin(MiddlewareRequestContext, PriorityRequestContext, m.RequestContextMiddlewareFunc())
 
-	// 3. 日志中间件（根据配置）
+	// 日志中间件（根据配置）
 	if m.cfg.Middleware.Logging.Enabled {
-		middlewares = append(middlewares, m.LoggingMiddleware())
+		builtin(MiddlewareLogging, PriorityLogging, m.LoggingMiddleware())
 	}
 
-	// 4. 国际化中间件（根据配置）
+	// 国际化中间件（根据配置）
 	if m.cfg.Middleware.I18N.Enabled {
-		middlewares = append(middlewares, m.I18nMiddleware())
+		builtin(MiddlewareI18n, PriorityI18n, m.I18nMiddleware())
 	}
 
-	// 5. 监控中间件（根据配置）
+	// 监控中间件（根据配置）
 	if m.cfg.Monitoring.Metrics.Enabled && m.metricsManager != nil {
-		middlewares = append(middlewares, m.HTTPMetricsMiddleware())
+		builtin(MiddlewareMetrics, PriorityMetrics, m.HTTPMetricsMiddleware())
 	}
 
-	// 6. 链路追踪中间件（根据配置）
-	if m.cfg.Middleware.Tracing.Enabled && m.tracingManager != nil {
-		middlewares = append(middlewares, m.HTTPTracingMiddleware())
-	}
-
-	// 7. 限流中间件（根据配置）
+	// 限流中间件（根据配置）
 	if m.cfg.RateLimit.Enabled && m.rateLimiter != nil {
-		middlewares = append(middlewares, m.RateLimitMiddleware())
+		builtin(MiddlewareRateLimit, PriorityRateLimit, m.RateLimitMiddleware())
 	}
 
-	// 8. 熔断中间件（根据配置）
+	// 熔断中间件（根据配置）
 	if m.cfg.Middleware.CircuitBreaker.Enabled {
-		middlewares = append(middlewares, m.BreakerMiddleware())
+		builtin(MiddlewareBreaker, PriorityBreaker, m.BreakerMiddleware())
 	}
 
-	// 9. 安全中间件（根据配置）
+	// 安全中间件（根据配置）
 	if m.cfg.Security.CSP.Enabled {
-		middlewares = append(middlewares, m.SCPMiddleware())
+		builtin(MiddlewareCSP, PrioritySecurity, m.SCPMiddleware())
 	}
 
-	// 10. CORS 中间件（根据配置）
-	if m.cfg.CORS.Enabled {
-		middlewares = append(middlewares, m.CORSMiddleware())
-	}
+	// CORS 中间件（全局配置未启用时仍可由路由组覆盖启用）
+	builtin(MiddlewareCORS, PriorityCORS, m.CORSMiddleware())
 
-	// 11. 签名验证中间件
+	// 签名验证中间件
 	if m.cfg.Middleware.Signature.Enabled {
-		middlewares = append(middlewares, m.TimestampMiddleware())
-		middlewares = append(middlewares, m.NonceMiddleware())
-		middlewares = append(middlewares, m.SignatureMiddleware())
+		// 签名 URL 校验通过的请求（临时公开访问）不再要求请求签名
+		builtin(MiddlewareTimestamp, PrioritySignature, SkipIfSignedURL(m.TimestampMiddleware()))
+		builtin(MiddlewareNonce, PrioritySignature, SkipIfSignedURL(m.NonceMiddleware()))
+		builtin(MiddlewareSignature, PrioritySignature, SkipIfSignedURL(m.SignatureMiddleware()))
 	}
 
-	return middlewares
+	for _, e := range m.customMiddlewares {
+		chain.add(e)
+	}
+	return chain.WithStageTiming(m.stageTiming)
+}
+
+// GetMiddlewares 获取中间件链（完全基于配置驱动，按执行顺序）
+func (m *Manager) GetMiddlewares() []MiddlewareFunc {
+	return m.Chain().Middlewares()
 }
 
 // HTTPMiddleware 应用HTTP中间件链
 func (m *Manager) HTTPMiddleware(handler http.Handler) http.Handler {
-	middlewares := m.GetMiddlewares()
-	return ApplyMiddlewares(handler, middlewares...)
+	return m.Chain().Then(handler)
 }
 
 // UnaryServerInterceptor 返回gRPC一元拦截器
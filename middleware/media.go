@@ -0,0 +1,523 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\media.go
+ * @Description: 媒体处理路由 - /media/{bucket}/{key}?w=200&h=200&fmt=png 从 MinIO 读取图片按参数变换后返回，
+ *               变换结果写入按 LRU 淘汰的磁盘缓存，ETag 由源对象 ETag 与规范化参数派生，同一源对象的并发变换数受限
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 媒体路由默认值
+const (
+	DefaultMediaPath                 = "/media/"
+	DefaultMediaCacheMaxBytes        = 1 << 30
+	DefaultMediaMaxDimension         = 4096
+	DefaultMediaMaxSourceBytes       = 32 << 20
+	DefaultMediaMaxSourcePixels      = 50_000_000
+	DefaultMediaQuality              = 85
+	DefaultMediaConcurrencyPerObject = 2
+	DefaultMediaCacheControl         = "public, max-age=86400"
+)
+
+// MediaConfig 媒体处理路由配置
+type MediaConfig struct {
+	Path                 string   // 路由前缀，默认 /media/，请求路径为 {Path}{bucket}/{key}
+	Buckets              []string // 允许访问的桶，必填，其余桶返回 404
+	CacheDir             string   // 变换结果缓存目录，默认 os.TempDir()/gateway-media
+	CacheMaxBytes        int64    // 缓存总大小上限，默认 1GiB，超出按最近最少使用淘汰
+	MaxWidth             int      // 输出宽度上限，默认 4096
+	MaxHeight            int      // 输出高度上限，默认 4096
+	MaxSourceBytes       int64    // 源对象大小上限，默认 32MiB
+	MaxSourcePixels      int      // 源图片像素上限（宽×高），默认 5000 万，解码前按图片头判断
+	Quality              int      // 默认 JPEG 质量，默认 85
+	ConcurrencyPerObject int      // 同一源对象同时进行的变换数，默认 2，超出的请求排队
+	CacheControl         string   // 响应 Cache-Control，默认 public, max-age=86400
+}
+
+// MediaServer 媒体处理路由
+type MediaServer struct {
+	config  MediaConfig
+	buckets map[string]struct{}
+	source  mediaSource
+	cache   *mediaDiskCache
+	limiter *mediaObjectLimiter
+}
+
+// mediaSource 源对象读取
+type mediaSource interface {
+	// stat 返回源对象 ETag、大小与 Content-Type，不存在时返回 errMediaNotFound
+	stat(ctx context.Context, bucket, key string) (etag string, size int64, contentType string, err error)
+	// open 读取指定 ETag 的源对象
+	open(ctx context.Context, bucket, key, etag string) (io.ReadCloser, error)
+}
+
+// errMediaNotFound 源对象不存在
+var errMediaNotFound = fmt.Errorf("media object not found")
+
+// 媒体路由指标（注册到默认 Registry）
+var (
+	mediaRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_media_requests_total",
+		Help: "Total number of media route requests by result",
+	}, []string{"result"})
+
+	mediaTransformDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_media_transform_duration_seconds",
+		Help:    "Latency of fetching, transforming and encoding media objects on cache miss",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+)
+
+// NewMediaServer 校验配置、补齐默认值并加载磁盘缓存索引
+func NewMediaServer(cfg MediaConfig) (*MediaServer, error) {
+	if len(cfg.Buckets) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "media buckets are required")
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultMediaPath
+	}
+	if !strings.HasSuffix(cfg.Path, "/") {
+		cfg.Path += "/"
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = filepath.Join(os.TempDir(), "gateway-media")
+	}
+	if cfg.CacheMaxBytes <= 0 {
+		cfg.CacheMaxBytes = DefaultMediaCacheMaxBytes
+	}
+	if cfg.MaxWidth <= 0 {
+		cfg.MaxWidth = DefaultMediaMaxDimension
+	}
+	if cfg.MaxHeight <= 0 {
+		cfg.MaxHeight = DefaultMediaMaxDimension
+	}
+	if cfg.MaxSourceBytes <= 0 {
+		cfg.MaxSourceBytes = DefaultMediaMaxSourceBytes
+	}
+	if cfg.MaxSourcePixels <= 0 {
+		cfg.MaxSourcePixels = DefaultMediaMaxSourcePixels
+	}
+	if cfg.Quality <= 0 || cfg.Quality > 100 {
+		cfg.Quality = DefaultMediaQuality
+	}
+	if cfg.ConcurrencyPerObject <= 0 {
+		cfg.ConcurrencyPerObject = DefaultMediaConcurrencyPerObject
+	}
+	if cfg.CacheControl == "" {
+		cfg.CacheControl = DefaultMediaCacheControl
+	}
+
+	cache, err := newMediaDiskCache(cfg.CacheDir, cfg.CacheMaxBytes)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "media cache dir %s: %v", cfg.CacheDir, err)
+	}
+	m := &MediaServer{
+		config:  cfg,
+		buckets: make(map[string]struct{}, len(cfg.Buckets)),
+		source:  minioMediaSource{},
+		cache:   cache,
+		limiter: &mediaObjectLimiter{limit: cfg.ConcurrencyPerObject, objects: make(map[string]*mediaObjectSlot)},
+	}
+	for _, b := range cfg.Buckets {
+		m.buckets[b] = struct{}{}
+	}
+	return m, nil
+}
+
+// Path 路由前缀
+func (m *MediaServer) Path() string {
+	return m.config.Path
+}
+
+// ServeHTTP 处理 GET / HEAD 请求
+func (m *MediaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "media supports GET and HEAD only")
+		return
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, m.config.Path), "/")
+	if _, allowed := m.buckets[bucket]; !ok || !allowed || key == "" {
+		response.WriteNotFoundResult(w, "media object not found")
+		return
+	}
+	opts, err := parseMediaOptions(r.URL.Query(), m.config.MaxWidth, m.config.MaxHeight, m.config.Quality)
+	if err != nil {
+		mediaRequestsTotal.WithLabelValues("bad_request").Inc()
+		response.WriteBadRequestResult(w, err.Error())
+		return
+	}
+
+	srcETag, size, contentType, err := m.source.stat(r.Context(), bucket, key)
+	switch {
+	case err == errMediaNotFound:
+		mediaRequestsTotal.WithLabelValues("not_found").Inc()
+		response.WriteNotFoundResult(w, "media object not found")
+		return
+	case err != nil:
+		mediaRequestsTotal.WithLabelValues("error").Inc()
+		global.LOGGER.WarnKV("媒体源对象读取失败", "bucket", bucket, "key", key, "error", err)
+		response.WriteServiceUnavailableResult(w, "media storage is unavailable")
+		return
+	case size > m.config.MaxSourceBytes:
+		mediaRequestsTotal.WithLabelValues("too_large").Inc()
+		response.WriteErrorResponse(w, errors.ErrRequestTooLarge)
+		return
+	}
+	if opts.Format == "" {
+		opts.Format = mediaFormatFromContentType(contentType)
+		if opts.Format == "" {
+			opts.Format = "jpeg"
+		}
+	}
+	enc, _ := lookupMediaEncoder(opts.Format)
+
+	sum := sha256.Sum256([]byte(bucket + "/" + key + "\n" + srcETag + "\n" + opts.canonical()))
+	variant := hex.EncodeToString(sum[:])
+	etag := `"m-` + variant[:32] + `"`
+	if mediaETagMatch(r.Header.Get(constants.HeaderIfNoneMatch), etag) {
+		mediaRequestsTotal.WithLabelValues("not_modified").Inc()
+		w.Header().Set(constants.HeaderETag, etag)
+		w.Header().Set(constants.HeaderCacheControl, m.config.CacheControl)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	name := variant + "." + opts.Format
+	data, hit := m.cache.get(name)
+	if !hit {
+		data, hit, err = m.render(r.Context(), bucket, key, srcETag, name, opts, enc)
+	}
+	if err != nil {
+		mediaRequestsTotal.WithLabelValues("error").Inc()
+		if appErr, ok := err.(*errors.AppError); ok {
+			response.WriteErrorResponse(w, appErr)
+			return
+		}
+		global.LOGGER.WarnKV("媒体变换失败", "bucket", bucket, "key", key, "options", opts.canonical(), "error", err)
+		response.WriteServiceUnavailableResult(w, "media transform failed")
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	mediaRequestsTotal.WithLabelValues(result).Inc()
+
+	w.Header().Set(constants.HeaderETag, etag)
+	w.Header().Set(constants.HeaderCacheControl, m.config.CacheControl)
+	w.Header().Set(constants.HeaderContentType, enc.contentType)
+	w.Header().Set(constants.HeaderXMediaCache, strings.ToUpper(result))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// render 在同一源对象的并发限制内读取、变换并写入缓存；排队期间其他请求已生成同一变体时直接复用
+func (m *MediaServer) render(ctx context.Context, bucket, key, srcETag, name string, opts *mediaOptions, enc mediaEncoder) ([]byte, bool, error) {
+	release, err := m.limiter.acquire(ctx, bucket+"/"+key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+	if data, hit := m.cache.get(name); hit {
+		return data, true, nil
+	}
+
+	start := time.Now()
+	defer func() { mediaTransformDuration.Observe(time.Since(start).Seconds()) }()
+
+	body, err := m.source.open(ctx, bucket, key, srcETag)
+	if err != nil {
+		return nil, false, err
+	}
+	defer body.Close()
+	raw, err := io.ReadAll(io.LimitReader(body, m.config.MaxSourceBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(raw)) > m.config.MaxSourceBytes {
+		return nil, false, errors.ErrRequestTooLarge
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false, errors.NewErrorf(errors.ErrCodeInvalidContentType, "media object is not a supported image: %v", err)
+	}
+	if cfg.Width*cfg.Height > m.config.MaxSourcePixels {
+		return nil, false, errors.NewErrorf(errors.ErrCodeRequestTooLarge, "media object is %dx%d, exceeds %d pixels", cfg.Width, cfg.Height, m.config.MaxSourcePixels)
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false, errors.NewErrorf(errors.ErrCodeInvalidContentType, "media object is not a supported image: %v", err)
+	}
+	img, err := transformImage(src, opts)
+	if err != nil {
+		return nil, false, errors.NewError(errors.ErrCodeBadRequest, err.Error())
+	}
+
+	var out bytes.Buffer
+	if err := enc.encode(&out, img, opts.Quality); err != nil {
+		return nil, false, err
+	}
+	if err := m.cache.put(name, out.Bytes()); err != nil {
+		global.LOGGER.WarnKV("媒体缓存写入失败", "name", name, "error", err)
+	}
+	return out.Bytes(), false, nil
+}
+
+// mediaETagMatch 判断 If-None-Match 是否包含 etag（弱比较）
+func mediaETagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// minioMediaSource 从全局 MinIO 连接读取
+type minioMediaSource struct{}
+
+// stat 查询对象元数据
+func (minioMediaSource) stat(ctx context.Context, bucket, key string) (string, int64, string, error) {
+	client := global.GetMinIO()
+	if client == nil {
+		return "", 0, "", fmt.Errorf("minio client is not initialized")
+	}
+	info, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return "", 0, "", errMediaNotFound
+		}
+		return "", 0, "", err
+	}
+	return info.ETag, info.Size, info.ContentType, nil
+}
+
+// open 读取对象，要求 ETag 与 stat 一致，避免读到变换期间被覆盖的新版本
+func (minioMediaSource) open(ctx context.Context, bucket, key, etag string) (io.ReadCloser, error) {
+	client := global.GetMinIO()
+	if client == nil {
+		return nil, fmt.Errorf("minio client is not initialized")
+	}
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetMatchETag(etag); err != nil {
+		return nil, err
+	}
+	return client.GetObject(ctx, bucket, key, opts)
+}
+
+// mediaObjectLimiter 按源对象限制并发变换数
+type mediaObjectLimiter struct {
+	limit   int
+	mu      sync.Mutex
+	objects map[string]*mediaObjectSlot
+}
+
+// mediaObjectSlot 单个源对象的信号量，无人使用时删除
+type mediaObjectSlot struct {
+	sem  chan struct{}
+	refs int
+}
+
+// acquire 获取名额，ctx 结束时放弃等待
+func (l *mediaObjectLimiter) acquire(ctx context.Context, object string) (func(), error) {
+	l.mu.Lock()
+	slot, ok := l.objects[object]
+	if !ok {
+		slot = &mediaObjectSlot{sem: make(chan struct{}, l.limit)}
+		l.objects[object] = slot
+	}
+	slot.refs++
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		if slot.refs--; slot.refs == 0 {
+			delete(l.objects, object)
+		}
+		l.mu.Unlock()
+	}
+	select {
+	case slot.sem <- struct{}{}:
+		return func() { <-slot.sem; done() }, nil
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+}
+
+// mediaDiskCache 磁盘缓存，索引在内存中按最近使用排序，启动时按文件修改时间恢复
+type mediaDiskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	lru   *list.List // 元素为 *mediaCacheEntry，队首最近使用
+	index map[string]*list.Element
+	size  int64
+}
+
+// mediaCacheEntry 缓存文件
+type mediaCacheEntry struct {
+	name string
+	size int64
+}
+
+// newMediaDiskCache 创建缓存目录并加载已有文件
+func newMediaDiskCache(dir string, maxBytes int64) (*mediaDiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &mediaDiskCache{dir: dir, maxBytes: maxBytes, lru: list.New(), index: make(map[string]*list.Element)}
+
+	type existing struct {
+		entry   *mediaCacheEntry
+		modTime time.Time
+	}
+	var files []existing
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, existing{entry: &mediaCacheEntry{name: d.Name(), size: info.Size()}, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		c.index[f.entry.name] = c.lru.PushBack(f.entry)
+		c.size += f.entry.size
+	}
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// path 缓存文件路径，按名称前两位分目录
+func (c *mediaDiskCache) path(name string) string {
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// get 读取缓存，命中时移到队首并更新修改时间
+func (c *mediaDiskCache) get(name string) ([]byte, bool) {
+	c.mu.Lock()
+	elem, ok := c.index[name]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(name))
+	if err != nil {
+		c.remove(name)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(c.path(name), now, now)
+	return data, true
+}
+
+// put 先写临时文件再重命名，避免并发读到写了一半的文件
+func (c *mediaDiskCache) put(name string, data []byte) error {
+	path := c.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[name]; ok {
+		c.size -= elem.Value.(*mediaCacheEntry).size
+		c.lru.Remove(elem)
+	}
+	c.index[name] = c.lru.PushFront(&mediaCacheEntry{name: name, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evictLocked()
+	return nil
+}
+
+// remove 删除索引与文件
+func (c *mediaDiskCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[name]; ok {
+		c.size -= elem.Value.(*mediaCacheEntry).size
+		c.lru.Remove(elem)
+		delete(c.index, name)
+	}
+	_ = os.Remove(c.path(name))
+}
+
+// evictLocked 超出上限时从队尾淘汰，调用方需持有锁
+func (c *mediaDiskCache) evictLocked() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		elem := c.lru.Back()
+		entry := elem.Value.(*mediaCacheEntry)
+		c.lru.Remove(elem)
+		delete(c.index, entry.name)
+		c.size -= entry.size
+		_ = os.Remove(c.path(entry.name))
+	}
+}
@@ -0,0 +1,319 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\media_transform.go
+ * @Description: 媒体变换 - 解析 w/h/fit/crop/fmt/q 参数，裁剪、缩放（可分离三角滤波）并按目标格式编码；
+ *               内置 jpeg / png / gif 编码器，其他格式（如 webp）通过 RegisterMediaEncoder 接入
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 缩放方式
+const (
+	MediaFitContain = "contain" // 等比缩放到框内（默认）
+	MediaFitCover   = "cover"   // 等比缩放铺满框后居中裁剪
+	MediaFitFill    = "fill"    // 拉伸到指定宽高
+)
+
+// MediaEncoder 把图片编码为目标格式，quality 为 1-100，不支持质量参数的格式忽略
+type MediaEncoder func(w io.Writer, img image.Image, quality int) error
+
+// mediaEncoder 已注册的编码器
+type mediaEncoder struct {
+	contentType string
+	encode      MediaEncoder
+}
+
+// mediaEncoders 格式名 -> 编码器
+var (
+	mediaEncodersMu sync.RWMutex
+	mediaEncoders   = map[string]mediaEncoder{
+		"jpeg": {contentType: "image/jpeg", encode: func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, flattenAlpha(img), &jpeg.Options{Quality: quality})
+		}},
+		"png": {contentType: "image/png", encode: func(w io.Writer, img image.Image, _ int) error {
+			return png.Encode(w, img)
+		}},
+		"gif": {contentType: "image/gif", encode: func(w io.Writer, img image.Image, _ int) error {
+			return gif.Encode(w, img, nil)
+		}},
+	}
+)
+
+// RegisterMediaEncoder 注册输出格式，如接入 webp 编码库：
+//
+//	middleware.RegisterMediaEncoder("webp", "image/webp", func(w io.Writer, img image.Image, q int) error {
+//		return webp.Encode(w, img, &webp.Options{Quality: float32(q)})
+//	})
+//
+// 解码由 image 包负责，新的源格式通过匿名导入对应解码器（如 golang.org/x/image/webp）注册
+func RegisterMediaEncoder(format, contentType string, encode MediaEncoder) {
+	mediaEncodersMu.Lock()
+	defer mediaEncodersMu.Unlock()
+	mediaEncoders[strings.ToLower(format)] = mediaEncoder{contentType: contentType, encode: encode}
+}
+
+// lookupMediaEncoder 查找编码器
+func lookupMediaEncoder(format string) (mediaEncoder, bool) {
+	mediaEncodersMu.RLock()
+	defer mediaEncodersMu.RUnlock()
+	enc, ok := mediaEncoders[format]
+	return enc, ok
+}
+
+// mediaFormats 已注册的格式，用于错误提示
+func mediaFormats() string {
+	mediaEncodersMu.RLock()
+	defer mediaEncodersMu.RUnlock()
+	formats := make([]string, 0, len(mediaEncoders))
+	for f := range mediaEncoders {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return strings.Join(formats, ", ")
+}
+
+// mediaFormatFromContentType 源对象 Content-Type 对应的输出格式，无对应编码器时为空
+func mediaFormatFromContentType(contentType string) string {
+	mediaEncodersMu.RLock()
+	defer mediaEncodersMu.RUnlock()
+	for f, enc := range mediaEncoders {
+		if strings.EqualFold(enc.contentType, contentType) {
+			return f
+		}
+	}
+	return ""
+}
+
+// mediaOptions 解析后的变换参数
+type mediaOptions struct {
+	Width, Height int
+	Fit           string
+	Crop          image.Rectangle // 零值表示不裁剪
+	Format        string          // 为空表示沿用源格式
+	Quality       int
+}
+
+// parseMediaOptions 解析查询参数，宽高不得超过上限
+func parseMediaOptions(q url.Values, maxWidth, maxHeight, defaultQuality int) (*mediaOptions, error) {
+	opts := &mediaOptions{Fit: MediaFitContain, Quality: defaultQuality}
+	var err error
+	if opts.Width, err = mediaDimension(q, "w", maxWidth); err != nil {
+		return nil, err
+	}
+	if opts.Height, err = mediaDimension(q, "h", maxHeight); err != nil {
+		return nil, err
+	}
+	if fit := q.Get("fit"); fit != "" {
+		switch fit {
+		case MediaFitContain, MediaFitCover, MediaFitFill:
+			opts.Fit = fit
+		default:
+			return nil, fmt.Errorf("fit must be contain, cover or fill")
+		}
+	}
+	if crop := q.Get("crop"); crop != "" {
+		parts := strings.Split(crop, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("crop must be x,y,width,height")
+		}
+		var v [4]int
+		for i, p := range parts {
+			if v[i], err = strconv.Atoi(strings.TrimSpace(p)); err != nil || v[i] < 0 {
+				return nil, fmt.Errorf("crop must be x,y,width,height with non-negative integers")
+			}
+		}
+		if v[2] == 0 || v[3] == 0 {
+			return nil, fmt.Errorf("crop width and height must be positive")
+		}
+		opts.Crop = image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])
+	}
+	if format := strings.ToLower(q.Get("fmt")); format != "" {
+		if format == "jpg" {
+			format = "jpeg"
+		}
+		if _, ok := lookupMediaEncoder(format); !ok {
+			return nil, fmt.Errorf("unsupported fmt %q, available: %s", format, mediaFormats())
+		}
+		opts.Format = format
+	}
+	if quality := q.Get("q"); quality != "" {
+		if opts.Quality, err = strconv.Atoi(quality); err != nil || opts.Quality < 1 || opts.Quality > 100 {
+			return nil, fmt.Errorf("q must be between 1 and 100")
+		}
+	}
+	return opts, nil
+}
+
+// mediaDimension 解析宽或高，未设置时为 0
+func mediaDimension(q url.Values, name string, limit int) (int, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 || v > limit {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, limit)
+	}
+	return v, nil
+}
+
+// canonical 变换参数的规范化表示，参与缓存键与 ETag 计算
+func (o *mediaOptions) canonical() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&crop=%d,%d,%d,%d&fmt=%s&q=%d",
+		o.Width, o.Height, o.Fit, o.Crop.Min.X, o.Crop.Min.Y, o.Crop.Dx(), o.Crop.Dy(), o.Format, o.Quality)
+}
+
+// transformImage 先裁剪再按 fit 缩放
+func transformImage(src image.Image, opts *mediaOptions) (image.Image, error) {
+	rect := src.Bounds()
+	if !opts.Crop.Empty() {
+		rect = opts.Crop.Add(src.Bounds().Min)
+		if !rect.In(src.Bounds()) {
+			return nil, fmt.Errorf("crop %v is outside the %dx%d image", opts.Crop, src.Bounds().Dx(), src.Bounds().Dy())
+		}
+	}
+	img := toRGBA(src, rect)
+
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	w, h := opts.Width, opts.Height
+	switch {
+	case w == 0 && h == 0:
+		return img, nil
+	case w == 0:
+		w = max(1, int(math.Round(float64(sw)*float64(h)/float64(sh))))
+		return resizeRGBA(img, w, h), nil
+	case h == 0:
+		h = max(1, int(math.Round(float64(sh)*float64(w)/float64(sw))))
+		return resizeRGBA(img, w, h), nil
+	}
+
+	switch opts.Fit {
+	case MediaFitFill:
+		return resizeRGBA(img, w, h), nil
+	case MediaFitCover:
+		scale := math.Max(float64(w)/float64(sw), float64(h)/float64(sh))
+		rw, rh := max(w, int(math.Round(float64(sw)*scale))), max(h, int(math.Round(float64(sh)*scale)))
+		resized := resizeRGBA(img, rw, rh)
+		x, y := (rw-w)/2, (rh-h)/2
+		return resized.SubImage(image.Rect(x, y, x+w, y+h)), nil
+	default:
+		scale := math.Min(float64(w)/float64(sw), float64(h)/float64(sh))
+		return resizeRGBA(img, max(1, int(math.Round(float64(sw)*scale))), max(1, int(math.Round(float64(sh)*scale)))), nil
+	}
+}
+
+// toRGBA 把图片的 rect 区域复制为原点对齐的 RGBA
+func toRGBA(src image.Image, rect image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// flattenAlpha 把带透明通道的图片铺到白底上，避免 JPEG 中透明区域变黑
+func flattenAlpha(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// resampleWeight 单个源像素的权重
+type resampleWeight struct {
+	index  int
+	weight float64
+}
+
+// resampleWeights 一维三角滤波权重：缩小时滤波半径随缩放比例放大，相当于区域平均，避免锯齿
+func resampleWeights(srcLen, dstLen int) [][]resampleWeight {
+	scale := float64(srcLen) / float64(dstLen)
+	support := math.Max(1, scale)
+	weights := make([][]resampleWeight, dstLen)
+	for i := range weights {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo, hi := int(math.Ceil(center-support)), int(math.Floor(center+support))
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			wt := 1 - math.Abs(float64(j)-center)/support
+			if wt <= 0 {
+				continue
+			}
+			idx := min(max(j, 0), srcLen-1)
+			weights[i] = append(weights[i], resampleWeight{index: idx, weight: wt})
+			sum += wt
+		}
+		for k := range weights[i] {
+			weights[i][k].weight /= sum
+		}
+	}
+	return weights
+}
+
+// resizeRGBA 可分离缩放：先水平后垂直
+func resizeRGBA(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == w && sh == h {
+		return src
+	}
+	tmp := image.NewRGBA(image.Rect(0, 0, w, sh))
+	xw := resampleWeights(sw, w)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < w; x++ {
+			var c [4]float64
+			for _, rw := range xw[x] {
+				off := src.PixOffset(rw.index, y)
+				for k := 0; k < 4; k++ {
+					c[k] += float64(src.Pix[off+k]) * rw.weight
+				}
+			}
+			writePixel(tmp.Pix[tmp.PixOffset(x, y):], c)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	yw := resampleWeights(sh, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var c [4]float64
+			for _, rw := range yw[y] {
+				off := tmp.PixOffset(x, rw.index)
+				for k := 0; k < 4; k++ {
+					c[k] += float64(tmp.Pix[off+k]) * rw.weight
+				}
+			}
+			writePixel(dst.Pix[dst.PixOffset(x, y):], c)
+		}
+	}
+	return dst
+}
+
+// writePixel 四舍五入并截断到 0-255
+func writePixel(pix []byte, c [4]float64) {
+	for k := 0; k < 4; k++ {
+		pix[k] = uint8(min(max(math.Round(c[k]), 0), 255))
+	}
+}
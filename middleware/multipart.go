@@ -0,0 +1,310 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\multipart.go
+ * @Description: multipart 表单策略 - 按路由限制文件个数、单文件与总大小，嗅探文件内容校验 MIME 类型，
+ *               校验通过的表单交给 ctx.Bind / r.FormFile 复用，并提供保存到 MinIO 的便捷方法
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// multipart 默认值
+const (
+	DefaultMultipartMaxTotalBytes = 64 << 20 // 整个请求体上限
+	DefaultMultipartMaxMemory     = 32 << 20 // 驻留内存上限，超出的文件写入临时文件
+	multipartSniffBytes           = 512      // http.DetectContentType 最多使用的字节数
+)
+
+// MultipartRule 单条 multipart 策略
+type MultipartRule struct {
+	Paths         []string // 路径，以 * 结尾表示前缀匹配
+	Methods       []string // HTTP 方法，为空匹配全部
+	MaxFiles      int      // 文件个数上限（所有字段合计），0 不限制
+	MaxFileBytes  int64    // 单个文件大小上限，0 不限制
+	MaxTotalBytes int64    // 整个请求体（含普通字段与分隔符）上限，默认 64MiB
+	AllowedTypes  []string // 允许的 MIME 类型，支持 image/* 形式；客户端声明的类型与嗅探出的类型都必须在列表中，为空不校验
+	MaxMemory     int64    // 表单驻留内存上限，默认 32MiB，超出部分写入 os.TempDir()（受 TMPDIR 环境变量控制）
+}
+
+// MultipartConfig multipart 表单策略配置
+type MultipartConfig struct {
+	Rules []MultipartRule // 按声明顺序匹配，先声明者优先
+}
+
+// Multipart 编译后的 multipart 策略
+type Multipart struct {
+	routes *RouteTable
+}
+
+// MultipartPolicy 单条路由生效的策略，也可脱离中间件在处理器中直接调用 Parse
+type MultipartPolicy struct {
+	rule         MultipartRule
+	allowedTypes []string
+}
+
+// UploadedFile 校验通过的上传文件
+type UploadedFile struct {
+	*multipart.FileHeader
+	Field        string // 表单字段名
+	DeclaredType string // 客户端声明的 Content-Type（不含参数），未声明时为 application/octet-stream
+	DetectedType string // 按文件内容嗅探出的类型（不含参数）
+}
+
+// uploadedFilesKey context 键
+type uploadedFilesKey struct{}
+
+// multipartRejectionsTotal multipart 拒绝数（注册到默认 Registry）
+var multipartRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_multipart_rejections_total",
+	Help: "Total number of multipart requests rejected by reason",
+}, []string{"reason"})
+
+// NewMultipart 校验配置并编译规则
+func NewMultipart(cfg MultipartConfig) (*Multipart, error) {
+	var patterns []RoutePattern
+	for i, rule := range cfg.Rules {
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "multipart rule %d has no paths", i)
+		}
+		policy, err := NewMultipartPolicy(rule)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "multipart rule %d: %v", i, err)
+		}
+		for _, p := range rule.Paths {
+			patterns = append(patterns, authnPattern(p, rule.Methods, policy))
+		}
+	}
+	return &Multipart{routes: NewRouteTable(patterns)}, nil
+}
+
+// NewMultipartPolicy 校验单条策略并补齐默认值
+func NewMultipartPolicy(rule MultipartRule) (*MultipartPolicy, error) {
+	if rule.MaxFiles < 0 || rule.MaxFileBytes < 0 || rule.MaxTotalBytes < 0 || rule.MaxMemory < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "multipart limits must not be negative")
+	}
+	if rule.MaxTotalBytes == 0 {
+		rule.MaxTotalBytes = DefaultMultipartMaxTotalBytes
+	}
+	if rule.MaxMemory == 0 {
+		rule.MaxMemory = DefaultMultipartMaxMemory
+	}
+	policy := &MultipartPolicy{rule: rule}
+	for _, t := range rule.AllowedTypes {
+		mediaType, _, err := mime.ParseMediaType(t)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid allowed type %q", t)
+		}
+		policy.allowedTypes = append(policy.allowedTypes, mediaType)
+	}
+	return policy, nil
+}
+
+// Handle 命中规则的 multipart 请求先解析并校验，通过后把表单挂到 r.MultipartForm，请求结束时删除临时文件
+func (m *Multipart) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(constants.HeaderContentType))
+	if mediaType != "multipart/form-data" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	value, ok := m.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	files, err := value.(*MultipartPolicy).Parse(w, r)
+	if err != nil {
+		response.WriteErrorResponse(w, err)
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+	next.ServeHTTP(w, r.WithContext(WithUploadedFiles(r.Context(), files)))
+}
+
+// HTTPMiddleware multipart 表单策略中间件
+func (m *Multipart) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Handle(w, r, next)
+	})
+}
+
+// Parse 解析 multipart 表单并按策略校验，成功时与 r.ParseMultipartForm 一样填充 r.Form、r.PostForm
+// 与 r.MultipartForm；失败时已写入的临时文件会被删除。调用方负责在请求结束时调用 r.MultipartForm.RemoveAll
+func (p *MultipartPolicy) Parse(w http.ResponseWriter, r *http.Request) ([]*UploadedFile, *errors.AppError) {
+	r.Body = http.MaxBytesReader(w, r.Body, p.rule.MaxTotalBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		multipartRejectionsTotal.WithLabelValues("invalid").Inc()
+		return nil, errors.NewErrorf(errors.ErrCodeBadRequest, "invalid multipart request: %v", err)
+	}
+	form, err := reader.ReadForm(p.rule.MaxMemory)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if stderrors.As(err, &maxBytesErr) || stderrors.Is(err, multipart.ErrMessageTooLarge) {
+			multipartRejectionsTotal.WithLabelValues("total_size").Inc()
+			return nil, errors.NewErrorf(errors.ErrCodeRequestTooLarge, "multipart request exceeds %d bytes", p.rule.MaxTotalBytes)
+		}
+		multipartRejectionsTotal.WithLabelValues("invalid").Inc()
+		return nil, errors.NewErrorf(errors.ErrCodeBadRequest, "invalid multipart request: %v", err)
+	}
+
+	files, appErr := p.validate(form)
+	if appErr != nil {
+		_ = form.RemoveAll()
+		return nil, appErr
+	}
+
+	if r.Form == nil {
+		r.Form = make(url.Values)
+		for k, v := range r.URL.Query() {
+			r.Form[k] = v
+		}
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	for k, v := range form.Value {
+		r.Form[k] = append(r.Form[k], v...)
+		r.PostForm[k] = append(r.PostForm[k], v...)
+	}
+	r.MultipartForm = form
+	return files, nil
+}
+
+// validate 校验文件个数、大小与类型
+func (p *MultipartPolicy) validate(form *multipart.Form) ([]*UploadedFile, *errors.AppError) {
+	fields := make([]string, 0, len(form.File))
+	for field := range form.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var files []*UploadedFile
+	for _, field := range fields {
+		for _, fh := range form.File[field] {
+			if p.rule.MaxFiles > 0 && len(files) >= p.rule.MaxFiles {
+				multipartRejectionsTotal.WithLabelValues("file_count").Inc()
+				return nil, errors.NewErrorf(errors.ErrCodeRequestTooLarge, "multipart request has more than %d files", p.rule.MaxFiles)
+			}
+			if p.rule.MaxFileBytes > 0 && fh.Size > p.rule.MaxFileBytes {
+				multipartRejectionsTotal.WithLabelValues("file_size").Inc()
+				return nil, errors.NewErrorf(errors.ErrCodeRequestTooLarge, "file %q is %d bytes, exceeds limit %d", fh.Filename, fh.Size, p.rule.MaxFileBytes)
+			}
+
+			file := &UploadedFile{FileHeader: fh, Field: field, DeclaredType: "application/octet-stream"}
+			if declared, _, err := mime.ParseMediaType(fh.Header.Get(constants.HeaderContentType)); err == nil {
+				file.DeclaredType = declared
+			}
+			detected, err := sniffContentType(fh)
+			if err != nil {
+				multipartRejectionsTotal.WithLabelValues("invalid").Inc()
+				return nil, errors.NewErrorf(errors.ErrCodeBadRequest, "read file %q: %v", fh.Filename, err)
+			}
+			file.DetectedType = detected
+
+			if !p.typeAllowed(file.DeclaredType) || !p.typeAllowed(file.DetectedType) {
+				multipartRejectionsTotal.WithLabelValues("file_type").Inc()
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidContentType,
+					"file %q has type %s (detected %s), allowed: %s", fh.Filename, file.DeclaredType, file.DetectedType, strings.Join(p.allowedTypes, ", "))
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// typeAllowed 判断类型是否在白名单中，白名单为空时全部允许
+func (p *MultipartPolicy) typeAllowed(mediaType string) bool {
+	if len(p.allowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range p.allowedTypes {
+		if allowed == mediaType || allowed == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffContentType 读取文件头部嗅探类型，返回不含参数的媒体类型
+func sniffContentType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, multipartSniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, nil
+}
+
+// WithUploadedFiles 把校验通过的文件放入 context
+func WithUploadedFiles(ctx context.Context, files []*UploadedFile) context.Context {
+	return context.WithValue(ctx, uploadedFilesKey{}, files)
+}
+
+// UploadedFilesFromContext 读取 multipart 中间件校验通过的文件，未经过中间件时返回 nil
+func UploadedFilesFromContext(ctx context.Context) []*UploadedFile {
+	files, _ := ctx.Value(uploadedFilesKey{}).([]*UploadedFile)
+	return files
+}
+
+// Ext 文件扩展名（含点，小写），用于生成对象键
+func (f *UploadedFile) Ext() string {
+	return strings.ToLower(path.Ext(f.Filename))
+}
+
+// SaveToMinIO 把文件上传到 MinIO，client 为 nil 时使用全局 MinIO 连接；对象的 Content-Type 取客户端声明的类型。
+// 配置了上传扫描（SetUploadScan）时先扫描，检出病毒返回 *UploadInfectedError，文件不会写入 bucket
+func (f *UploadedFile) SaveToMinIO(ctx context.Context, client *minio.Client, bucket, key string) (minio.UploadInfo, error) {
+	if client == nil {
+		client = global.GetMinIO()
+	}
+	if client == nil {
+		return minio.UploadInfo{}, errors.NewError(errors.ErrCodeServiceUnavailable, "minio client is not initialized")
+	}
+	if scan := uploadScan.Load(); scan != nil {
+		if err := scan.beforeSave(ctx, client, f, key); err != nil {
+			return minio.UploadInfo{}, err
+		}
+	}
+	file, err := f.Open()
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer file.Close()
+	return client.PutObject(ctx, bucket, key, file, f.Size, minio.PutObjectOptions{ContentType: f.DeclaredType})
+}
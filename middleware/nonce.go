@@ -21,13 +21,13 @@ import (
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-rpc-gateway/response"
 	"github.com/kamalyes/go-argus"
-	"github.com/redis/go-redis/v9"
+	"github.com/kamalyes/go-rpc-gateway/store"
 )
 
 // NonceMiddleware Nonce 防重放中间件
 //
 // 功能：
-// - 使用 Redis INCR 原子操作记录 Nonce 使用次数
+// - 使用状态存储的 INCR 原子操作记录 Nonce 使用次数（Redis 或内嵌存储）
 // - 检测重放攻击（同一 Nonce 被多次使用）
 // - 便于安全审计（可以统计 Nonce 使用频率）
 func NonceMiddleware(config *signature.Signature) HTTPMiddleware {
@@ -46,9 +46,9 @@ func NonceMiddleware(config *signature.Signature) HTTPMiddleware {
 				return
 			}
 
-			// Redis 不可用，记录警告并放行
-			if global.REDIS == nil {
-				global.LOGGER.WarnContext(r.Context(), "Nonce middleware enabled but Redis is not available")
+			// 状态存储不可用，记录警告并放行
+			if global.STORE == nil {
+				global.LOGGER.WarnContext(r.Context(), "Nonce middleware enabled but store is not available")
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -67,7 +67,7 @@ func NonceMiddleware(config *signature.Signature) HTTPMiddleware {
 			}
 
 			// 检查并递增 Nonce 计数（原子操作）
-			count, err := checkAndIncrNonce(r.Context(), global.REDIS, config.NonceKeyPrefix, nonceValue, config.NonceTTL)
+			count, err := checkAndIncrNonce(r.Context(), global.STORE, config.NonceKeyPrefix, nonceValue, config.NonceTTL)
 			if err != nil {
 				global.LOGGER.WarnContext(r.Context(), "Failed to check/store nonce: %v", err)
 				response.WriteErrorResponseWithCode(w, http.StatusInternalServerError, constants.SignatureErrorCodeInvalid, "Nonce validation failed")
@@ -94,18 +94,18 @@ func NonceMiddleware(config *signature.Signature) HTTPMiddleware {
 // 1. 原子操作，线程安全
 // 2. 记录使用次数，便于安全审计
 // 3. 可以检测重放攻击的频率
-func checkAndIncrNonce(ctx context.Context, redisClient redis.UniversalClient, keyPrefix, nonce string, ttl time.Duration) (int64, error) {
+func checkAndIncrNonce(ctx context.Context, s store.Store, keyPrefix, nonce string, ttl time.Duration) (int64, error) {
 	nonceKey := keyPrefix + nonce
 
 	// 使用 INCR 原子递增计数
-	count, err := redisClient.Incr(ctx, nonceKey).Result()
+	count, err := s.Incr(ctx, nonceKey)
 	if err != nil {
 		return 0, fmt.Errorf("failed to increment nonce counter: %w", err)
 	}
 
 	// 如果是第一次使用（count == 1），设置过期时间
 	if count == 1 {
-		if err := redisClient.Expire(ctx, nonceKey, ttl).Err(); err != nil {
+		if err := s.Expire(ctx, nonceKey, ttl); err != nil {
 			return count, fmt.Errorf("failed to set nonce expiration: %w", err)
 		}
 	}
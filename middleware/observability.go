@@ -43,10 +43,13 @@ type MetricsManager struct {
 type HTTPMetrics struct {
 	requestsTotal   *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
+	upstreamTime    *prometheus.HistogramVec // 调用上游的请求中等待上游的时间
+	selfTime        *prometheus.HistogramVec // 调用上游的请求中网关自身的时间（总耗时减去上游耗时）
 	requestSize     *prometheus.SummaryVec
 	responseSize    *prometheus.SummaryVec
 	activeRequests  prometheus.Gauge
 	pathNormalizer  PathNormalizer // 路径规范化器，减少标签基数
+	pathLabeler     *PathLabeler   // 路由模板标签（pathNormalizer 的默认实现）
 }
 
 // NewMetricsManager 创建可观测性管理器（支持 gRPC + HTTP 完整指标）
@@ -89,6 +92,9 @@ func NewMetricsManager(cfg *monitoring.Monitoring) *MetricsManager {
 		Help: "Total number of gRPC requests recovered from internal panic.",
 	})
 
+	// 构建信息指标
+	registerBuildInfoMetric(registry)
+
 	// 创建 HTTP 指标
 	httpMetrics := newHTTPMetrics(registry, buckets, cfg.Metrics.StaticPaths)
 
@@ -109,6 +115,7 @@ func NewMetricsManager(cfg *monitoring.Monitoring) *MetricsManager {
 
 // newHTTPMetrics 创建 HTTP 指标（智能路径规范化）
 func newHTTPMetrics(registry *prometheus.Registry, buckets []float64, staticPaths []string) *HTTPMetrics {
+	labeler, _ := NewPathLabeler(PathLabelConfig{}, staticPaths) // 空配置不会出错
 	return &HTTPMetrics{
 		requestsTotal: promauto.With(registry).NewCounterVec(
 			prometheus.CounterOpts{
@@ -125,6 +132,22 @@ func newHTTPMetrics(registry *prometheus.Registry, buckets []float64, staticPath
 			},
 			[]string{"method", "path"},
 		),
+		upstreamTime: promauto.With(registry).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_upstream_duration_seconds",
+				Help:    "Time proxied HTTP requests spent waiting on upstreams in seconds",
+				Buckets: buckets,
+			},
+			[]string{"method", "path"},
+		),
+		selfTime: promauto.With(registry).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_self_duration_seconds",
+				Help:    "Time proxied HTTP requests spent in the gateway itself (total minus upstream) in seconds",
+				Buckets: buckets,
+			},
+			[]string{"method", "path"},
+		),
 		requestSize: promauto.With(registry).NewSummaryVec(
 			prometheus.SummaryOpts{
 				Name: "http_request_size_bytes",
@@ -145,24 +168,39 @@ func newHTTPMetrics(registry *prometheus.Registry, buckets []float64, staticPath
 				Help: "Current number of HTTP requests being served",
 			},
 		),
-		pathNormalizer: newSmartPathNormalizer(staticPaths),
+		pathNormalizer: labeler,
+		pathLabeler:    labeler,
 	}
 }
 
 // RecordHTTPRequest 记录 HTTP 请求（使用详细指标）
 func (mm *MetricsManager) RecordHTTPRequest(method, path string, statusCode int, duration time.Duration, requestSize, responseSize int64) {
+	mm.recordHTTPRequest(method, path, statusCode, duration, requestSize, responseSize, nil)
+}
+
+// RecordHTTPRequestContext 记录 HTTP 请求，上下文中存在已采样的 span 时为请求数与耗时附加 trace Exemplar
+func (mm *MetricsManager) RecordHTTPRequestContext(ctx context.Context, method, path string, statusCode int, duration time.Duration, requestSize, responseSize int64) {
+	mm.recordHTTPRequest(method, path, statusCode, duration, requestSize, responseSize, ExemplarFromContext(ctx))
+}
+
+// recordHTTPRequest 记录 HTTP 请求，exemplar 为空时不附加
+func (mm *MetricsManager) recordHTTPRequest(method, path string, statusCode int, duration time.Duration, requestSize, responseSize int64, exemplar prometheus.Labels) {
 	if mm == nil || mm.httpMetrics == nil {
 		return
 	}
 
 	// 规范化路径，减少标签基数
-	normalizedPath := mm.httpMetrics.pathNormalizer.Normalize(path)
+	mm.recordHTTPRequestLabel(method, mm.httpMetrics.pathNormalizer.Normalize(path), statusCode, duration, requestSize, responseSize, exemplar)
+}
+
+// recordHTTPRequestLabel 按已确定的路径标签记录 HTTP 请求
+func (mm *MetricsManager) recordHTTPRequestLabel(method, normalizedPath string, statusCode int, duration time.Duration, requestSize, responseSize int64, exemplar prometheus.Labels) {
 
 	// 记录请求总数
-	mm.httpMetrics.requestsTotal.WithLabelValues(method, normalizedPath, http.StatusText(statusCode)).Inc()
+	incWithExemplar(mm.httpMetrics.requestsTotal.WithLabelValues(method, normalizedPath, statusText(statusCode)), exemplar)
 
 	// 记录请求持续时间
-	mm.httpMetrics.requestDuration.WithLabelValues(method, normalizedPath).Observe(duration.Seconds())
+	observeWithExemplar(mm.httpMetrics.requestDuration.WithLabelValues(method, normalizedPath), duration.Seconds(), exemplar)
 
 	// 记录请求大小
 	if requestSize > 0 {
@@ -200,23 +238,41 @@ func HTTPMetricsMiddleware(m *MetricsManager) MiddlewareFunc {
 				statusCode:     http.StatusOK,
 			}
 
+			// 放置路由模板槽位，内层路由匹配后回填
+			r = WithPathLabelSlot(r)
+
 			start := time.Now()
 			next.ServeHTTP(wrapped, r)
 			duration := time.Since(start)
 
-			// 记录指标
-			m.RecordHTTPRequest(
+			// 客户端断开的请求按 499 记录，与服务端错误区分
+			statusCode := wrapped.statusCode
+			if IsClientCanceled(r.Context(), nil) {
+				statusCode = StatusClientClosedRequest
+			}
+
+			// 记录指标（Exemplar 关联 trace，需 OpenMetrics 格式暴露）
+			m.recordHTTPRequestLabel(
 				r.Method,
-				r.URL.Path,
-				wrapped.statusCode,
+				m.httpMetrics.pathLabeler.Label(r),
+				statusCode,
 				duration,
 				r.ContentLength,
 				int64(wrapped.bytesWritten),
+				ExemplarFromContext(r.Context()),
 			)
 		})
 	}
 }
 
+// PathLabeler 指标路径标签生成器，未启用 HTTP 指标时返回 nil
+func (mm *MetricsManager) PathLabeler() *PathLabeler {
+	if mm == nil || mm.httpMetrics == nil {
+		return nil
+	}
+	return mm.httpMetrics.pathLabeler
+}
+
 // GetRegistry 获取 Prometheus 注册表
 func (mm *MetricsManager) GetRegistry() *prometheus.Registry {
 	return mm.registry
@@ -254,12 +310,41 @@ func (mm *MetricsManager) Handler() http.Handler {
 	return promhttp.HandlerFor(mm.registry, opts)
 }
 
-// ExemplarFromContext 从上下文中提取 Exemplar（用于关联 trace）
+// ExemplarFromContext 从上下文中提取 Exemplar（用于关联 trace），支持 context.Context 与 trace.SpanContext
+// 只有已采样的 span 才返回 Exemplar，避免指向追踪后端中不存在的 trace
 func ExemplarFromContext(ctx interface{}) prometheus.Labels {
-	if spanCtx, ok := ctx.(trace.SpanContext); ok && spanCtx.IsSampled() {
-		return prometheus.Labels{"trace_id": spanCtx.TraceID().String()}
+	var spanCtx trace.SpanContext
+	switch v := ctx.(type) {
+	case trace.SpanContext:
+		spanCtx = v
+	case context.Context:
+		spanCtx = trace.SpanContextFromContext(v)
+	}
+	if !spanCtx.IsSampled() {
+		return nil
 	}
-	return nil
+	return prometheus.Labels{
+		TraceIDKey:               spanCtx.TraceID().String(),
+		constants.LogFieldSpanID: spanCtx.SpanID().String(),
+	}
+}
+
+// observeWithExemplar 观测值，exemplar 非空且指标支持时附加 Exemplar
+func observeWithExemplar(o prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	o.Observe(value)
+}
+
+// incWithExemplar 计数加一，exemplar 非空且指标支持时附加 Exemplar
+func incWithExemplar(c prometheus.Counter, exemplar prometheus.Labels) {
+	if ea, ok := c.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		ea.AddWithExemplar(1, exemplar)
+		return
+	}
+	c.Inc()
 }
 
 // HTTPMiddleware 返回 HTTP 指标中间件
@@ -276,36 +361,55 @@ func (mm *MetricsManager) HTTPMiddleware() func(http.Handler) http.Handler {
 			mm.httpMetrics.activeRequests.Inc()
 			defer mm.httpMetrics.activeRequests.Dec()
 
-			// 规范化路径，减少标签基数
-			normalizedPath := mm.httpMetrics.pathNormalizer.Normalize(r.URL.Path)
-
-			// 记录请求大小
-			if r.ContentLength > 0 {
-				mm.httpMetrics.requestSize.WithLabelValues(r.Method, normalizedPath).Observe(float64(r.ContentLength))
-			}
-
 			// 包装 ResponseWriter 以捕获状态码和响应大小
 			wrapped := &metricsResponseWriter{
 				ResponseWriter: w,
 				statusCode:     http.StatusOK,
 			}
 
+			// 放置路由模板槽位，内层路由匹配后回填
+			r = WithPathLabelSlot(r)
+
+			// 放置上游计时器，转发与 gRPC 后端调用在其中累计等待上游的时间
+			r = WithUpstreamTimer(r)
+
 			// 记录请求开始时间
 			start := time.Now()
 
 			// 执行下一个处理器
 			next.ServeHTTP(wrapped, r)
 
-			// 记录持续时间
-			duration := time.Since(start).Seconds()
-			mm.httpMetrics.requestDuration.WithLabelValues(r.Method, normalizedPath).Observe(duration)
+			// 路由模板作为路径标签，减少标签基数
+			normalizedPath := mm.httpMetrics.pathLabeler.Label(r)
 
-			// 记录请求总数
-			mm.httpMetrics.requestsTotal.WithLabelValues(
+			// 记录请求大小
+			if r.ContentLength > 0 {
+				mm.httpMetrics.requestSize.WithLabelValues(r.Method, normalizedPath).Observe(float64(r.ContentLength))
+			}
+
+			// 记录持续时间（附加 trace Exemplar）
+			exemplar := ExemplarFromContext(r.Context())
+			elapsed := time.Since(start)
+			duration := elapsed.Seconds()
+			observeWithExemplar(mm.httpMetrics.requestDuration.WithLabelValues(r.Method, normalizedPath), duration, exemplar)
+
+			// 调用过上游的请求拆分上游耗时与网关自身耗时
+			if upstream, ok := UpstreamElapsed(r.Context()); ok {
+				upstream = min(upstream, elapsed)
+				observeWithExemplar(mm.httpMetrics.upstreamTime.WithLabelValues(r.Method, normalizedPath), upstream.Seconds(), exemplar)
+				observeWithExemplar(mm.httpMetrics.selfTime.WithLabelValues(r.Method, normalizedPath), (elapsed - upstream).Seconds(), exemplar)
+			}
+
+			// 记录请求总数（客户端断开按 499 记录）
+			statusCode := wrapped.statusCode
+			if IsClientCanceled(r.Context(), nil) {
+				statusCode = StatusClientClosedRequest
+			}
+			incWithExemplar(mm.httpMetrics.requestsTotal.WithLabelValues(
 				r.Method,
 				normalizedPath,
-				http.StatusText(wrapped.statusCode),
-			).Inc()
+				statusText(statusCode),
+			), exemplar)
 
 			// 记录响应大小
 			if wrapped.bytesWritten > 0 {
@@ -333,6 +437,11 @@ func (rw *metricsResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap 返回底层的 http.ResponseWriter（供 http.ResponseController 刷新流式响应）
+func (rw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // ============================================================================
 // 可观测性中间件 - HTTP & gRPC 拦截器
 // ============================================================================
@@ -0,0 +1,521 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\openapi_validation.go
+ * @Description: OpenAPI 正向校验 - 只放行与已加载（聚合）规范匹配的请求：未声明的路径返回 404、
+ *               未声明的方法返回 405、未声明或不合法的参数返回 400，请求不会到达上游；
+ *               支持 Swagger 2.0 与 OpenAPI 3.x，report 模式只记录不拦截，便于先观察再启用
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-swagger/loader"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// OpenAPI 校验模式
+const (
+	OpenAPIModeEnforce = "enforce" // 拒绝不匹配规范的请求
+	OpenAPIModeReport  = "report"  // 只记录日志与指标，请求照常转发
+)
+
+// OpenAPI 拒绝原因（指标 reason 标签）
+const (
+	OpenAPIRejectPath   = "path"   // 路径未声明
+	OpenAPIRejectMethod = "method" // 路径已声明但方法未声明
+	OpenAPIRejectParam  = "param"  // 参数未声明、缺失或类型不符
+)
+
+// DefaultOpenAPISkipPaths 默认不校验的路径：健康检查、指标、文档与管理诊断接口
+var DefaultOpenAPISkipPaths = []string{"/health", "/healthz", "/readyz", "/livez", "/metrics", "/swagger*", "/debug/*", "/admin/*"}
+
+// openAPIMethods 规范中表示操作的键
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OpenAPIValidationConfig OpenAPI 正向校验配置
+type OpenAPIValidationConfig struct {
+	Mode string // enforce（默认）或 report
+	// SpecFiles 规范文件（JSON / YAML），为空时使用 Swagger 配置：启用聚合时使用聚合后的规范，否则使用 spec-path
+	SpecFiles []string
+	// SkipPaths 不校验的路径，以 * 结尾为前缀匹配；为空时使用 DefaultOpenAPISkipPaths
+	SkipPaths []string
+	// AllowUnknownQuery 放行规范未声明的查询参数（仍校验已声明参数的必填与类型）
+	AllowUnknownQuery bool
+	// IgnoredQuery 始终放行的查询参数，如缓存击穿用的 _ 或 grpc-gateway 的 $alt
+	IgnoredQuery []string
+}
+
+// openAPIParam 规范中声明的参数
+type openAPIParam struct {
+	name     string
+	in       string // query / header / path / body
+	required bool
+	kind     string // string / integer / number / boolean / array，空为不校验
+	items    string // 数组元素类型
+	enum     []string
+}
+
+// openAPIOperation 编译后的操作
+type openAPIOperation struct {
+	template     string
+	method       string
+	query        map[string]*openAPIParam
+	headers      []*openAPIParam
+	pathParams   map[int]*openAPIParam // 路径段下标 → 参数，仅整段参数
+	bodyRequired bool
+}
+
+// openAPIPath 路径模板与其声明的方法（405 时的 Allow 头）
+type openAPIPath struct {
+	template string
+	allow    string
+}
+
+// OpenAPIValidator OpenAPI 正向校验器，构建后只读
+type OpenAPIValidator struct {
+	config     OpenAPIValidationConfig
+	report     bool
+	operations *RouteTable // 方法 + 路径 → *openAPIOperation
+	paths      *RouteTable // 路径 → *openAPIPath
+	skip       *RouteTable
+	ignored    map[string]bool
+	count      int
+}
+
+// openAPIRejectionsTotal 校验不通过的请求数（report 模式同样计数）
+var openAPIRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_openapi_rejections_total",
+	Help: "Total number of requests that did not match the loaded OpenAPI spec",
+}, []string{"reason", "mode"})
+
+// NewOpenAPIValidator 从规范编译校验器；cfg.SpecFiles 中的文件追加在 specs 之后，同一路径与方法以先出现的为准
+func NewOpenAPIValidator(cfg OpenAPIValidationConfig, specs ...map[string]any) (*OpenAPIValidator, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = OpenAPIModeEnforce
+	case OpenAPIModeEnforce, OpenAPIModeReport:
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "openapi validation: unknown mode %q", cfg.Mode)
+	}
+	for _, file := range cfg.SpecFiles {
+		spec, err := loader.LoadSpecFromPath(file)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "openapi validation: load %s: %v", file, err)
+		}
+		specs = append(specs, spec)
+	}
+	if cfg.SkipPaths == nil {
+		cfg.SkipPaths = DefaultOpenAPISkipPaths
+	}
+
+	var ops []*openAPIOperation
+	methods := make(map[string][]string) // 模板 → 方法
+	var templates []string
+	for _, spec := range specs {
+		if err := collectOpenAPIOperations(spec, func(template, method string, op *openAPIOperation) {
+			if _, ok := methods[template]; !ok {
+				templates = append(templates, template)
+			}
+			if !slices.Contains(methods[template], method) {
+				methods[template] = append(methods[template], method)
+				ops = append(ops, op)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if len(ops) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "openapi validation: spec declares no operations")
+	}
+
+	// 字面量段多的模板优先，避免 /users/{id} 遮住 /users/me
+	sort.SliceStable(templates, func(i, j int) bool {
+		return openAPITemplateRank(templates[i]) > openAPITemplateRank(templates[j])
+	})
+	rank := make(map[string]int, len(templates))
+	pathPatterns := make([]RoutePattern, 0, len(templates))
+	for i, template := range templates {
+		rank[template] = i
+		allow := slices.Sorted(slices.Values(methods[template]))
+		pathPatterns = append(pathPatterns, openAPIRoutePattern(template, nil, &openAPIPath{template: template, allow: strings.Join(allow, ", ")}))
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return rank[ops[i].template] < rank[ops[j].template] })
+	opPatterns := make([]RoutePattern, 0, len(ops))
+	for _, op := range ops {
+		opPatterns = append(opPatterns, openAPIRoutePattern(op.template, []string{op.method}, op))
+	}
+
+	skip := make([]RoutePattern, 0, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip = append(skip, authnPattern(p, nil, true))
+	}
+	ignored := make(map[string]bool, len(cfg.IgnoredQuery))
+	for _, name := range cfg.IgnoredQuery {
+		ignored[name] = true
+	}
+	return &OpenAPIValidator{
+		config:     cfg,
+		report:     cfg.Mode == OpenAPIModeReport,
+		operations: NewRouteTable(opPatterns),
+		paths:      NewRouteTable(pathPatterns),
+		skip:       NewRouteTable(skip),
+		ignored:    ignored,
+		count:      len(ops),
+	}, nil
+}
+
+// Mode 当前校验模式
+func (v *OpenAPIValidator) Mode() string {
+	return v.config.Mode
+}
+
+// Len 已编译的操作数（路径 + 方法）
+func (v *OpenAPIValidator) Len() int {
+	return v.count
+}
+
+// Handle 校验请求，不通过时按模式拒绝或记录
+func (v *OpenAPIValidator) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if _, skip := v.skip.Match(r.Method, r.URL.Path); skip {
+		next.ServeHTTP(w, r)
+		return
+	}
+	status, reason, message, allow := v.check(r)
+	if status == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+	openAPIRejectionsTotal.WithLabelValues(reason, v.config.Mode).Inc()
+	if v.report {
+		global.LOGGER.WarnContextKV(r.Context(), "请求与 OpenAPI 规范不匹配",
+			"method", r.Method, "path", r.URL.Path, "reason", reason, "message", message)
+		next.ServeHTTP(w, r)
+		return
+	}
+	switch status {
+	case http.StatusNotFound:
+		response.WriteAppErrorf(w, errors.ErrCodeNotFound, "%s", message)
+	case http.StatusMethodNotAllowed:
+		w.Header().Set("Allow", allow)
+		response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "%s", message)
+	default:
+		response.WriteAppErrorf(w, errors.ErrCodeInvalidParameter, "%s", message)
+	}
+}
+
+// check 返回 0 表示通过，否则返回状态码、拒绝原因、错误信息与 405 时的 Allow 头
+func (v *OpenAPIValidator) check(r *http.Request) (int, string, string, string) {
+	value, ok := v.operations.Match(r.Method, r.URL.Path)
+	if !ok {
+		if p, ok := v.paths.Match(r.Method, r.URL.Path); ok {
+			allow := p.(*openAPIPath).allow
+			return http.StatusMethodNotAllowed, OpenAPIRejectMethod,
+				"method " + r.Method + " is not allowed on " + r.URL.Path + " (allowed: " + allow + ")", allow
+		}
+		return http.StatusNotFound, OpenAPIRejectPath, "path " + r.URL.Path + " is not declared in the API spec", ""
+	}
+	op := value.(*openAPIOperation)
+	if msg := v.checkParams(op, r); msg != "" {
+		return http.StatusBadRequest, OpenAPIRejectParam, msg, ""
+	}
+	return 0, "", "", ""
+}
+
+// checkParams 校验路径、查询、请求头参数与请求体，返回错误信息
+func (v *OpenAPIValidator) checkParams(op *openAPIOperation, r *http.Request) string {
+	if len(op.pathParams) > 0 {
+		segments := strings.Split(r.URL.Path, "/")
+		for i, p := range op.pathParams {
+			if i >= len(segments) {
+				continue
+			}
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return "path parameter " + p.name + " is empty or malformed"
+			}
+			if msg := p.checkValue(value, p.kind); msg != "" {
+				return msg
+			}
+		}
+	}
+
+	query := r.URL.Query()
+	for name, values := range query {
+		p, ok := op.query[name]
+		if !ok {
+			if v.config.AllowUnknownQuery || v.ignored[name] {
+				continue
+			}
+			return "query parameter " + name + " is not declared in the API spec"
+		}
+		if p.kind != "array" && len(values) > 1 {
+			return "query parameter " + name + " must not be repeated"
+		}
+		for _, value := range values {
+			if msg := p.checkQueryValue(value); msg != "" {
+				return msg
+			}
+		}
+	}
+	for _, p := range op.query {
+		if p.required && !query.Has(p.name) {
+			return "query parameter " + p.name + " is required"
+		}
+	}
+
+	for _, p := range op.headers {
+		value := r.Header.Get(p.name)
+		if value == "" {
+			if p.required {
+				return "header " + p.name + " is required"
+			}
+			continue
+		}
+		if msg := p.checkValue(value, p.kind); msg != "" {
+			return msg
+		}
+	}
+
+	// ContentLength 为 -1（分块传输）时视为有请求体
+	if op.bodyRequired && r.ContentLength == 0 {
+		return "request body is required"
+	}
+	return ""
+}
+
+// checkQueryValue 校验单个查询参数值，数组按元素类型校验（兼容逗号分隔）
+func (p *openAPIParam) checkQueryValue(value string) string {
+	if p.kind != "array" {
+		return p.checkValue(value, p.kind)
+	}
+	for _, item := range strings.Split(value, ",") {
+		if msg := p.checkValue(item, p.items); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// checkValue 按类型与枚举校验参数值
+func (p *openAPIParam) checkValue(value, kind string) string {
+	var err error
+	switch kind {
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return p.in + " parameter " + p.name + " must be " + kind + ", got " + strconv.Quote(value)
+	}
+	if len(p.enum) > 0 && !slices.Contains(p.enum, value) {
+		return p.in + " parameter " + p.name + " must be one of [" + strings.Join(p.enum, ", ") + "], got " + strconv.Quote(value)
+	}
+	return ""
+}
+
+// collectOpenAPIOperations 遍历规范中的操作，路径模板带上 basePath（Swagger 2.0）或 servers 的路径（OpenAPI 3.x）
+func collectOpenAPIOperations(spec map[string]any, add func(template, method string, op *openAPIOperation)) error {
+	paths, ok := spec["paths"].(map[string]any)
+	if !ok {
+		return errors.NewError(errors.ErrCodeInvalidConfiguration, "openapi validation: spec has no paths object")
+	}
+	bases := openAPIBasePaths(spec)
+	for _, template := range slices.Sorted(maps.Keys(paths)) {
+		item := openAPIResolve(spec, paths[template])
+		if item == nil {
+			continue
+		}
+		shared := openAPIParams(spec, item["parameters"], nil)
+		for _, m := range openAPIMethods {
+			raw := openAPIResolve(spec, item[m])
+			if raw == nil {
+				continue
+			}
+			params := openAPIParams(spec, raw["parameters"], shared)
+			bodyRequired := false
+			if body := openAPIResolve(spec, raw["requestBody"]); body != nil {
+				bodyRequired, _ = body["required"].(bool)
+			}
+			for _, base := range bases {
+				op := newOpenAPIOperation(base+template, strings.ToUpper(m), params)
+				op.bodyRequired = op.bodyRequired || bodyRequired
+				add(op.template, op.method, op)
+			}
+		}
+	}
+	return nil
+}
+
+// openAPIBasePaths 规范声明的路径前缀，未声明时为空串
+func openAPIBasePaths(spec map[string]any) []string {
+	if base, ok := spec["basePath"].(string); ok {
+		return []string{strings.TrimSuffix(base, "/")}
+	}
+	var bases []string
+	servers, _ := spec["servers"].([]any)
+	for _, s := range servers {
+		server, _ := s.(map[string]any)
+		raw, _ := server["url"].(string)
+		u, err := url.Parse(raw)
+		if err != nil || strings.Contains(u.Path, "{") {
+			continue
+		}
+		if base := strings.TrimSuffix(u.Path, "/"); !slices.Contains(bases, base) {
+			bases = append(bases, base)
+		}
+	}
+	if len(bases) == 0 {
+		bases = []string{""}
+	}
+	return bases
+}
+
+// openAPIResolve 取对象，$ref 指向同一规范内的对象时解析引用（如 #/parameters/Page、#/components/parameters/Page）
+func openAPIResolve(spec map[string]any, value any) map[string]any {
+	obj, _ := value.(map[string]any)
+	for depth := 0; obj != nil && depth < 8; depth++ {
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return obj
+		}
+		pointer, ok := strings.CutPrefix(ref, "#/")
+		if !ok {
+			return nil
+		}
+		var node any = spec
+		for _, token := range strings.Split(pointer, "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			m, _ := node.(map[string]any)
+			node = m[token]
+		}
+		obj, _ = node.(map[string]any)
+	}
+	return obj
+}
+
+// openAPIParams 解析参数列表，同名同位置的参数覆盖 inherited 中的路径级参数
+func openAPIParams(spec map[string]any, value any, inherited []*openAPIParam) []*openAPIParam {
+	list, _ := value.([]any)
+	params := slices.Clone(inherited)
+	for _, item := range list {
+		raw := openAPIResolve(spec, item)
+		if raw == nil {
+			continue
+		}
+		p := &openAPIParam{}
+		p.name, _ = raw["name"].(string)
+		p.in, _ = raw["in"].(string)
+		p.required, _ = raw["required"].(bool)
+		schema := raw
+		if s := openAPIResolve(spec, raw["schema"]); s != nil && p.in != "body" {
+			schema = s
+		}
+		p.kind, _ = schema["type"].(string)
+		enum := schema["enum"]
+		if items := openAPIResolve(spec, schema["items"]); items != nil && p.kind == "array" {
+			p.items, _ = items["type"].(string)
+			enum = items["enum"]
+		}
+		values, _ := enum.([]any)
+		for _, e := range values {
+			p.enum = append(p.enum, fmt.Sprint(e))
+		}
+		params = slices.DeleteFunc(params, func(q *openAPIParam) bool { return q.name == p.name && q.in == p.in })
+		params = append(params, p)
+	}
+	return params
+}
+
+// newOpenAPIOperation 按位置归类参数；模板中整段的 {name} 参数记录段下标用于类型校验
+func newOpenAPIOperation(template, method string, params []*openAPIParam) *openAPIOperation {
+	op := &openAPIOperation{template: template, method: method, query: make(map[string]*openAPIParam)}
+	for _, p := range params {
+		switch p.in {
+		case "query":
+			op.query[p.name] = p
+		case "header":
+			op.headers = append(op.headers, p)
+		case "body":
+			op.bodyRequired = op.bodyRequired || p.required
+		}
+	}
+	// {name=shelves/*} 会展开为多段，此时段下标无法对齐，只校验路由
+	if strings.Contains(template, "=") {
+		return op
+	}
+	for i, segment := range strings.Split(template, "/") {
+		name, ok := strings.CutPrefix(segment, "{")
+		if name, ok = strings.CutSuffix(name, "}"); !ok || strings.ContainsAny(name, "{}") {
+			continue
+		}
+		for _, p := range params {
+			if p.in == "path" && p.name == name {
+				if op.pathParams == nil {
+					op.pathParams = make(map[int]*openAPIParam)
+				}
+				op.pathParams[i] = p
+			}
+		}
+	}
+	return op
+}
+
+// openAPITemplateRank 模板中不含参数的路径段数，越多越具体
+func openAPITemplateRank(template string) int {
+	rank := 0
+	for _, segment := range strings.Split(template, "/") {
+		if !strings.Contains(segment, "{") {
+			rank++
+		}
+	}
+	return rank
+}
+
+// openAPIRoutePattern 把路径模板转换为路由规则：{id} 转为 *，{name=shelves/*} 展开为其模式，
+// :cancel 等自定义动词保留为字面量，字面量中的通配符元字符转义；不含参数的模板精确匹配
+func openAPIRoutePattern(template string, methods []string, value any) RoutePattern {
+	if !strings.Contains(template, "{") {
+		return RoutePattern{Kind: RouteMatchExact, Pattern: template, Methods: methods, Value: value}
+	}
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c == '{' {
+			if end := strings.IndexByte(template[i:], '}'); end > 0 {
+				if _, pattern, ok := strings.Cut(template[i+1:i+end], "="); ok {
+					b.WriteString(strings.ReplaceAll(pattern, "**", "*"))
+				} else {
+					b.WriteByte('*')
+				}
+				i += end
+				continue
+			}
+		}
+		if strings.IndexByte(`*?[\`, c) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return RoutePattern{Kind: RouteMatchGlob, Pattern: b.String(), Methods: methods, Value: value}
+}
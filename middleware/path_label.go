@@ -0,0 +1,354 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\path_label.go
+ * @Description: 指标路径标签 - 以路由模板（/api/v1/users/{id}）代替原始路径作为 path 标签：
+ *               grpc-gateway 与 HTTP 多路复用器注册的路由自动识别，代理前缀显式映射，
+ *               其余路径经智能规范化后受基数上限保护，超出上限归入 "other"
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+const (
+	DefaultPathLabelOther      = "other" // 超出基数上限的路径标签
+	DefaultPathLabelMaxUnknown = 500     // 未匹配任何模板的路径最多产生的标签数
+)
+
+// PathLabelConfig 指标路径标签配置
+type PathLabelConfig struct {
+	Templates    []string          // 路径模板：{name} 或 * 匹配一段，{name=**} 或 ** 匹配剩余全部段（只能位于末尾）
+	Prefixes     map[string]string // 代理前缀 -> 标签，如 "/legacy/" -> "/legacy/*"；多个前缀命中时最长者优先
+	MaxUnknown   int               // 未匹配路径的标签数上限，默认 500，负数表示未匹配路径全部归入 OtherLabel
+	OtherLabel   string            // 超出上限的标签，默认 "other"
+	DisableLearn bool              // 未匹配路径不做智能规范化，直接计入上限
+}
+
+// templateNode 路径模板前缀树节点
+type templateNode struct {
+	literals map[string]*templateNode
+	param    *templateNode
+	rest     string // 以 ** 结尾的模板标签
+	label    string // 在此结束的模板标签
+}
+
+// pathLabelRules 编译后的规则，整体替换
+type pathLabelRules struct {
+	templates  *templateNode
+	prefixes   []pathLabelPrefix
+	maxUnknown int
+	other      string
+	learn      bool
+}
+
+// pathLabelPrefix 代理前缀映射
+type pathLabelPrefix struct {
+	prefix string
+	label  string
+}
+
+// PathLabeler 指标路径标签生成器，实现 PathNormalizer，可并发使用
+type PathLabeler struct {
+	rules    atomic.Pointer[pathLabelRules]
+	resolver atomic.Pointer[func(r *http.Request) string]
+	fallback *smartPathNormalizer
+
+	mu      sync.Mutex
+	unknown map[string]struct{} // 已分配的未匹配路径标签
+}
+
+// pathLabelSlotKey 请求上下文中路由模板槽位的键
+type pathLabelSlotKey struct{}
+
+// pathLabelSlot 内层路由匹配后回填的模板
+type pathLabelSlot struct {
+	template string
+}
+
+// paramSegment 模板中的变量段：{id}、{id=*}、{path=**}
+var paramSegment = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_.]*)(=(\*|\*\*))?\}$`)
+
+// NewPathLabeler 创建路径标签生成器，staticPaths 为直接作为标签的静态路径
+func NewPathLabeler(cfg PathLabelConfig, staticPaths []string) (*PathLabeler, error) {
+	l := &PathLabeler{fallback: newSmartPathNormalizer(staticPaths)}
+	if err := l.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Configure 替换模板、前缀映射与基数上限，已分配的未匹配标签清空
+func (l *PathLabeler) Configure(cfg PathLabelConfig) error {
+	rules := &pathLabelRules{
+		templates:  &templateNode{},
+		maxUnknown: cfg.MaxUnknown,
+		other:      cfg.OtherLabel,
+		learn:      !cfg.DisableLearn,
+	}
+	if rules.maxUnknown == 0 {
+		rules.maxUnknown = DefaultPathLabelMaxUnknown
+	}
+	if rules.other == "" {
+		rules.other = DefaultPathLabelOther
+	}
+	for _, tpl := range cfg.Templates {
+		if err := rules.templates.insert(tpl); err != nil {
+			return err
+		}
+	}
+	for prefix, label := range cfg.Prefixes {
+		if prefix == "" || label == "" {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid path label prefix %q → %q", prefix, label)
+		}
+		rules.prefixes = append(rules.prefixes, pathLabelPrefix{prefix: prefix, label: label})
+	}
+	sort.Slice(rules.prefixes, func(i, j int) bool {
+		return len(rules.prefixes[i].prefix) > len(rules.prefixes[j].prefix)
+	})
+
+	l.rules.Store(rules)
+	l.mu.Lock()
+	l.unknown = make(map[string]struct{})
+	l.mu.Unlock()
+	return nil
+}
+
+// AddTemplates 追加路径模板（保留已有模板与前缀映射）
+func (l *PathLabeler) AddTemplates(templates ...string) error {
+	current := l.rules.Load()
+	next := *current
+	next.templates = current.templates.clone()
+	for _, tpl := range templates {
+		if err := next.templates.insert(tpl); err != nil {
+			return err
+		}
+	}
+	l.rules.Store(&next)
+	return nil
+}
+
+// SetRouteResolver 设置路由模板解析函数（如 HTTP 多路复用器的路由模式），返回空串表示未识别
+func (l *PathLabeler) SetRouteResolver(fn func(r *http.Request) string) {
+	if fn == nil {
+		l.resolver.Store(nil)
+		return
+	}
+	l.resolver.Store(&fn)
+}
+
+// Normalize 实现 PathNormalizer：模板 → 前缀映射 → 受上限保护的智能规范化
+func (l *PathLabeler) Normalize(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	rules := l.rules.Load()
+	if label, ok := rules.templates.match(path); ok {
+		return label
+	}
+	if label, ok := rules.matchPrefix(path); ok {
+		return label
+	}
+	return l.guard(rules, path)
+}
+
+// Label 请求的路径标签：显式模板 → 内层路由回填的模板 → 前缀映射 → 路由解析函数 → 受上限保护的智能规范化
+func (l *PathLabeler) Label(r *http.Request) string {
+	rules := l.rules.Load()
+	path := r.URL.Path
+	if label, ok := rules.templates.match(path); ok {
+		return label
+	}
+	if slot, ok := r.Context().Value(pathLabelSlotKey{}).(*pathLabelSlot); ok && slot.template != "" {
+		return slot.template
+	}
+	if label, ok := rules.matchPrefix(path); ok {
+		return label
+	}
+	if fn := l.resolver.Load(); fn != nil {
+		if label := (*fn)(r); label != "" {
+			return label
+		}
+	}
+	return l.guard(rules, path)
+}
+
+// WithPathLabelSlot 在请求上下文中放置模板槽位，内层路由匹配后回填；指标中间件在调用后续处理器前使用
+func WithPathLabelSlot(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(pathLabelSlotKey{}).(*pathLabelSlot); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), pathLabelSlotKey{}, &pathLabelSlot{}))
+}
+
+// RecordPathTemplate 回填命中的路由模板（自定义路由器使用）
+func RecordPathTemplate(ctx context.Context, template string) {
+	if slot, ok := ctx.Value(pathLabelSlotKey{}).(*pathLabelSlot); ok {
+		slot.template = template
+	}
+}
+
+// PathPatternCaptureMiddleware grpc-gateway 中间件：把命中路由的 HTTP 规则模板回填给指标中间件
+func PathPatternCaptureMiddleware() runtime.Middleware {
+	return func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+				RecordPathTemplate(r.Context(), templateFromPattern(pattern.String()))
+			}
+			next(w, r, pathParams)
+		}
+	}
+}
+
+// templateFromPattern grpc-gateway 模式字符串转为模板：{id=*} → {id}
+func templateFromPattern(pattern string) string {
+	return strings.ReplaceAll(pattern, "=*}", "}")
+}
+
+// guard 未匹配路径：智能规范化后受基数上限保护
+func (l *PathLabeler) guard(rules *pathLabelRules, path string) string {
+	if rules.maxUnknown < 0 {
+		return rules.other
+	}
+	label := path
+	if rules.learn {
+		label = l.fallback.Normalize(path)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.unknown[label]; ok {
+		return label
+	}
+	if len(l.unknown) >= rules.maxUnknown {
+		return rules.other
+	}
+	l.unknown[label] = struct{}{}
+	return label
+}
+
+// matchPrefix 最长前缀映射
+func (r *pathLabelRules) matchPrefix(path string) (string, bool) {
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(path, p.prefix) {
+			return p.label, true
+		}
+	}
+	return "", false
+}
+
+// insert 插入模板
+func (n *templateNode) insert(tpl string) error {
+	if !strings.HasPrefix(tpl, "/") {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "path template %q must start with /", tpl)
+	}
+	label := templateFromPattern(tpl)
+	node := n
+	var segments []string
+	if trimmed := strings.Trim(tpl, "/"); trimmed != "" {
+		segments = strings.Split(trimmed, "/")
+	}
+	for i, seg := range segments {
+		isRest := seg == "**"
+		isParam := seg == "*"
+		if m := paramSegment.FindStringSubmatch(seg); m != nil {
+			isRest, isParam = m[3] == "**", m[3] != "**"
+		} else if strings.ContainsAny(seg, "{}") {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "path template %q has invalid segment %q", tpl, seg)
+		}
+		switch {
+		case isRest:
+			if i != len(segments)-1 {
+				return errors.NewErrorf(errors.ErrCodeInvalidParameter, "path template %q: ** must be the last segment", tpl)
+			}
+			node.rest = label
+			return nil
+		case isParam:
+			if node.param == nil {
+				node.param = &templateNode{}
+			}
+			node = node.param
+		default:
+			if node.literals == nil {
+				node.literals = make(map[string]*templateNode)
+			}
+			child, ok := node.literals[seg]
+			if !ok {
+				child = &templateNode{}
+				node.literals[seg] = child
+			}
+			node = child
+		}
+	}
+	node.label = label
+	return nil
+}
+
+// match 匹配路径，字面量优先于变量段，变量段优先于 **
+func (n *templateNode) match(path string) (string, bool) {
+	if n == nil || (n.literals == nil && n.param == nil && n.rest == "") {
+		return "", false
+	}
+	var segments []string
+	if trimmed := strings.Trim(path, "/"); trimmed != "" {
+		segments = strings.Split(trimmed, "/")
+	}
+	return n.matchSegments(segments)
+}
+
+// matchSegments 递归匹配剩余路径段
+func (n *templateNode) matchSegments(segments []string) (string, bool) {
+	if len(segments) == 0 {
+		if n.label != "" {
+			return n.label, true
+		}
+		if n.rest != "" {
+			return n.rest, true
+		}
+		return "", false
+	}
+	if child, ok := n.literals[segments[0]]; ok {
+		if label, ok := child.matchSegments(segments[1:]); ok {
+			return label, true
+		}
+	}
+	if n.param != nil && segments[0] != "" {
+		if label, ok := n.param.matchSegments(segments[1:]); ok {
+			return label, true
+		}
+	}
+	if n.rest != "" {
+		return n.rest, true
+	}
+	return "", false
+}
+
+// clone 深拷贝
+func (n *templateNode) clone() *templateNode {
+	c := &templateNode{rest: n.rest, label: n.label}
+	if n.param != nil {
+		c.param = n.param.clone()
+	}
+	if n.literals != nil {
+		c.literals = make(map[string]*templateNode, len(n.literals))
+		for k, v := range n.literals {
+			c.literals[k] = v.clone()
+		}
+	}
+	return c
+}
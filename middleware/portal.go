@@ -0,0 +1,300 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\portal.go
+ * @Description: 开发者门户 - 基于 Swagger 聚合的服务目录与按服务的文档（可 Try it out），
+ *               已登录的开发者自助申请 / 吊销 API Key 并查看每个 Key 的按天调用量；
+ *               全部页面与接口要求已认证的调用方（可再限定角色）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// 开发者门户默认值
+const (
+	DefaultPortalPath       = "/portal"
+	DefaultPortalTitle      = "Developer Portal"
+	DefaultPortalUsageDays  = 14
+	DefaultPortalCatalogTTL = time.Minute
+	portalMaxUsageDays      = 90
+	portalMaxRequestBytes   = 4 << 10
+)
+
+// PortalConfig 开发者门户配置
+type PortalConfig struct {
+	Path       string        // 门户路径，默认 /portal
+	Title      string        // 页面标题，默认 Developer Portal
+	Roles      []string      // 调用方需具备其中任一角色，为空时只要求已认证；身份由认证中间件写入
+	Keys       *APIKeys      // API Key 管理器（需同时加入认证器链才能用于调用），为空时不提供 Key 自助服务
+	UsageDays  int           // 用量看板展示的天数，默认 14
+	CatalogTTL time.Duration // 服务目录缓存时长，默认 1m；聚合模式下每次刷新会重新拉取各服务的规范
+
+	// Swagger UI 静态资源地址，为空时使用 swagger 配置中的 CDN 地址
+	SwaggerUICSS      string
+	SwaggerUIBundleJS string
+	SwaggerUIPresetJS string
+}
+
+// PortalService 目录中的服务
+type PortalService struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Version     string         `json:"version,omitempty"`
+	BasePath    string         `json:"base_path,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Operations  int            `json:"operations"`
+	Spec        map[string]any `json:"-"`
+}
+
+// PortalCatalog 提供最新的服务目录（含各服务的 Swagger / OpenAPI 规范）
+type PortalCatalog func() ([]PortalService, error)
+
+// Portal 开发者门户
+type Portal struct {
+	config  PortalConfig
+	catalog PortalCatalog
+
+	mu       sync.Mutex
+	services []PortalService
+	loadedAt time.Time
+}
+
+// NewPortal 创建开发者门户
+func NewPortal(cfg PortalConfig, catalog PortalCatalog) (*Portal, error) {
+	if catalog == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "portal: catalog is required")
+	}
+	cfg.Path = strings.TrimSuffix(cfg.Path, "/")
+	if cfg.Path == "" {
+		cfg.Path = DefaultPortalPath
+	}
+	if cfg.Title == "" {
+		cfg.Title = DefaultPortalTitle
+	}
+	if cfg.UsageDays <= 0 {
+		cfg.UsageDays = DefaultPortalUsageDays
+	}
+	cfg.UsageDays = min(cfg.UsageDays, portalMaxUsageDays)
+	if cfg.CatalogTTL <= 0 {
+		cfg.CatalogTTL = DefaultPortalCatalogTTL
+	}
+	return &Portal{config: cfg, catalog: catalog}, nil
+}
+
+// AdminPath 门户路径
+func (p *Portal) AdminPath() string {
+	return p.config.Path
+}
+
+// Services 服务目录，按名称排序；缓存过期后重新加载，加载失败时沿用上次的结果
+func (p *Portal) Services() ([]PortalService, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.services != nil && time.Since(p.loadedAt) < p.config.CatalogTTL {
+		return p.services, nil
+	}
+	services, err := p.catalog()
+	if err != nil {
+		if p.services != nil {
+			return p.services, nil
+		}
+		return nil, err
+	}
+	for i := range services {
+		services[i].Operations = countOperations(services[i].Spec)
+	}
+	slices.SortFunc(services, func(a, b PortalService) int { return strings.Compare(a.Name, b.Name) })
+	p.services, p.loadedAt = services, time.Now()
+	return services, nil
+}
+
+// service 按名称查找服务
+func (p *Portal) service(name string) (*PortalService, *errors.AppError) {
+	services, err := p.Services()
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "portal: load catalog: %v", err)
+	}
+	idx := slices.IndexFunc(services, func(s PortalService) bool { return s.Name == name })
+	if idx < 0 {
+		return nil, errors.NewErrorf(errors.ErrCodeNotFound, "service %s not found", name)
+	}
+	return &services[idx], nil
+}
+
+// countOperations 规范中的操作数
+func countOperations(spec map[string]any) int {
+	paths, _ := spec["paths"].(map[string]any)
+	n := 0
+	for _, item := range paths {
+		ops, _ := item.(map[string]any)
+		for method := range ops {
+			if method != "parameters" && !strings.HasPrefix(method, "x-") {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// AdminHandler 门户页面与接口：
+//
+//	GET    {path}/                          服务目录与 API Key 看板
+//	GET    {path}/services/{name}           服务文档（Swagger UI，可填入 API Key 直接调试）
+//	GET    {path}/api/services              服务目录
+//	GET    {path}/api/services/{name}/spec  服务规范
+//	GET    {path}/api/keys                  当前用户的 Key
+//	POST   {path}/api/keys                  申请 Key，响应中的明文 Key 只返回这一次
+//	DELETE {path}/api/keys/{id}             吊销 Key
+//	GET    {path}/api/keys/{id}/usage       Key 的按天用量，?days= 默认 UsageDays
+func (p *Portal) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := contextPrincipal(r.Context())
+		if principal.IsAnonymous() {
+			response.WriteAppError(w, errors.ErrUnauthorized)
+			return
+		}
+		if !principal.HasAnyRole(p.config.Roles...) {
+			response.WriteAppError(w, errors.ErrForbidden)
+			return
+		}
+
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, p.config.Path), "/")
+		segments := strings.Split(rest, "/")
+		for i, segment := range segments {
+			if unescaped, err := url.PathUnescape(segment); err == nil {
+				segments[i] = unescaped
+			}
+		}
+
+		switch {
+		case rest == "" && r.Method == http.MethodGet:
+			p.writeIndex(w, principal)
+		case len(segments) == 2 && segments[0] == "services" && r.Method == http.MethodGet:
+			p.writeServiceDocs(w, segments[1])
+		case len(segments) >= 2 && segments[0] == "api" && segments[1] == "services":
+			p.handleServices(w, r, segments[2:])
+		case len(segments) >= 2 && segments[0] == "api" && segments[1] == "keys":
+			p.handleKeys(w, r, principal, segments[2:])
+		default:
+			response.WriteNotFoundResult(w, "unknown portal endpoint")
+		}
+	}
+}
+
+// handleServices 服务目录接口
+func (p *Portal) handleServices(w http.ResponseWriter, r *http.Request, segments []string) {
+	if r.Method != http.MethodGet {
+		response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		return
+	}
+	switch {
+	case len(segments) == 0:
+		services, err := p.Services()
+		if err != nil {
+			response.WriteServiceUnavailableResult(w, "portal: load catalog: "+err.Error())
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, services)
+	case len(segments) == 2 && segments[1] == "spec":
+		svc, appErr := p.service(segments[0])
+		if appErr != nil {
+			response.WriteAppError(w, appErr)
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, svc.Spec)
+	default:
+		response.WriteNotFoundResult(w, "unknown portal endpoint")
+	}
+}
+
+// handleKeys API Key 自助服务接口；通过 API Key 认证的调用方不能管理 Key，避免泄漏的 Key 自我繁殖
+func (p *Portal) handleKeys(w http.ResponseWriter, r *http.Request, principal *Principal, segments []string) {
+	keys := p.config.Keys
+	if keys == nil {
+		response.WriteNotFoundResult(w, "api key self-service is not enabled")
+		return
+	}
+	if principal.AuthType == PrincipalAuthAPIKey {
+		response.WriteForbiddenResult(w, "api keys cannot be managed with an api key")
+		return
+	}
+
+	ctx := r.Context()
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		list, err := keys.List(ctx, principal.Subject)
+		if err != nil {
+			response.WriteServiceUnavailableResult(w, "api key store: "+err.Error())
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, map[string]any{
+			"keys":   list,
+			"scopes": keys.Scopes(),
+			"header": keys.Header(),
+		})
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		var req APIKeyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, portalMaxRequestBytes)).Decode(&req); err != nil {
+			response.WriteBadRequestResult(w, "invalid api key request: "+err.Error())
+			return
+		}
+		plain, key, appErr := keys.Create(ctx, principal, req)
+		if appErr != nil {
+			response.WriteAppError(w, appErr)
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusCreated, map[string]any{"key": plain, "api_key": key})
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		if appErr := keys.Revoke(ctx, principal.Subject, segments[0]); appErr != nil {
+			response.WriteAppError(w, appErr)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(segments) == 2 && segments[1] == "usage" && r.Method == http.MethodGet:
+		p.writeUsage(w, r, principal, segments[0])
+	case len(segments) <= 2:
+		response.WriteAppError(w, errors.ErrMethodNotAllowed)
+	default:
+		response.WriteNotFoundResult(w, "unknown portal endpoint")
+	}
+}
+
+// writeUsage Key 的按天用量，只能查询自己的 Key
+func (p *Portal) writeUsage(w http.ResponseWriter, r *http.Request, principal *Principal, id string) {
+	list, err := p.config.Keys.List(r.Context(), principal.Subject)
+	if err != nil {
+		response.WriteServiceUnavailableResult(w, "api key store: "+err.Error())
+		return
+	}
+	if !slices.ContainsFunc(list, func(key *APIKey) bool { return key.ID == id }) {
+		response.WriteNotFoundResult(w, "api key "+id+" not found")
+		return
+	}
+	days := p.config.UsageDays
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = min(v, portalMaxUsageDays)
+	}
+	usage, err := p.config.Keys.Usage(r.Context(), id, days)
+	if err != nil {
+		response.WriteServiceUnavailableResult(w, "api key store: "+err.Error())
+		return
+	}
+	response.WriteJSONResponse(w, http.StatusOK, usage)
+}
@@ -0,0 +1,270 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\portal_pages.go
+ * @Description: 开发者门户页面 - 服务目录与 Key 看板为单页，Key 的申请、吊销与用量通过门户 JSON 接口完成；
+ *               服务文档页复用 Swagger UI，Try it out 的请求自动带上所选 API Key
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// portalIndexData 首页数据
+type portalIndexData struct {
+	Title       string
+	Base        string
+	Subject     string
+	Services    []PortalService
+	Error       string
+	KeysEnabled bool
+	UsageDays   int
+}
+
+// portalDocsData 服务文档页数据
+type portalDocsData struct {
+	Title    string
+	Base     string
+	Service  PortalService
+	SpecURL  string
+	Header   string
+	CSS      string
+	BundleJS string
+	PresetJS string
+}
+
+// writeIndex 渲染首页
+func (p *Portal) writeIndex(w http.ResponseWriter, principal *Principal) {
+	data := portalIndexData{
+		Title:       p.config.Title,
+		Base:        p.config.Path,
+		Subject:     principal.Subject,
+		KeysEnabled: p.config.Keys != nil && principal.AuthType != PrincipalAuthAPIKey,
+		UsageDays:   p.config.UsageDays,
+	}
+	services, err := p.Services()
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Services = services
+	writePortalPage(w, portalIndexTemplate, data)
+}
+
+// writeServiceDocs 渲染服务文档页
+func (p *Portal) writeServiceDocs(w http.ResponseWriter, name string) {
+	svc, appErr := p.service(name)
+	if appErr != nil {
+		response.WriteAppError(w, appErr)
+		return
+	}
+	data := portalDocsData{
+		Title:    p.config.Title,
+		Base:     p.config.Path,
+		Service:  *svc,
+		SpecURL:  p.config.Path + "/api/services/" + url.PathEscape(svc.Name) + "/spec",
+		Header:   DefaultAPIKeyHeader,
+		CSS:      p.config.SwaggerUICSS,
+		BundleJS: p.config.SwaggerUIBundleJS,
+		PresetJS: p.config.SwaggerUIPresetJS,
+	}
+	if p.config.Keys != nil {
+		data.Header = p.config.Keys.Header()
+	}
+	writePortalPage(w, portalDocsTemplate, data)
+}
+
+// writePortalPage 渲染页面，先写入缓冲区以便模板出错时返回 500
+func writePortalPage(w http.ResponseWriter, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		response.WriteInternalServerErrorResult(w, "portal: render page: "+err.Error())
+		return
+	}
+	w.Header().Set(constants.HeaderContentType, "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// portalStyle 页面公共样式
+const portalStyle = `
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 0; color: #1f2328; background: #f6f8fa; }
+header { background: #24292f; color: #fff; padding: 12px 24px; display: flex; justify-content: space-between; align-items: center; }
+header a { color: #fff; text-decoration: none; font-weight: 600; }
+main { max-width: 1080px; margin: 24px auto; padding: 0 24px; }
+section { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: 16px 20px; margin-bottom: 24px; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 6px 8px; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+.muted { color: #656d76; font-size: 0.9em; }
+.error { color: #cf222e; }
+.secret { font-family: monospace; background: #fff8c5; padding: 8px; border-radius: 4px; word-break: break-all; }
+.bars { display: flex; align-items: flex-end; gap: 2px; height: 32px; }
+.bars span { width: 6px; background: #2da44e; min-height: 1px; }
+button { cursor: pointer; }
+`
+
+// portalIndexTemplate 首页：服务目录 + API Key 看板
+var portalIndexTemplate = template.Must(template.New("portal-index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>` + portalStyle + `</style>
+</head>
+<body>
+<header><a href="{{.Base}}/">{{.Title}}</a><span>{{.Subject}}</span></header>
+<main>
+<section>
+<h2>Services</h2>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<table>
+<tr><th>Service</th><th>Description</th><th>Version</th><th>Operations</th></tr>
+{{range .Services}}<tr>
+<td><a href="{{$.Base}}/services/{{.Name}}">{{.Name}}</a>{{if .BasePath}}<div class="muted">{{.BasePath}}</div>{{end}}</td>
+<td>{{.Description}}</td><td>{{.Version}}</td><td>{{.Operations}}</td>
+</tr>{{else}}<tr><td colspan="4" class="muted">No services</td></tr>{{end}}
+</table>
+</section>
+{{if .KeysEnabled}}
+<section>
+<h2>API Keys</h2>
+<form id="create">
+<input name="name" placeholder="Key name" required maxlength="64">
+<span id="scopes"></span>
+<input name="ttl_days" type="number" min="0" placeholder="Expires in days">
+<button type="submit">Create key</button>
+</form>
+<div id="created" hidden><p>Copy the key now, it will not be shown again:</p><div class="secret" id="secret"></div></div>
+<p class="error" id="key-error"></p>
+<table>
+<thead><tr><th>Name</th><th>Key</th><th>Scopes</th><th>Expires</th><th>Last {{.UsageDays}} days</th><th></th></tr></thead>
+<tbody id="keys"></tbody>
+</table>
+</section>
+<script>
+const base = {{.Base}} + "/api/keys";
+const days = {{.UsageDays}};
+const text = (tag, value, cls) => { const el = document.createElement(tag); el.textContent = value ?? ""; if (cls) el.className = cls; return el; };
+async function call(method, url, body) {
+  const res = await fetch(url, { method, credentials: "same-origin", headers: body ? { "Content-Type": "application/json" } : {}, body: body ? JSON.stringify(body) : undefined });
+  const data = res.status === 204 ? null : await res.json().catch(() => null);
+  if (!res.ok) throw new Error((data && (data.message || data.error)) || res.statusText);
+  return data;
+}
+async function usageCell(key) {
+  const cell = document.createElement("td");
+  try {
+    const usage = await call("GET", base + "/" + encodeURIComponent(key.id) + "/usage?days=" + days);
+    const max = Math.max(1, ...usage.daily.map(d => d.requests));
+    const bars = text("div", "", "bars");
+    for (const d of usage.daily) {
+      const bar = document.createElement("span");
+      bar.style.height = (100 * d.requests / max) + "%";
+      bar.title = d.date + ": " + d.requests;
+      bars.append(bar);
+    }
+    cell.append(bars, text("div", usage.total + " requests" + (usage.last_used_at ? ", last used " + new Date(usage.last_used_at).toLocaleString() : ""), "muted"));
+  } catch (e) {
+    cell.append(text("span", e.message, "error"));
+  }
+  return cell;
+}
+async function load() {
+  const data = await call("GET", base);
+  const scopes = document.getElementById("scopes");
+  scopes.replaceChildren(...(data.scopes || []).map(s => { const l = text("label", " " + s + " "); const c = document.createElement("input"); c.type = "checkbox"; c.name = "scope"; c.value = s; l.prepend(c); return l; }));
+  const rows = await Promise.all((data.keys || []).map(async key => {
+    const tr = document.createElement("tr");
+    const state = key.revoked_at ? "revoked" : (key.expires_at && new Date(key.expires_at) < new Date() ? "expired" : "");
+    tr.append(text("td", key.name + (state ? " (" + state + ")" : "")), text("td", key.hint + "…", "muted"), text("td", (key.scopes || []).join(", ")),
+      text("td", key.expires_at ? new Date(key.expires_at).toLocaleDateString() : "never"), await usageCell(key));
+    const action = document.createElement("td");
+    if (!state) {
+      const revoke = text("button", "Revoke");
+      revoke.onclick = async () => { if (confirm("Revoke " + key.name + "?")) { await call("DELETE", base + "/" + encodeURIComponent(key.id)); load(); } };
+      action.append(revoke);
+    }
+    tr.append(action);
+    return tr;
+  }));
+  document.getElementById("keys").replaceChildren(...rows);
+}
+document.getElementById("create").onsubmit = async (e) => {
+  e.preventDefault();
+  const form = new FormData(e.target);
+  document.getElementById("key-error").textContent = "";
+  try {
+    const created = await call("POST", base, { name: form.get("name"), scopes: form.getAll("scope"), ttl_seconds: Number(form.get("ttl_days") || 0) * 86400 });
+    document.getElementById("secret").textContent = created.key;
+    document.getElementById("created").hidden = false;
+    e.target.reset();
+    load();
+  } catch (err) {
+    document.getElementById("key-error").textContent = err.message;
+  }
+};
+load().catch(e => { document.getElementById("key-error").textContent = e.message; });
+</script>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// portalDocsTemplate 服务文档页：Swagger UI，Try it out 的请求带上页面中填写的 API Key（仅保存在当前会话）
+var portalDocsTemplate = template.Must(template.New("portal-docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Service.Name}} - {{.Title}}</title>
+<link rel="stylesheet" href="{{.CSS}}">
+<style>` + portalStyle + `</style>
+</head>
+<body>
+<header><a href="{{.Base}}/">{{.Title}}</a><span>{{.Service.Name}}{{if .Service.Version}} {{.Service.Version}}{{end}}</span></header>
+<main>
+<section>
+<label>{{.Header}}: <input id="api-key" size="60" placeholder="Paste an API key to try requests with it"></label>
+<span class="muted">Kept in this browser session only.</span>
+</section>
+<div id="swagger-ui"></div>
+</main>
+<script src="{{.BundleJS}}"></script>
+<script src="{{.PresetJS}}"></script>
+<script>
+const header = {{.Header}};
+const storageKey = "portal-api-key";
+const input = document.getElementById("api-key");
+input.value = sessionStorage.getItem(storageKey) || "";
+input.oninput = () => sessionStorage.setItem(storageKey, input.value.trim());
+window.ui = SwaggerUIBundle({
+  url: {{.SpecURL}},
+  dom_id: "#swagger-ui",
+  deepLinking: true,
+  tryItOutEnabled: true,
+  presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+  layout: "BaseLayout",
+  requestInterceptor: (req) => {
+    const key = input.value.trim();
+    if (key && req.url !== new URL({{.SpecURL}}, location.href).href) req.headers[header] = key;
+    return req;
+  },
+});
+</script>
+</body>
+</html>
+`))
@@ -14,6 +14,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"runtime"
@@ -186,10 +187,18 @@ func registerPProfHandlers(mux *http.ServeMux, pathPrefix string) {
 // StartPProfServer 启动独立的pprof服务器（在单独的端口）
 // 这个函数应该在 goroutine 中调用
 func (s *PProfServer) Start() error {
+	return s.Serve(nil)
+}
+
+// Serve 在已绑定的监听器上启动pprof服务器，listener 为 nil 时按配置端口监听
+func (s *PProfServer) Serve(listener net.Listener) error {
 	s.mu.Lock()
 	cfg := s.cfg
 	if cfg == nil || !cfg.Enabled {
 		s.mu.Unlock()
+		if listener != nil {
+			_ = listener.Close()
+		}
 		return nil
 	}
 
@@ -217,7 +226,13 @@ func (s *PProfServer) Start() error {
 		"address", addr,
 		"path", cfg.PathPrefix)
 
-	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+	}
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
@@ -0,0 +1,220 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\principal.go
+ * @Description: 统一身份模型 - 认证中间件 / 拦截器产生 Principal，授权、限流、审计在 HTTP 与 gRPC 上读取同一份身份；
+ *               提供与 gRPC metadata、HTTP 请求头的相互转换
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"google.golang.org/grpc/metadata"
+)
+
+// 内置认证方式（Principal.AuthType）
+const (
+	PrincipalAuthExtAuthz = "ext_authz" // 外部授权服务返回的身份
+	PrincipalAuthCustom   = "custom"    // 认证器未声明认证方式
+	PrincipalAuthInternal = "internal"  // 网关进程内发起的合成请求（如定时调用）
+	PrincipalAuthAPIKey   = "api_key"   // 网关签发的 API Key
+)
+
+// Principal 请求的调用方身份
+type Principal struct {
+	Subject    string            `json:"subject"`              // 主体（用户 ID、服务账号等）
+	Roles      []string          `json:"roles,omitempty"`      // 角色
+	Scopes     []string          `json:"scopes,omitempty"`     // 授权范围
+	Tenant     string            `json:"tenant,omitempty"`     // 租户
+	AuthType   string            `json:"auth_type,omitempty"`  // 认证方式：jwt、mtls、api_key、ext_authz 等
+	Attributes map[string]string `json:"attributes,omitempty"` // 附加声明，仅在进程内传递，不写入 metadata
+}
+
+// principalKey 请求上下文中身份的键
+type principalKey struct{}
+
+// principalHeaders 转发身份使用的请求头（小写，与 metadata 键一致），认证前从客户端请求中剔除
+var principalHeaders = []string{
+	constants.MetadataPrincipalRoles,
+	constants.MetadataPrincipalScope,
+	constants.MetadataPrincipalAuth,
+}
+
+// IsAnonymous 是否为匿名身份
+func (p *Principal) IsAnonymous() bool {
+	return p == nil || p.Subject == ""
+}
+
+// HasRole 是否具备角色
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasAnyRole 是否具备任一角色，roles 为空时返回 true
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScopes 是否具备全部授权范围
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if p == nil || !slices.Contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithPrincipal 在上下文中设置身份，同步 RequestCommonMeta 的用户 ID、角色与租户，
+// 按用户限流、访问日志与 Casbin 默认 subject 因此读取到同一身份
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	if p == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	ctx = WithUserID(ctx, p.Subject)
+	if len(p.Roles) > 0 {
+		ctx = WithRoleCode(ctx, p.Roles[0])
+	}
+	if p.Tenant != "" {
+		ctx = WithTenantID(ctx, p.Tenant)
+	}
+	return ctx
+}
+
+// PrincipalFromContext 读取上下文中的身份
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// contextPrincipal 上下文中的身份，没有时为 nil
+func contextPrincipal(ctx context.Context) *Principal {
+	p, _ := PrincipalFromContext(ctx)
+	return p
+}
+
+// internalPrincipalKey 进程内合成请求的身份，只能由网关代码写入，外部请求无法伪造
+type internalPrincipalKey struct{}
+
+// withInternalPrincipal 为合成请求设置身份，认证中间件直接采用而不再调用认证器
+func withInternalPrincipal(ctx context.Context, p *Principal) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return WithPrincipal(context.WithValue(ctx, internalPrincipalKey{}, p), p)
+}
+
+// internalPrincipal 读取合成请求的身份
+func internalPrincipal(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(internalPrincipalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// PrincipalToMetadata 身份转为 gRPC metadata：主体与租户沿用 x-user-id / x-tenant-id，角色逗号分隔，授权范围空格分隔
+func PrincipalToMetadata(p *Principal) metadata.MD {
+	md := metadata.MD{}
+	if p == nil {
+		return md
+	}
+	set := func(key, value string) {
+		if value != "" {
+			md.Set(key, value)
+		}
+	}
+	set(constants.MetadataUserID, p.Subject)
+	set(constants.MetadataTenantID, p.Tenant)
+	set(constants.MetadataPrincipalRoles, strings.Join(p.Roles, ","))
+	set(constants.MetadataPrincipalScope, strings.Join(p.Scopes, " "))
+	set(constants.MetadataPrincipalAuth, p.AuthType)
+	return md
+}
+
+// PrincipalFromMetadata 由 metadata 还原身份；主体或认证方式缺失时返回 false
+// 网关认证时会剔除客户端自带的 x-principal-* 头，只应信任来自可信调用方（如上游网关）的 metadata
+func PrincipalFromMetadata(md metadata.MD) (*Principal, bool) {
+	return principalFromLookup(func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	})
+}
+
+// PrincipalToHeader 身份写入 HTTP 请求头（覆盖同名头），经 grpc-gateway 转发后即为 PrincipalToMetadata 的结果
+func PrincipalToHeader(p *Principal, h http.Header) {
+	for key, values := range PrincipalToMetadata(p) {
+		h[http.CanonicalHeaderKey(key)] = values
+	}
+}
+
+// PrincipalFromHeader 由 HTTP 请求头还原身份，信任规则同 PrincipalFromMetadata
+func PrincipalFromHeader(h http.Header) (*Principal, bool) {
+	return principalFromLookup(h.Get)
+}
+
+// AppendPrincipalToOutgoingContext 把上下文中的身份写入 outgoing metadata（覆盖同名键），调用下游 gRPC 服务时使用
+func AppendPrincipalToOutgoingContext(ctx context.Context) context.Context {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for _, name := range principalHeaders {
+		md.Delete(name)
+	}
+	for key, values := range PrincipalToMetadata(p) {
+		md[key] = values
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// principalFromLookup 按 metadata 键读取身份
+func principalFromLookup(get func(name string) string) (*Principal, bool) {
+	p := &Principal{
+		Subject:  get(constants.MetadataUserID),
+		Tenant:   get(constants.MetadataTenantID),
+		AuthType: get(constants.MetadataPrincipalAuth),
+	}
+	if p.Subject == "" || p.AuthType == "" {
+		return nil, false
+	}
+	if roles := get(constants.MetadataPrincipalRoles); roles != "" {
+		p.Roles = splitTrim(roles, ",")
+	}
+	if scopes := get(constants.MetadataPrincipalScope); scopes != "" {
+		p.Scopes = strings.Fields(scopes)
+	}
+	return p, true
+}
+
+// splitTrim 按分隔符切分并去除空白与空项
+func splitTrim(s, sep string) []string {
+	parts := strings.Split(s, sep)
+	out := parts[:0]
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,336 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\priority_limit.go
+ * @Description: 优先级并发限制 - 按路由 / 请求头把请求划分为优先级类别，并发数饱和时各类别有界排队，
+ *               释放的并发槽位优先交给高优先级请求，低优先级请求先排队或被丢弃
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultPriorityClass        = "default"
+	DefaultPriorityQueueTimeout = time.Second
+)
+
+// PriorityClass 优先级类别
+type PriorityClass struct {
+	Name         string        // 类别名
+	Priority     int           // 优先级，越大越先获得释放的并发槽位
+	MaxQueue     int           // 并发饱和时的排队上限，0 不排队直接丢弃
+	QueueTimeout time.Duration // 最长排队时间，默认 1s
+	Bypass       bool          // 不受并发限制（健康检查等），不占用并发槽位
+}
+
+// PriorityRule 类别分配规则，按顺序匹配，各条件均需满足（未设置的条件视为满足）
+type PriorityRule struct {
+	Class   string   // 分配的类别
+	Paths   []string // HTTP 路径或 gRPC 完整方法名，以 * 结尾表示前缀匹配
+	Methods []string // HTTP 方法
+	Header  string   // 请求头（gRPC 为 metadata）名称
+	Values  []string // 请求头取值，为空时只要求请求头存在
+}
+
+// PriorityLimitConfig 优先级并发限制配置
+type PriorityLimitConfig struct {
+	MaxConcurrent int             // 最大并发请求数，必填
+	Classes       []PriorityClass // 类别定义；未定义 DefaultClass 时自动补充一个不排队的默认类别
+	Rules         []PriorityRule  // 类别分配规则
+	DefaultClass  string          // 未匹配任何规则的请求所属类别，默认 default
+}
+
+// 优先级并发限制指标
+var (
+	priorityRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_priority_requests_total",
+		Help: "Total number of requests handled by the priority concurrency limiter",
+	}, []string{"class", "result"})
+
+	priorityQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_priority_queue_length",
+		Help: "Number of requests waiting in the priority concurrency limiter queue",
+	}, []string{"class"})
+
+	priorityInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_priority_inflight_requests",
+		Help: "Number of requests holding a priority concurrency limiter slot",
+	})
+)
+
+// priorityClass 编译后的类别
+type priorityClass struct {
+	PriorityClass
+	queue *list.List // *priorityWaiter，先进先出
+	gauge prometheus.Gauge
+}
+
+// priorityWaiter 排队中的请求
+type priorityWaiter struct {
+	ready   chan struct{} // 获得槽位时关闭
+	granted bool
+}
+
+// priorityMatcher 编译后的分配规则
+type priorityMatcher struct {
+	class   *priorityClass
+	paths   *RouteTable
+	methods []string
+	header  string
+	values  []string
+}
+
+// PriorityLimiter 优先级并发限制器
+type PriorityLimiter struct {
+	max      int
+	classes  []*priorityClass // 按优先级降序
+	byName   map[string]*priorityClass
+	rules    []priorityMatcher
+	fallback *priorityClass
+
+	mu       sync.Mutex
+	inflight int
+}
+
+// NewPriorityLimiter 校验配置并创建优先级并发限制器
+func NewPriorityLimiter(cfg PriorityLimitConfig) (*PriorityLimiter, error) {
+	if cfg.MaxConcurrent <= 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "priority limiter requires a positive max concurrent")
+	}
+	if cfg.DefaultClass == "" {
+		cfg.DefaultClass = DefaultPriorityClass
+	}
+
+	l := &PriorityLimiter{max: cfg.MaxConcurrent, byName: make(map[string]*priorityClass, len(cfg.Classes)+1)}
+	classes := cfg.Classes
+	if !slices.ContainsFunc(classes, func(c PriorityClass) bool { return c.Name == cfg.DefaultClass }) {
+		classes = append(slices.Clone(classes), PriorityClass{Name: cfg.DefaultClass})
+	}
+	for _, c := range classes {
+		if c.Name == "" || l.byName[c.Name] != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "priority class name %q is empty or duplicated", c.Name)
+		}
+		if c.MaxQueue < 0 || c.QueueTimeout < 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "priority class %q queue settings must not be negative", c.Name)
+		}
+		if c.QueueTimeout == 0 {
+			c.QueueTimeout = DefaultPriorityQueueTimeout
+		}
+		pc := &priorityClass{PriorityClass: c, queue: list.New(), gauge: priorityQueueLength.WithLabelValues(c.Name)}
+		l.classes = append(l.classes, pc)
+		l.byName[c.Name] = pc
+	}
+	slices.SortStableFunc(l.classes, func(a, b *priorityClass) int { return b.Priority - a.Priority })
+	l.fallback = l.byName[cfg.DefaultClass]
+
+	for i, rule := range cfg.Rules {
+		class := l.byName[rule.Class]
+		if class == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "priority rule %d references unknown class %q", i, rule.Class)
+		}
+		m := priorityMatcher{class: class, header: rule.Header, values: rule.Values}
+		for _, method := range rule.Methods {
+			m.methods = append(m.methods, strings.ToUpper(method))
+		}
+		if len(rule.Paths) > 0 {
+			patterns := make([]RoutePattern, 0, len(rule.Paths))
+			for _, path := range rule.Paths {
+				p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Value: true}
+				if prefix, ok := strings.CutSuffix(path, "*"); ok {
+					p.Kind, p.Pattern = RouteMatchPrefix, prefix
+				}
+				patterns = append(patterns, p)
+			}
+			m.paths = NewRouteTable(patterns)
+		}
+		l.rules = append(l.rules, m)
+	}
+	return l, nil
+}
+
+// classify 按规则确定请求类别，header 返回请求头的全部取值
+func (l *PriorityLimiter) classify(method, path string, header func(name string) []string) *priorityClass {
+	for _, m := range l.rules {
+		if len(m.methods) > 0 && !slices.Contains(m.methods, method) {
+			continue
+		}
+		if m.paths != nil {
+			if _, ok := m.paths.Match(method, path); !ok {
+				continue
+			}
+		}
+		if m.header != "" {
+			values := header(m.header)
+			if len(values) == 0 || (len(m.values) > 0 && !slices.ContainsFunc(values, func(v string) bool { return slices.Contains(m.values, v) })) {
+				continue
+			}
+		}
+		return m.class
+	}
+	return l.fallback
+}
+
+// acquire 获取并发槽位，返回释放函数；无法获取时返回结果标签（shed / timeout / canceled）
+func (l *PriorityLimiter) acquire(ctx context.Context, class *priorityClass) (func(), string) {
+	if class.Bypass {
+		priorityRequestsTotal.WithLabelValues(class.Name, constants.PriorityResultAdmitted).Inc()
+		return func() {}, ""
+	}
+
+	l.mu.Lock()
+	if l.inflight < l.max && l.queuedLocked() == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		priorityInFlight.Inc()
+		priorityRequestsTotal.WithLabelValues(class.Name, constants.PriorityResultAdmitted).Inc()
+		return l.release, ""
+	}
+	if class.queue.Len() >= class.MaxQueue {
+		l.mu.Unlock()
+		priorityRequestsTotal.WithLabelValues(class.Name, constants.PriorityResultShed).Inc()
+		return nil, constants.PriorityResultShed
+	}
+	w := &priorityWaiter{ready: make(chan struct{})}
+	elem := class.queue.PushBack(w)
+	class.gauge.Inc()
+	l.mu.Unlock()
+
+	timer := time.NewTimer(class.QueueTimeout)
+	defer timer.Stop()
+	result := constants.PriorityResultTimeout
+	select {
+	case <-w.ready:
+		priorityRequestsTotal.WithLabelValues(class.Name, constants.PriorityResultQueued).Inc()
+		return l.release, ""
+	case <-timer.C:
+	case <-ctx.Done():
+		result = constants.PriorityResultCanceled
+	}
+
+	l.mu.Lock()
+	if w.granted {
+		// 超时与获得槽位同时发生，槽位已转交给本请求
+		l.mu.Unlock()
+		priorityRequestsTotal.WithLabelValues(class.Name, constants.PriorityResultQueued).Inc()
+		return l.release, ""
+	}
+	class.queue.Remove(elem)
+	class.gauge.Dec()
+	l.mu.Unlock()
+	priorityRequestsTotal.WithLabelValues(class.Name, result).Inc()
+	return nil, result
+}
+
+// release 释放槽位：有排队请求时直接转交给优先级最高的类别中最早排队者
+func (l *PriorityLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, class := range l.classes {
+		if front := class.queue.Front(); front != nil {
+			w := class.queue.Remove(front).(*priorityWaiter)
+			class.gauge.Dec()
+			w.granted = true
+			close(w.ready)
+			return
+		}
+	}
+	l.inflight--
+	priorityInFlight.Dec()
+}
+
+// queuedLocked 排队中的请求总数，调用方持有 l.mu
+func (l *PriorityLimiter) queuedLocked() int {
+	total := 0
+	for _, class := range l.classes {
+		total += class.queue.Len()
+	}
+	return total
+}
+
+// HTTPMiddleware HTTP 优先级并发限制中间件
+func (l *PriorityLimiter) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Handle(w, r, next)
+	})
+}
+
+// Handle 获取槽位后交给 next，无法获取时返回 503
+func (l *PriorityLimiter) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	class := l.classify(r.Method, r.URL.Path, r.Header.Values)
+	release, result := l.acquire(r.Context(), class)
+	if release == nil {
+		if result == constants.PriorityResultCanceled {
+			return // 客户端已断开，无需响应
+		}
+		code, message := constants.PriorityErrorCodeShed, constants.PriorityErrorShed
+		if result == constants.PriorityResultTimeout {
+			code, message = constants.PriorityErrorCodeQueueTimeout, constants.PriorityErrorQueueTimeout
+		}
+		w.Header().Set(constants.HeaderRetryAfter, strconv.Itoa(int(max(class.QueueTimeout, time.Second)/time.Second)))
+		response.WriteErrorResponseWithCode(w, http.StatusServiceUnavailable, code, message)
+		return
+	}
+	defer release()
+	next.ServeHTTP(w, r)
+}
+
+// Unary gRPC 一元调用优先级并发限制
+func (l *PriorityLimiter) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	release, err := l.acquireGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// Stream gRPC 流式调用优先级并发限制（整个流占用一个槽位）
+func (l *PriorityLimiter) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := l.acquireGRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+// acquireGRPC gRPC 调用获取槽位，按完整方法名与 metadata 分类
+func (l *PriorityLimiter) acquireGRPC(ctx context.Context, fullMethod string) (func(), error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	class := l.classify(http.MethodPost, fullMethod, func(name string) []string { return md.Get(name) })
+	release, result := l.acquire(ctx, class)
+	switch {
+	case release != nil:
+		return release, nil
+	case result == constants.PriorityResultCanceled:
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case result == constants.PriorityResultTimeout:
+		return nil, status.Error(codes.ResourceExhausted, constants.PriorityErrorQueueTimeout)
+	default:
+		return nil, status.Error(codes.ResourceExhausted, constants.PriorityErrorShed)
+	}
+}
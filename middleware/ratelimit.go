@@ -15,15 +15,18 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/errors"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-rpc-gateway/response"
-	"github.com/kamalyes/go-toolbox/pkg/matcher"
+	"github.com/kamalyes/go-rpc-gateway/store"
 	"github.com/kamalyes/go-toolbox/pkg/mathx"
 	"github.com/kamalyes/go-toolbox/pkg/netx"
 	"github.com/kamalyes/go-argus"
@@ -49,6 +52,8 @@ const (
 	keyFormatIP            = "ip:%s"               // IPkey格式
 	keyFormatUser          = "user:%s"             // 用户key格式
 	keyFormatRouteMethod   = "route:%s:%s"         // 路由+方法key格式
+	keyFormatTemplate      = "key:%s"              // 模板key格式
+	keyFormatRouteTemplate = "route:%s:key:%s"     // 路由+模板key格式
 
 	// 特殊key值
 	keyGlobal     = "global"    // 全局限流key
@@ -92,6 +97,22 @@ func NewTokenBucketLimiter(cfg *ratelimit.RateLimit) *TokenBucketLimiter {
 
 // Allow 检查是否允许请求（无锁原子操作）
 func (t *TokenBucketLimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
+	return t.take(ctx, key, rule, 1, false)
+}
+
+// AllowN 一次取走 n 个令牌，令牌不足时不扣减
+func (t *TokenBucketLimiter) AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error) {
+	return t.take(ctx, key, rule, n, false)
+}
+
+// ChargeN 无条件取走 n 个令牌，不足时透支为负数，由后续补充的令牌抵扣
+func (t *TokenBucketLimiter) ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error {
+	_, err := t.take(ctx, key, rule, n, true)
+	return err
+}
+
+// take 取走 n 个令牌，force 为 true 时不检查余量
+func (t *TokenBucketLimiter) take(ctx context.Context, key string, rule *ratelimit.LimitRule, n int, force bool) (bool, error) {
 	// 如果没有提供规则，使用全局配置
 	if rule == nil {
 		rule = t.globalRule
@@ -119,6 +140,7 @@ func (t *TokenBucketLimiter) Allow(ctx context.Context, key string, rule *rateli
 	bucket := bucketInterface.(*atomicTokenBucket)
 
 	now := time.Now().UnixNano()
+	cost := int64(n) * billion
 
 	for {
 		// 原子读取当前状态
@@ -133,26 +155,25 @@ func (t *TokenBucketLimiter) Allow(ctx context.Context, key string, rule *rateli
 		remainderNanos := elapsed % billion
 		addTokens := elapsedSeconds*bucket.refillRate + (remainderNanos*bucket.refillRate)/billion
 
-		// 计算新令牌数: min(maxTokens*billion, oldTokens+addTokens), 然后 max(0, result)
-		// 注意: mathx.AtLeast实际是min, mathx.AtMost实际是max
+		// 计算新令牌数: min(maxTokens*billion, oldTokens+addTokens)
+		// 注意: mathx.AtLeast实际是min；不限制下限，ChargeN 透支的负数由补充的令牌逐步抵扣
 		tokensAfterRefill := oldTokens + addTokens
 		maxTokensInt64 := bucket.maxTokens * billion
-		// 先用 AtLeast(min) 限制上限，再用 AtMost(max) 限制下限
-		newTokens := mathx.AtMost(0, mathx.AtLeast(maxTokensInt64, tokensAfterRefill))
+		newTokens := mathx.AtLeast(maxTokensInt64, tokensAfterRefill)
 
 		// 检查是否有足够令牌
-		if newTokens < billion {
+		if !force && newTokens < cost {
 			// 令牌不足，但需要更新lastRefillNano确保时间同步
 			atomic.StoreInt64(&bucket.tokensInt64, newTokens)
 			atomic.StoreInt64(&bucket.lastRefillNano, now)
-			global.LOGGER.DebugContext(ctx, "[TokenBucket] 令牌不足: key=%s, newTokens=%d (需要 %d)", bucketKey, newTokens/billion, 1)
+			global.LOGGER.DebugContext(ctx, "[TokenBucket] 令牌不足: key=%s, newTokens=%d (需要 %d)", bucketKey, newTokens/billion, n)
 			return false, nil // 令牌不足
 		}
 
 		// CAS更新令牌数和时间戳
-		if atomic.CompareAndSwapInt64(&bucket.tokensInt64, oldTokens, newTokens-billion) {
+		if atomic.CompareAndSwapInt64(&bucket.tokensInt64, oldTokens, newTokens-cost) {
 			atomic.StoreInt64(&bucket.lastRefillNano, now)
-			global.LOGGER.DebugContext(ctx, "[TokenBucket] 允许请求: key=%s, 剩余令牌=%d", bucketKey, (newTokens-billion)/billion)
+			global.LOGGER.DebugContext(ctx, "[TokenBucket] 允许请求: key=%s, 剩余令牌=%d", bucketKey, (newTokens-cost)/billion)
 			return true, nil
 		}
 		// CAS失败，重试
@@ -173,33 +194,15 @@ func (t *TokenBucketLimiter) Reset(ctx context.Context, key string) error {
 	return nil
 }
 
-// SlidingWindowLimiter 滑动窗口限流器（Redis实现）
+// SlidingWindowLimiter 滑动窗口限流器（基于状态存储：Redis 执行 Lua，内嵌存储执行等价的 Go 实现）
 type SlidingWindowLimiter struct {
 	config *ratelimit.RateLimit
 }
 
-// NewSlidingWindowLimiter 创建滑动窗口限流器
-func NewSlidingWindowLimiter(config *ratelimit.RateLimit) *SlidingWindowLimiter {
-	return &SlidingWindowLimiter{
-		config: config,
-	}
-}
-
-// Allow 检查是否允许请求（使用Lua脚本保证原子性）
-func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
-	if global.REDIS == nil {
-		return false, fmt.Errorf("redis not available for sliding window limiter")
-	}
-	// 使用mathx.IfNotEmpty设置key前缀默认值
-	keyPrefix := mathx.IfNotEmpty(s.config.Storage.KeyPrefix, defaultKeyPrefix)
-	// 生成包含规则参数的唯一key
-	fullKey := fmt.Sprintf(keyFormatSlidingWindow, keyPrefix, key, rule.WindowSize, rule.RequestsPerSecond)
-	now := time.Now()
-	windowStart := now.Add(-rule.WindowSize)
-
-	// 使用分布式锁 + Lua脚本保证100%准确性：
-	// 关键：用分布式锁串行化所有并发请求，确保检查和添加之间不会有其他请求插入
-	script := `
+// slidingWindowScript 滑动窗口限流脚本
+// 使用分布式锁 + Lua脚本保证100%准确性：
+// 关键：用分布式锁串行化所有并发请求，确保检查和添加之间不会有其他请求插入
+var slidingWindowScript = store.NewScript(`
 		local key = KEYS[1]
 		local counter_key = KEYS[2]
 		local lock_key = KEYS[3]
@@ -208,6 +211,8 @@ func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *rate
 		local limit = tonumber(ARGV[3])
 		local window_size = tonumber(ARGV[4])
 		local lock_value = ARGV[5]
+		local cost = tonumber(ARGV[6])
+		local force = ARGV[7] == '1'
 		
 		-- 1. 尝试获取分布式锁（NX表示不存在才设置，PX表示毫秒过期时间）
 		local lock_result = redis.call('SET', lock_key, lock_value, 'NX', 'PX', 1000)
@@ -222,16 +227,18 @@ func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *rate
 		-- 3. 统计窗口内的有效请求数
 		local count = redis.call('ZCOUNT', key, tostring(window_start), '+inf')
 		
-		-- 4. 如果已达到限制，释放锁并拒绝
-		if count >= limit then
+		-- 4. 如果计入本次成本后超过限制，释放锁并拒绝（force 时无条件计入）
+		if not force and count + cost > limit then
 			redis.call('DEL', lock_key)
 			return 0
 		end
 		
-		-- 5. 生成唯一member并添加
-		local unique_id = redis.call('INCR', counter_key)
-		local member = string.format('%d:%d', now, unique_id)
-		redis.call('ZADD', key, now, member)
+		-- 5. 按成本逐个生成唯一member并添加
+		for i = 1, cost do
+			local unique_id = redis.call('INCR', counter_key)
+			local member = string.format('%d:%d', now, unique_id)
+			redis.call('ZADD', key, now, member)
+		end
 		
 		-- 6. 设置过期时间
 		redis.call('EXPIRE', key, window_size * 2)
@@ -241,26 +248,115 @@ func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *rate
 		redis.call('DEL', lock_key)
 		
 		return 1
-	`
+	`, slidingWindowLocal)
+
+// slidingWindowLocal 滑动窗口脚本的 Go 实现（在存储锁内执行，无需分布式锁）
+// 窗口内的请求时间戳以逗号分隔保存在 KEYS[1] 中，成本为 n 的请求记录 n 个时间戳
+func slidingWindowLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 1 || len(args) < 7 {
+		return nil, fmt.Errorf("sliding window script: invalid keys or args")
+	}
+	now, err := store.ArgInt64(args[0])
+	if err != nil {
+		return nil, err
+	}
+	windowStart, err := store.ArgInt64(args[1])
+	if err != nil {
+		return nil, err
+	}
+	limit, err := store.ArgInt64(args[2])
+	if err != nil {
+		return nil, err
+	}
+	windowSize, err := store.ArgInt64(args[3])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := store.ArgInt64(args[5])
+	if err != nil {
+		return nil, err
+	}
+	force := store.ArgString(args[6]) == "1"
+
+	raw, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	// 清理窗口之前的数据，统计窗口内的有效请求数
+	timestamps := make([]string, 0)
+	if raw != "" {
+		for _, item := range strings.Split(raw, ",") {
+			ts, parseErr := strconv.ParseInt(item, 10, 64)
+			if parseErr == nil && ts > windowStart {
+				timestamps = append(timestamps, item)
+			}
+		}
+	}
+	if !force && int64(len(timestamps))+cost > limit {
+		return int64(0), nil
+	}
+
+	for i := int64(0); i < cost; i++ {
+		timestamps = append(timestamps, strconv.FormatInt(now, 10))
+	}
+	ttl := time.Duration(mathx.AtMost(1, windowSize)*2) * time.Second
+	if err := cmd.Set(ctx, keys[0], strings.Join(timestamps, ","), ttl); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+// NewSlidingWindowLimiter 创建滑动窗口限流器
+func NewSlidingWindowLimiter(config *ratelimit.RateLimit) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		config: config,
+	}
+}
+
+// Allow 检查是否允许请求（通过状态存储原子执行滑动窗口脚本）
+func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
+	return s.take(ctx, key, rule, 1, false)
+}
+
+// AllowN 一次计入 n 个请求，计入后超过限制时拒绝且不计入
+func (s *SlidingWindowLimiter) AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error) {
+	return s.take(ctx, key, rule, n, false)
+}
+
+// ChargeN 无条件计入 n 个请求（可超过限制），窗口滑过后释放
+func (s *SlidingWindowLimiter) ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error {
+	_, err := s.take(ctx, key, rule, n, true)
+	return err
+}
+
+// take 计入 n 个请求，force 为 true 时不检查限制
+func (s *SlidingWindowLimiter) take(ctx context.Context, key string, rule *ratelimit.LimitRule, n int, force bool) (bool, error) {
+	if global.STORE == nil {
+		return false, fmt.Errorf("store not available for sliding window limiter")
+	}
+	fullKey, counterKey, lockKey := s.keys(key, rule)
+	now := time.Now()
+	windowStart := now.Add(-rule.WindowSize)
 
 	// 生成锁的唯一值
-	lockKey := fullKey + ":lock"
 	lockValue := fmt.Sprintf("%d", time.Now().UnixNano())
-	counterKey := fullKey + ":counter"
 
 	// 重试机制：如果获取锁失败，短暂等待后重试（最多3次）
 	maxRetries := 3
 	for retry := 0; retry < maxRetries; retry++ {
-		result, err := global.REDIS.Eval(ctx, script, []string{fullKey, counterKey, lockKey},
+		result, err := global.STORE.Eval(ctx, slidingWindowScript, []string{fullKey, counterKey, lockKey},
 			now.UnixNano(),
 			windowStart.UnixNano(),
 			rule.RequestsPerSecond,
 			int64(rule.WindowSize.Seconds()),
 			lockValue,
-		).Result()
+			n,
+			mathx.IF(force, 1, 0),
+		)
 
 		if err != nil {
-			return false, fmt.Errorf("failed to execute lua script: %w", err)
+			return false, fmt.Errorf("failed to execute sliding window script: %w", err)
 		}
 
 		resultInt, ok := result.(int64)
@@ -285,37 +381,24 @@ func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string, rule *rate
 	return false, nil
 }
 
-// Reset 重置限流器（使用Lua脚本分批删除，避免阻塞）
+// keys 窗口数据、计数与锁三个 key（包含规则参数）；共享部分包在 hash tag 中，集群存储下落在同一分片
+func (s *SlidingWindowLimiter) keys(key string, rule *ratelimit.LimitRule) (data, counter, lock string) {
+	keyPrefix := mathx.IfNotEmpty(s.config.Storage.KeyPrefix, defaultKeyPrefix)
+	data = "{" + fmt.Sprintf(keyFormatSlidingWindow, keyPrefix, key, rule.WindowSize, rule.RequestsPerSecond) + "}"
+	return data, data + ":counter", data + ":lock"
+}
+
+// Reset 重置限流器（按前缀分批删除，Redis 使用 SCAN 避免阻塞）
 func (s *SlidingWindowLimiter) Reset(ctx context.Context, key string) error {
-	if global.REDIS == nil {
+	deleter, ok := global.STORE.(store.PrefixDeleter)
+	if !ok {
 		return nil
 	}
 	// 使用mathx.IfNotEmpty设置key前缀默认值
 	keyPrefix := mathx.IfNotEmpty(s.config.Storage.KeyPrefix, defaultKeyPrefix)
-	pattern := fmt.Sprintf(keyFormatResetPattern, keyPrefix, key)
-
-	// 使用Lua脚本:SCAN+DEL，避免KEYS阻塞，每批最多100个
-	script := `
-		local cursor = "0"
-		local deleted = 0
-		repeat
-			local result = redis.call('SCAN', cursor, 'MATCH', ARGV[1], 'COUNT', 100)
-			cursor = result[1]
-			local keys = result[2]
-			if #keys > 0 then
-				for i=1,#keys,100 do
-					local batch = {}
-					for j=i,math.min(i+99, #keys) do
-						table.insert(batch, keys[j])
-					end
-					redis.call('DEL', unpack(batch))
-					deleted = deleted + #batch
-				end
-			end
-		until cursor == "0"
-		return deleted
-	`
-	return global.REDIS.Eval(ctx, script, []string{}, pattern).Err()
+	prefix := "{" + strings.TrimSuffix(fmt.Sprintf(keyFormatResetPattern, keyPrefix, key), "*")
+	_, err := deleter.DeletePrefix(ctx, prefix)
+	return err
 }
 
 // FixedWindowLimiter 固定窗口限流器（使用atomic保证高性能）
@@ -347,6 +430,22 @@ func NewFixedWindowLimiter(config *ratelimit.RateLimit) *FixedWindowLimiter {
 
 // Allow 检查是否允许请求（使用atomic）
 func (f *FixedWindowLimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
+	return f.take(key, rule, 1), nil
+}
+
+// AllowN 一次计入 n 个请求，计入后超过限制时拒绝（与 Allow 一致，被拒绝的请求同样计数）
+func (f *FixedWindowLimiter) AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error) {
+	return f.take(key, rule, n), nil
+}
+
+// ChargeN 计入 n 个请求（可超过限制），窗口重置后清零
+func (f *FixedWindowLimiter) ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error {
+	f.take(key, rule, n)
+	return nil
+}
+
+// take 计入 n 个请求，返回计入后是否未超过限制
+func (f *FixedWindowLimiter) take(key string, rule *ratelimit.LimitRule, n int) bool {
 	// 生成包含规则参数的唯一key
 	counterKey := fmt.Sprintf(keyFormatFixedWindow, key, rule.WindowSize, rule.RequestsPerSecond)
 
@@ -368,17 +467,17 @@ func (f *FixedWindowLimiter) Allow(ctx context.Context, key string, rule *rateli
 		// 尝试重置（CAS保证只有一个goroutine重置）
 		newResetTime := now.Add(rule.WindowSize).UnixNano()
 		if atomic.CompareAndSwapInt64(&counter.resetTimeNano, resetTimeNano, newResetTime) {
-			// 重置计数器为 1（包含当前请求）
-			atomic.StoreInt64(&counter.count, 1)
-			return true, nil // 重置后第一个请求必然通过
+			// 重置计数器为 n（包含当前请求）
+			atomic.StoreInt64(&counter.count, int64(n))
+			return n <= max(rule.RequestsPerSecond, 1) // 重置后第一个请求只受自身成本限制
 		}
 		// CAS 失败说明其他 goroutine 已经重置，重新读取后继续
 	}
 
 	// 原子递增计数
-	newCount := atomic.AddInt64(&counter.count, 1)
+	newCount := atomic.AddInt64(&counter.count, int64(n))
 
-	return newCount <= int64(rule.RequestsPerSecond), nil
+	return newCount <= int64(rule.RequestsPerSecond)
 }
 
 // Reset 重置限流计数器
@@ -484,6 +583,12 @@ func resolveRateLimiterStrategy(strategy ratelimit.Strategy) ratelimit.Strategy
 		return ratelimit.StrategySlidingWindow
 	case ratelimit.StrategyFixedWindow:
 		return ratelimit.StrategyFixedWindow
+	case ratelimit.StrategyLeakyBucket, StrategyGCRA:
+		if global.STORE == nil {
+			global.LOGGER.Warn("状态存储不可用,限流器降级为令牌桶模式")
+			return ratelimit.StrategyTokenBucket
+		}
+		return strategy
 	case ratelimit.StrategyTokenBucket:
 		fallthrough
 	default:
@@ -499,6 +604,10 @@ func newRateLimiter(config *ratelimit.RateLimit, strategy ratelimit.Strategy) Ra
 		return NewSlidingWindowLimiter(config)
 	case ratelimit.StrategyFixedWindow:
 		return NewFixedWindowLimiter(config)
+	case ratelimit.StrategyLeakyBucket:
+		return NewLeakyBucketLimiter(config)
+	case StrategyGCRA:
+		return NewGCRALimiter(config)
 	case ratelimit.StrategyTokenBucket:
 		fallthrough
 	default:
@@ -511,6 +620,13 @@ type rateLimitMiddleware struct {
 	limiter         RateLimiter
 	limiters        *rateLimiterSet
 	dynamicProvider DynamicRateLimitProvider
+	routes          *RouteSet                               // 路由规则（共享路由表）
+	compiledRoutes  atomic.Pointer[[]ratelimit.RouteLimit] // 已编译的路由规则切片，配置替换后重新编译
+	penalty         *atomic.Pointer[RateLimitPenalty]      // 升级处罚（与管理器共享，运行时可替换）
+	costs           *atomic.Pointer[RateLimitCosts]        // 请求权重（与管理器共享，运行时可替换）
+	stats           *atomic.Pointer[RateLimitStats]        // 状态观测（与管理器共享，运行时可替换）
+	keys            *atomic.Pointer[RateLimitKeys]         // key 模板（与管理器共享，运行时可替换）
+	pathLabel       func(r *http.Request) string           // 指标路径标签，未启用 HTTP 指标时为 nil
 }
 
 func newRateLimitMiddleware(config *ratelimit.RateLimit, defaultLimiter RateLimiter, provider DynamicRateLimitProvider) *rateLimitMiddleware {
@@ -527,6 +643,11 @@ func newRateLimitMiddleware(config *ratelimit.RateLimit, defaultLimiter RateLimi
 		limiter:         limiter,
 		limiters:        limiters,
 		dynamicProvider: provider,
+		routes:          NewRouteSet("ratelimit", nil),
+		penalty:         new(atomic.Pointer[RateLimitPenalty]),
+		costs:           new(atomic.Pointer[RateLimitCosts]),
+		stats:           new(atomic.Pointer[RateLimitStats]),
+		keys:            new(atomic.Pointer[RateLimitKeys]),
 	}
 }
 
@@ -549,11 +670,16 @@ func (e *rateLimitMiddleware) Middleware() HTTPMiddleware {
 				return
 			}
 
-			if !e.allowRequests(w, r, decisions) {
+			ctx, cost := e.requestCost(r.Context(), r)
+			if ctx != r.Context() {
+				r = r.WithContext(ctx)
+			}
+			if !e.allowRequests(w, r, decisions, cost) {
 				return
 			}
 
 			next.ServeHTTP(w, r)
+			e.settleCost(ctx, decisions, cost)
 		})
 	}
 }
@@ -593,7 +719,7 @@ func (e *rateLimitMiddleware) normalizeDecisions(r *http.Request, decisions []Ra
 			continue
 		}
 		if decision.Key == "" {
-			decision.Key = e.generateKey(r, e.config.DefaultScope)
+			decision.Key = e.defaultKey(r)
 		}
 		if decision.Strategy == "" {
 			decision.Strategy = e.config.Strategy
@@ -612,20 +738,9 @@ func (e *rateLimitMiddleware) getRuleAndKey(r *http.Request) (*ratelimit.LimitRu
 
 	global.LOGGER.DebugContext(r.Context(), "getRuleAndKey: IP=%s, Path=%s, Method=%s", clientIP, path, method)
 
-	// 第一轮: 优先检查白名单和黑名单(最高优先级)
-	for i, routeLimit := range e.config.Routes {
-		global.LOGGER.DebugContext(r.Context(), "检查路由[%d]: Path=%s, Methods=%v", i, routeLimit.Path, routeLimit.Methods)
-
-		// 路径和方法匹配
-		pathMatch := matcher.MatchPathWithMethod(path, method, routeLimit.Path, routeLimit.Methods)
-		global.LOGGER.DebugContext(r.Context(), "MatchPathWithMethod结果: %v", pathMatch)
-
-		if !pathMatch {
-			global.LOGGER.DebugContext(r.Context(), "路由[%d]不匹配,continue", i)
-			continue
-		}
-
-		global.LOGGER.DebugContext(r.Context(), "路由[%d]匹配成功!", i)
+	// 第一轮: 优先检查白名单和黑名单(最高优先级)，首个匹配的路由生效
+	if routeLimit := e.matchRoute(r); routeLimit != nil {
+		global.LOGGER.DebugContext(r.Context(), "路由匹配成功: Path=%s, Methods=%v", routeLimit.Path, routeLimit.Methods)
 
 		// 1. 白名单 - 最高优先级,直接放行(仅当白名单非空时检查)
 		if len(routeLimit.Whitelist) > 0 && validator.IsIPAllowed(clientIP, routeLimit.Whitelist) {
@@ -646,6 +761,9 @@ func (e *rateLimitMiddleware) getRuleAndKey(r *http.Request) (*ratelimit.LimitRu
 
 		// 3. 应用路由限流规则
 		if routeLimit.Limit != nil {
+			if key, ok := e.templateKey(r); ok {
+				return routeLimit.Limit, fmt.Sprintf(keyFormatRouteTemplate, routeLimit.Path, key)
+			}
 			if routeLimit.PerUser {
 				userID := GetRequestCommonMeta(r.Context()).UserID
 				return routeLimit.Limit, fmt.Sprintf(keyFormatRouteUser, routeLimit.Path, userID)
@@ -689,33 +807,68 @@ func (e *rateLimitMiddleware) getRuleAndKey(r *http.Request) (*ratelimit.LimitRu
 
 	// 第四轮: 使用全局限流规则
 	if e.config.GlobalLimit != nil {
-		key := e.generateKey(r, e.config.DefaultScope)
-		return e.config.GlobalLimit, key
+		return e.config.GlobalLimit, e.defaultKey(r)
 	}
 
 	// 无任何限流规则,放行
 	return nil, ""
 }
 
-// allowRequests 检查是否允许请求
-func (e *rateLimitMiddleware) allowRequests(w http.ResponseWriter, r *http.Request, decisions []RateLimitDecision) bool {
+// matchRoute 通过共享路由表查找首个匹配的路由规则（glob 语义与 matcher.MatchPathWithMethod 一致）
+// Routes 切片被整体替换（如配置热更新）时重新编译
+func (e *rateLimitMiddleware) matchRoute(r *http.Request) *ratelimit.RouteLimit {
+	routes := e.config.Routes
+	if len(routes) == 0 {
+		return nil
+	}
+
+	if compiled := e.compiledRoutes.Load(); compiled == nil || len(*compiled) != len(routes) || &(*compiled)[0] != &routes[0] {
+		patterns := make([]RoutePattern, len(routes))
+		for i := range routes {
+			patterns[i] = RoutePattern{Kind: RouteMatchGlob, Pattern: routes[i].Path, Methods: routes[i].Methods, Value: &routes[i]}
+		}
+		e.routes.Update(patterns)
+		e.compiledRoutes.Store(&routes)
+	}
+
+	if value, ok := e.routes.Match(r); ok {
+		return value.(*ratelimit.RouteLimit)
+	}
+	return nil
+}
+
+// allowRequests 检查是否允许请求，每个限流 key 扣减 cost 个额度
+func (e *rateLimitMiddleware) allowRequests(w http.ResponseWriter, r *http.Request, decisions []RateLimitDecision, cost int) bool {
+	route := e.routeLabel(r)
 	for _, decision := range decisions {
+		if blocked := e.penaltyBlocked(r.Context(), decision.Key); blocked > 0 {
+			e.observeRateLimit(route, decision, nil, constants.RateLimitResultBanned)
+			w.Header().Set("Retry-After", retryAfterSeconds(blocked))
+			response.WriteAppError(w, penaltyError(blocked))
+			return false
+		}
+
 		limiter := e.getLimiter(decision.Strategy)
 		if limiter == nil {
 			response.WriteAppError(w, errors.NewError(errors.ErrCodeInternalServerError, fmt.Sprintf("unsupported rate limit strategy: %s", decision.Strategy)))
 			return false
 		}
 
-		allowed, err := limiter.Allow(r.Context(), decision.Key, decision.Rule)
+		allowed, err := allowRateLimit(r.Context(), limiter, decision, cost)
 		if err != nil {
 			response.WriteAppError(w, errors.NewError(errors.ErrCodeInternalServerError, err.Error()))
 			return false
 		}
 
 		if !allowed {
+			e.observeRateLimit(route, decision, limiter, constants.RateLimitResultRejected)
+			if block := e.penaltyStrike(r.Context(), decision); block > 0 {
+				w.Header().Set("Retry-After", retryAfterSeconds(block))
+			}
 			response.WriteErrorResponse(w, errors.ErrRateLimitExceeded)
 			return false
 		}
+		e.observeRateLimit(route, decision, limiter, constants.RateLimitResultAllowed)
 	}
 
 	return true
@@ -0,0 +1,196 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_cost.go
+ * @Description: 限流请求权重 - 路由声明每次请求消耗的额度（如 search=5、get=1），从同一个限流桶中扣减；
+ *               处理器可在运行时通过上下文按负载大小或查询复杂度调整本次请求的权重，
+ *               进入处理器前预扣路由权重，处理完成后补扣超出的部分（透支由后续额度抵扣，不退还）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// WeightedRateLimiter 支持按权重扣减的限流器，内置策略均已实现；
+// 自定义限流器未实现时按权重重复调用 Allow
+type WeightedRateLimiter interface {
+	RateLimiter
+	// AllowN 一次消耗 n 个额度，额度不足时拒绝
+	AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error)
+	// ChargeN 无条件消耗 n 个额度，用于请求处理后补扣
+	ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error
+}
+
+// RateLimitRouteCost 路由权重
+type RateLimitRouteCost struct {
+	Path    string   // 路径 glob，gRPC 写完整方法名（/pkg.Service/Method）
+	Methods []string // HTTP 方法，为空匹配全部
+	Cost    int      // 每次请求消耗的额度，必须大于 0
+}
+
+// RateLimitCostConfig 限流请求权重配置
+type RateLimitCostConfig struct {
+	Routes      []RateLimitRouteCost      // 路由权重，首个匹配的生效
+	DefaultCost int                       // 未匹配路由的权重，默认 1
+	MaxCost     int                       // 单个请求的权重上限（含处理器调整），0 不限制
+	CostFunc    func(r *http.Request) int // 按请求计算预扣权重（如按 Content-Length），返回值小于 1 时使用路由权重
+}
+
+// RateLimitCosts 编译后的请求权重规则
+type RateLimitCosts struct {
+	config RateLimitCostConfig
+	routes *RouteTable
+}
+
+// rateLimitCostKey 请求上下文中权重槽位的键
+type rateLimitCostKey struct{}
+
+// rateLimitCostSlot 本次请求的权重，初始为预扣值，处理器可调整
+type rateLimitCostSlot struct {
+	cost atomic.Int64
+}
+
+// NewRateLimitCosts 校验配置并编译路由权重
+func NewRateLimitCosts(cfg RateLimitCostConfig) (*RateLimitCosts, error) {
+	if cfg.DefaultCost < 0 || cfg.MaxCost < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "rate limit default cost and max cost must not be negative")
+	}
+	if cfg.DefaultCost == 0 {
+		cfg.DefaultCost = 1
+	}
+
+	patterns := make([]RoutePattern, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		if route.Path == "" {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "rate limit route cost has no path")
+		}
+		if route.Cost < 1 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "rate limit cost for %s must be positive", route.Path)
+		}
+		patterns = append(patterns, RoutePattern{Kind: RouteMatchGlob, Pattern: route.Path, Methods: route.Methods, Value: route.Cost})
+	}
+	return &RateLimitCosts{config: cfg, routes: NewRouteTable(patterns)}, nil
+}
+
+// Cost 请求进入处理器前预扣的权重：CostFunc > 路由权重 > DefaultCost，超过 MaxCost 时截断
+func (c *RateLimitCosts) Cost(r *http.Request) int {
+	cost := c.config.DefaultCost
+	if value, ok := c.routes.Match(r.Method, r.URL.Path); ok {
+		cost = value.(int)
+	}
+	if c.config.CostFunc != nil {
+		if dynamic := c.config.CostFunc(r); dynamic > 0 {
+			cost = dynamic
+		}
+	}
+	return c.clamp(cost)
+}
+
+// clamp 按 MaxCost 截断
+func (c *RateLimitCosts) clamp(cost int) int {
+	if c.config.MaxCost > 0 && cost > c.config.MaxCost {
+		return c.config.MaxCost
+	}
+	return cost
+}
+
+// SetRateLimitCost 设置本次请求的总权重（处理器中调用），超出预扣值的部分在处理完成后补扣；
+// 未配置请求权重或请求未经过限流时无效果
+func SetRateLimitCost(ctx context.Context, cost int) {
+	if slot, ok := ctx.Value(rateLimitCostKey{}).(*rateLimitCostSlot); ok {
+		slot.cost.Store(int64(max(cost, 0)))
+	}
+}
+
+// AddRateLimitCost 在本次请求的权重上追加 delta（如每返回一批数据追加一次）
+func AddRateLimitCost(ctx context.Context, delta int) {
+	if slot, ok := ctx.Value(rateLimitCostKey{}).(*rateLimitCostSlot); ok {
+		slot.cost.Add(int64(delta))
+	}
+}
+
+// RateLimitCost 本次请求当前的权重，未配置请求权重时返回 1
+func RateLimitCost(ctx context.Context) int {
+	if slot, ok := ctx.Value(rateLimitCostKey{}).(*rateLimitCostSlot); ok {
+		return int(slot.cost.Load())
+	}
+	return 1
+}
+
+// requestCost 计算预扣权重并在上下文中放置权重槽位，未配置请求权重时为 1 且不放置槽位
+func (e *rateLimitMiddleware) requestCost(ctx context.Context, r *http.Request) (context.Context, int) {
+	costs := e.costs.Load()
+	if costs == nil {
+		return ctx, 1
+	}
+	cost := costs.Cost(r)
+	slot := &rateLimitCostSlot{}
+	slot.cost.Store(int64(cost))
+	return context.WithValue(ctx, rateLimitCostKey{}, slot), cost
+}
+
+// settleCost 处理完成后按处理器调整的权重补扣差额（只补不退），补扣失败只记录日志
+func (e *rateLimitMiddleware) settleCost(ctx context.Context, decisions []RateLimitDecision, charged int) {
+	slot, ok := ctx.Value(rateLimitCostKey{}).(*rateLimitCostSlot)
+	if !ok {
+		return
+	}
+	total := int(slot.cost.Load())
+	if costs := e.costs.Load(); costs != nil {
+		total = costs.clamp(total)
+	}
+	extra := total - charged
+	if extra <= 0 {
+		return
+	}
+	for _, decision := range decisions {
+		limiter := e.getLimiter(decision.Strategy)
+		if limiter == nil {
+			continue
+		}
+		if err := chargeRateLimit(ctx, limiter, decision, extra); err != nil {
+			global.LOGGER.WarnKV("限流权重补扣失败", "key", decision.Key, "extra", extra, "error", err)
+		}
+	}
+}
+
+// allowRateLimit 按权重判定一次限流，权重为 1 时等同 Allow
+func allowRateLimit(ctx context.Context, limiter RateLimiter, decision RateLimitDecision, n int) (bool, error) {
+	if n <= 1 {
+		return limiter.Allow(ctx, decision.Key, decision.Rule)
+	}
+	if weighted, ok := limiter.(WeightedRateLimiter); ok {
+		return weighted.AllowN(ctx, decision.Key, decision.Rule, n)
+	}
+	for i := 0; i < n; i++ {
+		if allowed, err := limiter.Allow(ctx, decision.Key, decision.Rule); err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// chargeRateLimit 无条件扣减 n 个额度；不支持权重的限流器按次调用 Allow，额度不足的部分不计入
+func chargeRateLimit(ctx context.Context, limiter RateLimiter, decision RateLimitDecision, n int) error {
+	if weighted, ok := limiter.(WeightedRateLimiter); ok {
+		return weighted.ChargeN(ctx, decision.Key, decision.Rule, n)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := limiter.Allow(ctx, decision.Key, decision.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
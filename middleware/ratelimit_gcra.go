@@ -0,0 +1,154 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_gcra.go
+ * @Description: GCRA（通用信元速率算法）限流器 - 每个 key 只保存一个理论到达时间（TAT），
+ *               放行效果与令牌桶相同（稳态 RPS，允许 BurstSize 的突发），但状态只有一个整数，
+ *               一次 GET + SET 即可完成判定，适合 Redis 共享限流
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// StrategyGCRA GCRA 限流策略（go-config 未内置，配置中写 strategy: gcra）
+const StrategyGCRA ratelimit.Strategy = "gcra"
+
+// keyFormatGCRA GCRA key 格式（以 {prefix}:{key}: 开头，Reset 可按前缀删除）
+const keyFormatGCRA = "%s:%s:gcra:rps_%d:burst_%d"
+
+// gcraScript GCRA 脚本：KEYS[1] 保存理论到达时间（微秒），
+// 理论到达时间领先当前时间不超过容差时放行并推进 ARGV[2]（请求权重个发射间隔），返回 1 放行、0 拒绝
+var gcraScript = store.NewScript(`
+		local key = KEYS[1]
+		local now = tonumber(ARGV[1])
+		local interval = tonumber(ARGV[2])
+		local tolerance = tonumber(ARGV[3])
+
+		local tat = tonumber(redis.call('GET', key) or now)
+		if tat < now then
+			tat = now
+		end
+		if tat - now > tolerance then
+			return 0
+		end
+
+		local new_tat = tat + interval
+		redis.call('SET', key, new_tat, 'PX', math.ceil((new_tat - now) / 1000) + 1)
+		return 1
+	`, gcraLocal)
+
+// gcraLocal GCRA 脚本的 Go 实现
+func gcraLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("gcra script: invalid keys or args")
+	}
+	now, interval, tolerance, err := rateLimitScriptArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	tat := now
+	raw, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	if raw != "" {
+		stored, parseErr := strconv.ParseInt(raw, 10, 64)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		tat = max(now, stored)
+	}
+	if tat-now > tolerance {
+		return int64(0), nil
+	}
+
+	newTAT := tat + interval
+	ttl := time.Duration(newTAT-now)*time.Microsecond + time.Millisecond
+	if err := cmd.Set(ctx, keys[0], strconv.FormatInt(newTAT, 10), ttl); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+// GCRALimiter GCRA 限流器（基于状态存储：Redis 执行 Lua，内嵌存储执行等价的 Go 实现）
+type GCRALimiter struct {
+	config *ratelimit.RateLimit
+}
+
+// NewGCRALimiter 创建 GCRA 限流器
+func NewGCRALimiter(config *ratelimit.RateLimit) *GCRALimiter {
+	return &GCRALimiter{
+		config: config,
+	}
+}
+
+// Allow 检查是否允许请求：发射间隔为 1/RPS，突发容差为 (BurstSize-1) 个间隔
+func (g *GCRALimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
+	return g.take(ctx, key, rule, 1, false)
+}
+
+// AllowN 一次推进 n 个发射间隔，容差相应减少 n-1 个间隔；n 超过 BurstSize 的请求永远被拒绝
+func (g *GCRALimiter) AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error) {
+	return g.take(ctx, key, rule, n, false)
+}
+
+// ChargeN 无条件推进 n 个发射间隔，理论到达时间超前的部分由时间流逝抵扣
+func (g *GCRALimiter) ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error {
+	_, err := g.take(ctx, key, rule, n, true)
+	return err
+}
+
+// take 推进 n 个发射间隔，force 为 true 时不检查容差
+func (g *GCRALimiter) take(ctx context.Context, key string, rule *ratelimit.LimitRule, n int, force bool) (bool, error) {
+	if global.STORE == nil {
+		return false, fmt.Errorf("store not available for gcra limiter")
+	}
+	keyPrefix := mathx.IfNotEmpty(g.config.Storage.KeyPrefix, defaultKeyPrefix)
+	fullKey := fmt.Sprintf(keyFormatGCRA, keyPrefix, key, rule.RequestsPerSecond, rule.BurstSize)
+	interval := emissionInterval(rule)
+	tolerance := mathx.IF(force, int64(math.MaxInt64), interval*int64(max(rule.BurstSize, 1)-n))
+
+	result, err := global.STORE.Eval(ctx, gcraScript, []string{fullKey},
+		time.Now().UnixMicro(),
+		interval*int64(n),
+		tolerance,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute gcra script: %w", err)
+	}
+	allowed, ok := result.(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected result type: %T", result)
+	}
+	return allowed == 1, nil
+}
+
+// Reset 重置限流器（按前缀删除）
+func (g *GCRALimiter) Reset(ctx context.Context, key string) error {
+	deleter, ok := global.STORE.(store.PrefixDeleter)
+	if !ok {
+		return nil
+	}
+	keyPrefix := mathx.IfNotEmpty(g.config.Storage.KeyPrefix, defaultKeyPrefix)
+	prefix := strings.TrimSuffix(fmt.Sprintf(keyFormatResetPattern, keyPrefix, key), "*")
+	_, err := deleter.DeletePrefix(ctx, prefix)
+	return err
+}
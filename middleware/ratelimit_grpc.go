@@ -0,0 +1,143 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_grpc.go
+ * @Description: gRPC 限流拦截器 - 与 HTTP 限流共用 ratelimit 配置与限流器：
+ *               routes 的 path 写完整方法名（/pkg.Service/Method，支持 glob），
+ *               IP / 用户 / 全局限流的计数与 HTTP 共享，直连 gRPC 无法绕过 HTTP 侧的额度
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// gatewayLimitedToken 进程内随机令牌：网关转发到本进程 gRPC 服务的调用携带此令牌，避免同一请求被计数两次
+var gatewayLimitedToken = func() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// UnaryServerInterceptor gRPC 一元调用限流拦截器
+func (e *rateLimitMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, settle, err := e.allowGRPC(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer settle()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor gRPC 流式调用限流拦截器（建立流时计数一次，流结束后补扣处理器追加的权重）
+func (e *rateLimitMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, settle, err := e.allowGRPC(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer settle()
+		if ctx != ss.Context() {
+			ss = &contextWrappedServerStream{ServerStream: ss, ctx: ctx}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// GatewayLimitedAnnotator grpc-gateway metadata 注解：标记已经过 HTTP 限流的转发调用（仅在启用限流时注册）
+func GatewayLimitedAnnotator(ctx context.Context, r *http.Request) metadata.MD {
+	return metadata.Pairs(constants.MetadataGatewayLimited, gatewayLimitedToken)
+}
+
+// allowGRPC 按与 HTTP 相同的规则判定（白名单 > 黑名单 > 路由 > IP > 用户 > 全局），超限返回 ResourceExhausted；
+// 放行时返回携带权重槽位的上下文与处理完成后的补扣函数
+func (e *rateLimitMiddleware) allowGRPC(ctx context.Context, fullMethod string) (context.Context, func(), error) {
+	if !e.config.Enabled || limitedByGateway(ctx) {
+		return ctx, func() {}, nil
+	}
+
+	r := grpcRateLimitRequest(ctx, fullMethod)
+	decisions, appErr := e.getDecisions(r)
+	if appErr != nil {
+		return ctx, nil, appErr.ToGRPCError()
+	}
+	if len(decisions) == 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cost := e.requestCost(ctx, r)
+	for _, decision := range decisions {
+		if blocked := e.penaltyBlocked(ctx, decision.Key); blocked > 0 {
+			e.observeRateLimit(fullMethod, decision, nil, constants.RateLimitResultBanned)
+			return ctx, nil, penaltyError(blocked).ToGRPCError()
+		}
+		limiter := e.getLimiter(decision.Strategy)
+		if limiter == nil {
+			return ctx, nil, errors.NewError(errors.ErrCodeInternalServerError, fmt.Sprintf("unsupported rate limit strategy: %s", decision.Strategy)).ToGRPCError()
+		}
+		allowed, err := allowRateLimit(ctx, limiter, decision, cost)
+		if err != nil {
+			return ctx, nil, errors.NewError(errors.ErrCodeInternalServerError, err.Error()).ToGRPCError()
+		}
+		if !allowed {
+			e.observeRateLimit(fullMethod, decision, limiter, constants.RateLimitResultRejected)
+			e.penaltyStrike(ctx, decision)
+			return ctx, nil, errors.ErrRateLimitExceeded.ToGRPCError()
+		}
+		e.observeRateLimit(fullMethod, decision, limiter, constants.RateLimitResultAllowed)
+	}
+	return ctx, func() { e.settleCost(ctx, decisions, cost) }, nil
+}
+
+// limitedByGateway 调用是否由本进程网关转发且已在 HTTP 层限流
+func limitedByGateway(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(constants.MetadataGatewayLimited) {
+		if v == gatewayLimitedToken {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcRateLimitRequest 以 gRPC 调用构造等价的 HTTP 请求（POST + 完整方法名，metadata 作为请求头，peer 作为远端地址），
+// 复用 HTTP 的规则匹配、客户端 IP 解析与动态限流提供器
+func grpcRateLimitRequest(ctx context.Context, fullMethod string) *http.Request {
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     make(http.Header),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || strings.HasSuffix(key, "-bin") {
+				continue
+			}
+			r.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
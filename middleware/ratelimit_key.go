@@ -0,0 +1,418 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_key.go
+ * @Description: 限流 key 模板 - 用 JWT 声明、请求头、Cookie、查询参数与 JSON 请求体字段组合限流 key，
+ *               按租户、API Key 等维度限流；模板按顺序尝试，首个所有占位符都能取到值的模板生效，
+ *               全部取不到时沿用原有的 IP / 用户 key；占位符可加 |hash 只保留摘要，避免明文写入存储与指标
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+)
+
+// DefaultRateLimitKeyMaxBodyBytes 读取 JSON 请求体字段时的默认读取上限
+const DefaultRateLimitKeyMaxBodyBytes = 64 << 10
+
+// 限流 key 模板占位符来源
+const (
+	RateLimitKeySourceIP     = "ip"     // 客户端 IP
+	RateLimitKeySourceUser   = "user"   // 用户 ID（RequestCommonMeta）
+	RateLimitKeySourceClaim  = "claim"  // JWT 声明，支持点号路径
+	RateLimitKeySourceHeader = "header" // 请求头（gRPC 为 metadata）
+	RateLimitKeySourceCookie = "cookie" // Cookie
+	RateLimitKeySourceQuery  = "query"  // 查询参数
+	RateLimitKeySourceBody   = "body"   // JSON 请求体字段，支持点号路径
+)
+
+// rateLimitKeyHashModifier 占位符摘要修饰符
+const rateLimitKeyHashModifier = "hash"
+
+// RateLimitRouteKey 路由专用的 key 模板
+type RateLimitRouteKey struct {
+	Path      string   // 路径 glob，与限流路由规则的 path 写法一致
+	Methods   []string // HTTP 方法，为空匹配全部
+	Templates []string // 按顺序尝试的 key 模板
+}
+
+// RateLimitKeyConfig 限流 key 模板配置
+type RateLimitKeyConfig struct {
+	// Templates 按顺序尝试的 key 模板，如 "tenant:{claim:tenant_id}:{header:X-API-Key|hash}"
+	Templates []string
+	// Routes 路由专用模板，命中时替代 Templates
+	Routes []RateLimitRouteKey
+	// HashAll 所有占位符都只保留摘要
+	HashAll bool
+	// HashSecret 摘要使用 HMAC-SHA256 的密钥，为空时使用 SHA-256（可被字典枚举，建议设置）
+	HashSecret string
+	// MaxBodyBytes 读取 JSON 请求体字段时的读取上限，默认 64KB，超过上限的请求体视为取不到值
+	MaxBodyBytes int64
+	// ClaimsFunc 校验 Authorization Bearer 令牌并返回声明（请求尚未经过认证中间件时使用）
+	ClaimsFunc func(ctx context.Context, token string) (map[string]any, error)
+	// UnverifiedClaims 未设置 ClaimsFunc 时直接解码 Bearer JWT 的载荷，不校验签名；
+	// 客户端可伪造声明换取新的限流额度，只在上游已校验令牌时开启
+	UnverifiedClaims bool
+}
+
+// RateLimitKeys 编译后的限流 key 模板
+type RateLimitKeys struct {
+	config    RateLimitKeyConfig
+	templates []rateLimitKeyTemplate
+	routes    *RouteTable
+}
+
+// rateLimitKeyTemplate 解析后的模板：字面量与占位符交替
+type rateLimitKeyTemplate struct {
+	parts []rateLimitKeyPart
+}
+
+// rateLimitKeyPart 模板片段，source 为空时是字面量
+type rateLimitKeyPart struct {
+	literal string
+	source  string
+	name    string
+	hash    bool
+}
+
+// NewRateLimitKeys 解析并校验限流 key 模板
+func NewRateLimitKeys(cfg RateLimitKeyConfig) (*RateLimitKeys, error) {
+	if cfg.MaxBodyBytes < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "ratelimit key: max body bytes must not be negative")
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultRateLimitKeyMaxBodyBytes
+	}
+	if len(cfg.Templates) == 0 && len(cfg.Routes) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "ratelimit key: no templates configured")
+	}
+
+	k := &RateLimitKeys{config: cfg}
+	var err error
+	if k.templates, err = parseRateLimitKeyTemplates(cfg.Templates, cfg.HashAll); err != nil {
+		return nil, err
+	}
+	patterns := make([]RoutePattern, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		if route.Path == "" || len(route.Templates) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit key: route %q requires a path and templates", route.Path)
+		}
+		templates, err := parseRateLimitKeyTemplates(route.Templates, cfg.HashAll)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, RoutePattern{Kind: RouteMatchGlob, Pattern: route.Path, Methods: route.Methods, Value: templates})
+	}
+	k.routes = NewRouteTable(patterns)
+	return k, nil
+}
+
+// parseRateLimitKeyTemplates 解析一组模板
+func parseRateLimitKeyTemplates(raw []string, hashAll bool) ([]rateLimitKeyTemplate, error) {
+	templates := make([]rateLimitKeyTemplate, 0, len(raw))
+	for _, text := range raw {
+		tpl, err := parseRateLimitKeyTemplate(text, hashAll)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tpl)
+	}
+	return templates, nil
+}
+
+// parseRateLimitKeyTemplate 解析 "literal{source:name|hash}literal" 形式的模板
+func parseRateLimitKeyTemplate(text string, hashAll bool) (rateLimitKeyTemplate, error) {
+	var tpl rateLimitKeyTemplate
+	rest := text
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			tpl.parts = append(tpl.parts, rateLimitKeyPart{literal: rest})
+			break
+		}
+		if open > 0 {
+			tpl.parts = append(tpl.parts, rateLimitKeyPart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return tpl, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit key template %q: unclosed placeholder", text)
+		}
+		part, err := parseRateLimitKeyPlaceholder(rest[open+1 : open+end])
+		if err != nil {
+			return tpl, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit key template %q: %v", text, err)
+		}
+		part.hash = part.hash || hashAll
+		tpl.parts = append(tpl.parts, part)
+		rest = rest[open+end+1:]
+	}
+	for _, part := range tpl.parts {
+		if part.source != "" {
+			return tpl, nil
+		}
+	}
+	return tpl, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit key template %q has no placeholders", text)
+}
+
+// parseRateLimitKeyPlaceholder 解析占位符内容 source[:name][|hash]
+func parseRateLimitKeyPlaceholder(body string) (rateLimitKeyPart, error) {
+	var part rateLimitKeyPart
+	if spec, modifier, ok := strings.Cut(body, "|"); ok {
+		if modifier != rateLimitKeyHashModifier {
+			return part, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "unknown modifier %q", modifier)
+		}
+		body, part.hash = spec, true
+	}
+	part.source, part.name, _ = strings.Cut(body, ":")
+	switch part.source {
+	case RateLimitKeySourceIP, RateLimitKeySourceUser:
+		if part.name != "" {
+			return part, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "placeholder %q takes no name", part.source)
+		}
+	case RateLimitKeySourceClaim, RateLimitKeySourceHeader, RateLimitKeySourceCookie, RateLimitKeySourceQuery, RateLimitKeySourceBody:
+		if part.name == "" {
+			return part, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "placeholder %q requires a name", part.source)
+		}
+	default:
+		return part, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "unknown placeholder source %q", part.source)
+	}
+	return part, nil
+}
+
+// Key 按模板生成请求的限流 key（不含 key: / route: 前缀），没有模板能取到全部值时返回 false
+func (k *RateLimitKeys) Key(r *http.Request) (string, bool) {
+	templates := k.templates
+	if value, ok := k.routes.Match(r.Method, r.URL.Path); ok {
+		templates = value.([]rateLimitKeyTemplate)
+	}
+	src := &rateLimitKeySource{keys: k, r: r}
+	for _, tpl := range templates {
+		if key, ok := tpl.render(src); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// render 渲染模板，任一占位符取不到值时返回 false
+func (t rateLimitKeyTemplate) render(src *rateLimitKeySource) (string, bool) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.source == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		value := src.value(part.source, part.name)
+		if value == "" {
+			return "", false
+		}
+		if part.hash {
+			value = src.keys.hash(value)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), true
+}
+
+// hash 取值摘要（HMAC-SHA256 或 SHA-256 的前 16 字节）
+func (k *RateLimitKeys) hash(value string) string {
+	var sum []byte
+	if k.config.HashSecret != "" {
+		mac := hmac.New(sha256.New, []byte(k.config.HashSecret))
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256([]byte(value))
+		sum = digest[:]
+	}
+	return hex.EncodeToString(sum[:16])
+}
+
+// rateLimitKeySource 单个请求的取值来源，声明、查询参数与请求体只解析一次
+type rateLimitKeySource struct {
+	keys *RateLimitKeys
+	r    *http.Request
+
+	query      url.Values
+	claims     map[string]any
+	claimsDone bool
+	body       map[string]any
+	bodyDone   bool
+}
+
+// value 取占位符的值，取不到时返回空字符串
+func (s *rateLimitKeySource) value(source, name string) string {
+	switch source {
+	case RateLimitKeySourceIP:
+		return netx.GetClientIP(s.r)
+	case RateLimitKeySourceUser:
+		return GetRequestCommonMeta(s.r.Context()).UserID
+	case RateLimitKeySourceHeader:
+		return s.r.Header.Get(name)
+	case RateLimitKeySourceCookie:
+		if cookie, err := s.r.Cookie(name); err == nil {
+			return cookie.Value
+		}
+	case RateLimitKeySourceQuery:
+		if s.query == nil {
+			s.query = s.r.URL.Query()
+		}
+		return s.query.Get(name)
+	case RateLimitKeySourceClaim:
+		return s.claim(name)
+	case RateLimitKeySourceBody:
+		if !s.bodyDone {
+			s.bodyDone = true
+			s.body = s.keys.readJSONBody(s.r)
+		}
+		return rateLimitKeyField(s.body, name)
+	}
+	return ""
+}
+
+// claim 取声明：已认证的 Principal 优先，否则解析 Authorization Bearer 令牌
+func (s *rateLimitKeySource) claim(name string) string {
+	if p := contextPrincipal(s.r.Context()); p != nil {
+		switch name {
+		case "sub":
+			return p.Subject
+		case "tenant":
+			return p.Tenant
+		case "roles":
+			return strings.Join(p.Roles, ",")
+		case "scope":
+			return strings.Join(p.Scopes, " ")
+		case "auth_type":
+			return p.AuthType
+		default:
+			return p.Attributes[name]
+		}
+	}
+	if !s.claimsDone {
+		s.claimsDone = true
+		s.claims = s.keys.bearerClaims(s.r)
+	}
+	return rateLimitKeyField(s.claims, name)
+}
+
+// bearerClaims 解析 Bearer 令牌的声明；未配置 ClaimsFunc 且未开启 UnverifiedClaims 时返回 nil
+func (k *RateLimitKeys) bearerClaims(r *http.Request) map[string]any {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil
+	}
+	if k.config.ClaimsFunc != nil {
+		claims, err := k.config.ClaimsFunc(r.Context(), token)
+		if err != nil {
+			return nil
+		}
+		return claims
+	}
+	if !k.config.UnverifiedClaims {
+		return nil
+	}
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]any
+	if json.Unmarshal(payload, &claims) != nil {
+		return nil
+	}
+	return claims
+}
+
+// readJSONBody 读取不超过 MaxBodyBytes 的 JSON 请求体并放回，供后续处理器继续读取
+func (k *RateLimitKeys) readJSONBody(r *http.Request) map[string]any {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+		(mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, k.config.MaxBodyBytes+1))
+	r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	if err != nil || int64(len(buf)) > k.config.MaxBodyBytes {
+		return nil
+	}
+	var body map[string]any
+	if json.Unmarshal(buf, &body) != nil {
+		return nil
+	}
+	return body
+}
+
+// replayBody 已读取部分与剩余部分拼接的请求体，关闭时关闭原请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// rateLimitKeyField 按点号路径取字段并转为字符串，对象与数组取 JSON 文本
+func rateLimitKeyField(data map[string]any, path string) string {
+	var value any = data
+	for _, segment := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		if value, ok = obj[segment]; !ok {
+			return ""
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
+
+// templateKey 按模板生成限流 key；未配置模板或取不到值时返回 false
+func (e *rateLimitMiddleware) templateKey(r *http.Request) (string, bool) {
+	keys := e.keys.Load()
+	if keys == nil {
+		return "", false
+	}
+	return keys.Key(r)
+}
+
+// defaultKey 全局限流与未指定 key 的动态决策使用的 key：模板优先，取不到值时按 DefaultScope 生成
+func (e *rateLimitMiddleware) defaultKey(r *http.Request) string {
+	if key, ok := e.templateKey(r); ok {
+		return fmt.Sprintf(keyFormatTemplate, key)
+	}
+	return e.generateKey(r, e.config.DefaultScope)
+}
@@ -0,0 +1,183 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_leaky.go
+ * @Description: 漏桶限流器（队列模式）- 请求按 1/RPS 的固定间隔依次放行，桶内最多排队 BurstSize 个请求，
+ *               排队的请求在 Allow 中等待到自己的放行时刻，桶满时直接拒绝；
+ *               与令牌桶相比不允许突发流量直接通过，下游看到的是匀速请求
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// keyFormatLeakyBucket 漏桶 key 格式（以 {prefix}:{key}: 开头，Reset 可按前缀删除）
+const keyFormatLeakyBucket = "%s:%s:leaky:rps_%d:cap_%d"
+
+// leakyBucketScript 漏桶脚本：KEYS[1] 保存下一个空闲放行时刻（微秒），
+// 返回本次请求需要等待的微秒数，-1 表示桶已满
+var leakyBucketScript = store.NewScript(`
+		local key = KEYS[1]
+		local now = tonumber(ARGV[1])
+		local interval = tonumber(ARGV[2])
+		local max_wait = tonumber(ARGV[3])
+
+		local next_free = tonumber(redis.call('GET', key) or now)
+		local start = math.max(now, next_free)
+		local wait = start - now
+		if wait > max_wait then
+			return -1
+		end
+
+		local next_start = start + interval
+		redis.call('SET', key, next_start, 'PX', math.ceil((next_start - now) / 1000) + 1)
+		return wait
+	`, leakyBucketLocal)
+
+// leakyBucketLocal 漏桶脚本的 Go 实现
+func leakyBucketLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("leaky bucket script: invalid keys or args")
+	}
+	now, interval, maxWait, err := rateLimitScriptArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	start := now
+	raw, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	if raw != "" {
+		nextFree, parseErr := strconv.ParseInt(raw, 10, 64)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		start = max(now, nextFree)
+	}
+	wait := start - now
+	if wait > maxWait {
+		return int64(-1), nil
+	}
+
+	nextStart := start + interval
+	ttl := time.Duration(nextStart-now)*time.Microsecond + time.Millisecond
+	if err := cmd.Set(ctx, keys[0], strconv.FormatInt(nextStart, 10), ttl); err != nil {
+		return nil, err
+	}
+	return wait, nil
+}
+
+// rateLimitScriptArgs 解析漏桶 / GCRA 脚本的前三个整数参数
+func rateLimitScriptArgs(args []any) (int64, int64, int64, error) {
+	var values [3]int64
+	for i := range values {
+		v, err := store.ArgInt64(args[i])
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		values[i] = v
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// emissionInterval 每个请求占用的时间（微秒），RPS 超过 100 万时按 1 微秒计
+func emissionInterval(rule *ratelimit.LimitRule) int64 {
+	return max(int64(time.Second/time.Microsecond)/int64(max(rule.RequestsPerSecond, 1)), 1)
+}
+
+// LeakyBucketLimiter 漏桶限流器（基于状态存储：Redis 执行 Lua，内嵌存储执行等价的 Go 实现）
+type LeakyBucketLimiter struct {
+	config *ratelimit.RateLimit
+}
+
+// NewLeakyBucketLimiter 创建漏桶限流器
+func NewLeakyBucketLimiter(config *ratelimit.RateLimit) *LeakyBucketLimiter {
+	return &LeakyBucketLimiter{
+		config: config,
+	}
+}
+
+// Allow 检查是否允许请求；需要排队时阻塞到放行时刻，ctx 先结束时返回 false（已占用的放行时刻不归还）
+func (l *LeakyBucketLimiter) Allow(ctx context.Context, key string, rule *ratelimit.LimitRule) (bool, error) {
+	return l.take(ctx, key, rule, 1, false)
+}
+
+// AllowN 请求占用 n 个放行间隔，排队与拒绝规则同 Allow
+func (l *LeakyBucketLimiter) AllowN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) (bool, error) {
+	return l.take(ctx, key, rule, n, false)
+}
+
+// ChargeN 无条件占用 n 个放行间隔且不等待，推迟后续请求的放行时刻
+func (l *LeakyBucketLimiter) ChargeN(ctx context.Context, key string, rule *ratelimit.LimitRule, n int) error {
+	_, err := l.take(ctx, key, rule, n, true)
+	return err
+}
+
+// take 占用 n 个放行间隔，force 为 true 时不检查桶容量也不等待
+func (l *LeakyBucketLimiter) take(ctx context.Context, key string, rule *ratelimit.LimitRule, n int, force bool) (bool, error) {
+	if global.STORE == nil {
+		return false, fmt.Errorf("store not available for leaky bucket limiter")
+	}
+	keyPrefix := mathx.IfNotEmpty(l.config.Storage.KeyPrefix, defaultKeyPrefix)
+	fullKey := fmt.Sprintf(keyFormatLeakyBucket, keyPrefix, key, rule.RequestsPerSecond, rule.BurstSize)
+	interval := emissionInterval(rule)
+	maxWait := mathx.IF(force, int64(math.MaxInt64), interval*int64(max(rule.BurstSize, 0)))
+
+	result, err := global.STORE.Eval(ctx, leakyBucketScript, []string{fullKey},
+		time.Now().UnixMicro(),
+		interval*int64(n),
+		maxWait,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute leaky bucket script: %w", err)
+	}
+	wait, ok := result.(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected result type: %T", result)
+	}
+	if wait < 0 {
+		return false, nil
+	}
+	if wait == 0 || force {
+		return true, nil
+	}
+
+	timer := time.NewTimer(time.Duration(wait) * time.Microsecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true, nil
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+// Reset 重置限流器（按前缀删除）
+func (l *LeakyBucketLimiter) Reset(ctx context.Context, key string) error {
+	deleter, ok := global.STORE.(store.PrefixDeleter)
+	if !ok {
+		return nil
+	}
+	keyPrefix := mathx.IfNotEmpty(l.config.Storage.KeyPrefix, defaultKeyPrefix)
+	prefix := strings.TrimSuffix(fmt.Sprintf(keyFormatResetPattern, keyPrefix, key), "*")
+	_, err := deleter.DeletePrefix(ctx, prefix)
+	return err
+}
@@ -0,0 +1,421 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_penalty.go
+ * @Description: 限流升级处罚 - 同一限流 key 在 StrikeTTL 内反复超限达到阈值后整体封禁，
+ *               首次封禁时长取规则的 BlockDuration，之后每次再犯按倍数延长直至上限；
+ *               违规次数与封禁记录保存在状态存储中（Redis 时多实例共享），管理接口可查看与手动解封
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 升级处罚默认值
+const (
+	DefaultRateLimitPenaltyThreshold  = 3
+	DefaultRateLimitPenaltyMultiplier = 2.0
+	DefaultRateLimitPenaltyMaxBlock   = 24 * time.Hour
+	DefaultRateLimitPenaltyStrikeTTL  = time.Hour
+	DefaultRateLimitPenaltyAdminPath  = "/admin/ratelimit/bans"
+	DefaultRateLimitPenaltyKeyPrefix  = "ratelimit:penalty"
+)
+
+// 升级处罚指标
+var (
+	rateLimitBansCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_ratelimit_bans_total",
+		Help: "Total number of rate limit penalty blocks issued",
+	})
+
+	rateLimitBannedRequestsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_ratelimit_banned_requests_total",
+		Help: "Total number of requests rejected because the rate limit key is blocked",
+	})
+)
+
+// RateLimitPenaltyConfig 限流升级处罚配置
+type RateLimitPenaltyConfig struct {
+	Threshold            int           // StrikeTTL 内超限多少次后开始封禁，默认 3
+	Multiplier           float64       // 每次再犯的封禁时长倍数，默认 2
+	MaxBlockDuration     time.Duration // 单次封禁时长上限，默认 24h
+	StrikeTTL            time.Duration // 超限次数的保留时间，期间无新的超限则清零，默认 1h
+	DefaultBlockDuration time.Duration // 规则未设置 BlockDuration 时的首次封禁时长，0 表示这类规则不封禁
+	KeyPrefix            string        // 状态存储 key 前缀，默认 ratelimit:penalty
+	AdminPath            string        // 封禁管理接口，默认 /admin/ratelimit/bans
+}
+
+// RateLimitBan 一条封禁记录
+type RateLimitBan struct {
+	Key       string    `json:"key"`
+	Strikes   int64     `json:"strikes"`
+	BlockedAt time.Time `json:"blocked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// penaltyStrikeScript 记录一次超限：递增次数，达到阈值时写入带过期时间的封禁 key，返回 {次数, 封禁毫秒数}；
+// 每个限流 key 单独一条封禁记录，值为 "到期毫秒:封禁毫秒:次数"，列表通过前缀扫描获得
+// KEYS: 次数 key、封禁 key；ARGV: 当前毫秒、首次封禁毫秒、倍数、上限毫秒、次数保留毫秒、阈值
+var penaltyStrikeScript = store.NewScript(`
+		local now = tonumber(ARGV[1])
+		local base = tonumber(ARGV[2])
+		local multiplier = tonumber(ARGV[3])
+		local max_block = tonumber(ARGV[4])
+		local strike_ttl = tonumber(ARGV[5])
+		local threshold = tonumber(ARGV[6])
+
+		local strikes = redis.call('INCR', KEYS[1])
+		redis.call('PEXPIRE', KEYS[1], strike_ttl)
+		if strikes < threshold then
+			return {strikes, 0}
+		end
+
+		local duration = base * (multiplier ^ (strikes - threshold))
+		if duration > max_block then
+			duration = max_block
+		end
+		duration = math.floor(duration)
+		redis.call('SET', KEYS[2], string.format('%d:%d:%d', now + duration, now, strikes), 'PX', duration)
+		return {strikes, duration}
+	`, penaltyStrikeLocal)
+
+// penaltyStrikeLocal 超限记录脚本的 Go 实现
+func penaltyStrikeLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 2 || len(args) < 6 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "penalty strike script: invalid keys or args")
+	}
+	ints := make([]int64, 0, 5)
+	for _, i := range []int{0, 1, 3, 4, 5} {
+		v, err := store.ArgInt64(args[i])
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, v)
+	}
+	now, base, maxBlock, strikeTTL, threshold := ints[0], ints[1], ints[2], ints[3], ints[4]
+	multiplier, err := strconv.ParseFloat(store.ArgString(args[2]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	strikes, err := cmd.Incr(ctx, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Expire(ctx, keys[0], time.Duration(strikeTTL)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if strikes < threshold {
+		return []any{strikes, int64(0)}, nil
+	}
+
+	duration := math.Min(float64(base)*math.Pow(multiplier, float64(strikes-threshold)), float64(maxBlock))
+	blockMs := int64(duration)
+	value := fmt.Sprintf("%d:%d:%d", now+blockMs, now, strikes)
+	if err := cmd.Set(ctx, keys[1], value, time.Duration(blockMs)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return []any{strikes, blockMs}, nil
+}
+
+// penaltyUnbanScript 解除封禁并清零超限次数，返回是否存在封禁
+// KEYS: 次数 key、封禁 key
+var penaltyUnbanScript = store.NewScript(`
+		local found = redis.call('DEL', KEYS[2])
+		redis.call('DEL', KEYS[1])
+		return found
+	`, penaltyUnbanLocal)
+
+// penaltyUnbanLocal 解除封禁脚本的 Go 实现
+func penaltyUnbanLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 2 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "penalty unban script: invalid keys")
+	}
+	found := int64(0)
+	if _, err := cmd.Get(ctx, keys[1]); err == nil {
+		found = 1
+	}
+	if err := cmd.Del(ctx, keys[0], keys[1]); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// parsePenaltyBan 解析封禁 key 的值 "到期毫秒:封禁毫秒:次数"
+func parsePenaltyBan(value string) (expiresAt, blockedAt, strikes int64, err error) {
+	fields := strings.Split(value, ":")
+	if len(fields) != 3 {
+		return 0, 0, 0, errors.NewErrorf(errors.ErrCodeInvalidParameter, "ratelimit penalty: malformed ban %q", value)
+	}
+	if expiresAt, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if blockedAt, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if strikes, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	return expiresAt, blockedAt, strikes, nil
+}
+
+// RateLimitPenalty 限流升级处罚
+type RateLimitPenalty struct {
+	config RateLimitPenaltyConfig
+}
+
+// NewRateLimitPenalty 创建限流升级处罚
+func NewRateLimitPenalty(cfg RateLimitPenaltyConfig) (*RateLimitPenalty, error) {
+	if cfg.Threshold < 0 || cfg.MaxBlockDuration < 0 || cfg.StrikeTTL < 0 || cfg.DefaultBlockDuration < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "ratelimit penalty: threshold and durations must not be negative")
+	}
+	if cfg.Multiplier != 0 && cfg.Multiplier < 1 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit penalty: multiplier %v must be at least 1", cfg.Multiplier)
+	}
+	if cfg.Threshold == 0 {
+		cfg.Threshold = DefaultRateLimitPenaltyThreshold
+	}
+	if cfg.Multiplier == 0 {
+		cfg.Multiplier = DefaultRateLimitPenaltyMultiplier
+	}
+	if cfg.MaxBlockDuration == 0 {
+		cfg.MaxBlockDuration = DefaultRateLimitPenaltyMaxBlock
+	}
+	if cfg.StrikeTTL == 0 {
+		cfg.StrikeTTL = DefaultRateLimitPenaltyStrikeTTL
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultRateLimitPenaltyKeyPrefix
+	}
+	if cfg.AdminPath == "" {
+		cfg.AdminPath = DefaultRateLimitPenaltyAdminPath
+	}
+	return &RateLimitPenalty{config: cfg}, nil
+}
+
+// AdminPath 封禁管理接口路径
+func (p *RateLimitPenalty) AdminPath() string {
+	return p.config.AdminPath
+}
+
+// 处罚 key 后缀
+const (
+	penaltyStrikesSuffix = "}:strikes"
+	penaltyBanSuffix     = "}:ban"
+)
+
+// keys 限流 key 对应的次数 key 与封禁 key；hash tag 包含限流 key，同一 key 的两条记录落在同一分片，
+// 不同 key 分散到各分片，避免每个请求的封禁检查集中在一个分片上
+func (p *RateLimitPenalty) keys(subject string) []string {
+	tag := p.subjectPrefix() + subject
+	return []string{tag + penaltyStrikesSuffix, tag + penaltyBanSuffix}
+}
+
+// subjectPrefix 处罚 key 的公共前缀，列出封禁时按该前缀扫描全部分片
+func (p *RateLimitPenalty) subjectPrefix() string {
+	return "{" + p.config.KeyPrefix + ":"
+}
+
+// Blocked 返回限流 key 剩余的封禁时长，未封禁时为 0
+func (p *RateLimitPenalty) Blocked(ctx context.Context, subject string) (time.Duration, error) {
+	if global.STORE == nil {
+		return 0, nil
+	}
+	raw, err := global.STORE.Get(ctx, p.keys(subject)[1])
+	if err == store.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	expiresAt, _, _, err := parsePenaltyBan(raw)
+	if err != nil {
+		return 0, err
+	}
+	return max(time.Until(time.UnixMilli(expiresAt)), 0), nil
+}
+
+// Strike 记录一次超限，达到阈值时封禁并返回封禁时长；规则与配置均未设置封禁时长时不处罚
+func (p *RateLimitPenalty) Strike(ctx context.Context, subject string, rule *ratelimit.LimitRule) (time.Duration, error) {
+	base := p.config.DefaultBlockDuration
+	if rule != nil && rule.BlockDuration > 0 {
+		base = rule.BlockDuration
+	}
+	if base <= 0 || global.STORE == nil {
+		return 0, nil
+	}
+	result, err := global.STORE.Eval(ctx, penaltyStrikeScript, p.keys(subject),
+		time.Now().UnixMilli(),
+		base.Milliseconds(),
+		strconv.FormatFloat(p.config.Multiplier, 'f', -1, 64),
+		max(p.config.MaxBlockDuration, base).Milliseconds(),
+		p.config.StrikeTTL.Milliseconds(),
+		p.config.Threshold,
+	)
+	if err != nil {
+		return 0, err
+	}
+	values, ok := result.([]any)
+	if !ok || len(values) != 2 {
+		return 0, errors.NewErrorf(errors.ErrCodeInternalServerError, "ratelimit penalty: unexpected script result %T", result)
+	}
+	strikes, _ := values[0].(int64)
+	blockMs, _ := values[1].(int64)
+	if blockMs <= 0 {
+		return 0, nil
+	}
+	block := time.Duration(blockMs) * time.Millisecond
+	rateLimitBansCounter.Inc()
+	global.LOGGER.WarnKV("限流 key 已被封禁", "key", subject, "strikes", strikes, "duration", block.String())
+	return block, nil
+}
+
+// Unban 手动解除封禁并清零超限次数，返回是否存在封禁
+func (p *RateLimitPenalty) Unban(ctx context.Context, subject string) (bool, error) {
+	if global.STORE == nil {
+		return false, nil
+	}
+	result, err := global.STORE.Eval(ctx, penaltyUnbanScript, p.keys(subject))
+	if err != nil {
+		return false, err
+	}
+	found, _ := result.(int64)
+	if found == 1 {
+		global.LOGGER.InfoKV("限流封禁已手动解除", "key", subject)
+	}
+	return found == 1, nil
+}
+
+// Bans 当前生效的封禁（按到期时间排序），状态存储需支持前缀扫描
+func (p *RateLimitPenalty) Bans(ctx context.Context) ([]RateLimitBan, error) {
+	if global.STORE == nil {
+		return nil, nil
+	}
+	scanner, ok := global.STORE.(store.PrefixScanner)
+	if !ok {
+		return nil, errors.NewError(errors.ErrCodeInternalServerError, "ratelimit penalty: store does not support listing bans")
+	}
+	prefix := p.subjectPrefix()
+	entries, err := scanner.ScanPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	bans := make([]RateLimitBan, 0, len(entries))
+	for key, value := range entries {
+		if !strings.HasSuffix(key, penaltyBanSuffix) {
+			continue
+		}
+		expiresAt, blockedAt, strikes, err := parsePenaltyBan(value)
+		if err != nil || expiresAt <= now {
+			continue
+		}
+		bans = append(bans, RateLimitBan{
+			Key:       strings.TrimSuffix(strings.TrimPrefix(key, prefix), penaltyBanSuffix),
+			Strikes:   strikes,
+			BlockedAt: time.UnixMilli(blockedAt),
+			ExpiresAt: time.UnixMilli(expiresAt),
+		})
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].ExpiresAt.Before(bans[j].ExpiresAt) })
+	return bans, nil
+}
+
+// AdminHandler 封禁管理接口：
+//
+//	GET    {path}           当前封禁列表
+//	DELETE {path}?key=ip:x  解除指定限流 key 的封禁
+func (p *RateLimitPenalty) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			bans, err := p.Bans(r.Context())
+			if err != nil {
+				response.WriteAppErrorf(w, errors.ErrCodeInternalServerError, "list rate limit bans: %v", err)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, map[string]any{"bans": bans})
+		case http.MethodDelete:
+			subject := r.URL.Query().Get("key")
+			if subject == "" {
+				response.WriteBadRequestResult(w, "query parameter key is required")
+				return
+			}
+			found, err := p.Unban(r.Context(), subject)
+			if err != nil {
+				response.WriteAppErrorf(w, errors.ErrCodeInternalServerError, "unban %s: %v", subject, err)
+				return
+			}
+			if !found {
+				response.WriteNotFoundResult(w, "rate limit key is not banned: "+subject)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, map[string]any{"key": subject, "unbanned": true})
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "rate limit bans support GET and DELETE")
+		}
+	}
+}
+
+// retryAfterSeconds Retry-After 秒数（向上取整，至少 1 秒）
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(max(int64(math.Ceil(d.Seconds())), 1), 10)
+}
+
+// penaltyBlocked 限流 key 剩余的封禁时长；未启用处罚或存储出错时放行
+func (e *rateLimitMiddleware) penaltyBlocked(ctx context.Context, key string) time.Duration {
+	penalty := e.penalty.Load()
+	if penalty == nil {
+		return 0
+	}
+	blocked, err := penalty.Blocked(ctx, key)
+	if err != nil {
+		global.LOGGER.WarnKV("读取限流封禁状态失败", "key", key, "error", err)
+		return 0
+	}
+	if blocked > 0 {
+		rateLimitBannedRequestsCounter.Inc()
+	}
+	return blocked
+}
+
+// penaltyStrike 记录一次超限，返回因此产生的封禁时长
+func (e *rateLimitMiddleware) penaltyStrike(ctx context.Context, decision RateLimitDecision) time.Duration {
+	penalty := e.penalty.Load()
+	if penalty == nil {
+		return 0
+	}
+	block, err := penalty.Strike(ctx, decision.Key, decision.Rule)
+	if err != nil {
+		global.LOGGER.WarnKV("记录限流超限次数失败", "key", decision.Key, "error", err)
+		return 0
+	}
+	return block
+}
+
+// penaltyError 封禁期间的拒绝错误
+func penaltyError(blocked time.Duration) *errors.AppError {
+	return errors.NewErrorf(errors.ErrCodeRateLimitExceeded, "rate limit exceeded repeatedly, blocked for %s", blocked.Round(time.Second))
+}
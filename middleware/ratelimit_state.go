@@ -0,0 +1,596 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_state.go
+ * @Description: 限流状态观测 - 跟踪最近出现的限流 key 的放行 / 拒绝次数，按路由统计拒绝率，
+ *               定期把被拒绝最多的 key 导出为 Prometheus 指标，管理接口分页查看每个 key 的实时额度
+ *               （剩余令牌、窗口计数、排队数），用于排查某个客户为什么被限流
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 限流状态观测默认值
+const (
+	DefaultRateLimitStateMaxKeys     = 10000
+	DefaultRateLimitStateTopN        = 10
+	DefaultRateLimitStateRefresh     = 15 * time.Second
+	DefaultRateLimitStatePageSize    = 50
+	DefaultRateLimitStateMaxPageSize = 500
+	DefaultRateLimitStateAdminPath   = "/admin/ratelimit/state"
+)
+
+// 限流状态排序方式（管理接口 sort 参数）
+const (
+	RateLimitStateSortRejected = "rejected" // 拒绝次数降序（默认）
+	RateLimitStateSortAllowed  = "allowed"  // 放行次数降序
+	RateLimitStateSortRecent   = "recent"   // 最近出现时间降序
+)
+
+// 限流状态指标
+var (
+	rateLimitRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_ratelimit_requests_total",
+		Help: "Total number of rate limit decisions by route and result",
+	}, []string{"route", "result"})
+
+	rateLimitTrackedKeys = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_ratelimit_tracked_keys",
+		Help: "Number of rate limit keys currently tracked for introspection",
+	})
+
+	rateLimitTopKeyRejections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_ratelimit_top_key_rejections",
+		Help: "Rejections since tracking began for the most limited rate limit keys",
+	}, []string{"key", "route"})
+
+	rateLimitTopKeyRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_ratelimit_top_key_remaining",
+		Help: "Remaining quota of the most limited rate limit keys at the last refresh",
+	}, []string{"key", "route"})
+)
+
+// RateLimitStateConfig 限流状态观测配置
+type RateLimitStateConfig struct {
+	MaxKeys         int           // 跟踪的限流 key 上限，默认 10000，达到上限时淘汰 1/8（优先淘汰从未被拒绝、最久未出现的 key）
+	TopN            int           // 导出为指标的被拒绝最多的 key 数，默认 10
+	RefreshInterval time.Duration // top-N 指标刷新间隔，默认 15s
+	PageSize        int           // 管理接口默认每页条数，默认 50
+	MaxPageSize     int           // 管理接口每页条数上限，默认 500
+	AdminPath       string        // 管理接口，默认 /admin/ratelimit/state
+}
+
+// RateLimitUsage 限流器报告的实时额度
+type RateLimitUsage struct {
+	Remaining float64   `json:"remaining"`         // 剩余额度（令牌数 / 窗口余量 / 可排队数），透支时为负数
+	Used      int64     `json:"used"`              // 已用额度（窗口计数 / 排队数 / 已消耗令牌）
+	ResetAt   time.Time `json:"reset_at,omitzero"` // 额度完全恢复的时间，滑动窗口不提供
+}
+
+// RateLimitInspector 可查询实时额度的限流器（只读，不消耗额度），内置策略均已实现
+type RateLimitInspector interface {
+	Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error)
+}
+
+// RateLimitState 一个限流 key 的观测状态
+type RateLimitState struct {
+	Key               string             `json:"key"`
+	Route             string             `json:"route"`
+	Strategy          ratelimit.Strategy `json:"strategy"`
+	RequestsPerSecond int                `json:"requests_per_second"`
+	BurstSize         int                `json:"burst_size"`
+	Allowed           uint64             `json:"allowed"`
+	Rejected          uint64             `json:"rejected"`
+	Banned            uint64             `json:"banned"`
+	LastSeen          time.Time          `json:"last_seen"`
+	LastRejected      *time.Time         `json:"last_rejected,omitempty"`
+	Usage             *RateLimitUsage    `json:"usage,omitempty"`
+	Error             string             `json:"error,omitempty"`
+}
+
+// RateLimitRouteStats 路由维度的判定统计
+type RateLimitRouteStats struct {
+	Route         string  `json:"route"`
+	Allowed       uint64  `json:"allowed"`
+	Rejected      uint64  `json:"rejected"`
+	Banned        uint64  `json:"banned"`
+	RejectionRate float64 `json:"rejection_rate"` // (rejected + banned) / 总数
+}
+
+// rateLimitCounts 放行 / 拒绝 / 封禁计数
+type rateLimitCounts struct {
+	allowed  atomic.Uint64
+	rejected atomic.Uint64
+	banned   atomic.Uint64
+}
+
+// add 按判定结果计数
+func (c *rateLimitCounts) add(result string) {
+	switch result {
+	case constants.RateLimitResultAllowed:
+		c.allowed.Add(1)
+	case constants.RateLimitResultRejected:
+		c.rejected.Add(1)
+	case constants.RateLimitResultBanned:
+		c.banned.Add(1)
+	}
+}
+
+// rateLimitTarget 限流 key 最近一次判定使用的规则与限流器
+type rateLimitTarget struct {
+	strategy ratelimit.Strategy
+	rule     *ratelimit.LimitRule
+	limiter  RateLimiter
+}
+
+// rateLimitKeyStats 一个限流 key 的跟踪记录
+type rateLimitKeyStats struct {
+	rateLimitCounts
+	key          string
+	route        string
+	target       atomic.Pointer[rateLimitTarget] // 动态规则每次返回新的规则对象时随之替换，计数保留
+	lastSeen     atomic.Int64                    // 纳秒
+	lastRejected atomic.Int64                    // 纳秒，0 表示未被拒绝
+}
+
+// RateLimitStats 限流状态跟踪器，HTTP 限流中间件与 gRPC 限流拦截器共用
+type RateLimitStats struct {
+	config RateLimitStateConfig
+
+	mu     sync.RWMutex
+	keys   map[string]*rateLimitKeyStats
+	routes map[string]*rateLimitCounts
+
+	lastRefresh atomic.Int64
+	refreshing  atomic.Bool
+}
+
+// NewRateLimitStats 校验配置并创建限流状态跟踪器
+func NewRateLimitStats(cfg RateLimitStateConfig) (*RateLimitStats, error) {
+	if cfg.MaxKeys < 0 || cfg.TopN < 0 || cfg.RefreshInterval < 0 || cfg.PageSize < 0 || cfg.MaxPageSize < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "ratelimit state: limits and intervals must not be negative")
+	}
+	cfg.MaxKeys = mathx.IfNotZero(cfg.MaxKeys, DefaultRateLimitStateMaxKeys)
+	cfg.TopN = mathx.IfNotZero(cfg.TopN, DefaultRateLimitStateTopN)
+	cfg.RefreshInterval = mathx.IfNotZero(cfg.RefreshInterval, DefaultRateLimitStateRefresh)
+	cfg.PageSize = mathx.IfNotZero(cfg.PageSize, DefaultRateLimitStatePageSize)
+	cfg.MaxPageSize = mathx.IfNotZero(cfg.MaxPageSize, DefaultRateLimitStateMaxPageSize)
+	cfg.AdminPath = mathx.IfNotEmpty(cfg.AdminPath, DefaultRateLimitStateAdminPath)
+	if cfg.PageSize > cfg.MaxPageSize {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "ratelimit state: page size %d exceeds max page size %d", cfg.PageSize, cfg.MaxPageSize)
+	}
+	return &RateLimitStats{
+		config: cfg,
+		keys:   make(map[string]*rateLimitKeyStats),
+		routes: make(map[string]*rateLimitCounts),
+	}, nil
+}
+
+// AdminPath 管理接口路径
+func (s *RateLimitStats) AdminPath() string {
+	return s.config.AdminPath
+}
+
+// Record 记录一次限流判定，result 取 constants.RateLimitResult*；封禁期内的请求未经过限流器，limiter 为 nil
+func (s *RateLimitStats) Record(route string, decision RateLimitDecision, limiter RateLimiter, result string) {
+	rateLimitRequestsTotal.WithLabelValues(route, result).Inc()
+	now := time.Now().UnixNano()
+
+	s.mu.RLock()
+	entry := s.keys[decision.Key]
+	counts := s.routes[route]
+	s.mu.RUnlock()
+	if entry == nil || counts == nil {
+		entry, counts = s.track(route, decision.Key)
+	}
+	if target := entry.target.Load(); limiter != nil && (target == nil || target.rule != decision.Rule || target.limiter != limiter) {
+		entry.target.Store(&rateLimitTarget{strategy: decision.Strategy, rule: decision.Rule, limiter: limiter})
+	}
+
+	entry.add(result)
+	counts.add(result)
+	entry.lastSeen.Store(now)
+	if result != constants.RateLimitResultAllowed {
+		entry.lastRejected.Store(now)
+	}
+
+	if last := s.lastRefresh.Load(); now-last >= int64(s.config.RefreshInterval) && s.refreshing.CompareAndSwap(false, true) {
+		s.lastRefresh.Store(now)
+		go s.refresh()
+	}
+}
+
+// track 登记新的限流 key 与路由，达到上限时先淘汰一批 key
+func (s *RateLimitStats) track(route, key string) (*rateLimitKeyStats, *rateLimitCounts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.routes[route]
+	if counts == nil {
+		counts = &rateLimitCounts{}
+		s.routes[route] = counts
+	}
+	entry := s.keys[key]
+	if entry != nil {
+		return entry, counts
+	}
+	if len(s.keys) >= s.config.MaxKeys {
+		s.evictLocked()
+	}
+	entry = &rateLimitKeyStats{key: key, route: route}
+	s.keys[key] = entry
+	rateLimitTrackedKeys.Set(float64(len(s.keys)))
+	return entry, counts
+}
+
+// evictLocked 淘汰 1/8 key（调用方持有写锁）：先淘汰从未被拒绝的，同类中最久未出现的优先，
+// 大量新 key 涌入时仍保留被限流的 key 供排查
+func (s *RateLimitStats) evictLocked() {
+	entries := make([]*rateLimitKeyStats, 0, len(s.keys))
+	for _, entry := range s.keys {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ri, rj := entries[i].lastRejected.Load() > 0, entries[j].lastRejected.Load() > 0
+		if ri != rj {
+			return rj
+		}
+		return entries[i].lastSeen.Load() < entries[j].lastSeen.Load()
+	})
+	for _, entry := range entries[:max(len(entries)/8, 1)] {
+		delete(s.keys, entry.key)
+	}
+}
+
+// refresh 重新计算被拒绝最多的 key 并更新指标
+func (s *RateLimitStats) refresh() {
+	defer s.refreshing.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.RefreshInterval)
+	defer cancel()
+
+	top := s.snapshot("", "", RateLimitStateSortRejected)
+	top = top[:min(len(top), s.config.TopN)]
+	rateLimitTopKeyRejections.Reset()
+	rateLimitTopKeyRemaining.Reset()
+	for _, entry := range top {
+		rejected := entry.rejected.Load() + entry.banned.Load()
+		if rejected == 0 {
+			break
+		}
+		rateLimitTopKeyRejections.WithLabelValues(entry.key, entry.route).Set(float64(rejected))
+		if state := entry.state(ctx); state.Usage != nil {
+			rateLimitTopKeyRemaining.WithLabelValues(entry.key, entry.route).Set(state.Usage.Remaining)
+		}
+	}
+}
+
+// snapshot 按 key 前缀与路由过滤并排序的跟踪记录
+func (s *RateLimitStats) snapshot(keyPrefix, route, sortBy string) []*rateLimitKeyStats {
+	s.mu.RLock()
+	entries := make([]*rateLimitKeyStats, 0, len(s.keys))
+	for _, entry := range s.keys {
+		if strings.HasPrefix(entry.key, keyPrefix) && (route == "" || entry.route == route) {
+			entries = append(entries, entry)
+		}
+	}
+	s.mu.RUnlock()
+
+	var value func(e *rateLimitKeyStats) uint64
+	switch sortBy {
+	case RateLimitStateSortAllowed:
+		value = func(e *rateLimitKeyStats) uint64 { return e.allowed.Load() }
+	case RateLimitStateSortRecent:
+		value = func(e *rateLimitKeyStats) uint64 { return uint64(e.lastSeen.Load()) }
+	default:
+		value = func(e *rateLimitKeyStats) uint64 { return e.rejected.Load() + e.banned.Load() }
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		vi, vj := value(entries[i]), value(entries[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// state 跟踪记录与限流器实时额度
+func (e *rateLimitKeyStats) state(ctx context.Context) RateLimitState {
+	state := RateLimitState{
+		Key:      e.key,
+		Route:    e.route,
+		Allowed:  e.allowed.Load(),
+		Rejected: e.rejected.Load(),
+		Banned:   e.banned.Load(),
+		LastSeen: time.Unix(0, e.lastSeen.Load()),
+	}
+	if last := e.lastRejected.Load(); last > 0 {
+		at := time.Unix(0, last)
+		state.LastRejected = &at
+	}
+	target := e.target.Load()
+	if target == nil || target.rule == nil {
+		return state
+	}
+	state.Strategy = target.strategy
+	state.RequestsPerSecond, state.BurstSize = target.rule.RequestsPerSecond, target.rule.BurstSize
+	inspector, ok := target.limiter.(RateLimitInspector)
+	if !ok {
+		return state
+	}
+	usage, err := inspector.Inspect(ctx, e.key, target.rule)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	state.Usage = &usage
+	return state
+}
+
+// States 分页查询跟踪中的限流 key 及实时额度，返回当前页与过滤后的总数
+func (s *RateLimitStats) States(ctx context.Context, keyPrefix, route, sortBy string, page, size int) ([]RateLimitState, int) {
+	entries := s.snapshot(keyPrefix, route, sortBy)
+	total := len(entries)
+	start := min((page-1)*size, total)
+	entries = entries[start:min(start+size, total)]
+	states := make([]RateLimitState, len(entries))
+	for i, entry := range entries {
+		states[i] = entry.state(ctx)
+	}
+	return states, total
+}
+
+// Routes 路由维度的判定统计，按拒绝率降序
+func (s *RateLimitStats) Routes() []RateLimitRouteStats {
+	s.mu.RLock()
+	routes := make([]RateLimitRouteStats, 0, len(s.routes))
+	for route, counts := range s.routes {
+		stats := RateLimitRouteStats{
+			Route:    route,
+			Allowed:  counts.allowed.Load(),
+			Rejected: counts.rejected.Load(),
+			Banned:   counts.banned.Load(),
+		}
+		if total := stats.Allowed + stats.Rejected + stats.Banned; total > 0 {
+			stats.RejectionRate = float64(stats.Rejected+stats.Banned) / float64(total)
+		}
+		routes = append(routes, stats)
+	}
+	s.mu.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].RejectionRate != routes[j].RejectionRate {
+			return routes[i].RejectionRate > routes[j].RejectionRate
+		}
+		return routes[i].Route < routes[j].Route
+	})
+	return routes
+}
+
+// AdminHandler 限流状态管理接口：
+//
+//	GET {path}?page=1&size=50&sort=rejected|allowed|recent&key=ip:&route=/api/search
+//
+// 返回当前页的限流 key（含实时额度）、分页信息与各路由的拒绝率
+func (s *RateLimitStats) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "rate limit state supports GET")
+			return
+		}
+		query := r.URL.Query()
+		page, size := 1, s.config.PageSize
+		var err error
+		if raw := query.Get("page"); raw != "" {
+			if page, err = strconv.Atoi(raw); err != nil || page < 1 {
+				response.WriteBadRequestResult(w, "page must be a positive integer")
+				return
+			}
+		}
+		if raw := query.Get("size"); raw != "" {
+			if size, err = strconv.Atoi(raw); err != nil || size < 1 || size > s.config.MaxPageSize {
+				response.WriteBadRequestResult(w, fmt.Sprintf("size must be between 1 and %d", s.config.MaxPageSize))
+				return
+			}
+		}
+		sortBy := mathx.IfNotEmpty(query.Get("sort"), RateLimitStateSortRejected)
+		switch sortBy {
+		case RateLimitStateSortRejected, RateLimitStateSortAllowed, RateLimitStateSortRecent:
+		default:
+			response.WriteBadRequestResult(w, "sort must be one of rejected, allowed, recent")
+			return
+		}
+
+		items, total := s.States(r.Context(), query.Get("key"), query.Get("route"), sortBy, page, size)
+		response.WriteJSONResponse(w, http.StatusOK, map[string]any{
+			"items":  items,
+			"paging": map[string]int{"page": page, "size": size, "total": total},
+			"routes": s.Routes(),
+		})
+	}
+}
+
+// observeRateLimit 记录一次限流判定；未启用状态观测时无操作
+func (e *rateLimitMiddleware) observeRateLimit(route string, decision RateLimitDecision, limiter RateLimiter, result string) {
+	if stats := e.stats.Load(); stats != nil {
+		stats.Record(route, decision, limiter, result)
+	}
+}
+
+// routeLabel 限流指标的路由标签：命中的限流路由规则 > 指标路径标签 > other；未启用状态观测时为空
+func (e *rateLimitMiddleware) routeLabel(r *http.Request) string {
+	if e.stats.Load() == nil {
+		return ""
+	}
+	if route := e.matchRoute(r); route != nil {
+		return route.Path
+	}
+	if e.pathLabel != nil {
+		return e.pathLabel(r)
+	}
+	return constants.RateLimitRouteOther
+}
+
+// Inspect 当前令牌数（按经过的时间补充后计算，不修改桶），桶不存在时视为满
+func (t *TokenBucketLimiter) Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error) {
+	rule = mathx.IF(rule == nil, t.globalRule, rule)
+	if rule == nil {
+		return RateLimitUsage{}, nil
+	}
+	usage := RateLimitUsage{Remaining: float64(rule.BurstSize)}
+	value, ok := t.limiters.Load(fmt.Sprintf(keyFormatTokenBucket, key, rule.RequestsPerSecond, rule.BurstSize))
+	if !ok {
+		return usage, nil
+	}
+	bucket := value.(*atomicTokenBucket)
+	now := time.Now().UnixNano()
+	elapsed := max(now-atomic.LoadInt64(&bucket.lastRefillNano), 0)
+	tokens := atomic.LoadInt64(&bucket.tokensInt64) + elapsed/billion*bucket.refillRate + (elapsed%billion)*bucket.refillRate/billion
+	tokens = min(tokens, bucket.maxTokens*billion)
+
+	usage.Remaining = float64(tokens) / billion
+	usage.Used = bucket.maxTokens - tokens/billion
+	if missing := bucket.maxTokens*billion - tokens; missing > 0 && bucket.refillRate > 0 {
+		usage.ResetAt = time.Unix(0, now+int64(float64(missing)/float64(bucket.refillRate)*billion))
+	}
+	return usage, nil
+}
+
+// Inspect 当前窗口的计数与重置时间，窗口已过期时视为空
+func (f *FixedWindowLimiter) Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error) {
+	usage := RateLimitUsage{Remaining: float64(rule.RequestsPerSecond)}
+	value, ok := f.counters.Load(fmt.Sprintf(keyFormatFixedWindow, key, rule.WindowSize, rule.RequestsPerSecond))
+	if !ok {
+		return usage, nil
+	}
+	counter := value.(*atomicCounter)
+	resetAt := atomic.LoadInt64(&counter.resetTimeNano)
+	if time.Now().UnixNano() > resetAt {
+		return usage, nil
+	}
+	usage.Used = atomic.LoadInt64(&counter.count)
+	usage.Remaining = float64(int64(rule.RequestsPerSecond) - usage.Used)
+	usage.ResetAt = time.Unix(0, resetAt)
+	return usage, nil
+}
+
+// slidingWindowCountScript 统计滑动窗口内的请求数（只读）：KEYS[1] 窗口 key，ARGV[1] 窗口起点（纳秒，不含）
+var slidingWindowCountScript = store.NewScript(`
+		return redis.call('ZCOUNT', KEYS[1], '(' .. ARGV[1], '+inf')
+	`, slidingWindowCountLocal)
+
+// slidingWindowCountLocal 滑动窗口计数脚本的 Go 实现
+func slidingWindowCountLocal(ctx context.Context, cmd store.Commands, keys []string, args []any) (any, error) {
+	if len(keys) < 1 || len(args) < 1 {
+		return nil, fmt.Errorf("sliding window count script: invalid keys or args")
+	}
+	windowStart, err := store.ArgInt64(args[0])
+	if err != nil {
+		return nil, err
+	}
+	raw, err := cmd.Get(ctx, keys[0])
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	count := int64(0)
+	if raw != "" {
+		for _, item := range strings.Split(raw, ",") {
+			if ts, parseErr := strconv.ParseInt(item, 10, 64); parseErr == nil && ts > windowStart {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// Inspect 滑动窗口内的请求数
+func (s *SlidingWindowLimiter) Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error) {
+	if global.STORE == nil {
+		return RateLimitUsage{}, fmt.Errorf("store not available for sliding window limiter")
+	}
+	fullKey, _, _ := s.keys(key, rule)
+	result, err := global.STORE.Eval(ctx, slidingWindowCountScript, []string{fullKey}, time.Now().Add(-rule.WindowSize).UnixNano())
+	if err != nil {
+		return RateLimitUsage{}, fmt.Errorf("failed to execute sliding window count script: %w", err)
+	}
+	used, ok := result.(int64)
+	if !ok {
+		return RateLimitUsage{}, fmt.Errorf("unexpected result type: %T", result)
+	}
+	return RateLimitUsage{Remaining: float64(int64(rule.RequestsPerSecond) - used), Used: used}, nil
+}
+
+// loadRateLimitMicros 读取漏桶 / GCRA 保存的微秒时间戳，不存在时返回 0
+func loadRateLimitMicros(ctx context.Context, key string) (int64, error) {
+	if global.STORE == nil {
+		return 0, fmt.Errorf("store not available")
+	}
+	raw, err := global.STORE.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// Inspect 排队中的请求数与队列清空时间
+func (l *LeakyBucketLimiter) Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error) {
+	keyPrefix := mathx.IfNotEmpty(l.config.Storage.KeyPrefix, defaultKeyPrefix)
+	nextFree, err := loadRateLimitMicros(ctx, fmt.Sprintf(keyFormatLeakyBucket, keyPrefix, key, rule.RequestsPerSecond, rule.BurstSize))
+	if err != nil {
+		return RateLimitUsage{}, err
+	}
+	interval := emissionInterval(rule)
+	ahead := max(nextFree-time.Now().UnixMicro(), 0)
+	queued := (ahead + interval - 1) / interval
+	usage := RateLimitUsage{Remaining: float64(int64(rule.BurstSize) - queued), Used: queued}
+	if ahead > 0 {
+		usage.ResetAt = time.UnixMicro(nextFree)
+	}
+	return usage, nil
+}
+
+// Inspect 理论到达时间换算的剩余突发额度
+func (g *GCRALimiter) Inspect(ctx context.Context, key string, rule *ratelimit.LimitRule) (RateLimitUsage, error) {
+	keyPrefix := mathx.IfNotEmpty(g.config.Storage.KeyPrefix, defaultKeyPrefix)
+	tat, err := loadRateLimitMicros(ctx, fmt.Sprintf(keyFormatGCRA, keyPrefix, key, rule.RequestsPerSecond, rule.BurstSize))
+	if err != nil {
+		return RateLimitUsage{}, err
+	}
+	interval := emissionInterval(rule)
+	burst := int64(max(rule.BurstSize, 1))
+	ahead := max(tat-time.Now().UnixMicro(), 0)
+	used := (ahead + interval - 1) / interval
+	usage := RateLimitUsage{Remaining: float64(burst*interval-ahead) / float64(interval), Used: used}
+	if ahead > 0 {
+		usage.ResetAt = time.UnixMicro(tat)
+	}
+	return usage, nil
+}
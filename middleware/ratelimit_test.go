@@ -0,0 +1,94 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\ratelimit_test.go
+ * @Description: 限流器测试 - 滑动窗口在集群存储上执行脚本，三个 key 需落在同一分片
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kamalyes/go-config/pkg/ratelimit"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/store"
+)
+
+// newClusterStore 由多个内嵌存储组成的集群存储，测试结束后恢复 global.STORE
+func newClusterStore(t *testing.T, shards int) *store.ClusterStore {
+	t.Helper()
+	nodes := make([]store.ClusterNode, 0, shards)
+	for i := 0; i < shards; i++ {
+		memory, err := store.NewMemoryStore(nil)
+		if err != nil {
+			t.Fatalf("memory store: %v", err)
+		}
+		t.Cleanup(func() { _ = memory.Close() })
+		nodes = append(nodes, store.ClusterNode{Name: fmt.Sprintf("shard-%d", i), Store: memory})
+	}
+	cluster, err := store.NewClusterStore(nodes...)
+	if err != nil {
+		t.Fatalf("cluster store: %v", err)
+	}
+	previous := global.STORE
+	global.STORE = cluster
+	t.Cleanup(func() { global.STORE = previous })
+	return cluster
+}
+
+func TestSlidingWindowLimiterClusterStore(t *testing.T) {
+	cluster := newClusterStore(t, 4)
+	limiter := NewSlidingWindowLimiter(ratelimit.Default())
+	rule := &ratelimit.LimitRule{RequestsPerSecond: 3, WindowSize: time.Minute}
+	ctx := context.Background()
+
+	// 三个 key 共用 hash tag，必须落在同一分片，否则 Eval 返回 ErrCrossShard
+	for i := 0; i < 32; i++ {
+		key := fmt.Sprintf("ip:10.0.0.%d", i)
+		data, counter, lock := limiter.keys(key, rule)
+		if cluster.Node(data) != cluster.Node(counter) || cluster.Node(data) != cluster.Node(lock) {
+			t.Fatalf("%s: sliding window keys span multiple shards", key)
+		}
+
+		for n := 1; n <= 3; n++ {
+			allowed, err := limiter.Allow(ctx, key, rule)
+			if err != nil {
+				t.Fatalf("%s: request %d: %v", key, n, err)
+			}
+			if !allowed {
+				t.Fatalf("%s: request %d denied within limit", key, n)
+			}
+		}
+		allowed, err := limiter.Allow(ctx, key, rule)
+		if err != nil {
+			t.Fatalf("%s: request 4: %v", key, err)
+		}
+		if allowed {
+			t.Fatalf("%s: request 4 allowed over limit", key)
+		}
+
+		usage, err := limiter.Inspect(ctx, key, rule)
+		if err != nil {
+			t.Fatalf("%s: inspect: %v", key, err)
+		}
+		if usage.Used != 3 {
+			t.Fatalf("%s: used = %d, want 3", key, usage.Used)
+		}
+	}
+
+	if err := limiter.Reset(ctx, "ip:10.0.0.0"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	allowed, err := limiter.Allow(ctx, "ip:10.0.0.0", rule)
+	if err != nil || !allowed {
+		t.Fatalf("after reset: allowed=%v err=%v", allowed, err)
+	}
+}
@@ -13,7 +13,6 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -22,17 +21,30 @@ import (
 	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	commonapis "github.com/kamalyes/go-rpc-gateway/proto"
+	"github.com/kamalyes/go-rpc-gateway/response"
 	"github.com/kamalyes/go-toolbox/pkg/httpx"
 	"github.com/kamalyes/go-toolbox/pkg/netx"
 )
 
+// RecoveryHandlerFunc 自定义 panic 恢复处理器
+type RecoveryHandlerFunc func(w http.ResponseWriter, r *http.Request, err any)
+
 // RecoveryMiddleware 恢复中间件 - 处理 panic 恢复
 func RecoveryMiddleware(cfg *recovery.Recovery) HTTPMiddleware {
+	return RecoveryMiddlewareWithHandler(cfg, nil)
+}
+
+// RecoveryMiddlewareWithHandler 恢复中间件 - handler 在 panic 时调用，返回的处理器优先于配置中的 RecoveryHandler
+func RecoveryMiddlewareWithHandler(cfg *recovery.Recovery, handler func() RecoveryHandlerFunc) HTTPMiddleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					handlePanicRecovery(w, r, err, cfg)
+					var custom RecoveryHandlerFunc
+					if handler != nil {
+						custom = handler()
+					}
+					handlePanicRecovery(w, r, err, cfg, custom)
 				}
 			}()
 
@@ -42,7 +54,7 @@ func RecoveryMiddleware(cfg *recovery.Recovery) HTTPMiddleware {
 }
 
 // handlePanicRecovery 处理 panic 恢复（增强版）
-func handlePanicRecovery(w http.ResponseWriter, r *http.Request, err interface{}, config *recovery.Recovery) {
+func handlePanicRecovery(w http.ResponseWriter, r *http.Request, err interface{}, config *recovery.Recovery, custom RecoveryHandlerFunc) {
 	ctx := r.Context()
 
 	// 获取堆栈信息
@@ -57,6 +69,10 @@ func handlePanicRecovery(w http.ResponseWriter, r *http.Request, err interface{}
 	logPanicError(ctx, r, err, stackTrace, config)
 
 	// 自定义恢复处理
+	if custom != nil {
+		custom(w, r, err)
+		return
+	}
 	if config.RecoveryHandler != nil {
 		config.RecoveryHandler(w, r, err)
 		return
@@ -110,6 +126,9 @@ func setPanicErrorResponse(w http.ResponseWriter, ctx context.Context, err inter
 	message := config.ErrorMessage
 	if message == "" {
 		message = constants.MsgInternalError
+		if localized := T(ctx, constants.MsgKeyInternalError); localized != "" && localized != constants.MsgKeyInternalError {
+			message = localized
+		}
 	}
 
 	// 构建响应对象
@@ -129,7 +148,7 @@ func setPanicErrorResponse(w http.ResponseWriter, ctx context.Context, err inter
 	}
 
 	// 写入响应
-	if err := json.NewEncoder(w).Encode(result); err != nil && global.LOGGER != nil {
+	if err := response.WriteJSON(w, result); err != nil && global.LOGGER != nil {
 		global.LOGGER.ErrorContext(ctx, constants.LogMsgWriteResponseError, constants.LogFieldError, err)
 	}
 }
@@ -20,6 +20,7 @@ import (
 	gccommon "github.com/kamalyes/go-config/pkg/common"
 	goi18n "github.com/kamalyes/go-i18n"
 	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/gatewayctx"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-toolbox/pkg/contextx"
 	"github.com/kamalyes/go-toolbox/pkg/mathx"
@@ -65,10 +66,36 @@ type RequestCommonMeta struct {
 	Token          string `json:"token" header:"X-Token"`                  // Token
 	AcceptLanguage string `json:"acceptLanguage" header:"Accept-Language"` // 语言环境
 	ForwardedHost  string `json:"forwardedHost" header:"X-Forwarded-Host"` // 转发域名
+
+	routes routeClass // 请求级路由分类缓存，见 RouteSet.Match
 }
 
 type requestCommonMetaKey struct{}
 
+func init() {
+	// gatewayctx 写入标准字段时同步更新 RequestCommonMeta，保证 GetXxx 读到最新值
+	gatewayctx.OnFieldSet(func(ctx context.Context, key, value string) {
+		updateRequestCommonMetaField(ctx, func(m *RequestCommonMeta) {
+			switch key {
+			case constants.MetadataRequestID:
+				m.RequestID = value
+			case constants.MetadataTraceID:
+				m.TraceID = value
+			case constants.MetadataUserID:
+				m.UserID = value
+			case constants.MetadataTenantID:
+				m.TenantID = value
+			case constants.MetadataTenantCode:
+				m.TenantCode = value
+			case constants.MetadataIPAddress:
+				m.IPAddress = value
+			case constants.MetadataAcceptLanguage:
+				m.AcceptLanguage = value
+			}
+		})
+	})
+}
+
 // WithRequestCommonMeta 为上下文添加请求公共元信息
 func WithRequestCommonMeta(ctx context.Context, requestCommonMeta *RequestCommonMeta) context.Context {
 	return contextx.WithValue(ctx, requestCommonMetaKey{}, requestCommonMeta)
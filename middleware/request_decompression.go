@@ -0,0 +1,171 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\request_decompression.go
+ * @Description: 请求体解压 - Content-Encoding: gzip 的请求在指定路由上透明解压后交给处理器，
+ *               限制解压后大小与压缩比，防止压缩炸弹
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	DefaultDecompressedMaxBytes  = 10 << 20 // 默认解压后上限
+	DefaultDecompressionMaxRatio = 100      // 默认压缩比上限
+	decompressionRatioFloor      = 64 << 10 // 解压后不足该大小时不检查压缩比，避免小请求误判
+)
+
+// RequestDecompressionConfig 请求体解压配置
+type RequestDecompressionConfig struct {
+	Paths    []string // 启用解压的路径，以 * 结尾表示前缀匹配；为空时全部路由启用
+	MaxBytes int64    // 解压后大小上限，默认 10MiB，超出返回 413
+	MaxRatio int      // 解压后与压缩前的大小比上限，默认 100，超出返回 413
+}
+
+// RequestDecompression 请求体解压中间件
+type RequestDecompression struct {
+	config RequestDecompressionConfig
+	routes *RouteTable // nil 表示全部路由
+}
+
+// 解压失败原因
+var (
+	errDecompressedTooLarge = stderrors.New("decompressed request body too large")
+	errDecompressionRatio   = stderrors.New("request body compression ratio too high")
+)
+
+// requestDecompressionTotal 请求体解压次数（注册到默认 Registry）
+var requestDecompressionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_request_decompression_total",
+	Help: "Total number of gzip request bodies decompressed by result",
+}, []string{"result"})
+
+// NewRequestDecompression 校验配置并补齐默认值
+func NewRequestDecompression(cfg RequestDecompressionConfig) (*RequestDecompression, error) {
+	if cfg.MaxBytes < 0 || cfg.MaxRatio < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "request decompression limits must not be negative")
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = DefaultDecompressedMaxBytes
+	}
+	if cfg.MaxRatio == 0 {
+		cfg.MaxRatio = DefaultDecompressionMaxRatio
+	}
+	d := &RequestDecompression{config: cfg}
+	if len(cfg.Paths) > 0 {
+		patterns := make([]RoutePattern, 0, len(cfg.Paths))
+		for _, path := range cfg.Paths {
+			patterns = append(patterns, authnPattern(path, nil, struct{}{}))
+		}
+		d.routes = NewRouteTable(patterns)
+	}
+	return d, nil
+}
+
+// Handle 解压 gzip 请求体并移除 Content-Encoding；其余编码与未启用的路由原样放行
+func (d *RequestDecompression) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(constants.HeaderContentEncoding)))
+	if (encoding != "gzip" && encoding != "x-gzip") || r.Body == nil || r.Body == http.NoBody {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if d.routes != nil {
+		if _, ok := d.routes.Match(r.Method, r.URL.Path); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	body, err := d.decompress(r.Body)
+	_ = r.Body.Close()
+	switch {
+	case stderrors.Is(err, errDecompressedTooLarge):
+		requestDecompressionTotal.WithLabelValues("too_large").Inc()
+		response.WriteErrorResponse(w, errors.ErrRequestTooLarge)
+		return
+	case stderrors.Is(err, errDecompressionRatio):
+		requestDecompressionTotal.WithLabelValues("ratio_exceeded").Inc()
+		response.WriteErrorResponse(w, errors.ErrRequestTooLarge)
+		return
+	case err != nil:
+		requestDecompressionTotal.WithLabelValues("invalid").Inc()
+		response.WriteBadRequestResult(w, "invalid gzip request body: "+err.Error())
+		return
+	}
+	requestDecompressionTotal.WithLabelValues("ok").Inc()
+
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del(constants.HeaderContentEncoding)
+	r.Header.Set(constants.HeaderContentLength, strconv.Itoa(len(body)))
+	next.ServeHTTP(w, r)
+}
+
+// HTTPMiddleware 请求体解压中间件
+func (d *RequestDecompression) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.Handle(w, r, next)
+	})
+}
+
+// decompress 边读边检查解压后大小与压缩比，超限时立即停止
+func (d *RequestDecompression) decompress(body io.Reader) ([]byte, error) {
+	compressed := &countingReader{r: body}
+	zr, err := gzip.NewReader(compressed)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var out bytes.Buffer
+	chunk := make([]byte, 32<<10)
+	for {
+		n, err := zr.Read(chunk)
+		out.Write(chunk[:n])
+		if int64(out.Len()) > d.config.MaxBytes {
+			return nil, errDecompressedTooLarge
+		}
+		if out.Len() > decompressionRatioFloor && int64(out.Len()) > compressed.n*int64(d.config.MaxRatio) {
+			return nil, errDecompressionRatio
+		}
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// countingReader 统计已读取的压缩字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read 实现 io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
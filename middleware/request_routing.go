@@ -0,0 +1,386 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\request_routing.go
+ * @Description: 规则路由 - 按请求头、查询参数、Cookie 的取值与百分比分流把请求转发到指定上游，
+ *               规则按声明顺序匹配，提供 dry-run 解释接口查看请求会命中哪条规则
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RoutingSource 条件取值来源
+type RoutingSource string
+
+const (
+	RoutingSourceHeader RoutingSource = "header"
+	RoutingSourceQuery  RoutingSource = "query"
+	RoutingSourceCookie RoutingSource = "cookie"
+)
+
+// DefaultRoutingExplainPath 解释接口默认路径
+const DefaultRoutingExplainPath = "/admin/routing/explain"
+
+// routingBuckets 百分比分流的桶数（精度 0.01%）
+const routingBuckets = 10000
+
+// RoutingCondition 单个匹配条件，Equals 与 Regex 都为空时只要求取值存在
+type RoutingCondition struct {
+	Source RoutingSource // header / query / cookie
+	Name   string        // 请求头名（大小写不敏感）、查询参数名或 Cookie 名
+	Equals []string      // 取值等于其中任一
+	Regex  string        // 取值匹配正则
+	Negate bool          // 条件取反（取值不存在时同样视为条件不成立后再取反）
+}
+
+// RoutingRule 路由规则：路径、方法与全部条件都满足且落在分流比例内时转发到 Upstream
+type RoutingRule struct {
+	Name       string             // 规则名，用于指标与解释结果，默认 rule-<序号>
+	Paths      []string           // 路径，以 * 结尾表示前缀匹配
+	Methods    []string           // HTTP 方法，为空匹配全部
+	Conditions []RoutingCondition // 全部满足才命中
+	Percent    float64            // 命中请求中转发的比例（0~100），0 表示 100
+	StickyBy   string             // 分流依据，如 "header:X-User-Id"、"cookie:uid"、"query:device"；为空时随机分流
+	Upstream   string             // 上游名称，对应 RequestRoutingConfig.Upstreams
+}
+
+// RequestRoutingConfig 规则路由配置，未命中任何规则的请求交给后续处理器
+type RequestRoutingConfig struct {
+	Upstreams   map[string]http.Handler // 上游名称 -> 处理器（通常为 Gateway.NewUpstreamPool 创建）
+	Rules       []RoutingRule
+	ExplainPath string // 解释接口路径，为空不注册；接口本身需由认证 / 授权中间件保护
+}
+
+// RoutingStep 解释结果中单条规则的判定
+type RoutingStep struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason"`
+}
+
+// RoutingExplanation 解释结果
+type RoutingExplanation struct {
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Rule     string        `json:"rule,omitempty"`     // 命中的规则，为空表示交给默认处理器
+	Upstream string        `json:"upstream,omitempty"` // 命中的上游
+	Steps    []RoutingStep `json:"steps"`
+}
+
+// RoutingExplainRequest 解释接口请求体，描述一个假想的请求
+type RoutingExplainRequest struct {
+	Method  string            `json:"method"`            // 默认 GET
+	URL     string            `json:"url"`               // 路径与查询字符串，如 /api/v1/search?q=go
+	Headers map[string]string `json:"headers,omitempty"` // 请求头
+	Cookies map[string]string `json:"cookies,omitempty"` // Cookie
+}
+
+// routingDecisionsTotal 规则路由命中数（注册到默认 Registry）
+var routingDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_routing_decisions_total",
+	Help: "Total number of requests forwarded by config-driven routing rules",
+}, []string{"rule", "upstream"})
+
+// routingCondition 编译后的条件
+type routingCondition struct {
+	RoutingCondition
+	regex *regexp.Regexp
+}
+
+// routingRule 编译后的规则
+type routingRule struct {
+	name       string
+	paths      *RouteTable
+	conditions []routingCondition
+	buckets    int // 命中的桶数上限（不含）
+	sticky     *routingCondition
+	upstream   string
+	handler    http.Handler
+}
+
+// RequestRouter 规则路由
+type RequestRouter struct {
+	rules []*routingRule
+	paths *RouteTable // 全部规则路径的并集，用于快速跳过无关请求
+}
+
+// NewRequestRouter 校验配置并编译规则
+func NewRequestRouter(cfg RequestRoutingConfig) (*RequestRouter, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "request routing requires at least one rule")
+	}
+
+	rt := &RequestRouter{}
+	var all []RoutePattern
+	for i, rule := range cfg.Rules {
+		name := rule.Name
+		if name == "" {
+			name = "rule-" + strconv.Itoa(i+1)
+		}
+		handler, ok := cfg.Upstreams[rule.Upstream]
+		if !ok || handler == nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routing rule %s references unknown upstream %q", name, rule.Upstream)
+		}
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routing rule %s has no paths", name)
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routing rule %s percent %v is out of range", name, rule.Percent)
+		}
+
+		compiled := &routingRule{name: name, upstream: rule.Upstream, handler: handler, buckets: routingBuckets}
+		if rule.Percent > 0 {
+			compiled.buckets = int(rule.Percent * routingBuckets / 100)
+		}
+		patterns := make([]RoutePattern, 0, len(rule.Paths))
+		for _, path := range rule.Paths {
+			p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Methods: rule.Methods, Value: true}
+			if prefix, ok := strings.CutSuffix(path, "*"); ok {
+				p.Kind, p.Pattern = RouteMatchPrefix, prefix
+			}
+			patterns = append(patterns, p)
+		}
+		compiled.paths = NewRouteTable(patterns)
+		all = append(all, patterns...)
+
+		for _, cond := range rule.Conditions {
+			c, err := compileRoutingCondition(cond)
+			if err != nil {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routing rule %s: %v", name, err)
+			}
+			compiled.conditions = append(compiled.conditions, c)
+		}
+		if rule.StickyBy != "" {
+			source, key, _ := strings.Cut(rule.StickyBy, ":")
+			c, err := compileRoutingCondition(RoutingCondition{Source: RoutingSource(source), Name: key})
+			if err != nil {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "routing rule %s sticky key %q: %v", name, rule.StickyBy, err)
+			}
+			compiled.sticky = &c
+		}
+		rt.rules = append(rt.rules, compiled)
+	}
+	rt.paths = NewRouteTable(all)
+	return rt, nil
+}
+
+// compileRoutingCondition 校验条件并编译正则
+func compileRoutingCondition(cond RoutingCondition) (routingCondition, error) {
+	c := routingCondition{RoutingCondition: cond}
+	switch cond.Source {
+	case RoutingSourceHeader, RoutingSourceQuery, RoutingSourceCookie:
+	default:
+		return c, fmt.Errorf("unknown condition source %q", cond.Source)
+	}
+	if cond.Name == "" {
+		return c, fmt.Errorf("%s condition requires a name", cond.Source)
+	}
+	if cond.Regex != "" {
+		re, err := regexp.Compile(cond.Regex)
+		if err != nil {
+			return c, fmt.Errorf("invalid regex %q: %w", cond.Regex, err)
+		}
+		c.regex = re
+	}
+	return c, nil
+}
+
+// routingRequest 请求取值，查询参数按需解析一次
+type routingRequest struct {
+	r     *http.Request
+	query url.Values
+}
+
+// value 读取条件来源的取值
+func (rr *routingRequest) value(source RoutingSource, name string) (string, bool) {
+	switch source {
+	case RoutingSourceHeader:
+		values := rr.r.Header.Values(name)
+		if len(values) == 0 {
+			return "", false
+		}
+		return strings.Join(values, ","), true
+	case RoutingSourceQuery:
+		if rr.query == nil {
+			rr.query = rr.r.URL.Query()
+		}
+		values, ok := rr.query[name]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case RoutingSourceCookie:
+		cookie, err := rr.r.Cookie(name)
+		if err != nil {
+			return "", false
+		}
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// match 判定条件，返回是否成立与原因
+func (c *routingCondition) match(rr *routingRequest) (bool, string) {
+	value, present := rr.value(c.Source, c.Name)
+	ok, reason := present, fmt.Sprintf("%s %s is absent", c.Source, c.Name)
+	if present {
+		switch {
+		case len(c.Equals) > 0 && !slices.Contains(c.Equals, value):
+			ok, reason = false, fmt.Sprintf("%s %s=%q not in %v", c.Source, c.Name, value, c.Equals)
+		case c.regex != nil && !c.regex.MatchString(value):
+			ok, reason = false, fmt.Sprintf("%s %s=%q does not match %s", c.Source, c.Name, value, c.regex)
+		default:
+			reason = fmt.Sprintf("%s %s=%q matches", c.Source, c.Name, value)
+		}
+	}
+	if c.Negate {
+		return !ok, "not (" + reason + ")"
+	}
+	return ok, reason
+}
+
+// bucket 百分比分流的桶号：有分流依据时按规则名与取值哈希（同一用户稳定落在同一侧），否则随机
+func (rule *routingRule) bucket(rr *routingRequest) (int, string) {
+	if rule.sticky != nil {
+		if value, ok := rr.value(rule.sticky.Source, rule.sticky.Name); ok && value != "" {
+			h := fnv.New32a()
+			h.Write([]byte(rule.name))
+			h.Write([]byte{0})
+			h.Write([]byte(value))
+			return int(h.Sum32() % routingBuckets), "sticky"
+		}
+	}
+	return rand.IntN(routingBuckets), "random"
+}
+
+// evaluate 按声明顺序匹配规则；explain 为 true 时记录每条规则的判定
+func (rt *RequestRouter) evaluate(r *http.Request, explain bool) (*routingRule, []RoutingStep) {
+	if _, ok := rt.paths.Match(r.Method, r.URL.Path); !ok {
+		return nil, nil
+	}
+	rr := &routingRequest{r: r}
+	var steps []RoutingStep
+	record := func(rule *routingRule, matched bool, reason string) {
+		if explain {
+			steps = append(steps, RoutingStep{Rule: rule.name, Matched: matched, Reason: reason})
+		}
+	}
+
+rules:
+	for _, rule := range rt.rules {
+		if _, ok := rule.paths.Match(r.Method, r.URL.Path); !ok {
+			record(rule, false, "path or method does not match")
+			continue
+		}
+		var reasons []string
+		for i := range rule.conditions {
+			ok, reason := rule.conditions[i].match(rr)
+			if !ok {
+				record(rule, false, reason)
+				continue rules
+			}
+			reasons = append(reasons, reason)
+		}
+		if rule.buckets < routingBuckets {
+			bucket, mode := rule.bucket(rr)
+			split := fmt.Sprintf("%s bucket %d of %d (%.2f%% to %s)", mode, bucket, routingBuckets,
+				float64(rule.buckets)*100/routingBuckets, rule.upstream)
+			if bucket >= rule.buckets {
+				record(rule, false, split)
+				continue
+			}
+			reasons = append(reasons, split)
+		}
+		if len(reasons) == 0 {
+			reasons = append(reasons, "path matches")
+		}
+		record(rule, true, strings.Join(reasons, "; "))
+		return rule, steps
+	}
+	return nil, steps
+}
+
+// Handle 命中规则的请求转发到对应上游，未命中时交给 next
+func (rt *RequestRouter) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	rule, _ := rt.evaluate(r, false)
+	if rule == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+	routingDecisionsTotal.WithLabelValues(rule.name, rule.upstream).Inc()
+	rule.handler.ServeHTTP(w, r)
+}
+
+// HTTPMiddleware 规则路由中间件
+func (rt *RequestRouter) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.Handle(w, r, next)
+	})
+}
+
+// Explain 解释请求会命中的规则；带随机分流的规则每次结果可能不同
+func (rt *RequestRouter) Explain(r *http.Request) RoutingExplanation {
+	rule, steps := rt.evaluate(r, true)
+	exp := RoutingExplanation{Method: r.Method, Path: r.URL.Path, Steps: steps}
+	if exp.Steps == nil {
+		exp.Steps = []RoutingStep{}
+	}
+	if rule != nil {
+		exp.Rule, exp.Upstream = rule.name, rule.upstream
+	}
+	return exp
+}
+
+// ExplainHandler 解释接口：POST 请求体 RoutingExplainRequest，返回 RoutingExplanation，不转发请求
+func (rt *RequestRouter) ExplainHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set(constants.HeaderAllow, http.MethodPost)
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "use POST to explain a routing decision")
+			return
+		}
+		var req RoutingExplainRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			response.WriteBadRequestResult(w, "invalid explain request: "+err.Error())
+			return
+		}
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+		target, err := url.ParseRequestURI(req.URL)
+		if err != nil {
+			response.WriteBadRequestResult(w, "invalid url: "+err.Error())
+			return
+		}
+		probe := &http.Request{Method: strings.ToUpper(req.Method), URL: target, Header: make(http.Header), Host: target.Host}
+		for name, value := range req.Headers {
+			probe.Header.Set(name, value)
+		}
+		for name, value := range req.Cookies {
+			probe.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		response.WriteJSONResponse(w, http.StatusOK, rt.Explain(probe))
+	}
+}
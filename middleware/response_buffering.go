@@ -0,0 +1,150 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\response_buffering.go
+ * @Description: 响应缓冲策略 - 按路由声明响应是有上限地缓冲还是流式透传，需要完整响应体的中间件
+ *               （脚本钩子、日志捕获等）据此决定是否缓冲及上限，避免大文件代理被意外整体读入内存
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// 响应缓冲模式
+const (
+	BufferingModeBuffered  = "buffered"  // 允许中间件缓冲完整响应体，上限为 MaxBytes
+	BufferingModeStreaming = "streaming" // 任何中间件都不缓冲响应体，每次写入立即刷出
+)
+
+// DefaultResponseBufferMaxBytes buffered 模式的默认缓冲上限
+const DefaultResponseBufferMaxBytes = 1 << 20
+
+// ResponseBufferingRule 单条缓冲策略
+type ResponseBufferingRule struct {
+	Paths    []string // 路径，以 * 结尾表示前缀匹配
+	Methods  []string // HTTP 方法，为空匹配全部
+	Mode     string   // buffered / streaming
+	MaxBytes int      // buffered 模式下的缓冲上限，默认 1MiB；超过时各中间件按自身策略放弃缓冲并透传
+}
+
+// ResponseBufferingConfig 响应缓冲策略配置，未命中任何规则的请求保持各中间件的默认行为
+type ResponseBufferingConfig struct {
+	Rules []ResponseBufferingRule // 按声明顺序匹配，先声明者优先
+}
+
+// ResponseBuffering 编译后的缓冲策略
+type ResponseBuffering struct {
+	routes *RouteTable
+}
+
+// BufferingPolicy 请求生效的缓冲策略
+type BufferingPolicy struct {
+	Mode     string
+	MaxBytes int
+}
+
+// bufferingPolicyKey context 键
+type bufferingPolicyKey struct{}
+
+// NewResponseBuffering 校验配置并编译规则
+func NewResponseBuffering(cfg ResponseBufferingConfig) (*ResponseBuffering, error) {
+	var patterns []RoutePattern
+	for i, rule := range cfg.Rules {
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "response buffering rule %d has no paths", i)
+		}
+		policy := &BufferingPolicy{Mode: rule.Mode}
+		switch rule.Mode {
+		case BufferingModeBuffered:
+			if rule.MaxBytes < 0 {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "response buffering rule %d max bytes must not be negative", i)
+			}
+			policy.MaxBytes = rule.MaxBytes
+			if policy.MaxBytes == 0 {
+				policy.MaxBytes = DefaultResponseBufferMaxBytes
+			}
+		case BufferingModeStreaming:
+		default:
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "response buffering rule %d has unknown mode %q", i, rule.Mode)
+		}
+		for _, path := range rule.Paths {
+			patterns = append(patterns, authnPattern(path, rule.Methods, policy))
+		}
+	}
+	return &ResponseBuffering{routes: NewRouteTable(patterns)}, nil
+}
+
+// WithBufferingPolicy 把缓冲策略放入 context
+func WithBufferingPolicy(ctx context.Context, policy *BufferingPolicy) context.Context {
+	return context.WithValue(ctx, bufferingPolicyKey{}, policy)
+}
+
+// BufferingPolicyFromContext 读取请求的缓冲策略，未命中规则时返回 nil
+func BufferingPolicyFromContext(ctx context.Context) *BufferingPolicy {
+	policy, _ := ctx.Value(bufferingPolicyKey{}).(*BufferingPolicy)
+	return policy
+}
+
+// ResponseBufferLimit 需要缓冲响应体的中间件调用：streaming 路由返回 false（不得缓冲），
+// buffered 路由返回策略的上限，未配置策略时返回中间件自身的默认上限 fallback
+func ResponseBufferLimit(ctx context.Context, fallback int) (int, bool) {
+	policy := BufferingPolicyFromContext(ctx)
+	switch {
+	case policy == nil:
+		return fallback, true
+	case policy.Mode == BufferingModeStreaming:
+		return 0, false
+	default:
+		return policy.MaxBytes, true
+	}
+}
+
+// Handle 命中规则时写入策略；streaming 路由的每次写入立即刷出，不等待底层缓冲区填满
+func (b *ResponseBuffering) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	value, ok := b.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	policy := value.(*BufferingPolicy)
+	r = r.WithContext(WithBufferingPolicy(r.Context(), policy))
+	if policy.Mode == BufferingModeStreaming {
+		w = &streamingWriter{ResponseWriter: w}
+	}
+	next.ServeHTTP(w, r)
+}
+
+// HTTPMiddleware 响应缓冲策略中间件
+func (b *ResponseBuffering) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Handle(w, r, next)
+	})
+}
+
+// streamingWriter 每次写入后刷出
+type streamingWriter struct {
+	http.ResponseWriter
+}
+
+// Write 写入后立即刷出
+func (w *streamingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	if err == nil {
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+	}
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *streamingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -28,6 +28,9 @@ type ResponseWriter struct {
 	hijacked     bool          // 是否被劫持（WebSocket等）
 	body         *bytes.Buffer // 响应体缓存
 	captureBody  bool          // 是否捕获响应体
+	// 按响应 Content-Type 决定是否捕获，避免缓存文件下载、图片等二进制大响应
+	captureFilter func(contentType string) bool
+	captureLimit  int // 捕获上限（字节），0 不限制，超出部分截断
 }
 
 // responseWriterPool 对象池 - 减少内存分配，提升性能
@@ -49,6 +52,8 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 	rw.wroteHeader = false
 	rw.hijacked = false
 	rw.captureBody = false
+	rw.captureFilter = nil
+	rw.captureLimit = 0
 	rw.body.Reset()
 	return rw
 }
@@ -56,6 +61,7 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 // Release 归还 ResponseWriter 到对象池
 func (rw *ResponseWriter) Release() {
 	rw.ResponseWriter = nil
+	rw.captureFilter = nil
 	rw.body.Reset()
 	responseWriterPool.Put(rw)
 }
@@ -65,6 +71,17 @@ func (rw *ResponseWriter) EnableBodyCapture() {
 	rw.captureBody = true
 }
 
+// EnableBodyCaptureFor 启用响应体捕获，写入响应头时 filter 返回 false 则不再捕获
+func (rw *ResponseWriter) EnableBodyCaptureFor(filter func(contentType string) bool) {
+	rw.captureBody = true
+	rw.captureFilter = filter
+}
+
+// LimitBodyCapture 限制捕获的字节数，超出部分截断，0 不限制
+func (rw *ResponseWriter) LimitBodyCapture(limit int) {
+	rw.captureLimit = limit
+}
+
 // GetBody 获取捕获的响应体
 func (rw *ResponseWriter) GetBody() []byte {
 	if rw.body == nil {
@@ -78,6 +95,9 @@ func (rw *ResponseWriter) WriteHeader(statusCode int) {
 	if !rw.wroteHeader {
 		rw.statusCode = statusCode
 		rw.wroteHeader = true
+		if rw.captureBody && rw.captureFilter != nil && !rw.captureFilter(rw.Header().Get("Content-Type")) {
+			rw.captureBody = false
+		}
 		rw.ResponseWriter.WriteHeader(statusCode)
 	}
 }
@@ -88,7 +108,12 @@ func (rw *ResponseWriter) Write(data []byte) (int, error) {
 		rw.WriteHeader(http.StatusOK)
 	}
 	if rw.captureBody {
-		rw.body.Write(data)
+		captured := data
+		if rw.captureLimit > 0 && rw.body.Len()+len(captured) > rw.captureLimit {
+			captured = captured[:rw.captureLimit-rw.body.Len()]
+			rw.captureBody = false
+		}
+		rw.body.Write(captured)
 	}
 	n, err := rw.ResponseWriter.Write(data)
 	rw.bytesWritten += int64(n)
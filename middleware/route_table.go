@@ -0,0 +1,415 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\route_table.go
+ * @Description: 路由分类热路径 - 基于字节级基数树的预编译路由匹配（精确/前缀/通配符），
+ *               各中间件通过 RouteSet 共享同一棵树，每个请求只做一次路径分类且不产生内存分配
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxRouteSets 共享路由表可容纳的 RouteSet 数量，超出后新建的 RouteSet 使用独立路由表
+const maxRouteSets = 32
+
+// RouteMatchKind 路由匹配方式
+type RouteMatchKind uint8
+
+const (
+	RouteMatchExact  RouteMatchKind = iota // 精确匹配
+	RouteMatchPrefix                       // 字符串前缀匹配，与 strings.HasPrefix 一致
+	RouteMatchGlob                         // path.Match 通配符（* ? [...] 不跨越 /），不含通配符或模式非法时按精确匹配
+)
+
+// RoutePattern 路由规则
+type RoutePattern struct {
+	Kind    RouteMatchKind
+	Pattern string
+	Methods []string // HTTP 方法，为空匹配全部，大小写不敏感
+	Value   any      // 命中时返回的值
+}
+
+// routeMethods 预编译的方法集合
+type routeMethods struct {
+	all    bool
+	mask   uint16
+	custom []string
+}
+
+// 标准 HTTP 方法位
+const (
+	methodBitGet uint16 = 1 << iota
+	methodBitHead
+	methodBitPost
+	methodBitPut
+	methodBitPatch
+	methodBitDelete
+	methodBitConnect
+	methodBitOptions
+	methodBitTrace
+)
+
+// methodBit 标准方法对应的位，非标准方法返回 0
+func methodBit(method string) uint16 {
+	switch method {
+	case http.MethodGet:
+		return methodBitGet
+	case http.MethodHead:
+		return methodBitHead
+	case http.MethodPost:
+		return methodBitPost
+	case http.MethodPut:
+		return methodBitPut
+	case http.MethodPatch:
+		return methodBitPatch
+	case http.MethodDelete:
+		return methodBitDelete
+	case http.MethodConnect:
+		return methodBitConnect
+	case http.MethodOptions:
+		return methodBitOptions
+	case http.MethodTrace:
+		return methodBitTrace
+	}
+	return 0
+}
+
+// compileRouteMethods 编译方法列表
+func compileRouteMethods(methods []string) routeMethods {
+	if len(methods) == 0 {
+		return routeMethods{all: true}
+	}
+	var m routeMethods
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if bit := methodBit(method); bit != 0 {
+			m.mask |= bit
+		} else {
+			m.custom = append(m.custom, method)
+		}
+	}
+	return m
+}
+
+// match 检查方法是否匹配
+func (m *routeMethods) match(method string) bool {
+	if m.all {
+		return true
+	}
+	bit := methodBit(method)
+	if bit == 0 && method != strings.ToUpper(method) {
+		bit = methodBit(strings.ToUpper(method))
+	}
+	if bit != 0 {
+		return m.mask&bit != 0
+	}
+	for _, custom := range m.custom {
+		if strings.EqualFold(custom, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeEntry 编译后的规则
+type routeEntry struct {
+	slot    int    // 所属 RouteSet 槽位，独立路由表为 0
+	order   int    // 在所属集合中的顺序，越小越优先
+	glob    string // 通配符规则的完整模式
+	methods routeMethods
+	value   any
+}
+
+// routeNode 基数树节点，节点对应的路径前缀为从根到该节点的 label 拼接
+type routeNode struct {
+	label    string
+	children []*routeNode
+	exact    []*routeEntry // 路径恰好在此结束时命中
+	prefix   []*routeEntry // 路径经过此节点即命中
+	globs    []*routeEntry // 字面量前缀止于此的通配符规则，需完整校验
+}
+
+// child 按首字节查找子节点
+func (n *routeNode) child(c byte) *routeNode {
+	for _, ch := range n.children {
+		if ch.label[0] == c {
+			return ch
+		}
+	}
+	return nil
+}
+
+// insert 插入 key 并返回其终点节点，必要时分裂边
+func (n *routeNode) insert(key string) *routeNode {
+	node := n
+	for key != "" {
+		ch := node.child(key[0])
+		if ch == nil {
+			leaf := &routeNode{label: key}
+			node.children = append(node.children, leaf)
+			return leaf
+		}
+
+		common := 0
+		for common < len(key) && common < len(ch.label) && key[common] == ch.label[common] {
+			common++
+		}
+		if common < len(ch.label) {
+			split := &routeNode{label: ch.label[:common], children: []*routeNode{ch}}
+			ch.label = ch.label[common:]
+			for i, c := range node.children {
+				if c == ch {
+					node.children[i] = split
+					break
+				}
+			}
+			ch = split
+		}
+		key = key[common:]
+		node = ch
+	}
+	return node
+}
+
+// routeHits 一次分类的结果：每个槽位的最优规则
+type routeHits struct {
+	slots   uint32 // 参与分类的槽位
+	matched uint32
+	best    [maxRouteSets]*routeEntry
+}
+
+// wants 规则是否可能优于当前结果（方法与通配符校验之前的快速过滤）
+func (h *routeHits) wants(e *routeEntry) bool {
+	if h.slots&(1<<e.slot) == 0 {
+		return false
+	}
+	cur := h.best[e.slot]
+	return cur == nil || e.order < cur.order
+}
+
+// offer 记录命中的规则
+func (h *routeHits) offer(e *routeEntry, method string) {
+	if h.wants(e) && e.methods.match(method) {
+		h.best[e.slot] = e
+		h.matched |= 1 << e.slot
+	}
+}
+
+// value 槽位命中的规则值
+func (h *routeHits) value(slot int) (any, bool) {
+	if h.matched&(1<<slot) == 0 {
+		return nil, false
+	}
+	return h.best[slot].value, true
+}
+
+// RouteTable 预编译的路由表，构建后只读，可并发使用
+type RouteTable struct {
+	root       routeNode
+	generation uint64
+}
+
+// NewRouteTable 编译路由规则，多条规则命中时返回靠前的规则
+func NewRouteTable(patterns []RoutePattern) *RouteTable {
+	t := &RouteTable{}
+	t.add(0, patterns)
+	return t
+}
+
+// add 将一组规则加入指定槽位
+func (t *RouteTable) add(slot int, patterns []RoutePattern) {
+	for i, p := range patterns {
+		e := &routeEntry{slot: slot, order: i, methods: compileRouteMethods(p.Methods), value: p.Value}
+		switch p.Kind {
+		case RouteMatchPrefix:
+			node := t.root.insert(p.Pattern)
+			node.prefix = append(node.prefix, e)
+		case RouteMatchGlob:
+			meta := strings.IndexAny(p.Pattern, `*?[\`)
+			if _, err := path.Match(p.Pattern, ""); meta >= 0 && err == nil {
+				e.glob = p.Pattern
+				node := t.root.insert(p.Pattern[:meta])
+				node.globs = append(node.globs, e)
+				continue
+			}
+			fallthrough
+		default:
+			node := t.root.insert(p.Pattern)
+			node.exact = append(node.exact, e)
+		}
+	}
+}
+
+// walk 沿基数树走一遍路径，收集各槽位的最优规则
+func (t *RouteTable) walk(method, urlPath string, h *routeHits) {
+	node := &t.root
+	rest := urlPath
+	for {
+		for _, e := range node.prefix {
+			h.offer(e, method)
+		}
+		for _, e := range node.globs {
+			if h.wants(e) {
+				if ok, _ := path.Match(e.glob, urlPath); ok {
+					h.offer(e, method)
+				}
+			}
+		}
+		if rest == "" {
+			for _, e := range node.exact {
+				h.offer(e, method)
+			}
+			return
+		}
+		next := node.child(rest[0])
+		if next == nil || !strings.HasPrefix(rest, next.label) {
+			return
+		}
+		rest = rest[len(next.label):]
+		node = next
+	}
+}
+
+// Match 匹配路径与方法，返回命中规则的 Value
+func (t *RouteTable) Match(method, urlPath string) (any, bool) {
+	if t == nil {
+		return nil, false
+	}
+	h := routeHits{slots: 1}
+	t.walk(method, urlPath, &h)
+	return h.value(0)
+}
+
+// routeClass 请求级路由分类缓存（挂在 RequestCommonMeta 上，随请求复用）
+type routeClass struct {
+	generation uint64
+	method     string
+	path       string
+	hits       routeHits
+}
+
+// routeRegistry 共享路由表：所有 RouteSet 的规则编译进同一棵树
+var routeRegistry struct {
+	mu         sync.Mutex
+	sets       [maxRouteSets][]RoutePattern
+	used       uint32
+	generation uint64
+	table      atomic.Pointer[RouteTable]
+}
+
+// RouteSet 注册到共享路由表的一组规则
+// 同一请求内所有 RouteSet 共用一次路径分类结果，槽位耗尽时退化为独立路由表
+type RouteSet struct {
+	name    string
+	slot    int // -1 表示使用独立路由表
+	closed  atomic.Bool
+	private atomic.Pointer[RouteTable]
+}
+
+// NewRouteSet 创建并注册一组路由规则
+func NewRouteSet(name string, patterns []RoutePattern) *RouteSet {
+	s := &RouteSet{name: name, slot: -1}
+
+	routeRegistry.mu.Lock()
+	for slot := 0; slot < maxRouteSets; slot++ {
+		if routeRegistry.used&(1<<slot) == 0 {
+			routeRegistry.used |= 1 << slot
+			s.slot = slot
+			break
+		}
+	}
+	routeRegistry.mu.Unlock()
+
+	s.Update(patterns)
+	return s
+}
+
+// Update 替换规则，对之后的分类立即生效
+func (s *RouteSet) Update(patterns []RoutePattern) {
+	if s.closed.Load() {
+		return
+	}
+	patterns = append([]RoutePattern(nil), patterns...)
+	if s.slot < 0 {
+		s.private.Store(NewRouteTable(patterns))
+		return
+	}
+
+	routeRegistry.mu.Lock()
+	defer routeRegistry.mu.Unlock()
+	routeRegistry.sets[s.slot] = patterns
+	rebuildRouteRegistry()
+}
+
+// Close 注销规则并释放槽位，之后 Match 不再命中
+func (s *RouteSet) Close() {
+	if s.closed.Swap(true) || s.slot < 0 {
+		return
+	}
+
+	routeRegistry.mu.Lock()
+	defer routeRegistry.mu.Unlock()
+	routeRegistry.sets[s.slot] = nil
+	routeRegistry.used &^= 1 << s.slot
+	rebuildRouteRegistry()
+}
+
+// rebuildRouteRegistry 重建共享路由表（调用方持有 routeRegistry.mu）
+func rebuildRouteRegistry() {
+	routeRegistry.generation++
+	t := &RouteTable{generation: routeRegistry.generation}
+	for slot, patterns := range routeRegistry.sets {
+		if len(patterns) > 0 {
+			t.add(slot, patterns)
+		}
+	}
+	routeRegistry.table.Store(t)
+}
+
+// Match 匹配请求，返回命中规则的 Value
+// 请求经过 RequestContext 中间件时，首次调用对所有 RouteSet 完成分类并缓存，后续调用直接读取
+func (s *RouteSet) Match(r *http.Request) (any, bool) {
+	if s.closed.Load() {
+		return nil, false
+	}
+	if s.slot < 0 {
+		return s.private.Load().Match(r.Method, r.URL.Path)
+	}
+	meta, ok := r.Context().Value(requestCommonMetaKey{}).(*RequestCommonMeta)
+	if !ok || meta == nil {
+		return s.MatchPath(r.Method, r.URL.Path)
+	}
+
+	table := routeRegistry.table.Load()
+	rc := &meta.routes
+	if rc.generation != table.generation || rc.method != r.Method || rc.path != r.URL.Path {
+		rc.hits = routeHits{slots: ^uint32(0)}
+		table.walk(r.Method, r.URL.Path, &rc.hits)
+		rc.generation, rc.method, rc.path = table.generation, r.Method, r.URL.Path
+	}
+	return rc.hits.value(s.slot)
+}
+
+// MatchPath 直接匹配方法与路径（不使用请求级缓存，如 gRPC 方法名）
+func (s *RouteSet) MatchPath(method, urlPath string) (any, bool) {
+	if s.closed.Load() {
+		return nil, false
+	}
+	if s.slot < 0 {
+		return s.private.Load().Match(method, urlPath)
+	}
+	h := routeHits{slots: 1 << s.slot}
+	routeRegistry.table.Load().walk(method, urlPath, &h)
+	return h.value(s.slot)
+}
@@ -0,0 +1,177 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\runtime_metrics.go
+ * @Description: 运行时指标 - 堆内存、GC 停顿、协程、线程、文件描述符与运行时长以 gateway_runtime_* 指标暴露，
+ *               /admin/performance 从同一组指标生成 JSON，新增的运行时指标自动出现在接口中
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// DefaultPerformancePath 运行时性能查询接口默认路径
+const DefaultPerformancePath = "/admin/performance"
+
+// runtimeMetricPrefix 运行时指标名前缀，性能接口按此前缀收集
+const runtimeMetricPrefix = "gateway_runtime_"
+
+// memStatsMaxAge 内存统计缓存时长，同一次抓取中多个指标共用一次 ReadMemStats
+const memStatsMaxAge = time.Second
+
+// processStartedAt 进程启动时间（包初始化时刻）
+var processStartedAt = time.Now()
+
+// memStatsCache 最近一次读取的内存统计
+var memStatsCache struct {
+	mu     sync.Mutex
+	stats  runtime.MemStats
+	readAt time.Time
+}
+
+// readMemStats 读取内存统计，1s 内复用上次结果（ReadMemStats 需要短暂停止全部协程）
+func readMemStats() *runtime.MemStats {
+	memStatsCache.mu.Lock()
+	defer memStatsCache.mu.Unlock()
+	if time.Since(memStatsCache.readAt) >= memStatsMaxAge {
+		runtime.ReadMemStats(&memStatsCache.stats)
+		memStatsCache.readAt = time.Now()
+	}
+	stats := memStatsCache.stats
+	return &stats
+}
+
+// runtimeMetric 一个按需计算的运行时指标
+type runtimeMetric struct {
+	name    string
+	help    string
+	counter bool // 累计值（counter），否则为 gauge
+	value   func() float64
+}
+
+// runtimeMetrics 运行时指标，抓取时计算
+var runtimeMetrics = []runtimeMetric{
+	{name: "heap_alloc_bytes", help: "Bytes of allocated heap objects", value: func() float64 {
+		return float64(readMemStats().HeapAlloc)
+	}},
+	{name: "heap_inuse_bytes", help: "Bytes in in-use heap spans", value: func() float64 {
+		return float64(readMemStats().HeapInuse)
+	}},
+	{name: "heap_sys_bytes", help: "Bytes of heap memory obtained from the OS", value: func() float64 {
+		return float64(readMemStats().HeapSys)
+	}},
+	{name: "heap_objects", help: "Number of allocated heap objects", value: func() float64 {
+		return float64(readMemStats().HeapObjects)
+	}},
+	{name: "gc_cycles_total", help: "Number of completed GC cycles since process start", counter: true, value: func() float64 {
+		return float64(readMemStats().NumGC)
+	}},
+	{name: "gc_pause_last_seconds", help: "Duration of the most recent GC stop-the-world pause", value: func() float64 {
+		ms := readMemStats()
+		if ms.NumGC == 0 {
+			return 0
+		}
+		return float64(ms.PauseNs[(ms.NumGC+255)%256]) / float64(time.Second)
+	}},
+	{name: "gc_pause_max_seconds", help: "Longest GC stop-the-world pause among the last 256 cycles", value: func() float64 {
+		ms := readMemStats()
+		var longest uint64
+		for i := 0; i < int(min(ms.NumGC, 256)); i++ {
+			longest = max(longest, ms.PauseNs[i])
+		}
+		return float64(longest) / float64(time.Second)
+	}},
+	{name: "gc_pause_seconds_total", help: "Cumulative GC stop-the-world pause time since process start", counter: true, value: func() float64 {
+		return float64(readMemStats().PauseTotalNs) / float64(time.Second)
+	}},
+	{name: "goroutines", help: "Number of goroutines that currently exist", value: func() float64 {
+		return float64(runtime.NumGoroutine())
+	}},
+	{name: "threads", help: "Number of OS threads created by the runtime", value: func() float64 {
+		return float64(pprof.Lookup("threadcreate").Count())
+	}},
+	{name: "open_fds", help: "Number of open file descriptors (Linux only, -1 elsewhere)", value: func() float64 {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return -1
+		}
+		return float64(len(entries))
+	}},
+	{name: "uptime_seconds", help: "Seconds since the gateway process started", value: func() float64 {
+		return time.Since(processStartedAt).Seconds()
+	}},
+}
+
+// init 把运行时指标注册到默认 Registry
+func init() {
+	for _, m := range runtimeMetrics {
+		if m.counter {
+			promauto.NewCounterFunc(prometheus.CounterOpts{Name: runtimeMetricPrefix + m.name, Help: m.help}, m.value)
+			continue
+		}
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: runtimeMetricPrefix + m.name, Help: m.help}, m.value)
+	}
+}
+
+// PerformanceResponse 运行时性能查询接口响应，Runtime 的键为去掉 gateway_runtime_ 前缀的指标名
+type PerformanceResponse struct {
+	StartedAt string             `json:"started_at"`
+	Uptime    string             `json:"uptime"`
+	Runtime   map[string]float64 `json:"runtime"`
+}
+
+// PerformanceHandler 运行时性能查询接口：收集默认 Registry 中全部 gateway_runtime_* 指标（无标签的 gauge / counter）
+func PerformanceHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		families, err := prometheus.DefaultGatherer.Gather()
+		if err != nil && len(families) == 0 {
+			response.WriteServiceUnavailableResult(w, "runtime metrics are not available")
+			return
+		}
+		resp := PerformanceResponse{
+			StartedAt: processStartedAt.Format(time.RFC3339),
+			Uptime:    time.Since(processStartedAt).Truncate(time.Second).String(),
+			Runtime:   make(map[string]float64),
+		}
+		for _, family := range families {
+			name, ok := strings.CutPrefix(family.GetName(), runtimeMetricPrefix)
+			if !ok || len(family.GetMetric()) != 1 || len(family.GetMetric()[0].GetLabel()) != 0 {
+				continue
+			}
+			if value, ok := metricValue(family.GetType(), family.GetMetric()[0]); ok {
+				resp.Runtime[name] = value
+			}
+		}
+		response.WriteJSONResponse(w, http.StatusOK, resp)
+	}
+}
+
+// metricValue gauge / counter / untyped 指标的当前值
+func metricValue(kind dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch kind {
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue(), true
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue(), true
+	}
+	return 0, false
+}
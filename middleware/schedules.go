@@ -0,0 +1,473 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\schedules.go
+ * @Description: 定时调用 - 按 Cron 表达式以合成身份调用内部路由（经过完整中间件链）或上游 URL，
+ *               用于缓存预热、周期同步；同一调度不重叠执行，可选多实例互斥，保留运行历史
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 运行结果
+const (
+	ScheduledRunSucceeded = "succeeded"
+	ScheduledRunFailed    = "failed"
+	ScheduledRunSkipped   = "skipped" // 上一次仍在运行或其他实例已执行
+)
+
+// 触发方式
+const (
+	ScheduleTriggerCron   = "cron"
+	ScheduleTriggerManual = "manual"
+)
+
+// 定时调用默认值
+const (
+	DefaultScheduleAdminPath   = "/admin/schedules"
+	DefaultScheduleTimeout     = time.Minute
+	DefaultScheduleHistorySize = 20
+	scheduleLockKeyPrefix      = "gateway:schedule:"
+	scheduleUserAgent          = "go-rpc-gateway-scheduler"
+	scheduleResponseSnippet    = 512
+)
+
+// ScheduledCall 一个定时调用，Path 与 URL 二选一
+type ScheduledCall struct {
+	Name      string            // 调度名，管理接口与指标使用
+	Schedule  string            // Cron 表达式，如 "*/5 * * * *"、"@hourly"、"@every 30s"
+	Method    string            // 请求方法，默认 GET
+	Path      string            // 内部路由（含查询参数），请求经过网关完整的中间件链
+	URL       string            // 上游地址，直接发送 HTTP 请求
+	Header    map[string]string // 附加请求头
+	Body      string            // 请求体
+	Principal *Principal        // 合成身份：内部路由由认证中间件直接采用，上游请求写入身份头；为空时匿名
+	Timeout   time.Duration     // 单次调用超时，默认 1m
+}
+
+// SchedulesConfig 定时调用配置
+type SchedulesConfig struct {
+	Calls       []ScheduledCall
+	Location    string // Cron 表达式使用的时区（IANA 名称），默认本地时区
+	Distributed bool   // 多实例部署时通过状态存储加锁，每次触发只由一个实例执行
+	HistorySize int    // 每个调度保留的运行记录条数，默认 20
+	AdminPath   string // 管理接口路径，默认 /admin/schedules；接口本身需由认证 / 授权中间件保护
+}
+
+// ScheduledRun 一次运行记录
+type ScheduledRun struct {
+	Trigger    string        `json:"trigger"`
+	Status     string        `json:"status"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Response   string        `json:"response,omitempty"` // 响应体前 512 字节（仅失败时记录）
+}
+
+// ScheduleStatus 调度状态
+type ScheduleStatus struct {
+	Name     string         `json:"name"`
+	Schedule string         `json:"schedule"`
+	Target   string         `json:"target"`
+	Running  bool           `json:"running"`
+	NextRun  time.Time      `json:"next_run,omitzero"`
+	History  []ScheduledRun `json:"history"` // 最近的在前
+}
+
+// scheduledCall 运行时状态
+type scheduledCall struct {
+	ScheduledCall
+	cron    *CronSchedule
+	running atomic.Bool
+
+	mu      sync.Mutex
+	next    time.Time
+	history []ScheduledRun
+}
+
+// Scheduler 定时调用调度器
+type Scheduler struct {
+	config   SchedulesConfig
+	calls    []*scheduledCall
+	byName   map[string]*scheduledCall
+	loc      *time.Location
+	dispatch http.Handler
+	client   *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// 定时调用指标（注册到默认 Registry）
+var (
+	scheduledRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_scheduled_runs_total",
+		Help: "Total number of scheduled call runs by schedule and status",
+	}, []string{"schedule", "status"})
+
+	scheduledRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_scheduled_run_duration_seconds",
+		Help:    "Duration of scheduled call runs",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"schedule"})
+)
+
+// NewScheduler 校验配置并创建调度器，dispatch 为处理内部路由的 HTTP 处理器（网关中间件链）；调用 Start 后开始调度
+func NewScheduler(cfg SchedulesConfig, dispatch http.Handler) (*Scheduler, error) {
+	if len(cfg.Calls) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "schedules require at least one call")
+	}
+	if cfg.HistorySize < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "schedule history size must not be negative")
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = DefaultScheduleHistorySize
+	}
+	if cfg.AdminPath == "" {
+		cfg.AdminPath = DefaultScheduleAdminPath
+	}
+	cfg.AdminPath = strings.TrimSuffix(cfg.AdminPath, "/")
+	loc := time.Local
+	if cfg.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Location); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule location %q: %v", cfg.Location, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		config:   cfg,
+		byName:   make(map[string]*scheduledCall, len(cfg.Calls)),
+		loc:      loc,
+		dispatch: dispatch,
+		client:   &http.Client{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, call := range cfg.Calls {
+		c, err := newScheduledCall(call, dispatch != nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if s.byName[c.Name] != nil {
+			cancel()
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "duplicate schedule %q", c.Name)
+		}
+		s.calls = append(s.calls, c)
+		s.byName[c.Name] = c
+	}
+	return s, nil
+}
+
+// newScheduledCall 校验单个调度
+func newScheduledCall(call ScheduledCall, hasDispatch bool) (*scheduledCall, error) {
+	if call.Name == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "schedule name is required")
+	}
+	cron, err := ParseCron(call.Schedule)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule %q: %v", call.Name, err)
+	}
+	switch {
+	case (call.Path == "") == (call.URL == ""):
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule %q requires exactly one of path and url", call.Name)
+	case call.Path != "" && !strings.HasPrefix(call.Path, "/"):
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule %q path %q must start with /", call.Name, call.Path)
+	case call.Path != "" && !hasDispatch:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule %q calls an internal path but no handler is available", call.Name)
+	case call.URL != "":
+		if u, err := url.Parse(call.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "schedule %q url %q must be an absolute http(s) url", call.Name, call.URL)
+		}
+	}
+	if call.Method == "" {
+		call.Method = http.MethodGet
+	}
+	call.Method = strings.ToUpper(call.Method)
+	if call.Timeout <= 0 {
+		call.Timeout = DefaultScheduleTimeout
+	}
+	return &scheduledCall{ScheduledCall: call, cron: cron}, nil
+}
+
+// AdminPath 管理接口路径
+func (s *Scheduler) AdminPath() string {
+	return s.config.AdminPath
+}
+
+// Start 为每个调度启动计时协程
+func (s *Scheduler) Start() {
+	for _, c := range s.calls {
+		s.wg.Add(1)
+		go s.loop(c)
+	}
+}
+
+// Stop 停止调度并等待运行中的调用，ctx 结束时取消它们
+func (s *Scheduler) Stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	// 先让计时协程退出，运行中的调用使用独立的 ctx，等待期间不受影响
+	s.cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Trigger 手动触发一次调用，上一次仍在运行时返回 skipped 记录
+func (s *Scheduler) Trigger(name string) (ScheduledRun, *errors.AppError) {
+	c, ok := s.byName[name]
+	if !ok {
+		return ScheduledRun{}, errors.NewErrorf(errors.ErrCodeNotFound, "schedule %q not found", name)
+	}
+	if s.ctx.Err() != nil {
+		return ScheduledRun{}, errors.NewError(errors.ErrCodeServiceUnavailable, "scheduler is stopped")
+	}
+	return s.run(c, ScheduleTriggerManual, time.Now().In(s.loc)), nil
+}
+
+// Status 所有调度的状态，按名称排序
+func (s *Scheduler) Status() []ScheduleStatus {
+	out := make([]ScheduleStatus, 0, len(s.calls))
+	for _, c := range s.calls {
+		target := c.Path
+		if target == "" {
+			target = c.URL
+		}
+		c.mu.Lock()
+		st := ScheduleStatus{
+			Name:     c.Name,
+			Schedule: c.Schedule,
+			Target:   c.Method + " " + target,
+			Running:  c.running.Load(),
+			NextRun:  c.next,
+			History:  make([]ScheduledRun, 0, len(c.history)),
+		}
+		for i := len(c.history) - 1; i >= 0; i-- {
+			st.History = append(st.History, c.history[i])
+		}
+		c.mu.Unlock()
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// AdminHandler 管理接口：GET {path} 查看状态与历史，POST {path}/{name}/run 立即运行一次
+func (s *Scheduler) AdminHandler() http.HandlerFunc {
+	path := s.config.AdminPath
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, path)
+		switch {
+		case (rest == "" || rest == "/") && r.Method == http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, s.Status())
+		case strings.HasSuffix(rest, "/run") && r.Method == http.MethodPost:
+			run, appErr := s.Trigger(strings.TrimSuffix(strings.TrimPrefix(rest, "/"), "/run"))
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, run)
+		default:
+			response.WriteNotFoundResult(w, "unknown schedule admin endpoint")
+		}
+	}
+}
+
+// loop 等待下一次触发时间；运行在独立协程中，耗时较长时下一次触发会被跳过而不是排队
+func (s *Scheduler) loop(c *scheduledCall) {
+	defer s.wg.Done()
+	for {
+		next := c.cron.Next(time.Now().In(s.loc))
+		if next.IsZero() {
+			global.LOGGER.WarnKV("定时调用没有后续触发时间", "schedule", c.Name, "cron", c.Schedule)
+			return
+		}
+		c.mu.Lock()
+		c.next = next
+		c.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(c, ScheduleTriggerCron, next)
+		}()
+	}
+}
+
+// run 执行一次调用并记录结果
+func (s *Scheduler) run(c *scheduledCall, trigger string, tick time.Time) ScheduledRun {
+	rec := ScheduledRun{Trigger: trigger, StartedAt: time.Now()}
+	switch {
+	case !c.running.CompareAndSwap(false, true):
+		rec.Status, rec.Error = ScheduledRunSkipped, "previous run is still in progress"
+	case trigger == ScheduleTriggerCron && s.config.Distributed && !s.acquire(c, tick):
+		c.running.Store(false)
+		rec.Status, rec.Error = ScheduledRunSkipped, "another instance owns this run"
+	default:
+		defer c.running.Store(false)
+		rec.StatusCode, rec.Response, rec.Error = s.invoke(c)
+		rec.Duration = time.Since(rec.StartedAt)
+		rec.Status = ScheduledRunSucceeded
+		if rec.Error != "" {
+			rec.Status = ScheduledRunFailed
+			global.LOGGER.WarnKV("定时调用失败", "schedule", c.Name, "status", rec.StatusCode, "error", rec.Error)
+		} else {
+			rec.Response = ""
+		}
+		scheduledRunDuration.WithLabelValues(c.Name).Observe(rec.Duration.Seconds())
+	}
+	scheduledRunsTotal.WithLabelValues(c.Name, rec.Status).Inc()
+
+	c.mu.Lock()
+	c.history = append(c.history, rec)
+	if n := len(c.history) - s.config.HistorySize; n > 0 {
+		c.history = append(c.history[:0:0], c.history[n:]...)
+	}
+	c.mu.Unlock()
+	return rec
+}
+
+// acquire 多实例互斥：同一触发时间只有一个实例能写入锁，锁在超时后自动过期
+func (s *Scheduler) acquire(c *scheduledCall, tick time.Time) bool {
+	if global.STORE == nil {
+		return true
+	}
+	key := scheduleLockKeyPrefix + c.Name + ":" + strconv.FormatInt(tick.Unix(), 10)
+	ok, err := global.STORE.SetNX(s.ctx, key, "1", c.Timeout+time.Minute)
+	if err != nil {
+		global.LOGGER.WarnKV("定时调用加锁失败，本实例执行", "schedule", c.Name, "error", err)
+		return true
+	}
+	return ok
+}
+
+// invoke 发送请求，2xx 视为成功；返回状态码、响应片段与错误
+func (s *Scheduler) invoke(c *scheduledCall) (int, string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	target := c.URL
+	if c.Path != "" {
+		target = "http://" + scheduleUserAgent + c.Path
+	}
+	var body io.Reader
+	if c.Body != "" {
+		body = strings.NewReader(c.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.Method, target, body)
+	if err != nil {
+		return 0, "", err.Error()
+	}
+	for k, v := range c.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(constants.HeaderUserAgent, scheduleUserAgent)
+
+	var principal *Principal
+	if c.Principal != nil {
+		p := *c.Principal
+		p.AuthType = PrincipalAuthInternal
+		principal = &p
+	}
+
+	if c.Path == "" {
+		if principal != nil {
+			PrincipalToHeader(principal, req.Header)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return 0, "", err.Error()
+		}
+		defer resp.Body.Close()
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, scheduleResponseSnippet))
+		return scheduledResult(resp.StatusCode, snippet)
+	}
+
+	req.RemoteAddr = "127.0.0.1:0"
+	req = req.WithContext(withInternalPrincipal(ctx, principal))
+	rec := &scheduledResponse{header: make(http.Header)}
+	s.dispatch.ServeHTTP(rec, req)
+	if ctx.Err() != nil {
+		return rec.code(), string(rec.body), ctx.Err().Error()
+	}
+	return scheduledResult(rec.code(), rec.body)
+}
+
+// scheduledResult 按状态码判断结果
+func scheduledResult(status int, snippet []byte) (int, string, string) {
+	if status < 200 || status >= 300 {
+		return status, string(snippet), fmt.Sprintf("unexpected status %d", status)
+	}
+	return status, string(snippet), ""
+}
+
+// scheduledResponse 内部路由的响应，只保留状态码与响应体前 512 字节
+type scheduledResponse struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (r *scheduledResponse) Header() http.Header { return r.header }
+
+func (r *scheduledResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *scheduledResponse) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if room := scheduleResponseSnippet - len(r.body); room > 0 {
+		r.body = append(r.body, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// code 未写入状态码时视为 200
+func (r *scheduledResponse) code() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
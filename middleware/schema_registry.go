@@ -0,0 +1,743 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\schema_registry.go
+ * @Description: JSON Schema 注册表 - 服务按名称（subject）为路由登记请求体 Schema 的各个版本，网关以最新版本校验 JSON 请求体；
+ *               登记新版本前做向后兼容检查（新增必填字段、类型变化、枚举收窄、边界收紧等），不兼容时拒绝，除非显式 force；
+ *               记录保存在状态存储，多实例按刷新间隔同步
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/store"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Schema 注册表默认值
+const (
+	DefaultSchemaRegistryPath     = "/admin/schemas"
+	DefaultSchemaMaxBodyBytes     = 1 << 20
+	DefaultSchemaRefreshInterval  = 10 * time.Second
+	DefaultSchemaMaxVersions      = 50
+	schemaIndexStoreKey           = "gateway:schema:index"
+	schemaSubjectStorePrefix      = "gateway:schema:subject:"
+	schemaLockStorePrefix         = "gateway:schema:lock:"
+	schemaLockTTL                 = 10 * time.Second
+	schemaRegistrationSchemaLimit = 256 << 10
+)
+
+// Schema 校验结果（指标 result 标签）
+const (
+	SchemaResultValid     = "valid"
+	SchemaResultInvalid   = "invalid"   // 不符合 Schema
+	SchemaResultMalformed = "malformed" // 不是合法 JSON、内容类型不符或超出大小
+)
+
+// schemaNamePattern 合法的 subject 名称
+var schemaNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// SchemaRegistryConfig Schema 注册表配置
+type SchemaRegistryConfig struct {
+	Path  string   // 管理接口路径，默认 /admin/schemas
+	Roles []string // 调用方需具备其中任一角色，为空时只要求已认证；身份由认证中间件写入
+	Mode  string   // enforce（默认）拒绝不符合 Schema 的请求，report 只记录日志与指标
+	// MaxBodyBytes 参与校验的请求体上限，默认 1MiB，超出时拒绝
+	MaxBodyBytes int64
+	// RefreshInterval 从状态存储同步其他实例登记结果的间隔，默认 10s；未初始化状态存储时只保存在本进程
+	RefreshInterval time.Duration
+	// MaxVersions 每个 subject 保留的历史版本数，默认 50，超出时丢弃最早的版本（版本号不复用）
+	MaxVersions int
+}
+
+// SchemaVersion 一个 Schema 版本
+type SchemaVersion struct {
+	Version   int             `json:"version"`
+	Schema    json.RawMessage `json:"schema"`
+	Forced    bool            `json:"forced,omitempty"` // 不兼容但强制登记
+	Issues    []string        `json:"issues,omitempty"` // 强制登记时被忽略的不兼容项
+	CreatedBy string          `json:"created_by,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SchemaSubject 一条路由的 Schema 及其版本历史，最后一个版本生效
+type SchemaSubject struct {
+	Name      string          `json:"name"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"` // 路径模板，支持 {id} 参数
+	Versions  []SchemaVersion `json:"versions"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// SchemaSubjectSummary subject 摘要
+type SchemaSubjectSummary struct {
+	Name      string    `json:"name"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Active    int       `json:"active"`
+	Versions  int       `json:"versions"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SchemaRegistration 登记请求
+type SchemaRegistration struct {
+	Method string          `json:"method"` // 为空时沿用已登记的方法，首次登记默认 POST
+	Path   string          `json:"path"`   // 为空时沿用已登记的路径
+	Schema json.RawMessage `json:"schema"`
+	Force  bool            `json:"force,omitempty"` // 不兼容时仍登记
+}
+
+// SchemaCompatibility 兼容检查结果
+type SchemaCompatibility struct {
+	Subject    string   `json:"subject"`
+	Version    int      `json:"version"` // 登记成功时为新版本号，检查时为比较的版本号（0 表示首个版本）
+	Compatible bool     `json:"compatible"`
+	Issues     []string `json:"issues,omitempty"`
+}
+
+// Active 生效的版本
+func (s *SchemaSubject) Active() *SchemaVersion {
+	if len(s.Versions) == 0 {
+		return nil
+	}
+	return &s.Versions[len(s.Versions)-1]
+}
+
+// summary 摘要
+func (s *SchemaSubject) summary() SchemaSubjectSummary {
+	out := SchemaSubjectSummary{Name: s.Name, Method: s.Method, Path: s.Path, Versions: len(s.Versions), UpdatedAt: s.UpdatedAt}
+	if active := s.Active(); active != nil {
+		out.Active = active.Version
+	}
+	return out
+}
+
+// schemaEntry 编译后的生效版本
+type schemaEntry struct {
+	subject *SchemaSubject
+	schema  *jsonSchema
+}
+
+// schemaSnapshot 注册表快照，替换而不修改
+type schemaSnapshot struct {
+	entries map[string]*schemaEntry
+	routes  *RouteTable // 方法 + 路径 → *schemaEntry
+}
+
+// SchemaRegistry JSON Schema 注册表
+type SchemaRegistry struct {
+	config SchemaRegistryConfig
+	report bool
+
+	mu          sync.Mutex // 串行化本进程的登记与删除
+	snapshot    atomic.Pointer[schemaSnapshot]
+	lastRefresh atomic.Int64
+	refreshing  atomic.Bool
+}
+
+// schemaValidationTotal 请求体校验结果数（report 模式同样计数）
+var schemaValidationTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_schema_validation_total",
+	Help: "Total number of request bodies validated against registered JSON schemas",
+}, []string{"subject", "result", "mode"})
+
+// NewSchemaRegistry 创建注册表，已初始化状态存储时立即加载已登记的 Schema
+func NewSchemaRegistry(cfg SchemaRegistryConfig) (*SchemaRegistry, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = OpenAPIModeEnforce
+	case OpenAPIModeEnforce, OpenAPIModeReport:
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "schema registry: unknown mode %q", cfg.Mode)
+	}
+	if cfg.MaxBodyBytes < 0 || cfg.RefreshInterval < 0 || cfg.MaxVersions < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "schema registry: limits and intervals must not be negative")
+	}
+	cfg.Path = mathx.IfNotEmpty(strings.TrimSuffix(cfg.Path, "/"), DefaultSchemaRegistryPath)
+	cfg.MaxBodyBytes = mathx.IfNotZero(cfg.MaxBodyBytes, DefaultSchemaMaxBodyBytes)
+	cfg.RefreshInterval = mathx.IfNotZero(cfg.RefreshInterval, DefaultSchemaRefreshInterval)
+	cfg.MaxVersions = mathx.IfNotZero(cfg.MaxVersions, DefaultSchemaMaxVersions)
+
+	r := &SchemaRegistry{config: cfg, report: cfg.Mode == OpenAPIModeReport}
+	r.snapshot.Store(&schemaSnapshot{entries: map[string]*schemaEntry{}, routes: NewRouteTable(nil)})
+	if global.STORE != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.RefreshInterval)
+		defer cancel()
+		if err := r.reload(ctx); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "schema registry: load: %v", err)
+		}
+	}
+	return r, nil
+}
+
+// AdminPath 管理接口路径
+func (r *SchemaRegistry) AdminPath() string {
+	return r.config.Path
+}
+
+// Mode 当前校验模式
+func (r *SchemaRegistry) Mode() string {
+	return r.config.Mode
+}
+
+// Len 已登记的 subject 数
+func (r *SchemaRegistry) Len() int {
+	return len(r.snapshot.Load().entries)
+}
+
+// Subjects 全部 subject 摘要，按名称排序
+func (r *SchemaRegistry) Subjects() []SchemaSubjectSummary {
+	snap := r.snapshot.Load()
+	out := make([]SchemaSubjectSummary, 0, len(snap.entries))
+	for _, name := range slices.Sorted(maps.Keys(snap.entries)) {
+		out = append(out, snap.entries[name].subject.summary())
+	}
+	return out
+}
+
+// Subject 指定 subject 的版本历史
+func (r *SchemaRegistry) Subject(name string) (*SchemaSubject, bool) {
+	entry, ok := r.snapshot.Load().entries[name]
+	if !ok {
+		return nil, false
+	}
+	return entry.subject, true
+}
+
+// Register 登记新版本：Schema 与生效版本相同时不产生新版本；不兼容且未 force 时返回冲突错误，结果中带不兼容项
+func (r *SchemaRegistry) Register(ctx context.Context, name string, reg SchemaRegistration, by string) (*SchemaCompatibility, *errors.AppError) {
+	if !schemaNamePattern.MatchString(name) {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid schema subject name %q", name)
+	}
+	schema, appErr := compileSchemaRegistration(reg.Schema)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, appErr := r.lock(ctx, name)
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer unlock()
+
+	subject, err := r.load(ctx, name)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+	}
+	if subject == nil {
+		subject = &SchemaSubject{Name: name, Method: http.MethodPost}
+	}
+	bound := subject.Method + " " + subject.Path
+	if reg.Method != "" {
+		subject.Method = strings.ToUpper(reg.Method)
+	}
+	if reg.Path != "" {
+		subject.Path = reg.Path
+	}
+	if !strings.HasPrefix(subject.Path, "/") {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "schema path is required and must start with /")
+	}
+	if other := r.routeOwner(subject.Method, subject.Path, name); other != "" {
+		return nil, errors.NewErrorf(errors.ErrCodeConflict, "%s %s is already bound to schema subject %q", subject.Method, subject.Path, other)
+	}
+
+	result := &SchemaCompatibility{Subject: name, Compatible: true}
+	if active := subject.Active(); active != nil {
+		if bytes.Equal(compactJSON(active.Schema), compactJSON(reg.Schema)) && bound == subject.Method+" "+subject.Path {
+			result.Version = active.Version
+			return result, nil
+		}
+		prev, err := compileJSONSchema(active.Schema)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "schema %s v%d: %v", name, active.Version, err)
+		}
+		result.Issues = checkJSONSchemaCompat(prev, schema)
+		result.Compatible = len(result.Issues) == 0
+		if !result.Compatible && !reg.Force {
+			result.Version = active.Version
+			return result, errors.NewErrorf(errors.ErrCodeConflict,
+				"schema %s is not backward compatible with v%d (%d issues), register with force to override", name, active.Version, len(result.Issues))
+		}
+	}
+
+	version := SchemaVersion{
+		Version:   1,
+		Schema:    compactJSON(reg.Schema),
+		Forced:    !result.Compatible,
+		Issues:    result.Issues,
+		CreatedBy: by,
+		CreatedAt: time.Now().UTC(),
+	}
+	if active := subject.Active(); active != nil {
+		version.Version = active.Version + 1
+	}
+	subject.Versions = append(subject.Versions, version)
+	if extra := len(subject.Versions) - r.config.MaxVersions; extra > 0 {
+		subject.Versions = slices.Delete(subject.Versions, 0, extra)
+	}
+	subject.UpdatedAt = version.CreatedAt
+	if err := r.save(ctx, subject); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+	}
+	r.apply(name, &schemaEntry{subject: subject, schema: schema})
+	result.Version = version.Version
+	global.LOGGER.InfoContextKV(ctx, "JSON Schema 已登记",
+		"subject", name, "version", version.Version, "method", subject.Method, "path", subject.Path,
+		"forced", version.Forced, "issues", len(version.Issues), "by", by)
+	return result, nil
+}
+
+// Check 只做兼容检查，不登记
+func (r *SchemaRegistry) Check(ctx context.Context, name string, raw json.RawMessage) (*SchemaCompatibility, *errors.AppError) {
+	schema, appErr := compileSchemaRegistration(raw)
+	if appErr != nil {
+		return nil, appErr
+	}
+	subject, err := r.load(ctx, name)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+	}
+	result := &SchemaCompatibility{Subject: name, Compatible: true}
+	if subject == nil || subject.Active() == nil {
+		return result, nil
+	}
+	active := subject.Active()
+	prev, err := compileJSONSchema(active.Schema)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "schema %s v%d: %v", name, active.Version, err)
+	}
+	result.Version = active.Version
+	result.Issues = checkJSONSchemaCompat(prev, schema)
+	result.Compatible = len(result.Issues) == 0
+	return result, nil
+}
+
+// Delete 删除 subject 及其全部版本，对应路由不再校验
+func (r *SchemaRegistry) Delete(ctx context.Context, name string) *errors.AppError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, appErr := r.lock(ctx, name)
+	if appErr != nil {
+		return appErr
+	}
+	defer unlock()
+
+	subject, err := r.load(ctx, name)
+	if err != nil {
+		return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+	}
+	if subject == nil {
+		return errors.NewErrorf(errors.ErrCodeNotFound, "schema subject %s not found", name)
+	}
+	if global.STORE != nil {
+		if err := global.STORE.Del(ctx, schemaSubjectStorePrefix+name); err != nil {
+			return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+		}
+		if err := r.saveIndex(ctx, name, false); err != nil {
+			return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+		}
+	}
+	r.apply(name, nil)
+	global.LOGGER.InfoContextKV(ctx, "JSON Schema 已删除", "subject", name)
+	return nil
+}
+
+// Handle 按生效版本校验匹配路由的 JSON 请求体，不通过时按模式拒绝或记录
+func (r *SchemaRegistry) Handle(w http.ResponseWriter, req *http.Request, next http.Handler) {
+	r.maybeRefresh()
+	value, ok := r.snapshot.Load().routes.Match(req.Method, req.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, req)
+		return
+	}
+	entry := value.(*schemaEntry)
+
+	code, result, messages := r.checkBody(req, entry)
+	schemaValidationTotal.WithLabelValues(entry.subject.Name, result, r.config.Mode).Inc()
+	if result == SchemaResultValid {
+		next.ServeHTTP(w, req)
+		return
+	}
+	message := strings.Join(messages, "; ")
+	if r.report && code != errors.ErrCodeRequestTooLarge {
+		global.LOGGER.WarnContextKV(req.Context(), "请求体不符合 JSON Schema",
+			"subject", entry.subject.Name, "method", req.Method, "path", req.URL.Path, "message", message)
+		next.ServeHTTP(w, req)
+		return
+	}
+	response.WriteAppErrorf(w, code, "request body does not match schema %s v%d: %s",
+		entry.subject.Name, entry.subject.Active().Version, message)
+}
+
+// checkBody 读取并校验请求体，读取后还原供下游使用
+func (r *SchemaRegistry) checkBody(req *http.Request, entry *schemaEntry) (errors.ErrorCode, string, []string) {
+	if ct := req.Header.Get(constants.HeaderContentType); ct != "" {
+		mediaType, _, _ := mime.ParseMediaType(ct)
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return errors.ErrCodeInvalidContentType, SchemaResultMalformed, []string{"content type must be JSON, got " + ct}
+		}
+	}
+	var data []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		data, err = io.ReadAll(io.LimitReader(req.Body, r.config.MaxBodyBytes+1))
+		_ = req.Body.Close()
+		if err != nil {
+			return errors.ErrCodeBadRequest, SchemaResultMalformed, []string{"read body: " + err.Error()}
+		}
+		if int64(len(data)) > r.config.MaxBodyBytes {
+			return errors.ErrCodeRequestTooLarge, SchemaResultMalformed, []string{"body exceeds " + strconv.FormatInt(r.config.MaxBodyBytes, 10) + " bytes"}
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errors.ErrCodeMissingParameter, SchemaResultMalformed, []string{"request body is required"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return errors.ErrCodeInvalidParameter, SchemaResultMalformed, []string{"body is not valid JSON: " + err.Error()}
+	}
+	if dec.More() {
+		return errors.ErrCodeInvalidParameter, SchemaResultMalformed, []string{"body contains more than one JSON value"}
+	}
+	if errs := entry.schema.validate(value); len(errs) > 0 {
+		return errors.ErrCodeInvalidParameter, SchemaResultInvalid, errs
+	}
+	return errors.ErrCodeOK, SchemaResultValid, nil
+}
+
+// compileSchemaRegistration 校验并编译登记的 Schema
+func compileSchemaRegistration(raw json.RawMessage) (*jsonSchema, *errors.AppError) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, errors.NewError(errors.ErrCodeMissingParameter, "schema is required")
+	}
+	if len(raw) > schemaRegistrationSchemaLimit {
+		return nil, errors.NewErrorf(errors.ErrCodeRequestTooLarge, "schema exceeds %d bytes", schemaRegistrationSchemaLimit)
+	}
+	schema, err := compileJSONSchema(raw)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid json schema: %v", err)
+	}
+	return schema, nil
+}
+
+// compactJSON 去除空白，相同 Schema 的不同排版视为同一版本
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// routeOwner 绑定在同一方法与路径模板上的其他 subject
+func (r *SchemaRegistry) routeOwner(method, path, name string) string {
+	for other, entry := range r.snapshot.Load().entries {
+		if other != name && entry.subject.Method == method && entry.subject.Path == path {
+			return other
+		}
+	}
+	return ""
+}
+
+// apply 以替换单个 subject 的方式生成新快照，entry 为 nil 时删除
+func (r *SchemaRegistry) apply(name string, entry *schemaEntry) {
+	entries := maps.Clone(r.snapshot.Load().entries)
+	if entry == nil {
+		delete(entries, name)
+	} else {
+		entries[name] = entry
+	}
+	r.snapshot.Store(newSchemaSnapshot(entries))
+}
+
+// newSchemaSnapshot 构建路由表，字面量段多的路径优先，避免 /users/{id} 遮住 /users/me
+func newSchemaSnapshot(entries map[string]*schemaEntry) *schemaSnapshot {
+	list := slices.Collect(maps.Values(entries))
+	slices.SortStableFunc(list, func(a, b *schemaEntry) int {
+		if d := openAPITemplateRank(b.subject.Path) - openAPITemplateRank(a.subject.Path); d != 0 {
+			return d
+		}
+		return strings.Compare(a.subject.Name, b.subject.Name)
+	})
+	patterns := make([]RoutePattern, 0, len(list))
+	for _, entry := range list {
+		patterns = append(patterns, openAPIRoutePattern(entry.subject.Path, []string{entry.subject.Method}, entry))
+	}
+	return &schemaSnapshot{entries: entries, routes: NewRouteTable(patterns)}
+}
+
+// lock 跨实例串行化同一 subject 的修改，未初始化状态存储时只靠本进程的互斥锁
+func (r *SchemaRegistry) lock(ctx context.Context, name string) (func(), *errors.AppError) {
+	if global.STORE == nil {
+		return func() {}, nil
+	}
+	key := schemaLockStorePrefix + name
+	ok, err := global.STORE.SetNX(ctx, key, "1", schemaLockTTL)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "schema store: %v", err)
+	}
+	if !ok {
+		return nil, errors.NewErrorf(errors.ErrCodeConflict, "schema subject %s is being modified, retry later", name)
+	}
+	return func() { _ = global.STORE.Del(context.WithoutCancel(ctx), key) }, nil
+}
+
+// load 读取 subject，优先读状态存储，不存在时返回 nil
+func (r *SchemaRegistry) load(ctx context.Context, name string) (*SchemaSubject, error) {
+	if global.STORE == nil {
+		if entry, ok := r.snapshot.Load().entries[name]; ok {
+			subject := *entry.subject
+			subject.Versions = slices.Clone(entry.subject.Versions)
+			return &subject, nil
+		}
+		return nil, nil
+	}
+	raw, err := global.STORE.Get(ctx, schemaSubjectStorePrefix+name)
+	if stderrors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var subject SchemaSubject
+	if err := json.Unmarshal([]byte(raw), &subject); err != nil || subject.Name != name {
+		return nil, fmt.Errorf("corrupt schema subject record %s", name)
+	}
+	return &subject, nil
+}
+
+// save 写入 subject 与索引
+func (r *SchemaRegistry) save(ctx context.Context, subject *SchemaSubject) error {
+	if global.STORE == nil {
+		return nil
+	}
+	data, err := json.Marshal(subject)
+	if err != nil {
+		return err
+	}
+	if err := global.STORE.Set(ctx, schemaSubjectStorePrefix+subject.Name, string(data), 0); err != nil {
+		return err
+	}
+	return r.saveIndex(ctx, subject.Name, true)
+}
+
+// saveIndex 在索引中加入或移除 subject
+func (r *SchemaRegistry) saveIndex(ctx context.Context, name string, add bool) error {
+	names, err := r.index(ctx)
+	if err != nil {
+		return err
+	}
+	names = slices.DeleteFunc(names, func(n string) bool { return n == name })
+	if add {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return global.STORE.Set(ctx, schemaIndexStoreKey, string(data), 0)
+}
+
+// index 索引中的 subject 名称
+func (r *SchemaRegistry) index(ctx context.Context) ([]string, error) {
+	raw, err := global.STORE.Get(ctx, schemaIndexStoreKey)
+	if stderrors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(raw), &names); err != nil {
+		return nil, fmt.Errorf("corrupt schema index: %v", err)
+	}
+	return names, nil
+}
+
+// maybeRefresh 到达刷新间隔时在后台从状态存储同步，不阻塞请求
+func (r *SchemaRegistry) maybeRefresh() {
+	if global.STORE == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	if now-r.lastRefresh.Load() < int64(r.config.RefreshInterval) || !r.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	r.lastRefresh.Store(now)
+	go func() {
+		defer r.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), r.config.RefreshInterval)
+		defer cancel()
+		if err := r.reload(ctx); err != nil {
+			global.LOGGER.WarnKV("JSON Schema 同步失败，沿用上次的结果", "error", err)
+		}
+	}()
+}
+
+// reload 从状态存储重建快照，单个 subject 损坏时跳过并保留其当前生效版本；持锁避免覆盖并发登记的结果
+func (r *SchemaRegistry) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names, err := r.index(ctx)
+	if err != nil {
+		return err
+	}
+	current := r.snapshot.Load().entries
+	entries := make(map[string]*schemaEntry, len(names))
+	for _, name := range names {
+		subject, err := r.load(ctx, name)
+		if err != nil || subject == nil || subject.Active() == nil {
+			if entry, ok := current[name]; ok && err != nil {
+				entries[name] = entry
+			}
+			continue
+		}
+		if entry, ok := current[name]; ok && entry.subject.UpdatedAt.Equal(subject.UpdatedAt) &&
+			entry.subject.Active().Version == subject.Active().Version {
+			entries[name] = entry
+			continue
+		}
+		schema, err := compileJSONSchema(subject.Active().Schema)
+		if err != nil {
+			global.LOGGER.WarnKV("JSON Schema 编译失败，已跳过", "subject", name, "error", err)
+			continue
+		}
+		entries[name] = &schemaEntry{subject: subject, schema: schema}
+	}
+	r.snapshot.Store(newSchemaSnapshot(entries))
+	r.lastRefresh.Store(time.Now().UnixNano())
+	return nil
+}
+
+// AdminHandler 管理接口：
+//
+//	GET    {path}                               全部 subject 摘要
+//	GET    {path}/{name}                        subject 与版本历史
+//	GET    {path}/{name}/versions/{version}     指定版本的 Schema，version 为 latest 时取生效版本
+//	POST   {path}/{name}/versions               登记新版本（SchemaRegistration），不兼容时返回 409 与不兼容项
+//	POST   {path}/{name}/compatibility          只做兼容检查，请求体同登记
+//	DELETE {path}/{name}                        删除 subject
+func (r *SchemaRegistry) AdminHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p := contextPrincipal(req.Context())
+		if p.IsAnonymous() {
+			response.WriteAppError(w, errors.ErrUnauthorized)
+			return
+		}
+		if !p.HasAnyRole(r.config.Roles...) {
+			response.WriteAppError(w, errors.ErrForbidden)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, r.config.Path), "/"), "/")
+		name, action := parts[0], strings.Join(parts[1:], "/")
+		switch {
+		case name == "" && req.Method == http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, map[string]any{"mode": r.config.Mode, "subjects": r.Subjects()})
+		case name == "":
+			response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		case action == "" && req.Method == http.MethodGet:
+			subject, ok := r.Subject(name)
+			if !ok {
+				response.WriteNotFoundResult(w, "schema subject "+name+" not found")
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusOK, subject)
+		case action == "" && req.Method == http.MethodDelete:
+			if appErr := r.Delete(req.Context(), name); appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasPrefix(action, "versions/") && req.Method == http.MethodGet:
+			r.writeVersion(w, name, strings.TrimPrefix(action, "versions/"))
+		case (action == "versions" || action == "compatibility") && req.Method == http.MethodPost:
+			var reg SchemaRegistration
+			if err := json.NewDecoder(io.LimitReader(req.Body, schemaRegistrationSchemaLimit+4096)).Decode(&reg); err != nil {
+				response.WriteAppErrorf(w, errors.ErrCodeBadRequest, "invalid schema registration: %v", err)
+				return
+			}
+			if action == "compatibility" {
+				result, appErr := r.Check(req.Context(), name, reg.Schema)
+				if appErr != nil {
+					response.WriteAppError(w, appErr)
+					return
+				}
+				response.WriteJSONResponse(w, http.StatusOK, result)
+				return
+			}
+			result, appErr := r.Register(req.Context(), name, reg, p.Subject)
+			switch {
+			case appErr != nil && result != nil:
+				response.WriteJSONResponse(w, http.StatusConflict, map[string]any{"error": appErr.Error(), "result": result})
+			case appErr != nil:
+				response.WriteAppError(w, appErr)
+			default:
+				response.WriteJSONResponse(w, http.StatusCreated, result)
+			}
+		case action == "" || action == "versions" || action == "compatibility" || strings.HasPrefix(action, "versions/"):
+			response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		default:
+			response.WriteNotFoundResult(w, "unknown schema registry endpoint")
+		}
+	}
+}
+
+// writeVersion 写出指定版本的 Schema 原文
+func (r *SchemaRegistry) writeVersion(w http.ResponseWriter, name, version string) {
+	subject, ok := r.Subject(name)
+	if !ok {
+		response.WriteNotFoundResult(w, "schema subject "+name+" not found")
+		return
+	}
+	target := subject.Active()
+	if version != "latest" {
+		n, err := strconv.Atoi(version)
+		if err != nil {
+			response.WriteAppErrorf(w, errors.ErrCodeInvalidParameter, "invalid schema version %q", version)
+			return
+		}
+		i := slices.IndexFunc(subject.Versions, func(v SchemaVersion) bool { return v.Version == n })
+		if i < 0 {
+			response.WriteNotFoundResult(w, fmt.Sprintf("schema %s v%d not found", name, n))
+			return
+		}
+		target = &subject.Versions[i]
+	}
+	response.WriteJSONResponse(w, http.StatusOK, target)
+}
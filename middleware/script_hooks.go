@@ -0,0 +1,780 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\script_hooks.go
+ * @Description: Lua 脚本钩子 - 按路由在转发前（on-request）与返回前（on-response）执行 Lua 脚本改写请求 / 响应，
+ *               脚本运行在裁剪过标准库的沙箱中，受执行超时、调用栈 / 寄存器上限与报文大小限制
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"gopkg.in/yaml.v3"
+)
+
+// 脚本资源限制默认值
+const (
+	DefaultScriptTimeout         = 50 * time.Millisecond
+	DefaultScriptMaxBodyBytes    = 1 << 20
+	DefaultScriptCallStackSize   = 128
+	DefaultScriptRegistryMaxSize = 64 * 1024
+	DefaultScriptMaxAllocBytes   = 64 << 20
+)
+
+// scriptAllocCheckInterval 脚本执行期间检查堆分配量的间隔
+const scriptAllocCheckInterval = time.Millisecond
+
+// scriptAllocMetric 进程累计堆分配字节数
+const scriptAllocMetric = "/gc/heap/allocs:bytes"
+
+// ScriptHooksConfig 脚本钩子配置（对应配置文件顶层 scripts 段）
+type ScriptHooksConfig struct {
+	Limits   ScriptLimits `json:"limits" yaml:"limits" mapstructure:"limits"`
+	FailOpen bool         `json:"fail_open" yaml:"fail-open" mapstructure:"fail-open"` // 脚本出错或超时时放行原始请求 / 响应，默认返回 500
+	Rules    []ScriptRule `json:"rules" yaml:"rules" mapstructure:"rules"`             // 按声明顺序匹配，先声明者优先
+}
+
+// ScriptLimits 脚本沙箱资源限制，零值使用默认值
+type ScriptLimits struct {
+	Timeout         time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`                               // 单次执行超时
+	MaxBodyBytes    int           `json:"max_body_bytes" yaml:"max-body-bytes" mapstructure:"max-body-bytes"`          // 交给脚本的请求 / 响应体上限，也是脚本生成字符串的上限
+	CallStackSize   int           `json:"call_stack_size" yaml:"call-stack-size" mapstructure:"call-stack-size"`       // Lua 调用栈深度
+	RegistryMaxSize int           `json:"registry_max_size" yaml:"registry-max-size" mapstructure:"registry-max-size"` // Lua 数据栈（寄存器）最大槽位数
+	MaxAllocBytes   int           `json:"max_alloc_bytes" yaml:"max-alloc-bytes" mapstructure:"max-alloc-bytes"`       // 单次执行期间进程堆分配增量上限，超出时中断
+}
+
+// ScriptRule 单条路由的脚本
+type ScriptRule struct {
+	Name       string   `json:"name" yaml:"name" mapstructure:"name"`                      // 规则名（指标标签），默认 script-<序号>
+	Paths      []string `json:"paths" yaml:"paths" mapstructure:"paths"`                   // 路径，以 * 结尾表示前缀匹配
+	Methods    []string `json:"methods" yaml:"methods" mapstructure:"methods"`             // HTTP 方法，为空匹配全部
+	Body       bool     `json:"body" yaml:"body" mapstructure:"body"`                      // 向脚本暴露请求体 / 响应体（需缓冲），默认只暴露请求行与头
+	OnRequest  string   `json:"on_request" yaml:"on-request" mapstructure:"on-request"`    // 转发前执行，可改写 request 或调用 respond 直接返回
+	OnResponse string   `json:"on_response" yaml:"on-response" mapstructure:"on-response"` // 写出响应前执行，可改写 response
+}
+
+// ScriptHooks 编译后的脚本钩子
+type ScriptHooks struct {
+	limits   ScriptLimits
+	failOpen bool
+	routes   *RouteTable
+	states   sync.Pool
+	count    int
+}
+
+// scriptRule 编译后的规则
+type scriptRule struct {
+	name       string
+	body       bool
+	onRequest  *lua.FunctionProto
+	onResponse *lua.FunctionProto
+}
+
+// scriptHookExecutions 脚本执行次数（注册到默认 Registry）
+var scriptHookExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_script_hook_executions_total",
+	Help: "Total number of script hook executions by rule, phase and result",
+}, []string{"rule", "phase", "result"})
+
+// 脚本执行阶段与结果（指标标签）
+const (
+	scriptPhaseRequest  = "request"
+	scriptPhaseResponse = "response"
+
+	scriptResultOK        = "ok"
+	scriptResultResponded = "responded"
+	scriptResultError     = "error"
+	scriptResultTimeout   = "timeout"
+	scriptResultSkipped   = "skipped"
+)
+
+// scriptDisabledGlobals 沙箱中移除的基础库函数：加载代码、访问文件与修改函数环境
+var scriptDisabledGlobals = []string{
+	"dofile", "loadfile", "load", "loadstring", "require", "module",
+	"collectgarbage", "getfenv", "setfenv", "newproxy", "print", "_printregs",
+}
+
+// ParseScriptHooks 把配置文件中 scripts 段的原始值解析为配置，未知字段视为错误
+func ParseScriptHooks(raw any) (*ScriptHooksConfig, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "scripts: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	cfg := &ScriptHooksConfig{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "scripts: %v", err)
+	}
+	return cfg, nil
+}
+
+// NewScriptHooks 校验配置并编译脚本，语法错误在此时返回
+func NewScriptHooks(cfg ScriptHooksConfig) (*ScriptHooks, error) {
+	limits := cfg.Limits
+	if limits.Timeout < 0 || limits.MaxBodyBytes < 0 || limits.CallStackSize < 0 || limits.RegistryMaxSize < 0 || limits.MaxAllocBytes < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "scripts: limits must not be negative")
+	}
+	if limits.Timeout == 0 {
+		limits.Timeout = DefaultScriptTimeout
+	}
+	if limits.MaxBodyBytes == 0 {
+		limits.MaxBodyBytes = DefaultScriptMaxBodyBytes
+	}
+	if limits.CallStackSize == 0 {
+		limits.CallStackSize = DefaultScriptCallStackSize
+	}
+	if limits.RegistryMaxSize == 0 {
+		limits.RegistryMaxSize = DefaultScriptRegistryMaxSize
+	}
+	if limits.MaxAllocBytes == 0 {
+		limits.MaxAllocBytes = DefaultScriptMaxAllocBytes
+	}
+
+	var patterns []RoutePattern
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("script-%d", i)
+		}
+		if len(rule.Paths) == 0 {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "scripts: rule %q has no paths", rule.Name)
+		}
+		if rule.OnRequest == "" && rule.OnResponse == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "scripts: rule %q sets neither on-request nor on-response", rule.Name)
+		}
+		compiled := &scriptRule{name: rule.Name, body: rule.Body}
+		var err error
+		if compiled.onRequest, err = compileScript(rule.Name+":on-request", rule.OnRequest); err != nil {
+			return nil, err
+		}
+		if compiled.onResponse, err = compileScript(rule.Name+":on-response", rule.OnResponse); err != nil {
+			return nil, err
+		}
+		for _, path := range rule.Paths {
+			patterns = append(patterns, authnPattern(path, rule.Methods, compiled))
+		}
+	}
+
+	h := &ScriptHooks{limits: limits, failOpen: cfg.FailOpen, routes: NewRouteTable(patterns), count: len(cfg.Rules)}
+	h.states.New = func() any { return h.newState() }
+	return h, nil
+}
+
+// compileScript 编译脚本，源码为空时返回 nil
+func compileScript(name, source string) (*lua.FunctionProto, error) {
+	if source == "" {
+		return nil, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "scripts: %s: %v", name, err)
+	}
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "scripts: %s: %v", name, err)
+	}
+	return proto, nil
+}
+
+// scriptState 池化的 Lua 虚拟机及其共享表快照
+type scriptState struct {
+	L      *lua.LState
+	shared []scriptSharedTable
+}
+
+// scriptSharedTable 各次执行共享的表（全局表、标准库表、字符串元表）在初始化完成时的内容
+type scriptSharedTable struct {
+	table  *lua.LTable
+	meta   lua.LValue
+	fields map[lua.LValue]lua.LValue
+}
+
+// newState 创建沙箱 Lua 虚拟机：只加载 base / table / string / math，移除加载代码与修改环境的函数，
+// string.rep、string.format、table.concat 的结果受 MaxBodyBytes 限制；记录共享表的快照，归还前据此检查是否被改写
+func (h *ScriptHooks) newState() *scriptState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:        true,
+		CallStackSize:       h.limits.CallStackSize,
+		RegistryMaxSize:     h.limits.RegistryMaxSize,
+		MinimizeStackMemory: true,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range scriptDisabledGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		maxBytes := h.limits.MaxBodyBytes
+		str.RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+			s, n := L.CheckString(1), L.CheckInt(2)
+			if len(s) > 0 && n > maxBytes/len(s) {
+				L.RaiseError("string.rep result exceeds %d bytes", maxBytes)
+			}
+			L.Push(lua.LString(strings.Repeat(s, max(n, 0))))
+			return 1
+		}))
+		limitScriptResult(L, str, "format", maxBytes, scriptFormatSize)
+	}
+	if tbl, ok := L.GetGlobal(lua.TabLibName).(*lua.LTable); ok {
+		limitScriptResult(L, tbl, "concat", h.limits.MaxBodyBytes, scriptConcatSize)
+	}
+	return &scriptState{L: L, shared: snapshotSharedTables(L)}
+}
+
+// limitScriptResult 包装库函数：调用前按 size 估算结果长度的上界，超过 maxBytes 时报错
+func limitScriptResult(L *lua.LState, lib *lua.LTable, name string, maxBytes int, size func(L *lua.LState) int) {
+	fn, ok := lib.RawGetString(name).(*lua.LFunction)
+	if !ok || fn.GFunction == nil {
+		return
+	}
+	lib.RawSetString(name, L.NewFunction(func(L *lua.LState) int {
+		if size(L) > maxBytes {
+			L.RaiseError("%s result exceeds %d bytes", name, maxBytes)
+		}
+		return fn.GFunction(L)
+	}))
+}
+
+// scriptFormatSize string.format 结果长度的上界：格式串、各参数的字符串形式与格式串中的数字（宽度 / 精度）之和
+func scriptFormatSize(L *lua.LState) int {
+	format := L.CheckString(1)
+	size := len(format)
+	for i := 2; i <= L.GetTop(); i++ {
+		size += len(L.Get(i).String())
+	}
+	width := 0
+	for i := 0; i < len(format); i++ {
+		if c := format[i]; c >= '0' && c <= '9' {
+			width = min(width*10+int(c-'0'), math.MaxInt32)
+			continue
+		}
+		size += width
+		width = 0
+	}
+	return size + width
+}
+
+// scriptConcatSize table.concat 结果长度的上界：区间内各元素的字符串形式与分隔符之和
+func scriptConcatSize(L *lua.LState) int {
+	tbl := L.CheckTable(1)
+	sep := L.OptString(2, "")
+	n := tbl.Len()
+	size := 0
+	for i := max(L.OptInt(3, 1), 1); i <= min(L.OptInt(4, n), n); i++ {
+		size += len(tbl.RawGetInt(i).String()) + len(sep)
+	}
+	return size
+}
+
+// snapshotSharedTables 记录全局表、其中的库表与字符串元表；
+// 脚本的环境只隔离了全局变量的写入，通过 _G、库表或 getmetatable("") 仍能改写这些表
+func snapshotSharedTables(L *lua.LState) []scriptSharedTable {
+	tables := []*lua.LTable{L.G.Global}
+	L.G.Global.ForEach(func(_, v lua.LValue) {
+		if tbl, ok := v.(*lua.LTable); ok && tbl != L.G.Global {
+			tables = append(tables, tbl)
+		}
+	})
+	if mt, ok := L.GetMetatable(lua.LString("")).(*lua.LTable); ok {
+		tables = append(tables, mt)
+	}
+
+	shared := make([]scriptSharedTable, 0, len(tables))
+	for _, tbl := range tables {
+		fields := make(map[lua.LValue]lua.LValue)
+		tbl.ForEach(func(k, v lua.LValue) { fields[k] = v })
+		shared = append(shared, scriptSharedTable{table: tbl, meta: L.GetMetatable(tbl), fields: fields})
+	}
+	return shared
+}
+
+// modified 共享表是否被脚本改写（如替换 string.rep、经 _G 写入字段、修改字符串元表）
+func (s *scriptState) modified() bool {
+	for _, shared := range s.shared {
+		if s.L.GetMetatable(shared.table) != shared.meta {
+			return true
+		}
+		n, changed := 0, false
+		shared.table.ForEach(func(k, v lua.LValue) {
+			n++
+			if shared.fields[k] != v {
+				changed = true
+			}
+		})
+		if changed || n != len(shared.fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptRun 一次脚本执行的输入输出
+type scriptRun struct {
+	request   *lua.LTable
+	response  *lua.LTable
+	responded bool
+	status    int
+	body      string
+	headers   *lua.LTable
+}
+
+// run 在独立环境中执行脚本：脚本写入的全局变量与改写的库表不会影响之后的请求；超时或出错返回错误
+func (h *ScriptHooks) run(ctx context.Context, proto *lua.FunctionProto, prepare func(L *lua.LState, run *scriptRun)) (*scriptRun, bool, error) {
+	state := h.states.Get().(*scriptState)
+	L := state.L
+	ctx, cancel := context.WithTimeout(ctx, h.limits.Timeout)
+	defer cancel()
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	run := &scriptRun{}
+	env := L.NewTable()
+	meta := L.NewTable()
+	meta.RawSetString("__index", L.G.Global)
+	L.SetMetatable(env, meta)
+	prepare(L, run)
+	env.RawSetString("request", run.request)
+	if run.response != nil {
+		env.RawSetString("response", run.response)
+	}
+	env.RawSetString("respond", L.NewFunction(func(L *lua.LState) int {
+		run.responded = true
+		run.status = L.CheckInt(1)
+		run.body = L.OptString(2, "")
+		run.headers = L.OptTable(3, nil)
+		return 0
+	}))
+	env.RawSetString("log", L.NewFunction(func(L *lua.LState) int {
+		global.LOGGER.InfoContext(ctx, "[script] %s", L.CheckString(1))
+		return 0
+	}))
+
+	fn := L.NewFunctionFromProto(proto)
+	fn.Env = env
+	L.SetContext(ctx)
+	stopWatch := h.watchAllocs(ctx, abort)
+	L.Push(fn)
+	err := L.PCall(0, 0, nil)
+	stopWatch()
+	L.RemoveContext()
+	L.SetTop(0)
+
+	// 脚本已正常结束时不视为中断，即便上下文随后到期
+	timedOut := err != nil && ctx.Err() != nil
+	if cause := context.Cause(ctx); timedOut && cause != ctx.Err() {
+		err = cause
+	}
+	if timedOut || state.modified() {
+		// 被中断或共享表被改写的虚拟机不可复用，对象池会重新创建
+		L.Close()
+	} else {
+		h.states.Put(state)
+	}
+	return run, timedOut, err
+}
+
+// watchAllocs 执行期间定期读取进程累计堆分配量，增量超过 MaxAllocBytes 时中断脚本；
+// Lua 虚拟机没有堆配额，`..` 拼接等无法逐次拦截的分配由此兜底。该指标是进程级的，并发请求的分配同样计入
+func (h *ScriptHooks) watchAllocs(ctx context.Context, abort context.CancelCauseFunc) (stop func()) {
+	sample := []metrics.Sample{{Name: scriptAllocMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return func() {}
+	}
+	start, limit := sample[0].Value.Uint64(), uint64(h.limits.MaxAllocBytes)
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(scriptAllocCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				metrics.Read(sample)
+				if sample[0].Value.Uint64()-start > limit {
+					abort(errors.NewErrorf(errors.ErrCodeInternalServerError, "script allocated more than %d bytes", limit))
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// Len 规则条数
+func (h *ScriptHooks) Len() int {
+	return h.count
+}
+
+// Handle 命中规则的请求执行 on-request / on-response 脚本，未命中时原样放行
+func (h *ScriptHooks) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	value, ok := h.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	rule := value.(*scriptRule)
+
+	var reqBody []byte
+	if rule.body && r.Body != nil && r.Body != http.NoBody {
+		data, err := io.ReadAll(io.LimitReader(r.Body, int64(h.limits.MaxBodyBytes)+1))
+		_ = r.Body.Close()
+		if err != nil {
+			response.WriteErrorResponse(w, errors.ErrBadRequest)
+			return
+		}
+		if len(data) > h.limits.MaxBodyBytes {
+			response.WriteErrorResponse(w, errors.ErrRequestTooLarge)
+			return
+		}
+		reqBody = data
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if rule.onRequest != nil {
+		var done bool
+		r, done = h.onRequest(w, r, rule, reqBody)
+		if done {
+			return
+		}
+	}
+	if rule.onResponse == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// 路由声明为 streaming 时不缓冲响应体，on-response 只能改写状态码与响应头
+	maxBody, bufferable := ResponseBufferLimit(r.Context(), h.limits.MaxBodyBytes)
+	sw := &scriptResponseWriter{ResponseWriter: w, hooks: h, rule: rule, request: r, buffered: rule.body && bufferable, maxBody: maxBody}
+	next.ServeHTTP(sw, r)
+	sw.finish()
+}
+
+// HTTPMiddleware 脚本钩子中间件
+func (h *ScriptHooks) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Handle(w, r, next)
+	})
+}
+
+// onRequest 执行 on-request 脚本并回写改动，返回改写后的请求；已写出响应（respond 或失败）时 done 为 true
+func (h *ScriptHooks) onRequest(w http.ResponseWriter, r *http.Request, rule *scriptRule, body []byte) (*http.Request, bool) {
+	run, timedOut, err := h.run(r.Context(), rule.onRequest, func(L *lua.LState, run *scriptRun) {
+		run.request = scriptRequestTable(L, r, rule.body, body)
+	})
+	if err != nil {
+		h.recordFailure(r.Context(), rule, scriptPhaseRequest, timedOut, err)
+		if h.failOpen {
+			return r, false
+		}
+		h.writeFailure(w)
+		return r, true
+	}
+
+	if run.responded {
+		scriptHookExecutions.WithLabelValues(rule.name, scriptPhaseRequest, scriptResultResponded).Inc()
+		if run.headers != nil {
+			applyScriptHeaders(w.Header(), run.headers)
+		}
+		w.WriteHeader(scriptStatus(run.status))
+		_, _ = io.WriteString(w, run.body)
+		return r, true
+	}
+	scriptHookExecutions.WithLabelValues(rule.name, scriptPhaseRequest, scriptResultOK).Inc()
+
+	r = r.Clone(r.Context())
+	req := run.request
+	if method := lua.LVAsString(req.RawGetString("method")); method != "" {
+		r.Method = strings.ToUpper(method)
+	}
+	if path := lua.LVAsString(req.RawGetString("path")); path != r.URL.Path && strings.HasPrefix(path, "/") {
+		r.URL.Path, r.URL.RawPath = path, ""
+	}
+	r.URL.RawQuery = lua.LVAsString(req.RawGetString("query"))
+	r.Header = http.Header{}
+	if headers, ok := req.RawGetString("headers").(*lua.LTable); ok {
+		applyScriptHeaders(r.Header, headers)
+	}
+	if rule.body {
+		newBody := lua.LVAsString(req.RawGetString("body"))
+		r.Body = io.NopCloser(strings.NewReader(newBody))
+		r.ContentLength = int64(len(newBody))
+		r.Header.Set(constants.HeaderContentLength, strconv.Itoa(len(newBody)))
+	}
+	return r, false
+}
+
+// recordFailure 记录脚本失败
+func (h *ScriptHooks) recordFailure(ctx context.Context, rule *scriptRule, phase string, timedOut bool, err error) {
+	result := scriptResultError
+	if timedOut {
+		result = scriptResultTimeout
+	}
+	scriptHookExecutions.WithLabelValues(rule.name, phase, result).Inc()
+	global.LOGGER.WarnContext(ctx, "脚本执行失败: rule=%s, phase=%s, result=%s, error=%v", rule.name, phase, result, err)
+}
+
+// writeFailure 脚本失败时的响应
+func (h *ScriptHooks) writeFailure(w http.ResponseWriter) {
+	response.WriteErrorResponseWithCode(w, http.StatusInternalServerError, constants.ScriptErrorCode, constants.ScriptErrorMessage)
+}
+
+// scriptRequestTable 构造脚本可见的 request：method / path / query / headers / client_ip / principal，按需包含 body
+func scriptRequestTable(L *lua.LState, r *http.Request, withBody bool, body []byte) *lua.LTable {
+	req := L.NewTable()
+	req.RawSetString("method", lua.LString(r.Method))
+	req.RawSetString("path", lua.LString(r.URL.Path))
+	req.RawSetString("query", lua.LString(r.URL.RawQuery))
+	req.RawSetString("host", lua.LString(r.Host))
+	req.RawSetString("client_ip", lua.LString(netx.GetClientIP(r)))
+	req.RawSetString("headers", scriptHeaderTable(L, r.Header))
+	if withBody {
+		req.RawSetString("body", lua.LString(body))
+	}
+	if p := contextPrincipal(r.Context()); !p.IsAnonymous() {
+		principal := L.NewTable()
+		principal.RawSetString("subject", lua.LString(p.Subject))
+		principal.RawSetString("tenant", lua.LString(p.Tenant))
+		principal.RawSetString("auth_type", lua.LString(p.AuthType))
+		principal.RawSetString("roles", scriptStringList(L, p.Roles))
+		principal.RawSetString("scopes", scriptStringList(L, p.Scopes))
+		req.RawSetString("principal", principal)
+	}
+	return req
+}
+
+// scriptHeaderTable 头转为 Lua 表：键为小写头名，单值为字符串，多值为数组
+func scriptHeaderTable(L *lua.LState, h http.Header) *lua.LTable {
+	tbl := L.NewTable()
+	for name, values := range h {
+		key := strings.ToLower(name)
+		if len(values) == 1 {
+			tbl.RawSetString(key, lua.LString(values[0]))
+		} else {
+			tbl.RawSetString(key, scriptStringList(L, values))
+		}
+	}
+	return tbl
+}
+
+// scriptStringList 字符串切片转为 Lua 数组
+func scriptStringList(L *lua.LState, values []string) *lua.LTable {
+	tbl := L.CreateTable(len(values), 0)
+	for _, v := range values {
+		tbl.Append(lua.LString(v))
+	}
+	return tbl
+}
+
+// applyScriptHeaders 按 Lua 表设置头：字符串或数字为单值，数组为多值，false 删除
+func applyScriptHeaders(h http.Header, tbl *lua.LTable) {
+	tbl.ForEach(func(key, value lua.LValue) {
+		name := http.CanonicalHeaderKey(lua.LVAsString(key))
+		if name == "" {
+			return
+		}
+		switch v := value.(type) {
+		case lua.LString, lua.LNumber:
+			h.Set(name, lua.LVAsString(v))
+		case *lua.LTable:
+			h.Del(name)
+			v.ForEach(func(_, item lua.LValue) {
+				h.Add(name, lua.LVAsString(item))
+			})
+		default:
+			h.Del(name)
+		}
+	})
+}
+
+// scriptStatus 脚本给出的状态码，超出范围时按 500 处理
+func scriptStatus(status int) int {
+	if status < 100 || status > 599 {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// scriptResponseWriter 在写出响应头前执行 on-response 脚本；Body 模式下缓冲完整响应，超过上限时放弃执行脚本并原样输出
+type scriptResponseWriter struct {
+	http.ResponseWriter
+	hooks    *ScriptHooks
+	rule     *scriptRule
+	request  *http.Request
+	buffered bool
+	maxBody  int // 缓冲上限，取路由缓冲策略或 MaxBodyBytes
+
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	passthrough bool // 缓冲超限后直接输出
+	discard     bool // 脚本失败已写出 500，丢弃上游响应体
+}
+
+// WriteHeader 非缓冲模式下执行脚本后写出；缓冲模式下只记录状态码
+func (w *scriptResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if w.buffered {
+		return
+	}
+	status, failed := w.runScript(nil)
+	if failed {
+		w.discard = true
+		w.hooks.writeFailure(w.ResponseWriter)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write 缓冲模式下累积响应体，超过上限时切换为直接输出
+func (w *scriptResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	if !w.buffered || w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) > w.maxBody {
+		scriptHookExecutions.WithLabelValues(w.rule.name, scriptPhaseResponse, scriptResultSkipped).Inc()
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		w.buf.Reset()
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush 缓冲模式下不能提前输出，其余情况透传
+func (w *scriptResponseWriter) Flush() {
+	if w.buffered && !w.passthrough {
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *scriptResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish 缓冲模式下执行脚本并写出最终响应
+func (w *scriptResponseWriter) finish() {
+	if !w.buffered || w.passthrough {
+		return
+	}
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = http.StatusOK, true
+	}
+	status, failed := w.runScript(w.buf.Bytes())
+	if failed {
+		w.hooks.writeFailure(w.ResponseWriter)
+		return
+	}
+	w.Header().Set(constants.HeaderContentLength, strconv.Itoa(w.buf.Len()))
+	w.ResponseWriter.WriteHeader(status)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// runScript 执行 on-response 脚本并回写状态码、响应头与（缓冲模式下的）响应体；
+// 失败且未开启 FailOpen 时清空上游响应头并返回 failed，由调用方写出 500
+func (w *scriptResponseWriter) runScript(body []byte) (int, bool) {
+	h := w.hooks
+	run, timedOut, err := h.run(w.request.Context(), w.rule.onResponse, func(L *lua.LState, run *scriptRun) {
+		run.request = scriptRequestTable(L, w.request, false, nil)
+		resp := L.NewTable()
+		resp.RawSetString("status", lua.LNumber(w.status))
+		resp.RawSetString("headers", scriptHeaderTable(L, w.Header()))
+		if w.buffered {
+			resp.RawSetString("body", lua.LString(body))
+		}
+		run.response = resp
+	})
+	if err != nil {
+		h.recordFailure(w.request.Context(), w.rule, scriptPhaseResponse, timedOut, err)
+		if h.failOpen {
+			return w.status, false
+		}
+		for name := range w.Header() {
+			w.Header().Del(name)
+		}
+		return 0, true
+	}
+	scriptHookExecutions.WithLabelValues(w.rule.name, scriptPhaseResponse, scriptResultOK).Inc()
+
+	resp := run.response
+	for name := range w.Header() {
+		w.Header().Del(name)
+	}
+	if headers, ok := resp.RawGetString("headers").(*lua.LTable); ok {
+		applyScriptHeaders(w.Header(), headers)
+	}
+	if w.buffered {
+		w.buf.Reset()
+		w.buf.WriteString(lua.LVAsString(resp.RawGetString("body")))
+	}
+	status := w.status
+	if n, ok := resp.RawGetString("status").(lua.LNumber); ok {
+		status = scriptStatus(int(n))
+	}
+	return status, false
+}
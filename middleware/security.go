@@ -4,7 +4,7 @@
  * @LastEditors: kamalyes 501893067@qq.com
  * @LastEditTime: 2025-12-11 18:02:50
  * @FilePath: \go-rpc-gateway\middleware\security.go
- * @Description: 安全中间件 - 包含CORS, CSP, CSRF, IP白名单等
+ * @Description: 安全中间件 - 包含CSP, CSRF, IP白名单等（CORS 见 cors.go）
  *
  * Copyright (c) 2024 by kamalyes, All Rights Reserved.
  */
@@ -19,91 +19,15 @@ import (
 	"strings"
 	"time"
 
-	"github.com/kamalyes/go-config/pkg/cors"
 	"github.com/kamalyes/go-config/pkg/security"
 	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/errors"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-rpc-gateway/response"
-	"github.com/kamalyes/go-toolbox/pkg/mathx"
 	"github.com/kamalyes/go-toolbox/pkg/netx"
 	"github.com/kamalyes/go-argus"
 )
 
-// CORSMiddleware CORS 中间件
-func CORSMiddleware(corsConfig *cors.Cors) HTTPMiddleware {
-	mergedHeaders := mathx.SliceUnion(cors.Default().AllowedHeaders, corsConfig.AllowedHeaders)
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			setCORSHeaders(w, r, corsConfig, mergedHeaders)
-
-			// 处理预检请求
-			if r.Method == constants.HTTPMethodOptions {
-				w.WriteHeader(http.StatusNoContent)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
-// setCORSHeaders 设置CORS相关头部
-func setCORSHeaders(w http.ResponseWriter, r *http.Request, config *cors.Cors, mergedHeaders []string) {
-	setAllowOrigin(w, r.Header.Get(constants.HeaderOrigin), config.AllowedOrigins)
-	setAllowMethods(w, config.AllowedMethods)
-	setAllowHeaders(w, mergedHeaders)
-	setAllowCredentials(w, config.AllowCredentials)
-	setMaxAge(w, config.MaxAge)
-}
-
-// setAllowOrigin 设置允许的源
-func setAllowOrigin(w http.ResponseWriter, origin string, allowOrigins []string) {
-	if len(allowOrigins) == 0 {
-		return
-	}
-
-	// 检查是否允许所有源或包含当前源
-	for _, allowedOrigin := range allowOrigins {
-		if allowedOrigin == "*" || allowedOrigin == origin {
-			w.Header().Set(constants.HeaderAccessControlAllowOrigin, origin)
-			return
-		}
-	}
-}
-
-// setAllowMethods 设置允许的方法
-func setAllowMethods(w http.ResponseWriter, methods []string) {
-	if len(methods) == 0 {
-		return
-	}
-
-	w.Header().Set(constants.HeaderAccessControlAllowMethods, strings.Join(methods, ", "))
-}
-
-// setAllowHeaders 设置允许的头部
-func setAllowHeaders(w http.ResponseWriter, headers []string) {
-	if len(headers) == 0 {
-		return
-	}
-
-	w.Header().Set(constants.HeaderAccessControlAllowHeaders, strings.Join(headers, ", "))
-}
-
-// setAllowCredentials 设置是否允许凭证
-func setAllowCredentials(w http.ResponseWriter, allowCredentials bool) {
-	if allowCredentials {
-		w.Header().Set(constants.HeaderAccessControlAllowCredentials, constants.CORSCredentialsTrue)
-	}
-}
-
-// setMaxAge 设置预检请求缓存时间
-func setMaxAge(w http.ResponseWriter, maxAge string) {
-	if maxAge != "" {
-		w.Header().Set(constants.HeaderAccessControlMaxAge, maxAge)
-	}
-}
-
 // SCPMiddleware 安全中间件 - 从配置读取 CSP 策略
 // 参数 cspConfig: 从 go-config/pkg/security 读取的 CSP 配置
 func SCPMiddleware(cspConfig *security.CSP) HTTPMiddleware {
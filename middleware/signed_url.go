@@ -0,0 +1,295 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\signed_url.go
+ * @Description: 签名 URL - 生成带过期时间、允许方法与可选 IP 绑定的临时访问链接（报表下载、邀请链接），
+ *               校验通过的请求跳过签名校验、外部授权与 Casbin 授权
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+)
+
+// 签名 URL 查询参数
+const (
+	SignedURLParamExpires   = "gw_expires" // 过期时间（Unix 秒）
+	SignedURLParamMethod    = "gw_method"  // 允许的 HTTP 方法
+	SignedURLParamIP        = "gw_ip"      // 绑定的客户端 IP
+	SignedURLParamSignature = "gw_sig"     // HMAC-SHA256 签名（base64url）
+)
+
+const (
+	DefaultSignedURLTTL      = 15 * time.Minute   // 默认有效期
+	DefaultSignedURLMaxTTL   = 7 * 24 * time.Hour // 默认最长有效期
+	DefaultSignedURLMinKey   = 32                 // 签名密钥最短字节数
+	DefaultSignedURLMintPath = "/admin/signed-url"
+)
+
+// SignedURLConfig 签名 URL 配置
+type SignedURLConfig struct {
+	Key          []byte        // 签名密钥（至少 32 字节）
+	PreviousKeys [][]byte      // 轮换前的旧密钥，仅用于校验
+	Routes       []string      // 允许签名访问的路径，以 * 结尾表示前缀匹配；其他路径携带签名参数时拒绝
+	MaxTTL       time.Duration // 签发的最长有效期，默认 7 天
+	MintPath     string        // 签发接口路径，为空不注册；接口本身需由认证 / 授权中间件保护
+}
+
+// SignedURLOptions 签发选项
+type SignedURLOptions struct {
+	TTL      time.Duration // 有效期，默认 15 分钟，不超过 MaxTTL
+	Method   string        // 允许的 HTTP 方法，默认 GET
+	ClientIP string        // 绑定的客户端 IP，为空不绑定
+}
+
+// URLSigner 签名 URL 签发与校验
+type URLSigner struct {
+	config SignedURLConfig
+	routes *RouteTable
+}
+
+// signedURLKey 请求上下文中签名 URL 校验通过的标记
+type signedURLKey struct{}
+
+// currentURLSigner 供 SignURL 使用的当前签发器
+var currentURLSigner atomic.Pointer[URLSigner]
+
+// NewURLSigner 校验配置并创建签名 URL 签发器
+func NewURLSigner(cfg SignedURLConfig) (*URLSigner, error) {
+	if len(cfg.Key) < DefaultSignedURLMinKey {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "signed url key must be at least %d bytes", DefaultSignedURLMinKey)
+	}
+	if len(cfg.Routes) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "signed url requires at least one route")
+	}
+	if cfg.MaxTTL < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "signed url max ttl must not be negative")
+	}
+	if cfg.MaxTTL == 0 {
+		cfg.MaxTTL = DefaultSignedURLMaxTTL
+	}
+
+	patterns := make([]RoutePattern, 0, len(cfg.Routes))
+	for _, path := range cfg.Routes {
+		p := RoutePattern{Kind: RouteMatchExact, Pattern: path, Value: true}
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			p.Kind, p.Pattern = RouteMatchPrefix, prefix
+		}
+		patterns = append(patterns, p)
+	}
+	return &URLSigner{config: cfg, routes: NewRouteTable(patterns)}, nil
+}
+
+// SetCurrentURLSigner 设置 SignURL 使用的签发器，nil 清除
+func SetCurrentURLSigner(s *URLSigner) {
+	currentURLSigner.Store(s)
+}
+
+// SignURL 使用当前签发器签发 URL，未配置时返回错误
+func SignURL(rawURL string, opts SignedURLOptions) (string, time.Time, error) {
+	s := currentURLSigner.Load()
+	if s == nil {
+		return "", time.Time{}, errors.NewError(errors.ErrCodeServiceUnavailable, "signed url is not configured")
+	}
+	return s.Sign(rawURL, opts)
+}
+
+// IsSignedURLRequest 请求是否通过签名 URL 校验
+func IsSignedURLRequest(ctx context.Context) bool {
+	ok, _ := ctx.Value(signedURLKey{}).(bool)
+	return ok
+}
+
+// Sign 签发 URL（可为完整 URL 或路径），返回签名后的 URL 与过期时间
+func (s *URLSigner) Sign(rawURL string, opts SignedURLOptions) (string, time.Time, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", time.Time{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid url %q: %v", rawURL, err)
+	}
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if _, ok := s.routes.Match(method, u.Path); !ok {
+		return "", time.Time{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "path %s is not allowed for signed access", u.Path)
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultSignedURLTTL
+	}
+	if ttl > s.config.MaxTTL {
+		return "", time.Time{}, errors.NewErrorf(errors.ErrCodeInvalidParameter, "signed url ttl %s exceeds max %s", ttl, s.config.MaxTTL)
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	query := u.Query()
+	for _, name := range []string{SignedURLParamExpires, SignedURLParamMethod, SignedURLParamIP, SignedURLParamSignature} {
+		query.Del(name)
+	}
+	query.Set(SignedURLParamExpires, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(SignedURLParamMethod, method)
+	if opts.ClientIP != "" {
+		query.Set(SignedURLParamIP, opts.ClientIP)
+	}
+	query.Set(SignedURLParamSignature, signURL(s.config.Key, u.Path, query))
+	u.RawQuery = query.Encode()
+	return u.String(), expires, nil
+}
+
+// signURL 计算签名：路径与排序后的查询参数（不含签名本身）
+func signURL(key []byte, path string, query url.Values) string {
+	values := make(url.Values, len(query))
+	for name, v := range query {
+		if name != SignedURLParamSignature {
+			values[name] = v
+		}
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(values.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验请求中的签名参数，返回错误代码与原因；未携带签名参数时 present 为 false
+func (s *URLSigner) Verify(r *http.Request) (present bool, code, reason string) {
+	query := r.URL.Query()
+	sig := query.Get(SignedURLParamSignature)
+	if sig == "" {
+		return false, "", ""
+	}
+	if _, ok := s.routes.Match(r.Method, r.URL.Path); !ok {
+		return true, constants.SignedURLErrorCodeInvalid, "path is not allowed for signed access"
+	}
+
+	valid := false
+	for _, key := range append([][]byte{s.config.Key}, s.config.PreviousKeys...) {
+		if subtle.ConstantTimeCompare([]byte(signURL(key, r.URL.Path, query)), []byte(sig)) == 1 {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return true, constants.SignedURLErrorCodeInvalid, "signature mismatch"
+	}
+
+	expires, err := strconv.ParseInt(query.Get(SignedURLParamExpires), 10, 64)
+	if err != nil {
+		return true, constants.SignedURLErrorCodeInvalid, "invalid expiry"
+	}
+	if time.Now().Unix() > expires {
+		return true, constants.SignedURLErrorCodeExpired, "signed url expired"
+	}
+	if method := query.Get(SignedURLParamMethod); method != "" && !strings.EqualFold(method, r.Method) {
+		return true, constants.SignedURLErrorCodeInvalid, "method not allowed for this signed url"
+	}
+	if ip := query.Get(SignedURLParamIP); ip != "" && ip != netx.GetClientIP(r) {
+		return true, constants.SignedURLErrorCodeInvalid, "signed url is bound to another client"
+	}
+	return true, "", ""
+}
+
+// Handle 校验签名 URL：通过时标记上下文并放行，校验失败返回 403，未携带签名参数时原样放行
+func (s *URLSigner) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	present, code, reason := s.Verify(r)
+	if !present {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if code != "" {
+		global.LOGGER.WarnContextKV(r.Context(), "签名 URL 校验失败",
+			"path", r.URL.Path, "code", code, "reason", reason, "ip", netx.GetClientIP(r))
+		response.WriteErrorResponseWithCode(w, http.StatusForbidden, code, reason)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedURLKey{}, true)))
+}
+
+// HTTPMiddleware 签名 URL 校验中间件
+func (s *URLSigner) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Handle(w, r, next)
+	})
+}
+
+// SkipIfSignedURL 包装认证类中间件：签名 URL 校验通过的请求跳过该中间件
+func SkipIfSignedURL(mw MiddlewareFunc) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsSignedURLRequest(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SignedURLMintRequest 签发接口请求体
+type SignedURLMintRequest struct {
+	URL      string `json:"url"`
+	TTL      string `json:"ttl,omitempty"` // 如 30m、24h
+	Method   string `json:"method,omitempty"`
+	ClientIP string `json:"client_ip,omitempty"`
+}
+
+// SignedURLMintResponse 签发接口响应
+type SignedURLMintResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MintHandler 签发接口：POST 请求体 SignedURLMintRequest，返回签名后的 URL
+func (s *URLSigner) MintHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set(constants.HeaderAllow, http.MethodPost)
+			response.WriteErrorResponseWithCode(w, http.StatusMethodNotAllowed, constants.SignedURLErrorCodeInvalid, "use POST to mint a signed url")
+			return
+		}
+		var req SignedURLMintRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			response.WriteBadRequestResult(w, "invalid signed url request: "+err.Error())
+			return
+		}
+		opts := SignedURLOptions{Method: req.Method, ClientIP: req.ClientIP}
+		if req.TTL != "" {
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil {
+				response.WriteBadRequestResult(w, "invalid ttl: "+err.Error())
+				return
+			}
+			opts.TTL = ttl
+		}
+		signed, expires, err := s.Sign(req.URL, opts)
+		if err != nil {
+			response.WriteBadRequestResult(w, err.Error())
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, SignedURLMintResponse{URL: signed, ExpiresAt: expires})
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\stage_timing.go
+ * @Description: 中间件分阶段计时 - 编译中间件链时为每个中间件与末端处理器加上计时边界，
+ *               已采样的请求按阶段生成子 span 或 span 事件；总耗时超过阈值时输出各阶段自身耗时日志
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// 阶段追踪方式
+const (
+	StageTraceNone  = ""      // 不写入链路追踪
+	StageTraceSpan  = "span"  // 每个阶段一个子 span，按中间件嵌套
+	StageTraceEvent = "event" // 在所在 span 上记录阶段事件，不增加 span 数量
+)
+
+// StageHandler 末端处理器（业务路由、grpc-gateway 转发）的阶段名
+const StageHandler = "handler"
+
+// stageTracerName 阶段 span 的 instrumentation 名称
+const stageTracerName = "github.com/kamalyes/go-rpc-gateway/middleware"
+
+// 阶段 span / 事件属性
+const (
+	stageAttrName       = "gateway.stage.name"
+	stageAttrDurationMS = "gateway.stage.duration_ms"
+	stageAttrSelfMS     = "gateway.stage.self_ms"
+	stageAttrUpstreamMS = "gateway.upstream_ms"
+)
+
+// StageTimingConfig 中间件分阶段计时配置
+type StageTimingConfig struct {
+	Trace         string        `json:"trace" yaml:"trace" mapstructure:"trace"`                            // span / event，为空不写入链路追踪；只对已采样的请求生效
+	SlowThreshold time.Duration `json:"slow_threshold" yaml:"slow-threshold" mapstructure:"slow-threshold"` // 总耗时达到阈值时输出分阶段耗时日志，0 关闭
+}
+
+// validate 校验配置
+func (c StageTimingConfig) validate() error {
+	switch c.Trace {
+	case StageTraceNone, StageTraceSpan, StageTraceEvent:
+	default:
+		return errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "stage timing: unsupported trace mode %q", c.Trace)
+	}
+	if c.SlowThreshold < 0 {
+		return errors.NewError(errors.ErrCodeInvalidConfiguration, "stage timing: slow threshold must not be negative")
+	}
+	return nil
+}
+
+// enabled 是否需要计时
+func (c *StageTimingConfig) enabled() bool {
+	return c != nil && (c.Trace != StageTraceNone || c.SlowThreshold > 0)
+}
+
+// stageRecorderKey 阶段记录器的上下文键
+type stageRecorderKey struct{}
+
+// stageRecorder 一次请求各阶段的累计耗时；中间件多次调用后续处理器（如重试）时累加
+type stageRecorder struct {
+	mu       sync.Mutex
+	total    []time.Duration
+	entered  []bool
+	upstream time.Duration
+	proxied  bool
+	traceCtx context.Context // 末端处理器的上下文，慢请求日志据此关联 trace_id
+}
+
+// stageTimer 按阶段计时的中间件链
+type stageTimer struct {
+	config StageTimingConfig
+	names  []string
+}
+
+// wrap 为每个中间件及末端处理器加上计时边界，最外层放置记录器并在结束后输出慢请求日志
+func (c StageTimingConfig) wrap(final http.Handler, entries []ChainEntry) http.Handler {
+	t := &stageTimer{config: c, names: make([]string, 0, len(entries)+1)}
+	for _, e := range entries {
+		t.names = append(t.names, e.Name)
+	}
+	t.names = append(t.names, StageHandler)
+
+	handler := t.stage(len(entries), final)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Middleware == nil {
+			continue
+		}
+		handler = t.stage(i, entries[i].Middleware(handler))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &stageRecorder{total: make([]time.Duration, len(t.names)), entered: make([]bool, len(t.names))}
+		start := time.Now()
+		// 未启用指标中间件时也需要上游计时器，末端处理器据此区分转发耗时
+		r = WithUpstreamTimer(r)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), stageRecorderKey{}, rec)))
+		if elapsed := time.Since(start); t.config.SlowThreshold > 0 && elapsed >= t.config.SlowThreshold {
+			t.logSlow(r, rec, elapsed)
+		}
+	})
+}
+
+// stage 计时第 i 个阶段
+func (t *stageTimer) stage(i int, next http.Handler) http.Handler {
+	name := t.names[i]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec, ok := r.Context().Value(stageRecorderKey{}).(*stageRecorder)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		parent := oteltrace.SpanFromContext(r.Context())
+		tracing := t.config.Trace != StageTraceNone && parent.IsRecording()
+		var span oteltrace.Span
+		if tracing && t.config.Trace == StageTraceSpan {
+			var ctx context.Context
+			ctx, span = parent.TracerProvider().Tracer(stageTracerName).Start(r.Context(), "middleware "+name)
+			r = r.WithContext(ctx)
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		elapsed := time.Since(start)
+
+		self, upstream, proxied := rec.finish(r.Context(), i, elapsed)
+		if !tracing {
+			return
+		}
+		attrs := []attribute.KeyValue{
+			attribute.String(stageAttrName, name),
+			attribute.Float64(stageAttrDurationMS, milliseconds(elapsed)),
+			attribute.Float64(stageAttrSelfMS, milliseconds(self)),
+		}
+		if proxied {
+			attrs = append(attrs, attribute.Float64(stageAttrUpstreamMS, milliseconds(upstream)))
+		}
+		if span != nil {
+			span.SetAttributes(attrs...)
+			span.End()
+			return
+		}
+		parent.AddEvent("middleware "+name, oteltrace.WithTimestamp(start), oteltrace.WithAttributes(attrs...))
+	})
+}
+
+// finish 累计第 i 个阶段的耗时，返回本阶段自身耗时（扣除后续阶段）；末端处理器另记录上游耗时
+func (rec *stageRecorder) finish(ctx context.Context, i int, elapsed time.Duration) (self, upstream time.Duration, proxied bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.total[i] += elapsed
+	rec.entered[i] = true
+	self = elapsed
+	if i+1 < len(rec.total) {
+		self = max(rec.total[i]-rec.total[i+1], 0)
+	} else {
+		rec.traceCtx = ctx
+		rec.upstream, rec.proxied = UpstreamElapsed(ctx)
+	}
+	return self, rec.upstream, rec.proxied && i+1 == len(rec.total)
+}
+
+// logSlow 输出慢请求的分阶段耗时：各阶段为扣除后续阶段后的自身耗时，未执行到的阶段不列出
+func (t *stageTimer) logSlow(r *http.Request, rec *stageRecorder, elapsed time.Duration) {
+	if global.LOGGER == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var b strings.Builder
+	for i, name := range t.names {
+		if !rec.entered[i] {
+			continue
+		}
+		self := rec.total[i]
+		if i+1 < len(rec.total) {
+			self = max(self-rec.total[i+1], 0)
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%s", name, self.Round(time.Microsecond))
+	}
+	if rec.proxied {
+		fmt.Fprintf(&b, " (upstream=%s)", rec.upstream.Round(time.Microsecond))
+	}
+
+	ctx := rec.traceCtx
+	if ctx == nil {
+		ctx = r.Context()
+	}
+	global.LOGGER.WarnContext(ctx, "慢请求分阶段耗时: method=%s, path=%s, total=%s, stages: %s",
+		r.Method, r.URL.Path, elapsed.Round(time.Microsecond), b.String())
+}
+
+// milliseconds 以毫秒表示的时长
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,154 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\sticky_session.go
+ * @Description: 会话保持 - 读取亲和 Cookie 交给 sticky_round_robin 负载均衡器把同一客户端固定到同一上游实例，
+ *               首次分配或原实例下线改派时写回 Cookie；也可直接按业务已有的会话 Cookie 做一致性哈希
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// DefaultStickyCookie 网关签发的亲和 Cookie 默认名称
+const DefaultStickyCookie = "GW_AFFINITY"
+
+// StickySessionConfig 会话保持配置，需配合负载均衡策略 sticky_round_robin 使用
+type StickySessionConfig struct {
+	Cookie     string        // 网关签发的亲和 Cookie 名，默认 GW_AFFINITY；值为实例标识（地址的摘要，不暴露内网地址）
+	HashCookie string        // 使用业务已有的会话 Cookie（如 JSESSIONID）按值哈希选择实例，设置后网关不签发 Cookie
+	MaxAge     time.Duration // 亲和 Cookie 有效期，0 为浏览器会话期
+	Path       string        // Cookie 路径，默认 /
+	Domain     string        // Cookie 域
+	Secure     bool          // 仅 HTTPS 发送
+	SameSite   http.SameSite // 默认 Lax
+}
+
+// StickySessions 会话保持中间件
+type StickySessions struct {
+	config StickySessionConfig
+}
+
+// StickySession 单个请求的亲和信息，由负载均衡器在选择实例时读取并回填
+type StickySession struct {
+	key  string
+	hash bool
+
+	mu       sync.Mutex
+	assigned string
+}
+
+// stickySessionKey context 键
+type stickySessionKey struct{}
+
+// NewStickySessions 校验配置并补齐默认值
+func NewStickySessions(cfg StickySessionConfig) (*StickySessions, error) {
+	if cfg.MaxAge < 0 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "sticky session max age must not be negative")
+	}
+	if cfg.Cookie == "" {
+		cfg.Cookie = DefaultStickyCookie
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	return &StickySessions{config: cfg}, nil
+}
+
+// WithStickySession 把亲和信息放入 context
+func WithStickySession(ctx context.Context, session *StickySession) context.Context {
+	return context.WithValue(ctx, stickySessionKey{}, session)
+}
+
+// StickySessionFromContext 读取请求的亲和信息，未启用会话保持时返回 nil
+func StickySessionFromContext(ctx context.Context) *StickySession {
+	session, _ := ctx.Value(stickySessionKey{}).(*StickySession)
+	return session
+}
+
+// Key 亲和键：网关 Cookie 中的实例标识，或 HashCookie 的值；为空表示新客户端
+func (s *StickySession) Key() string {
+	return s.key
+}
+
+// Hash 是否按亲和键哈希选择实例（使用业务 Cookie 时）
+func (s *StickySession) Hash() bool {
+	return s.hash
+}
+
+// Assign 记录负载均衡器实际选中的实例标识，重试时以最后一次为准
+func (s *StickySession) Assign(instance string) {
+	s.mu.Lock()
+	s.assigned = instance
+	s.mu.Unlock()
+}
+
+// Assigned 实际选中的实例标识
+func (s *StickySession) Assigned() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.assigned
+}
+
+// Handle 读取亲和 Cookie 放入 context；选中的实例与 Cookie 不一致时在写出响应头前写回
+func (s *StickySessions) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if s.config.HashCookie != "" {
+		cookie, err := r.Cookie(s.config.HashCookie)
+		if err != nil || cookie.Value == "" {
+			next.ServeHTTP(w, r) // 尚无会话时按普通轮询
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithStickySession(r.Context(), &StickySession{key: cookie.Value, hash: true})))
+		return
+	}
+
+	session := &StickySession{}
+	if cookie, err := r.Cookie(s.config.Cookie); err == nil {
+		session.key = strings.TrimSpace(cookie.Value)
+	}
+	sw := &cacheHeaderWriter{ResponseWriter: w, apply: func(int) {
+		if assigned := session.Assigned(); assigned != "" && assigned != session.key {
+			http.SetCookie(w, s.cookie(assigned))
+		}
+	}}
+	next.ServeHTTP(sw, r.WithContext(WithStickySession(r.Context(), session)))
+}
+
+// HTTPMiddleware 会话保持中间件
+func (s *StickySessions) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Handle(w, r, next)
+	})
+}
+
+// cookie 生成亲和 Cookie
+func (s *StickySessions) cookie(instance string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     s.config.Cookie,
+		Value:    instance,
+		Path:     s.config.Path,
+		Domain:   s.config.Domain,
+		Secure:   s.config.Secure,
+		HttpOnly: true,
+		SameSite: s.config.SameSite,
+	}
+	if s.config.MaxAge > 0 {
+		cookie.MaxAge = int(s.config.MaxAge / time.Second)
+	}
+	return cookie
+}
@@ -0,0 +1,507 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\stream_limit.go
+ * @Description: 流式连接（SSE / WebSocket）限制 - 按用户与客户端 IP 限制同时在线的长连接数，
+ *               空闲超时断开（SSE 以业务事件、WebSocket 以客户端帧含 pong 计活跃），
+ *               关闭排空时向每个连接发送关闭消息（SSE close 事件 / WebSocket 1001 关闭帧）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-toolbox/pkg/netx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 流式连接类型
+const (
+	StreamKindSSE       = "sse"
+	StreamKindWebSocket = "websocket"
+)
+
+// 流式连接被网关关闭的原因
+const (
+	StreamCloseIdle  = "idle"  // 空闲超时
+	StreamCloseDrain = "drain" // 关闭排空
+)
+
+// DefaultStreamCloseMessage 关闭排空时发送给客户端的默认原因
+const DefaultStreamCloseMessage = "server is shutting down"
+
+// streamIdleMessage 空闲超时关闭时发送给客户端的原因
+const streamIdleMessage = "idle timeout"
+
+// streamCloseWriteTimeout 写出关闭消息的超时时间
+const streamCloseWriteTimeout = time.Second
+
+// WebSocket 关闭码（RFC 6455 7.4.1）
+const (
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+)
+
+// errStreamClosed 连接已被网关关闭后处理器继续写入
+var errStreamClosed = stderrors.New("stream connection closed by gateway")
+
+// 流式连接指标
+var (
+	streamConnectionsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_stream_connections",
+		Help: "Number of open SSE / WebSocket connections tracked by the stream limiter",
+	}, []string{"kind"})
+
+	streamRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_stream_rejections_total",
+		Help: "Total number of SSE / WebSocket connections rejected by stream limits",
+	}, []string{"kind", "limit"})
+
+	streamClosedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_stream_closed_total",
+		Help: "Total number of SSE / WebSocket connections closed by the gateway",
+	}, []string{"kind", "reason"})
+)
+
+// StreamLimitConfig 流式连接限制配置，零值字段不限制
+type StreamLimitConfig struct {
+	MaxPerUser int // 单个用户（认证身份的 Subject）同时在线的流式连接数，匿名请求只受 MaxPerIP 限制
+	MaxPerIP   int // 单个客户端 IP 同时在线的流式连接数
+	// Routes 额外视为 SSE 的路由（客户端未携带 Accept: text/event-stream 时），以 * 结尾为前缀匹配；
+	// WebSocket 升级请求与 Accept: text/event-stream 的请求总是识别
+	Routes []string
+	// IdleTimeout 空闲超时：SSE 超过该时长没有业务事件、WebSocket 超过该时长没有收到客户端帧（包括对服务端 ping 的 pong）即关闭
+	IdleTimeout time.Duration
+	// PingInterval SSE 超过该时长没有写出时发送 ": ping" 注释行保持连接，不计入活跃；WebSocket 的 ping 由 WebSocket 服务发送
+	PingInterval time.Duration
+	// CloseMessage 关闭排空时发送给客户端的原因，默认 DefaultStreamCloseMessage
+	CloseMessage string
+}
+
+// streamSettings 生效的配置与编译后的路由
+type streamSettings struct {
+	config StreamLimitConfig
+	routes *RouteTable
+}
+
+// StreamLimiter 流式连接限制器，配置可运行时替换，已建立的连接计数保留
+type StreamLimiter struct {
+	settings atomic.Pointer[streamSettings]
+
+	mu       sync.Mutex
+	perUser  map[string]int
+	perIP    map[string]int
+	conns    map[*streamConn]struct{}
+	draining bool
+}
+
+// streamConn 一个被跟踪的流式连接
+type streamConn struct {
+	limiter *StreamLimiter
+	kind    string
+	user    string
+	ip      string
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu         sync.Mutex
+	closed     bool
+	released   bool
+	hijacked   bool
+	finished   bool // 处理器已返回
+	started    bool // SSE 已写出响应头
+	lastActive time.Time
+	lastWrite  time.Time
+	w          http.ResponseWriter // SSE
+	rc         *http.ResponseController
+	conn       *streamNetConn // WebSocket 劫持后的连接
+}
+
+// NewStreamLimiter 校验配置并创建流式连接限制器
+func NewStreamLimiter(cfg StreamLimitConfig) (*StreamLimiter, error) {
+	l := &StreamLimiter{
+		perUser: make(map[string]int),
+		perIP:   make(map[string]int),
+		conns:   make(map[*streamConn]struct{}),
+	}
+	if err := l.Update(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update 校验并替换配置，新限制只约束之后建立的连接
+func (l *StreamLimiter) Update(cfg StreamLimitConfig) error {
+	if cfg.MaxPerUser < 0 || cfg.MaxPerIP < 0 || cfg.IdleTimeout < 0 || cfg.PingInterval < 0 {
+		return errors.NewError(errors.ErrCodeInvalidParameter, "stream limits must not be negative")
+	}
+	if cfg.CloseMessage == "" {
+		cfg.CloseMessage = DefaultStreamCloseMessage
+	}
+	patterns := make([]RoutePattern, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		patterns = append(patterns, authnPattern(route, nil, true))
+	}
+	l.settings.Store(&streamSettings{config: cfg, routes: NewRouteTable(patterns)})
+	return nil
+}
+
+// Active 当前跟踪的流式连接数
+func (l *StreamLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// streamKind 识别流式请求，普通请求返回空串
+func (s *streamSettings) streamKind(r *http.Request) string {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return StreamKindWebSocket
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return StreamKindSSE
+	}
+	if _, ok := s.routes.Match(r.Method, r.URL.Path); ok {
+		return StreamKindSSE
+	}
+	return ""
+}
+
+// Handle 限制并跟踪流式连接，普通请求直接放行
+func (l *StreamLimiter) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	settings := l.settings.Load()
+	kind := settings.streamKind(r)
+	if kind == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	user := ""
+	if p := contextPrincipal(r.Context()); !p.IsAnonymous() {
+		user = p.Subject
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	now := time.Now()
+	sc := &streamConn{limiter: l, kind: kind, user: user, ip: netx.GetClientIP(r), cancel: cancel,
+		done: make(chan struct{}), lastActive: now, lastWrite: now}
+	if kind == StreamKindSSE {
+		sc.w, sc.rc = w, http.NewResponseController(w)
+	}
+	if limit, value := l.acquire(settings.config, sc); limit != "" {
+		streamRejectionsTotal.WithLabelValues(kind, limit).Inc()
+		if limit == StreamCloseDrain {
+			response.WriteServiceUnavailableResult(w, settings.config.CloseMessage)
+			return
+		}
+		response.WriteTooManyRequestsResult(w, fmt.Sprintf("too many concurrent %s connections per %s (limit %d)", kind, limit, value))
+		return
+	}
+	if settings.config.IdleTimeout > 0 || (kind == StreamKindSSE && settings.config.PingInterval > 0) {
+		go sc.watch(settings.config)
+	}
+
+	var sw http.ResponseWriter = &sseWriter{ResponseWriter: w, sc: sc}
+	if kind == StreamKindWebSocket {
+		sw = &streamHijackWriter{ResponseWriter: w, sc: sc}
+	}
+	defer func() {
+		// 劫持后的 WebSocket 连接可能在处理器返回后继续使用，在连接关闭时释放
+		sc.mu.Lock()
+		hijacked := sc.hijacked
+		sc.finished = true
+		sc.closed = sc.closed || !hijacked
+		sc.mu.Unlock()
+		if !hijacked {
+			sc.release()
+		}
+	}()
+	next.ServeHTTP(sw, r.WithContext(ctx))
+}
+
+// acquire 登记连接，超限时返回超限项（user / ip / drain）及其上限
+func (l *StreamLimiter) acquire(cfg StreamLimitConfig, sc *streamConn) (string, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.draining:
+		return StreamCloseDrain, 0
+	case sc.user != "" && cfg.MaxPerUser > 0 && l.perUser[sc.user] >= cfg.MaxPerUser:
+		return "user", cfg.MaxPerUser
+	case cfg.MaxPerIP > 0 && l.perIP[sc.ip] >= cfg.MaxPerIP:
+		return "ip", cfg.MaxPerIP
+	}
+	if sc.user != "" {
+		l.perUser[sc.user]++
+	}
+	l.perIP[sc.ip]++
+	l.conns[sc] = struct{}{}
+	streamConnectionsGauge.WithLabelValues(sc.kind).Inc()
+	return "", 0
+}
+
+// release 注销连接，重复调用无副作用
+func (sc *streamConn) release() {
+	sc.mu.Lock()
+	if sc.released {
+		sc.mu.Unlock()
+		return
+	}
+	sc.released = true
+	close(sc.done)
+	sc.mu.Unlock()
+
+	l := sc.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, sc)
+	if sc.user != "" {
+		if l.perUser[sc.user]--; l.perUser[sc.user] <= 0 {
+			delete(l.perUser, sc.user)
+		}
+	}
+	if l.perIP[sc.ip]--; l.perIP[sc.ip] <= 0 {
+		delete(l.perIP, sc.ip)
+	}
+	streamConnectionsGauge.WithLabelValues(sc.kind).Dec()
+}
+
+// Drain 拒绝新的流式连接，并向全部已建立的连接发送关闭消息后断开，返回关闭的连接数
+func (l *StreamLimiter) Drain() int {
+	l.mu.Lock()
+	l.draining = true
+	conns := make([]*streamConn, 0, len(l.conns))
+	for sc := range l.conns {
+		conns = append(conns, sc)
+	}
+	l.mu.Unlock()
+
+	message := l.settings.Load().config.CloseMessage
+	closed := 0
+	for _, sc := range conns {
+		if sc.close(StreamCloseDrain, message) {
+			closed++
+		}
+	}
+	return closed
+}
+
+// watch 空闲检查与 SSE 保活，直到连接注销
+func (sc *streamConn) watch(cfg StreamLimitConfig) {
+	interval := cfg.IdleTimeout
+	if sc.kind == StreamKindSSE && cfg.PingInterval > 0 && (interval == 0 || cfg.PingInterval < interval) {
+		interval = cfg.PingInterval
+	}
+	ticker := time.NewTicker(max(interval/2, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.done:
+			return
+		case <-ticker.C:
+		}
+		sc.mu.Lock()
+		idle := cfg.IdleTimeout > 0 && time.Since(sc.lastActive) >= cfg.IdleTimeout
+		if !idle && !sc.closed && sc.started && cfg.PingInterval > 0 && time.Since(sc.lastWrite) >= cfg.PingInterval {
+			if _, err := sc.w.Write([]byte(": ping\n\n")); err == nil {
+				_ = sc.rc.Flush()
+			}
+			sc.lastWrite = time.Now()
+		}
+		sc.mu.Unlock()
+		if idle {
+			sc.close(StreamCloseIdle, streamIdleMessage)
+			return
+		}
+	}
+}
+
+// close 发送关闭消息并断开连接，已关闭时返回 false
+// 先设置写超时，避免处理器阻塞在慢客户端上的写入拖住关闭流程
+func (sc *streamConn) close(reason, message string) bool {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return false
+	}
+	sc.closed = true
+	conn := sc.conn
+	sc.mu.Unlock()
+
+	deadline := time.Now().Add(streamCloseWriteTimeout)
+	switch {
+	case conn != nil:
+		code := wsCloseGoingAway
+		if reason == StreamCloseIdle {
+			code = wsCloseNormal
+		}
+		_ = conn.Conn.SetWriteDeadline(deadline)
+		conn.wmu.Lock()
+		_, _ = conn.Conn.Write(wsCloseFrame(code, message))
+		conn.wmu.Unlock()
+		_ = conn.Close()
+	case sc.kind == StreamKindSSE:
+		_ = sc.rc.SetWriteDeadline(deadline)
+		sc.mu.Lock()
+		if sc.started && !sc.finished {
+			data, _ := json.Marshal(map[string]string{"reason": reason, "message": message})
+			if _, err := fmt.Fprintf(sc.w, "event: close\ndata: %s\n\n", data); err == nil {
+				_ = sc.rc.Flush()
+			}
+		}
+		sc.mu.Unlock()
+	}
+	streamClosedTotal.WithLabelValues(sc.kind, reason).Inc()
+	sc.cancel()
+	return true
+}
+
+// wsCloseFrame 服务端关闭帧（不加掩码），原因截断到控制帧允许的 123 字节
+func wsCloseFrame(code int, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	frame := make([]byte, 4, 4+len(reason))
+	frame[0] = 0x88 // FIN + close
+	frame[1] = byte(2 + len(reason))
+	binary.BigEndian.PutUint16(frame[2:], uint16(code))
+	return append(frame, reason...)
+}
+
+// sseWriter 记录 SSE 活跃时间，与保活、关闭消息的写入串行化
+type sseWriter struct {
+	http.ResponseWriter
+	sc *streamConn
+}
+
+// WriteHeader 写入状态码
+func (w *sseWriter) WriteHeader(code int) {
+	w.sc.mu.Lock()
+	defer w.sc.mu.Unlock()
+	w.sc.started = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 写入业务事件并刷新活跃时间
+func (w *sseWriter) Write(p []byte) (int, error) {
+	w.sc.mu.Lock()
+	defer w.sc.mu.Unlock()
+	if w.sc.closed {
+		return 0, errStreamClosed
+	}
+	w.sc.started = true
+	w.sc.lastActive = time.Now()
+	w.sc.lastWrite = w.sc.lastActive
+	return w.ResponseWriter.Write(p)
+}
+
+// FlushError 刷新已写出的事件
+func (w *sseWriter) FlushError() error {
+	w.sc.mu.Lock()
+	defer w.sc.mu.Unlock()
+	return w.sc.rc.Flush()
+}
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (w *sseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// streamHijackWriter 劫持时包装连接，以客户端帧计活跃并在连接关闭时释放名额
+type streamHijackWriter struct {
+	http.ResponseWriter
+	sc *streamConn
+}
+
+// Hijack 劫持连接；已读入缓冲区的数据由包装连接先行返回
+func (w *streamHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sconn := &streamNetConn{Conn: conn, sc: w.sc}
+	if n := brw.Reader.Buffered(); n > 0 {
+		buffered, _ := brw.Reader.Peek(n)
+		sconn.pending = append([]byte(nil), buffered...)
+	}
+	w.sc.mu.Lock()
+	w.sc.hijacked = true
+	w.sc.conn = sconn
+	w.sc.mu.Unlock()
+	return sconn, bufio.NewReadWriter(bufio.NewReader(sconn), bufio.NewWriter(sconn)), nil
+}
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (w *streamHijackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// streamNetConn 劫持后的 WebSocket 连接
+type streamNetConn struct {
+	net.Conn
+	sc        *streamConn
+	pending   []byte
+	wmu       sync.Mutex // 处理器写入与关闭帧串行化
+	closeOnce sync.Once
+}
+
+// Read 读取客户端数据（含 ping / pong 控制帧）并刷新活跃时间
+func (c *streamNetConn) Read(p []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.sc.mu.Lock()
+		c.sc.lastActive = time.Now()
+		c.sc.mu.Unlock()
+	}
+	return n, err
+}
+
+// Write 写入服务端帧，网关关闭连接后返回错误
+func (c *streamNetConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.sc.mu.Lock()
+	closed := c.sc.closed
+	c.sc.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	return c.Conn.Write(p)
+}
+
+// Close 关闭连接并释放名额
+func (c *streamNetConn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		err = c.Conn.Close()
+		c.sc.mu.Lock()
+		c.sc.closed = true
+		c.sc.mu.Unlock()
+		c.sc.release()
+	})
+	return err
+}
@@ -25,6 +25,8 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	validator "github.com/kamalyes/go-argus"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -43,6 +45,27 @@ func getStructTagValidator() *validator.Validate {
 	return structTagValidator
 }
 
+func init() {
+	// ctx.Bind 解码后默认走 struct tag 校验，与 gRPC 拦截器保持一致
+	response.SetBindValidator(ValidateStruct)
+}
+
+// ValidateStruct 使用共享的 validator 实例校验 v（非结构体直接放行）
+func ValidateStruct(v any) error {
+	if v == nil {
+		return nil
+	}
+	if err := getStructTagValidator().Struct(v); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !toValidationErrors(err, &fieldErrs) {
+			// InvalidValidationError（非结构体等）不视为校验失败
+			return nil
+		}
+		return errors.NewError(errors.ErrCodeInvalidParameter, formatStructTagValidationError(err))
+	}
+	return nil
+}
+
 // StructTagValidatorUnaryInterceptor 基于 struct tag 的 gRPC Unary 校验拦截器
 // 对每个入参 req 做校验
 // 工作方式：对每个入参 req 调用 `validator.Struct(req)`若 pb 消息字段通过
@@ -0,0 +1,540 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upload_scan.go
+ * @Description: 上传文件病毒扫描 - 上传文件落盘前交给 ClamAV（clamd INSTREAM）或 ICAP 服务扫描，
+ *               命中时拒绝或转存隔离桶；扫描结果按内容 SHA-256 缓存，重复上传的相同文件不再重复扫描
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 扫描后端
+const (
+	UploadScanBackendClamAV = "clamav" // clamd TCP，INSTREAM 命令
+	UploadScanBackendICAP   = "icap"   // ICAP RESPMOD（RFC 3507）
+)
+
+// 检出病毒时的处理方式
+const (
+	UploadScanActionReject     = "reject"     // 拒绝保存
+	UploadScanActionQuarantine = "quarantine" // 转存到隔离桶，原目标不保存
+)
+
+// 扫描服务不可用时的处理方式
+const (
+	UploadScanOnErrorReject = "reject" // 拒绝保存（默认）
+	UploadScanOnErrorAllow  = "allow"  // 告警后照常保存
+)
+
+// 扫描默认值
+const (
+	DefaultUploadScanTimeout   = 30 * time.Second
+	DefaultUploadScanCacheTTL  = time.Hour
+	DefaultUploadScanCacheSize = 10000
+	DefaultICAPService         = "avscan"
+	scanChunkSize              = 64 << 10
+)
+
+// UploadScanConfig 上传扫描配置
+type UploadScanConfig struct {
+	Backend          string        // clamav / icap
+	Address          string        // 扫描服务地址 host:port
+	ICAPService      string        // ICAP 服务路径，默认 avscan
+	Timeout          time.Duration // 单次扫描超时（含建连），默认 30s
+	Action           string        // 检出时 reject / quarantine，默认 reject
+	QuarantineBucket string        // quarantine 时转存的桶，对象键与原目标相同
+	OnError          string        // 扫描失败时 reject / allow，默认 reject
+	CacheTTL         time.Duration // 扫描结果缓存时长，默认 1h，负数关闭缓存
+	CacheSize        int           // 缓存条数上限，默认 10000
+}
+
+// UploadScanResult 扫描结果
+type UploadScanResult struct {
+	Infected  bool
+	Signature string // 病毒特征名，未检出时为空
+	SHA256    string // 内容哈希（十六进制）
+	Cached    bool   // 结果来自缓存
+}
+
+// UploadScanner 扫描后端
+type UploadScanner interface {
+	Scan(ctx context.Context, content io.Reader) (*UploadScanResult, error)
+}
+
+// UploadScan 上传扫描器：后端 + 结果缓存 + 检出处理
+type UploadScan struct {
+	config  UploadScanConfig
+	scanner UploadScanner
+
+	mu    sync.Mutex
+	cache map[string]uploadScanEntry
+}
+
+// uploadScanEntry 缓存的扫描结果
+type uploadScanEntry struct {
+	infected  bool
+	signature string
+	expiresAt time.Time
+}
+
+// UploadInfectedError 检出病毒，Quarantined 为 true 时文件已转存到 QuarantineBucket
+type UploadInfectedError struct {
+	Filename    string
+	Signature   string
+	Quarantined bool
+	Bucket      string
+	Key         string
+}
+
+// Error 实现 error
+func (e *UploadInfectedError) Error() string {
+	if e.Quarantined {
+		return fmt.Sprintf("file %q is infected with %s, quarantined to %s/%s", e.Filename, e.Signature, e.Bucket, e.Key)
+	}
+	return fmt.Sprintf("file %q is infected with %s", e.Filename, e.Signature)
+}
+
+// 上传扫描指标（注册到默认 Registry）
+var (
+	uploadScansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upload_scans_total",
+		Help: "Total number of uploaded file scans by backend, result and cache hit",
+	}, []string{"backend", "result", "cached"})
+
+	uploadScanDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upload_scan_duration_seconds",
+		Help:    "Latency of uploaded file scans against the scanning backend",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"backend"})
+)
+
+// uploadScan 当前生效的上传扫描器
+var uploadScan atomic.Pointer[UploadScan]
+
+// NewUploadScan 校验配置并创建扫描器
+func NewUploadScan(cfg UploadScanConfig) (*UploadScan, error) {
+	if cfg.Address == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "upload scan address is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultUploadScanTimeout
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultUploadScanCacheTTL
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = DefaultUploadScanCacheSize
+	}
+	switch cfg.Action {
+	case "":
+		cfg.Action = UploadScanActionReject
+	case UploadScanActionReject:
+	case UploadScanActionQuarantine:
+		if cfg.QuarantineBucket == "" {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "upload scan quarantine action requires quarantine bucket")
+		}
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unknown upload scan action %q", cfg.Action)
+	}
+	switch cfg.OnError {
+	case "":
+		cfg.OnError = UploadScanOnErrorReject
+	case UploadScanOnErrorReject, UploadScanOnErrorAllow:
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unknown upload scan on-error %q", cfg.OnError)
+	}
+
+	s := &UploadScan{config: cfg, cache: make(map[string]uploadScanEntry)}
+	switch cfg.Backend {
+	case UploadScanBackendClamAV:
+		s.scanner = &clamAVScanner{address: cfg.Address, timeout: cfg.Timeout}
+	case UploadScanBackendICAP:
+		service := cfg.ICAPService
+		if service == "" {
+			service = DefaultICAPService
+		}
+		s.scanner = &icapScanner{address: cfg.Address, service: strings.TrimPrefix(service, "/"), timeout: cfg.Timeout}
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unknown upload scan backend %q", cfg.Backend)
+	}
+	return s, nil
+}
+
+// SetUploadScan 设置上传扫描，nil 关闭；对之后保存的文件立即生效
+func SetUploadScan(cfg *UploadScanConfig) error {
+	if cfg == nil {
+		uploadScan.Store(nil)
+		return nil
+	}
+	s, err := NewUploadScan(*cfg)
+	if err != nil {
+		return err
+	}
+	uploadScan.Store(s)
+	return nil
+}
+
+// CurrentUploadScan 当前生效的上传扫描器，未配置时返回 nil
+func CurrentUploadScan() *UploadScan {
+	return uploadScan.Load()
+}
+
+// Scan 扫描内容，open 每次调用都返回从头读取的内容：先计算哈希查缓存，未命中再交给后端
+func (s *UploadScan) Scan(ctx context.Context, open func() (io.ReadCloser, error)) (*UploadScanResult, error) {
+	sum, err := hashContent(open)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := s.cached(sum); ok {
+		uploadScansTotal.WithLabelValues(s.config.Backend, scanResultLabel(result), "true").Inc()
+		return result, nil
+	}
+
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	start := time.Now()
+	result, err := s.scanner.Scan(ctx, content)
+	uploadScanDuration.WithLabelValues(s.config.Backend).Observe(time.Since(start).Seconds())
+	if err != nil {
+		uploadScansTotal.WithLabelValues(s.config.Backend, "error", "false").Inc()
+		return nil, err
+	}
+	result.SHA256 = sum
+	uploadScansTotal.WithLabelValues(s.config.Backend, scanResultLabel(result), "false").Inc()
+	s.store(result)
+	return result, nil
+}
+
+// cached 查询未过期的缓存结果
+func (s *UploadScan) cached(sum string) (*UploadScanResult, bool) {
+	if s.config.CacheTTL < 0 {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cache[sum]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return &UploadScanResult{Infected: entry.infected, Signature: entry.signature, SHA256: sum, Cached: true}, true
+}
+
+// store 写入缓存，满时先清理过期条目，仍不足则清空一半
+func (s *UploadScan) store(result *UploadScanResult) {
+	if s.config.CacheTTL < 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= s.config.CacheSize {
+		now := time.Now()
+		for k, e := range s.cache {
+			if now.After(e.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		for k := range s.cache {
+			if len(s.cache) < s.config.CacheSize/2 {
+				break
+			}
+			delete(s.cache, k)
+		}
+	}
+	s.cache[result.SHA256] = uploadScanEntry{
+		infected:  result.Infected,
+		signature: result.Signature,
+		expiresAt: time.Now().Add(s.config.CacheTTL),
+	}
+}
+
+// beforeSave 保存前扫描：未检出返回 nil；检出时按 Action 拒绝或转存隔离桶并返回 *UploadInfectedError；
+// 扫描失败时 OnError 为 allow 则告警放行
+func (s *UploadScan) beforeSave(ctx context.Context, client *minio.Client, f *UploadedFile, key string) error {
+	result, err := s.Scan(ctx, func() (io.ReadCloser, error) { return f.Open() })
+	if err != nil {
+		if s.config.OnError == UploadScanOnErrorAllow {
+			global.LOGGER.WarnKV("⚠️ 上传文件扫描失败，按配置放行", "file", f.Filename, "backend", s.config.Backend, "error", err)
+			return nil
+		}
+		return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "scan file %q: %v", f.Filename, err)
+	}
+	if !result.Infected {
+		return nil
+	}
+
+	infected := &UploadInfectedError{Filename: f.Filename, Signature: result.Signature}
+	global.LOGGER.WarnKV("上传文件检出病毒", "file", f.Filename, "signature", result.Signature, "sha256", result.SHA256, "action", s.config.Action)
+	if s.config.Action != UploadScanActionQuarantine {
+		return infected
+	}
+
+	file, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := client.PutObject(ctx, s.config.QuarantineBucket, key, file, f.Size, minio.PutObjectOptions{
+		ContentType:  f.DeclaredType,
+		UserMetadata: map[string]string{"virus-signature": result.Signature, "sha256": result.SHA256},
+	}); err != nil {
+		return fmt.Errorf("quarantine infected file %q: %w", f.Filename, err)
+	}
+	infected.Quarantined, infected.Bucket, infected.Key = true, s.config.QuarantineBucket, key
+	return infected
+}
+
+// IsUploadInfected 判断错误是否为检出病毒
+func IsUploadInfected(err error) bool {
+	var infected *UploadInfectedError
+	return stderrors.As(err, &infected)
+}
+
+// hashContent 计算内容 SHA-256
+func hashContent(open func() (io.ReadCloser, error)) (string, error) {
+	content, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scanResultLabel 指标 result 标签
+func scanResultLabel(result *UploadScanResult) string {
+	if result.Infected {
+		return "infected"
+	}
+	return "clean"
+}
+
+// dialScanner 建立到扫描服务的连接，整个会话受 ctx 截止时间约束
+func dialScanner(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// clamAVScanner clamd INSTREAM 扫描
+type clamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// Scan 以 4 字节大端长度前缀分块发送内容，长度 0 表示结束；响应为 "stream: OK" 或 "stream: <名称> FOUND"
+func (c *clamAVScanner) Scan(ctx context.Context, content io.Reader) (*UploadScanResult, error) {
+	conn, err := dialScanner(ctx, c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, scanChunkSize)
+	var size [4]byte
+	for {
+		n, err := content.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := w.Write(size[:]); werr != nil {
+				return nil, werr
+			}
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				return nil, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	status := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case status == "OK":
+		return &UploadScanResult{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return &UploadScanResult{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// icapScanner ICAP RESPMOD 扫描
+type icapScanner struct {
+	address string
+	service string
+	timeout time.Duration
+}
+
+// icapInfectionHeaders ICAP 服务报告检出结果使用的响应头
+var icapInfectionHeaders = []string{"X-Infection-Found", "X-Virus-Id", "X-Violations-Found"}
+
+// Scan 把内容封装为 HTTP 响应体以 chunked 编码发送；204 表示未修改（干净），200 由 icapModifiedResult 判定
+func (c *icapScanner) Scan(ctx context.Context, content io.Reader) (*UploadScanResult, error) {
+	conn, err := dialScanner(ctx, c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resHdr := "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n\r\n"
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "RESPMOD icap://%s/%s ICAP/1.0\r\n", c.address, c.service)
+	fmt.Fprintf(w, "Host: %s\r\nAllow: 204\r\nEncapsulated: res-hdr=0, res-body=%d\r\n\r\n", c.address, len(resHdr))
+	w.WriteString(resHdr)
+	chunk := make([]byte, scanChunkSize)
+	for {
+		n, err := content.Read(chunk)
+		if n > 0 {
+			fmt.Fprintf(w, "%x\r\n", n)
+			w.Write(chunk[:n])
+			w.WriteString("\r\n")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	statusLine, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "ICAP/") {
+		return nil, fmt.Errorf("icap: malformed status line %q", statusLine)
+	}
+	switch fields[1] {
+	case "204":
+		return &UploadScanResult{}, nil
+	case "200":
+		return icapModifiedResult(tp, header, statusLine)
+	default:
+		return nil, fmt.Errorf("icap: %s", statusLine)
+	}
+}
+
+// icapModifiedResult 判定 200（内容被修改）：ICAP 头或封装的 HTTP 响应头带检出头，或封装的 HTTP 状态码非 2xx 时视为检出；
+// 其余 200 无法确认内容干净，返回错误按 OnError 处理
+func icapModifiedResult(tp *textproto.Reader, header textproto.MIMEHeader, statusLine string) (*UploadScanResult, error) {
+	if result := icapInfection(header); result != nil {
+		return result, nil
+	}
+	if !strings.Contains(header.Get("Encapsulated"), "res-hdr") {
+		return nil, fmt.Errorf("icap: %s without infection header or encapsulated response", statusLine)
+	}
+	httpStatus, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	httpHeader, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if result := icapInfection(httpHeader); result != nil {
+		return result, nil
+	}
+	fields := strings.Fields(httpStatus)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		return nil, fmt.Errorf("icap: malformed encapsulated status line %q", httpStatus)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("icap: malformed encapsulated status line %q", httpStatus)
+	}
+	if code < 200 || code > 299 {
+		return &UploadScanResult{Infected: true, Signature: strings.Join(fields[1:], " ")}, nil
+	}
+	return nil, fmt.Errorf("icap: %s with encapsulated %s and no infection header", statusLine, httpStatus)
+}
+
+// icapInfection 按检出头构造检出结果，没有检出头时返回 nil
+func icapInfection(header textproto.MIMEHeader) *UploadScanResult {
+	for _, name := range icapInfectionHeaders {
+		if v := header.Get(name); v != "" {
+			return &UploadScanResult{Infected: true, Signature: icapSignature(v)}
+		}
+	}
+	return nil
+}
+
+// icapSignature 从检出头提取特征名：X-Infection-Found 形如 "Type=0; Resolution=2; Threat=Eicar-Test;"
+func icapSignature(value string) string {
+	for _, part := range strings.Split(value, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.EqualFold(k, "Threat") {
+			return v
+		}
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,272 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upstream_errors.go
+ * @Description: 上游失败分类 - 拒绝连接、DNS、TLS、超时、上游 5xx 与响应无法解析分别映射为独立的网关错误码，
+ *               以标准 Result 结构返回并按分类计入 gateway_upstream_errors_total；
+ *               声明式路由在 ReverseProxy 错误处理中分类，gRPC 上游由客户端拦截器分类后交给 UpstreamHTTPErrorHandler
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	stderrors "errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UpstreamErrorClass 上游失败分类（指标标签）
+type UpstreamErrorClass string
+
+// 上游失败分类
+const (
+	UpstreamErrorConnectRefused UpstreamErrorClass = "connect_refused"    // 上游拒绝连接
+	UpstreamErrorDNS            UpstreamErrorClass = "dns"                // 域名解析失败
+	UpstreamErrorTLS            UpstreamErrorClass = "tls"                // TLS 握手或证书校验失败
+	UpstreamErrorTimeout        UpstreamErrorClass = "timeout"            // 建连或等待响应超时
+	UpstreamErrorServer         UpstreamErrorClass = "server_error"       // 上游自身返回的 5xx / Internal / Unknown / Unavailable
+	UpstreamErrorMalformed      UpstreamErrorClass = "malformed_response" // 响应不是合法的 HTTP / gRPC 响应
+	UpstreamErrorConnection     UpstreamErrorClass = "connection"         // 连接被重置、提前关闭等其他连接失败
+)
+
+// upstreamErrorDomain gRPC 状态 ErrorInfo 的 Domain，标记由网关检测到的上游失败
+const upstreamErrorDomain = "gateway.upstream"
+
+// upstreamErrorMapping 分类对应的网关错误码与 Result.error 前缀
+var upstreamErrorMapping = map[UpstreamErrorClass]struct {
+	code   errors.ErrorCode
+	reason string
+}{
+	UpstreamErrorConnectRefused: {errors.ErrCodeUpstreamConnectRefused, constants.UpstreamErrorCodeConnectRefused},
+	UpstreamErrorDNS:            {errors.ErrCodeUpstreamDNSFailed, constants.UpstreamErrorCodeDNS},
+	UpstreamErrorTLS:            {errors.ErrCodeUpstreamTLSFailed, constants.UpstreamErrorCodeTLS},
+	UpstreamErrorTimeout:        {errors.ErrCodeUpstreamTimeout, constants.UpstreamErrorCodeTimeout},
+	UpstreamErrorServer:         {errors.ErrCodeUpstreamServerError, constants.UpstreamErrorCodeServer},
+	UpstreamErrorMalformed:      {errors.ErrCodeUpstreamMalformedResponse, constants.UpstreamErrorCodeMalformed},
+	UpstreamErrorConnection:     {errors.ErrCodeUpstreamConnectionFailed, constants.DeclarativeRouteErrorCodeUpstream},
+}
+
+// upstreamErrors 上游失败数（注册到默认 Registry）
+var upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_upstream_errors_total",
+	Help: "Total number of upstream failures by upstream and class",
+}, []string{"upstream", "class"})
+
+// AppError 分类对应的网关错误，未知分类按连接失败处理
+func (c UpstreamErrorClass) AppError() *errors.AppError {
+	if m, ok := upstreamErrorMapping[c]; ok {
+		return errors.NewError(m.code, "")
+	}
+	return errors.NewError(errors.ErrCodeUpstreamConnectionFailed, "")
+}
+
+// Reason 分类的机器可读错误标识，如 UPSTREAM_CONNECT_REFUSED
+func (c UpstreamErrorClass) Reason() string {
+	if m, ok := upstreamErrorMapping[c]; ok {
+		return m.reason
+	}
+	return constants.DeclarativeRouteErrorCodeUpstream
+}
+
+// WriteUpstreamError 以 Result 结构写出上游失败，error 为 "<错误标识>: <错误消息>"
+func WriteUpstreamError(w http.ResponseWriter, class UpstreamErrorClass) {
+	appErr := class.AppError()
+	response.WriteErrorResult(w, appErr.GetHTTPStatus(), class.Reason()+": "+appErr.GetMessage(), appErr.GetStatusCode())
+}
+
+// recordUpstreamError 计入上游失败指标
+func recordUpstreamError(upstream string, class UpstreamErrorClass) {
+	upstreamErrors.WithLabelValues(upstream, string(class)).Inc()
+}
+
+// ClassifyUpstreamError 对 HTTP 上游的转发错误（Transport.RoundTrip 返回的错误）分类
+func ClassifyUpstreamError(err error) UpstreamErrorClass {
+	var (
+		dnsErr    *net.DNSError
+		certErr   *tls.CertificateVerificationError
+		recordErr tls.RecordHeaderError
+		alertErr  tls.AlertError
+		netErr    net.Error
+	)
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return UpstreamErrorTimeout
+	case stderrors.As(err, &dnsErr):
+		return UpstreamErrorDNS
+	// Windows 的 WSAECONNREFUSED 不等于 syscall.ECONNREFUSED，按消息兜底
+	case stderrors.Is(err, syscall.ECONNREFUSED), strings.Contains(err.Error(), "refused"):
+		return UpstreamErrorConnectRefused
+	case stderrors.As(err, &certErr), stderrors.As(err, &recordErr), stderrors.As(err, &alertErr):
+		return UpstreamErrorTLS
+	case stderrors.As(err, &netErr) && netErr.Timeout():
+		return UpstreamErrorTimeout
+	case strings.Contains(err.Error(), "malformed"):
+		return UpstreamErrorMalformed
+	}
+	return UpstreamErrorConnection
+}
+
+// upstreamStatusPatterns gRPC 客户端生成的失败消息片段，按顺序匹配
+var upstreamStatusPatterns = []struct {
+	fragment string
+	class    UpstreamErrorClass
+}{
+	{"refused", UpstreamErrorConnectRefused},
+	{"no such host", UpstreamErrorDNS},
+	{"resolver", UpstreamErrorDNS},
+	{"authentication handshake failed", UpstreamErrorTLS},
+	{"x509:", UpstreamErrorTLS},
+	{"tls:", UpstreamErrorTLS},
+	{"unexpected HTTP status code", UpstreamErrorMalformed},
+	{"unexpected content-type", UpstreamErrorMalformed},
+	{"server preface", UpstreamErrorMalformed}, // 对端不是 HTTP/2（如 HTTP/1.1 服务）
+	{"malformed", UpstreamErrorMalformed},
+}
+
+// ClassifyUpstreamStatus 对 gRPC 上游调用返回的状态分类，业务状态（NotFound、InvalidArgument 等）返回空
+func ClassifyUpstreamStatus(st *status.Status) UpstreamErrorClass {
+	msg := st.Message()
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return UpstreamErrorTimeout
+	case codes.Unavailable:
+		// 只有 gRPC 客户端生成的消息（连接 / resolver / 非 gRPC 响应）按片段细分，上游自己返回的 Unavailable 视为上游错误
+		if !strings.Contains(msg, "connection error") && !strings.Contains(msg, "transport") &&
+			!strings.Contains(msg, "resolver") && !strings.Contains(msg, "unexpected HTTP status code") {
+			return UpstreamErrorServer
+		}
+		for _, p := range upstreamStatusPatterns {
+			if strings.Contains(msg, p.fragment) {
+				return p.class
+			}
+		}
+		return UpstreamErrorConnection
+	case codes.Internal, codes.Unknown:
+		if strings.Contains(msg, "failed to unmarshal the received message") ||
+			strings.Contains(msg, "unexpected HTTP status code") || strings.Contains(msg, "unexpected content-type") {
+			return UpstreamErrorMalformed
+		}
+		return UpstreamErrorServer
+	case codes.DataLoss:
+		return UpstreamErrorServer
+	}
+	return ""
+}
+
+// classifyUpstreamCall 分类 gRPC 调用错误并计数；网关侧检测到的失败附加 ErrorInfo，上游自身返回的错误原样返回
+func classifyUpstreamCall(ctx context.Context, upstream string, err error) error {
+	if err == nil || stderrors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	class := ClassifyUpstreamStatus(st)
+	if class == "" {
+		return err
+	}
+	recordUpstreamError(upstream, class)
+	if class == UpstreamErrorServer {
+		return err
+	}
+	tagged, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   class.Reason(),
+		Domain:   upstreamErrorDomain,
+		Metadata: map[string]string{"upstream": upstream},
+	})
+	if detailErr != nil {
+		return err
+	}
+	return tagged.Err()
+}
+
+// UpstreamErrorClassOf 读取 gRPC 错误上由网关附加的上游失败分类
+func UpstreamErrorClassOf(err error) (UpstreamErrorClass, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return "", false
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != upstreamErrorDomain {
+			continue
+		}
+		for class, m := range upstreamErrorMapping {
+			if m.reason == info.GetReason() {
+				return class, true
+			}
+		}
+	}
+	return "", false
+}
+
+// UnaryClientUpstreamErrorInterceptor gRPC 客户端一元调用上游失败分类拦截器，upstream 为指标中的上游名
+func UnaryClientUpstreamErrorInterceptor(upstream string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return classifyUpstreamCall(ctx, upstream, invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientUpstreamErrorInterceptor gRPC 客户端流式调用上游失败分类拦截器：分类建流错误与接收错误
+func StreamClientUpstreamErrorInterceptor(upstream string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, classifyUpstreamCall(ctx, upstream, err)
+		}
+		return &upstreamErrorClientStream{ClientStream: stream, ctx: ctx, upstream: upstream}, nil
+	}
+}
+
+// upstreamErrorClientStream 分类接收错误的 ClientStream（stream.Context 在流结束后即被取消，使用调用方的 ctx 判断是否主动取消）
+type upstreamErrorClientStream struct {
+	grpc.ClientStream
+	ctx      context.Context
+	upstream string
+}
+
+func (s *upstreamErrorClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return classifyUpstreamCall(s.ctx, s.upstream, err)
+}
+
+// UpstreamErrorDialOptions 上游连接的失败分类 dial options
+func UpstreamErrorDialOptions(upstream string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientUpstreamErrorInterceptor(upstream)),
+		grpc.WithChainStreamInterceptor(StreamClientUpstreamErrorInterceptor(upstream)),
+	}
+}
+
+// UpstreamHTTPErrorHandler grpc-gateway 错误处理器：带上游失败分类的错误写出 Result，其余交给默认处理器
+func UpstreamHTTPErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if class, ok := UpstreamErrorClassOf(err); ok {
+		WriteUpstreamError(w, class)
+		return
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
@@ -0,0 +1,250 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upstream_rewrite.go
+ * @Description: 上游 URL 改写 - 声明式路由转发前按上游配置去掉 / 追加路径前缀、按正则替换路径并增删查询参数，
+ *               附带试算接口，不发出请求即可查看某个 URL 最终会被转发到哪里
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// DefaultRewriteTestPath 改写试算接口默认路径
+const DefaultRewriteTestPath = "/admin/rewrite-test"
+
+// UpstreamRewrite 上游改写规则，依次执行：StripPrefix → Rules（首个匹配的规则生效）→ AddPrefix → Query
+type UpstreamRewrite struct {
+	StripPrefix string            `json:"strip_prefix" yaml:"strip-prefix" mapstructure:"strip-prefix"`
+	Rules       []PathRewriteRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+	AddPrefix   string            `json:"add_prefix" yaml:"add-prefix" mapstructure:"add-prefix"`
+	Query       QueryRewrite      `json:"query" yaml:"query" mapstructure:"query"`
+}
+
+// PathRewriteRule 正则路径替换，Replace 中可用 $1、${name} 引用分组
+type PathRewriteRule struct {
+	Regex   string `json:"regex" yaml:"regex" mapstructure:"regex"`
+	Replace string `json:"replace" yaml:"replace" mapstructure:"replace"`
+}
+
+// QueryRewrite 查询参数改写，依次删除、设置（覆盖）、追加；参数名区分大小写，因此用列表而不是映射声明
+type QueryRewrite struct {
+	Remove []string     `json:"remove" yaml:"remove" mapstructure:"remove"`
+	Set    []QueryParam `json:"set" yaml:"set" mapstructure:"set"`
+	Add    []QueryParam `json:"add" yaml:"add" mapstructure:"add"`
+}
+
+// QueryParam 查询参数
+type QueryParam struct {
+	Name  string `json:"name" yaml:"name" mapstructure:"name"`
+	Value string `json:"value" yaml:"value" mapstructure:"value"`
+}
+
+// RewriteTestRequest 改写试算接口请求体
+type RewriteTestRequest struct {
+	Method   string `json:"method"`             // 默认 GET
+	URL      string `json:"url"`                // 路径与查询字符串，如 /api/users/1?debug=1
+	Upstream string `json:"upstream,omitempty"` // 指定时跳过路由匹配，直接按该上游的规则改写
+}
+
+// RewriteTestResult 改写试算结果
+type RewriteTestResult struct {
+	Method   string   `json:"method"`
+	URL      string   `json:"url"`
+	Route    string   `json:"route,omitempty"`    // 命中的路由，为空表示未命中（指定 Upstream 时同样为空）
+	Result   string   `json:"result"`             // proxied / static / redirected / unmatched
+	Upstream string   `json:"upstream,omitempty"` // 转发的上游
+	Target   string   `json:"target,omitempty"`   // 最终转发地址
+	Steps    []string `json:"steps"`              // 依次生效的改写
+}
+
+// 试算结果中未命中路由
+const rewriteTestUnmatched = "unmatched"
+
+// upstreamRewriter 编译后的上游改写规则
+type upstreamRewriter struct {
+	stripPrefix string
+	addPrefix   string
+	rules       []pathRewriteRule
+	query       QueryRewrite
+}
+
+// pathRewriteRule 编译后的正则替换
+type pathRewriteRule struct {
+	regex   *regexp.Regexp
+	replace string
+}
+
+// newUpstreamRewriter 校验前缀、编译正则
+func newUpstreamRewriter(cfg UpstreamRewrite) (*upstreamRewriter, error) {
+	if cfg.StripPrefix != "" && !strings.HasPrefix(cfg.StripPrefix, "/") {
+		return nil, fmt.Errorf("strip-prefix %q must start with /", cfg.StripPrefix)
+	}
+	if cfg.AddPrefix != "" && !strings.HasPrefix(cfg.AddPrefix, "/") {
+		return nil, fmt.Errorf("add-prefix %q must start with /", cfg.AddPrefix)
+	}
+	rw := &upstreamRewriter{stripPrefix: cfg.StripPrefix, addPrefix: cfg.AddPrefix, query: cfg.Query}
+	for i, rule := range cfg.Rules {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d regex: %v", i, err)
+		}
+		rw.rules = append(rw.rules, pathRewriteRule{regex: regex, replace: rule.Replace})
+	}
+	for _, param := range append(append([]QueryParam{}, cfg.Query.Set...), cfg.Query.Add...) {
+		if param.Name == "" {
+			return nil, fmt.Errorf("query parameter without name")
+		}
+	}
+	return rw, nil
+}
+
+// apply 改写 u 的路径与查询串；explain 为 true 时返回每一步的说明
+func (rw *upstreamRewriter) apply(u *url.URL, explain bool) []string {
+	var steps []string
+	path := u.Path
+	if rw.stripPrefix != "" && strings.HasPrefix(path, rw.stripPrefix) {
+		path = strings.TrimPrefix(path, rw.stripPrefix)
+		if explain {
+			steps = append(steps, fmt.Sprintf("strip-prefix %s: %s", rw.stripPrefix, path))
+		}
+	}
+	for _, rule := range rw.rules {
+		match := rule.regex.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		path = string(rule.regex.ExpandString(nil, rule.replace, path, match))
+		if explain {
+			steps = append(steps, fmt.Sprintf("regex %s: %s", rule.regex, path))
+		}
+		break
+	}
+	if rw.addPrefix != "" {
+		path = rw.addPrefix + path
+		if explain {
+			steps = append(steps, fmt.Sprintf("add-prefix %s: %s", rw.addPrefix, path))
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if path != u.Path {
+		u.Path, u.RawPath = path, ""
+	}
+
+	q := rw.query
+	if len(q.Remove) == 0 && len(q.Set) == 0 && len(q.Add) == 0 {
+		return steps
+	}
+	values := u.Query()
+	for _, name := range q.Remove {
+		values.Del(name)
+	}
+	for _, param := range q.Set {
+		values.Set(param.Name, param.Value)
+	}
+	for _, param := range q.Add {
+		values.Add(param.Name, param.Value)
+	}
+	u.RawQuery = values.Encode()
+	if explain {
+		steps = append(steps, "query: "+u.RawQuery)
+	}
+	return steps
+}
+
+// RewriteTest 试算请求经路由改写与上游改写后的转发地址，不发出请求
+func (d *DeclarativeRouter) RewriteTest(method string, target *url.URL, upstreamName string) (RewriteTestResult, error) {
+	result := RewriteTestResult{Method: method, URL: target.String(), Steps: []string{}}
+	out := &http.Request{Method: method, URL: cloneURL(target), Header: http.Header{}, Host: target.Host}
+
+	var upstream *routeUpstream
+	if upstreamName != "" {
+		var ok bool
+		if upstream, ok = d.upstreams[upstreamName]; !ok {
+			return result, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unknown upstream %q", upstreamName)
+		}
+	} else {
+		value, ok := d.routes.Match(method, target.Path)
+		route, _ := value.(*declarativeRoute)
+		if !ok || (route.match != nil && !route.match(target.Path)) {
+			result.Result = rewriteTestUnmatched
+			return result, nil
+		}
+		result.Route, result.Result = route.name, route.result
+		if route.upstream == nil {
+			return result, nil
+		}
+		upstream = route.upstream
+		if t := route.transform; t != nil && (t.StripPrefix != "" || t.AddPrefix != "") {
+			t.rewritePath(out.URL)
+			result.Steps = append(result.Steps, "route transform: "+out.URL.Path)
+		}
+	}
+
+	result.Result, result.Upstream = declarativeResultProxied, upstream.Name
+	if upstream.rewrite != nil {
+		result.Steps = append(result.Steps, upstream.rewrite.apply(out.URL, true)...)
+	}
+	pr := &httputil.ProxyRequest{In: out, Out: out}
+	pr.SetURL(upstream.target)
+	result.Target = out.URL.String()
+	return result, nil
+}
+
+// RewriteTestHandler 改写试算接口：POST 请求体 RewriteTestRequest，返回 RewriteTestResult
+func (d *DeclarativeRouter) RewriteTestHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set(constants.HeaderAllow, http.MethodPost)
+			response.WriteAppErrorf(w, errors.ErrCodeMethodNotAllowed, "use POST to test a rewrite")
+			return
+		}
+		var req RewriteTestRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			response.WriteBadRequestResult(w, "invalid rewrite test request: "+err.Error())
+			return
+		}
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+		target, err := url.ParseRequestURI(req.URL)
+		if err != nil {
+			response.WriteBadRequestResult(w, "invalid url: "+err.Error())
+			return
+		}
+		result, err := d.RewriteTest(strings.ToUpper(req.Method), target, req.Upstream)
+		if err != nil {
+			response.WriteBadRequestResult(w, err.Error())
+			return
+		}
+		response.WriteJSONResponse(w, http.StatusOK, result)
+	}
+}
+
+// cloneURL 复制 URL，避免改写影响调用方
+func cloneURL(u *url.URL) *url.URL {
+	clone := *u
+	if u.User != nil {
+		user := *u.User
+		clone.User = &user
+	}
+	return &clone
+}
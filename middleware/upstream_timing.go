@@ -0,0 +1,172 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upstream_timing.go
+ * @Description: 上游耗时拆分 - 请求上下文中累计等待上游（声明式路由转发、gRPC 后端调用）的时间，
+ *               指标中间件据此把请求耗时拆成上游耗时与网关自身耗时两个直方图，用于判断延迟出在网关还是后端
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// upstreamTimerKey 上游计时器的上下文键
+type upstreamTimerKey struct{}
+
+// upstreamTimer 一次请求等待上游的累计时间；并发的上游调用按重叠区间合并，结果不会超过请求总耗时
+type upstreamTimer struct {
+	mu     sync.Mutex
+	active int       // 进行中的上游调用数
+	since  time.Time // active 从 0 变为 1 的时间
+	total  time.Duration
+	calls  int
+}
+
+// begin 开始一段上游等待
+func (t *upstreamTimer) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == 0 {
+		t.since = time.Now()
+	}
+	t.active++
+	t.calls++
+}
+
+// end 结束一段上游等待
+func (t *upstreamTimer) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == 0 {
+		return
+	}
+	t.active--
+	if t.active == 0 {
+		t.total += time.Since(t.since)
+	}
+}
+
+// elapsed 累计的上游等待时间，以及是否发生过上游调用
+func (t *upstreamTimer) elapsed() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.total
+	if t.active > 0 {
+		d += time.Since(t.since)
+	}
+	return d, t.calls > 0
+}
+
+// WithUpstreamTimer 在请求上下文中放置上游计时器；指标中间件在调用后续处理器前使用，已存在时原样返回
+func WithUpstreamTimer(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(upstreamTimerKey{}).(*upstreamTimer); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), upstreamTimerKey{}, &upstreamTimer{}))
+}
+
+// TrackUpstream 标记一段等待上游的时间，返回的函数在等待结束时调用（自定义代理、HTTP 客户端使用）；
+// 上下文中没有计时器时返回空函数
+func TrackUpstream(ctx context.Context) func() {
+	t, ok := ctx.Value(upstreamTimerKey{}).(*upstreamTimer)
+	if !ok {
+		return func() {}
+	}
+	t.begin()
+	var once sync.Once
+	return func() { once.Do(t.end) }
+}
+
+// UpstreamElapsed 返回请求至今等待上游的累计时间，ok 为 false 表示请求未调用上游
+func UpstreamElapsed(ctx context.Context) (time.Duration, bool) {
+	t, ok := ctx.Value(upstreamTimerKey{}).(*upstreamTimer)
+	if !ok {
+		return 0, false
+	}
+	return t.elapsed()
+}
+
+// timedBody 上游响应体：每次 Read 计入上游等待时间，两次 Read 之间向客户端写出的时间算作网关耗时
+type timedBody struct {
+	io.ReadCloser
+	timer *upstreamTimer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	b.timer.begin()
+	defer b.timer.end()
+	return b.ReadCloser.Read(p)
+}
+
+// trackRoundTrip 计时一次上游 HTTP 往返：发送请求到收到响应头，以及之后读取响应体的时间
+func trackRoundTrip(r *http.Request, roundTrip func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	timer, ok := r.Context().Value(upstreamTimerKey{}).(*upstreamTimer)
+	if !ok {
+		return roundTrip(r)
+	}
+	timer.begin()
+	resp, err := roundTrip(r)
+	timer.end()
+	// 协议升级的响应体需保持 io.ReadWriteCloser，不包装
+	if err == nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body = &timedBody{ReadCloser: resp.Body, timer: timer}
+	}
+	return resp, err
+}
+
+// UnaryClientUpstreamTimingInterceptor gRPC Client 一元调用拦截器：调用耗时计入 HTTP 请求的上游耗时
+func UnaryClientUpstreamTimingInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done := TrackUpstream(ctx)
+		defer done()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientUpstreamTimingInterceptor gRPC Client 流式调用拦截器：建立流、等待响应头与接收消息的时间计入上游耗时
+func StreamClientUpstreamTimingInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		timer, ok := ctx.Value(upstreamTimerKey{}).(*upstreamTimer)
+		if !ok {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		timer.begin()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		timer.end()
+		if err != nil {
+			return nil, err
+		}
+		return &timedClientStream{ClientStream: stream, timer: timer}, nil
+	}
+}
+
+// timedClientStream 计时接收的 ClientStream
+type timedClientStream struct {
+	grpc.ClientStream
+	timer *upstreamTimer
+}
+
+func (s *timedClientStream) Header() (metadata.MD, error) {
+	s.timer.begin()
+	defer s.timer.end()
+	return s.ClientStream.Header()
+}
+
+func (s *timedClientStream) RecvMsg(m interface{}) error {
+	s.timer.begin()
+	defer s.timer.end()
+	return s.ClientStream.RecvMsg(m)
+}
@@ -0,0 +1,79 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upstream_tls.go
+ * @Description: 上游 TLS 客户端配置 - 声明式路由转发到 HTTPS 上游时按上游使用自定义 CA、mTLS 客户端证书、
+ *               SNI 与最低 TLS 版本
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// UpstreamTLS HTTPS 上游的 TLS 客户端配置
+type UpstreamTLS struct {
+	CAFile             string              `json:"ca_file" yaml:"ca-file" mapstructure:"ca-file"`                                        // PEM 格式的 CA 证书包，设置后只信任其中的 CA，默认使用系统根证书
+	CertFile           string              `json:"cert_file" yaml:"cert-file" mapstructure:"cert-file"`                                  // mTLS 客户端证书，需与 KeyFile 同时设置
+	KeyFile            string              `json:"key_file" yaml:"key-file" mapstructure:"key-file"`                                     // mTLS 客户端私钥
+	ServerName         string              `json:"server_name" yaml:"server-name" mapstructure:"server-name"`                            // SNI 与证书校验使用的主机名，默认取上游地址的主机名
+	MinVersion         gwconfig.TLSVersion `json:"min_version" yaml:"min-version" mapstructure:"min-version"`                            // TLS10 / TLS11 / TLS12 / TLS13，默认 TLS12
+	InsecureSkipVerify bool                `json:"insecure_skip_verify" yaml:"insecure-skip-verify" mapstructure:"insecure-skip-verify"` // 跳过证书校验，仅用于排障，启用时每次加载都会告警
+}
+
+// newUpstreamTLSConfig 按配置创建 TLS 客户端配置，证书文件在创建时读取，配置重载时重新读取
+func newUpstreamTLSConfig(upstream string, cfg UpstreamTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	switch cfg.MinVersion {
+	case "":
+	case gwconfig.TLSVersion10, gwconfig.TLSVersion11, gwconfig.TLSVersion12, gwconfig.TLSVersion13:
+		tlsConfig.MinVersion = cfg.MinVersion.ToUint16()
+	default:
+		return nil, fmt.Errorf("unsupported min-version %q", cfg.MinVersion)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca-file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca-file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("cert-file and key-file must be set together")
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.InsecureSkipVerify {
+		global.LOGGER.WarnKV("⚠️ 上游已关闭 TLS 证书校验，连接可被中间人劫持，仅限排障使用",
+			"upstream", upstream,
+			"server_name", cfg.ServerName)
+	}
+	return tlsConfig, nil
+}
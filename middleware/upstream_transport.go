@@ -0,0 +1,121 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\upstream_transport.go
+ * @Description: 上游连接 - 声明式路由的每个上游使用独立的 Transport：连接超时、TCP keepalive、
+ *               双栈 Happy Eyeballs 与空闲连接数可单独调整，并记录建连耗时与连接复用情况，便于排查慢上游
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// UpstreamDial 上游建连与连接池配置，零值沿用 http.DefaultTransport 的设置
+type UpstreamDial struct {
+	ConnectTimeout       time.Duration `json:"connect_timeout" yaml:"connect-timeout" mapstructure:"connect-timeout"`                         // TCP 建连超时，默认 30s
+	KeepAlive            time.Duration `json:"keep_alive" yaml:"keep-alive" mapstructure:"keep-alive"`                                        // TCP keepalive 探测间隔，默认 30s，负数关闭
+	FallbackDelay        time.Duration `json:"fallback_delay" yaml:"fallback-delay" mapstructure:"fallback-delay"`                            // Happy Eyeballs 等待 IPv6 多久后并行尝试 IPv4，默认 300ms
+	DisableHappyEyeballs bool          `json:"disable_happy_eyeballs" yaml:"disable-happy-eyeballs" mapstructure:"disable-happy-eyeballs"`    // 关闭双栈并行建连，按解析顺序依次尝试
+	MaxIdleConnsPerHost  int           `json:"max_idle_conns_per_host" yaml:"max-idle-conns-per-host" mapstructure:"max-idle-conns-per-host"` // 每个主机保留的空闲连接数，默认 2
+	MaxConnsPerHost      int           `json:"max_conns_per_host" yaml:"max-conns-per-host" mapstructure:"max-conns-per-host"`                // 每个主机的连接数上限（含使用中），0 不限制
+	IdleConnTimeout      time.Duration `json:"idle_conn_timeout" yaml:"idle-conn-timeout" mapstructure:"idle-conn-timeout"`                   // 空闲连接保留时长，默认 90s
+}
+
+// 上游连接指标（注册到默认 Registry）
+var (
+	upstreamDialDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_dial_duration_seconds",
+		Help:    "Latency of TCP connection establishment to declarative route upstreams",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"upstream", "result"})
+
+	upstreamConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_connections_total",
+		Help: "Total number of connections obtained for declarative route upstream requests by reuse",
+	}, []string{"upstream", "reused"})
+)
+
+// upstreamTransport 带指标的上游 Transport
+type upstreamTransport struct {
+	*http.Transport
+	name  string
+	trace *httptrace.ClientTrace
+}
+
+// newUpstreamTransport 按建连与 TLS 配置创建上游专用的 Transport
+func newUpstreamTransport(upstream string, dial *UpstreamDial, tlsCfg *UpstreamTLS) (*upstreamTransport, error) {
+	d := UpstreamDial{}
+	if dial != nil {
+		d = *dial
+	}
+	if d.ConnectTimeout < 0 || d.FallbackDelay < 0 || d.IdleConnTimeout < 0 || d.MaxIdleConnsPerHost < 0 || d.MaxConnsPerHost < 0 {
+		return nil, fmt.Errorf("dial settings must not be negative (except keep-alive)")
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, FallbackDelay: d.FallbackDelay}
+	if d.ConnectTimeout > 0 {
+		dialer.Timeout = d.ConnectTimeout
+	}
+	if d.KeepAlive != 0 {
+		dialer.KeepAlive = d.KeepAlive
+	}
+	if d.DisableHappyEyeballs {
+		dialer.FallbackDelay = -1
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, network, addr)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		upstreamDialDuration.WithLabelValues(upstream, result).Observe(time.Since(start).Seconds())
+		return conn, err
+	}
+	if d.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = d.MaxIdleConnsPerHost
+	}
+	transport.MaxConnsPerHost = d.MaxConnsPerHost
+	if d.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = d.IdleConnTimeout
+	}
+	if tlsCfg != nil {
+		tlsConfig, err := newUpstreamTLSConfig(upstream, *tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &upstreamTransport{
+		Transport: transport,
+		name:      upstream,
+		trace: &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			upstreamConnections.WithLabelValues(upstream, strconv.FormatBool(info.Reused)).Inc()
+		}},
+	}, nil
+}
+
+// RoundTrip 占用上游的舱壁槽位，记录本次请求使用的连接是否复用，等待上游的时间计入请求的上游耗时
+func (t *upstreamTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return bulkheadRoundTrip(t.name, r, func(r *http.Request) (*http.Response, error) {
+		return trackRoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), t.trace)), t.Transport.RoundTrip)
+	})
+}
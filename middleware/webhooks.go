@@ -0,0 +1,632 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\middleware\webhooks.go
+ * @Description: 出站 Webhook 投递 - 按事件类型匹配订阅，请求体带 HMAC 签名，失败后指数退避重试，
+ *               超过最大次数进入死信；按目标主机限速，投递记录与订阅可在管理接口查询和维护
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 投递状态
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryRetrying  = "retrying"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+// Webhook 投递默认值
+const (
+	DefaultWebhookAdminPath      = "/admin/webhooks"
+	DefaultWebhookWorkers        = 4
+	DefaultWebhookQueueSize      = 1000
+	DefaultWebhookMaxAttempts    = 8
+	DefaultWebhookInitialBackoff = time.Second
+	DefaultWebhookMaxBackoff     = 10 * time.Minute
+	DefaultWebhookTimeout        = 10 * time.Second
+	DefaultWebhookRateLimit      = 10
+	DefaultWebhookLogSize        = 1000
+	webhookResponseSnippetBytes  = 512
+)
+
+// WebhookSubscription 订阅：Events 支持精确类型、"order.*" 前缀与 "*"
+type WebhookSubscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // 签名密钥，查询接口中不返回
+	Events []string `json:"events"`
+}
+
+// WebhooksConfig Webhook 投递配置
+type WebhooksConfig struct {
+	Subscriptions  []WebhookSubscription
+	AdminPath      string        // 管理接口路径，默认 /admin/webhooks；接口本身需由认证 / 授权中间件保护
+	Workers        int           // 并发投递数，默认 4
+	QueueSize      int           // 待投递队列长度，默认 1000，队列满时 Publish 返回错误
+	MaxAttempts    int           // 最大投递次数（含首次），默认 8，用尽后进入死信
+	InitialBackoff time.Duration // 首次重试间隔，默认 1s，之后每次翻倍并加 ±20% 抖动
+	MaxBackoff     time.Duration // 重试间隔上限，默认 10m
+	Timeout        time.Duration // 单次请求超时，默认 10s
+	RateLimit      float64       // 每个目标主机每秒最多投递次数，默认 10
+	Burst          int           // 目标主机突发量，默认与 RateLimit 取整一致
+	LogSize        int           // 保留的投递记录条数，默认 1000；未结束的投递不会被淘汰
+	OnDeadLetter   func(WebhookDelivery)
+}
+
+// WebhookEvent 事件，作为请求体发送
+type WebhookEvent struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// WebhookDelivery 一个事件到一个订阅的投递记录
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	URL            string     `json:"url"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	StatusCode     int        `json:"status_code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Response       string     `json:"response,omitempty"` // 最后一次响应体前 512 字节
+	CreatedAt      time.Time  `json:"created_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// webhookDelivery 投递任务，body 在事件发布时编码一次，重试复用
+type webhookDelivery struct {
+	record WebhookDelivery
+	secret string
+	host   string
+	body   []byte
+}
+
+// WebhookManager 出站 Webhook 投递器
+type WebhookManager struct {
+	config WebhooksConfig
+	client *http.Client
+	queue  chan *webhookDelivery
+
+	mu         sync.RWMutex
+	subs       map[string]WebhookSubscription
+	deliveries map[string]*webhookDelivery
+	order      []string // 投递记录按创建顺序
+	limiters   map[string]*webhookLimiter
+	timers     map[string]*time.Timer
+	closing    bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Webhook 指标（注册到默认 Registry）
+var (
+	webhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_webhook_deliveries_total",
+		Help: "Total number of webhook delivery attempts by result",
+	}, []string{"result"})
+
+	webhookDeliveryDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_webhook_delivery_duration_seconds",
+		Help:    "Duration of outbound webhook requests",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// NewWebhookManager 校验配置并启动投递 worker
+func NewWebhookManager(cfg WebhooksConfig) (*WebhookManager, error) {
+	if cfg.Workers < 0 || cfg.QueueSize < 0 || cfg.MaxAttempts < 0 || cfg.RateLimit < 0 || cfg.Burst < 0 || cfg.LogSize < 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidParameter, "webhook workers, queue size, attempts, rate limit, burst and log size must not be negative")
+	}
+	if cfg.AdminPath == "" {
+		cfg.AdminPath = DefaultWebhookAdminPath
+	}
+	cfg.AdminPath = strings.TrimSuffix(cfg.AdminPath, "/")
+	if cfg.Workers == 0 {
+		cfg.Workers = DefaultWebhookWorkers
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = DefaultWebhookQueueSize
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultWebhookInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultWebhookMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultWebhookTimeout
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = DefaultWebhookRateLimit
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = max(1, int(cfg.RateLimit))
+	}
+	if cfg.LogSize == 0 {
+		cfg.LogSize = DefaultWebhookLogSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &WebhookManager{
+		config:     cfg,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		queue:      make(chan *webhookDelivery, cfg.QueueSize),
+		subs:       make(map[string]WebhookSubscription, len(cfg.Subscriptions)),
+		deliveries: make(map[string]*webhookDelivery),
+		limiters:   make(map[string]*webhookLimiter),
+		timers:     make(map[string]*time.Timer),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for _, sub := range cfg.Subscriptions {
+		if _, appErr := m.AddSubscription(sub); appErr != nil {
+			cancel()
+			return nil, appErr
+		}
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m, nil
+}
+
+// AdminPath 管理接口路径
+func (m *WebhookManager) AdminPath() string {
+	return m.config.AdminPath
+}
+
+// AddSubscription 添加或替换订阅，ID 为空时自动生成
+func (m *WebhookManager) AddSubscription(sub WebhookSubscription) (WebhookSubscription, *errors.AppError) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return sub, errors.NewErrorf(errors.ErrCodeInvalidParameter, "webhook url %q must be an absolute http(s) url", sub.URL)
+	}
+	if len(sub.Events) == 0 {
+		return sub, errors.NewErrorf(errors.ErrCodeInvalidParameter, "webhook subscription %q requires at least one event type", sub.URL)
+	}
+	if sub.ID == "" {
+		sub.ID = newWebhookID()
+	}
+	m.mu.Lock()
+	m.subs[sub.ID] = sub
+	m.mu.Unlock()
+	return sub, nil
+}
+
+// RemoveSubscription 删除订阅，已生成的投递继续完成
+func (m *WebhookManager) RemoveSubscription(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.subs[id]
+	delete(m.subs, id)
+	return ok
+}
+
+// Subscriptions 当前订阅，按 ID 排序，不含密钥
+func (m *WebhookManager) Subscriptions() []WebhookSubscription {
+	m.mu.RLock()
+	subs := make([]WebhookSubscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+	m.mu.RUnlock()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// Publish 向匹配 eventType 的订阅投递事件，返回事件 ID；没有匹配的订阅时不做任何事
+func (m *WebhookManager) Publish(eventType string, data any) (string, *errors.AppError) {
+	event := WebhookEvent{ID: newWebhookID(), Type: eventType, Time: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", errors.NewErrorf(errors.ErrCodeInvalidParameter, "encode webhook event: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return "", errors.NewError(errors.ErrCodeServiceUnavailable, "webhook manager is closed")
+	}
+	var matched []*webhookDelivery
+	for _, sub := range m.subs {
+		if !webhookEventMatch(sub.Events, eventType) {
+			continue
+		}
+		u, _ := url.Parse(sub.URL)
+		matched = append(matched, &webhookDelivery{
+			record: WebhookDelivery{
+				ID:             newWebhookID(),
+				SubscriptionID: sub.ID,
+				URL:            sub.URL,
+				EventID:        event.ID,
+				EventType:      eventType,
+				Status:         WebhookDeliveryPending,
+				CreatedAt:      event.Time,
+			},
+			secret: sub.Secret,
+			host:   strings.ToLower(u.Host),
+			body:   body,
+		})
+	}
+	if len(m.queue)+len(matched) > cap(m.queue) {
+		return "", errors.NewError(errors.ErrCodeServiceUnavailable, "webhook queue is full")
+	}
+	for _, d := range matched {
+		m.track(d)
+		m.queue <- d
+	}
+	return event.ID, nil
+}
+
+// Deliveries 投递记录，按创建时间倒序；status / subscription / event 为空表示不过滤
+func (m *WebhookManager) Deliveries(status, subscription, eventType string) []WebhookDelivery {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]WebhookDelivery, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		rec := m.deliveries[m.order[i]].record
+		if (status == "" || rec.Status == status) &&
+			(subscription == "" || rec.SubscriptionID == subscription) &&
+			(eventType == "" || rec.EventType == eventType) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// Redeliver 重新投递一条已结束的记录（通常是死信），投递次数从 0 开始
+func (m *WebhookManager) Redeliver(id string) (WebhookDelivery, *errors.AppError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deliveries[id]
+	if !ok {
+		return WebhookDelivery{}, errors.NewErrorf(errors.ErrCodeNotFound, "webhook delivery %q not found", id)
+	}
+	if d.record.Status != WebhookDeliveryDead && d.record.Status != WebhookDeliverySucceeded {
+		return d.record, errors.NewErrorf(errors.ErrCodeBadRequest, "webhook delivery %q is still %s", id, d.record.Status)
+	}
+	if m.closing || len(m.queue) == cap(m.queue) {
+		return d.record, errors.NewError(errors.ErrCodeServiceUnavailable, "webhook queue is full")
+	}
+	d.record.Status, d.record.Attempts, d.record.NextAttemptAt = WebhookDeliveryPending, 0, nil
+	m.queue <- d
+	return d.record, nil
+}
+
+// Shutdown 停止接收事件，取消等待中的重试，ctx 结束前尽量把队列中的投递发完
+func (m *WebhookManager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return
+	}
+	m.closing = true
+	for id, t := range m.timers {
+		t.Stop()
+		delete(m.timers, id)
+	}
+	close(m.queue)
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		m.cancel()
+		<-done
+	}
+	m.cancel()
+}
+
+// worker 依次投递队列中的任务
+func (m *WebhookManager) worker() {
+	defer m.wg.Done()
+	for d := range m.queue {
+		if m.ctx.Err() != nil {
+			continue
+		}
+		if wait := m.limiter(d.host).reserve(time.Now()); wait > 0 {
+			m.schedule(d, wait, "")
+			continue
+		}
+		m.attempt(d)
+	}
+}
+
+// attempt 发送一次请求并根据结果决定完成、重试或进入死信
+func (m *WebhookManager) attempt(d *webhookDelivery) {
+	m.mu.RLock()
+	attempt := d.record.Attempts + 1
+	m.mu.RUnlock()
+
+	start := time.Now()
+	statusCode, snippet, err := m.send(d)
+	webhookDeliveryDuration.Observe(time.Since(start).Seconds())
+
+	m.mu.Lock()
+	rec := &d.record
+	rec.Attempts = attempt
+	rec.LastAttemptAt = &start
+	rec.StatusCode, rec.Response, rec.Error, rec.NextAttemptAt = statusCode, snippet, "", nil
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	switch {
+	case err == nil:
+		rec.Status = WebhookDeliverySucceeded
+		webhookDeliveriesTotal.WithLabelValues("success").Inc()
+	case attempt >= m.config.MaxAttempts:
+		rec.Status = WebhookDeliveryDead
+		webhookDeliveriesTotal.WithLabelValues("dead").Inc()
+	default:
+		webhookDeliveriesTotal.WithLabelValues("retry").Inc()
+	}
+	status, dead := rec.Status, *rec
+	m.mu.Unlock()
+
+	switch {
+	case status == WebhookDeliveryDead:
+		global.LOGGER.WarnKV("Webhook 投递进入死信",
+			"delivery", dead.ID,
+			"subscription", dead.SubscriptionID,
+			"event", dead.EventType,
+			"attempts", dead.Attempts,
+			"error", dead.Error)
+		if m.config.OnDeadLetter != nil {
+			m.config.OnDeadLetter(dead)
+		}
+	case err != nil:
+		m.schedule(d, m.backoff(attempt), WebhookDeliveryRetrying)
+	}
+}
+
+// send 发送请求，2xx 视为成功
+// 签名：X-Webhook-Signature: sha256=HMAC-SHA256(secret, "{timestamp}.{body}")，接收方应校验时间戳防重放
+func (m *WebhookManager) send(d *webhookDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, d.record.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(constants.HeaderContentType, "application/json")
+	req.Header.Set(constants.HeaderXWebhookID, d.record.ID)
+	req.Header.Set(constants.HeaderXWebhookEvent, d.record.EventType)
+	req.Header.Set(constants.HeaderXWebhookTimestamp, ts)
+	if d.secret != "" {
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(d.body)
+		req.Header.Set(constants.HeaderXWebhookSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseSnippetBytes))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(snippet), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// schedule 延迟后重新入队，status 为空时保持当前状态；关闭后不再调度
+func (m *WebhookManager) schedule(d *webhookDelivery, delay time.Duration, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return
+	}
+	if status != "" {
+		d.record.Status = status
+	}
+	next := time.Now().Add(delay)
+	d.record.NextAttemptAt = &next
+	id := d.record.ID
+	m.timers[id] = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.timers, id)
+		if m.closing {
+			return
+		}
+		select {
+		case m.queue <- d:
+		default:
+			// 队列满时稍后再试，重试不计入投递次数
+			next := time.Now().Add(m.config.InitialBackoff)
+			d.record.NextAttemptAt = &next
+			m.timers[id] = time.AfterFunc(m.config.InitialBackoff, func() { m.schedule(d, 0, "") })
+		}
+	})
+}
+
+// backoff 第 attempt 次失败后的等待时间：InitialBackoff * 2^(attempt-1)，上限 MaxBackoff，±20% 抖动
+func (m *WebhookManager) backoff(attempt int) time.Duration {
+	delay := m.config.InitialBackoff
+	for i := 1; i < attempt && delay < m.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, m.config.MaxBackoff)
+	var b [1]byte
+	_, _ = rand.Read(b[:])
+	jitter := (float64(b[0])/255*0.4 - 0.2) * float64(delay)
+	return delay + time.Duration(jitter)
+}
+
+// track 记录投递，超过 LogSize 时淘汰最早的已结束记录
+func (m *WebhookManager) track(d *webhookDelivery) {
+	m.deliveries[d.record.ID] = d
+	m.order = append(m.order, d.record.ID)
+	for excess := len(m.order) - m.config.LogSize; excess > 0; excess-- {
+		idx := -1
+		for i, id := range m.order {
+			if s := m.deliveries[id].record.Status; s == WebhookDeliverySucceeded || s == WebhookDeliveryDead {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return
+		}
+		delete(m.deliveries, m.order[idx])
+		m.order = append(m.order[:idx], m.order[idx+1:]...)
+	}
+}
+
+// limiter 目标主机的令牌桶
+func (m *WebhookManager) limiter(host string) *webhookLimiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.limiters[host]
+	if !ok {
+		l = &webhookLimiter{rate: m.config.RateLimit, burst: float64(m.config.Burst), tokens: float64(m.config.Burst)}
+		m.limiters[host] = l
+	}
+	return l
+}
+
+// webhookLimiter 令牌桶，reserve 返回拿到令牌前需要等待的时间
+type webhookLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve 有令牌时扣减并返回 0，否则返回下一个令牌可用前的等待时间
+func (l *webhookLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// AdminHandler 管理接口：
+//
+//	GET    {path}                              订阅与最近投递
+//	GET    {path}/deliveries?status=&subscription=&event=
+//	POST   {path}/deliveries/{id}/redeliver
+//	POST   {path}/subscriptions                添加或替换订阅
+//	DELETE {path}/subscriptions/{id}
+func (m *WebhookManager) AdminHandler() http.HandlerFunc {
+	path := m.config.AdminPath
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, path)
+		switch {
+		case (rest == "" || rest == "/") && r.Method == http.MethodGet:
+			response.WriteJSONResponse(w, http.StatusOK, map[string]any{
+				"subscriptions": m.Subscriptions(),
+				"deliveries":    m.Deliveries("", "", ""),
+			})
+		case rest == "/deliveries" && r.Method == http.MethodGet:
+			q := r.URL.Query()
+			response.WriteJSONResponse(w, http.StatusOK, m.Deliveries(q.Get("status"), q.Get("subscription"), q.Get("event")))
+		case strings.HasPrefix(rest, "/deliveries/") && strings.HasSuffix(rest, "/redeliver") && r.Method == http.MethodPost:
+			id := strings.TrimSuffix(strings.TrimPrefix(rest, "/deliveries/"), "/redeliver")
+			rec, appErr := m.Redeliver(id)
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			response.WriteJSONResponse(w, http.StatusAccepted, rec)
+		case rest == "/subscriptions" && r.Method == http.MethodPost:
+			var sub WebhookSubscription
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&sub); err != nil {
+				response.WriteBadRequestResult(w, "invalid webhook subscription: "+err.Error())
+				return
+			}
+			sub, appErr := m.AddSubscription(sub)
+			if appErr != nil {
+				response.WriteAppError(w, appErr)
+				return
+			}
+			sub.Secret = ""
+			response.WriteJSONResponse(w, http.StatusCreated, sub)
+		case strings.HasPrefix(rest, "/subscriptions/") && r.Method == http.MethodDelete:
+			if !m.RemoveSubscription(strings.TrimPrefix(rest, "/subscriptions/")) {
+				response.WriteNotFoundResult(w, "webhook subscription not found")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			response.WriteNotFoundResult(w, "unknown webhook admin endpoint")
+		}
+	}
+}
+
+// webhookEventMatch 事件类型是否匹配订阅
+func webhookEventMatch(patterns []string, eventType string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newWebhookID 生成 96 位随机 ID
+func newWebhookID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -42,6 +42,8 @@ type WhitelistRule interface {
 type WhitelistManager struct {
 	rules   []WhitelistRule
 	ipRules []IPWhitelistRule // IP 相关规则单独存储
+	routes  *RouteTable       // 前缀/Glob/精确路径规则编译成的路由表
+	others  []WhitelistRule   // 无法编译进路由表的规则
 	mu      sync.RWMutex
 }
 
@@ -88,14 +90,35 @@ func (m *WhitelistManager) Register(rule WhitelistRule) {
 	if !inserted {
 		m.rules = append(m.rules, rule)
 	}
+	m.compile()
 }
 
-// IsWhitelisted 检查请求是否在白名单中
-func (m *WhitelistManager) IsWhitelisted(method, path string) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+// compile 将路径类规则编译进路由表（调用方持有写锁）
+// 白名单只关心是否命中，与规则顺序无关
+func (m *WhitelistManager) compile() {
+	var patterns []RoutePattern
+	m.others = m.others[:0]
 	for _, rule := range m.rules {
+		switch r := rule.(type) {
+		case *PathPrefixRule:
+			patterns = append(patterns, RoutePattern{Kind: RouteMatchPrefix, Pattern: r.prefix, Methods: r.methods, Value: r})
+		case *PathGlobRule:
+			patterns = append(patterns, RoutePattern{Kind: RouteMatchGlob, Pattern: r.pattern, Methods: r.methods, Value: r})
+		case *ExactPathRule:
+			patterns = append(patterns, RoutePattern{Kind: RouteMatchExact, Pattern: r.path, Methods: []string{r.method}, Value: r})
+		default:
+			m.others = append(m.others, rule)
+		}
+	}
+	m.routes = NewRouteTable(patterns)
+}
+
+// matchRules 检查路径类规则与其余规则（调用方持有读锁）
+func (m *WhitelistManager) matchRules(method, path string) bool {
+	if _, ok := m.routes.Match(method, path); ok {
+		return true
+	}
+	for _, rule := range m.others {
 		if rule.Match(method, path) {
 			return true
 		}
@@ -103,6 +126,14 @@ func (m *WhitelistManager) IsWhitelisted(method, path string) bool {
 	return false
 }
 
+// IsWhitelisted 检查请求是否在白名单中
+func (m *WhitelistManager) IsWhitelisted(method, path string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.matchRules(method, path)
+}
+
 // IsWhitelistedWithIP 检查请求是否在白名单中（包含 IP 检查）
 func (m *WhitelistManager) IsWhitelistedWithIP(method, path, clientIP string) bool {
 	m.mu.RLock()
@@ -116,12 +147,7 @@ func (m *WhitelistManager) IsWhitelistedWithIP(method, path, clientIP string) bo
 	}
 
 	// 再检查普通规则
-	for _, rule := range m.rules {
-		if rule.Match(method, path) {
-			return true
-		}
-	}
-	return false
+	return m.matchRules(method, path)
 }
 
 // GetRules 获取所有规则（用于调试）
@@ -140,6 +166,8 @@ func (m *WhitelistManager) Clear() {
 	defer m.mu.Unlock()
 	m.rules = make([]WhitelistRule, 0)
 	m.ipRules = make([]IPWhitelistRule, 0)
+	m.routes = nil
+	m.others = nil
 }
 
 // ============================================================================
@@ -0,0 +1,348 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\overrides\apply.go
+ * @Description: 覆盖写入 - 按 mapstructure 标签逐段定位配置字段，键名忽略大小写、- 与 _；
+ *               途经的空指针按需创建，切片按下标定位，map 按键定位；叶子值按字段类型解析，复杂类型接受 JSON；
+ *               写入深拷贝，全部成功后才替换目标，失败时目标保持不变
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package overrides
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result 写入结果
+type Result struct {
+	Applied []Override // 已写入的覆盖
+	Ignored []Override // 配置中不存在的环境变量覆盖（环境变量可能属于其他用途，不视为错误）
+}
+
+// durationType time.Duration 按时长字符串解析
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Apply 按顺序把覆盖写入 target（结构体指针），后写入的覆盖先写入的；
+// 命令行与代码指定的键不存在或值无法解析时返回错误且不修改 target，环境变量的键不存在时记入 Ignored
+func Apply(target any, list []Override) (*Result, error) {
+	root := reflect.ValueOf(target)
+	if root.Kind() != reflect.Pointer || root.IsNil() {
+		return nil, fmt.Errorf("overrides: target must be a non-nil pointer, got %T", target)
+	}
+	work := reflect.New(root.Elem().Type()).Elem()
+	deepCopy(work, root.Elem(), map[uintptr]reflect.Value{})
+	result := &Result{}
+	for _, o := range list {
+		path := strings.Split(o.Key, ".")
+		err := assign(work, path, o.Value)
+		if err == nil {
+			result.Applied = append(result.Applied, o)
+			continue
+		}
+		if _, unknown := err.(unknownKeyError); unknown && o.Source == SourceEnv {
+			result.Ignored = append(result.Ignored, o)
+			continue
+		}
+		return nil, fmt.Errorf("overrides: %s (%s %s): %w", o.Key, o.Source, o.Origin, err)
+	}
+	root.Elem().Set(work)
+	return result, nil
+}
+
+// deepCopy 把 src 复制到 dst：导出字段途经的指针、map、切片与接口逐层复制，写入副本不会影响 src；
+// 未导出字段按值复制（覆盖不会写入），seen 记录已复制的指针以保留共享与环
+func deepCopy(dst, src reflect.Value, seen map[uintptr]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		if fresh, ok := seen[src.Pointer()]; ok && fresh.Type() == src.Type() {
+			dst.Set(fresh)
+			return
+		}
+		fresh := reflect.New(src.Type().Elem())
+		seen[src.Pointer()] = fresh
+		deepCopy(fresh.Elem(), src.Elem(), seen)
+		dst.Set(fresh)
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				deepCopy(dst.Field(i), src.Field(i), seen)
+			}
+		}
+	case reflect.Array:
+		dst.Set(src)
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i), seen)
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		fresh := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(fresh.Index(i), src.Index(i), seen)
+		}
+		dst.Set(fresh)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		fresh := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for iter := src.MapRange(); iter.Next(); {
+			elem := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(elem, iter.Value(), seen)
+			fresh.SetMapIndex(iter.Key(), elem)
+		}
+		dst.Set(fresh)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(elem, src.Elem(), seen)
+		dst.Set(elem)
+	default:
+		dst.Set(src)
+	}
+}
+
+// unknownKeyError 配置中不存在的键
+type unknownKeyError string
+
+// Error 错误信息
+func (e unknownKeyError) Error() string {
+	return "unknown config key segment " + strconv.Quote(string(e))
+}
+
+// assign 定位 path 对应的字段并写入
+func assign(v reflect.Value, path []string, raw string) error {
+	if len(path) == 0 {
+		return parseInto(v, raw)
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice:
+		default:
+			return unknownKeyError(path[0])
+		}
+		if v.IsNil() {
+			fresh := reflect.New(v.Type().Elem())
+			if err := assign(fresh.Elem(), path, raw); err != nil {
+				return err
+			}
+			v.Set(fresh)
+			return nil
+		}
+		return assign(v.Elem(), path, raw)
+	case reflect.Struct:
+		field, ok := findField(v, path[0])
+		if !ok {
+			return unknownKeyError(path[0])
+		}
+		return assign(field, path[1:], raw)
+	case reflect.Map:
+		return assignMap(v, path, raw)
+	case reflect.Slice:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i > v.Len() {
+			return fmt.Errorf("index %q out of range (len %d, use len to append)", path[0], v.Len())
+		}
+		if i == v.Len() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(elem, path[1:], raw); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+			return nil
+		}
+		return assign(v.Index(i), path[1:], raw)
+	case reflect.Interface:
+		m, ok := v.Interface().(map[string]any)
+		if !ok {
+			m = map[string]any{}
+		}
+		mv := reflect.ValueOf(m)
+		if err := assignMap(mv, path, raw); err != nil {
+			return err
+		}
+		v.Set(mv)
+		return nil
+	}
+	return unknownKeyError(path[0])
+}
+
+// assignMap map 的值不可寻址，先复制出来写入再放回；已有键按规范化后的名称匹配，保留原始大小写
+func assignMap(m reflect.Value, path []string, raw string) error {
+	if m.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("map key type %s is not supported", m.Type().Key())
+	}
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	key := reflect.ValueOf(path[0]).Convert(m.Type().Key())
+	for _, k := range m.MapKeys() {
+		if normalize(k.String()) == normalize(path[0]) {
+			key = k
+			break
+		}
+	}
+	elem := reflect.New(m.Type().Elem()).Elem()
+	if existing := m.MapIndex(key); existing.IsValid() {
+		elem.Set(existing)
+	}
+	if err := assign(elem, path[1:], raw); err != nil {
+		return err
+	}
+	m.SetMapIndex(key, elem)
+	return nil
+}
+
+// findField 按 mapstructure 标签（缺省为字段名）定位字段，展开 squash 与匿名嵌入的结构体
+func findField(v reflect.Value, segment string) (reflect.Value, bool) {
+	want := normalize(segment)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous || strings.Contains(opts, "squash") {
+			inner := v.Field(i)
+			if inner.Kind() == reflect.Pointer {
+				if inner.IsNil() {
+					continue
+				}
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				if field, ok := findField(inner, segment); ok {
+					return field, true
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if normalize(name) == want {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// normalize 键名规范化：小写并去掉 - 与 _，rate-limit、rate_limit、RATELIMIT、rateLimit 视为同一键
+func normalize(s string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(s))
+}
+
+// parseInto 按字段类型解析文本
+func parseInto(v reflect.Value, raw string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(raw))
+		}
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Pointer:
+		fresh := reflect.New(v.Type().Elem())
+		if err := parseInto(fresh.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(fresh)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			return decodeJSON(v, raw)
+		}
+		// 逗号分隔的标量列表，空串表示清空
+		out := reflect.MakeSlice(v.Type(), 0, 0)
+		if raw != "" {
+			for _, item := range strings.Split(raw, ",") {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := parseInto(elem, strings.TrimSpace(item)); err != nil {
+					return err
+				}
+				out = reflect.Append(out, elem)
+			}
+		}
+		v.Set(out)
+	case reflect.Interface:
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+		v.Set(reflect.ValueOf(&value).Elem())
+	case reflect.Map, reflect.Struct:
+		return decodeJSON(v, raw)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// decodeJSON 复杂类型以 JSON 整体替换（字段名按 json 标签）
+func decodeJSON(v reflect.Value, raw string) error {
+	fresh := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(raw), fresh.Interface()); err != nil {
+		return fmt.Errorf("invalid JSON for %s: %v", v.Type(), err)
+	}
+	v.Set(fresh.Elem())
+	return nil
+}
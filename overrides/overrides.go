@@ -0,0 +1,226 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\overrides\overrides.go
+ * @Description: 配置覆盖 - 以环境变量（GATEWAY_RATE_LIMIT__ENABLED=true）与命令行参数（--set rate-limit.enabled=true）
+ *               覆盖任意配置键，容器部署调整少量配置时无需模板化 YAML；环境变量覆盖需显式开启（Config.Env 或 --env-overrides）；
+ *               优先级从低到高：默认值 < 配置文件 < 环境变量 < 命令行参数 < 代码指定
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package overrides
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 默认值
+const (
+	DefaultEnvPrefix = "GATEWAY_"
+	EnvSeparator     = "__" // 环境变量中的层级分隔符，单个下划线属于键名本身（rate_limit 匹配 rate-limit）
+	FlagName         = "set"
+	FlagUsage        = "覆盖配置键，格式 key=value，可重复，如 --set rate-limit.enabled=true"
+	EnvFlagName      = "env-overrides"
+	EnvFlagUsage     = "读取 GATEWAY_ 前缀的环境变量覆盖配置键"
+)
+
+// Source 覆盖来源，取值越大优先级越高
+type Source int
+
+// 覆盖来源
+const (
+	SourceEnv  Source = iota + 1 // 环境变量
+	SourceFlag                   // 命令行参数
+	SourceCode                   // 代码指定（Config.Values）
+)
+
+// String 来源名称
+func (s Source) String() string {
+	switch s {
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	case SourceCode:
+		return "code"
+	}
+	return "unknown"
+}
+
+// Override 一项配置覆盖
+type Override struct {
+	Key    string // 点分配置键，如 rate-limit.enabled、listeners.0.port
+	Value  string
+	Source Source
+	Origin string // 环境变量名或命令行参数，用于日志与错误信息
+}
+
+// Config 覆盖来源配置
+type Config struct {
+	EnvPrefix string // 环境变量前缀，默认 GATEWAY_
+	// Env 读取环境变量覆盖，默认关闭；Args 中出现 --env-overrides 时同样开启
+	Env bool
+	// Args 命令行参数（通常为 os.Args[1:]），识别 --set key=value、--set=key=value、--env-overrides 与单横线形式，其他参数忽略
+	Args []string
+	// Flags 应用自行解析的 --set 参数（flag.Var(&flags, overrides.FlagName, overrides.FlagUsage)），与 Args 同级
+	Flags SetFlag
+	// Values 代码指定的覆盖，优先级最高
+	Values map[string]string
+}
+
+// Collect 按优先级从低到高收集全部覆盖，同一来源内保持出现顺序，后出现的覆盖先出现的
+func Collect(cfg Config) ([]Override, error) {
+	var out []Override
+	env := cfg.Env
+	if !env {
+		var err error
+		if env, err = EnvFlag(cfg.Args); err != nil {
+			return nil, err
+		}
+	}
+	if env {
+		prefix := cfg.EnvPrefix
+		if prefix == "" {
+			prefix = DefaultEnvPrefix
+		}
+		out = append(out, FromEnv(prefix, os.Environ())...)
+	}
+	args, err := FromArgs(cfg.Args)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, args...)
+	out = append(out, cfg.Flags...)
+	keys := make([]string, 0, len(cfg.Values))
+	for key := range cfg.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out = append(out, Override{Key: key, Value: cfg.Values[key], Source: SourceCode, Origin: key})
+	}
+	slices.SortStableFunc(out, func(a, b Override) int { return int(a.Source - b.Source) })
+	return out, nil
+}
+
+// FromEnv 从环境变量解析覆盖：去掉前缀后以 __ 分隔层级并转为小写，GATEWAY_MIDDLEWARE__CIRCUIT_BREAKER__ENABLED
+// 对应 middleware.circuit_breaker.enabled；环境变量按名称排序，结果与进程环境的顺序无关
+func FromEnv(prefix string, environ []string) []Override {
+	var out []Override
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		segments := strings.Split(strings.ToLower(name[len(prefix):]), EnvSeparator)
+		if slices.Contains(segments, "") {
+			continue
+		}
+		out = append(out, Override{Key: strings.Join(segments, "."), Value: value, Source: SourceEnv, Origin: name})
+	}
+	slices.SortFunc(out, func(a, b Override) int { return strings.Compare(a.Origin, b.Origin) })
+	return out
+}
+
+// FromArgs 从命令行参数中解析 --set，其他参数原样忽略；-- 之后的参数不再解析
+func FromArgs(args []string) ([]Override, error) {
+	var out []Override
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg {
+			continue
+		}
+		var value string
+		switch {
+		case name == FlagName:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("overrides: %s requires key=value", arg)
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(name, FlagName+"="):
+			value = name[len(FlagName)+1:]
+		default:
+			continue
+		}
+		o, err := parseAssignment(value, SourceFlag, arg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// EnvFlag 命令行参数中是否开启环境变量覆盖：--env-overrides 或 --env-overrides=true，-- 之后的参数不再解析
+func EnvFlag(args []string) (bool, error) {
+	enabled := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		switch {
+		case name == arg:
+		case name == FlagName:
+			i++ // 跳过 --set 的取值
+		case name == EnvFlagName:
+			enabled = true
+		case strings.HasPrefix(name, EnvFlagName+"="):
+			v, err := strconv.ParseBool(name[len(EnvFlagName)+1:])
+			if err != nil {
+				return false, fmt.Errorf("overrides: %s: expected a boolean", arg)
+			}
+			enabled = v
+		}
+	}
+	return enabled, nil
+}
+
+// parseAssignment 解析 key=value
+func parseAssignment(s string, source Source, origin string) (Override, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Override{}, fmt.Errorf("overrides: %s: expected key=value, got %q", origin, s)
+	}
+	return Override{Key: key, Value: value, Source: source, Origin: origin}, nil
+}
+
+// SetFlag 实现 flag.Value，收集可重复的 --set key=value
+type SetFlag []Override
+
+// String 已收集的覆盖
+func (f *SetFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(*f))
+	for _, o := range *f {
+		parts = append(parts, o.Key+"="+o.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set 追加一项覆盖
+func (f *SetFlag) Set(s string) error {
+	o, err := parseAssignment(s, SourceFlag, "--"+FlagName)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, o)
+	return nil
+}
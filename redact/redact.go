@@ -0,0 +1,186 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\redact\redact.go
+ * @Description: 密钥脱敏 - 配置结构体写入日志或 HTTP 响应前自动脱敏；规则包括 sensitive:"true" 标签、
+ *               点分路径规则、自定义规则，以及配置结构体内按键名识别的密钥（password、secret、access-key 等）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package redact
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// 默认值
+const (
+	Mask    = "******"
+	TagName = "sensitive" // sensitive:"true" 脱敏，sensitive:"false" 豁免键名识别
+)
+
+// DefaultConfigPackages 默认视为配置结构体的包路径前缀
+var DefaultConfigPackages = []string{"github.com/kamalyes/go-config"}
+
+// secretKeyWords 键名最后一段为这些词时视为密钥
+var secretKeyWords = []string{"password", "passwd", "secret", "token", "tokens", "key", "keys", "apikey", "credential", "credentials"}
+
+// IsSecretKey 键名是否表示密钥：按 - _ . 分段后取最后一段判断，key-file 等路径类配置、header-name 等名称类配置除外
+func IsSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	if name == "key-key" { // TLS 私钥文件路径的历史键名
+		return false
+	}
+	segments := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	if len(segments) == 0 {
+		return false
+	}
+	return slices.Contains(secretKeyWords, segments[len(segments)-1])
+}
+
+// Rule 自定义规则，返回 true 时脱敏；path 为点分路径（列表元素沿用所在字段的路径），
+// field 为结构体字段，map 的键没有对应字段时为 nil
+type Rule func(path string, field *reflect.StructField) bool
+
+// Config 脱敏配置
+type Config struct {
+	// Paths 路径规则，任意结构中命中即脱敏；段间以 . 分隔，* 匹配一段，** 匹配任意多段，
+	// 如 oss.**.access-key、**.dsn；段名取 mapstructure 标签，其次 json 标签，忽略大小写
+	Paths []string
+	// Exclude 不按键名识别脱敏的路径，规则同 Paths；标签与 Paths 命中的字段仍会脱敏
+	Exclude []string
+	// ConfigPackages 视为配置结构体的包路径前缀，默认 go-config；配置结构体（含其中的 map）内按键名识别密钥
+	ConfigPackages []string
+	// DisableKeyNames 关闭按键名识别，只按标签、路径与自定义规则脱敏
+	DisableKeyNames bool
+	Rules           []Rule
+	Mask            string // 替换字符串，默认 ******
+}
+
+// Redactor 脱敏器
+type Redactor struct {
+	config   Config
+	paths    [][]string
+	exclude  [][]string
+	typeInfo sync.Map // reflect.Type → bool，类型中是否可能存在需要脱敏的字段
+}
+
+// New 创建脱敏器
+func New(cfg Config) (*Redactor, error) {
+	if cfg.Mask == "" {
+		cfg.Mask = Mask
+	}
+	if cfg.ConfigPackages == nil {
+		cfg.ConfigPackages = DefaultConfigPackages
+	}
+	r := &Redactor{config: cfg}
+	var err error
+	if r.paths, err = compilePaths(cfg.Paths); err != nil {
+		return nil, err
+	}
+	if r.exclude, err = compilePaths(cfg.Exclude); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// compilePaths 校验并拆分路径规则
+func compilePaths(patterns []string) ([][]string, error) {
+	out := make([][]string, 0, len(patterns))
+	for _, p := range patterns {
+		segments := strings.Split(strings.ToLower(strings.TrimSpace(p)), ".")
+		if slices.Contains(segments, "") {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "redact: invalid path rule %q", p)
+		}
+		out = append(out, segments)
+	}
+	return out, nil
+}
+
+// current 全局脱敏器，日志与 HTTP 响应写入时使用
+var current atomic.Pointer[Redactor]
+
+func init() {
+	r, _ := New(Config{})
+	current.Store(r)
+}
+
+// Default 当前全局脱敏器
+func Default() *Redactor {
+	return current.Load()
+}
+
+// SetDefault 替换全局脱敏器，nil 恢复默认规则
+func SetDefault(r *Redactor) {
+	if r == nil {
+		r, _ = New(Config{})
+	}
+	current.Store(r)
+}
+
+// Value 使用全局脱敏器脱敏
+func Value(v any) any {
+	return current.Load().Value(v)
+}
+
+// Values 逐个脱敏，全部未变化时返回原切片，供日志参数使用
+func Values(values []any) []any {
+	r := current.Load()
+	var out []any
+	for i, v := range values {
+		redacted, changed := r.redact(v)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(values)
+		}
+		out[i] = redacted
+	}
+	if out == nil {
+		return values
+	}
+	return out
+}
+
+// Fields 脱敏日志字段，全部未变化时返回原 map
+func Fields(fields map[string]any) map[string]any {
+	if redacted, changed := current.Load().redact(fields); changed {
+		return redacted.(map[string]any)
+	}
+	return fields
+}
+
+// Value 返回脱敏后的副本，不修改原值；没有需要脱敏的字段时原样返回。
+// 只复制需要修改的路径，其余部分与原值共享
+func (r *Redactor) Value(v any) any {
+	out, _ := r.redact(v)
+	return out
+}
+
+// redact 脱敏并返回是否有变化
+func (r *Redactor) redact(v any) (any, bool) {
+	switch v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, []byte, error:
+		return v, false
+	}
+	rv := reflect.ValueOf(v)
+	if !r.mayContain(rv.Type()) {
+		return v, false
+	}
+	out, changed := r.walk(rv, "", false, 0)
+	if !changed {
+		return v, false
+	}
+	return out.Interface(), true
+}
@@ -0,0 +1,414 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\redact\walk.go
+ * @Description: 脱敏遍历 - 写时复制：只复制通往被脱敏字段的路径，原值保持不变；
+ *               按类型缓存是否需要遍历，普通日志参数与响应不产生额外分配
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package redact
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// maxDepth 遍历深度上限，防止指针环
+const maxDepth = 32
+
+// mayContain 类型中是否可能存在需要脱敏的字段；配置了路径或自定义规则时任何复合类型都需要遍历
+func (r *Redactor) mayContain(t reflect.Type) bool {
+	if cached, ok := r.typeInfo.Load(t); ok {
+		return cached.(bool)
+	}
+	var result bool
+	if len(r.paths) > 0 || len(r.config.Rules) > 0 {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			result = true
+		}
+	} else {
+		result = r.typeContains(t, map[reflect.Type]bool{})
+	}
+	r.typeInfo.Store(t, result)
+	return result
+}
+
+// typeContains 静态检查类型：配置结构体、带 sensitive 标签的字段、interface（运行时才知道具体类型）
+func (r *Redactor) typeContains(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return r.typeContains(t.Elem(), visiting)
+	case reflect.Struct:
+		if !r.config.DisableKeyNames && r.isConfigType(t) {
+			return true
+		}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			if sf.Tag.Get(TagName) == "true" || r.typeContains(sf.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isConfigType 是否为配置结构体
+func (r *Redactor) isConfigType(t reflect.Type) bool {
+	pkg := t.PkgPath()
+	if pkg == "" {
+		return false
+	}
+	for _, prefix := range r.config.ConfigPackages {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk 遍历 v，返回与 v 同类型的脱敏结果与是否有变化
+func (r *Redactor) walk(v reflect.Value, path string, inConfig bool, depth int) (reflect.Value, bool) {
+	if depth > maxDepth {
+		return v, false
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := r.walk(v.Elem(), path, inConfig, depth+1)
+		if !changed {
+			return v, false
+		}
+		p := reflect.New(elem.Type())
+		p.Elem().Set(elem)
+		return p, true
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		inner, changed := r.walk(v.Elem(), path, inConfig, depth+1)
+		if !changed {
+			return v, false
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(inner)
+		return out, true
+	case reflect.Struct:
+		return r.walkStruct(v, path, inConfig || (!r.config.DisableKeyNames && r.isConfigType(v.Type())), depth)
+	case reflect.Map:
+		return r.walkMap(v, path, inConfig, depth)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v, false
+		}
+		return r.walkElems(v, func(elem reflect.Value) (reflect.Value, bool) {
+			return r.walk(elem, path, inConfig, depth+1)
+		})
+	}
+	return v, false
+}
+
+// walkStruct 遍历导出字段，squash 与匿名嵌入的字段不增加路径段
+func (r *Redactor) walkStruct(v reflect.Value, path string, inConfig bool, depth int) (reflect.Value, bool) {
+	t := v.Type()
+	var out reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, squash := fieldName(sf)
+		fieldPath := path
+		if !squash {
+			fieldPath = joinPath(path, name)
+		}
+
+		field := v.Field(i)
+		var replaced reflect.Value
+		var changed bool
+		if all, ok := r.sensitive(fieldPath, name, &sf, inConfig && !squash); ok {
+			replaced, changed = r.mask(field, all, depth)
+		} else {
+			replaced, changed = r.walk(field, fieldPath, inConfig, depth+1)
+		}
+		if !changed {
+			continue
+		}
+		if !out.IsValid() {
+			out = reflect.New(t).Elem()
+			out.Set(v)
+		}
+		out.Field(i).Set(replaced)
+	}
+	if !out.IsValid() {
+		return v, false
+	}
+	return out, true
+}
+
+// walkMap 遍历 map，键作为路径段
+func (r *Redactor) walkMap(v reflect.Value, path string, inConfig bool, depth int) (reflect.Value, bool) {
+	if v.IsNil() {
+		return v, false
+	}
+	var out reflect.Value
+	iter := v.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		name := fmt.Sprint(key.Interface())
+		keyPath := joinPath(path, name)
+
+		var replaced reflect.Value
+		var changed bool
+		if all, ok := r.sensitive(keyPath, name, nil, inConfig); ok {
+			replaced, changed = r.mask(iter.Value(), all, depth)
+		} else {
+			replaced, changed = r.walk(iter.Value(), keyPath, inConfig, depth+1)
+		}
+		if !changed {
+			continue
+		}
+		if !out.IsValid() {
+			out = reflect.MakeMapWithSize(v.Type(), v.Len())
+			copied := v.MapRange()
+			for copied.Next() {
+				out.SetMapIndex(copied.Key(), copied.Value())
+			}
+		}
+		out.SetMapIndex(key, replaced)
+	}
+	if !out.IsValid() {
+		return v, false
+	}
+	return out, true
+}
+
+// walkElems 逐个处理切片与数组元素，有变化时复制
+func (r *Redactor) walkElems(v reflect.Value, each func(reflect.Value) (reflect.Value, bool)) (reflect.Value, bool) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return v, false
+	}
+	var out reflect.Value
+	for i := 0; i < v.Len(); i++ {
+		replaced, changed := each(v.Index(i))
+		if !changed {
+			continue
+		}
+		if !out.IsValid() {
+			if v.Kind() == reflect.Slice {
+				out = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			} else {
+				out = reflect.New(v.Type()).Elem()
+			}
+			reflect.Copy(out, v)
+		}
+		out.Index(i).Set(replaced)
+	}
+	if !out.IsValid() {
+		return v, false
+	}
+	return out, true
+}
+
+// sensitive 判断字段或 map 键是否脱敏；all 为真时整体脱敏（非字符串置为零值），
+// 按键名识别的只替换其中的字符串，max-tokens 这类数值配置保持原样
+func (r *Redactor) sensitive(path, name string, field *reflect.StructField, inConfig bool) (all, ok bool) {
+	tag := ""
+	if field != nil {
+		tag = field.Tag.Get(TagName)
+	}
+	if tag == "true" || matchAny(r.paths, path) {
+		return true, true
+	}
+	for _, rule := range r.config.Rules {
+		if rule(path, field) {
+			return true, true
+		}
+	}
+	if tag == "false" || !inConfig || r.config.DisableKeyNames || !IsSecretKey(name) || matchAny(r.exclude, path) {
+		return false, false
+	}
+	return false, true
+}
+
+// mask 替换字符串；all 为真时其他非零值置为零值
+func (r *Redactor) mask(v reflect.Value, all bool, depth int) (reflect.Value, bool) {
+	if depth > maxDepth {
+		return v, false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 || v.String() == r.config.Mask {
+			return v, false
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(r.config.Mask)
+		return out, true
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		inner, changed := r.mask(v.Elem(), all, depth+1)
+		if !changed {
+			return v, false
+		}
+		if v.Kind() == reflect.Pointer {
+			p := reflect.New(inner.Type())
+			p.Elem().Set(inner)
+			return p, true
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(inner)
+		return out, true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return r.walkElems(v, func(elem reflect.Value) (reflect.Value, bool) {
+				return r.mask(elem, all, depth+1)
+			})
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return v, false
+		}
+		var out reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			replaced, changed := r.mask(iter.Value(), all, depth+1)
+			if !changed {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.MakeMapWithSize(v.Type(), v.Len())
+				copied := v.MapRange()
+				for copied.Next() {
+					out.SetMapIndex(copied.Key(), copied.Value())
+				}
+			}
+			out.SetMapIndex(iter.Key(), replaced)
+		}
+		if out.IsValid() {
+			return out, true
+		}
+		return v, false
+	case reflect.Struct:
+		if !all {
+			var out reflect.Value
+			for i := 0; i < v.NumField(); i++ {
+				if !v.Type().Field(i).IsExported() {
+					continue
+				}
+				replaced, changed := r.mask(v.Field(i), false, depth+1)
+				if !changed {
+					continue
+				}
+				if !out.IsValid() {
+					out = reflect.New(v.Type()).Elem()
+					out.Set(v)
+				}
+				out.Field(i).Set(replaced)
+			}
+			if out.IsValid() {
+				return out, true
+			}
+			return v, false
+		}
+	}
+	if all && !v.IsZero() {
+		return reflect.Zero(v.Type()), true
+	}
+	return v, false
+}
+
+// fieldName 路径段名：mapstructure 标签，其次 json 标签，都没有时把字段名转为 kebab-case；
+// squash 与匿名嵌入的字段不增加路径段
+func fieldName(sf reflect.StructField) (string, bool) {
+	name, opts, _ := strings.Cut(sf.Tag.Get("mapstructure"), ",")
+	if strings.Contains(opts, "squash") {
+		return "", true
+	}
+	if name == "" || name == "-" {
+		name, _, _ = strings.Cut(sf.Tag.Get("json"), ",")
+	}
+	if name == "" || name == "-" {
+		if sf.Anonymous {
+			return "", true
+		}
+		name = kebab(sf.Name)
+	}
+	return name, false
+}
+
+// kebab 驼峰转 kebab-case：AccessKey → access-key，APIKey → api-key
+func kebab(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for i, c := range runes {
+		if unicode.IsUpper(c) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('-')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(c))
+	}
+	return sb.String()
+}
+
+// joinPath 拼接路径
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// matchAny 路径是否命中任一规则
+func matchAny(patterns [][]string, path string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	segments := strings.Split(strings.ToLower(path), ".")
+	for _, p := range patterns {
+		if matchSegments(p, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 逐段匹配，* 匹配一段，** 匹配零到多段
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || (pattern[0] != "*" && pattern[0] != segments[0]) {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,50 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remote_config.go
+ * @Description: Gateway 远程配置接入 - 构建前拉取远程配置到本地缓存，缓存文件作为配置文件加载；
+ *               轮询到新内容后手动触发重载，与编辑本地配置文件走同一套热更新回调
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"context"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/remoteconfig"
+)
+
+// loadRemoteConfig 创建远程配置客户端并完成首次拉取
+func (b *GatewayBuilder) loadRemoteConfig() (*remoteconfig.Client, error) {
+	client, err := remoteconfig.New(*b.remoteConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Signed() {
+		global.LOGGER.WarnKV("远程配置未启用签名校验，配置源被篡改时无法发现", "url", b.remoteConfig.URL)
+	}
+	if err := client.Load(b.Context()); err != nil {
+		return nil, err
+	}
+	global.LOGGER.InfoKV("远程配置已加载", "url", b.remoteConfig.URL, "cache", client.Path())
+	return client, nil
+}
+
+// startRemoteConfig 开始轮询远程配置
+func (g *Gateway) startRemoteConfig(client *remoteconfig.Client) {
+	if client == nil {
+		return
+	}
+	g.remoteConfig = client
+	client.Start(g.Context(), g.reloadRemoteConfig)
+}
+
+// reloadRemoteConfig 缓存文件已更新，手动触发重载；文件监听也可能触发一次，内容相同的重复重载不影响结果
+func (g *Gateway) reloadRemoteConfig(ctx context.Context) error {
+	return g.configManager.GetHotReloader().Reload(ctx)
+}
@@ -0,0 +1,113 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\http.go
+ * @Description: HTTP(S) 配置源 - 携带 If-None-Match 条件请求，304 表示配置未变化；
+ *               签名取自响应头，或配置地址加后缀的独立签名文件
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+)
+
+// maxConfigSize 配置文件大小上限
+const maxConfigSize = 16 << 20
+
+// httpSource HTTP(S) 配置源
+type httpSource struct {
+	url     string
+	sigURL  string // 独立签名文件地址，Suffix 为空时为空
+	headers map[string]string
+	sig     SignatureConfig
+	client  *http.Client
+}
+
+// newHTTPSource 创建 HTTP(S) 配置源，超时由调用方的 ctx 控制
+func newHTTPSource(u *url.URL, cfg Config) *httpSource {
+	sig := cfg.Signature
+	if sig.Header == "" {
+		sig.Header = DefaultSignatureHeader
+	}
+	s := &httpSource{url: u.String(), headers: cfg.Headers, sig: sig, client: &http.Client{}}
+	if sig.Suffix != "" {
+		sigURL := *u
+		sigURL.Path += sig.Suffix
+		sigURL.RawPath = ""
+		s.sigURL = sigURL.String()
+	}
+	return s
+}
+
+// Fetch 拉取配置，内容未变化时返回 nil
+func (s *httpSource) Fetch(ctx context.Context, etag string) (*Snapshot, error) {
+	resp, err := s.get(ctx, s.url, etag)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	content, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Content: content, ETag: resp.Header.Get(constants.HeaderETag), Signature: resp.Header.Get(s.sig.Header)}
+	if s.sigURL != "" {
+		sigResp, err := s.get(ctx, s.sigURL, "")
+		if err != nil {
+			return nil, err
+		}
+		defer sigResp.Body.Close()
+		signature, err := readBody(sigResp)
+		if err != nil {
+			return nil, fmt.Errorf("signature: %w", err)
+		}
+		snapshot.Signature = strings.TrimSpace(string(signature))
+	}
+	return snapshot, nil
+}
+
+// get 发送 GET 请求，etag 非空时携带 If-None-Match
+func (s *httpSource) get(ctx context.Context, target, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if etag != "" {
+		req.Header.Set(constants.HeaderIfNoneMatch, etag)
+	}
+	return s.client.Do(req)
+}
+
+// readBody 读取 200 响应体，其他状态码返回错误
+func readBody(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxConfigSize {
+		return nil, fmt.Errorf("config exceeds %d bytes", maxConfigSize)
+	}
+	return content, nil
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\owner_other.go
+ * @Description: 缓存文件属主检查 - 非 Unix 系统由 ACL 控制访问，不做检查
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import "os"
+
+// checkOwner 非 Unix 系统不检查属主
+func checkOwner(name string, info os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\owner_unix.go
+ * @Description: 缓存文件属主检查 - 类 Unix 系统要求属主为当前进程用户且组与其他用户不可写
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner 文件或目录须属于当前进程用户，且组与其他用户不可写
+func checkOwner(name string, info os.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is owned by uid %d, not the current user", name, st.Uid)
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s is writable by group or others (mode %v)", name, info.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\remoteconfig.go
+ * @Description: 远程配置源 - 从 HTTP(S) 地址或 S3/MinIO 对象拉取配置文件，校验签名后原子写入本地缓存文件，
+ *               网关按普通配置文件加载缓存；后台按 ETag 轮询，内容变化时触发热更新。
+ *               拉取失败时沿用上一次校验通过的缓存，远程源短暂不可用不影响启动；
+ *               签名随缓存保存在 .sig 文件中，沿用缓存前重新校验
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// 默认值
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultTimeout      = 10 * time.Second
+	DefaultFormat       = ".yaml"
+	// SignatureExt 缓存签名文件的后缀，与缓存文件位于同一目录
+	SignatureExt = ".sig"
+	// pollJitter 轮询间隔的随机抖动比例，避免大量实例同时请求配置源
+	pollJitter = 0.1
+)
+
+// Config 远程配置源
+type Config struct {
+	// URL 配置文件地址：http(s)://host/path/gateway.yaml 或 s3://bucket/key/gateway.yaml；
+	// 扩展名决定配置格式，没有扩展名时按 YAML 解析
+	URL     string
+	Headers map[string]string `sensitive:"true"` // HTTP 请求头，如 Authorization
+	S3      S3Config          // s3:// 地址的连接参数，MinIO 需设置 Endpoint 并开启 PathStyle
+	// CachePath 本地缓存文件，默认位于用户缓存目录（$XDG_CACHE_HOME 或 ~/.cache）；用户缓存目录不可用时，
+	// 未开启签名校验才退回系统临时目录，开启签名校验则必须显式设置。容器中建议挂载到持久卷以便远程源不可用时重启
+	CachePath string
+	// PollInterval 轮询间隔，默认 30s，小于 0 时只在启动时拉取一次
+	PollInterval time.Duration
+	Timeout      time.Duration // 单次拉取超时，默认 10s
+	Signature    SignatureConfig
+}
+
+// S3Config S3/MinIO 连接参数，AccessKey 为空时使用 AWS 默认凭证链（环境变量、实例角色等）
+type S3Config struct {
+	Endpoint     string // 如 http://minio:9000，为空时使用 AWS 区域端点
+	Region       string // 默认 us-east-1
+	AccessKey    string `sensitive:"true"`
+	SecretKey    string `sensitive:"true"`
+	SessionToken string `sensitive:"true"`
+	PathStyle    bool   // 路径样式访问，MinIO 需开启
+}
+
+// Snapshot 一次拉取的结果
+type Snapshot struct {
+	Content   []byte
+	ETag      string
+	Signature string // 随内容下发的签名，未下发时为空
+}
+
+// Source 配置源；etag 为上次拉取的 ETag，内容未变化时返回 nil Snapshot
+type Source interface {
+	Fetch(ctx context.Context, etag string) (*Snapshot, error)
+}
+
+// Client 远程配置客户端
+type Client struct {
+	config   Config
+	source   Source
+	verifier *verifier
+	format   string
+
+	mu       sync.Mutex
+	etag     string
+	checksum string // 缓存文件内容的 SHA-256
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New 创建远程配置客户端
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "remote config: invalid url %q", cfg.URL)
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	c := &Client{config: cfg, format: strings.ToLower(path.Ext(u.Path))}
+	if c.format == "" {
+		c.format = DefaultFormat
+	}
+	switch u.Scheme {
+	case "http", "https":
+		c.source = newHTTPSource(u, cfg)
+	case "s3":
+		if c.source, err = newS3Source(u, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "remote config: unsupported scheme %q, use http, https or s3", u.Scheme)
+	}
+	if c.verifier, err = newVerifier(cfg.Signature); err != nil {
+		return nil, err
+	}
+	if c.config.CachePath == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			// 系统临时目录所有用户可写，签名校验开启时不在其中保存缓存
+			if c.verifier != nil {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "remote config: no user cache dir (%v), set cache path explicitly", err)
+			}
+			dir = os.TempDir()
+		}
+		sum := sha256.Sum256([]byte(cfg.URL))
+		c.config.CachePath = filepath.Join(dir, "go-rpc-gateway", "remote-config", hex.EncodeToString(sum[:8])+c.format)
+	}
+	return c, nil
+}
+
+// Path 本地缓存文件路径，网关按该路径加载配置
+func (c *Client) Path() string {
+	return c.config.CachePath
+}
+
+// Signed 是否校验签名
+func (c *Client) Signed() bool {
+	return c.verifier != nil
+}
+
+// Load 启动时拉取配置并写入缓存；拉取或签名校验失败时，缓存文件可用则沿用缓存并记录警告，否则返回错误
+func (c *Client) Load(ctx context.Context) error {
+	if _, err := c.Sync(ctx); err != nil {
+		content, cacheErr := c.readCache()
+		if cacheErr != nil {
+			if !stderrors.Is(cacheErr, fs.ErrNotExist) {
+				global.LOGGER.WarnKV("本地缓存不可用", "cache", c.config.CachePath, "error", cacheErr)
+			}
+			return err
+		}
+		c.mu.Lock()
+		c.checksum = checksum(content)
+		c.mu.Unlock()
+		global.LOGGER.WarnKV("拉取远程配置失败，使用本地缓存", "url", c.config.URL, "cache", c.config.CachePath, "error", err)
+	}
+	return nil
+}
+
+// Sync 拉取一次配置，内容变化且签名校验通过时写入缓存并返回 true
+func (c *Client) Sync(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot, err := c.source.Fetch(ctx, c.etag)
+	if err != nil {
+		return false, errors.NewErrorf(errors.ErrCodeServiceUnavailable, "remote config %s: %v", c.config.URL, err)
+	}
+	if snapshot == nil {
+		return false, nil
+	}
+	if c.verifier != nil {
+		if err := c.verifier.verify(snapshot.Content, snapshot.Signature); err != nil {
+			return false, errors.NewErrorf(errors.ErrCodeSignatureInvalid, "remote config %s: %v", c.config.URL, err)
+		}
+	}
+	// 签名通过后才记录 ETag，签名错误的内容在下次轮询时重新拉取
+	c.etag = snapshot.ETag
+	sum := checksum(snapshot.Content)
+	if sum == c.checksum {
+		return false, nil
+	}
+	changed := true
+	if cached, err := os.ReadFile(c.config.CachePath); err == nil && bytes.Equal(cached, snapshot.Content) {
+		changed = false
+	} else if err := writeFile(c.config.CachePath, snapshot.Content); err != nil {
+		return false, err
+	}
+	// 内容未变化时也重写签名，旧版本留下的缓存没有签名文件
+	if c.verifier != nil {
+		if err := writeFile(c.signaturePath(), []byte(snapshot.Signature)); err != nil {
+			return false, err
+		}
+	}
+	c.checksum = sum
+	return changed, nil
+}
+
+// signaturePath 缓存签名文件路径
+func (c *Client) signaturePath() string {
+	return c.config.CachePath + SignatureExt
+}
+
+// readCache 读取本地缓存；开启签名校验时用缓存旁的签名文件重新校验，缓存目录可能被他人改写
+func (c *Client) readCache() ([]byte, error) {
+	content, err := readOwnedFile(c.config.CachePath)
+	if err != nil {
+		return nil, err
+	}
+	if c.verifier == nil {
+		return content, nil
+	}
+	signature, err := readOwnedFile(c.signaturePath())
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	if err := c.verifier.verify(content, string(signature)); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeSignatureInvalid, "remote config cache %s: %v", c.config.CachePath, err)
+	}
+	return content, nil
+}
+
+// Start 后台轮询，缓存更新后调用 onChange 触发热更新；PollInterval 小于 0 时不轮询
+func (c *Client) Start(ctx context.Context, onChange func(ctx context.Context) error) {
+	if c.config.PollInterval < 0 || c.cancel != nil {
+		return
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		for {
+			timer := time.NewTimer(jitter(c.config.PollInterval))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			changed, err := c.Sync(ctx)
+			if err != nil {
+				global.LOGGER.WarnKV("拉取远程配置失败，保留当前配置", "url", c.config.URL, "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			global.LOGGER.InfoKV("远程配置已更新", "url", c.config.URL, "cache", c.config.CachePath)
+			if err := onChange(ctx); err != nil {
+				global.LOGGER.WarnKV("远程配置热更新失败", "url", c.config.URL, "error", err)
+			}
+		}
+	}()
+}
+
+// Stop 停止轮询并等待正在进行的拉取结束
+func (c *Client) Stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+}
+
+// jitter 在间隔上叠加 ±10% 的随机抖动
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * pollJitter
+	return d + time.Duration((rand.Float64()*2-1)*delta)
+}
+
+// checksum 内容的 SHA-256
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readOwnedFile 读取当前进程所有、他人不可写的普通文件，拒绝符号链接
+func readOwnedFile(name string) ([]byte, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	if err := checkOwner(name, info); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 打开前后不是同一个文件，说明检查之后被替换
+	if opened, err := f.Stat(); err != nil || !os.SameFile(info, opened) {
+		return nil, fmt.Errorf("%s changed while opening", name)
+	}
+	return io.ReadAll(io.LimitReader(f, maxConfigSize+1))
+}
+
+// writeFile 以临时文件加重命名的方式写入缓存，配置可能含密钥，目录权限为 0700、文件权限为 0600；
+// 目录已存在但属于其他用户或他人可写时拒绝写入
+func writeFile(name string, content []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if err := checkOwner(dir, info); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
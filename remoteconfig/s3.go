@@ -0,0 +1,141 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\s3.go
+ * @Description: S3/MinIO 配置源 - s3://bucket/key 地址，GetObject 携带 IfNoneMatch，304 表示配置未变化；
+ *               签名取自对象元数据，或同一存储桶中加后缀的签名对象
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// defaultS3Region 未指定区域时使用，MinIO 不校验区域
+const defaultS3Region = "us-east-1"
+
+// s3Source S3/MinIO 配置源
+type s3Source struct {
+	cfg    S3Config
+	bucket string
+	key    string
+	sig    SignatureConfig
+	client *s3.Client // 首次拉取时创建，默认凭证链需要 ctx
+}
+
+// newS3Source 创建 S3/MinIO 配置源
+func newS3Source(u *url.URL, cfg Config) (*s3Source, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "remote config: %q has no object key, use s3://bucket/key", cfg.URL)
+	}
+	sig := cfg.Signature
+	if sig.Metadata == "" {
+		sig.Metadata = DefaultSignatureMetadata
+	}
+	return &s3Source{cfg: cfg.S3, bucket: u.Host, key: key, sig: sig}, nil
+}
+
+// Fetch 拉取配置对象，内容未变化时返回 nil
+func (s *s3Source) Fetch(ctx context.Context, etag string) (*Snapshot, error) {
+	if s.client == nil {
+		client, err := s.newClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+
+	input := &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key)}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	content, out, err := s.get(ctx, input)
+	if isNotModified(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Content: content, ETag: aws.ToString(out.ETag)}
+	for k, v := range out.Metadata {
+		if strings.EqualFold(k, s.sig.Metadata) {
+			snapshot.Signature = v
+		}
+	}
+	if s.sig.Suffix != "" {
+		signature, _, err := s.get(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key + s.sig.Suffix)})
+		if err != nil {
+			return nil, fmt.Errorf("signature: %w", err)
+		}
+		snapshot.Signature = strings.TrimSpace(string(signature))
+	}
+	return snapshot, nil
+}
+
+// get 读取对象内容
+func (s *s3Source) get(ctx context.Context, input *s3.GetObjectInput) ([]byte, *s3.GetObjectOutput, error) {
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer out.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(out.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(content) > maxConfigSize {
+		return nil, nil, fmt.Errorf("config exceeds %d bytes", maxConfigSize)
+	}
+	return content, out, nil
+}
+
+// newClient 创建 S3 客户端，AccessKey 为空时使用默认凭证链
+func (s *s3Source) newClient(ctx context.Context) (*s3.Client, error) {
+	region := s.cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if s.cfg.AccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			s.cfg.AccessKey,
+			s.cfg.SecretKey,
+			s.cfg.SessionToken,
+		)))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if s.cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s.cfg.Endpoint)
+		}
+		o.UsePathStyle = s.cfg.PathStyle
+	}), nil
+}
+
+// isNotModified IfNoneMatch 命中时 SDK 以 304 响应错误返回
+func isNotModified(err error) bool {
+	var re interface{ HTTPStatusCode() int }
+	return err != nil && stderrors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotModified
+}
@@ -0,0 +1,105 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\remoteconfig\signature.go
+ * @Description: 远程配置签名校验 - 对配置文件原文计算 HMAC-SHA256 或 Ed25519 签名；
+ *               Ed25519 只需在实例上分发公钥，配置源被篡改也无法伪造签名
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package remoteconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// 签名默认位置
+const (
+	DefaultSignatureHeader   = "X-Config-Signature" // HTTP 响应头
+	DefaultSignatureMetadata = "signature"          // S3 对象元数据（x-amz-meta-signature）
+)
+
+// SignatureConfig 签名校验，HMACSecret 与 PublicKey 都为空时不校验
+type SignatureConfig struct {
+	HMACSecret string `sensitive:"true"` // HMAC-SHA256 共享密钥
+	PublicKey  string // Ed25519 公钥，base64 或 hex 编码
+	// Header 携带签名的 HTTP 响应头，默认 X-Config-Signature
+	Header string
+	// Metadata 携带签名的 S3 对象元数据键，默认 signature
+	Metadata string
+	// Suffix 独立签名文件的后缀，如 .sig；设置后从配置地址加后缀处读取签名，不再读取响应头或元数据
+	Suffix string
+}
+
+// verifier 签名校验器
+type verifier struct {
+	hmacSecret []byte
+	publicKey  ed25519.PublicKey
+}
+
+// newVerifier 创建签名校验器，未配置密钥时返回 nil
+func newVerifier(cfg SignatureConfig) (*verifier, error) {
+	if cfg.HMACSecret == "" && cfg.PublicKey == "" {
+		return nil, nil
+	}
+	if cfg.HMACSecret != "" && cfg.PublicKey != "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "remote config: set either hmac secret or public key, not both")
+	}
+	v := &verifier{hmacSecret: []byte(cfg.HMACSecret)}
+	if cfg.PublicKey != "" {
+		key, err := decodeBytes(cfg.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "remote config: public key must be a %d-byte ed25519 key in base64 or hex", ed25519.PublicKeySize)
+		}
+		v.publicKey = key
+	}
+	return v, nil
+}
+
+// verify 校验签名，签名为 base64 或 hex 编码
+func (v *verifier) verify(content []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("signature is missing")
+	}
+	sig, err := decodeBytes(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64 or hex: %w", err)
+	}
+	if v.publicKey != nil {
+		if !ed25519.Verify(v.publicKey, content, sig) {
+			return fmt.Errorf("ed25519 signature mismatch")
+		}
+		return nil
+	}
+	mac := hmac.New(sha256.New, v.hmacSecret)
+	mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return fmt.Errorf("hmac signature mismatch")
+	}
+	return nil
+}
+
+// decodeBytes 解码 hex 或 base64（标准与 URL 编码，允许省略填充）
+func decodeBytes(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid encoding")
+}
@@ -0,0 +1,218 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\resource\audit.go
+ * @Description: 审计列约定 - deleted_at 软删除、created_by / updated_by / deleted_by 取自请求身份、
+ *               version 乐观锁，以及与变更同事务写入的操作日志（Oplog）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 约定列名，模型中存在同名列时自动生效
+const (
+	ColumnCreatedBy = "created_by" // 创建人，创建时填入 Principal.Subject
+	ColumnUpdatedBy = "updated_by" // 最后修改人，创建与更新时填入
+	ColumnDeletedBy = "deleted_by" // 删除人，软删除时填入
+	ColumnDeletedAt = "deleted_at" // 软删除时间，非 gorm.DeletedAt 类型时由资源层过滤 IS NULL
+	ColumnVersion   = "version"    // 乐观锁版本号（整数），创建为 1，每次更新加 1
+)
+
+// 操作日志动作
+const (
+	OplogActionCreate = "create"
+	OplogActionUpdate = "update"
+	OplogActionDelete = "delete"
+)
+
+// Oplog 操作日志记录
+type Oplog struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	Resource   string    `gorm:"size:128;index:idx_oplog_resource" json:"resource"`   // 资源表名
+	ResourceID string    `gorm:"size:64;index:idx_oplog_resource" json:"resource_id"` // 记录主键
+	Action     string    `gorm:"size:16" json:"action"`                               // create / update / delete
+	Operator   string    `gorm:"size:128;index" json:"operator"`                      // 操作人（Principal.Subject）
+	RequestID  string    `gorm:"size:64" json:"request_id"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"` // 变更前记录 JSON，创建时为空
+	After      string    `gorm:"type:text" json:"after,omitempty"`  // 变更后记录 JSON，删除时为空
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OplogWriter 写入操作日志，tx 为变更所在事务，返回错误时整个变更回滚
+type OplogWriter func(tx *gorm.DB, log *Oplog) error
+
+// OplogNew 默认写入器，插入 Oplog 表（表名 oplogs，需预先迁移）
+func OplogNew(tx *gorm.DB, log *Oplog) error {
+	return tx.Create(log).Error
+}
+
+// auditFields 模型中按约定识别出的审计列
+type auditFields struct {
+	createdBy, updatedBy, deletedBy *schema.Field
+	deletedAt                       *schema.Field
+	nativeSoftDelete                bool // deleted_at 为 gorm.DeletedAt，查询过滤与删除由 GORM 处理
+	version                         *schema.Field
+}
+
+// newAuditFields 按约定列名识别审计列，version 需为整数类型
+func newAuditFields(s *schema.Schema) auditFields {
+	a := auditFields{
+		createdBy: s.LookUpField(ColumnCreatedBy),
+		updatedBy: s.LookUpField(ColumnUpdatedBy),
+		deletedBy: s.LookUpField(ColumnDeletedBy),
+		deletedAt: s.LookUpField(ColumnDeletedAt),
+		version:   s.LookUpField(ColumnVersion),
+	}
+	if a.deletedAt != nil {
+		a.nativeSoftDelete = a.deletedAt.FieldType == reflect.TypeOf(gorm.DeletedAt{})
+	}
+	if a.version != nil {
+		switch a.version.FieldType.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		default:
+			a.version = nil
+		}
+	}
+	return a
+}
+
+// protected 由资源层维护、不接受请求写入的列
+func (a *auditFields) protected(f *schema.Field) bool {
+	return f == a.createdBy || f == a.updatedBy || f == a.deletedBy || f == a.deletedAt
+}
+
+// operator 当前请求的操作人
+func operator(ctx context.Context) string {
+	if p, ok := middleware.PrincipalFromContext(ctx); ok && !p.IsAnonymous() {
+		return p.Subject
+	}
+	return ""
+}
+
+// stamp 填充操作人列；created 为 true 时先清空请求中传入的审计列，再填充创建人并把版本号置为 1
+func (r *Resource) stamp(ctx context.Context, model any, created bool) *errors.AppError {
+	rv := reflect.ValueOf(model).Elem()
+	who := operator(ctx)
+	fields := []*schema.Field{r.audit.updatedBy}
+	if created {
+		for _, f := range r.schema.Fields {
+			if r.audit.protected(f) {
+				f.ReflectValueOf(ctx, rv).SetZero()
+			}
+		}
+		fields = append(fields, r.audit.createdBy)
+		if v := r.audit.version; v != nil {
+			if err := v.Set(ctx, rv, 1); err != nil {
+				return errors.NewErrorf(errors.ErrCodeInternalServerError, "set %s: %v", v.DBName, err)
+			}
+		}
+	}
+	for _, f := range fields {
+		if f == nil || who == "" {
+			continue
+		}
+		if err := f.Set(ctx, rv, who); err != nil {
+			return errors.NewErrorf(errors.ErrCodeInternalServerError, "set %s: %v", f.DBName, err)
+		}
+	}
+	return nil
+}
+
+// expectedVersion 客户端声明的版本号：优先取 If-Match 头，其次取 supplied 中的版本列；
+// 模型没有版本列时返回 0
+func (r *Resource) expectedVersion(ctx context.Context, ifMatch string, supplied any) (int64, *errors.AppError) {
+	v := r.audit.version
+	if v == nil {
+		return 0, nil
+	}
+	if ifMatch = strings.Trim(strings.TrimPrefix(strings.TrimSpace(ifMatch), "W/"), `"`); ifMatch != "" {
+		n, err := strconv.ParseInt(ifMatch, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid %s header %q", constants.HeaderIfMatch, ifMatch)
+		}
+		return n, nil
+	}
+	if supplied != nil {
+		if n := versionOf(ctx, v, supplied); n > 0 {
+			return n, nil
+		}
+	}
+	return 0, errors.NewErrorf(errors.ErrCodeInvalidParameter, "%s is required in the request body or %s header", jsonName(v), constants.HeaderIfMatch)
+}
+
+// versionOf 读取模型的版本号
+func versionOf(ctx context.Context, v *schema.Field, model any) int64 {
+	rv := v.ReflectValueOf(ctx, reflect.ValueOf(model).Elem())
+	if rv.CanInt() {
+		return rv.Int()
+	}
+	return int64(rv.Uint())
+}
+
+// softDeleteColumns 软删除写入的列：删除时间与删除人
+func (r *Resource) softDeleteColumns(ctx context.Context) (map[string]any, *errors.AppError) {
+	rv := reflect.New(r.modelType).Elem()
+	cols := make(map[string]any, 2)
+	for f, value := range map[*schema.Field]any{r.audit.deletedAt: time.Now(), r.audit.deletedBy: operator(ctx)} {
+		if f == nil || value == "" {
+			continue
+		}
+		if err := f.Set(ctx, rv, value); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "set %s: %v", f.DBName, err)
+		}
+		cols[f.DBName], _ = f.ValueOf(ctx, rv)
+	}
+	return cols, nil
+}
+
+// snapshot 记录的 JSON 快照（不含 json:"-" 字段），未配置操作日志时返回空
+func (r *Resource) snapshot(model any) string {
+	if r.config.Oplog == nil || model == nil {
+		return ""
+	}
+	b, err := json.Marshal(model)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// newOplog 构造操作日志
+func (r *Resource) newOplog(ctx context.Context, action, resourceID, before, after string) *Oplog {
+	return &Oplog{
+		Resource:   r.schema.Table,
+		ResourceID: resourceID,
+		Action:     action,
+		Operator:   operator(ctx),
+		RequestID:  middleware.GetRequestID(ctx),
+		Before:     before,
+		After:      after,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// jsonName 字段对外名称
+func jsonName(f *schema.Field) string {
+	if name := jsonFieldName(f); name != "" {
+		return name
+	}
+	return f.DBName
+}
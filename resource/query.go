@@ -0,0 +1,172 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\resource\query.go
+ * @Description: 列表查询参数 - 分页（page / size）、排序（sort=-price,name）与字段过滤（price__gte=10），
+ *               字段名按模型列校验后才拼入 SQL
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package resource
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 保留的查询参数
+const (
+	QueryPage = "page"
+	QuerySize = "size"
+	QuerySort = "sort"
+)
+
+// filterOperators 过滤后缀与对应的 SQL 运算符
+var filterOperators = map[string]string{
+	"":     "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "LIKE",
+	"in":   "IN",
+}
+
+// Paging 分页信息
+type Paging struct {
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+// ListResult 列表响应
+type ListResult struct {
+	Items  any     `json:"items"`
+	Paging *Paging `json:"paging"`
+}
+
+// listQuery 解析后的列表查询
+type listQuery struct {
+	Page, Size int
+	Sort       []clause.OrderByColumn
+	Filters    []clause.Expression
+}
+
+// columnSet 可按名称查找的列，键为数据库列名与 JSON 字段名
+type columnSet map[string]*schema.Field
+
+// parseListQuery 解析列表查询参数；filterable / sortable 为空时不允许对应操作
+func parseListQuery(values url.Values, filterable, sortable columnSet, defaultSize, maxSize int) (*listQuery, *errors.AppError) {
+	q := &listQuery{Page: 1, Size: defaultSize}
+	var err error
+	if raw := values.Get(QueryPage); raw != "" {
+		if q.Page, err = strconv.Atoi(raw); err != nil || q.Page < 1 {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "page must be a positive integer")
+		}
+	}
+	if raw := values.Get(QuerySize); raw != "" {
+		if q.Size, err = strconv.Atoi(raw); err != nil || q.Size < 1 || q.Size > maxSize {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "size must be between 1 and %d", maxSize)
+		}
+	}
+
+	if raw := values.Get(QuerySort); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			field, ok := sortable[strings.TrimPrefix(name, "-")]
+			if !ok {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cannot sort by %q", name)
+			}
+			q.Sort = append(q.Sort, clause.OrderByColumn{Column: clause.Column{Name: field.DBName}, Desc: desc})
+		}
+	}
+
+	for key, vals := range values {
+		if key == QueryPage || key == QuerySize || key == QuerySort {
+			continue
+		}
+		name, op, _ := strings.Cut(key, "__")
+		field, ok := filterable[name]
+		sqlOp, known := filterOperators[op]
+		if !ok || !known {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unsupported filter %q", key)
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		for _, v := range vals {
+			switch op {
+			case "in":
+				items := strings.Split(v, ",")
+				args := make([]any, len(items))
+				for i, item := range items {
+					args[i] = item
+				}
+				q.Filters = append(q.Filters, clause.IN{Column: column, Values: args})
+			case "like":
+				q.Filters = append(q.Filters, clause.Like{Column: column, Value: "%" + v + "%"})
+			default:
+				q.Filters = append(q.Filters, clause.Expr{SQL: "? " + sqlOp + " ?", Vars: []any{column, v}})
+			}
+		}
+	}
+	return q, nil
+}
+
+// apply 把过滤条件应用到查询（计数与列表共用）
+func (q *listQuery) apply(db *gorm.DB) *gorm.DB {
+	if len(q.Filters) > 0 {
+		db = db.Clauses(clause.Where{Exprs: q.Filters})
+	}
+	return db
+}
+
+// paginate 应用排序与分页
+func (q *listQuery) paginate(db *gorm.DB) *gorm.DB {
+	if len(q.Sort) > 0 {
+		db = db.Order(clause.OrderBy{Columns: q.Sort})
+	}
+	return db.Offset((q.Page - 1) * q.Size).Limit(q.Size)
+}
+
+// newColumnSet 收集模型中对外可见的列（json:"-" 的字段不能过滤或排序）；names 不为空时只保留其中列出的列
+func newColumnSet(s *schema.Schema, names []string) columnSet {
+	all := make(columnSet, len(s.Fields)*2)
+	for _, f := range s.Fields {
+		if f.DBName == "" || !f.Readable || f.StructField.Tag.Get("json") == "-" {
+			continue
+		}
+		all[f.DBName] = f
+		if jsonName := jsonFieldName(f); jsonName != "" {
+			all[jsonName] = f
+		}
+	}
+	if len(names) == 0 {
+		return all
+	}
+	allowed := make(columnSet, len(names)*2)
+	for _, name := range names {
+		if f, ok := all[name]; ok {
+			allowed[f.DBName] = f
+			if jsonName := jsonFieldName(f); jsonName != "" {
+				allowed[jsonName] = f
+			}
+		}
+	}
+	return allowed
+}
+
+// jsonFieldName 字段 json 标签中的名称
+func jsonFieldName(f *schema.Field) string {
+	name, _, _ := strings.Cut(f.StructField.Tag.Get("json"), ",")
+	return name
+}
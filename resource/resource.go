@@ -0,0 +1,575 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\resource\resource.go
+ * @Description: CRUD 资源 - 根据 GORM 模型生成列表 / 详情 / 创建 / 替换 / 局部更新 / 删除接口，
+ *               请求体按 Content-Type 绑定并校验，可选通过 go-pbmo 以 PB 类型作为请求与响应结构
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package resource 为 GORM 模型生成标准 CRUD HTTP 接口，减少逐个实体编写样板代码
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	gopbmo "github.com/kamalyes/go-pbmo"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 分页默认值
+const (
+	DefaultPageSize = 20
+	DefaultMaxSize  = 100
+)
+
+// Config 资源配置
+type Config struct {
+	DB          *gorm.DB                                    // 数据库连接，为空时在请求时使用 global.GetDB()
+	PB          any                                         // 可选 PB 类型（如 &pb.Product{}），设置后请求体绑定到 PB、响应以 PB 输出，经 go-pbmo 与模型互转
+	ReadOnly    bool                                        // 只生成列表与详情接口
+	Filterable  []string                                    // 允许过滤的列（数据库列名或 JSON 字段名），为空时允许所有对外可见的列
+	Sortable    []string                                    // 允许排序的列，规则同 Filterable
+	PageSize    int                                         // 默认每页条数，默认 20
+	MaxPageSize int                                         // 每页条数上限，默认 100
+	Scope       func(r *http.Request, db *gorm.DB) *gorm.DB // 所有查询、更新与删除附加的条件（如按租户或所有者过滤）
+	BeforeSave  func(r *http.Request, model any) error      // 创建与更新写库前调用，可填充所有者等字段，返回错误时中止
+	Oplog       OplogWriter                                 // 设置后创建、更新、删除与操作日志在同一事务内写入，默认实现为 OplogNew
+}
+
+// Resource 一个模型的 CRUD 处理器，路径为 {prefix} 与 {prefix}/{id}
+type Resource struct {
+	prefix    string
+	config    Config
+	modelType reflect.Type // 模型结构体类型
+	pbType    reflect.Type // PB 结构体类型，未配置时为 nil
+	converter *gopbmo.BidiConverter
+
+	once       sync.Once
+	schemaErr  error
+	schema     *schema.Schema
+	filterable columnSet
+	sortable   columnSet
+	audit      auditFields
+}
+
+// New 创建资源处理器，model 为模型指针（如 &Product{}），需要有单一主键
+func New(prefix string, model any, cfg Config) (*Resource, error) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	t := reflect.TypeOf(model)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "resource %s model must be a pointer to struct, got %T", prefix, model)
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = DefaultPageSize
+	}
+	if cfg.MaxPageSize <= 0 {
+		cfg.MaxPageSize = DefaultMaxSize
+	}
+	if cfg.PageSize > cfg.MaxPageSize {
+		cfg.PageSize = cfg.MaxPageSize
+	}
+
+	r := &Resource{prefix: prefix, config: cfg, modelType: t.Elem()}
+	if cfg.PB != nil {
+		pt := reflect.TypeOf(cfg.PB)
+		if pt.Kind() != reflect.Pointer || pt.Elem().Kind() != reflect.Struct {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "resource %s PB must be a pointer to struct, got %T", prefix, cfg.PB)
+		}
+		r.pbType = pt.Elem()
+		r.converter = gopbmo.NewBidiConverter(cfg.PB, model)
+	}
+	return r, nil
+}
+
+// Prefix 资源路径前缀
+func (r *Resource) Prefix() string {
+	return r.prefix
+}
+
+// ServeHTTP 按方法与路径分发：
+//
+//	GET    {prefix}        列表，支持 page / size / sort 与字段过滤
+//	POST   {prefix}        创建
+//	GET    {prefix}/{id}   详情
+//	PUT    {prefix}/{id}   替换
+//	PATCH  {prefix}/{id}   局部更新（JSON，仅更新请求中出现的字段）
+//	DELETE {prefix}/{id}   删除
+func (r *Resource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	response.Handle(r.handle, nil).ServeHTTP(w, req)
+}
+
+// handle 分发请求
+func (r *Resource) handle(c *response.Context) error {
+	db, appErr := r.db(c.Request)
+	if appErr != nil {
+		return appErr
+	}
+
+	id := strings.Trim(strings.TrimPrefix(c.Request.URL.Path, r.prefix), "/")
+	method := c.Request.Method
+	if r.config.ReadOnly && method != http.MethodGet && method != http.MethodHead {
+		return errors.NewErrorf(errors.ErrCodeMethodNotAllowed, "%s is read-only", r.prefix)
+	}
+	switch {
+	case strings.Contains(id, "/"):
+		return errors.NewError(errors.ErrCodeNotFound, "resource not found")
+	case id == "" && (method == http.MethodGet || method == http.MethodHead):
+		return r.list(c, db)
+	case id == "" && method == http.MethodPost:
+		return r.create(c, db)
+	case id == "":
+	case method == http.MethodGet || method == http.MethodHead:
+		return r.get(c, db, id)
+	case method == http.MethodPut:
+		return r.replace(c, db, id)
+	case method == http.MethodPatch:
+		return r.patch(c, db, id)
+	case method == http.MethodDelete:
+		return r.delete(c, db, id)
+	}
+	return errors.NewErrorf(errors.ErrCodeMethodNotAllowed, "method %s is not allowed", method)
+}
+
+// db 请求使用的连接：附加请求上下文与 Scope，首次使用时解析模型结构
+func (r *Resource) db(req *http.Request) (*gorm.DB, *errors.AppError) {
+	db := r.config.DB
+	if db == nil {
+		db = global.GetDB()
+	}
+	if db == nil {
+		return nil, errors.NewError(errors.ErrCodeServiceUnavailable, "database is not initialized")
+	}
+	r.once.Do(func() { r.parseSchema(db) })
+	if r.schemaErr != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "resource %s: %v", r.prefix, r.schemaErr)
+	}
+	db = db.WithContext(req.Context())
+	if r.config.Scope != nil {
+		db = r.config.Scope(req, db)
+	}
+	if f := r.audit.deletedAt; f != nil && !r.audit.nativeSoftDelete {
+		db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: nil})
+	}
+	// 新会话保证后续链式调用各自复制条件，查询与写库互不影响
+	return db.Session(&gorm.Session{}), nil
+}
+
+// parseSchema 解析模型结构，要求单一主键
+func (r *Resource) parseSchema(db *gorm.DB) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(r.modelType).Interface()); err != nil {
+		r.schemaErr = err
+		return
+	}
+	if len(stmt.Schema.PrimaryFields) != 1 {
+		r.schemaErr = stderrors.New("model must have exactly one primary key")
+		return
+	}
+	r.schema = stmt.Schema
+	r.filterable = newColumnSet(stmt.Schema, r.config.Filterable)
+	r.sortable = newColumnSet(stmt.Schema, r.config.Sortable)
+	r.audit = newAuditFields(stmt.Schema)
+}
+
+// list 列表
+func (r *Resource) list(c *response.Context, db *gorm.DB) error {
+	q, appErr := parseListQuery(c.Request.URL.Query(), r.filterable, r.sortable, r.config.PageSize, r.config.MaxPageSize)
+	if appErr != nil {
+		return appErr
+	}
+	db = q.apply(db.Model(reflect.New(r.modelType).Interface()))
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return dbError(err)
+	}
+	items := reflect.New(reflect.SliceOf(reflect.PointerTo(r.modelType)))
+	if err := q.paginate(db).Find(items.Interface()).Error; err != nil {
+		return dbError(err)
+	}
+	out, appErr := r.output(items.Elem())
+	if appErr != nil {
+		return appErr
+	}
+	return c.Respond(&ListResult{Items: out, Paging: &Paging{Page: q.Page, Size: q.Size, Total: total}})
+}
+
+// get 详情
+func (r *Resource) get(c *response.Context, db *gorm.DB, id string) error {
+	model, appErr := r.find(c.Context(), db, id)
+	if appErr != nil {
+		return appErr
+	}
+	return r.respond(c, http.StatusOK, model)
+}
+
+// create 创建，自增主键忽略请求中的值
+func (r *Resource) create(c *response.Context, db *gorm.DB) error {
+	model, appErr := r.bind(c, true)
+	if appErr != nil {
+		return appErr
+	}
+	if pk := r.primaryField(); pk.AutoIncrement {
+		pk.ReflectValueOf(c.Context(), reflect.ValueOf(model).Elem()).SetZero()
+	}
+	if appErr := r.stamp(c.Context(), model, true); appErr != nil {
+		return appErr
+	}
+	if appErr := r.beforeSave(c.Request, model); appErr != nil {
+		return appErr
+	}
+	appErr = r.write(c, db, OplogActionCreate, model, "", func(tx *gorm.DB) error {
+		return tx.Create(model).Error
+	})
+	if appErr != nil {
+		return appErr
+	}
+	if id, zero := r.primaryField().ValueOf(c.Context(), reflect.ValueOf(model).Elem()); !zero {
+		c.Writer.Header().Set(constants.HeaderLocation, r.prefix+"/"+toString(id))
+	}
+	return r.respond(c, http.StatusCreated, model)
+}
+
+// replace 整体替换，保留主键、自动创建时间、审计列与不对外输出（json:"-"）的字段
+func (r *Resource) replace(c *response.Context, db *gorm.DB, id string) error {
+	existing, appErr := r.find(c.Context(), db, id)
+	if appErr != nil {
+		return appErr
+	}
+	model, appErr := r.bind(c, true)
+	if appErr != nil {
+		return appErr
+	}
+	version, appErr := r.expectedVersion(c.Context(), c.Request.Header.Get(constants.HeaderIfMatch), model)
+	if appErr != nil {
+		return appErr
+	}
+	src, dst := reflect.ValueOf(existing).Elem(), reflect.ValueOf(model).Elem()
+	for _, f := range r.schema.Fields {
+		if f.PrimaryKey || f.AutoCreateTime != 0 || r.audit.protected(f) || f.StructField.Tag.Get("json") == "-" {
+			f.ReflectValueOf(c.Context(), dst).Set(f.ReflectValueOf(c.Context(), src))
+		}
+	}
+	return r.update(c, db, model, version, r.snapshot(existing))
+}
+
+// patch 局部更新：请求中出现的字段覆盖到现有记录后整体校验
+func (r *Resource) patch(c *response.Context, db *gorm.DB, id string) error {
+	existing, appErr := r.find(c.Context(), db, id)
+	if appErr != nil {
+		return appErr
+	}
+	before := r.snapshot(existing)
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, response.DefaultBindOptions().MaxBodySize))
+	if err != nil {
+		return errors.ErrRequestTooLarge
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return errors.NewErrorf(errors.ErrCodeBadRequest, "patch body must be a JSON object: %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.Header.Set(constants.HeaderContentType, "application/json")
+	patch, appErr := r.bind(c, false)
+	if appErr != nil {
+		return appErr
+	}
+
+	// 版本号只作为前置条件，不直接覆盖
+	var supplied any
+	src, dst := reflect.ValueOf(patch).Elem(), reflect.ValueOf(existing).Elem()
+	for key := range keys {
+		f := r.patchField(key)
+		switch {
+		case f == nil:
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "field %q cannot be updated", key)
+		case f == r.audit.version:
+			supplied = patch
+		default:
+			f.ReflectValueOf(c.Context(), dst).Set(f.ReflectValueOf(c.Context(), src))
+		}
+	}
+	version, appErr := r.expectedVersion(c.Context(), c.Request.Header.Get(constants.HeaderIfMatch), supplied)
+	if appErr != nil {
+		return appErr
+	}
+	if err := middleware.ValidateStruct(existing); err != nil {
+		return err
+	}
+	return r.update(c, db, existing, version, before)
+}
+
+// delete 删除：模型含 deleted_at 列时为软删除并记录删除人，携带 If-Match 时校验版本号
+func (r *Resource) delete(c *response.Context, db *gorm.DB, id string) error {
+	ctx := c.Context()
+	key, appErr := r.primaryKey(ctx, id)
+	if appErr != nil {
+		return appErr
+	}
+	var before string
+	if r.config.Oplog != nil {
+		existing, appErr := r.find(ctx, db, id)
+		if appErr != nil {
+			return appErr
+		}
+		before = r.snapshot(existing)
+	}
+	db = db.Where(map[string]any{r.primaryField().DBName: key})
+	ifMatch := c.Request.Header.Get(constants.HeaderIfMatch)
+	versioned := ifMatch != "" && r.audit.version != nil
+	if versioned {
+		version, appErr := r.expectedVersion(ctx, ifMatch, nil)
+		if appErr != nil {
+			return appErr
+		}
+		db = db.Where(map[string]any{r.audit.version.DBName: version})
+	}
+
+	model := reflect.New(r.modelType).Interface()
+	if err := r.primaryField().Set(ctx, reflect.ValueOf(model).Elem(), key); err != nil {
+		return errors.NewErrorf(errors.ErrCodeInternalServerError, "set primary key: %v", err)
+	}
+	appErr = r.write(c, db, OplogActionDelete, model, before, func(tx *gorm.DB) error {
+		var res *gorm.DB
+		if r.audit.deletedAt != nil {
+			cols, appErr := r.softDeleteColumns(ctx)
+			if appErr != nil {
+				return appErr
+			}
+			res = tx.Model(model).UpdateColumns(cols)
+		} else {
+			res = tx.Delete(model)
+		}
+		if res.Error == nil && res.RowsAffected == 0 {
+			return missing(versioned)
+		}
+		return res.Error
+	})
+	if appErr != nil {
+		return appErr
+	}
+	c.Writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// update 按主键整行更新；有版本列时附加版本条件并加 1，未命中视为版本冲突
+func (r *Resource) update(c *response.Context, db *gorm.DB, model any, version int64, before string) error {
+	ctx := c.Context()
+	if appErr := r.stamp(ctx, model, false); appErr != nil {
+		return appErr
+	}
+	if appErr := r.beforeSave(c.Request, model); appErr != nil {
+		return appErr
+	}
+	if v := r.audit.version; v != nil {
+		db = db.Where(map[string]any{v.DBName: version})
+		if err := v.Set(ctx, reflect.ValueOf(model).Elem(), version+1); err != nil {
+			return errors.NewErrorf(errors.ErrCodeInternalServerError, "set %s: %v", v.DBName, err)
+		}
+	}
+	// 不使用 Save：记录被并发删除时 Save 会退化为插入
+	appErr := r.write(c, db, OplogActionUpdate, model, before, func(tx *gorm.DB) error {
+		res := tx.Model(model).Select("*").Updates(model)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return missing(r.audit.version != nil)
+		}
+		return res.Error
+	})
+	if appErr != nil {
+		return appErr
+	}
+	return r.respond(c, http.StatusOK, model)
+}
+
+// write 执行写操作；配置操作日志时与日志在同一事务内提交
+func (r *Resource) write(c *response.Context, db *gorm.DB, action string, model any, before string, fn func(tx *gorm.DB) error) *errors.AppError {
+	if r.config.Oplog == nil {
+		return writeError(fn(db))
+	}
+	return writeError(db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		id, _ := r.primaryField().ValueOf(c.Context(), reflect.ValueOf(model).Elem())
+		var after string
+		if action != OplogActionDelete {
+			after = r.snapshot(model)
+		}
+		return r.config.Oplog(tx, r.newOplog(c.Context(), action, toString(id), before, after))
+	}))
+}
+
+// missing 写操作未命中记录：附加了版本条件时为版本冲突，否则为 404
+func missing(versioned bool) *errors.AppError {
+	if versioned {
+		return errors.NewError(errors.ErrCodeConflict, "resource has been modified, reload and retry")
+	}
+	return errors.NewError(errors.ErrCodeNotFound, "resource not found")
+}
+
+// find 按主键查询（附加 Scope），不存在时返回 404
+func (r *Resource) find(ctx context.Context, db *gorm.DB, id string) (any, *errors.AppError) {
+	key, appErr := r.primaryKey(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	model := reflect.New(r.modelType).Interface()
+	err := db.Where(map[string]any{r.primaryField().DBName: key}).Take(model).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewError(errors.ErrCodeNotFound, "resource not found")
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return model, nil
+}
+
+// bind 绑定请求体到 PB 或模型并转换为模型
+func (r *Resource) bind(c *response.Context, validate bool) (any, *errors.AppError) {
+	opts := response.DefaultBindOptions()
+	opts.Validate = validate
+	target := r.modelType
+	if r.pbType != nil {
+		target = r.pbType
+	}
+	dto := reflect.New(target).Interface()
+	if err := response.BindRequest(c.Writer, c.Request, dto, opts); err != nil {
+		return nil, toAppError(err)
+	}
+	if r.converter == nil {
+		return dto, nil
+	}
+	model := reflect.New(r.modelType).Interface()
+	if err := r.converter.ConvertPBToModel(dto, model); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "convert request: %v", err)
+	}
+	return model, nil
+}
+
+// respond 输出单条记录，有版本列时附带 ETag
+func (r *Resource) respond(c *response.Context, status int, model any) error {
+	if v := r.audit.version; v != nil {
+		c.Writer.Header().Set(constants.HeaderETag, strconv.Quote(strconv.FormatInt(versionOf(c.Context(), v, model), 10)))
+	}
+	if r.converter == nil {
+		return c.RespondStatus(status, model)
+	}
+	pb := reflect.New(r.pbType).Interface()
+	if err := r.converter.ConvertModelToPB(model, pb); err != nil {
+		return errors.NewErrorf(errors.ErrCodeInternalServerError, "convert response: %v", err)
+	}
+	return c.RespondStatus(status, pb)
+}
+
+// output 列表结果，配置 PB 时批量转换
+func (r *Resource) output(models reflect.Value) (any, *errors.AppError) {
+	if r.converter == nil {
+		return models.Interface(), nil
+	}
+	pbs := reflect.New(reflect.SliceOf(reflect.PointerTo(r.pbType)))
+	if err := r.converter.BatchConvertModelToPB(models.Interface(), pbs.Interface()); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "convert response: %v", err)
+	}
+	return pbs.Elem().Interface(), nil
+}
+
+// beforeSave 调用写库前钩子
+func (r *Resource) beforeSave(req *http.Request, model any) *errors.AppError {
+	if r.config.BeforeSave == nil {
+		return nil
+	}
+	if err := r.config.BeforeSave(req, model); err != nil {
+		return toAppError(err)
+	}
+	return nil
+}
+
+// primaryKey 将路径中的 id 转换为主键字段类型，无法转换时视为不存在
+func (r *Resource) primaryKey(ctx context.Context, id string) (any, *errors.AppError) {
+	pk := r.primaryField()
+	v := reflect.New(r.modelType).Elem()
+	if err := pk.Set(ctx, v, id); err != nil {
+		return nil, errors.NewError(errors.ErrCodeNotFound, "resource not found")
+	}
+	return pk.ReflectValueOf(ctx, v).Interface(), nil
+}
+
+// primaryField 主键字段
+func (r *Resource) primaryField() *schema.Field {
+	return r.schema.PrimaryFields[0]
+}
+
+// patchField 局部更新请求中的键对应的可更新字段；键与字段名、列名、JSON 名比较时忽略大小写与下划线，
+// 以兼容 PB 的 lowerCamelCase JSON 名
+func (r *Resource) patchField(key string) *schema.Field {
+	norm := normalizeName(key)
+	for _, f := range r.schema.Fields {
+		if f.DBName == "" || !f.Updatable || f.PrimaryKey || f.AutoCreateTime != 0 || r.audit.protected(f) || f.StructField.Tag.Get("json") == "-" {
+			continue
+		}
+		if norm == normalizeName(f.Name) || norm == normalizeName(f.DBName) || norm == normalizeName(jsonFieldName(f)) {
+			return f
+		}
+	}
+	return nil
+}
+
+// normalizeName 去掉下划线并转小写
+func normalizeName(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+}
+
+// dbError 数据库错误转换：唯一键冲突为 409，其余为 500
+func dbError(err error) *errors.AppError {
+	if stderrors.Is(err, gorm.ErrDuplicatedKey) {
+		return errors.NewError(errors.ErrCodeConflict, "resource already exists")
+	}
+	return errors.NewErrorf(errors.ErrCodeInternalServerError, "database error: %v", err)
+}
+
+// writeError 写操作错误转换：AppError 原样返回，其余按数据库错误处理
+func writeError(err error) *errors.AppError {
+	if err == nil {
+		return nil
+	}
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+	return dbError(err)
+}
+
+// toAppError 非 AppError 按参数错误处理
+func toAppError(err error) *errors.AppError {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+	return errors.NewError(errors.ErrCodeInvalidParameter, err.Error())
+}
+
+// toString 主键值转为路径片段
+func toString(v any) string {
+	b, _ := json.Marshal(v)
+	return strings.Trim(string(b), `"`)
+}
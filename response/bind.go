@@ -0,0 +1,325 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\bind.go
+ * @Description: 请求体绑定 - ctx.Bind 按 Content-Type 解码 JSON、表单、multipart、XML、Protobuf，
+ *               支持大小限制、严格模式（拒绝未知字段）与校验器集成
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	stderrors "errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// BindOptions 请求体绑定配置
+type BindOptions struct {
+	MaxBodySize int64 // 请求体大小上限（字节），<=0 不限制
+	MaxMemory   int64 // multipart 表单驻留内存上限，超出部分写入临时文件
+	Strict      bool  // 严格模式：请求中出现目标结构未定义的字段时返回 400
+	Validate    bool  // 解码后调用已注册的校验器
+}
+
+// DefaultBindOptions 默认绑定配置
+func DefaultBindOptions() *BindOptions {
+	return &BindOptions{
+		MaxBodySize: 10 << 20,
+		MaxMemory:   32 << 20,
+		Strict:      false,
+		Validate:    true,
+	}
+}
+
+// BindValidator 绑定后的校验函数
+type BindValidator func(v any) error
+
+// bindValidator 全局校验器（middleware 包默认注册 struct tag 校验）
+var bindValidator atomic.Pointer[BindValidator]
+
+// SetBindValidator 设置绑定后的校验器，nil 关闭校验
+func SetBindValidator(fn BindValidator) {
+	if fn == nil {
+		bindValidator.Store(nil)
+		return
+	}
+	bindValidator.Store(&fn)
+}
+
+// SetBindOptions 设置当前上下文的绑定配置
+func (c *Context) SetBindOptions(opts *BindOptions) {
+	c.binding = opts
+}
+
+// Bind 按 Content-Type 解码请求体到 v（v 必须为指针），并按配置执行校验
+func (c *Context) Bind(v any) error {
+	opts := c.binding
+	if opts == nil {
+		opts = DefaultBindOptions()
+	}
+	return BindRequest(c.Writer, c.Request, v, opts)
+}
+
+// BindRequest 按 Content-Type 解码请求体到 v，可在普通 http.Handler 中使用
+func BindRequest(w http.ResponseWriter, r *http.Request, v any, opts *BindOptions) error {
+	if opts == nil {
+		opts = DefaultBindOptions()
+	}
+	if opts.MaxBodySize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodySize)
+	}
+
+	mediaType := httpx.ContentTypeApplicationJSON
+	if ct := r.Header.Get(constants.HeaderContentType); ct != "" {
+		parsed, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeInvalidContentType, "invalid Content-Type %q", ct)
+		}
+		mediaType = parsed
+	}
+
+	var err error
+	switch mediaType {
+	case httpx.ContentTypeApplicationJSON:
+		err = bindJSON(r.Body, v, opts.Strict)
+	case httpx.ContentTypeApplicationXML, httpx.ContentTypeTextXML:
+		err = xml.NewDecoder(r.Body).Decode(v)
+	case constants.ContentTypeProtobuf, constants.ContentTypeXProtobuf:
+		err = bindProtobuf(r.Body, v, opts.Strict)
+	case httpx.ContentTypeWWWFormURLEncoded:
+		if err = r.ParseForm(); err == nil {
+			err = bindForm(r.PostForm, nil, v, opts.Strict)
+		}
+	case httpx.ContentTypeMultipartFormData:
+		if err = r.ParseMultipartForm(opts.MaxMemory); err == nil {
+			err = bindForm(r.MultipartForm.Value, r.MultipartForm.File, v, opts.Strict)
+		}
+	default:
+		return errors.NewErrorf(errors.ErrCodeInvalidContentType, "unsupported Content-Type %q", mediaType)
+	}
+	if err != nil {
+		return bindError(err)
+	}
+
+	if opts.Validate {
+		if validate := bindValidator.Load(); validate != nil {
+			if err := (*validate)(v); err != nil {
+				var appErr *errors.AppError
+				if stderrors.As(err, &appErr) {
+					return appErr
+				}
+				return errors.NewError(errors.ErrCodeInvalidParameter, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// bindError 将解码错误转换为 AppError
+func bindError(err error) error {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(err, &maxBytesErr) {
+		return errors.NewErrorf(errors.ErrCodeRequestTooLarge, "request body exceeds %d bytes", maxBytesErr.Limit)
+	}
+	if stderrors.Is(err, io.EOF) {
+		return errors.NewError(errors.ErrCodeBadRequest, "empty request body")
+	}
+	return errors.NewError(errors.ErrCodeBadRequest, err.Error())
+}
+
+// bindJSON 解码 JSON，proto.Message 使用 protojson
+func bindJSON(body io.Reader, v any, strict bool) error {
+	if msg, ok := v.(proto.Message); ok {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		return protojson.UnmarshalOptions{DiscardUnknown: !strict}.Unmarshal(data, msg)
+	}
+	decoder := json.NewDecoder(body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+// bindProtobuf 解码 Protobuf 二进制，严格模式下拒绝包含未知字段的消息
+func bindProtobuf(body io.Reader, v any, strict bool) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.NewErrorf(errors.ErrCodeInvalidContentType, "protobuf body requires proto.Message, got %T", v)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	if strict && len(msg.ProtoReflect().GetUnknown()) > 0 {
+		return errors.NewError(errors.ErrCodeBadRequest, "protobuf body contains unknown fields")
+	}
+	return nil
+}
+
+// fileHeaderType multipart 文件字段类型
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+// bindForm 将表单值绑定到结构体字段，字段名取 form 标签，其次 json 标签，最后字段名
+func bindForm(values map[string][]string, files map[string][]*multipart.FileHeader, v any, strict bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "form binding requires pointer to struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	known := make(map[string]struct{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := formFieldName(field)
+		if name == "-" {
+			continue
+		}
+		known[name] = struct{}{}
+
+		target := rv.Field(i)
+		if fhs, ok := files[name]; ok && len(fhs) > 0 {
+			switch {
+			case field.Type == fileHeaderType:
+				target.Set(reflect.ValueOf(fhs[0]))
+			case field.Type.Kind() == reflect.Slice && field.Type.Elem() == fileHeaderType:
+				target.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+		raw, ok := values[name]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+		if err := setFormValue(target, raw); err != nil {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "field %s: %v", name, err)
+		}
+	}
+
+	if strict {
+		for name := range values {
+			if _, ok := known[name]; !ok {
+				return errors.NewErrorf(errors.ErrCodeBadRequest, "unknown field %q", name)
+			}
+		}
+		for name := range files {
+			if _, ok := known[name]; !ok {
+				return errors.NewErrorf(errors.ErrCodeBadRequest, "unknown field %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// formFieldName 获取字段的表单名称
+func formFieldName(field reflect.StructField) string {
+	for _, key := range []string{"form", "json"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+// setFormValue 将表单字符串设置到字段（支持基础类型、指针与切片）
+func setFormValue(target reflect.Value, raw []string) error {
+	switch target.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(target.Type().Elem())
+		if err := setFormValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case reflect.Slice:
+		if target.Type().Elem().Kind() == reflect.Uint8 {
+			target.SetBytes([]byte(raw[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(target.Type(), len(raw), len(raw))
+		for i, item := range raw {
+			if err := setScalar(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+	default:
+		return setScalar(target, raw[0])
+	}
+}
+
+// setScalar 将字符串解析为基础类型
+func setScalar(target reflect.Value, s string) error {
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		target.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(f)
+	case reflect.Pointer:
+		elem := reflect.New(target.Type().Elem())
+		if err := setScalar(elem.Elem(), s); err != nil {
+			return err
+		}
+		target.Set(elem)
+	default:
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "unsupported field type %s", target.Type())
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\codec.go
+ * @Description: 响应编码器注册表 - 内置 JSON、XML、Protobuf、MessagePack，支持注册自定义编码器
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoder 响应编码器
+type Encoder interface {
+	// ContentType 写入响应的 Content-Type
+	ContentType() string
+	// Encode 将 v 编码写入 w
+	Encode(w io.Writer, v any) error
+}
+
+// EncoderFunc 函数式编码器
+type EncoderFunc struct {
+	MediaType string
+	Fn        func(w io.Writer, v any) error
+}
+
+// ContentType 写入响应的 Content-Type
+func (e EncoderFunc) ContentType() string { return e.MediaType }
+
+// Encode 将 v 编码写入 w
+func (e EncoderFunc) Encode(w io.Writer, v any) error { return e.Fn(w, v) }
+
+// encoderRegistry 媒体类型 → 编码器
+var encoderRegistry = struct {
+	mu       sync.RWMutex
+	encoders map[string]Encoder
+	order    []string // 注册顺序，Accept 为 */* 时按此顺序选择
+}{encoders: make(map[string]Encoder)}
+
+func init() {
+	RegisterEncoder(httpx.ContentTypeApplicationJSON, JSONEncoder{})
+	RegisterEncoder(httpx.ContentTypeApplicationXML, XMLEncoder{})
+	RegisterEncoder(httpx.ContentTypeTextXML, XMLEncoder{MediaType: httpx.ContentTypeTextXML})
+	RegisterEncoder(constants.ContentTypeProtobuf, ProtobufEncoder{})
+	RegisterEncoder(constants.ContentTypeXProtobuf, ProtobufEncoder{MediaType: constants.ContentTypeXProtobuf})
+	RegisterEncoder(constants.ContentTypeMsgPack, MsgPackEncoder{})
+	RegisterEncoder(constants.ContentTypeXMsgPack, MsgPackEncoder{MediaType: constants.ContentTypeXMsgPack})
+}
+
+// RegisterEncoder 注册（或替换）媒体类型对应的编码器
+func RegisterEncoder(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || enc == nil {
+		return
+	}
+	encoderRegistry.mu.Lock()
+	defer encoderRegistry.mu.Unlock()
+	if _, exists := encoderRegistry.encoders[mediaType]; !exists {
+		encoderRegistry.order = append(encoderRegistry.order, mediaType)
+	}
+	encoderRegistry.encoders[mediaType] = enc
+}
+
+// GetEncoder 获取媒体类型对应的编码器
+func GetEncoder(mediaType string) (Encoder, bool) {
+	encoderRegistry.mu.RLock()
+	defer encoderRegistry.mu.RUnlock()
+	enc, ok := encoderRegistry.encoders[strings.ToLower(mediaType)]
+	return enc, ok
+}
+
+// RegisteredMediaTypes 返回已注册的媒体类型（按注册顺序）
+func RegisteredMediaTypes() []string {
+	encoderRegistry.mu.RLock()
+	defer encoderRegistry.mu.RUnlock()
+	return append([]string(nil), encoderRegistry.order...)
+}
+
+// JSONEncoder JSON 编码器，proto.Message 使用 protojson 编码
+type JSONEncoder struct{}
+
+// ContentType 写入响应的 Content-Type
+func (JSONEncoder) ContentType() string { return httpx.ContentTypeApplicationJSON }
+
+// Encode 将 v 编码为 JSON
+func (JSONEncoder) Encode(w io.Writer, v any) error {
+	if msg, ok := v.(proto.Message); ok {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return WriteJSON(w, v)
+}
+
+// XMLEncoder XML 编码器（仅支持 encoding/xml 可编码的类型，map 等需自行转换）
+type XMLEncoder struct {
+	MediaType string
+}
+
+// ContentType 写入响应的 Content-Type
+func (e XMLEncoder) ContentType() string {
+	if e.MediaType != "" {
+		return e.MediaType
+	}
+	return httpx.ContentTypeApplicationXML
+}
+
+// Encode 将 v 编码为 XML
+func (XMLEncoder) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// ProtobufEncoder Protobuf 二进制编码器，v 必须实现 proto.Message
+type ProtobufEncoder struct {
+	MediaType string
+}
+
+// ContentType 写入响应的 Content-Type
+func (e ProtobufEncoder) ContentType() string {
+	if e.MediaType != "" {
+		return e.MediaType
+	}
+	return constants.ContentTypeProtobuf
+}
+
+// Encode 将 v 编码为 Protobuf 二进制
+func (ProtobufEncoder) Encode(w io.Writer, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "protobuf encoder requires proto.Message, got %T", v)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
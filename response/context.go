@@ -0,0 +1,139 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\context.go
+ * @Description: 处理器上下文 - handler 通过 ctx.Bind 解码请求体、ctx.Respond(data) 返回数据，网关按 Accept 头协商编码
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	commonapis "github.com/kamalyes/go-rpc-gateway/proto"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+)
+
+// NegotiationOptions 路由级内容协商配置
+type NegotiationOptions struct {
+	Offers  []string // 可返回的媒体类型（按服务端优先级），为空时使用全部已注册编码器
+	Default string   // 无可接受类型且非严格模式时使用，默认 application/json
+	Strict  bool     // 严格模式：无可接受类型时返回 406
+}
+
+// defaultNegotiation 默认协商配置
+var defaultNegotiation = &NegotiationOptions{}
+
+// Context 处理器上下文
+type Context struct {
+	Writer      http.ResponseWriter
+	Request     *http.Request
+	negotiation *NegotiationOptions
+	binding     *BindOptions
+}
+
+// ContextHandlerFunc 使用处理器上下文的 handler，返回的错误按 AppError 写入响应
+type ContextHandlerFunc func(c *Context) error
+
+// NewContext 创建处理器上下文
+func NewContext(w http.ResponseWriter, r *http.Request) *Context {
+	return &Context{Writer: w, Request: r, negotiation: defaultNegotiation}
+}
+
+// Handle 将 ContextHandlerFunc 适配为 http.Handler，opts 为路由级协商配置（可为 nil）
+// 处理器上下文取自对象池，fn 返回后即被回收，不得在 fn 之外（如异步 goroutine）持有
+func Handle(fn ContextHandlerFunc, opts *NegotiationOptions) http.Handler {
+	if opts == nil {
+		opts = defaultNegotiation
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := acquireContext(w, r, opts)
+		defer releaseContext(c)
+		if err := fn(c); err != nil {
+			c.Error(err)
+		}
+	})
+}
+
+// Context 请求的 context.Context
+func (c *Context) Context() context.Context {
+	return c.Request.Context()
+}
+
+// SetNegotiation 设置协商配置
+func (c *Context) SetNegotiation(opts *NegotiationOptions) {
+	if opts == nil {
+		opts = defaultNegotiation
+	}
+	c.negotiation = opts
+}
+
+// Respond 按 Accept 头编码 data 并返回 200
+func (c *Context) Respond(data any) error {
+	return c.RespondStatus(http.StatusOK, data)
+}
+
+// RespondStatus 按 Accept 头编码 data 并返回指定状态码
+func (c *Context) RespondStatus(httpStatus int, data any) error {
+	enc, ok := c.negotiate()
+	if !ok {
+		WriteErrorResult(c.Writer, http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable), commonapis.StatusCode_InvalidArgument)
+		return nil
+	}
+
+	// 先编码到缓冲区，编码失败时仍可返回 500
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	if err := enc.Encode(buf, data); err != nil {
+		return errors.NewErrorf(errors.ErrCodeInternalServerError, "encode %s response: %v", enc.ContentType(), err)
+	}
+
+	header := c.Writer.Header()
+	header.Set(constants.HeaderContentType, enc.ContentType())
+	header.Add(constants.HeaderVary, constants.HeaderAccept)
+	c.Writer.WriteHeader(httpStatus)
+	if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+		global.LOGGER.WithError(err).ErrorMsg("Failed to write negotiated response")
+	}
+	return nil
+}
+
+// Error 写入错误响应（非 AppError 按 500 处理）
+func (c *Context) Error(err error) {
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		appErr = errors.NewError(errors.ErrCodeInternalServerError, err.Error())
+	}
+	WriteAppError(c.Writer, appErr)
+}
+
+// negotiate 选择编码器
+func (c *Context) negotiate() (Encoder, bool) {
+	offers := c.negotiation.Offers
+	if len(offers) == 0 {
+		offers = RegisteredMediaTypes()
+	}
+
+	if mediaType, ok := NegotiateContentType(c.Request.Header.Get(constants.HeaderAccept), offers); ok {
+		if enc, found := GetEncoder(mediaType); found {
+			return enc, true
+		}
+	}
+	if c.negotiation.Strict {
+		return nil, false
+	}
+
+	fallback := c.negotiation.Default
+	if fallback == "" {
+		fallback = httpx.ContentTypeApplicationJSON
+	}
+	return GetEncoder(fallback)
+}
@@ -0,0 +1,207 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\json.go
+ * @Description: 统一 JSON 编解码 - 池化编码缓冲区，可在 std / jsoniter / sonic（-tags sonic）后端间切换，
+ *               用于响应信封、健康检查、管理接口等热路径
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/redact"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+)
+
+// 内置 JSON 后端名称
+const (
+	JSONBackendStd      = "std"
+	JSONBackendJSONIter = "jsoniter"
+	JSONBackendSonic    = "sonic"
+)
+
+// maxPooledJSONBuffer 超过该容量的缓冲区不放回池中，避免偶发的大响应长期占用内存
+const maxPooledJSONBuffer = 64 << 10
+
+// JSONBackend JSON 编解码后端，输出需与 encoding/json 兼容
+type JSONBackend interface {
+	// Name 后端名称
+	Name() string
+	// Marshal 编码 v
+	Marshal(v any) ([]byte, error)
+	// Unmarshal 解码 data 到 v
+	Unmarshal(data []byte, v any) error
+	// Encode 将 v 完整编码（末尾带换行，与 json.Encoder 一致）后一次性写入 w，编码失败时不写入任何内容
+	Encode(w io.Writer, v any) error
+}
+
+// jsonBackendRegistry 名称 → 后端
+var jsonBackendRegistry = struct {
+	mu       sync.RWMutex
+	backends map[string]JSONBackend
+}{backends: make(map[string]JSONBackend)}
+
+// currentJSONBackend 当前使用的后端
+var currentJSONBackend atomic.Pointer[JSONBackend]
+
+func init() {
+	RegisterJSONBackend(stdJSONBackend{})
+	RegisterJSONBackend(jsoniterBackend{})
+	// -tags sonic 时由 json_sonic.go 切换为 sonic
+	if currentJSONBackend.Load() == nil {
+		_ = SetJSONBackend(JSONBackendStd)
+	}
+}
+
+// RegisterJSONBackend 注册（或替换）JSON 后端
+func RegisterJSONBackend(backend JSONBackend) {
+	if backend == nil || backend.Name() == "" {
+		return
+	}
+	jsonBackendRegistry.mu.Lock()
+	defer jsonBackendRegistry.mu.Unlock()
+	jsonBackendRegistry.backends[strings.ToLower(backend.Name())] = backend
+}
+
+// SetJSONBackend 切换 JSON 后端，name 为空时恢复编译期默认后端（-tags sonic 时为 sonic，否则为 std）
+func SetJSONBackend(name string) error {
+	if name == "" {
+		name = defaultJSONBackend
+	}
+	jsonBackendRegistry.mu.RLock()
+	backend, ok := jsonBackendRegistry.backends[strings.ToLower(name)]
+	jsonBackendRegistry.mu.RUnlock()
+	if !ok {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "json backend %q is not registered (sonic requires -tags sonic)", name)
+	}
+	currentJSONBackend.Store(&backend)
+	return nil
+}
+
+// GetJSONBackend 获取当前 JSON 后端
+func GetJSONBackend() JSONBackend {
+	return *currentJSONBackend.Load()
+}
+
+// MarshalJSON 使用当前后端编码
+func MarshalJSON(v any) ([]byte, error) {
+	return GetJSONBackend().Marshal(v)
+}
+
+// UnmarshalJSON 使用当前后端解码
+func UnmarshalJSON(data []byte, v any) error {
+	return GetJSONBackend().Unmarshal(data, v)
+}
+
+// WriteJSON 使用当前后端将 v 编码后一次性写入 w
+func WriteJSON(w io.Writer, v any) error {
+	return GetJSONBackend().Encode(w, v)
+}
+
+// jsonBodyWriter 首次写入时才写出 Content-Type 与状态码，编码失败时仍可改写为 500
+type jsonBodyWriter struct {
+	w      http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+// jsonBodyWriterPool 响应体写入器对象池
+var jsonBodyWriterPool = sync.Pool{
+	New: func() any { return &jsonBodyWriter{} },
+}
+
+// Write 写出响应头后写入响应体
+func (bw *jsonBodyWriter) Write(p []byte) (int, error) {
+	if !bw.wrote {
+		bw.wrote = true
+		bw.w.Header().Set(constants.HeaderContentType, httpx.ContentTypeApplicationJSON)
+		bw.w.WriteHeader(bw.status)
+	}
+	return bw.w.Write(p)
+}
+
+// writeJSONBody 先编码再写状态码与响应体，编码失败时返回 500 而不是写出半截响应；
+// 响应中的配置结构体按 redact 规则脱敏后再编码
+func writeJSONBody(w http.ResponseWriter, httpStatus int, v any, logMsg string) {
+	bw := jsonBodyWriterPool.Get().(*jsonBodyWriter)
+	bw.w, bw.status, bw.wrote = w, httpStatus, false
+	err := WriteJSON(bw, redact.Value(v))
+	wrote := bw.wrote
+	bw.w = nil
+	jsonBodyWriterPool.Put(bw)
+
+	if err == nil {
+		return
+	}
+	if global.LOGGER != nil {
+		global.LOGGER.WithError(err).ErrorMsg(logMsg)
+	}
+	if !wrote {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// stdJSONBackend encoding/json 后端
+type stdJSONBackend struct{}
+
+// Name 后端名称
+func (stdJSONBackend) Name() string { return JSONBackendStd }
+
+// Marshal 编码 v
+func (stdJSONBackend) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal 解码 data 到 v
+func (stdJSONBackend) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// Encode 编码后写入 w（encoding/json 内部已池化编码缓冲区，Encoder 只在成功编码后整体写出）
+func (stdJSONBackend) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// jsoniterAPI 与标准库行为兼容的 jsoniter 配置（自带 Stream 对象池）
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// jsoniterBackend json-iterator 后端
+type jsoniterBackend struct{}
+
+// Name 后端名称
+func (jsoniterBackend) Name() string { return JSONBackendJSONIter }
+
+// Marshal 编码 v
+func (jsoniterBackend) Marshal(v any) ([]byte, error) { return jsoniterAPI.Marshal(v) }
+
+// Unmarshal 解码 data 到 v
+func (jsoniterBackend) Unmarshal(data []byte, v any) error { return jsoniterAPI.Unmarshal(data, v) }
+
+// Encode 编码到池化 Stream 缓冲区，成功后一次性写入 w
+func (jsoniterBackend) Encode(w io.Writer, v any) error {
+	stream := jsoniterAPI.BorrowStream(nil)
+	defer func() {
+		if cap(stream.Buffer()) <= maxPooledJSONBuffer {
+			jsoniterAPI.ReturnStream(stream)
+		}
+	}()
+
+	stream.WriteVal(v)
+	if stream.Error != nil {
+		return stream.Error
+	}
+	stream.WriteRaw("\n")
+	_, err := w.Write(stream.Buffer())
+	return err
+}
@@ -0,0 +1,71 @@
+//go:build sonic
+
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\json_sonic.go
+ * @Description: sonic JSON 后端 - 使用 -tags sonic 构建时注册并设为默认后端（需 amd64/arm64）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"io"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/encoder"
+)
+
+// defaultJSONBackend 编译期默认 JSON 后端
+const defaultJSONBackend = JSONBackendSonic
+
+// sonicEncodeOptions 与 encoding/json 输出保持一致的编码选项
+const sonicEncodeOptions = encoder.SortMapKeys | encoder.EscapeHTML | encoder.CompactMarshaler
+
+// sonicBufferPool sonic 编码缓冲区对象池
+var sonicBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+func init() {
+	RegisterJSONBackend(sonicBackend{})
+	_ = SetJSONBackend(JSONBackendSonic)
+}
+
+// sonicBackend bytedance/sonic 后端（ConfigStd，与标准库行为兼容）
+type sonicBackend struct{}
+
+// Name 后端名称
+func (sonicBackend) Name() string { return JSONBackendSonic }
+
+// Marshal 编码 v
+func (sonicBackend) Marshal(v any) ([]byte, error) { return sonic.ConfigStd.Marshal(v) }
+
+// Unmarshal 解码 data 到 v
+func (sonicBackend) Unmarshal(data []byte, v any) error { return sonic.ConfigStd.Unmarshal(data, v) }
+
+// Encode 编码到池化缓冲区，成功后一次性写入 w
+func (sonicBackend) Encode(w io.Writer, v any) error {
+	buf := sonicBufferPool.Get().(*[]byte)
+	defer func() {
+		if cap(*buf) <= maxPooledJSONBuffer {
+			*buf = (*buf)[:0]
+			sonicBufferPool.Put(buf)
+		}
+	}()
+
+	if err := encoder.EncodeInto(buf, v, sonicEncodeOptions); err != nil {
+		return err
+	}
+	*buf = append(*buf, '\n')
+	_, err := w.Write(*buf)
+	return err
+}
@@ -0,0 +1,17 @@
+//go:build !sonic
+
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\json_std.go
+ * @Description: 未启用 sonic 构建标签时的默认 JSON 后端
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+// defaultJSONBackend 编译期默认 JSON 后端
+const defaultJSONBackend = JSONBackendStd
@@ -0,0 +1,145 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\json_test.go
+ * @Description: JSON 后端测试 - 各已注册后端的池化编码与 json.NewEncoder 对比，输出需与 encoding/json 一致；
+ *               sonic 后端需带构建标签运行：go test -tags sonic -run '^$' -bench JSONEncode -benchmem ./response
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"testing"
+)
+
+// jsonBenchRecord 结构体信封中的记录
+type jsonBenchRecord struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email,omitempty"`
+	Tags  []string `json:"tags"`
+	Score float64  `json:"score"`
+}
+
+// jsonBenchEnvelope 结构体信封
+type jsonBenchEnvelope struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Data    []jsonBenchRecord `json:"data"`
+}
+
+// jsonBenchPayloads 结构体信封与嵌套 map 两类负载
+var jsonBenchPayloads = []struct {
+	name  string
+	value any
+}{
+	{"struct", jsonBenchEnvelope{
+		Code:    0,
+		Message: "ok",
+		Data: []jsonBenchRecord{
+			{ID: 1, Name: "alice", Email: "alice@example.com", Tags: []string{"admin", "ops"}, Score: 98.5},
+			{ID: 2, Name: "bob", Tags: []string{"dev"}, Score: 71},
+			{ID: 3, Name: "<carol & dave>", Email: "team@example.com", Tags: []string{}, Score: 0.25},
+		},
+	}},
+	{"map", map[string]any{
+		"code":    0,
+		"message": "ok",
+		"data": map[string]any{
+			"items": []any{
+				map[string]any{"id": 1, "name": "alice", "roles": []any{"admin", "ops"}},
+				map[string]any{"id": 2, "name": "bob", "roles": []any{"dev"}},
+			},
+			"page":  map[string]any{"size": 20, "offset": 0, "total": 2},
+			"extra": map[string]any{"html": "<b>&</b>", "ratio": 0.5, "enabled": true, "none": nil},
+		},
+	}},
+}
+
+// jsonBackendNames 已注册后端名称，-tags sonic 时包含 sonic
+func jsonBackendNames() []string {
+	jsonBackendRegistry.mu.RLock()
+	defer jsonBackendRegistry.mu.RUnlock()
+	names := make([]string, 0, len(jsonBackendRegistry.backends))
+	for name := range jsonBackendRegistry.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonBackend 按名称取已注册后端
+func jsonBackend(tb testing.TB, name string) JSONBackend {
+	tb.Helper()
+	jsonBackendRegistry.mu.RLock()
+	defer jsonBackendRegistry.mu.RUnlock()
+	backend, ok := jsonBackendRegistry.backends[name]
+	if !ok {
+		tb.Fatalf("json backend %q is not registered", name)
+	}
+	return backend
+}
+
+func TestJSONBackendsMatchEncoder(t *testing.T) {
+	for _, name := range jsonBackendNames() {
+		backend := jsonBackend(t, name)
+		for _, payload := range jsonBenchPayloads {
+			var want, got bytes.Buffer
+			if err := json.NewEncoder(&want).Encode(payload.value); err != nil {
+				t.Fatalf("encoding/json %s: %v", payload.name, err)
+			}
+			if err := backend.Encode(&got, payload.value); err != nil {
+				t.Fatalf("%s %s: %v", name, payload.name, err)
+			}
+			if !bytes.Equal(want.Bytes(), got.Bytes()) {
+				t.Errorf("%s %s:\n got %s\nwant %s", name, payload.name, got.Bytes(), want.Bytes())
+			}
+		}
+	}
+}
+
+func TestJSONBackendEncodeError(t *testing.T) {
+	for _, name := range jsonBackendNames() {
+		var buf bytes.Buffer
+		if err := jsonBackend(t, name).Encode(&buf, map[string]any{"ch": make(chan int)}); err == nil {
+			t.Errorf("%s: encoding a channel succeeded", name)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: wrote %d bytes on encode error", name, buf.Len())
+		}
+	}
+}
+
+// BenchmarkJSONEncode 每个负载下对比 json.NewEncoder 与各后端的池化编码；
+// std 后端即 json.NewEncoder，作为同一构建下的基线
+func BenchmarkJSONEncode(b *testing.B) {
+	for _, payload := range jsonBenchPayloads {
+		b.Run(payload.name+"/encoding-json", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := json.NewEncoder(io.Discard).Encode(payload.value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		for _, name := range jsonBackendNames() {
+			backend := jsonBackend(b, name)
+			b.Run(payload.name+"/"+name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := backend.Encode(io.Discard, payload.value); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
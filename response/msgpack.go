@@ -0,0 +1,216 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\msgpack.go
+ * @Description: MessagePack 编码器 - 无第三方依赖，结构体按 json 标签编码（proto.Message 按 protojson 字段名）
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// MsgPackEncoder MessagePack 编码器
+type MsgPackEncoder struct {
+	MediaType string
+}
+
+// ContentType 写入响应的 Content-Type
+func (e MsgPackEncoder) ContentType() string {
+	if e.MediaType != "" {
+		return e.MediaType
+	}
+	return constants.ContentTypeMsgPack
+}
+
+// Encode 将 v 编码为 MessagePack
+func (MsgPackEncoder) Encode(w io.Writer, v any) error {
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	if err := writeMsgPack(buf, v); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeMsgPack 编码常见类型，其余类型先转为 JSON 通用结构再编码（保持与 JSON 响应一致的字段名）
+func writeMsgPack(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if val {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		writeMsgPackInt(buf, int64(val))
+	case int8:
+		writeMsgPackInt(buf, int64(val))
+	case int16:
+		writeMsgPackInt(buf, int64(val))
+	case int32:
+		writeMsgPackInt(buf, int64(val))
+	case int64:
+		writeMsgPackInt(buf, val)
+	case uint:
+		writeMsgPackUint(buf, uint64(val))
+	case uint8:
+		writeMsgPackUint(buf, uint64(val))
+	case uint16:
+		writeMsgPackUint(buf, uint64(val))
+	case uint32:
+		writeMsgPackUint(buf, uint64(val))
+	case uint64:
+		writeMsgPackUint(buf, val)
+	case float32:
+		buf.WriteByte(0xca)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(val))
+	case float64:
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(val))
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			writeMsgPackInt(buf, i)
+			return nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return err
+		}
+		return writeMsgPack(buf, f)
+	case string:
+		writeMsgPackString(buf, val)
+	case []byte:
+		writeMsgPackHeader(buf, len(val), 0, 0xc4, 0xc5, 0xc6)
+		buf.Write(val)
+	case []any:
+		writeMsgPackHeader(buf, len(val), 0x90, 0, 0xdc, 0xdd)
+		for _, item := range val {
+			if err := writeMsgPack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeMsgPackHeader(buf, len(val), 0x80, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			writeMsgPackString(buf, k)
+			if err := writeMsgPack(buf, val[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		generic, err := toGenericValue(v)
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "msgpack encode %T: %v", v, err)
+		}
+		return writeMsgPack(buf, generic)
+	}
+	return nil
+}
+
+// toGenericValue 通过 JSON 编码转换为 map/slice/基础类型
+func toGenericValue(v any) (any, error) {
+	raw := AcquireBuffer()
+	defer ReleaseBuffer(raw)
+	if err := (JSONEncoder{}).Encode(raw, v); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(raw)
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// writeMsgPackInt 写入有符号整数（选择最短编码）
+func writeMsgPackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		writeMsgPackUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// writeMsgPackUint 写入无符号整数（选择最短编码）
+func writeMsgPackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= 0x7f:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(u))
+	default:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, u)
+	}
+}
+
+// writeMsgPackString 写入字符串
+func writeMsgPackString(buf *bytes.Buffer, s string) {
+	if len(s) < 32 {
+		buf.WriteByte(0xa0 | byte(len(s)))
+	} else {
+		writeMsgPackHeader(buf, len(s), 0, 0xd9, 0xda, 0xdb)
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgPackHeader 写入长度头：fix 非 0 时短长度使用 fix 格式（数组/映射），
+// 否则从 8 位长度格式 c8 起步（字符串/二进制），c16/c32 为 16/32 位长度格式
+func writeMsgPackHeader(buf *bytes.Buffer, n int, fix, c8, c16, c32 byte) {
+	switch {
+	case fix != 0 && n < 16:
+		buf.WriteByte(fix | byte(n))
+	case fix == 0 && n <= math.MaxUint8:
+		buf.WriteByte(c8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(c16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(c32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\negotiate.go
+ * @Description: Accept 内容协商 - 解析质量值（q）与通配符，按客户端偏好与服务端顺序选择媒体类型
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptSpec Accept 头中的单个媒体范围
+type AcceptSpec struct {
+	MediaType string  // 如 application/json、text/*、*/*
+	Quality   float64 // q 值，缺省为 1
+}
+
+// ParseAccept 解析 Accept 头，按 q 值从高到低排序（q 相同保持原顺序）
+func ParseAccept(header string) []AcceptSpec {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+
+	parts := strings.Split(header, ",")
+	specs := make([]AcceptSpec, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				quality = q
+			}
+		}
+		specs = append(specs, AcceptSpec{MediaType: mediaType, Quality: quality})
+	}
+
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Quality > specs[j].Quality
+	})
+	return specs
+}
+
+// NegotiateContentType 从 offers（服务端优先级顺序）中选择客户端最可接受的媒体类型
+// Accept 为空时返回 offers[0]；没有可接受的类型时返回 false
+func NegotiateContentType(accept string, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	specs := ParseAccept(accept)
+	if len(specs) == 0 {
+		return offers[0], true
+	}
+
+	var (
+		best        string
+		bestQuality float64
+	)
+	for _, offer := range offers {
+		quality, matched := acceptQuality(specs, strings.ToLower(offer))
+		if !matched || quality <= 0 {
+			continue
+		}
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best, best != ""
+}
+
+// acceptQuality 返回 offer 在 Accept 中最具体匹配项的 q 值（精确 > type/* > */*）
+func acceptQuality(specs []AcceptSpec, offer string) (float64, bool) {
+	offerType, _, _ := strings.Cut(offer, "/")
+
+	bestSpecificity := -1
+	quality := 0.0
+	for _, spec := range specs {
+		specificity := -1
+		switch {
+		case spec.MediaType == offer:
+			specificity = 2
+		case spec.MediaType == offerType+"/*":
+			specificity = 1
+		case spec.MediaType == "*/*":
+			specificity = 0
+		}
+		if specificity > bestSpecificity {
+			bestSpecificity, quality = specificity, spec.Quality
+		}
+	}
+	return quality, bestSpecificity >= 0
+}
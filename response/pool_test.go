@@ -0,0 +1,118 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\response\pool_test.go
+ * @Description: 对象池测试 - 池化与直接分配的开销对比；并发获取/归还下上下文与缓冲区不残留上一次请求的状态，
+ *               需配合 -race 运行：go test -race -run Pool -bench Pool -benchmem ./response
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package response
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// poolPayload 模拟一次响应编码写入的内容
+var poolPayload = bytes.Repeat([]byte(`{"code":0,"message":"ok"}`), 16)
+
+// sinkBuffer / sinkContext 防止编译器把分配优化掉
+var (
+	sinkBuffer  *bytes.Buffer
+	sinkContext *Context
+)
+
+func BenchmarkPoolBuffer(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := AcquireBuffer()
+			buf.Write(poolPayload)
+			ReleaseBuffer(buf)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := bytes.NewBuffer(make([]byte, 0, 1024))
+			buf.Write(poolPayload)
+			sinkBuffer = buf
+		}
+	})
+}
+
+func BenchmarkPoolContext(b *testing.B) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c := acquireContext(w, r, defaultNegotiation)
+			releaseContext(c)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sinkContext = &Context{Writer: w, Request: r, negotiation: defaultNegotiation}
+		}
+	})
+}
+
+func TestPoolConcurrentReuse(t *testing.T) {
+	const (
+		workers = 16
+		rounds  = 2000
+	)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			opts := &NegotiationOptions{}
+			for i := 0; i < rounds; i++ {
+				c := acquireContext(w, r, opts)
+				if c.binding != nil {
+					t.Errorf("acquired context carries binding from a previous request")
+					return
+				}
+				if c.Writer != w || c.Request != r || c.negotiation != opts {
+					t.Errorf("acquired context does not hold the current request")
+					return
+				}
+				// 归还后 c 可能已被其他 goroutine 取走，只能在下一次获取时检查是否残留
+				c.SetBindOptions(&BindOptions{Strict: true})
+				releaseContext(c)
+
+				buf := AcquireBuffer()
+				if buf.Len() != 0 {
+					t.Errorf("acquired buffer has %d stale bytes", buf.Len())
+					return
+				}
+				buf.Write(poolPayload)
+				ReleaseBuffer(buf)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReleaseBufferDropsLarge(t *testing.T) {
+	buf := AcquireBuffer()
+	buf.Grow(maxPooledJSONBuffer + 1)
+	ReleaseBuffer(buf)
+	for i := 0; i < 100; i++ {
+		if got := AcquireBuffer(); got == buf {
+			t.Fatalf("buffer over %d bytes returned to pool", maxPooledJSONBuffer)
+		}
+	}
+}
@@ -12,14 +12,10 @@
 package response
 
 import (
-	"encoding/json"
 	"net/http"
 
-	"github.com/kamalyes/go-rpc-gateway/constants"
 	"github.com/kamalyes/go-rpc-gateway/errors"
-	"github.com/kamalyes/go-rpc-gateway/global"
 	commonapis "github.com/kamalyes/go-rpc-gateway/proto"
-	"github.com/kamalyes/go-toolbox/pkg/httpx"
 )
 
 // WriteErrorResponse 写入标准化的错误响应
@@ -30,12 +26,7 @@ func WriteErrorResponse(w http.ResponseWriter, appErr *errors.AppError) {
 
 // WriteResultResponse 写入Result响应
 func WriteResultResponse(w http.ResponseWriter, httpStatus int, result *commonapis.Result) {
-	w.Header().Set(constants.HeaderContentType, httpx.ContentTypeApplicationJSON)
-	w.WriteHeader(httpStatus)
-
-	if err := json.NewEncoder(w).Encode(result); err != nil && global.LOGGER != nil {
-		global.LOGGER.WithError(err).ErrorMsg("Failed to encode Result response")
-	}
+	writeJSONBody(w, httpStatus, result, "Failed to encode Result response")
 }
 
 // WriteSimpleError 写入简单的错误响应（用于避免循环导入的场景）
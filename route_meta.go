@@ -0,0 +1,69 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\route_meta.go
+ * @Description: proto 路由元信息 - 供 protoc-gen-gateway 生成代码注册路由与校验钩子
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/server"
+)
+
+// RouteInfo 由 google.api.http 注解推导出的路由元信息
+// 通常由 protoc-gen-gateway 生成，业务方无需手写
+type RouteInfo struct {
+	Service    string     // proto 服务全名，例如 user.v1.UserService
+	Method     string     // RPC 方法名，例如 GetUser
+	FullMethod string     // gRPC 完整方法名，例如 /user.v1.UserService/GetUser
+	HTTPMethod string     // HTTP 方法，例如 GET
+	Pattern    string     // HTTP 路径模板，例如 /v1/users/{id}
+	Body       string     // 请求体映射字段（"*" 表示整个消息）
+	Summary    string     // 接口描述（取自 proto 注释）
+	NewRequest func() any // 请求消息工厂，用于 HTTP 层 struct tag 校验
+}
+
+// RegisterRoutes 登记 proto 路由元信息
+// 带请求体且提供了 NewRequest 的路由会注册到 struct tag 校验中间件，
+// 同时路由会加入端点收集器，用于 API 注册汇总与端点查询，
+// 并登记 HTTP 方法，用于 OPTIONS 自动返回 Allow 与 HEAD 委托 GET
+func (g *Gateway) RegisterRoutes(routes ...RouteInfo) {
+	for _, route := range routes {
+		if route.NewRequest != nil && route.Body != "" {
+			middleware.RegisterGatewayMessageType(route.HTTPMethod, route.Pattern, route.NewRequest)
+		}
+		g.Server.RegisterRouteMethod(route.HTTPMethod, route.Pattern)
+		g.endpointCollector().AddEndpoint(server.GenerateEndpointInfo(
+			route.HTTPMethod, route.Pattern, route.Summary, route.FullMethod, []string{route.Service},
+		))
+		g.routeInfos = append(g.routeInfos, route)
+		global.LOGGER.DebugContext(g.Context(), "登记proto路由: %s %s -> %s", route.HTTPMethod, route.Pattern, route.FullMethod)
+	}
+}
+
+// GetRoutes 获取已登记的 proto 路由元信息
+func (g *Gateway) GetRoutes() []RouteInfo {
+	result := make([]RouteInfo, len(g.routeInfos))
+	copy(result, g.routeInfos)
+	return result
+}
+
+// GetEndpointCollector 获取 proto 路由对应的端点收集器
+func (g *Gateway) GetEndpointCollector() *server.EndpointCollector {
+	return g.endpointCollector()
+}
+
+// endpointCollector 延迟创建端点收集器
+func (g *Gateway) endpointCollector() *server.EndpointCollector {
+	if g.endpoints == nil {
+		g.endpoints = server.NewEndpointCollector()
+	}
+	return g.endpoints
+}
@@ -0,0 +1,29 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\script_hooks.go
+ * @Description: 脚本钩子加载 - 启动与配置热更新时读取配置文件顶层 scripts 段
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/server"
+)
+
+// applyScriptHooks 解析 scripts 段并替换服务器上的脚本钩子，raw 为 nil（未配置）时关闭
+func applyScriptHooks(srv *server.Server, raw any) error {
+	if raw == nil {
+		return srv.SetScriptHooks(nil)
+	}
+	cfg, err := middleware.ParseScriptHooks(raw)
+	if err != nil {
+		return err
+	}
+	return srv.SetScriptHooks(cfg)
+}
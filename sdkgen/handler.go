@@ -0,0 +1,139 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\sdkgen\handler.go
+ * @Description: SDK 下载接口 - 每次请求重新加载规范并生成，保证前端拿到的客户端与当前部署的接口一致
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package sdkgen
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-swagger/loader"
+)
+
+// DefaultPath SDK 下载接口默认路径
+const DefaultPath = "/admin/sdk"
+
+// zipSuffix 下载文件后缀
+const zipSuffix = ".zip"
+
+// HandlerConfig SDK 下载接口配置
+type HandlerConfig struct {
+	Config
+	Path      string     // 接口路径，默认 /admin/sdk
+	SpecFiles []string   // 规范文件，为空时由 SpecSource 提供（网关按 Swagger 配置加载，启用聚合时为聚合规范）
+	Languages []Language // 允许下载的语言，默认全部
+}
+
+// SpecSource 每次生成时提供最新的规范
+type SpecSource func() ([]map[string]any, error)
+
+// Handler SDK 下载接口
+type Handler struct {
+	config HandlerConfig
+	source SpecSource
+}
+
+// NewHandler 创建 SDK 下载接口，配置了 SpecFiles 时忽略 source
+func NewHandler(cfg HandlerConfig, source SpecSource) (*Handler, error) {
+	cfg.Path = strings.TrimSuffix(cfg.Path, "/")
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	if len(cfg.Languages) == 0 {
+		cfg.Languages = Languages
+	}
+	for _, lang := range cfg.Languages {
+		if _, err := ParseLanguage(string(lang)); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.SpecFiles) > 0 {
+		source = specFileSource(cfg.SpecFiles)
+	}
+	if source == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "sdkgen: no spec files and no spec source")
+	}
+	return &Handler{config: cfg, source: source}, nil
+}
+
+// specFileSource 每次重新读取规范文件
+func specFileSource(files []string) SpecSource {
+	return func() ([]map[string]any, error) {
+		specs := make([]map[string]any, 0, len(files))
+		for _, file := range files {
+			spec, err := loader.LoadSpecFromPath(file)
+			if err != nil {
+				return nil, fmt.Errorf("load %s: %w", file, err)
+			}
+			specs = append(specs, spec)
+		}
+		return specs, nil
+	}
+}
+
+// AdminPath 接口路径
+func (h *Handler) AdminPath() string {
+	return h.config.Path
+}
+
+// ServeHTTP SDK 下载接口：
+//
+//	GET {path}/                 可下载的文件列表
+//	GET {path}/{language}.zip   生成并下载对应语言的 SDK，如 /admin/sdk/typescript.zip
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.WriteAppError(w, errors.ErrMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, h.config.Path), "/")
+	if name == "" {
+		downloads := make([]string, 0, len(h.config.Languages))
+		for _, lang := range h.config.Languages {
+			downloads = append(downloads, h.config.Path+"/"+string(lang)+zipSuffix)
+		}
+		response.WriteJSONResponse(w, http.StatusOK, map[string]any{"downloads": downloads})
+		return
+	}
+
+	lang := Language(strings.TrimSuffix(name, zipSuffix))
+	if !strings.HasSuffix(name, zipSuffix) || !slices.Contains(h.config.Languages, lang) {
+		response.WriteNotFoundResult(w, "unknown sdk: "+name)
+		return
+	}
+
+	specs, err := h.source()
+	if err != nil {
+		response.WriteServiceUnavailableResult(w, "sdkgen: load spec: "+err.Error())
+		return
+	}
+	files, err := Generate(lang, h.config.Config, specs...)
+	if err != nil {
+		response.WriteInternalServerErrorResult(w, err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, "", files); err != nil {
+		response.WriteInternalServerErrorResult(w, err.Error())
+		return
+	}
+
+	w.Header().Set(constants.HeaderContentType, constants.ContentTypeZip)
+	w.Header().Set(constants.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
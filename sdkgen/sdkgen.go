@@ -0,0 +1,205 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\sdkgen\sdkgen.go
+ * @Description: 客户端 SDK 生成 - 由 Swagger / OpenAPI 规范渲染 TypeScript 与 Go 客户端，
+ *               模板内置且可按文件覆盖或追加，结果打包为 zip
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package sdkgen 根据（聚合后的）Swagger / OpenAPI 规范生成客户端 SDK，
+// 既可由网关管理接口按需下载，也可由 gateway-cli sdk 在流水线中离线生成
+package sdkgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"embed"
+	"go/format"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// Language 目标语言
+type Language string
+
+// 支持的语言
+const (
+	LanguageTypeScript Language = "typescript"
+	LanguageGo         Language = "go"
+)
+
+// Languages 支持的全部语言
+var Languages = []Language{LanguageTypeScript, LanguageGo}
+
+// DefaultPackage 默认包名
+const DefaultPackage = "gateway-client"
+
+// templateSuffix 模板文件后缀，去掉后即为输出文件名
+const templateSuffix = ".tmpl"
+
+//go:embed templates/*/*.tmpl
+var builtinTemplates embed.FS
+
+// Config 生成配置
+type Config struct {
+	Package  string // 包名，用于 package.json 的 name，最后一段作为 Go 包名；默认 gateway-client
+	GoModule string // Go SDK 的模块路径，默认与 Package 相同
+	// TemplateDir 自定义模板目录，其下 typescript/、go/ 子目录中的 *.tmpl 按文件名覆盖内置模板或追加新文件，
+	// 模板数据为 API
+	TemplateDir string
+}
+
+// File 生成的文件
+type File struct {
+	Name string
+	Data []byte
+}
+
+// withDefaults 填充默认值
+func (c Config) withDefaults() Config {
+	if c.Package == "" {
+		c.Package = DefaultPackage
+	}
+	if c.GoModule == "" {
+		c.GoModule = c.Package
+	}
+	return c
+}
+
+// ParseLanguage 解析语言名，ts 视为 typescript
+func ParseLanguage(name string) (Language, error) {
+	lang := Language(strings.ToLower(name))
+	if lang == "ts" {
+		lang = LanguageTypeScript
+	}
+	if !slices.Contains(Languages, lang) {
+		return "", errors.NewErrorf(errors.ErrCodeInvalidParameter, "sdkgen: unsupported language %q", name)
+	}
+	return lang, nil
+}
+
+// Generate 由一个或多个规范生成指定语言的 SDK 文件，按文件名排序；Go 文件会经过 gofmt
+func Generate(lang Language, cfg Config, specs ...map[string]any) ([]File, error) {
+	cfg = cfg.withDefaults()
+	templates, err := loadTemplates(lang, cfg.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	api, err := buildAPI(cfg, specs...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(templates[name])
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "sdkgen: parse template %s/%s: %v", lang, name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, api); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "sdkgen: render %s/%s: %v", lang, name, err)
+		}
+		data := buf.Bytes()
+		if strings.HasSuffix(name, ".go") {
+			if data, err = format.Source(data); err != nil {
+				return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "sdkgen: format %s: %v", name, err)
+			}
+		}
+		files = append(files, File{Name: name, Data: data})
+	}
+	return files, nil
+}
+
+// templateFuncs 模板可用的辅助函数
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+}
+
+// loadTemplates 内置模板叠加自定义目录中的同语言模板，键为输出文件名
+func loadTemplates(lang Language, dir string) (map[string]string, error) {
+	if !slices.Contains(Languages, lang) {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "sdkgen: unsupported language %q", lang)
+	}
+	builtin, err := fs.Sub(builtinTemplates, "templates/"+string(lang))
+	if err != nil {
+		return nil, err
+	}
+	templates := make(map[string]string)
+	if err := readTemplates(builtin, templates); err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return templates, nil
+	}
+	custom := filepath.Join(dir, string(lang))
+	if _, err := os.Stat(custom); os.IsNotExist(err) {
+		return templates, nil
+	}
+	if err := readTemplates(os.DirFS(custom), templates); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "sdkgen: read templates %s: %v", custom, err)
+	}
+	return templates, nil
+}
+
+// readTemplates 读取目录下的 *.tmpl，同名覆盖
+func readTemplates(fsys fs.FS, templates map[string]string) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), templateSuffix) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+		templates[strings.TrimSuffix(entry.Name(), templateSuffix)] = string(data)
+	}
+	return nil
+}
+
+// WriteZip 把生成的文件打包为 zip，全部文件位于 root 目录下（root 为空时位于根目录）
+func WriteZip(w io.Writer, root string, files []File) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now()
+	for _, file := range files {
+		name := file.Name
+		if root != "" {
+			name = root + "/" + name
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
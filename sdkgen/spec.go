@@ -0,0 +1,617 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\sdkgen\spec.go
+ * @Description: 规范解析 - 把 Swagger 2.0 / OpenAPI 3 规范转换为模板使用的 API 模型：
+ *               操作（路径 / 查询参数、请求体、响应）与模型（definitions / components.schemas），
+ *               并完成 TypeScript 与 Go 的类型映射和标识符处理
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package sdkgen
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// API 模板数据
+type API struct {
+	Title      string       // 规范标题
+	Version    string       // 规范版本，不符合语义化版本时为 0.0.0
+	Package    string       // TypeScript 包名
+	GoPackage  string       // Go 包名
+	GoModule   string       // Go 模块路径
+	Operations []*Operation // 操作，按路径与方法排序
+	Models     []*Model     // 模型，按名称排序
+}
+
+// Operation 一个 HTTP 操作
+type Operation struct {
+	Name          string   // TypeScript 方法名
+	GoName        string   // Go 方法名
+	Method        string   // HTTP 方法（大写）
+	Path          string   // 原始路径
+	Summary       string   // 摘要（单行）
+	Deprecated    bool     // 是否已废弃
+	PathParams    []*Param // 路径参数，按出现顺序
+	QueryParams   []*Param // 查询参数
+	QueryRequired bool     // 是否存在必填查询参数
+	Body          *Type    // 请求体类型，无请求体时为 nil
+	Response      *Type    // 成功响应类型，无响应体时为 nil
+	TSPath        string   // TypeScript 模板字符串内容，路径参数已替换为 ${encodeURIComponent(...)}
+	GoPathFormat  string   // Go fmt 格式串，路径参数已替换为 %s
+}
+
+// Param 参数
+type Param struct {
+	Name     string // 原始参数名
+	TSName   string // TypeScript 属性名（必要时带引号）
+	Ident    string // TypeScript 标识符
+	GoIdent  string // Go 标识符
+	Type     *Type  // 参数类型
+	Required bool   // 是否必填
+	Multi    bool   // 路径参数可跨多段（grpc-gateway 的 {name=a/*} 写法），斜杠不转义
+}
+
+// Model 模型
+type Model struct {
+	Name        string   // TypeScript 类型名
+	GoName      string   // Go 类型名
+	Description string   // 描述（单行）
+	Fields      []*Field // 字段，按 JSON 名排序
+	Enum        []string // 字符串枚举值，非空时为枚举类型
+	Alias       *Type    // 非对象的模型（如数组、Map）对应的类型，非空时为类型别名
+}
+
+// Field 模型字段
+type Field struct {
+	JSONName    string // JSON 字段名
+	TSName      string // TypeScript 属性名（必要时带引号）
+	GoName      string // Go 字段名
+	Description string // 描述（单行）
+	Type        *Type  // 字段类型
+	Required    bool   // 是否必填
+}
+
+// Type 类型在两种语言中的写法
+type Type struct {
+	TS      string // TypeScript 类型
+	Go      string // Go 类型
+	GoField string // 作为 Go 结构体字段时的类型，引用结构体模型时为指针以避免递归定义
+}
+
+// pathParamPattern 路径参数，兼容 grpc-gateway 的 {name=pattern} 写法
+var pathParamPattern = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// semverPattern 语义化版本
+var semverPattern = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+
+// httpMethods 规范中可能出现的操作方法
+var httpMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch,
+}
+
+// reservedWords TypeScript 与 Go 中不能作为标识符的关键字
+var reservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "chan": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "defer": true, "delete": true, "do": true,
+	"else": true, "enum": true, "export": true, "extends": true, "fallthrough": true, "false": true,
+	"finally": true, "for": true, "func": true, "function": true, "go": true, "goto": true,
+	"if": true, "import": true, "in": true, "instanceof": true, "interface": true, "map": true,
+	"new": true, "null": true, "package": true, "range": true, "return": true, "select": true,
+	"struct": true, "super": true, "switch": true, "this": true, "throw": true, "true": true,
+	"try": true, "type": true, "typeof": true, "var": true, "void": true, "while": true, "with": true,
+	// 生成代码中已占用的名字
+	"body": true, "ctx": true, "query": true, "path": true, "out": true,
+}
+
+// parser 规范解析状态
+type parser struct {
+	schemas map[string]map[string]any // 原始模型名 -> schema
+	names   map[string]string         // 原始模型名 -> 类型名
+	structs map[string]bool           // 原始模型名是否为结构体（有属性的对象）
+}
+
+// buildAPI 解析一个或多个规范，多个规范的操作与模型合并，同名模型以先出现者为准
+func buildAPI(cfg Config, specs ...map[string]any) (*API, error) {
+	if len(specs) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "sdkgen: no spec")
+	}
+	p := &parser{
+		schemas: make(map[string]map[string]any),
+		names:   make(map[string]string),
+		structs: make(map[string]bool),
+	}
+	api := &API{Package: cfg.Package, GoPackage: goPackageName(cfg.Package), GoModule: cfg.GoModule}
+
+	// 先收集全部模型名，使操作与字段中的引用都能解析
+	used := make(map[string]bool)
+	for _, spec := range specs {
+		schemas := specSchemas(spec)
+		names := make([]string, 0, len(schemas))
+		for name := range schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			schema := schemas[name]
+			if _, ok := p.schemas[name]; ok {
+				continue
+			}
+			p.schemas[name] = schema
+			p.names[name] = uniqueName(exportedIdent(name), used)
+			p.structs[name] = isStruct(schema)
+		}
+	}
+	for name, schema := range p.schemas {
+		api.Models = append(api.Models, p.model(name, schema))
+	}
+	sort.Slice(api.Models, func(i, j int) bool { return api.Models[i].Name < api.Models[j].Name })
+
+	for _, spec := range specs {
+		info, _ := spec["info"].(map[string]any)
+		if api.Title == "" {
+			api.Title, _ = info["title"].(string)
+		}
+		if api.Version == "" {
+			if version, _ := info["version"].(string); semverPattern.MatchString(version) {
+				api.Version = version
+			}
+		}
+		basePath, _ := spec["basePath"].(string)
+		basePath = strings.TrimSuffix(basePath, "/")
+		paths, _ := spec["paths"].(map[string]any)
+		for route, raw := range paths {
+			item, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			common, _ := item["parameters"].([]any)
+			for _, method := range httpMethods {
+				op, ok := item[strings.ToLower(method)].(map[string]any)
+				if !ok {
+					continue
+				}
+				api.Operations = append(api.Operations, p.operation(method, basePath+route, op, common))
+			}
+		}
+	}
+	if len(api.Operations) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "sdkgen: spec has no operations")
+	}
+	if api.Title == "" {
+		api.Title = "API"
+	}
+	if api.Version == "" {
+		api.Version = "0.0.0"
+	}
+	sort.Slice(api.Operations, func(i, j int) bool {
+		a, b := api.Operations[i], api.Operations[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return slices.Index(httpMethods, a.Method) < slices.Index(httpMethods, b.Method)
+	})
+	nameOperations(api.Operations)
+	return api, nil
+}
+
+// specSchemas Swagger 2.0 的 definitions 或 OpenAPI 3 的 components.schemas
+func specSchemas(spec map[string]any) map[string]map[string]any {
+	raw, _ := spec["definitions"].(map[string]any)
+	if raw == nil {
+		components, _ := spec["components"].(map[string]any)
+		raw, _ = components["schemas"].(map[string]any)
+	}
+	schemas := make(map[string]map[string]any, len(raw))
+	for name, schema := range raw {
+		if m, ok := schema.(map[string]any); ok {
+			schemas[name] = m
+		}
+	}
+	return schemas
+}
+
+// model 构造模型
+func (p *parser) model(name string, schema map[string]any) *Model {
+	m := &Model{
+		Name:        p.names[name],
+		GoName:      p.names[name],
+		Description: docLine(schema),
+		Enum:        stringEnum(schema),
+	}
+	if len(m.Enum) > 0 {
+		return m
+	}
+	if !p.structs[name] {
+		m.Alias = p.typeOf(schema)
+		return m
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	required := stringList(schema["required"])
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	used := make(map[string]bool)
+	for _, key := range keys {
+		prop, _ := properties[key].(map[string]any)
+		m.Fields = append(m.Fields, &Field{
+			JSONName:    key,
+			TSName:      tsPropertyName(key),
+			GoName:      uniqueName(exportedIdent(key), used),
+			Description: docLine(prop),
+			Type:        p.typeOf(prop),
+			Required:    slices.Contains(required, key),
+		})
+	}
+	return m
+}
+
+// operation 构造操作，名称在全部操作收集后统一分配
+func (p *parser) operation(method, route string, op map[string]any, common []any) *Operation {
+	o := &Operation{
+		Method:     method,
+		Path:       route,
+		Summary:    docLine(op),
+		Deprecated: op["deprecated"] == true,
+	}
+	operationID, _ := op["operationId"].(string)
+	o.Name = operationID
+
+	params := make(map[string]map[string]any)
+	var order []string
+	for _, raw := range append(slices.Clone(common), anySlice(op["parameters"])...) {
+		param, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := param["name"].(string)
+		key := fmt.Sprint(param["in"]) + ":" + name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+
+	used := make(map[string]bool)
+	for _, key := range order {
+		param := params[key]
+		name, _ := param["name"].(string)
+		schema := param
+		if s, ok := param["schema"].(map[string]any); ok {
+			schema = s
+		}
+		switch param["in"] {
+		case "path":
+			o.PathParams = append(o.PathParams, p.param(name, schema, true, used))
+		case "query":
+			required := param["required"] == true
+			o.QueryParams = append(o.QueryParams, p.param(name, schema, required, used))
+			o.QueryRequired = o.QueryRequired || required
+		case "body":
+			o.Body = p.typeOf(schema)
+		}
+	}
+	if body, ok := op["requestBody"].(map[string]any); ok {
+		if schema := jsonContentSchema(body); schema != nil {
+			o.Body = p.typeOf(schema)
+		}
+	}
+	o.Response = p.response(op)
+
+	// 路径参数按出现顺序排列；规范未声明的路径参数按字符串补齐
+	declared := o.PathParams
+	o.PathParams = nil
+	tsPath := strings.ReplaceAll(route, "`", "\\`")
+	goPath := strings.ReplaceAll(route, "%", "%%")
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route, -1) {
+		name := match[1]
+		idx := slices.IndexFunc(declared, func(param *Param) bool { return param.Name == name })
+		var param *Param
+		if idx >= 0 {
+			param = declared[idx]
+		} else {
+			param = p.param(name, map[string]any{"type": "string"}, true, used)
+		}
+		param.Multi = strings.Contains(match[2], "/")
+		o.PathParams = append(o.PathParams, param)
+		escaped := "encodeURIComponent(String(" + param.Ident + "))"
+		if param.Multi {
+			escaped = "String(" + param.Ident + ").split(\"/\").map(encodeURIComponent).join(\"/\")"
+		}
+		tsPath = strings.Replace(tsPath, match[0], "${"+escaped+"}", 1)
+		goPath = strings.Replace(goPath, match[0], "%s", 1)
+	}
+	o.TSPath = tsPath
+	o.GoPathFormat = goPath
+	return o
+}
+
+// param 构造参数
+func (p *parser) param(name string, schema map[string]any, required bool, used map[string]bool) *Param {
+	ident := uniqueName(lowerIdent(name), used)
+	return &Param{
+		Name:     name,
+		TSName:   tsPropertyName(name),
+		Ident:    ident,
+		GoIdent:  ident,
+		Type:     p.typeOf(schema),
+		Required: required,
+	}
+}
+
+// response 取第一个 2xx 响应的 JSON 类型
+func (p *parser) response(op map[string]any) *Type {
+	responses, _ := op["responses"].(map[string]any)
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		resp, _ := responses[code].(map[string]any)
+		schema, _ := resp["schema"].(map[string]any)
+		if schema == nil {
+			schema = jsonContentSchema(resp)
+		}
+		if schema != nil {
+			return p.typeOf(schema)
+		}
+	}
+	return nil
+}
+
+// jsonContentSchema OpenAPI 3 content 中 JSON 媒体类型的 schema
+func jsonContentSchema(obj map[string]any) map[string]any {
+	content, _ := obj["content"].(map[string]any)
+	for _, mediaType := range []string{"application/json", "*/*"} {
+		if media, ok := content[mediaType].(map[string]any); ok {
+			if schema, ok := media["schema"].(map[string]any); ok {
+				return schema
+			}
+		}
+	}
+	return nil
+}
+
+// typeOf schema 到两种语言类型的映射，无法识别的 schema 退化为 unknown / any
+func (p *parser) typeOf(schema map[string]any) *Type {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		typeName, ok := p.names[name]
+		if !ok {
+			return &Type{TS: "unknown", Go: "any", GoField: "any"}
+		}
+		goField := typeName
+		if p.structs[name] {
+			goField = "*" + typeName
+		}
+		return &Type{TS: typeName, Go: typeName, GoField: goField}
+	}
+	if all, ok := schema["allOf"].([]any); ok && len(all) == 1 {
+		if inner, ok := all[0].(map[string]any); ok {
+			return p.typeOf(inner)
+		}
+	}
+
+	switch schema["type"] {
+	case "string":
+		if enum := stringEnum(schema); len(enum) > 0 {
+			quoted := make([]string, len(enum))
+			for i, value := range enum {
+				quoted[i] = fmt.Sprintf("%q", value)
+			}
+			return scalar(strings.Join(quoted, " | "), "string")
+		}
+		return scalar("string", "string")
+	case "boolean":
+		return scalar("boolean", "bool")
+	case "integer":
+		switch schema["format"] {
+		case "int32":
+			return scalar("number", "int32")
+		case "uint32":
+			return scalar("number", "uint32")
+		case "uint64":
+			return scalar("number", "uint64")
+		}
+		return scalar("number", "int64")
+	case "number":
+		if schema["format"] == "float" {
+			return scalar("number", "float32")
+		}
+		return scalar("number", "float64")
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		item := p.typeOf(items)
+		ts := item.TS
+		if strings.Contains(ts, " ") {
+			ts = "(" + ts + ")"
+		}
+		return &Type{TS: ts + "[]", Go: "[]" + item.Go, GoField: "[]" + item.Go}
+	case "object", nil:
+		if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+			value := p.typeOf(additional)
+			return &Type{
+				TS:      "Record<string, " + value.TS + ">",
+				Go:      "map[string]" + value.Go,
+				GoField: "map[string]" + value.Go,
+			}
+		}
+		if schema["type"] == "object" {
+			return scalar("Record<string, unknown>", "map[string]any")
+		}
+	}
+	return scalar("unknown", "any")
+}
+
+// scalar 非引用类型，字段写法与值写法相同
+func scalar(ts, goType string) *Type {
+	return &Type{TS: ts, Go: goType, GoField: goType}
+}
+
+// nameOperations 分配方法名：优先使用 operationId 中最后一段（如 UserService_GetUser 的 GetUser），
+// 冲突时使用完整 operationId，缺失时由方法与路径生成
+func nameOperations(ops []*Operation) {
+	short := make(map[string]int)
+	for _, op := range ops {
+		if op.Name == "" {
+			op.Name = op.Method + " " + op.Path
+		}
+		short[shortOperationName(op.Name)]++
+	}
+	used := make(map[string]bool)
+	for _, op := range ops {
+		name := shortOperationName(op.Name)
+		if short[name] > 1 {
+			name = op.Name
+		}
+		goName := uniqueName(exportedIdent(name), used)
+		op.GoName = goName
+		op.Name = lowerIdent(goName)
+	}
+}
+
+// shortOperationName operationId 中最后一个下划线之后的部分
+func shortOperationName(operationID string) string {
+	if idx := strings.LastIndex(operationID, "_"); idx >= 0 && idx < len(operationID)-1 {
+		return operationID[idx+1:]
+	}
+	return operationID
+}
+
+// words 按非字母数字字符切分
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// exportedIdent 大驼峰标识符，如 user_id -> UserId、v1.GetUserRequest -> V1GetUserRequest
+func exportedIdent(s string) string {
+	var b strings.Builder
+	for _, word := range words(s) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	ident := b.String()
+	if ident == "" {
+		return "X"
+	}
+	if unicode.IsDigit([]rune(ident)[0]) {
+		return "X" + ident
+	}
+	return ident
+}
+
+// lowerIdent 小驼峰标识符，与关键字冲突时追加下划线
+func lowerIdent(s string) string {
+	runes := []rune(exportedIdent(s))
+	runes[0] = unicode.ToLower(runes[0])
+	ident := string(runes)
+	if reservedWords[ident] {
+		ident += "_"
+	}
+	return ident
+}
+
+// uniqueName 冲突时追加数字后缀
+func uniqueName(name string, used map[string]bool) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// identPattern 合法的 JavaScript 标识符
+var identPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName TypeScript 属性名，非标识符时加引号
+func tsPropertyName(name string) string {
+	if identPattern.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// goPackageName 由包名得到 Go 包名，如 @acme/gateway-client -> client
+func goPackageName(pkg string) string {
+	parts := words(strings.ToLower(pkg[strings.LastIndex(pkg, "/")+1:]))
+	if len(parts) == 0 {
+		return "client"
+	}
+	name := parts[len(parts)-1]
+	if unicode.IsDigit([]rune(name)[0]) || reservedWords[name] {
+		return "client"
+	}
+	return name
+}
+
+// docLine 取 title / description / summary 作为单行注释
+func docLine(obj map[string]any) string {
+	for _, key := range []string{"summary", "title", "description"} {
+		if s, ok := obj[key].(string); ok && s != "" {
+			return singleLine(s)
+		}
+	}
+	return ""
+}
+
+// singleLine 折叠为单行并避免提前结束块注释
+func singleLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "*/", "* /")
+}
+
+// isStruct 有属性，或未声明 additionalProperties 的对象
+func isStruct(schema map[string]any) bool {
+	if _, ok := schema["properties"].(map[string]any); ok {
+		return true
+	}
+	_, isMap := schema["additionalProperties"].(map[string]any)
+	return schema["type"] == "object" && !isMap
+}
+
+// stringEnum 字符串枚举值
+func stringEnum(schema map[string]any) []string {
+	if schema["type"] != "string" {
+		return nil
+	}
+	return stringList(schema["enum"])
+}
+
+// stringList []any 中的字符串
+func stringList(v any) []string {
+	var list []string
+	for _, item := range anySlice(v) {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// anySlice 断言为 []any
+func anySlice(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
@@ -0,0 +1,72 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\search.go
+ * @Description: Gateway 检索接入 - 按 WithSearch 或配置文件 search 段创建 Elasticsearch / OpenSearch 客户端，
+ *               注册健康检查与检索路由
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/search"
+)
+
+// RegisterSearchRoute 注册检索路由，GET 查询参数按 mapping 转换为查询 DSL 后检索 index，需先通过 WithSearch 或 search 配置段启用
+//
+// 使用示例:
+//
+//	gw.RegisterSearchRoute("/api/v1/products/search", "products", search.Mapping{
+//		Fields:    []string{"name^3", "description"},
+//		Filters:   map[string]string{"brand": "brand.keyword", "price": "price"},
+//		Sortable:  map[string]string{"price": "price", "created": "created_at"},
+//		Highlight: []string{"name", "description"},
+//	})
+//	// GET /api/v1/products/search?q=phone&brand=apple,huawei&price__gte=1000&sort=-price&page=2
+func (g *Gateway) RegisterSearchRoute(path, index string, mapping search.Mapping) error {
+	if g.searchClient == nil {
+		return errors.NewError(errors.ErrCodeServiceUnavailable, "search is not configured")
+	}
+	if path == "" || index == "" {
+		return errors.NewError(errors.ErrCodeInvalidParameter, "search route requires path and index")
+	}
+	g.RegisterHTTPRoute(path, search.Handler(g.searchClient, index, mapping).ServeHTTP)
+	return nil
+}
+
+// SearchClient 获取检索客户端，未配置时返回 nil
+func (g *Gateway) SearchClient() *search.Client {
+	return g.searchClient
+}
+
+// initSearch 创建检索客户端，注册健康检查与配置中声明的检索路由
+func (g *Gateway) initSearch(cfg *search.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	client, err := search.NewClient(*cfg)
+	if err != nil {
+		return err
+	}
+	g.searchClient = client
+
+	healthPath := client.Config().HealthPath
+	if healthPath == "-" {
+		healthPath = ""
+	}
+	g.Server.RegisterHealthChecker(search.NewChecker(client), healthPath)
+
+	for _, route := range cfg.Routes {
+		if err := g.RegisterSearchRoute(route.Path, route.Index, route.Mapping); err != nil {
+			return err
+		}
+	}
+	global.LOGGER.InfoKV("检索服务已启用", "addresses", cfg.Addresses, "routes", len(cfg.Routes))
+	return nil
+}
@@ -0,0 +1,260 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\search\client.go
+ * @Description: Elasticsearch / OpenSearch 客户端 - 基于 REST API，多节点轮询，网络错误与 5xx 时切换节点重试，
+ *               支持 Basic 与 ApiKey 认证
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package search 提供 Elasticsearch / OpenSearch 检索接口：查询参数转换为查询 DSL，返回分页与高亮结果
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-toolbox/pkg/httpx"
+	"gopkg.in/yaml.v3"
+)
+
+// 默认值
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultHealthPath = "/health/search"
+)
+
+// maxErrorBody 读取错误响应的上限
+const maxErrorBody = 64 << 10
+
+// Config 检索客户端配置，可写在配置文件顶层 search 段
+type Config struct {
+	Addresses          []string      `json:"addresses" yaml:"addresses" mapstructure:"addresses"`                                  // 节点地址，如 http://es-0:9200，多个时轮询
+	Username           string        `json:"username" yaml:"username" mapstructure:"username"`                                     // Basic 认证用户名
+	Password           string        `json:"password" yaml:"password" mapstructure:"password"`                                     // Basic 认证密码
+	APIKey             string        `json:"api_key" yaml:"api-key" mapstructure:"api-key"`                                        // ApiKey 认证（base64 编码的 id:key），优先于 Basic
+	Timeout            time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`                                        // 单次请求超时，默认 10s
+	MaxRetries         int           `json:"max_retries" yaml:"max-retries" mapstructure:"max-retries"`                            // 网络错误与 5xx 时切换节点重试的次数，默认为节点数 - 1
+	CAFile             string        `json:"ca_file" yaml:"ca-file" mapstructure:"ca-file"`                                        // https 节点的 CA 证书
+	InsecureSkipVerify bool          `json:"insecure_skip_verify" yaml:"insecure-skip-verify" mapstructure:"insecure-skip-verify"` // 跳过证书校验，仅用于测试环境
+	HealthPath         string        `json:"health_path" yaml:"health-path" mapstructure:"health-path"`                            // 组件健康检查路径，默认 /health/search，"-" 不注册
+	Routes             []RouteConfig `json:"routes" yaml:"routes" mapstructure:"routes"`                                           // 配置文件声明的检索路由
+}
+
+// RouteConfig 配置文件声明的检索路由
+type RouteConfig struct {
+	Path    string  `json:"path" yaml:"path" mapstructure:"path"`
+	Index   string  `json:"index" yaml:"index" mapstructure:"index"` // 索引名或别名，支持逗号分隔与通配符
+	Mapping Mapping `json:"mapping" yaml:"mapping" mapstructure:"mapping"`
+}
+
+// ParseConfig 解析配置文件中的 search 段
+func ParseConfig(raw any) (*Config, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "search: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	cfg := &Config{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "search: %v", err)
+	}
+	return cfg, nil
+}
+
+// Client Elasticsearch / OpenSearch 客户端，并发安全
+type Client struct {
+	config Config
+	nodes  []*url.URL
+	next   atomic.Uint32
+	http   *http.Client
+}
+
+// NewClient 创建客户端
+func NewClient(cfg Config) (*Client, error) {
+	if len(cfg.Addresses) == 0 {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "search: at least one address is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = len(cfg.Addresses) - 1
+	}
+	if cfg.HealthPath == "" {
+		cfg.HealthPath = DefaultHealthPath
+	}
+
+	c := &Client{config: cfg}
+	for _, addr := range cfg.Addresses {
+		u, err := url.Parse(strings.TrimRight(addr, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "search: invalid address %q", addr)
+		}
+		c.nodes = append(c.nodes, u)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} //nolint:gosec // 由配置显式开启
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "search: read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "search: no certificate found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Transport: transport, Timeout: cfg.Timeout}
+	return c, nil
+}
+
+// Config 客户端配置（已填充默认值）
+func (c *Client) Config() Config {
+	return c.config
+}
+
+// Close 关闭空闲连接
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// SearchResponse _search 响应中使用的部分
+type SearchResponse struct {
+	Took int `json:"took"`
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []SearchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchHit 命中的文档
+type SearchHit struct {
+	Index     string              `json:"_index"`
+	ID        string              `json:"_id"`
+	Score     *float64            `json:"_score"`
+	Source    json.RawMessage     `json:"_source"`
+	Highlight map[string][]string `json:"highlight"`
+}
+
+// ClusterHealth 集群健康状态
+type ClusterHealth struct {
+	ClusterName      string `json:"cluster_name"`
+	Status           string `json:"status"` // green / yellow / red
+	NumberOfNodes    int    `json:"number_of_nodes"`
+	ActiveShards     int    `json:"active_shards"`
+	UnassignedShards int    `json:"unassigned_shards"`
+}
+
+// Search 对索引执行 _search，body 为查询 DSL
+func (c *Client) Search(ctx context.Context, index string, body any) (*SearchResponse, *errors.AppError) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInternalServerError, "search: encode query: %v", err)
+	}
+	out := &SearchResponse{}
+	if appErr := c.do(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_search", payload, out); appErr != nil {
+		return nil, appErr
+	}
+	return out, nil
+}
+
+// Health 查询集群健康状态
+func (c *Client) Health(ctx context.Context) (*ClusterHealth, *errors.AppError) {
+	out := &ClusterHealth{}
+	if appErr := c.do(ctx, http.MethodGet, "/_cluster/health", nil, out); appErr != nil {
+		return nil, appErr
+	}
+	return out, nil
+}
+
+// do 发送请求并解码响应；网络错误与 5xx 时换下一个节点重试
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) *errors.AppError {
+	var lastErr *errors.AppError
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		node := c.nodes[int(c.next.Add(1)-1)%len(c.nodes)]
+		req, err := http.NewRequestWithContext(ctx, method, node.String()+path, bytes.NewReader(body))
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeInternalServerError, "search: %v", err)
+		}
+		req.Header.Set(constants.HeaderContentType, httpx.ContentTypeApplicationJSON)
+		req.Header.Set(constants.HeaderAccept, httpx.ContentTypeApplicationJSON)
+		switch {
+		case c.config.APIKey != "":
+			req.Header.Set(constants.HeaderAuthorization, "ApiKey "+c.config.APIKey)
+		case c.config.Username != "":
+			req.SetBasicAuth(c.config.Username, c.config.Password)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.NewErrorf(errors.ErrCodeGatewayTimeout, "search: %v", ctx.Err())
+			}
+			var netErr interface{ Timeout() bool }
+			if stderrors.As(err, &netErr) && netErr.Timeout() {
+				lastErr = errors.NewErrorf(errors.ErrCodeGatewayTimeout, "search: %s timed out", node.Host)
+			} else {
+				lastErr = errors.NewErrorf(errors.ErrCodeServiceUnavailable, "search: %s unreachable: %v", node.Host, err)
+			}
+			continue
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			lastErr = responseError(resp)
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				continue
+			}
+			return lastErr
+		}
+		err = json.NewDecoder(resp.Body).Decode(out)
+		resp.Body.Close()
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "search: decode response from %s: %v", node.Host, err)
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// responseError 错误响应转换：400 为查询参数问题，其余视为检索服务不可用
+func responseError(resp *http.Response) *errors.AppError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	reason := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error.Reason != "" {
+		reason = fmt.Sprintf("%s: %s", body.Error.Type, body.Error.Reason)
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "search: %s", reason)
+	}
+	return errors.NewErrorf(errors.ErrCodeServiceUnavailable, "search: status %d: %s", resp.StatusCode, reason)
+}
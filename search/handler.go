@@ -0,0 +1,143 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\search\handler.go
+ * @Description: 检索路由处理器与健康检查 - 返回 items / paging 结构，命中文档附带相关度与高亮片段；
+ *               集群状态 green / yellow / red 对应健康检查 ok / warning / error
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 检索指标
+var (
+	searchRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_search_requests_total",
+		Help: "Total number of search route requests by index and result",
+	}, []string{"index", "result"})
+	searchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_search_duration_seconds",
+		Help:    "Search backend round-trip duration in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"index"})
+)
+
+// Hit 检索结果条目
+type Hit struct {
+	ID        string              `json:"id"`
+	Index     string              `json:"index"`
+	Score     *float64            `json:"score,omitempty"`
+	Source    json.RawMessage     `json:"source"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+// Paging 分页信息
+type Paging struct {
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+// Result 检索响应
+type Result struct {
+	Items  []Hit   `json:"items"`
+	Paging *Paging `json:"paging"`
+	TookMs int     `json:"took_ms"` // 检索服务端耗时
+}
+
+// Handler 检索路由处理器：GET 查询参数转换为 DSL 后对 index 执行检索
+func Handler(client *Client, index string, mapping Mapping) http.Handler {
+	mapping = mapping.withDefaults()
+	return response.Handle(func(c *response.Context) error {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return errors.NewErrorf(errors.ErrCodeMethodNotAllowed, "method %s is not allowed", c.Request.Method)
+		}
+		q, appErr := buildQuery(c.Request.URL.Query(), mapping)
+		if appErr != nil {
+			searchRequests.WithLabelValues(index, "invalid").Inc()
+			return appErr
+		}
+
+		start := time.Now()
+		resp, appErr := client.Search(c.Context(), index, q.body)
+		searchDuration.WithLabelValues(index).Observe(time.Since(start).Seconds())
+		if appErr != nil {
+			searchRequests.WithLabelValues(index, "error").Inc()
+			return appErr
+		}
+		searchRequests.WithLabelValues(index, "ok").Inc()
+
+		result := &Result{
+			Items:  make([]Hit, 0, len(resp.Hits.Hits)),
+			Paging: &Paging{Page: q.page, Size: q.size, Total: resp.Hits.Total.Value},
+			TookMs: resp.Took,
+		}
+		for _, h := range resp.Hits.Hits {
+			result.Items = append(result.Items, Hit{ID: h.ID, Index: h.Index, Score: h.Score, Source: h.Source, Highlight: h.Highlight})
+		}
+		return c.Respond(result)
+	}, nil)
+}
+
+// Checker 检索集群健康检查器，实现 middleware.HealthChecker
+type Checker struct {
+	client *Client
+}
+
+// NewChecker 创建健康检查器
+func NewChecker(client *Client) *Checker {
+	return &Checker{client: client}
+}
+
+// Name 检查器名称
+func (h *Checker) Name() string {
+	return "search"
+}
+
+// Check 查询集群健康状态
+func (h *Checker) Check(ctx context.Context) middleware.HealthStatus {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, h.client.config.Timeout)
+	defer cancel()
+
+	health, appErr := h.client.Health(ctx)
+	status := middleware.HealthStatus{Latency: time.Since(start), CheckedAt: start}
+	if appErr != nil {
+		status.Status = "error"
+		status.Message = appErr.Error()
+		return status
+	}
+
+	switch health.Status {
+	case "green":
+		status.Status, status.Message = "ok", "Search cluster is healthy"
+	case "yellow":
+		status.Status, status.Message = "warning", "Search cluster has unassigned replica shards"
+	default:
+		status.Status, status.Message = "error", "Search cluster status is "+health.Status
+	}
+	status.Details = map[string]any{
+		"cluster_name":      health.ClusterName,
+		"cluster_status":    health.Status,
+		"number_of_nodes":   health.NumberOfNodes,
+		"active_shards":     health.ActiveShards,
+		"unassigned_shards": health.UnassignedShards,
+	}
+	return status
+}
@@ -0,0 +1,188 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\search\query.go
+ * @Description: 查询参数到查询 DSL 的转换 - q 全文检索、字段过滤（等值 / 多值 / 范围）、排序、分页与高亮，
+ *               只有映射中声明的参数会进入查询
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package search
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+)
+
+// 保留的查询参数
+const (
+	QueryText = "q"
+	QueryPage = "page"
+	QuerySize = "size"
+	QuerySort = "sort"
+)
+
+// 分页默认值
+const (
+	DefaultPageSize        = 20
+	DefaultMaxPageSize     = 100
+	DefaultMaxResultWindow = 10000 // 与 index.max_result_window 默认值一致
+)
+
+// rangeOperators 过滤后缀与 range 查询运算符
+var rangeOperators = map[string]string{
+	"gt":  "gt",
+	"gte": "gte",
+	"lt":  "lt",
+	"lte": "lte",
+}
+
+// Mapping 检索路由的参数映射
+type Mapping struct {
+	Fields          []string          `json:"fields" yaml:"fields" mapstructure:"fields"`                                  // q 检索的字段，支持 ^ 权重（如 title^3），为空时使用索引默认字段
+	Fuzziness       string            `json:"fuzziness" yaml:"fuzziness" mapstructure:"fuzziness"`                         // 模糊匹配（如 AUTO），为空不启用
+	Filters         map[string]string `json:"filters" yaml:"filters" mapstructure:"filters"`                               // 过滤参数名 → 字段名（keyword / 数值 / 日期字段）
+	Sortable        map[string]string `json:"sortable" yaml:"sortable" mapstructure:"sortable"`                            // 排序参数名 → 字段名，_score 始终可用
+	Highlight       []string          `json:"highlight" yaml:"highlight" mapstructure:"highlight"`                         // 高亮字段
+	HighlightTags   [2]string         `json:"highlight_tags" yaml:"highlight-tags" mapstructure:"highlight-tags"`          // 高亮前后标签，默认 <em> </em>
+	Source          []string          `json:"source" yaml:"source" mapstructure:"source"`                                  // 返回的 _source 字段，为空返回全部
+	PageSize        int               `json:"page_size" yaml:"page-size" mapstructure:"page-size"`                         // 默认每页条数，默认 20
+	MaxPageSize     int               `json:"max_page_size" yaml:"max-page-size" mapstructure:"max-page-size"`             // 每页条数上限，默认 100
+	MaxResultWindow int               `json:"max_result_window" yaml:"max-result-window" mapstructure:"max-result-window"` // 可翻页的最大深度（from + size），默认 10000
+}
+
+// withDefaults 填充默认值
+func (m Mapping) withDefaults() Mapping {
+	if m.PageSize <= 0 {
+		m.PageSize = DefaultPageSize
+	}
+	if m.MaxPageSize <= 0 {
+		m.MaxPageSize = DefaultMaxPageSize
+	}
+	if m.PageSize > m.MaxPageSize {
+		m.PageSize = m.MaxPageSize
+	}
+	if m.MaxResultWindow <= 0 {
+		m.MaxResultWindow = DefaultMaxResultWindow
+	}
+	if m.HighlightTags[0] == "" && m.HighlightTags[1] == "" {
+		m.HighlightTags = [2]string{"<em>", "</em>"}
+	}
+	return m
+}
+
+// query 解析后的检索请求
+type query struct {
+	page, size int
+	body       map[string]any
+}
+
+// buildQuery 将查询参数转换为 _search 请求体
+//
+//	q=phone&brand=apple,huawei&price__gte=1000&sort=-price&page=2&size=10
+func buildQuery(values url.Values, m Mapping) (*query, *errors.AppError) {
+	q := &query{page: 1, size: m.PageSize}
+	var err error
+	if raw := values.Get(QueryPage); raw != "" {
+		if q.page, err = strconv.Atoi(raw); err != nil || q.page < 1 {
+			return nil, errors.NewError(errors.ErrCodeInvalidParameter, "page must be a positive integer")
+		}
+	}
+	if raw := values.Get(QuerySize); raw != "" {
+		if q.size, err = strconv.Atoi(raw); err != nil || q.size < 1 || q.size > m.MaxPageSize {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "size must be between 1 and %d", m.MaxPageSize)
+		}
+	}
+	from := (q.page - 1) * q.size
+	if from+q.size > m.MaxResultWindow {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cannot page beyond the first %d results, refine the query instead", m.MaxResultWindow)
+	}
+
+	must := map[string]any{"match_all": map[string]any{}}
+	if text := strings.TrimSpace(values.Get(QueryText)); text != "" {
+		match := map[string]any{"query": text, "type": "best_fields"}
+		if len(m.Fields) > 0 {
+			match["fields"] = m.Fields
+		}
+		if m.Fuzziness != "" {
+			match["fuzziness"] = m.Fuzziness
+		}
+		must = map[string]any{"multi_match": match}
+	}
+
+	filters := []any{}
+	for key, vals := range values {
+		switch key {
+		case QueryText, QueryPage, QuerySize, QuerySort:
+			continue
+		}
+		name, op, _ := strings.Cut(key, "__")
+		field, ok := m.Filters[name]
+		rangeOp, isRange := rangeOperators[op]
+		if !ok || (op != "" && !isRange) {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "unsupported filter %q", key)
+		}
+		for _, v := range vals {
+			switch {
+			case isRange:
+				filters = append(filters, map[string]any{"range": map[string]any{field: map[string]any{rangeOp: v}}})
+			case strings.Contains(v, ","):
+				filters = append(filters, map[string]any{"terms": map[string]any{field: strings.Split(v, ",")}})
+			default:
+				filters = append(filters, map[string]any{"term": map[string]any{field: v}})
+			}
+		}
+	}
+
+	sort := []any{}
+	if raw := values.Get(QuerySort); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			order := "asc"
+			if strings.HasPrefix(name, "-") {
+				order = "desc"
+			}
+			field, ok := m.Sortable[strings.TrimPrefix(name, "-")]
+			if strings.TrimPrefix(name, "-") == "_score" {
+				field, ok = "_score", true
+			}
+			if !ok {
+				return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "cannot sort by %q", name)
+			}
+			sort = append(sort, map[string]any{field: map[string]any{"order": order}})
+		}
+	}
+
+	q.body = map[string]any{
+		"from":             from,
+		"size":             q.size,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": map[string]any{"must": must, "filter": filters}},
+	}
+	if len(sort) > 0 {
+		q.body["sort"] = sort
+		// 按字段排序时仍返回相关度分数
+		q.body["track_scores"] = true
+	}
+	if len(m.Source) > 0 {
+		q.body["_source"] = m.Source
+	}
+	if len(m.Highlight) > 0 {
+		fields := make(map[string]any, len(m.Highlight))
+		for _, f := range m.Highlight {
+			fields[f] = map[string]any{}
+		}
+		q.body["highlight"] = map[string]any{
+			"fields":    fields,
+			"pre_tags":  []string{m.HighlightTags[0]},
+			"post_tags": []string{m.HighlightTags[1]},
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,74 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\authn.go
+ * @Description: 统一认证接入 - HTTP 中间件与 gRPC 拦截器共用同一组认证器与 Principal 规则
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"google.golang.org/grpc"
+)
+
+// SetAuthentication 设置统一认证，nil 关闭；对之后的请求立即生效
+// 认证先于外部授权与 Casbin 执行，后两者读取的即是这里产生的 Principal
+func (s *Server) SetAuthentication(cfg *middleware.AuthenticationConfig) error {
+	if cfg == nil {
+		s.authn.Store(nil)
+		return nil
+	}
+	authn, err := middleware.NewAuthentication(*cfg)
+	if err != nil {
+		return err
+	}
+	s.authn.Store(authn)
+	if !s.authnRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareAuthn, middleware.PriorityAuthn, s.authnMiddleware)
+	}
+	global.LOGGER.InfoKV("统一认证已启用",
+		"authenticators", len(cfg.Authenticators),
+		"rules", len(cfg.Rules),
+		"allow_anonymous", cfg.AllowAnonymous,
+		"skip_paths", cfg.SkipPaths)
+	return nil
+}
+
+// authnMiddleware HTTP 统一认证，未配置时直接放行
+func (s *Server) authnMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authn := s.authn.Load()
+		if authn == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authn.Handle(w, r, next)
+	})
+}
+
+// authnUnaryInterceptor gRPC 一元调用统一认证，未配置时直接放行
+func (s *Server) authnUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	authn := s.authn.Load()
+	if authn == nil {
+		return handler(ctx, req)
+	}
+	return authn.Unary(ctx, req, info, handler)
+}
+
+// authnStreamInterceptor gRPC 流式调用统一认证，未配置时直接放行
+func (s *Server) authnStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	authn := s.authn.Load()
+	if authn == nil {
+		return handler(srv, ss)
+	}
+	return authn.Stream(srv, ss, info, handler)
+}
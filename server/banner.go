@@ -14,17 +14,22 @@ package server
 import (
 	"context"
 	"fmt"
+	"strings"
+	"text/template"
 
 	"github.com/kamalyes/go-config/pkg/banner"
 	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
 	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
 )
 
 // BannerManager 横幅管理器
 type BannerManager struct {
-	ctx      context.Context
-	config   *gwconfig.Gateway
-	features []string
+	ctx        context.Context
+	config     *gwconfig.Gateway
+	features   []string
+	options    BannerOptions
+	lastReport *StartupReport // 最近一次启动成功后的启动报告，供横幅模板引用
 }
 
 // NewBannerManager 创建横幅管理器
@@ -56,13 +61,9 @@ func (b *BannerManager) printStartupBanner(report startupReport) {
 		return
 	}
 
-	if report.bannerTemplate != "" {
-		global.LOGGER.InfoContext(b.ctx, report.bannerTemplate)
-	} else {
-		global.LOGGER.InfoContext(b.ctx, banner.Default().Template)
-	}
-	global.LOGGER.InfoContext(b.ctx, "🚀 %s - Enterprise Edition", report.title)
-	global.LOGGER.InfoContext(b.ctx, "")
+	b.info("%s", b.renderBannerTemplate(report))
+	b.info("🚀 %s - Enterprise Edition", report.title)
+	b.info("")
 
 	b.printFieldSection("📋 基础信息", []startupField{
 		{label: "🏷️  名称", value: report.title},
@@ -92,33 +93,33 @@ func (b *BannerManager) printStartupBanner(report startupReport) {
 		{label: "⏰ 启动时间", value: report.runtime.startedAt},
 	})
 
-	global.LOGGER.InfoContext(b.ctx, "🎉 ================================================")
-	global.LOGGER.InfoContext(b.ctx, "")
+	b.info("🎉 ================================================")
+	b.info("")
 }
 
 // PrintShutdownBanner 打印关闭横幅
 func (b *BannerManager) PrintShutdownBanner() {
-	global.LOGGER.InfoContext(b.ctx, "🛑 ================================================")
-	global.LOGGER.InfoContext(b.ctx, "⏹️  Gateway正在优雅关闭...")
-	global.LOGGER.InfoContext(b.ctx, "🛑 ================================================")
+	b.info("🛑 ================================================")
+	b.info("⏹️  Gateway正在优雅关闭...")
+	b.info("🛑 ================================================")
 }
 
 // PrintShutdownComplete 打印关闭完成
 func (b *BannerManager) PrintShutdownComplete() {
-	global.LOGGER.InfoContext(b.ctx, "✅ Gateway已安全关闭")
-	global.LOGGER.InfoContext(b.ctx, "👋 感谢使用 Go RPC Gateway！")
+	b.info("✅ Gateway已安全关闭")
+	b.info("👋 感谢使用 Go RPC Gateway！")
 }
 
 func (b *BannerManager) printMiddlewareStatus(report startupReport) {
-	global.LOGGER.InfoContext(b.ctx, "🔌 中间件状态:")
+	b.info("🔌 中间件状态:")
 	for _, item := range report.middleware {
 		status := "❌ 禁用"
 		if item.enabled {
 			status = "✅ 启用"
 		}
-		global.LOGGER.InfoContext(b.ctx, "   %s - %s (%s)", status, item.displayLabel(), item.name)
+		b.info("   %s - %s (%s)", status, item.displayLabel(), item.name)
 	}
-	global.LOGGER.InfoContext(b.ctx, "")
+	b.info("")
 }
 
 func (b *BannerManager) printUsageGuide(report startupReport) {
@@ -260,11 +261,11 @@ func (b *BannerManager) printFieldSection(title string, fields []startupField) {
 		return
 	}
 
-	global.LOGGER.InfoContext(b.ctx, title+":")
+	b.info("%s:", title)
 	for _, field := range fields {
-		global.LOGGER.InfoContext(b.ctx, "   %s: %s", field.label, field.value)
+		b.info("   %s: %s", field.label, field.value)
 	}
-	global.LOGGER.InfoContext(b.ctx, "")
+	b.info("")
 }
 
 func (b *BannerManager) printChecklist(title string, items []string) {
@@ -272,9 +273,71 @@ func (b *BannerManager) printChecklist(title string, items []string) {
 		return
 	}
 
-	global.LOGGER.InfoContext(b.ctx, title+":")
+	b.info("%s:", title)
 	for _, item := range items {
-		global.LOGGER.InfoContext(b.ctx, "   ✅ %s", item)
+		b.info("   ✅ %s", item)
+	}
+	b.info("")
+}
+
+// info 输出一行横幅信息，纯文本模式下去掉 emoji
+func (b *BannerManager) info(format string, args ...any) {
+	line := fmt.Sprintf(format, args...)
+	if b.options.Plain {
+		line = stripEmoji(line)
+	}
+	global.LOGGER.InfoContext(b.ctx, "%s", line)
+}
+
+// renderBannerTemplate 渲染横幅模板：选项模板优先，其次配置 Banner.Template，最后使用默认图案
+// 模板包含 {{ }} 时按 text/template 渲染，数据为最近一次的 StartupReport
+func (b *BannerManager) renderBannerTemplate(report startupReport) string {
+	tpl := mathx.IfEmpty(b.options.Template, mathx.IfEmpty(report.bannerTemplate, banner.Default().Template))
+	if !strings.Contains(tpl, "{{") || b.lastReport == nil {
+		return tpl
+	}
+
+	t, err := template.New("banner").Parse(tpl)
+	if err != nil {
+		global.LOGGER.WarnKV("横幅模板解析失败，按原文输出", "error", err)
+		return tpl
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, b.lastReport); err != nil {
+		global.LOGGER.WarnKV("横幅模板渲染失败，按原文输出", "error", err)
+		return tpl
+	}
+	return sb.String()
+}
+
+// isEmoji 是否为横幅中使用的 emoji / 图标字符（不含制表符与方块字符，默认横幅图案不受影响）
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000:
+		return true
+	case r >= 0x2300 && r <= 0x23FF, r >= 0x2600 && r <= 0x27BF, r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	case r == 0xFE0F || r == 0x200D:
+		return true
+	}
+	return false
+}
+
+// stripEmoji 去掉 emoji 及其后紧跟的空格
+func stripEmoji(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	skipSpace := false
+	for _, r := range s {
+		if isEmoji(r) {
+			skipSpace = true
+			continue
+		}
+		if skipSpace && r == ' ' {
+			continue
+		}
+		skipSpace = false
+		sb.WriteRune(r)
 	}
-	global.LOGGER.InfoContext(b.ctx, "")
+	return sb.String()
 }
@@ -0,0 +1,81 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\broadcast.go
+ * @Description: 广播推送接入 - 注册订阅接口与管理接口，替换配置时旧推送器断开其上的全部订阅连接
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetBroadcast 设置广播推送，nil 关闭；订阅接口经过中间件链，受认证与流式连接限制约束
+func (s *Server) SetBroadcast(cfg *middleware.BroadcastConfig) error {
+	if cfg == nil {
+		if old := s.broadcaster.Swap(nil); old != nil {
+			old.Close()
+			global.LOGGER.InfoKV("广播推送已关闭")
+		}
+		return nil
+	}
+
+	broadcaster, err := middleware.NewBroadcaster(*cfg)
+	if err != nil {
+		return err
+	}
+	if old := s.broadcaster.Swap(broadcaster); old != nil {
+		old.Close()
+	}
+
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(broadcaster.Path(), s.broadcastSubscribeHandler)
+	s.RegisterHTTPHandlerFunc(broadcaster.AdminPath(), s.broadcastAdminHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("广播推送已启用",
+		"path", broadcaster.Path(),
+		"admin_path", broadcaster.AdminPath(),
+		"topics", cfg.Topics)
+	return nil
+}
+
+// GetBroadcaster 当前生效的广播推送器，未配置时返回 nil
+func (s *Server) GetBroadcaster() *middleware.Broadcaster {
+	return s.broadcaster.Load()
+}
+
+// broadcastSubscribeHandler 订阅接口，使用当前生效的推送器
+func (s *Server) broadcastSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	broadcaster := s.broadcaster.Load()
+	if broadcaster == nil {
+		response.WriteServiceUnavailableResult(w, "broadcast is not configured")
+		return
+	}
+	broadcaster.SubscribeHandler()(w, r)
+}
+
+// broadcastAdminHandler 管理推送接口，使用当前生效的推送器
+func (s *Server) broadcastAdminHandler(w http.ResponseWriter, r *http.Request) {
+	broadcaster := s.broadcaster.Load()
+	if broadcaster == nil {
+		response.WriteServiceUnavailableResult(w, "broadcast is not configured")
+		return
+	}
+	broadcaster.AdminHandler()(w, r)
+}
+
+// closeBroadcast 向全部订阅连接发送关闭消息并断开
+func (s *Server) closeBroadcast() {
+	if broadcaster := s.broadcaster.Swap(nil); broadcaster != nil {
+		broadcaster.Close()
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\build_info.go
+ * @Description: 构建信息暴露 - 查询接口、HTTP 响应头与 gRPC 响应元数据
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// BuildInfoConfig 构建信息暴露配置
+type BuildInfoConfig struct {
+	Path            string // 构建信息查询接口路径，默认 /admin/info
+	ResponseHeaders bool   // HTTP 响应头附带 X-Build-Version / X-Build-Commit，gRPC 响应 header 附带 x-build-version / x-build-commit
+}
+
+// SetBuildInfoConfig 注册构建信息查询接口，并按需开启响应头与 gRPC 响应元数据（开启后不可关闭）
+func (s *Server) SetBuildInfoConfig(cfg BuildInfoConfig) {
+	if cfg.Path == "" {
+		cfg.Path = middleware.DefaultBuildInfoPath
+	}
+	if cfg.ResponseHeaders && !s.buildInfoHeaders.Swap(true) {
+		// 紧随异常恢复之后，限流、认证等中间件拒绝的响应同样带上版本
+		s.UseMiddleware("build_info", middleware.PriorityRecovery+1, middleware.BuildInfoHeaderMiddleware())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.httpMux != nil {
+		s.RegisterHTTPHandlerFunc(cfg.Path, middleware.BuildInfoHandler(time.Now()))
+		global.LOGGER.InfoKV("构建信息查询接口已注册", "path", cfg.Path, "response_headers", cfg.ResponseHeaders)
+	}
+}
+
+// buildInfoMD 构建信息 gRPC 响应元数据
+func buildInfoMD() metadata.MD {
+	info := global.GetBuildInfo()
+	md := metadata.MD{}
+	if info.Version != "" {
+		md.Set(constants.MetadataBuildVersion, info.Version)
+	}
+	if info.Commit != "" {
+		md.Set(constants.MetadataBuildCommit, info.Commit)
+	}
+	return md
+}
+
+// buildInfoUnaryInterceptor 一元调用响应 header 附带构建信息，未开启时直接放行
+func (s *Server) buildInfoUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.buildInfoHeaders.Load() {
+		if md := buildInfoMD(); md.Len() > 0 {
+			_ = grpc.SetHeader(ctx, md)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// buildInfoStreamInterceptor 流式调用响应 header 附带构建信息，未开启时直接放行
+func (s *Server) buildInfoStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if s.buildInfoHeaders.Load() {
+		if md := buildInfoMD(); md.Len() > 0 {
+			_ = ss.SetHeader(md)
+		}
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,74 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\cache_control.go
+ * @Description: CDN 缓存指令接入 - 响应头中间件与清除接口，运行时可替换规则或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetCacheControl 设置 CDN 缓存指令，nil 关闭（之后的响应不再附加缓存头，PurgeCDN 返回错误）
+// 中间件位于监控之后、限流之前，限流、熔断与授权失败的响应按状态码决定是否附加
+func (s *Server) SetCacheControl(cfg *middleware.CacheControlConfig) error {
+	if cfg == nil {
+		s.cacheDirectives.Store(nil)
+		middleware.SetCurrentCacheDirectives(nil)
+		return nil
+	}
+
+	c, err := middleware.NewCacheDirectives(*cfg)
+	if err != nil {
+		return err
+	}
+	s.cacheDirectives.Store(c)
+	middleware.SetCurrentCacheDirectives(c)
+
+	if !s.cacheDirectivesRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareCacheControl, middleware.PriorityCacheControl, s.cacheControlMiddleware)
+	}
+	if cfg.PurgePath != "" {
+		s.mu.Lock()
+		s.RegisterHTTPHandlerFunc(cfg.PurgePath, s.cdnPurgeHandler)
+		s.mu.Unlock()
+	}
+	global.LOGGER.InfoKV("CDN 缓存指令已启用",
+		"rules", len(cfg.Rules),
+		"surrogate_key_header", cfg.SurrogateKeyHeader,
+		"purgers", len(cfg.Purgers),
+		"purge_path", cfg.PurgePath)
+	return nil
+}
+
+// cacheControlMiddleware 附加缓存指令，未配置时直接放行
+func (s *Server) cacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := s.cacheDirectives.Load()
+		if c == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c.Handle(w, r, next)
+	})
+}
+
+// cdnPurgeHandler 清除接口，使用当前生效的配置
+func (s *Server) cdnPurgeHandler(w http.ResponseWriter, r *http.Request) {
+	c := s.cacheDirectives.Load()
+	if c == nil {
+		response.WriteServiceUnavailableResult(w, "cdn cache control is not configured")
+		return
+	}
+	c.PurgeHandler()(w, r)
+}
@@ -0,0 +1,102 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\casbin.go
+ * @Description: Casbin 授权接入 - HTTP 中间件、gRPC 拦截器与策略管理接口，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"google.golang.org/grpc"
+)
+
+// SetCasbin 设置 Casbin 授权，nil 关闭；替换时停止旧配置的定时重新加载
+// HTTP 检查位于外部授权之后，gRPC 检查位于外部授权拦截器之后
+func (s *Server) SetCasbin(cfg *middleware.CasbinConfig) error {
+	if cfg == nil {
+		if old := s.casbin.Swap(nil); old != nil {
+			old.Close()
+		}
+		middleware.SetCurrentCasbin(nil)
+		return nil
+	}
+
+	c, err := middleware.NewCasbin(*cfg)
+	if err != nil {
+		return err
+	}
+	c.StartReload(s.ctx)
+	if old := s.casbin.Swap(c); old != nil {
+		old.Close()
+	}
+	middleware.SetCurrentCasbin(c)
+
+	if !s.casbinRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareCasbin, middleware.PriorityCasbin, s.casbinMiddleware)
+	}
+	if cfg.AdminPath != "" {
+		s.mu.Lock()
+		s.RegisterHTTPHandlerFunc(strings.TrimSuffix(cfg.AdminPath, "/")+"/", s.casbinAdminHandler(cfg.AdminPath))
+		s.mu.Unlock()
+	}
+	global.LOGGER.InfoKV("Casbin 授权已启用",
+		"routes", len(cfg.Routes),
+		"enforce_unmapped", cfg.EnforceUnmapped,
+		"reload_interval", cfg.ReloadInterval,
+		"admin_path", cfg.AdminPath)
+	return nil
+}
+
+// casbinMiddleware HTTP Casbin 授权，未配置时直接放行
+func (s *Server) casbinMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := s.casbin.Load()
+		if c == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c.Handle(w, r, next)
+	})
+}
+
+// casbinAdminHandler 策略管理接口，转发给当前生效的 Casbin 配置
+func (s *Server) casbinAdminHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := s.casbin.Load()
+		if c == nil {
+			response.WriteServiceUnavailableResult(w, "casbin is not configured")
+			return
+		}
+		c.AdminHandler(path)(w, r)
+	}
+}
+
+// casbinUnaryInterceptor gRPC 一元调用 Casbin 授权，未配置时直接放行
+func (s *Server) casbinUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	c := s.casbin.Load()
+	if c == nil {
+		return handler(ctx, req)
+	}
+	return c.Unary(ctx, req, info, handler)
+}
+
+// casbinStreamInterceptor gRPC 流式调用 Casbin 授权，未配置时直接放行
+func (s *Server) casbinStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	c := s.casbin.Load()
+	if c == nil {
+		return handler(srv, ss)
+	}
+	return c.Stream(srv, ss, info, handler)
+}
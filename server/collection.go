@@ -0,0 +1,147 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\collection.go
+ * @Description: Postman / Insomnia 接口集合接入 - 默认挂在 Swagger UI 路径下，规范按 Swagger 配置加载；
+ *               Swagger 服务列表页加入下载入口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/collection"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	swaggerconst "github.com/kamalyes/go-swagger/constants"
+)
+
+// collectionsPathSuffix 未指定路径时下载接口相对 Swagger UI 路径的位置
+const collectionsPathSuffix = "/collections"
+
+// servicesGridMarker 服务列表页中服务卡片区域的起始标记，下载入口插在其前面
+const servicesGridMarker = `<div class="services-grid">`
+
+// SetCollectionExport 设置 Postman / Insomnia 接口集合下载，nil 关闭
+func (s *Server) SetCollectionExport(cfg *collection.HandlerConfig) error {
+	if cfg == nil {
+		if s.collections.Swap(nil) != nil {
+			global.LOGGER.InfoKV("接口集合下载已关闭")
+		}
+		return nil
+	}
+
+	handlerCfg := *cfg
+	if handlerCfg.Path == "" && s.config.Swagger != nil && strings.Trim(s.config.Swagger.UIPath, "/") != "" {
+		handlerCfg.Path = strings.TrimSuffix(s.config.Swagger.UIPath, "/") + collectionsPathSuffix
+	}
+	handler, err := collection.NewHandler(handlerCfg, s.loadSDKSpecs)
+	if err != nil {
+		return err
+	}
+	s.collections.Store(handler)
+
+	path := handler.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.collectionsHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.collectionsHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("接口集合下载已注册",
+		"path", path,
+		"base_url", cfg.BaseURL,
+		"spec_files", len(cfg.SpecFiles),
+		"routes", cfg.Routes != nil)
+	return nil
+}
+
+// GetCollectionExport 当前生效的接口集合下载接口，未配置时返回 nil
+func (s *Server) GetCollectionExport() *collection.Handler {
+	return s.collections.Load()
+}
+
+// collectionsHandler 下载接口，使用当前生效的配置
+func (s *Server) collectionsHandler(w http.ResponseWriter, r *http.Request) {
+	handler := s.collections.Load()
+	if handler == nil {
+		response.WriteServiceUnavailableResult(w, "collection export is not configured")
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// withCollectionLinks 在 Swagger 服务列表页加入接口集合的下载入口，未启用下载时原样返回
+func (s *Server) withCollectionLinks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler := s.collections.Load()
+		swagger := s.config.Swagger
+		if handler == nil || swagger == nil || r.Method != http.MethodGet ||
+			r.URL.Path != strings.TrimSuffix(swagger.UIPath, "/")+swaggerconst.ServicesPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		page := &pageRecorder{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(page, r)
+		body := page.body.Bytes()
+		if page.status == http.StatusOK && strings.HasPrefix(w.Header().Get(constants.HeaderContentType), "text/html") {
+			body = injectCollectionLinks(body, handler.AdminPath())
+			w.Header().Del(constants.HeaderContentLength)
+		}
+		w.WriteHeader(page.status)
+		_, _ = w.Write(body)
+	})
+}
+
+// injectCollectionLinks 把下载入口插入服务卡片区域之前，找不到时追加在 </body> 前
+func injectCollectionLinks(page []byte, path string) []byte {
+	base := html.EscapeString(path)
+	links := `
+    <div class="aggregate-actions">
+        <h3>接口集合</h3>
+        <p>导入 Postman / Insomnia 直接调试，环境变量 baseUrl 为网关地址，authToken 为访问令牌</p>
+        <a href="` + base + `/` + collection.FilePostman + `" class="btn btn-primary">下载 Postman 集合</a>
+        <a href="` + base + `/` + collection.FilePostmanEnvironment + `" class="btn btn-secondary">下载 Postman 环境</a>
+        <a href="` + base + `/` + collection.FileInsomnia + `" class="btn btn-primary">下载 Insomnia 导出</a>
+    </div>
+`
+	for _, marker := range []string{servicesGridMarker, "</body>"} {
+		if idx := bytes.Index(page, []byte(marker)); idx >= 0 {
+			out := make([]byte, 0, len(page)+len(links))
+			out = append(out, page[:idx]...)
+			out = append(out, links...)
+			return append(out, page[idx:]...)
+		}
+	}
+	return page
+}
+
+// pageRecorder 缓存响应体以便改写，响应头直接写入底层 ResponseWriter
+type pageRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header 底层 ResponseWriter 的响应头
+func (p *pageRecorder) Header() http.Header {
+	return p.header
+}
+
+// WriteHeader 记录状态码
+func (p *pageRecorder) WriteHeader(status int) {
+	p.status = status
+}
+
+// Write 缓存响应体
+func (p *pageRecorder) Write(b []byte) (int, error) {
+	return p.body.Write(b)
+}
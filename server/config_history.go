@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\config_history.go
+ * @Description: 配置版本历史接入 - 版本历史由网关创建（回滚需要配置管理器），服务器只负责挂载管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/confighistory"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetConfigHistory 设置配置版本历史并注册管理接口，nil 关闭
+func (s *Server) SetConfigHistory(history *confighistory.History) {
+	if history == nil {
+		if s.configHistory.Swap(nil) != nil {
+			global.LOGGER.InfoKV("配置版本历史已关闭")
+		}
+		return
+	}
+
+	s.configHistory.Store(history)
+	path := history.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.configHistoryHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.configHistoryHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("配置版本历史已启用", "path", path, "namespace", history.Namespace())
+}
+
+// GetConfigHistory 当前生效的配置版本历史，未配置时返回 nil
+func (s *Server) GetConfigHistory() *confighistory.History {
+	return s.configHistory.Load()
+}
+
+// configHistoryHandler 管理接口，使用当前生效的版本历史
+func (s *Server) configHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	history := s.configHistory.Load()
+	if history == nil {
+		response.WriteServiceUnavailableResult(w, "config history is not configured")
+		return
+	}
+	history.AdminHandler()(w, r)
+}
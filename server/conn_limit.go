@@ -0,0 +1,285 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\conn_limit.go
+ * @Description: 连接级限制 - 限制监听器的总并发连接数与单 IP 连接数，超限时拒绝或排队，
+ *               提供连接表查询与指标
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/net/netutil"
+)
+
+// ConnLimitMode 超出总连接数时的处理方式
+type ConnLimitMode string
+
+const (
+	ConnLimitReject ConnLimitMode = "reject" // 立即关闭新连接
+	ConnLimitQueue  ConnLimitMode = "queue"  // 暂停 Accept，等待已有连接释放（由内核 backlog 排队）
+)
+
+// 连接拒绝原因
+const (
+	connRejectTotal = "max_connections"
+	connRejectPerIP = "max_connections_per_ip"
+)
+
+// 连接指标
+var (
+	activeConnectionsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_active_connections",
+		Help: "Number of active connections per listener",
+	}, []string{"listener"})
+
+	rejectedConnectionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rejected_connections_total",
+		Help: "Total number of connections rejected by connection limits",
+	}, []string{"listener", "reason"})
+)
+
+// ConnLimitConfig 连接限制配置
+type ConnLimitConfig struct {
+	MaxConnections      int           // 单个监听器的最大并发连接数，<=0 不限制
+	MaxConnectionsPerIP int           // 单 IP 最大并发连接数，<=0 不限制（超限总是拒绝）
+	Mode                ConnLimitMode // 超出 MaxConnections 时的处理方式，默认 reject
+	AdminPath           string        // 连接表查询路径（如 /debug/connections），为空不注册
+}
+
+// ConnectionInfo 连接表条目
+type ConnectionInfo struct {
+	Listener   string    `json:"listener"`
+	RemoteAddr string    `json:"remote_addr"`
+	IP         string    `json:"ip"`
+	Since      time.Time `json:"since"`
+}
+
+// ConnectionStats 连接统计
+type ConnectionStats struct {
+	Active      int              `json:"active"`
+	PerListener map[string]int   `json:"per_listener"`
+	TopIPs      map[string]int   `json:"top_ips"`
+	Connections []ConnectionInfo `json:"connections,omitempty"`
+}
+
+// connTable 所有受限监听器共享的连接表
+type connTable struct {
+	mu    sync.Mutex
+	conns map[*limitedConn]struct{}
+	perIP map[string]int
+}
+
+// newConnTable 创建连接表
+func newConnTable() *connTable {
+	return &connTable{
+		conns: make(map[*limitedConn]struct{}),
+		perIP: make(map[string]int),
+	}
+}
+
+// limitListener 带连接限制的监听器
+type limitListener struct {
+	net.Listener
+	name  string
+	cfg   ConnLimitConfig
+	table *connTable
+	sem   chan struct{} // reject 模式的总连接信号量
+}
+
+// newLimitListener 包装监听器，未配置任何限制时仅做连接统计
+func newLimitListener(l net.Listener, name string, cfg ConnLimitConfig, table *connTable) net.Listener {
+	if cfg.MaxConnections > 0 && cfg.Mode == ConnLimitQueue {
+		l = netutil.LimitListener(l, cfg.MaxConnections)
+	}
+	ll := &limitListener{Listener: l, name: name, cfg: cfg, table: table}
+	if cfg.MaxConnections > 0 && cfg.Mode != ConnLimitQueue {
+		ll.sem = make(chan struct{}, cfg.MaxConnections)
+	}
+	return ll
+}
+
+// Accept 接受连接，超限的连接直接关闭并继续等待下一个
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.sem != nil {
+			select {
+			case l.sem <- struct{}{}:
+			default:
+				l.reject(conn, connRejectTotal)
+				continue
+			}
+		}
+
+		ip := remoteIP(conn.RemoteAddr())
+		lc := &limitedConn{Conn: conn, listener: l, ip: ip, since: time.Now()}
+		if !l.table.add(lc, l.cfg.MaxConnectionsPerIP) {
+			if l.sem != nil {
+				<-l.sem
+			}
+			l.reject(conn, connRejectPerIP)
+			continue
+		}
+		activeConnectionsGauge.WithLabelValues(l.name).Inc()
+		return lc, nil
+	}
+}
+
+// reject 关闭超限连接并记录指标
+func (l *limitListener) reject(conn net.Conn, reason string) {
+	rejectedConnectionsCounter.WithLabelValues(l.name, reason).Inc()
+	global.LOGGER.DebugKV("连接超出限制已拒绝", "listener", l.name, "remote", conn.RemoteAddr().String(), "reason", reason)
+	_ = conn.Close()
+}
+
+// limitedConn 计入连接表的连接
+type limitedConn struct {
+	net.Conn
+	listener *limitListener
+	ip       string
+	since    time.Time
+	closed   atomic.Bool
+}
+
+// Close 关闭连接并释放配额（只释放一次）
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	if c.closed.CompareAndSwap(false, true) {
+		c.listener.table.remove(c)
+		if c.listener.sem != nil {
+			<-c.listener.sem
+		}
+		activeConnectionsGauge.WithLabelValues(c.listener.name).Dec()
+	}
+	return err
+}
+
+// add 登记连接，单 IP 超限时返回 false
+func (t *connTable) add(c *limitedConn, perIPLimit int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if perIPLimit > 0 && t.perIP[c.ip] >= perIPLimit {
+		return false
+	}
+	t.conns[c] = struct{}{}
+	t.perIP[c.ip]++
+	return true
+}
+
+// remove 注销连接
+func (t *connTable) remove(c *limitedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, c)
+	if t.perIP[c.ip]--; t.perIP[c.ip] <= 0 {
+		delete(t.perIP, c.ip)
+	}
+}
+
+// stats 连接统计快照，topN 为返回的连接数最多的 IP 数量
+func (t *connTable) stats(topN int, withConnections bool) ConnectionStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := ConnectionStats{
+		Active:      len(t.conns),
+		PerListener: make(map[string]int),
+		TopIPs:      make(map[string]int),
+	}
+	for c := range t.conns {
+		stats.PerListener[c.listener.name]++
+		if withConnections {
+			stats.Connections = append(stats.Connections, ConnectionInfo{
+				Listener:   c.listener.name,
+				RemoteAddr: c.RemoteAddr().String(),
+				IP:         c.ip,
+				Since:      c.since,
+			})
+		}
+	}
+
+	ips := make([]string, 0, len(t.perIP))
+	for ip := range t.perIP {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return t.perIP[ips[i]] > t.perIP[ips[j]] })
+	for i, ip := range ips {
+		if i >= topN {
+			break
+		}
+		stats.TopIPs[ip] = t.perIP[ip]
+	}
+
+	sort.Slice(stats.Connections, func(i, j int) bool {
+		return stats.Connections[i].Since.Before(stats.Connections[j].Since)
+	})
+	return stats
+}
+
+// remoteIP 提取连接的 IP
+func remoteIP(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// SetConnLimitConfig 设置连接限制配置（在 Start 之前调用，对主 HTTP 服务器与命名监听器生效）
+func (s *Server) SetConnLimitConfig(cfg *ConnLimitConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connLimit = cfg
+	if cfg != nil && cfg.AdminPath != "" && s.httpMux != nil {
+		s.RegisterHTTPHandlerFunc(cfg.AdminPath, s.connectionTableHandler)
+	}
+}
+
+// ConnectionStats 返回当前连接统计，withConnections 为 true 时附带完整连接表
+func (s *Server) ConnectionStats(withConnections bool) ConnectionStats {
+	return s.connTable.stats(10, withConnections)
+}
+
+// wrapListener 按临时封禁列表与连接限制配置包装监听器
+func (s *Server) wrapListener(l net.Listener, name string) net.Listener {
+	l = &banListener{Listener: l, name: name, bans: s.ipBans}
+
+	cfg := s.connLimit
+	if cfg == nil {
+		return l
+	}
+	global.LOGGER.InfoKV("连接限制已启用", "listener", name,
+		"max_connections", cfg.MaxConnections,
+		"max_connections_per_ip", cfg.MaxConnectionsPerIP,
+		"mode", cfg.Mode)
+	return newLimitListener(l, name, *cfg, s.connTable)
+}
+
+// connectionTableHandler 连接表查询处理器（?detail=true 返回完整连接表）
+func (s *Server) connectionTableHandler(w http.ResponseWriter, r *http.Request) {
+	detailed := r.URL.Query().Get("detail") == "true"
+	response.WriteJSONResponse(w, http.StatusOK, s.ConnectionStats(detailed))
+}
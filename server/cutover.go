@@ -0,0 +1,82 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\cutover.go
+ * @Description: 蓝绿切换接入 - 路由前缀注册到 HTTP 多路复用器，经过完整的中间件链后按当前生效的上游组转发
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetCutover 设置蓝绿切换，nil 关闭（已注册的前缀回落到默认的 gwMux）
+// 替换配置时各路由回到配置中的初始上游组，切换记录不保留
+func (s *Server) SetCutover(cfg *middleware.CutoverConfig) error {
+	if cfg == nil {
+		s.cutover.Store(nil)
+		return nil
+	}
+
+	c, err := middleware.NewCutover(*cfg)
+	if err != nil {
+		return err
+	}
+	s.cutover.Store(c)
+
+	s.mu.Lock()
+	for _, prefix := range c.Prefixes() {
+		s.RegisterHTTPHandlerFunc(prefix, s.cutoverHandler)
+		s.RegisterHTTPHandlerFunc(prefix+"/", s.cutoverHandler)
+	}
+	if cfg.AdminPath != "" {
+		adminPath := strings.TrimSuffix(cfg.AdminPath, "/")
+		s.RegisterHTTPHandlerFunc(adminPath, s.cutoverAdminHandler(adminPath))
+		s.RegisterHTTPHandlerFunc(adminPath+"/", s.cutoverAdminHandler(adminPath))
+	}
+	s.mu.Unlock()
+
+	global.LOGGER.InfoKV("蓝绿切换已启用",
+		"prefixes", c.Prefixes(),
+		"rollback_error_rate", cfg.Rollback.ErrorRate,
+		"rollback_window", cfg.Rollback.Window,
+		"admin_path", cfg.AdminPath)
+	return nil
+}
+
+// GetCutover 当前生效的蓝绿切换控制器，未配置时返回 nil
+func (s *Server) GetCutover() *middleware.Cutover {
+	return s.cutover.Load()
+}
+
+// cutoverHandler 按当前生效的上游组转发，未配置或前缀已移除时交给 gwMux
+func (s *Server) cutoverHandler(w http.ResponseWriter, r *http.Request) {
+	c := s.cutover.Load()
+	if c == nil {
+		s.gwMux.ServeHTTP(w, r)
+		return
+	}
+	c.Handle(w, r, s.gwMux)
+}
+
+// cutoverAdminHandler 管理接口，使用当前生效的控制器
+func (s *Server) cutoverAdminHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := s.cutover.Load()
+		if c == nil {
+			response.WriteServiceUnavailableResult(w, "cutover is not configured")
+			return
+		}
+		c.AdminHandler(path)(w, r)
+	}
+}
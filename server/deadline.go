@@ -0,0 +1,214 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\deadline.go
+ * @Description: 请求截止时间传递 - 可信调用方通过 X-Timeout-Ms / Grpc-Timeout 请求头指定超时，
+ *               gRPC 调用沿用 grpc-timeout，统一按服务端上限截断，调用方放弃后后端处理随之取消
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"google.golang.org/grpc"
+)
+
+// DeadlineConfig 请求截止时间配置
+type DeadlineConfig struct {
+	DefaultTimeout time.Duration // 调用方未指定超时时使用，0 不设置
+	MaxTimeout     time.Duration // 截止时间上限，调用方指定的更长超时会被截断，0 不限制
+	TrustedCIDRs   []string      // 允许通过请求头指定超时的来源（连接远端地址，支持 IP 与 CIDR），为空时忽略请求头
+	SkipPaths      []string      // 不设置截止时间的 HTTP 路径（长轮询、SSE、大文件上传等），以 * 结尾表示前缀匹配
+}
+
+// deadlineRules 编译后的截止时间规则
+type deadlineRules struct {
+	config  DeadlineConfig
+	trusted []netip.Prefix
+	skip    *middleware.RouteTable
+}
+
+// SetDeadlineConfig 设置请求截止时间规则，nil 关闭；对之后的请求立即生效
+// gRPC 调用的 grpc-timeout 由 gRPC 框架直接生效，这里只按上限截断并补充默认值；流式 RPC 不受默认值与上限影响
+func (s *Server) SetDeadlineConfig(cfg *DeadlineConfig) error {
+	if cfg == nil {
+		s.deadline.Store(nil)
+		return nil
+	}
+	if cfg.DefaultTimeout < 0 || cfg.MaxTimeout < 0 {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "default and max timeout must not be negative")
+	}
+
+	rules := &deadlineRules{config: *cfg}
+	for _, cidr := range cfg.TrustedCIDRs {
+		prefix, err := parseTrustedPrefix(cidr)
+		if err != nil {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid trusted CIDR %q: %v", cidr, err)
+		}
+		rules.trusted = append(rules.trusted, prefix)
+	}
+
+	patterns := make([]middleware.RoutePattern, 0, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		p := middleware.RoutePattern{Kind: middleware.RouteMatchExact, Pattern: path, Value: true}
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			p.Kind, p.Pattern = middleware.RouteMatchPrefix, prefix
+		}
+		patterns = append(patterns, p)
+	}
+	rules.skip = middleware.NewRouteTable(patterns)
+
+	s.deadline.Store(rules)
+	return nil
+}
+
+// parseTrustedPrefix 解析 CIDR 或单个 IP
+func parseTrustedPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// isTrusted 连接远端地址是否允许指定超时
+func (r *deadlineRules) isTrusted(remoteAddr string) bool {
+	if len(r.trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clamp 按上限截断超时，未指定时使用默认值
+func (r *deadlineRules) clamp(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		timeout = r.config.DefaultTimeout
+	}
+	if limit := r.config.MaxTimeout; limit > 0 && (timeout <= 0 || timeout > limit) {
+		timeout = limit
+	}
+	return timeout
+}
+
+// httpTimeout 计算 HTTP 请求的超时，0 表示不设置
+func (r *deadlineRules) httpTimeout(req *http.Request) time.Duration {
+	if req.Header.Get("Upgrade") != "" {
+		return 0
+	}
+	if _, skip := r.skip.Match(req.Method, req.URL.Path); skip {
+		return 0
+	}
+
+	var timeout time.Duration
+	if r.isTrusted(req.RemoteAddr) {
+		if ms, err := strconv.ParseInt(req.Header.Get(constants.HeaderXTimeoutMs), 10, 64); err == nil && ms > 0 {
+			timeout = time.Duration(min(ms, math.MaxInt64/int64(time.Millisecond))) * time.Millisecond
+		}
+		if d, ok := parseGRPCTimeout(req.Header.Get(constants.HeaderGRPCTimeout)); ok && (timeout == 0 || d < timeout) {
+			timeout = d
+		}
+	}
+	return r.clamp(timeout)
+}
+
+// parseGRPCTimeout 解析 grpc-timeout 格式（最多 8 位数字 + 单位 H/M/S/m/u/n）
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// deadlineMiddleware 为 HTTP 请求设置截止时间，未配置时直接放行
+func (s *Server) deadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rules := s.deadline.Load()
+		if rules == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout := rules.httpTimeout(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// deadlineUnaryInterceptor 一元调用：调用方超时超过上限时截断，未携带超时时使用默认值
+func (s *Server) deadlineUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	rules := s.deadline.Load()
+	if rules == nil {
+		return handler(ctx, req)
+	}
+
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if limit := rules.config.MaxTimeout; limit <= 0 || timeout <= limit {
+			return handler(ctx, req)
+		}
+	}
+	if timeout = rules.clamp(timeout); timeout <= 0 {
+		return handler(ctx, req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return handler(ctx, req)
+}
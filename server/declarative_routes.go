@@ -0,0 +1,69 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\declarative_routes.go
+ * @Description: 声明式路由接入 - 配置文件 routes 段编译后的路由位于规则路由之后，配置热更新时整体替换
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetDeclarativeRoutes 设置声明式路由，nil 关闭；校验失败时返回错误并保留当前路由
+func (s *Server) SetDeclarativeRoutes(cfg *middleware.DeclarativeRoutesConfig) error {
+	if cfg == nil {
+		if s.declarativeRouter.Swap(nil) != nil {
+			global.LOGGER.InfoKV("声明式路由已关闭")
+		}
+		return nil
+	}
+
+	router, err := middleware.NewDeclarativeRouter(*cfg)
+	if err != nil {
+		return err
+	}
+	s.declarativeRouter.Store(router)
+
+	if !s.declarativeRouterRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareDeclarative, middleware.PriorityDeclarative, s.declarativeRoutesMiddleware)
+	}
+	if cfg.RewriteTestPath != "" {
+		s.mu.Lock()
+		s.RegisterHTTPHandlerFunc(cfg.RewriteTestPath, s.rewriteTestHandler)
+		s.mu.Unlock()
+	}
+	global.LOGGER.InfoKV("声明式路由已启用", "routes", router.Len(), "upstreams", len(cfg.Upstreams), "rewrite_test_path", cfg.RewriteTestPath)
+	return nil
+}
+
+// declarativeRoutesMiddleware 按当前路由处理，未配置或未命中时交给后续处理器
+func (s *Server) declarativeRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router := s.declarativeRouter.Load()
+		if router == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		router.Handle(w, r, next)
+	})
+}
+
+// rewriteTestHandler 改写试算接口，使用当前生效的路由与上游
+func (s *Server) rewriteTestHandler(w http.ResponseWriter, r *http.Request) {
+	router := s.declarativeRouter.Load()
+	if router == nil {
+		response.WriteServiceUnavailableResult(w, "declarative routes are not configured")
+		return
+	}
+	router.RewriteTestHandler()(w, r)
+}
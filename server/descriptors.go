@@ -0,0 +1,75 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\descriptors.go
+ * @Description: 服务描述符查询接入 - 本进程 gRPC Server 注册的服务与 reflection 发现的上游服务作为描述符来源，
+ *               并注册 FileDescriptorSet 管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	grpcpool "github.com/kamalyes/go-rpc-gateway/cpool/grpc"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetDescriptors 设置服务描述符查询接口，nil 关闭
+func (s *Server) SetDescriptors(cfg *middleware.DescriptorsConfig) error {
+	if cfg == nil {
+		if s.descriptors.Swap(nil) != nil {
+			global.LOGGER.InfoKV("服务描述符查询接口已关闭")
+		}
+		return nil
+	}
+
+	descriptors, err := middleware.NewDescriptors(*cfg, s.RegisteredServiceNames)
+	if err != nil {
+		return err
+	}
+	s.descriptors.Store(descriptors)
+
+	path := descriptors.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.descriptorsHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.descriptorsHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("服务描述符查询接口已注册",
+		"path", path,
+		"roles", cfg.Roles,
+		"services", cfg.Services)
+	return nil
+}
+
+// GetDescriptors 当前生效的描述符注册表，未配置时返回 nil
+func (s *Server) GetDescriptors() *middleware.Descriptors {
+	return s.descriptors.Load()
+}
+
+// RegisteredServiceNames 本进程 gRPC Server 注册的服务与 reflection 发现的上游服务
+func (s *Server) RegisteredServiceNames() []string {
+	var names []string
+	if s.grpcServer != nil {
+		for name := range s.grpcServer.GetServiceInfo() {
+			names = append(names, name)
+		}
+	}
+	return append(names, grpcpool.ReflectionServiceNames()...)
+}
+
+// descriptorsHandler 管理接口，使用当前生效的注册表
+func (s *Server) descriptorsHandler(w http.ResponseWriter, r *http.Request) {
+	descriptors := s.descriptors.Load()
+	if descriptors == nil {
+		response.WriteServiceUnavailableResult(w, "descriptors endpoint is not configured")
+		return
+	}
+	descriptors.AdminHandler()(w, r)
+}
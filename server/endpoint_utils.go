@@ -12,7 +12,6 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"net/http"
@@ -21,6 +20,8 @@ import (
 	"sort"
 	"strings"
 	"sync"
+
+	"github.com/kamalyes/go-rpc-gateway/response"
 )
 
 // EndpointInfo API端点信息
@@ -222,10 +223,10 @@ func (ec *EndpointCollector) CollectFromSwagger(swaggerData map[string]interface
 // ToJSON 将端点信息转换为JSON格式
 func (ec *EndpointCollector) ToJSON() ([]byte, error) {
 	endpoints := ec.GetAllEndpoints()
-	response := EndpointResponse{
+	resp := EndpointResponse{
 		EndpointInfos: endpoints,
 	}
-	return json.Marshal(response)
+	return response.MarshalJSON(resp)
 }
 
 // CreateHTTPHandler 创建HTTP处理器（工具方法）
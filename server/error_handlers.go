@@ -0,0 +1,143 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\error_handlers.go
+ * @Description: 自定义 404/405/500 处理器 - 默认返回标准 Result 结构与国际化消息，
+ *               可替换为品牌错误页或按内容协商区分 HTML 客户端与 API 调用方
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	commonapis "github.com/kamalyes/go-rpc-gateway/proto"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// InternalErrorHandler 500 错误处理器，err 为 panic 值
+type InternalErrorHandler func(w http.ResponseWriter, r *http.Request, err any)
+
+// errorHandlers 已注册的错误处理器（独立加锁，避免请求路径依赖 s.mu）
+type errorHandlers struct {
+	mu               sync.RWMutex
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+// SetNotFoundHandler 设置 404 处理器，nil 恢复默认
+func (s *Server) SetNotFoundHandler(h http.Handler) {
+	s.errorHandlers.mu.Lock()
+	defer s.errorHandlers.mu.Unlock()
+	s.errorHandlers.notFound = h
+}
+
+// SetMethodNotAllowedHandler 设置 405 处理器，nil 恢复默认
+// 调用前已写入 Allow 响应头
+func (s *Server) SetMethodNotAllowedHandler(h http.Handler) {
+	s.errorHandlers.mu.Lock()
+	defer s.errorHandlers.mu.Unlock()
+	s.errorHandlers.methodNotAllowed = h
+}
+
+// SetInternalErrorHandler 设置 500 处理器（处理 HTTP 层 panic），nil 恢复默认
+func (s *Server) SetInternalErrorHandler(h InternalErrorHandler) {
+	if s.middlewareManager != nil {
+		s.middlewareManager.SetRecoveryHandler(middleware.RecoveryHandlerFunc(h))
+	}
+}
+
+// NotFound 按已注册的处理器写入 404 响应
+func (s *Server) NotFound(w http.ResponseWriter, r *http.Request) {
+	s.errorHandlers.mu.RLock()
+	h := s.errorHandlers.notFound
+	s.errorHandlers.mu.RUnlock()
+
+	if h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	WriteDefaultError(w, r, http.StatusNotFound)
+}
+
+// MethodNotAllowed 按已注册的处理器写入 405 响应
+func (s *Server) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if allowed := s.AllowedMethods(r.URL.Path); len(allowed) > 0 {
+		w.Header().Set(constants.HeaderAllow, strings.Join(allowed, ", "))
+	}
+
+	s.errorHandlers.mu.RLock()
+	h := s.errorHandlers.methodNotAllowed
+	s.errorHandlers.mu.RUnlock()
+
+	if h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	WriteDefaultError(w, r, http.StatusMethodNotAllowed)
+}
+
+// routingErrorHandler grpc-gateway 路由错误处理，404/405 交给已注册的处理器
+func (s *Server) routingErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, httpStatus int) {
+	switch httpStatus {
+	case http.StatusNotFound:
+		s.NotFound(w, r)
+	case http.StatusMethodNotAllowed:
+		s.MethodNotAllowed(w, r)
+	default:
+		runtime.DefaultRoutingErrorHandler(ctx, mux, marshaler, w, r, httpStatus)
+	}
+}
+
+// WriteDefaultError 写入默认错误响应：标准 Result 结构 + 国际化消息
+func WriteDefaultError(w http.ResponseWriter, r *http.Request, httpStatus int) {
+	ctx := r.Context()
+	switch httpStatus {
+	case http.StatusNotFound:
+		response.WriteErrorResult(w, httpStatus, localizeMessage(ctx, constants.MsgKeyNotFound, httpStatus), commonapis.StatusCode_NotFound)
+	case http.StatusMethodNotAllowed:
+		response.WriteErrorResult(w, httpStatus, localizeMessage(ctx, constants.MsgKeyMethodNotAllowed, httpStatus), commonapis.StatusCode_Unimplemented)
+	default:
+		response.WriteErrorResult(w, httpStatus, localizeMessage(ctx, constants.MsgKeyInternalError, httpStatus), commonapis.StatusCode_Internal)
+	}
+}
+
+// NegotiatedErrorHandler 按内容协商选择错误处理器：浏览器（偏好 text/html）使用 html，API 调用方返回默认 JSON
+func NegotiatedErrorHandler(html http.Handler, httpStatus int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if html != nil && WantsHTML(r) {
+			html.ServeHTTP(w, r)
+			return
+		}
+		WriteDefaultError(w, r, httpStatus)
+	})
+}
+
+// WantsHTML 客户端是否偏好 HTML（Accept 中 text/html 出现在 application/json 之前）
+func WantsHTML(r *http.Request) bool {
+	accept := strings.ToLower(r.Header.Get(constants.HeaderAccept))
+	htmlIdx := strings.Index(accept, "text/html")
+	if htmlIdx < 0 {
+		return false
+	}
+	jsonIdx := strings.Index(accept, "application/json")
+	return jsonIdx < 0 || htmlIdx < jsonIdx
+}
+
+// localizeMessage 翻译错误消息，未配置国际化或缺少翻译时使用 HTTP 状态文本
+func localizeMessage(ctx context.Context, key string, httpStatus int) string {
+	if msg := middleware.T(ctx, key); msg != "" && msg != key {
+		return msg
+	}
+	return http.StatusText(httpStatus)
+}
@@ -0,0 +1,73 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ext_authz.go
+ * @Description: 外部授权接入 - HTTP 中间件链与 gRPC 拦截器链中的授权检查，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"google.golang.org/grpc"
+)
+
+// SetExtAuthz 设置外部授权，nil 关闭；对之后的请求立即生效
+// HTTP 检查位于签名校验之后，gRPC 检查位于日志、监控等拦截器之后，被拒绝的请求同样有访问日志
+func (s *Server) SetExtAuthz(cfg *middleware.ExtAuthzConfig) error {
+	if cfg == nil {
+		s.extAuthz.Store(nil)
+		return nil
+	}
+	authz, err := middleware.NewExtAuthz(*cfg)
+	if err != nil {
+		return err
+	}
+	s.extAuthz.Store(authz)
+	if !s.extAuthzRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareExtAuthz, middleware.PriorityExtAuthz, s.extAuthzMiddleware)
+	}
+	global.LOGGER.InfoKV("外部授权已启用",
+		"fail_open", cfg.FailOpen,
+		"cache_ttl", cfg.CacheTTL,
+		"skip_paths", cfg.SkipPaths)
+	return nil
+}
+
+// extAuthzMiddleware HTTP 外部授权，未配置时直接放行
+func (s *Server) extAuthzMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authz := s.extAuthz.Load()
+		if authz == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authz.Handle(w, r, next)
+	})
+}
+
+// extAuthzUnaryInterceptor gRPC 一元调用外部授权，未配置时直接放行
+func (s *Server) extAuthzUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	authz := s.extAuthz.Load()
+	if authz == nil {
+		return handler(ctx, req)
+	}
+	return authz.Unary(ctx, req, info, handler)
+}
+
+// extAuthzStreamInterceptor gRPC 流式调用外部授权，未配置时直接放行
+func (s *Server) extAuthzStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	authz := s.extAuthz.Load()
+	if authz == nil {
+		return handler(srv, ss)
+	}
+	return authz.Stream(srv, ss, info, handler)
+}
@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\fallback.go
+ * @Description: 降级响应接入 - 中间件位于熔断之前，熔断器拒绝的请求同样可以降级；规则运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetFallbacks 设置降级响应规则，nil 关闭（已缓存的成功响应随规则一起丢弃）
+func (s *Server) SetFallbacks(cfg *middleware.FallbackConfig) error {
+	if cfg == nil {
+		if s.fallbacks.Swap(nil) != nil {
+			global.LOGGER.InfoKV("降级响应已关闭")
+		}
+		return nil
+	}
+
+	f, err := middleware.NewFallbacks(*cfg)
+	if err != nil {
+		return err
+	}
+	s.fallbacks.Store(f)
+
+	if !s.fallbacksRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareFallback, middleware.PriorityFallback, s.fallbackMiddleware)
+	}
+	global.LOGGER.InfoKV("降级响应已启用", "rules", len(cfg.Rules))
+	return nil
+}
+
+// fallbackMiddleware 按当前规则处理，未配置时直接放行
+func (s *Server) fallbackMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f := s.fallbacks.Load()
+		if f == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		f.Handle(w, r, next)
+	})
+}
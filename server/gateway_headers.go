@@ -0,0 +1,256 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\gateway_headers.go
+ * @Description: grpc-gateway 映射规则 - 请求头 → gRPC metadata 允许名单与重命名、metadata → 响应头映射、
+ *               查询参数别名/忽略/分隔符拆分/未知参数校验；规则可随时替换，对之后的请求立即生效
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GatewayHeaderConfig grpc-gateway 请求头、元数据与查询参数映射规则，零值保持默认行为
+// 请求头名称大小写不敏感，以 * 结尾表示前缀匹配（例如 "X-Tenant-*"）
+type GatewayHeaderConfig struct {
+	ForwardHeaders   []string          // 转发为 gRPC metadata 的请求头允许名单，为空时转发全部（hop-by-hop 头始终过滤）
+	HeaderToMetadata map[string]string // 请求头 → metadata key 重命名，自动视为允许转发
+	ResponseHeaders  []string          // 按原名写入 HTTP 响应头的 header metadata 允许名单，为空时全部以 Grpc-Metadata- 前缀写入
+	MetadataToHeader map[string]string // metadata key → 响应头名称，自动视为允许写入
+	Query            QueryParamConfig  // 查询参数解析选项
+}
+
+// QueryParamConfig 查询参数解析选项
+type QueryParamConfig struct {
+	Aliases           map[string]string // 参数别名 → 字段路径，例如 "q" → "filter.keyword"
+	Ignore            []string          // 解析前丢弃的参数（支持 * 前缀匹配），例如 "_t"、"utm_*"
+	RepeatedDelimiter string            // 重复字段的分隔符，例如 "," 使 ids=1,2,3 等价于 ids=1&ids=2&ids=3
+	DisallowUnknown   bool              // 存在无法映射到请求字段的参数时返回 InvalidArgument
+}
+
+// headerPatterns 编译后的名称匹配规则（小写）
+type headerPatterns struct {
+	exact    map[string]struct{}
+	prefixes []string
+}
+
+// newHeaderPatterns 编译名称列表
+func newHeaderPatterns(names []string) headerPatterns {
+	p := headerPatterns{exact: make(map[string]struct{}, len(names))}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			p.prefixes = append(p.prefixes, prefix)
+		} else if name != "" {
+			p.exact[name] = struct{}{}
+		}
+	}
+	return p
+}
+
+// empty 是否未配置任何规则
+func (p *headerPatterns) empty() bool {
+	return len(p.exact) == 0 && len(p.prefixes) == 0
+}
+
+// match 检查小写名称是否命中
+func (p *headerPatterns) match(name string) bool {
+	if _, ok := p.exact[name]; ok {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayHeaderRules 编译后的映射规则
+type gatewayHeaderRules struct {
+	forward     headerPatterns
+	toMetadata  map[string]string
+	response    headerPatterns
+	toHeader    map[string]string
+	aliases     map[string]string
+	ignore      headerPatterns
+	delimiter   string
+	strictQuery bool
+}
+
+// compileGatewayHeaderConfig 校验并编译映射规则
+func compileGatewayHeaderConfig(cfg *GatewayHeaderConfig) (*gatewayHeaderRules, error) {
+	rules := &gatewayHeaderRules{
+		forward:     newHeaderPatterns(cfg.ForwardHeaders),
+		toMetadata:  make(map[string]string, len(cfg.HeaderToMetadata)),
+		response:    newHeaderPatterns(cfg.ResponseHeaders),
+		toHeader:    make(map[string]string, len(cfg.MetadataToHeader)),
+		aliases:     make(map[string]string, len(cfg.Query.Aliases)),
+		ignore:      newHeaderPatterns(cfg.Query.Ignore),
+		delimiter:   cfg.Query.RepeatedDelimiter,
+		strictQuery: cfg.Query.DisallowUnknown,
+	}
+	for from, to := range cfg.HeaderToMetadata {
+		if from == "" || to == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid header to metadata mapping %q → %q", from, to)
+		}
+		rules.toMetadata[strings.ToLower(from)] = strings.ToLower(to)
+	}
+	for from, to := range cfg.MetadataToHeader {
+		if from == "" || to == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid metadata to header mapping %q → %q", from, to)
+		}
+		rules.toHeader[strings.ToLower(from)] = to
+	}
+	for alias, field := range cfg.Query.Aliases {
+		if alias == "" || field == "" {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "invalid query alias %q → %q", alias, field)
+		}
+		rules.aliases[alias] = field
+	}
+	return rules, nil
+}
+
+// SetGatewayHeaderConfig 设置 grpc-gateway 映射规则，nil 恢复默认行为；对之后的请求立即生效，无需重建网关
+func (s *Server) SetGatewayHeaderConfig(cfg *GatewayHeaderConfig) error {
+	if cfg == nil {
+		s.gatewayHeaders.Store(nil)
+		return nil
+	}
+	rules, err := compileGatewayHeaderConfig(cfg)
+	if err != nil {
+		return err
+	}
+	s.gatewayHeaders.Store(rules)
+	return nil
+}
+
+// incomingHeaderMatcher 请求头 → gRPC metadata
+func (s *Server) incomingHeaderMatcher(key string) (string, bool) {
+	lower := strings.ToLower(key)
+	switch lower {
+	// HTTP/2 规范禁止的头，转发这些头会导致 gRPC 服务端发送 RST_STREAM
+	case "connection", "keep-alive", "proxy-connection",
+		"transfer-encoding", "upgrade", "te":
+		return key, false
+	case "authorization":
+		// grpc-gateway 的 AnnotateContext 已对 Authorization 做无条件转发（向后兼容），
+		// 此处再匹配会导致 metadata 中出现重复的 authorization 值，
+		// 下游服务解析 token 失败 → 认证失败 → 无数据权限
+		return key, false
+	}
+
+	rules := s.gatewayHeaders.Load()
+	if rules == nil {
+		return key, true
+	}
+	if to, ok := rules.toMetadata[lower]; ok {
+		return to, true
+	}
+	if rules.forward.empty() || rules.forward.match(lower) {
+		return key, true
+	}
+	return key, false
+}
+
+// outgoingHeaderMatcher header metadata → HTTP 响应头
+func (s *Server) outgoingHeaderMatcher(key string) (string, bool) {
+	rules := s.gatewayHeaders.Load()
+	if rules == nil {
+		return runtime.MetadataHeaderPrefix + key, true
+	}
+	if to, ok := rules.toHeader[key]; ok {
+		return to, true
+	}
+	if rules.response.empty() {
+		return runtime.MetadataHeaderPrefix + key, true
+	}
+	if rules.response.match(key) {
+		return key, true
+	}
+	return key, false
+}
+
+// gatewayQueryParser 按映射规则预处理查询参数后交给默认解析器
+type gatewayQueryParser struct {
+	server *Server
+}
+
+// Parse 实现 runtime.QueryParameterParser
+func (p *gatewayQueryParser) Parse(msg proto.Message, values url.Values, filter *utilities.DoubleArray) error {
+	rules := p.server.gatewayHeaders.Load()
+	if rules == nil {
+		return (&runtime.DefaultQueryParser{}).Parse(msg, values, filter)
+	}
+
+	desc := msg.ProtoReflect().Descriptor()
+	parsed := make(url.Values, len(values))
+	for key, vals := range values {
+		if rules.ignore.match(strings.ToLower(key)) {
+			continue
+		}
+		if field, ok := rules.aliases[key]; ok {
+			key = field
+		}
+
+		fd, known := resolveQueryField(desc, key)
+		if !known && rules.strictQuery {
+			return fmt.Errorf("unknown query parameter %q", key)
+		}
+		if fd != nil && fd.IsList() && rules.delimiter != "" {
+			vals = splitQueryValues(vals, rules.delimiter)
+		}
+		parsed[key] = append(parsed[key], vals...)
+	}
+	return (&runtime.DefaultQueryParser{}).Parse(msg, parsed, filter)
+}
+
+// resolveQueryField 按字段路径（proto 名或 JSON 名，. 分隔）查找字段，map 参数形如 field[key]
+func resolveQueryField(desc protoreflect.MessageDescriptor, key string) (protoreflect.FieldDescriptor, bool) {
+	if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+		key = key[:i]
+	}
+	var fd protoreflect.FieldDescriptor
+	for _, name := range strings.Split(key, ".") {
+		if fd != nil {
+			if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+				return nil, false
+			}
+			desc = fd.Message()
+		}
+		fields := desc.Fields()
+		if fd = fields.ByTextName(name); fd == nil {
+			if fd = fields.ByJSONName(name); fd == nil {
+				return nil, false
+			}
+		}
+	}
+	return fd, true
+}
+
+// splitQueryValues 按分隔符拆分重复字段的值
+func splitQueryValues(vals []string, delimiter string) []string {
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		for _, part := range strings.Split(v, delimiter) {
+			if part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\gateway_stream.go
+ * @Description: 服务端流式 RPC 的 HTTP 输出 - grpc-gateway 以换行分隔 JSON 逐条写出，
+ *               按路由配置 Content-Type、逐条刷新、空闲心跳与写超时顺延
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// DefaultStreamHeartbeat 默认心跳内容：空行（NDJSON 解析器会跳过空行）
+const DefaultStreamHeartbeat = "\n"
+
+// StreamOptions 流式响应输出选项
+type StreamOptions struct {
+	ContentType       string        // 覆盖响应 Content-Type（如 application/x-ndjson），为空沿用 Marshaler（application/json）
+	HeartbeatInterval time.Duration // 超过该时长没有消息时写出心跳，0 关闭；仅对 JSON 流生效（HttpBody 二进制流不插入心跳）
+	Heartbeat         string        // 心跳内容，默认空行；SSE 风格客户端可用 ": ping\n"
+	DisableFlush      bool          // 关闭逐条刷新，由底层缓冲决定发送时机
+	WriteTimeout      time.Duration // 请求开始及每次写出后顺延写超时，使长连接流不受 HTTP Server WriteTimeout 限制，0 沿用服务器配置
+}
+
+// StreamRoute 单个路由的流式响应选项
+type StreamRoute struct {
+	Pattern string // HTTP 路径，以 * 结尾表示前缀匹配
+	StreamOptions
+}
+
+// StreamConfig 服务端流式 RPC 输出配置
+type StreamConfig struct {
+	Default *StreamOptions // 未命中路由规则的流式响应使用，nil 保持 grpc-gateway 默认行为
+	Routes  []StreamRoute  // 按路由覆盖，精确匹配优先于前缀，前缀越长越优先
+}
+
+// gatewayStreamRules 编译后的流式输出规则
+type gatewayStreamRules struct {
+	def    *StreamOptions
+	routes *middleware.RouteTable
+}
+
+// match 返回请求适用的选项，nil 表示不处理
+func (r *gatewayStreamRules) match(req *http.Request) *StreamOptions {
+	if v, ok := r.routes.Match(req.Method, req.URL.Path); ok {
+		return v.(*StreamOptions)
+	}
+	return r.def
+}
+
+// normalizeStreamOptions 校验并补全默认值
+func normalizeStreamOptions(opts StreamOptions) (*StreamOptions, error) {
+	if opts.HeartbeatInterval < 0 || opts.WriteTimeout < 0 {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidParameter, "stream heartbeat interval and write timeout must not be negative")
+	}
+	if opts.HeartbeatInterval > 0 && opts.Heartbeat == "" {
+		opts.Heartbeat = DefaultStreamHeartbeat
+	}
+	return &opts, nil
+}
+
+// SetStreamConfig 设置服务端流式 RPC 输出配置，nil 恢复 grpc-gateway 默认行为；对之后的请求立即生效
+func (s *Server) SetStreamConfig(cfg *StreamConfig) error {
+	if cfg == nil {
+		s.gatewayStream.Store(nil)
+		return nil
+	}
+
+	rules := &gatewayStreamRules{}
+	if cfg.Default != nil {
+		def, err := normalizeStreamOptions(*cfg.Default)
+		if err != nil {
+			return err
+		}
+		rules.def = def
+	}
+
+	// 精确匹配在前，前缀越长越优先（RouteTable 多条命中时返回靠前的规则）
+	routes := append([]StreamRoute(nil), cfg.Routes...)
+	sortStreamRoutes(routes)
+	patterns := make([]middleware.RoutePattern, 0, len(routes))
+	for _, rt := range routes {
+		if rt.Pattern == "" {
+			return errors.NewErrorf(errors.ErrCodeInvalidParameter, "stream route pattern is required")
+		}
+		opts, err := normalizeStreamOptions(rt.StreamOptions)
+		if err != nil {
+			return err
+		}
+		p := middleware.RoutePattern{Kind: middleware.RouteMatchExact, Pattern: rt.Pattern, Value: opts}
+		if prefix, ok := strings.CutSuffix(rt.Pattern, "*"); ok {
+			p.Kind, p.Pattern = middleware.RouteMatchPrefix, prefix
+		}
+		patterns = append(patterns, p)
+	}
+	rules.routes = middleware.NewRouteTable(patterns)
+
+	s.gatewayStream.Store(rules)
+	return nil
+}
+
+// sortStreamRoutes 精确匹配在前，前缀按长度降序
+func sortStreamRoutes(routes []StreamRoute) {
+	rank := func(rt StreamRoute) int {
+		if strings.HasSuffix(rt.Pattern, "*") {
+			return len(rt.Pattern)
+		}
+		return math.MaxInt
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return rank(routes[i]) > rank(routes[j]) })
+}
+
+// streamMiddleware grpc-gateway 中间件：为适用的请求包装 streamWriter，未配置时直接放行
+func (s *Server) streamMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		rules := s.gatewayStream.Load()
+		if rules == nil {
+			next(w, r, params)
+			return
+		}
+		opts := rules.match(r)
+		if opts == nil {
+			next(w, r, params)
+			return
+		}
+
+		sw := &streamWriter{ResponseWriter: w, opts: opts, rc: http.NewResponseController(w)}
+		sw.extendDeadline()
+		defer sw.stop()
+		next(sw, r, params)
+	}
+}
+
+// streamWriter 流式响应写入器：识别 grpc-gateway 流式响应后应用选项，并在空闲时写出心跳
+// 心跳协程与处理器并发写入，全部写操作由 mu 串行化
+type streamWriter struct {
+	http.ResponseWriter
+	opts *StreamOptions
+	rc   *http.ResponseController
+
+	mu        sync.Mutex
+	prepared  bool
+	stopped   bool
+	lastWrite time.Time
+	done      chan struct{}
+}
+
+// prepare 首次写出前判断是否为流式响应（grpc-gateway 流式转发会设置 Transfer-Encoding: chunked）
+func (w *streamWriter) prepare() {
+	if w.prepared {
+		return
+	}
+	w.prepared = true
+	if w.Header().Get("Transfer-Encoding") != "chunked" {
+		return
+	}
+	isJSON := strings.Contains(w.Header().Get("Content-Type"), "json")
+	if w.opts.ContentType != "" {
+		w.Header().Set("Content-Type", w.opts.ContentType)
+	}
+	if w.opts.HeartbeatInterval > 0 && isJSON {
+		w.done = make(chan struct{})
+		go w.heartbeat()
+	}
+}
+
+// extendDeadline 顺延写超时
+func (w *streamWriter) extendDeadline() {
+	if w.opts.WriteTimeout > 0 {
+		_ = w.rc.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+	}
+}
+
+// WriteHeader 写入状态码
+func (w *streamWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prepare()
+	w.extendDeadline()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 写入一条消息（或分隔符）
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prepare()
+	w.extendDeadline()
+	w.lastWrite = time.Now()
+	return w.ResponseWriter.Write(p)
+}
+
+// FlushError 逐条刷新，DisableFlush 时忽略 grpc-gateway 的刷新请求
+func (w *streamWriter) FlushError() error {
+	if w.opts.DisableFlush {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rc.Flush()
+}
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// heartbeat 空闲时写出心跳，直到处理器返回
+func (w *streamWriter) heartbeat() {
+	ticker := time.NewTicker(max(w.opts.HeartbeatInterval/2, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.stopped && time.Since(w.lastWrite) >= w.opts.HeartbeatInterval {
+				w.extendDeadline()
+				if _, err := w.ResponseWriter.Write([]byte(w.opts.Heartbeat)); err == nil {
+					_ = w.rc.Flush()
+				}
+				w.lastWrite = time.Now()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// stop 处理器返回后停止心跳，之后不再写入
+func (w *streamWriter) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.done != nil {
+		close(w.done)
+	}
+}
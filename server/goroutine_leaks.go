@@ -0,0 +1,73 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\goroutine_leaks.go
+ * @Description: 协程泄漏检测接入 - 启动后台检测器并注册报告接口，关闭时停止采样
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetLeakDetector 设置协程泄漏检测，nil 关闭；替换配置时采样历史重新累积
+func (s *Server) SetLeakDetector(cfg *middleware.LeakDetectorConfig) error {
+	if cfg == nil {
+		if old := s.leakDetector.Swap(nil); old != nil {
+			old.Stop(context.Background())
+			global.LOGGER.InfoKV("协程泄漏检测已关闭")
+		}
+		return nil
+	}
+
+	detector, err := middleware.NewLeakDetector(*cfg)
+	if err != nil {
+		return err
+	}
+	if old := s.leakDetector.Swap(detector); old != nil {
+		old.Stop(context.Background())
+	}
+	detector.Start()
+
+	path := detector.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, middleware.RequireFeature(middleware.FeatureDebugEndpoints, s.leakReportHandler))
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("协程泄漏检测已启用",
+		"interval", cfg.Interval,
+		"window", cfg.Window,
+		"admin_path", path)
+	return nil
+}
+
+// GetLeakDetector 当前生效的协程泄漏检测器，未配置时返回 nil
+func (s *Server) GetLeakDetector() *middleware.LeakDetector {
+	return s.leakDetector.Load()
+}
+
+// leakReportHandler 报告接口，使用当前生效的检测器
+func (s *Server) leakReportHandler(w http.ResponseWriter, r *http.Request) {
+	detector := s.leakDetector.Load()
+	if detector == nil {
+		response.WriteServiceUnavailableResult(w, "leak detector is not configured")
+		return
+	}
+	detector.AdminHandler()(w, r)
+}
+
+// stopLeakDetector 停止采样
+func (s *Server) stopLeakDetector(ctx context.Context) {
+	if detector := s.leakDetector.Swap(nil); detector != nil {
+		detector.Stop(ctx)
+	}
+}
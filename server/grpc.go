@@ -12,13 +12,12 @@
 package server
 
 import (
+	"context"
 	stderrors "errors"
-	"fmt"
 	"net"
 	"time"
 
 	grpcpool "github.com/kamalyes/go-rpc-gateway/cpool/grpc"
-	"github.com/kamalyes/go-rpc-gateway/errors"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-rpc-gateway/middleware"
 	"google.golang.org/grpc"
@@ -71,8 +70,12 @@ func (s *Server) initGRPCServer() error {
 	}
 
 	// 添加Keepalive配置
+	var (
+		keepalivePolicy      keepalive.ServerParameters
+		keepaliveEnforcement *keepalive.EnforcementPolicy
+	)
 	if grpcServer.KeepaliveTime > 0 {
-		keepalivePolicy := keepalive.ServerParameters{
+		keepalivePolicy = keepalive.ServerParameters{
 			Time:    time.Duration(grpcServer.KeepaliveTime) * time.Second,
 			Timeout: time.Duration(grpcServer.KeepaliveTimeout) * time.Second,
 		}
@@ -85,16 +88,39 @@ func (s *Server) initGRPCServer() error {
 
 	// 添加连接超时配置
 	if grpcServer.ConnectionTimeout > 0 {
-		keepaliveEnforcement := keepalive.EnforcementPolicy{
+		keepaliveEnforcement = &keepalive.EnforcementPolicy{
 			MinTime:             time.Duration(grpcServer.ConnectionTimeout) * time.Second,
 			PermitWithoutStream: true,
 		}
-		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepaliveEnforcement))
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(*keepaliveEnforcement))
 
 		global.LOGGER.InfoKV("gRPC连接超时配置已启用",
 			"connection_timeout", grpcServer.ConnectionTimeout)
 	}
 
+	// 调优配置（连接寿命、并发流、强制策略，覆盖上面的同类设置）
+	opts = append(opts, s.grpcTuningOptions(keepalivePolicy, keepaliveEnforcement)...)
+
+	// 在途请求统计（位于拦截器链最外层）
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.inflight.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(s.inflight.StreamServerInterceptor()),
+	)
+
+	// 构建信息响应元数据（SetBuildInfoConfig 开启 ResponseHeaders 后生效）与请求截止时间上限
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.buildInfoUnaryInterceptor, s.deadlineUnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.buildInfoStreamInterceptor),
+	)
+
+	// 按服务的消息大小上限与响应压缩
+	if s.grpcTuning != nil && len(s.grpcTuning.Services) > 0 {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(serviceTuningUnaryInterceptor(s.grpcTuning)),
+			grpc.ChainStreamInterceptor(serviceTuningStreamInterceptor(s.grpcTuning)),
+		)
+	}
+
 	// 添加中间件拦截器链（按执行顺序）
 	if s.middlewareManager != nil {
 		// 构建 Unary 拦截器链
@@ -118,6 +144,11 @@ func (s *Server) initGRPCServer() error {
 			unaryInterceptors = append(unaryInterceptors, tracingInterceptor)
 		}
 
+		// 添加限流拦截器（与 HTTP 限流共用配置与计数，在参数校验之前拒绝超限调用）
+		if rateLimitInterceptor := s.middlewareManager.GRPCRateLimitUnaryInterceptor(); rateLimitInterceptor != nil {
+			unaryInterceptors = append(unaryInterceptors, rateLimitInterceptor)
+		}
+
 		// 添加 struct tag 参数校验拦截器（配合 protoc-go-inject-tag 生效）
 		unaryInterceptors = append(unaryInterceptors, s.middlewareManager.GRPCStructTagValidatorInterceptor())
 
@@ -132,9 +163,14 @@ func (s *Server) initGRPCServer() error {
 		streamInterceptors := []grpc.StreamServerInterceptor{
 			middleware.StreamServerRequestContextInterceptor(), // 1. RequestContext 注入
 			middleware.StreamServerLoggingInterceptor(),        // 2. 日志记录
-			s.middlewareManager.GRPCStructTagValidatorStreamInterceptor(),
 		}
 
+		// 添加 Stream 限流拦截器（建立流时计数）
+		if rateLimitStreamInterceptor := s.middlewareManager.GRPCRateLimitStreamInterceptor(); rateLimitStreamInterceptor != nil {
+			streamInterceptors = append(streamInterceptors, rateLimitStreamInterceptor)
+		}
+		streamInterceptors = append(streamInterceptors, s.middlewareManager.GRPCStructTagValidatorStreamInterceptor())
+
 		// 添加 i18n Stream 拦截器（如果启用国际化）
 		if i18nStreamInterceptor := s.middlewareManager.GRPCStreamI18nInterceptor(); i18nStreamInterceptor != nil {
 			streamInterceptors = append(streamInterceptors, i18nStreamInterceptor)
@@ -148,6 +184,12 @@ func (s *Server) initGRPCServer() error {
 		opts = append(opts, grpc.ChainStreamInterceptor(streamInterceptors...))
 	}
 
+	// metadata 大小限制、优先级并发限制、统一认证、外部授权与 Casbin 授权（对应 Set* 配置后生效，位于日志与监控拦截器之后）
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.headerLimitUnaryInterceptor, s.priorityLimitUnaryInterceptor, s.authnUnaryInterceptor, s.extAuthzUnaryInterceptor, s.casbinUnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.headerLimitStreamInterceptor, s.priorityLimitStreamInterceptor, s.authnStreamInterceptor, s.extAuthzStreamInterceptor, s.casbinStreamInterceptor),
+	)
+
 	s.grpcServer = grpc.NewServer(opts...)
 
 	// 启用反射
@@ -175,8 +217,8 @@ func (s *Server) initGRPCServer() error {
 	return nil
 }
 
-// startGRPCServer 启动gRPC服务器
-func (s *Server) startGRPCServer() error {
+// startGRPCServer 启动gRPC服务器，listener 为 Start 预先绑定的监听器，nil 时自行绑定
+func (s *Server) startGRPCServer(listener net.Listener) error {
 	grpcServer := s.config.GRPC.Server
 
 	// 检查是否启用 gRPC 服务
@@ -191,11 +233,35 @@ func (s *Server) startGRPCServer() error {
 		return nil
 	}
 
-	address := fmt.Sprintf("%s:%d", grpcServer.Host, grpcServer.Port)
+	// 进程内通道：只服务内存监听器，不占用 TCP 端口
+	if s.inProcessGRPC.Enabled && s.inProcessGRPC.SkipTCP {
+		s.registerGRPCHealth(grpcServerInstance)
+		global.LOGGER.InfoMsg("Starting gRPC server (in-process only)")
+		if err := grpcServerInstance.Serve(s.newInProcessListener()); err != nil && !stderrors.Is(err, grpc.ErrServerStopped) {
+			return err
+		}
+		return nil
+	}
 
-	listener, err := net.Listen(grpcServer.Network, address)
-	if err != nil {
-		return errors.NewErrorf(errors.ErrCodeGRPCConnectionFailed, "failed to listen on %s: %v", address, err)
+	if listener == nil {
+		var err error
+		if listener, _, err = s.bindPort("gRPC", grpcServer.Network, grpcServer.Host, grpcServer.Port); err != nil {
+			return err
+		}
+	}
+	address := listener.Addr().String()
+
+	// 自动注册标准健康检查服务（必须在 Serve 之前）
+	s.registerGRPCHealth(grpcServerInstance)
+
+	// 进程内通道：同一 gRPC 服务实例额外挂在内存监听器上
+	if s.inProcessGRPC.Enabled {
+		inProcessListener := s.newInProcessListener()
+		go func() {
+			if err := grpcServerInstance.Serve(inProcessListener); err != nil && !stderrors.Is(err, grpc.ErrServerStopped) {
+				global.LOGGER.WithError(err).ErrorMsg("in-process gRPC listener failed")
+			}
+		}()
 	}
 
 	global.LOGGER.InfoKV("Starting gRPC server", "address", address)
@@ -205,12 +271,33 @@ func (s *Server) startGRPCServer() error {
 	return nil
 }
 
-// stopGRPCServer 停止gRPC服务器
+// stopGRPCServer 停止gRPC服务器（使用排空超时）
 func (s *Server) stopGRPCServer() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownConfig.DrainTimeout)
+	defer cancel()
+	s.shutdownGRPCServer(ctx)
+}
+
+// shutdownGRPCServer 优雅停止gRPC服务器，ctx 超时后强制断开剩余连接
+func (s *Server) shutdownGRPCServer(ctx context.Context) {
 	grpcServerInstance := s.grpcServer
-	if grpcServerInstance != nil {
-		global.LOGGER.InfoContext(s.ctx, "Stopping gRPC server...")
+	if grpcServerInstance == nil {
+		return
+	}
+
+	global.LOGGER.InfoContext(ctx, "Stopping gRPC server...")
+	stopped := make(chan struct{})
+	go func() {
 		grpcServerInstance.GracefulStop()
-		global.LOGGER.InfoContext(s.ctx, "gRPC server stopped")
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		global.LOGGER.WarnKV("gRPC 优雅停止超时，强制关闭", "inflight", s.InFlightRequests().GRPC)
+		grpcServerInstance.Stop()
+		<-stopped
 	}
+	global.LOGGER.InfoContext(ctx, "gRPC server stopped")
 }
@@ -0,0 +1,176 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\grpc_health.go
+ * @Description: gRPC 标准健康检查服务 - 启用健康检查时自动注册 grpc.health.v1.Health，
+ *               按 HealthChecker 探测结果更新各服务状态，关闭时提前切换为 NOT_SERVING
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 不参与健康状态汇总的内置服务
+const (
+	grpcReflectionServiceV1      = "grpc.reflection.v1.ServerReflection"
+	grpcReflectionServiceV1Alpha = "grpc.reflection.v1alpha.ServerReflection"
+)
+
+// GRPCHealthConfig gRPC 健康检查服务配置
+type GRPCHealthConfig struct {
+	Interval     time.Duration       // 探测间隔
+	Timeout      time.Duration       // 单次探测超时
+	Dependencies map[string][]string // 服务名 → 依赖的 HealthChecker 名称，任一依赖 error 时该服务 NOT_SERVING；未配置的服务跟随整体状态
+}
+
+// DefaultGRPCHealthConfig 默认 gRPC 健康检查服务配置
+func DefaultGRPCHealthConfig() *GRPCHealthConfig {
+	return &GRPCHealthConfig{
+		Interval: 10 * time.Second,
+		Timeout:  5 * time.Second,
+	}
+}
+
+// grpcHealthState gRPC 健康检查服务状态
+type grpcHealthState struct {
+	server   *health.Server
+	config   atomic.Pointer[GRPCHealthConfig]
+	services atomic.Pointer[[]string] // 已注册到 gRPC Server 的业务服务
+}
+
+// newGRPCHealthState 创建 gRPC 健康检查服务状态
+func newGRPCHealthState() *grpcHealthState {
+	state := &grpcHealthState{server: health.NewServer()}
+	state.config.Store(DefaultGRPCHealthConfig())
+	return state
+}
+
+// SetGRPCHealthConfig 设置 gRPC 健康检查服务配置
+func (s *Server) SetGRPCHealthConfig(cfg *GRPCHealthConfig) {
+	if cfg == nil {
+		cfg = DefaultGRPCHealthConfig()
+	}
+	s.grpcHealth.config.Store(cfg)
+}
+
+// GetGRPCHealthServer 获取 gRPC 健康检查服务，可用于手动设置服务状态
+func (s *Server) GetGRPCHealthServer() *health.Server {
+	return s.grpcHealth.server
+}
+
+// registerGRPCHealth 在 Serve 前注册标准健康检查服务（业务方已手动注册时跳过）
+func (s *Server) registerGRPCHealth(grpcServer *grpc.Server) {
+	if !s.config.Health.Enabled {
+		return
+	}
+
+	info := grpcServer.GetServiceInfo()
+	if _, exists := info[healthpb.Health_ServiceDesc.ServiceName]; exists {
+		global.LOGGER.InfoMsg("gRPC 健康检查服务已由业务方注册，跳过自动注册")
+		return
+	}
+	healthpb.RegisterHealthServer(grpcServer, s.grpcHealth.server)
+
+	services := make([]string, 0, len(info))
+	for name := range info {
+		switch name {
+		case grpcReflectionServiceV1, grpcReflectionServiceV1Alpha:
+			continue
+		}
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	s.grpcHealth.services.Store(&services)
+
+	s.updateGRPCHealth(context.Background())
+	global.LOGGER.InfoKV("gRPC 健康检查服务已注册", "services", services)
+}
+
+// runGRPCHealthProbe 按间隔执行 HealthChecker 探测并同步到 gRPC 健康状态，服务器上下文取消时退出
+func (s *Server) runGRPCHealthProbe() {
+	if !s.config.Health.Enabled {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			interval := s.grpcHealth.config.Load().Interval
+			if interval <= 0 {
+				interval = DefaultGRPCHealthConfig().Interval
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(interval):
+				s.updateGRPCHealth(s.ctx)
+			}
+		}
+	}()
+}
+
+// updateGRPCHealth 执行一次探测并更新整体与各服务状态
+func (s *Server) updateGRPCHealth(ctx context.Context) {
+	cfg := s.grpcHealth.config.Load()
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	var failed map[string]bool
+	if s.healthManager != nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultGRPCHealthConfig().Timeout
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		result := s.healthManager.Check(checkCtx, true)
+		cancel()
+
+		failed = make(map[string]bool, len(result.Checks))
+		for name, status := range result.Checks {
+			failed[name] = status.Status == "error"
+		}
+		if result.Status == "error" {
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	// 关闭后 health.Server 会忽略状态更新，无需额外判断
+	s.grpcHealth.server.SetServingStatus("", overall)
+	services := s.grpcHealth.services.Load()
+	if services == nil {
+		return
+	}
+	for _, service := range *services {
+		status := overall
+		if deps, ok := cfg.Dependencies[service]; ok {
+			status = healthpb.HealthCheckResponse_SERVING
+			for _, dep := range deps {
+				if failed[dep] {
+					status = healthpb.HealthCheckResponse_NOT_SERVING
+					break
+				}
+			}
+		}
+		s.grpcHealth.server.SetServingStatus(service, status)
+	}
+}
+
+// shutdownGRPCHealth 将所有服务切换为 NOT_SERVING，使负载均衡尽早摘除实例
+func (s *Server) shutdownGRPCHealth() {
+	s.grpcHealth.server.Shutdown()
+	global.LOGGER.InfoMsg("gRPC 健康状态已切换为 NOT_SERVING")
+}
@@ -0,0 +1,75 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\grpc_inprocess.go
+ * @Description: 进程内 gRPC 通道 - gRPC 服务额外挂在内存监听器上，网关调用本进程服务时
+ *               不经过 localhost TCP，服务端拦截器链保持不变
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// DefaultInProcessBufferSize 内存连接默认缓冲区大小
+const DefaultInProcessBufferSize = 1 << 20
+
+// InProcessTarget 进程内连接使用的 gRPC target（仅用于日志与连接标识）
+const InProcessTarget = "passthrough:///in-process"
+
+// InProcessGRPCConfig 进程内 gRPC 通道配置
+type InProcessGRPCConfig struct {
+	Enabled    bool // 网关调用本进程 gRPC 服务时走内存连接
+	BufferSize int  // 内存连接缓冲区大小，默认 1MiB
+	SkipTCP    bool // 不监听 gRPC TCP 端口，服务仅供进程内调用（外部 gRPC 客户端将无法访问）
+}
+
+// SetInProcessGRPC 设置进程内 gRPC 通道，需在 Start 之前调用
+func (s *Server) SetInProcessGRPC(cfg InProcessGRPCConfig) error {
+	if cfg.BufferSize < 0 {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "in-process gRPC buffer size must not be negative")
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = DefaultInProcessBufferSize
+	}
+	s.inProcessGRPC = cfg
+	return nil
+}
+
+// InProcessGRPCEnabled 是否启用进程内 gRPC 通道
+func (s *Server) InProcessGRPCEnabled() bool {
+	return s.inProcessGRPC.Enabled
+}
+
+// InProcessDialOptions 连接进程内 gRPC 服务的 dial options
+// 每次建连读取当前的内存监听器，gRPC 服务重载后客户端连接自动重连到新实例
+func (s *Server) InProcessDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			lis := s.inProcessListener.Load()
+			if lis == nil {
+				return nil, errors.NewError(errors.ErrCodeServiceUnavailable, "in-process gRPC server is not serving")
+			}
+			return lis.DialContext(ctx)
+		}),
+	}
+}
+
+// newInProcessListener 为本次启动创建内存监听器，gRPC 服务停止时随之关闭
+func (s *Server) newInProcessListener() *bufconn.Listener {
+	lis := bufconn.Listen(s.inProcessGRPC.BufferSize)
+	s.inProcessListener.Store(lis)
+	return lis
+}
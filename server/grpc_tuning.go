@@ -0,0 +1,220 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\grpc_tuning.go
+ * @Description: gRPC Server 调优 - keepalive 强制策略、连接寿命、最大并发流，
+ *               以及按服务设置消息大小上限与响应压缩
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	grpcpool "github.com/kamalyes/go-rpc-gateway/cpool/grpc"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCTuningConfig gRPC Server 调优配置（零值字段不覆盖 go-config 中的设置）
+type GRPCTuningConfig struct {
+	// keepalive 强制策略：客户端 ping 间隔小于 MinTime 时断开连接
+	KeepaliveMinTime    time.Duration
+	PermitWithoutStream bool
+
+	// 连接寿命：空闲超时、最大存活时间及到期后的宽限期
+	MaxConnectionIdle     time.Duration
+	MaxConnectionAge      time.Duration
+	MaxConnectionAgeGrace time.Duration
+
+	// 单连接最大并发流
+	MaxConcurrentStreams uint32
+
+	// 传输层 metadata 总大小上限（字节），超过时连接层直接拒绝，防止超大头部占用内存
+	MaxHeaderListSize uint32
+
+	// 额外注册的压缩算法（gzip / zstd），供客户端请求或按服务响应压缩使用
+	Compressors []string
+
+	// 按服务调优，key 为完整服务名（如 user.v1.UserService）
+	Services map[string]*GRPCServiceTuning
+}
+
+// GRPCServiceTuning 单个 gRPC 服务调优配置
+type GRPCServiceTuning struct {
+	MaxRecvMsgSize int    // 请求消息大小上限（字节），只能比服务器级上限更严格
+	MaxSendMsgSize int    // 响应消息大小上限（字节），只能比服务器级上限更严格
+	Compression    string // 响应压缩算法（需客户端声明支持）
+}
+
+// SetGRPCTuningConfig 设置 gRPC Server 调优配置
+// gRPC Server 未运行且尚未注册服务时立即重建；否则在下次 ReloadGRPCServer 时生效
+func (s *Server) SetGRPCTuningConfig(cfg *GRPCTuningConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.grpcTuning = cfg
+	if s.grpcServer == nil {
+		return nil
+	}
+	if s.running || len(s.grpcServer.GetServiceInfo()) > 0 {
+		global.LOGGER.WarnMsg("gRPC Server 已注册服务或正在运行，调优配置将在下次重建时生效")
+		return nil
+	}
+
+	s.grpcServer = nil
+	return s.initGRPCServer()
+}
+
+// grpcTuningOptions 构建调优相关的 ServerOption，base 为 go-config 中的 keepalive 参数
+func (s *Server) grpcTuningOptions(base keepalive.ServerParameters, enforcement *keepalive.EnforcementPolicy) []grpc.ServerOption {
+	cfg := s.grpcTuning
+	if cfg == nil {
+		return nil
+	}
+
+	var opts []grpc.ServerOption
+
+	params := base
+	params.MaxConnectionIdle = cfg.MaxConnectionIdle
+	params.MaxConnectionAge = cfg.MaxConnectionAge
+	params.MaxConnectionAgeGrace = cfg.MaxConnectionAgeGrace
+	if params != base {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+
+	if cfg.KeepaliveMinTime > 0 || cfg.PermitWithoutStream {
+		policy := keepalive.EnforcementPolicy{
+			MinTime:             cfg.KeepaliveMinTime,
+			PermitWithoutStream: cfg.PermitWithoutStream,
+		}
+		if policy.MinTime <= 0 && enforcement != nil {
+			policy.MinTime = enforcement.MinTime
+		}
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(policy))
+	}
+
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+
+	if cfg.MaxHeaderListSize > 0 {
+		opts = append(opts, grpc.MaxHeaderListSize(cfg.MaxHeaderListSize))
+	}
+
+	for _, name := range cfg.Compressors {
+		grpcpool.EnsureCompressorRegistered(name)
+	}
+	for _, svc := range cfg.Services {
+		if svc != nil && svc.Compression != "" {
+			grpcpool.EnsureCompressorRegistered(svc.Compression)
+		}
+	}
+
+	global.LOGGER.InfoKV("gRPC 调优配置已应用",
+		"max_connection_idle", cfg.MaxConnectionIdle.String(),
+		"max_connection_age", cfg.MaxConnectionAge.String(),
+		"max_concurrent_streams", cfg.MaxConcurrentStreams,
+		"max_header_list_size", cfg.MaxHeaderListSize,
+		"keepalive_min_time", cfg.KeepaliveMinTime.String(),
+		"services", len(cfg.Services))
+	return opts
+}
+
+// serviceTuning 按完整方法名（/pkg.Service/Method）查找服务调优配置
+func (cfg *GRPCTuningConfig) serviceTuning(fullMethod string) *GRPCServiceTuning {
+	if cfg == nil || len(cfg.Services) == 0 {
+		return nil
+	}
+	service, _, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil
+	}
+	return cfg.Services[service]
+}
+
+// checkMsgSize 校验消息大小
+func checkMsgSize(msg any, limit int, direction string) error {
+	if limit <= 0 {
+		return nil
+	}
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	if size := proto.Size(m); size > limit {
+		return status.Errorf(codes.ResourceExhausted, "grpc: %s message larger than max (%d vs. %d)", direction, size, limit)
+	}
+	return nil
+}
+
+// serviceTuningUnaryInterceptor 按服务限制消息大小并设置响应压缩
+func serviceTuningUnaryInterceptor(cfg *GRPCTuningConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tuning := cfg.serviceTuning(info.FullMethod)
+		if tuning == nil {
+			return handler(ctx, req)
+		}
+		if err := checkMsgSize(req, tuning.MaxRecvMsgSize, "received"); err != nil {
+			return nil, err
+		}
+		if tuning.Compression != "" {
+			// 客户端未声明支持该算法时忽略，保持默认编码
+			_ = grpc.SetSendCompressor(ctx, tuning.Compression)
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if err := checkMsgSize(resp, tuning.MaxSendMsgSize, "sent"); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// serviceTuningStreamInterceptor 按服务限制流消息大小并设置响应压缩
+func serviceTuningStreamInterceptor(cfg *GRPCTuningConfig) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tuning := cfg.serviceTuning(info.FullMethod)
+		if tuning == nil {
+			return handler(srv, ss)
+		}
+		if tuning.Compression != "" {
+			_ = grpc.SetSendCompressor(ss.Context(), tuning.Compression)
+		}
+		return handler(srv, &tunedServerStream{ServerStream: ss, tuning: tuning})
+	}
+}
+
+// tunedServerStream 按服务限制消息大小的 ServerStream
+type tunedServerStream struct {
+	grpc.ServerStream
+	tuning *GRPCServiceTuning
+}
+
+// RecvMsg 接收消息并校验大小
+func (s *tunedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkMsgSize(m, s.tuning.MaxRecvMsgSize, "received")
+}
+
+// SendMsg 校验大小后发送消息
+func (s *tunedServerStream) SendMsg(m any) error {
+	if err := checkMsgSize(m, s.tuning.MaxSendMsgSize, "sent"); err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
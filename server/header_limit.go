@@ -0,0 +1,75 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\header_limit.go
+ * @Description: 请求头 / metadata 限制接入 - HTTP 中间件链与 gRPC 拦截器链共用同一份限制，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"google.golang.org/grpc"
+)
+
+// SetHeaderLimit 设置请求头条数与大小限制，nil 关闭
+// HTTP 检查位于日志与监控之后、限流之前；传输层上限分别由 HTTPTuningConfig.MaxHeaderBytes 与 GRPCTuningConfig.MaxHeaderListSize 控制
+func (s *Server) SetHeaderLimit(cfg *middleware.HeaderLimitConfig) error {
+	if cfg == nil {
+		s.headerLimiter.Store(nil)
+		return nil
+	}
+	limiter, err := middleware.NewHeaderLimiter(*cfg)
+	if err != nil {
+		return err
+	}
+	s.headerLimiter.Store(limiter)
+	if !s.headerLimiterRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareHeaderLimit, middleware.PriorityHeaderLimit, s.headerLimitMiddleware)
+	}
+	global.LOGGER.InfoKV("请求头限制已启用",
+		"max_count", cfg.MaxCount,
+		"max_name_bytes", cfg.MaxNameBytes,
+		"max_value_bytes", cfg.MaxValueBytes,
+		"max_total_bytes", cfg.MaxTotalBytes,
+		"overrides", len(cfg.Limits))
+	return nil
+}
+
+// headerLimitMiddleware HTTP 请求头限制，未配置时直接放行
+func (s *Server) headerLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := s.headerLimiter.Load()
+		if limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limiter.Handle(w, r, next)
+	})
+}
+
+// headerLimitUnaryInterceptor gRPC 一元调用 metadata 限制，未配置时直接放行
+func (s *Server) headerLimitUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	limiter := s.headerLimiter.Load()
+	if limiter == nil {
+		return handler(ctx, req)
+	}
+	return limiter.Unary(ctx, req, info, handler)
+}
+
+// headerLimitStreamInterceptor gRPC 流式调用 metadata 限制，未配置时直接放行
+func (s *Server) headerLimitStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	limiter := s.headerLimiter.Load()
+	if limiter == nil {
+		return handler(srv, ss)
+	}
+	return limiter.Stream(srv, ss, info, handler)
+}
@@ -31,7 +31,6 @@ import (
 	"github.com/kamalyes/go-rpc-gateway/response"
 	"github.com/kamalyes/go-toolbox/pkg/desensitize"
 	"github.com/kamalyes/go-toolbox/pkg/httpx"
-	"github.com/kamalyes/go-toolbox/pkg/mathx"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
@@ -47,7 +46,8 @@ func (s *Server) buildServeMuxOptions() []runtime.ServeMuxOption {
 	discardUnknown := s.config.JSON.DiscardUnknown
 
 	opts := []runtime.ServeMuxOption{
-		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		// HttpBody 响应按其 content_type 原样输出，不做 JSON 包装
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, withHTTPBody(&runtime.JSONPb{
 			MarshalOptions: protojson.MarshalOptions{
 				UseProtoNames:   useProtoNames,   // 使用 proto 字段名（snake_case）
 				EmitUnpopulated: emitUnpopulated, // 输出所有字段，包括零值
@@ -55,28 +55,30 @@ func (s *Server) buildServeMuxOptions() []runtime.ServeMuxOption {
 			UnmarshalOptions: protojson.UnmarshalOptions{
 				DiscardUnknown: discardUnknown, // 忽略未知字段
 			},
-		}),
+		})),
+		runtime.WithForwardResponseOption(httpBodyResponseOption),
 		// 🔑 将 HTTP Header 传递到 gRPC metadata（过滤 HTTP/2 禁止的头，避免 RST_STREAM PROTOCOL_ERROR）
-		runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
-			// HTTP/2 规范禁止的头，转发这些头会导致 gRPC 服务端发送 RST_STREAM
-			switch strings.ToLower(key) {
-			case "connection", "keep-alive", "proxy-connection",
-				"transfer-encoding", "upgrade", "te":
-				return key, false
-			case "authorization":
-				// grpc-gateway 的 AnnotateContext 已对 Authorization 做无条件转发（向后兼容），
-				// 此处再匹配会导致 metadata 中出现重复的 authorization 值，
-				// 下游服务解析 token 失败 → 认证失败 → 无数据权限
-				return key, false
-			}
-			return key, true
-		}),
+		// 转发允许名单、metadata → 响应头与查询参数解析规则见 SetGatewayHeaderConfig
+		runtime.WithIncomingHeaderMatcher(s.incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(s.outgoingHeaderMatcher),
+		runtime.SetQueryParameterParser(&gatewayQueryParser{server: s}),
+		// 404/405 交给可自定义的错误处理器（默认 Result 结构 + 国际化消息）
+		runtime.WithRoutingErrorHandler(s.routingErrorHandler),
+		// 上游建连 / DNS / TLS / 超时 / 响应无法解析等失败按分类返回 Result，其余错误沿用默认处理
+		runtime.WithErrorHandler(middleware.UpstreamHTTPErrorHandler),
+	}
+
+	// 转发调用标记已在 HTTP 层限流，网关代理到本进程 gRPC 服务时限流拦截器不再重复计数
+	if s.middlewareManager != nil {
+		if annotator := s.middlewareManager.GRPCGatewayRateLimitAnnotator(); annotator != nil {
+			opts = append(opts, runtime.WithMetadata(annotator))
+		}
 	}
 
 	// 启用 Protobuf 响应支持（当 gRPC Server 配置了 EnableProtobufResp 时）
 	if s.config.GRPC != nil && s.config.GRPC.Server != nil && s.config.GRPC.Server.EnableProtobufResp {
-		opts = append(opts, runtime.WithMarshalerOption("application/x-protobuf", &protobufMarshaler{}))
-		opts = append(opts, runtime.WithMarshalerOption("application/protobuf", &protobufMarshaler{}))
+		opts = append(opts, runtime.WithMarshalerOption("application/x-protobuf", withHTTPBody(&protobufMarshaler{})))
+		opts = append(opts, runtime.WithMarshalerOption("application/protobuf", withHTTPBody(&protobufMarshaler{})))
 		global.LOGGER.InfoMsg("✅ Protobuf 响应格式已启用（支持 application/x-protobuf 和 application/protobuf）")
 	}
 
@@ -166,11 +168,12 @@ func (s *Server) initGzipWriterPool() {
 		},
 	}
 
-	// 预处理跳过路径和扩展名为 map，提升查找性能（O(1) vs O(n)）
-	s.gzipSkipPathsMap = make(map[string]bool, len(s.config.HTTPServer.GzipSkipPaths))
+	// 跳过路径编译为前缀路由表，扩展名预处理为 map
+	patterns := make([]middleware.RoutePattern, 0, len(s.config.HTTPServer.GzipSkipPaths))
 	for _, path := range s.config.HTTPServer.GzipSkipPaths {
-		s.gzipSkipPathsMap[path] = true
+		patterns = append(patterns, middleware.RoutePattern{Kind: middleware.RouteMatchPrefix, Pattern: path, Value: true})
 	}
+	s.gzipSkipPaths = middleware.NewRouteTable(patterns)
 
 	s.gzipSkipExtensionsMap = make(map[string]bool, len(s.config.HTTPServer.GzipSkipExtensions))
 	for _, ext := range s.config.HTTPServer.GzipSkipExtensions {
@@ -194,22 +197,26 @@ func (w *gzipResponseWriter) Close() error {
 	return w.gzipWriter.Close()
 }
 
-// shouldSkipGzip 判断是否跳过 gzip 压缩（使用预处理的 map，O(1) 查找）
+// Flush 先刷新已压缩的数据再刷新底层连接，保证流式响应（SSE、grpc-gateway 服务端流）及时送达
+func (w *gzipResponseWriter) Flush() {
+	_ = w.gzipWriter.Flush()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// shouldSkipGzip 判断是否跳过 gzip 压缩
 func (s *Server) shouldSkipGzip(r *http.Request) bool {
 	path := r.URL.Path
 
-	// 检查完整路径是否在跳过列表中
-	if s.gzipSkipPathsMap[path] {
+	// 检查路径前缀（包含完整路径）
+	if _, ok := s.gzipSkipPaths.Match("", path); ok {
 		return true
 	}
 
-	// 检查路径前缀（遍历 map 的 key）
-	for skipPath := range s.gzipSkipPathsMap {
-		if strings.HasPrefix(path, skipPath) {
-			return true
-		}
-	}
-
 	// 检查文件扩展名（直接 map 查找）
 	for ext := range s.gzipSkipExtensionsMap {
 		if strings.HasSuffix(path, ext) {
@@ -314,8 +321,14 @@ func (s *Server) initHTTPGateway() error {
 	if s.middlewareManager != nil {
 		validatorMW := s.middlewareManager.GRPCGatewayStructTagValidatorMiddleware()
 		allMiddlewares = append(allMiddlewares, validatorMW)
+		// 命中的 HTTP 规则模板回填给指标中间件作为 path 标签
+		allMiddlewares = append(allMiddlewares, s.middlewareManager.GRPCGatewayPathPatternMiddleware())
+		s.middlewareManager.SetPathRouteResolver(s.httpRouteLabel)
 	}
 
+	// 流式响应输出选项与 HttpBody 流式透传（HttpBody 必须位于最内层）
+	allMiddlewares = append(allMiddlewares, s.streamMiddleware, httpBodyMiddleware)
+
 	// 中间件数量超过阈值时警告（warn-only 模式，不硬限制）
 	if len(allMiddlewares) > middlewareWarnThreshold {
 		global.LOGGER.WarnContext(s.ctx, "⚠️  中间件数量超过建议值",
@@ -329,6 +342,7 @@ func (s *Server) initHTTPGateway() error {
 		global.LOGGER.InfoContext(s.ctx, "✅ 已注册 %d 个 gRPC-Gateway 中间件", len(allMiddlewares))
 	}
 
+	s.gwMuxOptions = opts
 	s.gwMux = runtime.NewServeMux(opts...)
 
 	// 创建HTTP多路复用器
@@ -362,15 +376,18 @@ func (s *Server) initHTTPGateway() error {
 		global.LOGGER.InfoKV("📊 监控指标服务可用", "url", "http://"+httpEndpoint+prometheusPath)
 	}
 
-	// 应用中间件
-	var handler http.Handler = s.httpMux
+	// 应用中间件（OPTIONS/HEAD 自动处理位于业务路由之前、中间件链之后）
+	var handler http.Handler = s.autoMethodMiddleware(s.httpMux)
 
+	// 中间件链在此一次性编译，自定义中间件变更时重新编译并原子替换
+	s.httpChain = middleware.NewCompiledChain(handler)
 	if s.middlewareManager != nil {
-		var middlewares []middleware.MiddlewareFunc
-		middlewares = s.middlewareManager.GetMiddlewares()
-		handler = middleware.ApplyMiddlewares(handler, middlewares...)
+		s.httpChain.Compile(s.middlewareManager.Chain())
 	}
 
+	// 在途请求统计（位于中间件链最外层），其内设置请求截止时间
+	handler = s.inflight.HTTPMiddleware(s.deadlineMiddleware(s.httpChain))
+
 	// 最后应用Gzip压缩中间件（如果启用）
 	// 注意：Gzip 应该在日志中间件之后执行，否则日志记录的是压缩后的乱码
 	if s.config.HTTPServer.EnableGzipCompress {
@@ -378,6 +395,9 @@ func (s *Server) initHTTPGateway() error {
 		global.LOGGER.InfoMsg("✅ HTTP Gzip压缩已启用")
 	}
 
+	// 慢速请求体防护（位于 h2c 之内，保证可设置底层连接读超时）
+	handler = s.slowlorisMiddleware(handler)
+
 	// 根据配置决定是否启用 HTTP/2
 	if s.config.HTTPServer.EnableHTTP2 {
 		h2s := s.buildHTTP2Server()
@@ -387,15 +407,15 @@ func (s *Server) initHTTPGateway() error {
 
 	// 创建 HTTP 服务器
 	s.httpServer = &http.Server{
-		Addr:              httpEndpoint,
-		Handler:           handler,
-		ReadTimeout:       time.Duration(s.config.HTTPServer.ReadTimeout) * time.Second,
-		ReadHeaderTimeout: time.Duration(s.config.HTTPServer.ReadHeaderTimeout) * time.Second,
-		WriteTimeout:      time.Duration(s.config.HTTPServer.WriteTimeout) * time.Second,
-		IdleTimeout:       time.Duration(s.config.HTTPServer.IdleTimeout) * time.Second,
-		MaxHeaderBytes:    s.config.HTTPServer.MaxHeaderBytes,
-		TLSConfig:         s.buildTLSConfig(),
+		Addr:      httpEndpoint,
+		Handler:   handler,
+		TLSConfig: s.buildTLSConfig(),
 	}
+	tuning := s.resolveHTTPTuning()
+	warnDangerousHTTPTuning(tuning)
+	applyHTTPTuning(s.httpServer, tuning)
+	s.httpServer.ConnState = s.slowlorisConnState(s.httpServer)
+	s.httpServer.ConnContext = slowlorisConnContext
 
 	return nil
 }
@@ -430,9 +450,9 @@ func (s *Server) registerComponentHealthChecks() {
 	// 如: Elasticsearch, MongoDB, Kafka 等
 }
 
-// startHTTPServer 启动HTTP服务器
+// startHTTPServer 启动HTTP服务器，listener 为 Start 预先绑定的监听器，nil 时自行绑定
 // 当 HTTPServer.Port == 0 时跳过启动（适用于仅使用命名监听器的场景）
-func (s *Server) startHTTPServer() error {
+func (s *Server) startHTTPServer(listener net.Listener) error {
 	httpServer := s.httpServer
 	if httpServer == nil {
 		return nil
@@ -453,12 +473,14 @@ func (s *Server) startHTTPServer() error {
 
 	global.LOGGER.InfoKV("Starting HTTP server", "address", address)
 
-	// 从配置中获取网络类型
-	listener, err := net.Listen(s.config.HTTPServer.Network, address)
-	if err != nil {
-		return fmt.Errorf("failed to create %s listener: %w", s.config.HTTPServer.Network, err)
+	if listener == nil {
+		var err error
+		if listener, _, err = s.bindPort("HTTP", s.config.HTTPServer.Network, s.config.HTTPServer.Host, s.config.HTTPServer.Port); err != nil {
+			return err
+		}
 	}
 	defer listener.Close() // Fix 确保 listener 关闭，防止连接泄漏
+	listener = s.wrapListener(listener, "http")
 
 	if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 		return err
@@ -466,25 +488,25 @@ func (s *Server) startHTTPServer() error {
 	return nil
 }
 
-// stopHTTPServer 停止HTTP服务器
+// stopHTTPServer 停止HTTP服务器（使用排空超时）
 func (s *Server) stopHTTPServer() error {
-	if s.httpServer == nil {
-		return nil
-	}
-
-	// 创建30秒超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownConfig.DrainTimeout)
 	defer cancel()
+	return s.shutdownHTTPServer(ctx)
+}
 
-	global.LOGGER.InfoContext(ctx, "Stopping HTTP server...")
-
+// shutdownHTTPServer 关闭HTTP监听并等待在途请求完成，ctx 超时后强制关闭剩余连接
+func (s *Server) shutdownHTTPServer(ctx context.Context) error {
 	httpServer := s.httpServer
 	if httpServer == nil {
 		return nil
 	}
 
+	global.LOGGER.InfoContext(ctx, "Stopping HTTP server...")
+
 	if err := httpServer.Shutdown(ctx); err != nil {
 		global.LOGGER.WithError(err).ErrorContext(ctx, "Failed to shutdown HTTP server")
+		_ = httpServer.Close()
 		return err
 	}
 
@@ -514,6 +536,25 @@ func (s *Server) mysqlHealthCheckHandler(w http.ResponseWriter, r *http.Request)
 	s.componentHealthCheck(w, r, "mysql")
 }
 
+// RegisterHealthChecker 注册组件健康检查器，纳入 /health?detail=true 与 gRPC 健康状态；
+// path 不为空时额外注册该组件的独立检查端点
+func (s *Server) RegisterHealthChecker(checker middleware.HealthChecker, path string) {
+	if s.healthManager == nil {
+		global.LOGGER.WarnKV("健康检查管理器未初始化，忽略组件检查器", "checker", checker.Name())
+		return
+	}
+	s.healthManager.RegisterChecker(checker)
+	if path == "" {
+		return
+	}
+	component := checker.Name()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		s.componentHealthCheck(w, r, component)
+	})
+	s.mu.Unlock()
+}
+
 // componentHealthCheck 组件健康检查通用处理器
 func (s *Server) componentHealthCheck(w http.ResponseWriter, r *http.Request, component string) {
 	w.Header().Set(constants.HeaderContentType, httpx.ContentTypeApplicationJSON)
@@ -568,6 +609,7 @@ func (s *Server) RegisterHTTPRoute(pattern string, handler http.Handler) {
 
 	s.httpMux.Handle(pattern, handler)
 	s.httpRoutePatterns[pattern] = struct{}{}
+	s.registerPatternMethod(pattern)
 	global.LOGGER.InfoKV("✅ 注册HTTP路由成功",
 		"pattern", pattern,
 		"handler_type", fmt.Sprintf("%T", handler))
@@ -590,9 +632,16 @@ func (s *Server) RegisterHTTPHandlerFunc(pattern string, handlerFunc http.Handle
 
 	s.httpMux.HandleFunc(pattern, handlerFunc)
 	s.httpRoutePatterns[pattern] = struct{}{}
+	s.registerPatternMethod(pattern)
 	global.LOGGER.InfoKV("✅ 注册HTTP处理函数成功", "pattern", pattern)
 }
 
+// RegisterContextHandler 注册使用处理器上下文的路由，响应按 Accept 头协商编码
+// opts 为路由级协商配置（可返回的媒体类型、默认类型、严格模式），nil 使用全部已注册编码器
+func (s *Server) RegisterContextHandler(pattern string, fn response.ContextHandlerFunc, opts *response.NegotiationOptions) {
+	s.RegisterHTTPRoute(pattern, response.Handle(fn, opts))
+}
+
 // buildTLSConfig 构建 TLS 配置（从配置文件读取）
 func (s *Server) buildTLSConfig() *tls.Config {
 	if s.config.HTTPServer.TLS == nil {
@@ -645,20 +694,19 @@ func (s *Server) initNamedListeners() error {
 	}
 
 	s.namedListeners = make(map[string]*namedListener, len(s.config.Listeners))
+	tuning := s.resolveHTTPTuning()
 
 	for _, l := range s.config.Listeners {
 		if l == nil || l.Name == "" {
 			continue
 		}
 
-		// 复用主 HTTP 网关的 handler（包含中间件链和 gwMux）
-		var handler http.Handler = s.httpMux
-		if s.middlewareManager != nil {
-			handler = middleware.ApplyMiddlewares(handler, s.middlewareManager.GetMiddlewares()...)
-		}
+		// 复用主 HTTP 网关已编译的中间件链（包含 gwMux）
+		handler := s.inflight.HTTPMiddleware(s.deadlineMiddleware(s.httpChain))
 		if s.config.HTTPServer.EnableGzipCompress {
 			handler = s.gzipMiddleware(handler)
 		}
+		handler = s.slowlorisMiddleware(handler)
 		if s.config.HTTPServer.EnableHTTP2 {
 			h2s := s.buildHTTP2Server()
 			handler = h2c.NewHandler(handler, h2s)
@@ -666,14 +714,12 @@ func (s *Server) initNamedListeners() error {
 
 		addr := fmt.Sprintf("%s:%d", l.Host, l.Port)
 		srv := &http.Server{
-			Addr:              addr,
-			Handler:           handler,
-			ReadTimeout:       time.Duration(s.config.HTTPServer.ReadTimeout) * time.Second,
-			ReadHeaderTimeout: time.Duration(s.config.HTTPServer.ReadHeaderTimeout) * time.Second,
-			WriteTimeout:      time.Duration(s.config.HTTPServer.WriteTimeout) * time.Second,
-			IdleTimeout:       time.Duration(s.config.HTTPServer.IdleTimeout) * time.Second,
-			MaxHeaderBytes:    s.config.HTTPServer.MaxHeaderBytes,
+			Addr:    addr,
+			Handler: handler,
 		}
+		applyHTTPTuning(srv, tuning)
+		srv.ConnState = s.slowlorisConnState(srv)
+		srv.ConnContext = slowlorisConnContext
 
 		s.namedListeners[l.Name] = &namedListener{
 			name:   l.Name,
@@ -687,21 +733,20 @@ func (s *Server) initNamedListeners() error {
 	return nil
 }
 
-// startNamedListeners 启动所有命名监听器
-func (s *Server) startNamedListeners() {
+// startNamedListeners 在 Start 预先绑定的监听器上启动所有命名监听器
+func (s *Server) startNamedListeners(listeners map[string]net.Listener) {
 	for _, nl := range s.namedListeners {
 		nl := nl
+		listener, ok := listeners[nl.name]
+		if !ok {
+			continue
+		}
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
 			addr := nl.server.Addr
-			network := mathx.IfEmpty(nl.config.Network, "tcp4")
-			listener, err := net.Listen(network, addr)
-			if err != nil {
-				global.LOGGER.WithError(err).ErrorKV("命名监听器启动失败", "name", nl.name, "address", addr)
-				return
-			}
 			defer listener.Close()
+			listener = s.wrapListener(listener, nl.name)
 
 			global.LOGGER.InfoKV("命名监听器已启动", "name", nl.name, "address", addr)
 			if err := nl.server.Serve(listener); err != nil && err != http.ErrServerClosed {
@@ -711,16 +756,14 @@ func (s *Server) startNamedListeners() {
 	}
 }
 
-// stopNamedListeners 停止所有命名监听器
-func (s *Server) stopNamedListeners() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+// shutdownNamedListeners 停止所有命名监听器，ctx 超时后强制关闭剩余连接
+func (s *Server) shutdownNamedListeners(ctx context.Context) {
 	for _, nl := range s.namedListeners {
 		if nl.server != nil {
 			global.LOGGER.InfoKV("停止命名监听器", "name", nl.name)
 			if err := nl.server.Shutdown(ctx); err != nil {
 				global.LOGGER.WithError(err).WarnKV("命名监听器关闭失败", "name", nl.name)
+				_ = nl.server.Close()
 			}
 		}
 	}
@@ -0,0 +1,161 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\http_tuning.go
+ * @Description: HTTP Server 调优 - 读写/空闲超时、请求头大小与 keep-alive 开关，
+ *               未配置时使用推荐默认值，危险配置（如未设置 ReadHeaderTimeout）输出告警
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+// 请求头大小推荐范围
+const (
+	minRecommendedHeaderBytes = 4 << 10
+	maxRecommendedHeaderBytes = 8 << 20
+)
+
+// HTTPTuningConfig HTTP Server 调优配置
+// 零值表示未设置（使用 go-config 或推荐默认值），负值表示显式关闭该超时
+type HTTPTuningConfig struct {
+	ReadTimeout       time.Duration // 读取整个请求（含请求体）的超时
+	ReadHeaderTimeout time.Duration // 读取请求头的超时，防御慢速请求头攻击
+	WriteTimeout      time.Duration // 写响应超时（流式/SSE 路由需按需调大或关闭）
+	IdleTimeout       time.Duration // keep-alive 连接空闲超时
+	MaxHeaderBytes    int           // 请求头大小上限（字节）
+	DisableKeepAlives bool          // 关闭 HTTP keep-alive
+}
+
+// DefaultHTTPTuningConfig 推荐的 HTTP Server 调优默认值
+func DefaultHTTPTuningConfig() *HTTPTuningConfig {
+	return &HTTPTuningConfig{
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// SetHTTPTuningConfig 设置 HTTP Server 调优配置，服务未启动时立即应用到主服务器与命名监听器
+func (s *Server) SetHTTPTuningConfig(cfg *HTTPTuningConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.httpTuning = cfg
+	if s.running {
+		global.LOGGER.WarnMsg("HTTP 服务器正在运行，调优配置将在下次重建时生效")
+		return
+	}
+
+	tuning := s.resolveHTTPTuning()
+	warnDangerousHTTPTuning(tuning)
+	s.applyHTTPTuningToServers(tuning)
+}
+
+// applyHTTPTuningToServers 将调优配置应用到主服务器与命名监听器
+func (s *Server) applyHTTPTuningToServers(tuning *HTTPTuningConfig) {
+	if s.httpServer != nil {
+		applyHTTPTuning(s.httpServer, tuning)
+	}
+	for _, nl := range s.namedListeners {
+		if nl.server != nil {
+			applyHTTPTuning(nl.server, tuning)
+		}
+	}
+}
+
+// GetHTTPTuningConfig 获取生效的 HTTP Server 调优配置
+func (s *Server) GetHTTPTuningConfig() HTTPTuningConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return *s.resolveHTTPTuning()
+}
+
+// resolveHTTPTuning 合并推荐默认值、go-config（秒）、调优配置与慢速攻击防护的请求头时限
+func (s *Server) resolveHTTPTuning() *HTTPTuningConfig {
+	resolved := DefaultHTTPTuningConfig()
+
+	if httpCfg := s.config.HTTPServer; httpCfg != nil {
+		overrideDuration(&resolved.ReadTimeout, time.Duration(httpCfg.ReadTimeout)*time.Second)
+		overrideDuration(&resolved.ReadHeaderTimeout, time.Duration(httpCfg.ReadHeaderTimeout)*time.Second)
+		overrideDuration(&resolved.WriteTimeout, time.Duration(httpCfg.WriteTimeout)*time.Second)
+		overrideDuration(&resolved.IdleTimeout, time.Duration(httpCfg.IdleTimeout)*time.Second)
+		if httpCfg.MaxHeaderBytes > 0 {
+			resolved.MaxHeaderBytes = httpCfg.MaxHeaderBytes
+		}
+	}
+
+	if override := s.httpTuning; override != nil {
+		overrideDuration(&resolved.ReadTimeout, override.ReadTimeout)
+		overrideDuration(&resolved.ReadHeaderTimeout, override.ReadHeaderTimeout)
+		overrideDuration(&resolved.WriteTimeout, override.WriteTimeout)
+		overrideDuration(&resolved.IdleTimeout, override.IdleTimeout)
+		if override.MaxHeaderBytes > 0 {
+			resolved.MaxHeaderBytes = override.MaxHeaderBytes
+		}
+		resolved.DisableKeepAlives = override.DisableKeepAlives
+	}
+
+	// 慢速攻击防护的严格请求头时限
+	resolved.ReadHeaderTimeout = s.slowloris.strictHeaderTimeout(resolved.ReadHeaderTimeout)
+
+	return resolved
+}
+
+// overrideDuration 非零时覆盖（负值表示关闭，归一为 0）
+func overrideDuration(dst *time.Duration, value time.Duration) {
+	switch {
+	case value > 0:
+		*dst = value
+	case value < 0:
+		*dst = 0
+	}
+}
+
+// warnDangerousHTTPTuning 输出危险配置告警
+func warnDangerousHTTPTuning(cfg *HTTPTuningConfig) {
+	if cfg.ReadHeaderTimeout == 0 && cfg.ReadTimeout == 0 {
+		global.LOGGER.WarnMsg("⚠️ HTTP ReadHeaderTimeout 与 ReadTimeout 均未设置，易受慢速请求头（Slowloris）攻击")
+	} else if cfg.ReadHeaderTimeout == 0 {
+		global.LOGGER.WarnKV("⚠️ HTTP ReadHeaderTimeout 未设置，读取请求头将使用 ReadTimeout",
+			"read_timeout", cfg.ReadTimeout.String())
+	}
+	if cfg.ReadTimeout > 0 && cfg.ReadHeaderTimeout > cfg.ReadTimeout {
+		global.LOGGER.WarnKV("⚠️ HTTP ReadHeaderTimeout 大于 ReadTimeout，实际以 ReadTimeout 为准",
+			"read_header_timeout", cfg.ReadHeaderTimeout.String(),
+			"read_timeout", cfg.ReadTimeout.String())
+	}
+	if cfg.WriteTimeout == 0 {
+		global.LOGGER.WarnMsg("⚠️ HTTP WriteTimeout 未设置，慢客户端可长期占用连接")
+	}
+	if cfg.IdleTimeout == 0 && cfg.ReadTimeout == 0 && !cfg.DisableKeepAlives {
+		global.LOGGER.WarnMsg("⚠️ HTTP IdleTimeout 与 ReadTimeout 均未设置，空闲 keep-alive 连接不会被回收")
+	}
+	if cfg.MaxHeaderBytes < minRecommendedHeaderBytes || cfg.MaxHeaderBytes > maxRecommendedHeaderBytes {
+		global.LOGGER.WarnKV("⚠️ HTTP MaxHeaderBytes 超出推荐范围",
+			"current", cfg.MaxHeaderBytes,
+			"min_recommended", minRecommendedHeaderBytes,
+			"max_recommended", maxRecommendedHeaderBytes)
+	}
+}
+
+// applyHTTPTuning 将调优配置应用到 http.Server（需在 Serve 之前调用）
+func applyHTTPTuning(srv *http.Server, cfg *HTTPTuningConfig) {
+	srv.ReadTimeout = cfg.ReadTimeout
+	srv.ReadHeaderTimeout = cfg.ReadHeaderTimeout
+	srv.WriteTimeout = cfg.WriteTimeout
+	srv.IdleTimeout = cfg.IdleTimeout
+	srv.MaxHeaderBytes = cfg.MaxHeaderBytes
+	srv.SetKeepAlivesEnabled(!cfg.DisableKeepAlives)
+}
@@ -0,0 +1,87 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\httpbody.go
+ * @Description: google.api.HttpBody 透传 - 按 content_type 原样输出原始字节（文件下载、图片），
+ *               服务端流式 HttpBody 逐块写出并刷新，分块之间不插入换行分隔符
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/protobuf/proto"
+)
+
+// streamDelimiter grpc-gateway 流式响应在每条消息后写入的分隔符
+// （HTTPBodyMarshaler 未实现 runtime.Delimited，使用默认换行）
+var streamDelimiter = []byte("\n")
+
+// withHTTPBody 包装 marshaler：HttpBody 响应使用其 content_type 并原样输出 data，其余消息交给 m
+func withHTTPBody(m runtime.Marshaler) runtime.Marshaler {
+	return &runtime.HTTPBodyMarshaler{Marshaler: m}
+}
+
+// httpBodyWriter 去掉流式 HttpBody 分块后的分隔符，使二进制流按原样透传
+type httpBodyWriter struct {
+	http.ResponseWriter
+	chunk         bool // 下一次写入为 HttpBody 数据
+	skipDelimiter bool // 下一次写入若为分隔符则丢弃
+}
+
+// Write 写入响应，丢弃紧跟在 HttpBody 分块之后的分隔符
+func (w *httpBodyWriter) Write(p []byte) (int, error) {
+	switch {
+	case w.chunk:
+		w.chunk, w.skipDelimiter = false, true
+	case w.skipDelimiter:
+		w.skipDelimiter = false
+		if bytes.Equal(p, streamDelimiter) {
+			return len(p), nil
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap 返回底层的 http.ResponseWriter（grpc-gateway 通过 http.ResponseController 逐块刷新）
+func (w *httpBodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// httpBodyWriterPool httpBodyWriter 对象池
+var httpBodyWriterPool = sync.Pool{
+	New: func() any { return &httpBodyWriter{} },
+}
+
+// httpBodyMiddleware grpc-gateway 中间件：包装 ResponseWriter，配合 httpBodyResponseOption 识别 HttpBody 分块
+// 需位于 grpc-gateway 中间件链最内层，处理器拿到的才是 *httpBodyWriter
+func httpBodyMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		hw := httpBodyWriterPool.Get().(*httpBodyWriter)
+		hw.ResponseWriter = w
+		next(hw, r, params)
+		*hw = httpBodyWriter{}
+		httpBodyWriterPool.Put(hw)
+	}
+}
+
+// httpBodyResponseOption grpc-gateway ForwardResponseOption：在写出 HttpBody 之前打标记
+func httpBodyResponseOption(_ context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if _, ok := resp.(*httpbody.HttpBody); !ok {
+		return nil
+	}
+	if hw, ok := w.(*httpBodyWriter); ok {
+		hw.chunk = true
+	}
+	return nil
+}
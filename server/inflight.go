@@ -0,0 +1,120 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\inflight.go
+ * @Description: 在途请求统计 - 记录正在处理的 HTTP/gRPC 请求数，用于优雅关闭排空观测；
+ *               同时统计处理结束前客户端已断开的请求
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+)
+
+// 在途请求协议标签
+const (
+	InFlightProtocolHTTP = "http"
+	InFlightProtocolGRPC = "grpc"
+)
+
+// inFlightGauge 在途请求数指标（注册到默认 Registry，由 /metrics 暴露）
+var inFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "gateway_inflight_requests",
+	Help: "Number of in-flight requests being processed by the gateway",
+}, []string{"protocol"})
+
+// InFlightStats 在途请求快照
+type InFlightStats struct {
+	HTTP int64 `json:"http"`
+	GRPC int64 `json:"grpc"`
+}
+
+// Total 在途请求总数
+func (st InFlightStats) Total() int64 {
+	return st.HTTP + st.GRPC
+}
+
+// inFlightTracker 在途请求计数器
+type inFlightTracker struct {
+	http atomic.Int64
+	grpc atomic.Int64
+}
+
+// newInFlightTracker 创建在途请求计数器
+func newInFlightTracker() *inFlightTracker {
+	return &inFlightTracker{}
+}
+
+// Stats 返回当前在途请求快照
+func (t *inFlightTracker) Stats() InFlightStats {
+	return InFlightStats{HTTP: t.http.Load(), GRPC: t.grpc.Load()}
+}
+
+// track 计数 +1，返回对应的 -1 函数
+func (t *inFlightTracker) track(counter *atomic.Int64, protocol string) func() {
+	counter.Add(1)
+	gauge := inFlightGauge.WithLabelValues(protocol)
+	gauge.Inc()
+	return func() {
+		counter.Add(-1)
+		gauge.Dec()
+	}
+}
+
+// HTTPMiddleware HTTP 在途请求统计中间件（位于中间件链最外层）
+func (t *inFlightTracker) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := t.track(&t.http, InFlightProtocolHTTP)
+		defer done()
+		next.ServeHTTP(w, r)
+		if middleware.IsClientCanceled(r.Context(), nil) {
+			middleware.RecordClientCanceled(InFlightProtocolHTTP)
+		}
+	})
+}
+
+// UnaryServerInterceptor gRPC Unary 在途请求统计拦截器
+func (t *inFlightTracker) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		done := t.track(&t.grpc, InFlightProtocolGRPC)
+		defer done()
+		resp, err := handler(ctx, req)
+		if err != nil && middleware.IsClientCanceled(ctx, err) {
+			middleware.RecordClientCanceled(InFlightProtocolGRPC)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor gRPC Stream 在途请求统计拦截器
+func (t *inFlightTracker) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := t.track(&t.grpc, InFlightProtocolGRPC)
+		defer done()
+		err := handler(srv, ss)
+		if err != nil && middleware.IsClientCanceled(ss.Context(), err) {
+			middleware.RecordClientCanceled(InFlightProtocolGRPC)
+		}
+		return err
+	}
+}
+
+// InFlightRequests 返回当前在途的 HTTP/gRPC 请求数
+func (s *Server) InFlightRequests() InFlightStats {
+	if s.inflight == nil {
+		return InFlightStats{}
+	}
+	return s.inflight.Stats()
+}
@@ -0,0 +1,144 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ip_ban.go
+ * @Description: 临时 IP 封禁列表 - 在监听器 Accept 阶段直接关闭被封禁 IP 的连接，
+ *               封禁到期后自动解除
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 封禁指标
+var (
+	ipBansCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_ip_bans_total",
+		Help: "Total number of temporary IP bans",
+	}, []string{"reason"})
+
+	bannedConnectionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_banned_connections_total",
+		Help: "Total number of connections closed because the client IP is banned",
+	}, []string{"listener"})
+)
+
+// IPBan 封禁条目
+type IPBan struct {
+	IP     string    `json:"ip"`
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// IPBanList 临时 IP 封禁列表
+type IPBanList struct {
+	mu   sync.RWMutex
+	bans map[string]IPBan
+}
+
+// NewIPBanList 创建临时 IP 封禁列表
+func NewIPBanList() *IPBanList {
+	return &IPBanList{bans: make(map[string]IPBan)}
+}
+
+// Ban 封禁 IP，duration 内该 IP 的新连接将被直接关闭（重复封禁时延长到较晚的到期时间）
+func (l *IPBanList) Ban(ip string, duration time.Duration, reason string) {
+	if ip == "" || duration <= 0 {
+		return
+	}
+	until := time.Now().Add(duration)
+
+	l.mu.Lock()
+	if existing, ok := l.bans[ip]; ok && existing.Until.After(until) {
+		until = existing.Until
+	}
+	l.bans[ip] = IPBan{IP: ip, Reason: reason, Until: until}
+	l.mu.Unlock()
+
+	ipBansCounter.WithLabelValues(reason).Inc()
+	global.LOGGER.WarnKV("IP 已被临时封禁", "ip", ip, "reason", reason, "until", until.Format(time.RFC3339))
+}
+
+// Unban 解除封禁
+func (l *IPBanList) Unban(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.bans, ip)
+}
+
+// IsBanned 检查 IP 是否处于封禁期（过期条目惰性清理）
+func (l *IPBanList) IsBanned(ip string) bool {
+	l.mu.RLock()
+	ban, ok := l.bans[ip]
+	l.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if time.Now().Before(ban.Until) {
+		return true
+	}
+
+	l.mu.Lock()
+	if current, ok := l.bans[ip]; ok && !time.Now().Before(current.Until) {
+		delete(l.bans, ip)
+	}
+	l.mu.Unlock()
+	return false
+}
+
+// List 返回当前有效的封禁条目（按到期时间排序）
+func (l *IPBanList) List() []IPBan {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	bans := make([]IPBan, 0, len(l.bans))
+	for _, ban := range l.bans {
+		if now.Before(ban.Until) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// GetIPBanList 获取服务器的临时 IP 封禁列表，可用于手动封禁/解封
+func (s *Server) GetIPBanList() *IPBanList {
+	return s.ipBans
+}
+
+// banListener 关闭被封禁 IP 连接的监听器
+type banListener struct {
+	net.Listener
+	name string
+	bans *IPBanList
+}
+
+// Accept 接受连接，被封禁 IP 的连接直接关闭并继续等待下一个
+func (l *banListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.bans.IsBanned(remoteIP(conn.RemoteAddr())) {
+			bannedConnectionsCounter.WithLabelValues(l.name).Inc()
+			_ = conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
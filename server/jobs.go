@@ -0,0 +1,80 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\jobs.go
+ * @Description: 异步任务接入 - 注册状态查询路由与任务提交路由，替换配置时旧的任务队列在后台排空
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetJobs 设置异步任务队列，nil 关闭（提交与查询返回 503）；被替换的队列不再接收任务，已排队的任务执行完后退出
+func (s *Server) SetJobs(cfg *middleware.JobsConfig) error {
+	if cfg == nil {
+		if old := s.jobs.Swap(nil); old != nil {
+			go old.Shutdown(context.Background())
+			global.LOGGER.InfoKV("异步任务已关闭")
+		}
+		return nil
+	}
+
+	jobs, err := middleware.NewJobManager(*cfg)
+	if err != nil {
+		return err
+	}
+	if old := s.jobs.Swap(jobs); old != nil {
+		go old.Shutdown(context.Background())
+	}
+
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(jobs.Path(), s.jobStatusHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("异步任务已启用",
+		"path", jobs.Path(),
+		"workers", cfg.Workers,
+		"queue_size", cfg.QueueSize,
+		"result_bucket", cfg.ResultBucket)
+	return nil
+}
+
+// JobHandler 返回异步任务提交处理器：POST 返回 202 与任务 ID，fn 在后台 worker 中执行
+// 处理器在请求时读取当前任务队列，SetJobs 替换配置后无需重新注册
+func (s *Server) JobHandler(fn middleware.JobFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs := s.jobs.Load()
+		if jobs == nil {
+			response.WriteServiceUnavailableResult(w, "async jobs are not configured")
+			return
+		}
+		jobs.Submit(w, r, fn)
+	}
+}
+
+// jobStatusHandler 使用当前生效的任务队列查询状态
+func (s *Server) jobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	jobs := s.jobs.Load()
+	if jobs == nil {
+		response.WriteServiceUnavailableResult(w, "async jobs are not configured")
+		return
+	}
+	jobs.StatusHandler()(w, r)
+}
+
+// stopJobs 停止接收任务并等待执行中的任务，ctx 结束时取消剩余任务
+func (s *Server) stopJobs(ctx context.Context) {
+	if jobs := s.jobs.Swap(nil); jobs != nil {
+		jobs.Shutdown(ctx)
+	}
+}
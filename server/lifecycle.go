@@ -12,8 +12,6 @@
 package server
 
 import (
-	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -35,11 +33,17 @@ func (s *Server) Start() error {
 		return errors.NewError(errors.ErrCodeServiceUnavailable, "server is already running")
 	}
 
+	// 预先绑定全部端口：任一组件绑定失败立即返回并释放已绑定端口，不会出现部分组件已启动的情况
+	listeners, err := s.bindStartupListeners()
+	if err != nil {
+		return err
+	}
+
 	// 启动gRPC服务器
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		if err := s.startGRPCServer(); err != nil {
+		if err := s.startGRPCServer(listeners.grpc); err != nil {
 			logger.WithError(err).ErrorMsg("gRPC server failed")
 		}
 	}()
@@ -51,13 +55,19 @@ func (s *Server) Start() error {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		if err := s.startHTTPServer(); err != nil {
+		if err := s.startHTTPServer(listeners.http); err != nil {
 			logger.WithError(err).ErrorMsg("HTTP server failed")
 		}
 	}()
 
 	// 启动命名监听器（多端口支持）
-	s.startNamedListeners()
+	s.startNamedListeners(listeners.named)
+
+	// 定期同步 HealthChecker 探测结果到 gRPC 健康状态
+	s.runGRPCHealthProbe()
+
+	// 资源超限自监控（堆内存 / goroutine / 文件描述符）
+	s.runWatchdog()
 
 	// 启动 WebSocket 服务（如果已初始化）
 	if s.webSocketService != nil {
@@ -73,7 +83,7 @@ func (s *Server) Start() error {
 		s.wg.Add(1)
 		go func(pprofServer *middleware.PProfServer) {
 			defer s.wg.Done()
-			if err := pprofServer.Start(); err != nil {
+			if err := pprofServer.Serve(listeners.pprof); err != nil {
 				logger.WithError(err).WarnMsg("PProf server failed to start")
 			}
 		}(s.pprofServer)
@@ -81,68 +91,18 @@ func (s *Server) Start() error {
 
 	s.running = true
 
-	// 获取端点信息（配置已通过 safe.MergeWithDefaults 合并默认值）
-	httpHost := s.config.HTTPServer.Host
-	httpPort := s.config.HTTPServer.Port
-	grpcHost := s.config.GRPC.Server.Host
-	grpcPort := s.config.GRPC.Server.Port
-
-	// 使用 Console 展示启动信息
-	cg := logger.NewConsoleGroup()
-	cg.Group("🚀 Gateway 启动成功!")
-
-	// 展示端点信息
-	endpoints := []map[string]any{
-		{
-			"服务类型": "HTTP",
-			"地址":   fmt.Sprintf("%s:%d", httpHost, httpPort),
-			"URL":  fmt.Sprintf("http://%s:%d", httpHost, httpPort),
-		},
-		{
-			"服务类型": "gRPC",
-			"地址":   fmt.Sprintf("%s:%d", grpcHost, grpcPort),
-			"URL":  fmt.Sprintf("grpc://%s:%d", grpcHost, grpcPort),
-		},
-	}
-
-	if s.webSocketService != nil && s.webSocketService.IsRunning() {
-		wsHost := s.webSocketService.GetConfig().NodeIP
-		wsPort := s.webSocketService.GetConfig().NodePort
-		endpoints = append(endpoints, map[string]any{
-			"服务类型": "WebSocket",
-			"地址":   fmt.Sprintf("%s:%d", wsHost, wsPort),
-			"URL":  fmt.Sprintf("ws://%s:%d", wsHost, wsPort),
-		})
+	// 生成启动报告（配置已通过 safe.MergeWithDefaults 合并默认值，端口为实际绑定端口）
+	report := s.buildStartupReport()
+	if s.bannerManager != nil {
+		s.bannerManager.lastReport = report
 	}
-
-	// 添加 PProf 服务器信息（如果启用）
-	if s.config.Middleware != nil && s.config.Middleware.PProf != nil {
-		pprofCfg := s.config.Middleware.PProf
-		if pprofCfg.Enabled && pprofCfg.Port > 0 {
-			endpoints = append(endpoints, map[string]any{
-				"服务类型": "PProf",
-				"地址":   fmt.Sprintf(":%d", pprofCfg.Port),
-				"URL":  fmt.Sprintf("http://localhost:%d%s", pprofCfg.Port, pprofCfg.PathPrefix),
-			})
-		}
-	}
-
-	// 添加命名监听器信息
-	for _, nl := range s.namedListeners {
-		endpoints = append(endpoints, map[string]any{
-			"服务类型": fmt.Sprintf("Listener[%s]", nl.name),
-			"地址":   nl.server.Addr,
-			"URL":  nl.config.GetEndpoint(),
-		})
-	}
-
-	cg.Table(endpoints)
-	cg.GroupEnd()
+	s.printStartupListeners(report)
+	s.emitStartupReport(report)
 
 	return nil
 }
 
-// Stop 停止服务器
+// Stop 停止服务器，按阶段执行：停止接入 → 排空在途请求 → 关闭后台任务 → 关闭基础设施连接
 func (s *Server) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -155,38 +115,7 @@ func (s *Server) Stop() error {
 
 	logger.InfoMsg("Stopping Gateway server...")
 
-	// 取消上下文
-	s.cancel()
-
-	// 停止 WebSocket 服务
-	if s.webSocketService != nil {
-		if err := s.webSocketService.Stop(); err != nil {
-			logger.WithError(err).WarnMsg("Failed to stop WebSocket service")
-		}
-	}
-
-	// 停止HTTP服务器
-	if err := s.stopHTTPServer(); err != nil {
-		logger.WithError(err).ErrorMsg("Failed to stop HTTP server")
-	}
-
-	// 停止命名监听器
-	s.stopNamedListeners()
-
-	// 停止gRPC服务器
-	s.stopGRPCServer()
-
-	if s.pprofServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		if err := s.pprofServer.Shutdown(ctx); err != nil {
-			logger.WithError(err).WarnMsg("Failed to stop PProf server")
-		}
-		cancel()
-		s.pprofServer = nil
-	}
-
-	// 等待所有goroutine结束
-	s.wg.Wait()
+	s.runShutdownPhases()
 
 	s.running = false
 	logger.InfoMsg("Gateway server stopped")
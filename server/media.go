@@ -0,0 +1,56 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\media.go
+ * @Description: 媒体处理路由接入 - 在 HTTP 路由上注册 /media/ 前缀，请求经过完整中间件链（认证、限流等），运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetMedia 设置媒体处理路由，nil 关闭（已注册的路径返回 503）；路径变更时新旧路径都会注册，旧路径返回 404
+func (s *Server) SetMedia(cfg *middleware.MediaConfig) error {
+	if cfg == nil {
+		if s.media.Swap(nil) != nil {
+			global.LOGGER.InfoKV("媒体处理路由已关闭")
+		}
+		return nil
+	}
+
+	media, err := middleware.NewMediaServer(*cfg)
+	if err != nil {
+		return err
+	}
+	s.media.Store(media)
+
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(media.Path(), s.mediaHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("媒体处理路由已启用",
+		"path", media.Path(),
+		"buckets", cfg.Buckets,
+		"cache_dir", cfg.CacheDir,
+		"cache_max_bytes", cfg.CacheMaxBytes)
+	return nil
+}
+
+// mediaHandler 使用当前生效的媒体配置处理请求
+func (s *Server) mediaHandler(w http.ResponseWriter, r *http.Request) {
+	media := s.media.Load()
+	if media == nil {
+		response.WriteServiceUnavailableResult(w, "media route is not configured")
+		return
+	}
+	media.ServeHTTP(w, r)
+}
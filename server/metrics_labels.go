@@ -0,0 +1,48 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\metrics_labels.go
+ * @Description: 指标路径标签接入 - HTTP 多路复用器路由模式识别与模板 / 代理前缀配置
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetMetricsPathLabels 设置指标 path 标签规则：显式模板、代理前缀映射与未匹配路径的基数上限
+// grpc-gateway 路由与通过 RegisterHTTPRoute 注册的路由无需配置，自动以路由模板作为标签
+func (s *Server) SetMetricsPathLabels(cfg middleware.PathLabelConfig) error {
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if err := s.middlewareManager.SetPathLabels(cfg); err != nil {
+		return err
+	}
+	global.LOGGER.InfoKV("指标路径标签规则已更新",
+		"templates", len(cfg.Templates),
+		"prefixes", len(cfg.Prefixes),
+		"max_unknown", cfg.MaxUnknown)
+	return nil
+}
+
+// httpRouteLabel HTTP 多路复用器命中的路由模式；兜底的 "/"（转发给 grpc-gateway）不视为模板
+// ServeMux 自身并发安全，注册新路由无需持有 s.mu
+func (s *Server) httpRouteLabel(r *http.Request) string {
+	if s.httpMux == nil {
+		return ""
+	}
+	_, pattern := s.httpMux.Handler(r)
+	if pattern == "" || pattern == "/" {
+		return ""
+	}
+	return pattern
+}
@@ -83,3 +83,74 @@ func (s *Server) initServers() error {
 
 	return nil
 }
+
+// SetRouteLogConfig 设置按路由日志覆盖规则，AdminPath 非空时注册运行时管理接口
+func (s *Server) SetRouteLogConfig(cfg *middleware.RouteLogConfig) error {
+	if cfg == nil {
+		return middleware.SetRouteLogOverrides(nil)
+	}
+	if err := middleware.SetRouteLogOverrides(cfg.Overrides); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg.AdminPath != "" && s.httpMux != nil {
+		s.RegisterHTTPHandlerFunc(cfg.AdminPath, middleware.RouteLogOverridesHandler())
+		global.LOGGER.InfoKV("路由日志覆盖管理接口已注册", "path", cfg.AdminPath)
+	}
+	return nil
+}
+
+// UseMiddleware 添加自定义 HTTP 中间件（按 priority 与内置中间件统一排序），并重新编译中间件链
+func (s *Server) UseMiddleware(name string, priority int, mw middleware.MiddlewareFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.middlewareManager == nil {
+		return
+	}
+	s.middlewareManager.Use(name, priority, mw)
+	if s.httpChain != nil {
+		s.httpChain.Compile(s.middlewareManager.Chain())
+	}
+}
+
+// SetStageTiming 设置中间件分阶段计时（子 span / span 事件、慢请求分阶段日志），cfg 为空时关闭，并重新编译中间件链
+func (s *Server) SetStageTiming(cfg *middleware.StageTimingConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if err := s.middlewareManager.SetStageTiming(cfg); err != nil {
+		return err
+	}
+	if s.httpChain != nil {
+		s.httpChain.Compile(s.middlewareManager.Chain())
+	}
+	if cfg != nil {
+		global.LOGGER.InfoKV("中间件分阶段计时已启用", "trace", cfg.Trace, "slow_threshold", cfg.SlowThreshold)
+	}
+	return nil
+}
+
+// MiddlewareOrder 返回当前生效的 HTTP 中间件执行顺序
+func (s *Server) MiddlewareOrder() []middleware.ChainEntry {
+	if s.httpChain == nil {
+		return nil
+	}
+	return s.httpChain.Order()
+}
+
+// SetMiddlewareAdminPath 注册中间件顺序查询接口（如 /admin/middleware），用于排查中间件执行顺序
+func (s *Server) SetMiddlewareAdminPath(path string) {
+	if path == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.httpMux != nil {
+		s.RegisterHTTPHandlerFunc(path, middleware.RequireFeature(middleware.FeatureDebugEndpoints, middleware.MiddlewareOrderHandler(s.MiddlewareOrder)))
+		global.LOGGER.InfoKV("中间件顺序查询接口已注册", "path", path)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\multipart.go
+ * @Description: multipart 表单策略接入 - 位于授权之后，未通过认证授权的请求不会触发表单解析，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetMultipart 设置 multipart 表单策略，nil 关闭（之后的表单由处理器自行解析）
+func (s *Server) SetMultipart(cfg *middleware.MultipartConfig) error {
+	if cfg == nil {
+		if s.multipart.Swap(nil) != nil {
+			global.LOGGER.InfoKV("multipart 表单策略已关闭")
+		}
+		return nil
+	}
+
+	mp, err := middleware.NewMultipart(*cfg)
+	if err != nil {
+		return err
+	}
+	s.multipart.Store(mp)
+
+	if !s.multipartRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareMultipart, middleware.PriorityMultipart, s.multipartMiddleware)
+	}
+	global.LOGGER.InfoKV("multipart 表单策略已启用", "rules", len(cfg.Rules))
+	return nil
+}
+
+// multipartMiddleware 按当前策略解析并校验 multipart 表单，未配置时直接放行
+func (s *Server) multipartMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mp := s.multipart.Load()
+		if mp == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mp.Handle(w, r, next)
+	})
+}
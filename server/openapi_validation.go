@@ -0,0 +1,87 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\openapi_validation.go
+ * @Description: OpenAPI 正向校验接入 - 未指定规范文件时从 Swagger 配置加载规范（启用聚合时使用聚合结果），
+ *               校验位于 CORS 之后、签名与认证之前，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-swagger/aggregate"
+	"github.com/kamalyes/go-swagger/loader"
+)
+
+// SetOpenAPIValidation 设置 OpenAPI 正向校验，nil 关闭
+func (s *Server) SetOpenAPIValidation(cfg *middleware.OpenAPIValidationConfig) error {
+	if cfg == nil {
+		s.openAPIValidator.Store(nil)
+		return nil
+	}
+	var specs []map[string]any
+	if len(cfg.SpecFiles) == 0 {
+		spec, err := s.loadOpenAPISpec()
+		if err != nil {
+			return err
+		}
+		specs = append(specs, spec)
+	}
+	validator, err := middleware.NewOpenAPIValidator(*cfg, specs...)
+	if err != nil {
+		return err
+	}
+	s.openAPIValidator.Store(validator)
+	if !s.openAPIValidatorRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareOpenAPI, middleware.PriorityOpenAPI, s.openAPIValidationMiddleware)
+	}
+	global.LOGGER.InfoKV("OpenAPI 正向校验已启用",
+		"mode", validator.Mode(),
+		"operations", validator.Len(),
+		"spec_files", len(cfg.SpecFiles))
+	return nil
+}
+
+// loadOpenAPISpec 按 Swagger 配置加载规范：启用聚合时加载全部服务并聚合，否则读取 spec-path
+func (s *Server) loadOpenAPISpec() (map[string]any, error) {
+	swagger := s.config.Swagger
+	if swagger == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "openapi validation: no spec files and no swagger configuration")
+	}
+	if swagger.IsAggregateEnabled() {
+		aggregator := aggregate.NewAggregator(swagger, global.LOGGER)
+		if err := aggregator.LoadAll(); err != nil {
+			return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "openapi validation: aggregate swagger specs: %v", err)
+		}
+		return aggregator.GetAggregatedSpecMap(), nil
+	}
+	if swagger.SpecPath == "" {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "openapi validation: swagger spec-path is empty")
+	}
+	spec, err := loader.LoadSpecFromPath(swagger.SpecPath)
+	if err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "openapi validation: load %s: %v", swagger.SpecPath, err)
+	}
+	return spec, nil
+}
+
+// openAPIValidationMiddleware OpenAPI 正向校验，未配置时直接放行
+func (s *Server) openAPIValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validator := s.openAPIValidator.Load()
+		if validator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		validator.Handle(w, r, next)
+	})
+}
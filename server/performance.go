@@ -0,0 +1,32 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\performance.go
+ * @Description: 运行时性能查询接口 - 以 JSON 返回 gateway_runtime_* 指标的当前值
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetPerformanceEndpoint 注册运行时性能查询接口，path 为空时使用 /admin/performance；
+// 数据与 /metrics 中的 gateway_runtime_* 指标同源，接口本身需由认证 / 授权中间件保护
+func (s *Server) SetPerformanceEndpoint(path string) {
+	if path == "" {
+		path = middleware.DefaultPerformancePath
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.httpMux != nil {
+		s.RegisterHTTPHandlerFunc(path, middleware.RequireFeature(middleware.FeatureDebugEndpoints, middleware.PerformanceHandler()))
+		global.LOGGER.InfoKV("运行时性能查询接口已注册", "path", path)
+	}
+}
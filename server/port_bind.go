@@ -0,0 +1,170 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\port_bind.go
+ * @Description: 端口预绑定 - Start 时先同步绑定 HTTP/gRPC/命名监听器/PProf 端口，任一失败即返回
+ *               指明组件的错误；开发模式可开启端口回退，被占用时依次尝试后续端口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// DefaultPortFallbackAttempts 端口回退默认尝试的后续端口数
+const DefaultPortFallbackAttempts = 10
+
+// PortFallbackConfig 端口被占用时的回退策略，仅建议在本地开发时启用
+type PortFallbackConfig struct {
+	Enabled  bool // 端口被占用时依次尝试后续端口，实际端口写回配置并打印在启动信息中
+	Attempts int  // 最多尝试的后续端口数，默认 10
+}
+
+// SetPortFallback 设置端口回退策略，需在 Start 之前调用
+func (s *Server) SetPortFallback(cfg PortFallbackConfig) {
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = DefaultPortFallbackAttempts
+	}
+	s.portFallback = cfg
+}
+
+// isAddrInUse 是否为端口被占用错误（Windows 下为 WSAEADDRINUSE，按错误信息识别）
+func isAddrInUse(err error) bool {
+	if stderrors.Is(err, syscall.EADDRINUSE) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "address already in use") || strings.Contains(msg, "Only one usage of each socket address")
+}
+
+// bindPort 绑定端口，返回监听器与实际使用的端口；错误信息包含组件名称与地址
+func (s *Server) bindPort(component, network, host string, port int) (net.Listener, int, error) {
+	network = mathx.IfEmpty(network, "tcp")
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	listener, err := net.Listen(network, addr)
+	if err == nil {
+		return listener, port, nil
+	}
+	if !isAddrInUse(err) {
+		return nil, 0, errors.NewErrorf(errors.ErrCodeServerCreationFailed, "%s server failed to listen on %s: %v", component, addr, err)
+	}
+	if !s.portFallback.Enabled || port == 0 {
+		return nil, 0, errors.NewErrorf(errors.ErrCodeServerCreationFailed,
+			"%s server failed to listen on %s: port %d is already in use (free the port, change it in config, or enable port fallback)",
+			component, addr, port)
+	}
+
+	last := min(port+s.portFallback.Attempts, 65535)
+	for candidate := port + 1; candidate <= last; candidate++ {
+		listener, err = net.Listen(network, net.JoinHostPort(host, strconv.Itoa(candidate)))
+		if err == nil {
+			global.LOGGER.WarnKV("端口被占用，已回退到后续端口",
+				"component", component, "configured", port, "port", candidate)
+			return listener, candidate, nil
+		}
+		if !isAddrInUse(err) {
+			return nil, 0, errors.NewErrorf(errors.ErrCodeServerCreationFailed, "%s server failed to listen on port %d: %v", component, candidate, err)
+		}
+	}
+	return nil, 0, errors.NewErrorf(errors.ErrCodeServerCreationFailed,
+		"%s server failed to listen on %s: ports %d-%d are all in use", component, addr, port, last)
+}
+
+// startupListeners Start 阶段预先绑定的监听器
+type startupListeners struct {
+	grpc  net.Listener
+	http  net.Listener
+	pprof net.Listener
+	named map[string]net.Listener
+}
+
+// close 释放已绑定的监听器（启动失败时调用）
+func (l *startupListeners) close() {
+	for _, listener := range []net.Listener{l.grpc, l.http, l.pprof} {
+		if listener != nil {
+			_ = listener.Close()
+		}
+	}
+	for _, listener := range l.named {
+		_ = listener.Close()
+	}
+}
+
+// bindStartupListeners 按 gRPC → HTTP → 命名监听器 → PProf 顺序绑定端口，
+// 回退后的端口写回配置，使启动信息与 Banner 显示实际端口
+func (s *Server) bindStartupListeners() (*startupListeners, error) {
+	bound := &startupListeners{named: make(map[string]net.Listener, len(s.namedListeners))}
+
+	if grpcCfg := s.config.GRPC.Server; grpcCfg.Enable && s.grpcServer != nil && !(s.inProcessGRPC.Enabled && s.inProcessGRPC.SkipTCP) {
+		listener, port, err := s.bindPort("gRPC", grpcCfg.Network, grpcCfg.Host, grpcCfg.Port)
+		if err != nil {
+			bound.close()
+			return nil, err
+		}
+		grpcCfg.Port = port
+		_ = grpcCfg.AfterLoad()
+		bound.grpc = listener
+	}
+
+	if httpCfg := s.config.HTTPServer; s.httpServer != nil && httpCfg.Port != 0 {
+		listener, port, err := s.bindPort("HTTP", httpCfg.Network, httpCfg.Host, httpCfg.Port)
+		if err != nil {
+			bound.close()
+			return nil, err
+		}
+		httpCfg.Port = port
+		_ = httpCfg.AfterLoad()
+		s.httpServer.Addr = fmt.Sprintf("%s:%d", httpCfg.Host, port)
+		bound.http = listener
+	}
+
+	for name, nl := range s.namedListeners {
+		listener, port, err := s.bindPort(fmt.Sprintf("Listener[%s]", name), mathx.IfEmpty(nl.config.Network, "tcp4"), nl.config.Host, nl.config.Port)
+		if err != nil {
+			bound.close()
+			return nil, err
+		}
+		nl.config.Port = port
+		_ = nl.config.AfterLoad()
+		nl.server.Addr = fmt.Sprintf("%s:%d", nl.config.Host, port)
+		bound.named[name] = listener
+	}
+
+	if s.config.Middleware != nil && s.config.Middleware.PProf != nil && s.config.Middleware.PProf.Enabled {
+		pprofCfg := s.config.Middleware.PProf
+		listener, port, err := s.bindPort("PProf", "tcp", "", mathx.IfNotZero(pprofCfg.Port, 6060))
+		if err != nil {
+			bound.close()
+			return nil, err
+		}
+		pprofCfg.Port = port
+		bound.pprof = listener
+	}
+
+	return bound, nil
+}
+
+// LocalGRPCAddress 本进程 gRPC 服务的拨号地址（端口回退后为实际端口），通配监听地址改为回环地址
+func (s *Server) LocalGRPCAddress() string {
+	grpcCfg := s.GetConfig().GRPC.Server
+	host := grpcCfg.Host
+	switch host {
+	case "", "0.0.0.0", "::", "[::]":
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(grpcCfg.Port))
+}
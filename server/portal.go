@@ -0,0 +1,124 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\portal.go
+ * @Description: 开发者门户接入 - 服务目录来自 Swagger 配置：启用聚合时每个聚合服务为一项，
+ *               否则 spec-path 指向的规范作为唯一服务；Swagger UI 资源沿用 swagger 的 CDN 配置
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-swagger/aggregate"
+)
+
+// SetPortal 设置开发者门户，nil 关闭
+func (s *Server) SetPortal(cfg *middleware.PortalConfig) error {
+	if cfg == nil {
+		if s.portal.Swap(nil) != nil {
+			global.LOGGER.InfoKV("开发者门户已关闭")
+		}
+		return nil
+	}
+
+	portalCfg := *cfg
+	if swagger := s.config.Swagger; swagger != nil {
+		if portalCfg.SwaggerUICSS == "" {
+			portalCfg.SwaggerUICSS = swagger.GetCDNCSSURL()
+		}
+		if portalCfg.SwaggerUIBundleJS == "" {
+			portalCfg.SwaggerUIBundleJS = swagger.GetCDNBundleJS()
+		}
+		if portalCfg.SwaggerUIPresetJS == "" {
+			portalCfg.SwaggerUIPresetJS = swagger.GetCDNPresetJS()
+		}
+	}
+	portal, err := middleware.NewPortal(portalCfg, s.portalCatalog)
+	if err != nil {
+		return err
+	}
+	s.portal.Store(portal)
+
+	path := portal.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.portalHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.portalHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("开发者门户已注册",
+		"path", path,
+		"roles", cfg.Roles,
+		"api_keys", cfg.Keys != nil)
+	return nil
+}
+
+// GetPortal 当前生效的开发者门户，未配置时返回 nil
+func (s *Server) GetPortal() *middleware.Portal {
+	return s.portal.Load()
+}
+
+// portalCatalog 按 Swagger 配置加载服务目录
+func (s *Server) portalCatalog() ([]middleware.PortalService, error) {
+	swagger := s.config.Swagger
+	if swagger == nil {
+		return nil, errors.NewError(errors.ErrCodeInvalidConfiguration, "portal: no swagger configuration")
+	}
+	if !swagger.IsAggregateEnabled() {
+		spec, err := s.loadOpenAPISpec()
+		if err != nil {
+			return nil, err
+		}
+		name := swagger.Title
+		if info, ok := spec["info"].(map[string]any); ok && name == "" {
+			name, _ = info["title"].(string)
+		}
+		if name == "" {
+			name = "api"
+		}
+		return []middleware.PortalService{{Name: name, Description: swagger.Description, Spec: spec}}, nil
+	}
+
+	aggregator := aggregate.NewAggregator(swagger, global.LOGGER)
+	if err := aggregator.LoadAll(); err != nil {
+		return nil, errors.NewErrorf(errors.ErrCodeInvalidConfiguration, "portal: aggregate swagger specs: %v", err)
+	}
+	specs := aggregator.GetServiceSpecs()
+	services := make([]middleware.PortalService, 0, len(specs))
+	for _, svc := range swagger.Aggregate.Services {
+		if svc == nil {
+			continue
+		}
+		spec, ok := specs[svc.Name]
+		if !ok {
+			continue
+		}
+		services = append(services, middleware.PortalService{
+			Name:        svc.Name,
+			Description: svc.Description,
+			Version:     svc.Version,
+			BasePath:    svc.BasePath,
+			Tags:        svc.Tags,
+			Spec:        spec,
+		})
+	}
+	return services, nil
+}
+
+// portalHandler 门户入口，使用当前生效的配置
+func (s *Server) portalHandler(w http.ResponseWriter, r *http.Request) {
+	portal := s.portal.Load()
+	if portal == nil {
+		response.WriteServiceUnavailableResult(w, "developer portal is not configured")
+		return
+	}
+	portal.AdminHandler()(w, r)
+}
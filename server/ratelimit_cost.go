@@ -0,0 +1,39 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ratelimit_cost.go
+ * @Description: 限流请求权重接入 - 权重规则挂在中间件管理器上，HTTP 限流中间件与 gRPC 限流拦截器共用
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetRateLimitCosts 设置限流请求权重，nil 恢复为每个请求消耗 1 个额度
+func (s *Server) SetRateLimitCosts(cfg *middleware.RateLimitCostConfig) error {
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if cfg == nil {
+		s.middlewareManager.SetRateLimitCosts(nil)
+		return nil
+	}
+
+	costs, err := middleware.NewRateLimitCosts(*cfg)
+	if err != nil {
+		return err
+	}
+	s.middlewareManager.SetRateLimitCosts(costs)
+	global.LOGGER.InfoKV("限流请求权重已启用",
+		"routes", len(cfg.Routes),
+		"max_cost", cfg.MaxCost,
+		"dynamic", cfg.CostFunc != nil)
+	return nil
+}
@@ -0,0 +1,40 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ratelimit_key.go
+ * @Description: 限流 key 模板接入 - 模板挂在中间件管理器上，HTTP 限流中间件与 gRPC 限流拦截器按同一规则生成 key
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetRateLimitKeys 设置限流 key 模板，nil 恢复为按 IP / 用户生成 key
+func (s *Server) SetRateLimitKeys(cfg *middleware.RateLimitKeyConfig) error {
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if cfg == nil {
+		s.middlewareManager.SetRateLimitKeys(nil)
+		return nil
+	}
+
+	keys, err := middleware.NewRateLimitKeys(*cfg)
+	if err != nil {
+		return err
+	}
+	s.middlewareManager.SetRateLimitKeys(keys)
+	global.LOGGER.InfoKV("限流 key 模板已启用",
+		"templates", len(cfg.Templates),
+		"routes", len(cfg.Routes),
+		"hash_all", cfg.HashAll,
+		"verified_claims", cfg.ClaimsFunc != nil)
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ratelimit_penalty.go
+ * @Description: 限流升级处罚接入 - 处罚挂在中间件管理器上，HTTP 限流中间件与 gRPC 限流拦截器共用，
+ *               并注册封禁列表与手动解封的管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetRateLimitPenalty 设置限流升级处罚，nil 关闭；封禁记录保存在状态存储中，关闭后已有的封禁不再生效
+func (s *Server) SetRateLimitPenalty(cfg *middleware.RateLimitPenaltyConfig) error {
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if cfg == nil {
+		s.middlewareManager.SetRateLimitPenalty(nil)
+		return nil
+	}
+
+	penalty, err := middleware.NewRateLimitPenalty(*cfg)
+	if err != nil {
+		return err
+	}
+	s.middlewareManager.SetRateLimitPenalty(penalty)
+
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(penalty.AdminPath(), s.rateLimitBansHandler)
+	s.mu.Unlock()
+	if global.STORE == nil {
+		global.LOGGER.WarnKV("状态存储不可用，限流升级处罚不会生效", "admin_path", penalty.AdminPath())
+	}
+	global.LOGGER.InfoKV("限流升级处罚已启用",
+		"threshold", cfg.Threshold,
+		"multiplier", cfg.Multiplier,
+		"max_block", cfg.MaxBlockDuration,
+		"admin_path", penalty.AdminPath())
+	return nil
+}
+
+// rateLimitBansHandler 封禁管理接口，使用当前生效的处罚配置
+func (s *Server) rateLimitBansHandler(w http.ResponseWriter, r *http.Request) {
+	penalty := s.middlewareManager.RateLimitPenalty()
+	if penalty == nil {
+		response.WriteServiceUnavailableResult(w, "rate limit penalty is not configured")
+		return
+	}
+	penalty.AdminHandler()(w, r)
+}
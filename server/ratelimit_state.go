@@ -0,0 +1,57 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\ratelimit_state.go
+ * @Description: 限流状态观测接入 - 跟踪器挂在中间件管理器上，HTTP 限流中间件与 gRPC 限流拦截器共用，
+ *               并注册分页查看限流 key 实时额度的管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetRateLimitState 设置限流状态观测，nil 关闭；重新设置时已跟踪的 key 与计数清空
+func (s *Server) SetRateLimitState(cfg *middleware.RateLimitStateConfig) error {
+	if s.middlewareManager == nil {
+		return nil
+	}
+	if cfg == nil {
+		s.middlewareManager.SetRateLimitStats(nil)
+		return nil
+	}
+
+	stats, err := middleware.NewRateLimitStats(*cfg)
+	if err != nil {
+		return err
+	}
+	s.middlewareManager.SetRateLimitStats(stats)
+
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(stats.AdminPath(), s.rateLimitStateHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("限流状态观测已启用",
+		"max_keys", cfg.MaxKeys,
+		"top_n", cfg.TopN,
+		"admin_path", stats.AdminPath())
+	return nil
+}
+
+// rateLimitStateHandler 限流状态管理接口，使用当前生效的跟踪器
+func (s *Server) rateLimitStateHandler(w http.ResponseWriter, r *http.Request) {
+	stats := s.middlewareManager.RateLimitStats()
+	if stats == nil {
+		response.WriteServiceUnavailableResult(w, "rate limit state is not configured")
+		return
+	}
+	stats.AdminHandler()(w, r)
+}
@@ -28,6 +28,7 @@ func (s *Server) ApplyConfig(cfg *gwconfig.Gateway) {
 	s.config = cfg
 	if s.bannerManager != nil {
 		s.bannerManager = NewBannerManager(cfg).WithContext(s.ctx)
+		s.bannerManager.options = s.bannerOptions
 	}
 }
 
@@ -65,7 +66,7 @@ func (s *Server) ReloadHTTPGateway(cfg *gwconfig.Gateway, replay func() error) e
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			if err := s.startHTTPServer(); err != nil {
+			if err := s.startHTTPServer(nil); err != nil {
 				global.LOGGER.WithError(err).ErrorMsg("HTTP server failed")
 			}
 		}()
@@ -106,7 +107,7 @@ func (s *Server) ReloadGRPCServer(cfg *gwconfig.Gateway, registrars []func(*grpc
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			if err := s.startGRPCServer(); err != nil {
+			if err := s.startGRPCServer(nil); err != nil {
 				global.LOGGER.WithError(err).ErrorMsg("gRPC server failed")
 			}
 		}()
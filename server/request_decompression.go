@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\request_decompression.go
+ * @Description: 请求体解压接入 - 位于签名校验之后，签名仍按客户端发送的压缩字节计算，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetRequestDecompression 设置请求体解压，nil 关闭（之后的 gzip 请求体原样交给处理器）
+func (s *Server) SetRequestDecompression(cfg *middleware.RequestDecompressionConfig) error {
+	if cfg == nil {
+		if s.requestDecompression.Swap(nil) != nil {
+			global.LOGGER.InfoKV("请求体解压已关闭")
+		}
+		return nil
+	}
+
+	decompression, err := middleware.NewRequestDecompression(*cfg)
+	if err != nil {
+		return err
+	}
+	s.requestDecompression.Store(decompression)
+
+	if !s.requestDecompressionRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareDecompression, middleware.PriorityDecompression, s.requestDecompressionMiddleware)
+	}
+	global.LOGGER.InfoKV("请求体解压已启用", "paths", cfg.Paths, "max_bytes", cfg.MaxBytes, "max_ratio", cfg.MaxRatio)
+	return nil
+}
+
+// requestDecompressionMiddleware 按当前配置解压请求体，未配置时直接放行
+func (s *Server) requestDecompressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompression := s.requestDecompression.Load()
+		if decompression == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		decompression.Handle(w, r, next)
+	})
+}
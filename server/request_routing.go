@@ -0,0 +1,68 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\request_routing.go
+ * @Description: 规则路由接入 - 位于授权之后的中间件按规则转发到指定上游，规则可在运行时整体替换
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetRequestRouting 设置规则路由，nil 关闭（之后的请求全部交给默认处理器）
+// 中间件位于 Casbin 授权之后，被转发的请求同样经过认证、授权、限流与访问日志
+func (s *Server) SetRequestRouting(cfg *middleware.RequestRoutingConfig) error {
+	if cfg == nil {
+		s.requestRouter.Store(nil)
+		return nil
+	}
+
+	rt, err := middleware.NewRequestRouter(*cfg)
+	if err != nil {
+		return err
+	}
+	s.requestRouter.Store(rt)
+
+	if !s.requestRouterRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareRouting, middleware.PriorityRouting, s.requestRoutingMiddleware)
+	}
+	if cfg.ExplainPath != "" {
+		s.mu.Lock()
+		s.RegisterHTTPHandlerFunc(cfg.ExplainPath, s.routingExplainHandler)
+		s.mu.Unlock()
+	}
+	global.LOGGER.InfoKV("规则路由已启用", "rules", len(cfg.Rules), "upstreams", len(cfg.Upstreams), "explain_path", cfg.ExplainPath)
+	return nil
+}
+
+// requestRoutingMiddleware 按当前规则转发，未配置时直接放行
+func (s *Server) requestRoutingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := s.requestRouter.Load()
+		if rt == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rt.Handle(w, r, next)
+	})
+}
+
+// routingExplainHandler 解释接口，使用当前生效的规则
+func (s *Server) routingExplainHandler(w http.ResponseWriter, r *http.Request) {
+	rt := s.requestRouter.Load()
+	if rt == nil {
+		response.WriteServiceUnavailableResult(w, "request routing is not configured")
+		return
+	}
+	rt.ExplainHandler()(w, r)
+}
@@ -0,0 +1,54 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\response_buffering.go
+ * @Description: 响应缓冲策略接入 - 位于日志之前的中间件把路由的缓冲策略写入请求上下文，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetResponseBuffering 设置响应缓冲策略，nil 关闭（之后各中间件恢复默认的缓冲行为）
+// 中间件位于请求上下文之后、日志之前，日志捕获与脚本钩子都能读取到策略
+func (s *Server) SetResponseBuffering(cfg *middleware.ResponseBufferingConfig) error {
+	if cfg == nil {
+		if s.responseBuffering.Swap(nil) != nil {
+			global.LOGGER.InfoKV("响应缓冲策略已关闭")
+		}
+		return nil
+	}
+
+	b, err := middleware.NewResponseBuffering(*cfg)
+	if err != nil {
+		return err
+	}
+	s.responseBuffering.Store(b)
+
+	if !s.responseBufferingRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareBuffering, middleware.PriorityBuffering, s.responseBufferingMiddleware)
+	}
+	global.LOGGER.InfoKV("响应缓冲策略已启用", "rules", len(cfg.Rules))
+	return nil
+}
+
+// responseBufferingMiddleware 按当前策略处理，未配置时直接放行
+func (s *Server) responseBufferingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := s.responseBuffering.Load()
+		if b == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		b.Handle(w, r, next)
+	})
+}
@@ -0,0 +1,324 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\route_methods.go
+ * @Description: OPTIONS/HEAD 自动处理 - 根据已登记路由的方法返回 Allow，HEAD 委托给 GET 并丢弃响应体
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+)
+
+// AutoMethodConfig OPTIONS/HEAD 自动处理配置
+type AutoMethodConfig struct {
+	Options bool // 自动响应 OPTIONS 请求，返回 Allow 头
+	Head    bool // 未登记 HEAD 的路由自动委托给 GET，并丢弃响应体
+}
+
+// DefaultAutoMethodConfig 默认 OPTIONS/HEAD 自动处理配置
+func DefaultAutoMethodConfig() *AutoMethodConfig {
+	return &AutoMethodConfig{
+		Options: true,
+		Head:    true,
+	}
+}
+
+// RouteMethodOptions 单个路由的 OPTIONS/HEAD 处理配置
+type RouteMethodOptions struct {
+	DisableAutoOptions bool // 该路由的 OPTIONS 交给业务 handler 处理
+	DisableAutoHead    bool // 该路由的 HEAD 交给业务 handler 处理
+}
+
+// routeTemplate 已登记的路由模板
+type routeTemplate struct {
+	pattern  string
+	segments []string // "*" 匹配单段，"**" 匹配剩余所有段
+	verb     string   // google.api.http 自定义动词，如 :cancel
+	methods  map[string]struct{}
+	options  RouteMethodOptions
+}
+
+// routeMethodTable 路由方法登记表
+type routeMethodTable struct {
+	mu     sync.RWMutex
+	routes map[string]*routeTemplate
+}
+
+// newRouteMethodTable 创建路由方法登记表
+func newRouteMethodTable() *routeMethodTable {
+	return &routeMethodTable{routes: make(map[string]*routeTemplate)}
+}
+
+// add 登记路由方法
+func (t *routeMethodTable) add(method, pattern string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tpl := t.templateLocked(pattern)
+	tpl.methods[strings.ToUpper(method)] = struct{}{}
+}
+
+// setOptions 设置路由的 OPTIONS/HEAD 处理配置
+func (t *routeMethodTable) setOptions(pattern string, opts RouteMethodOptions) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.templateLocked(pattern).options = opts
+}
+
+// templateLocked 获取或创建路由模板（调用方需持有写锁）
+func (t *routeMethodTable) templateLocked(pattern string) *routeTemplate {
+	if tpl, ok := t.routes[pattern]; ok {
+		return tpl
+	}
+	segments, verb := compileRouteTemplate(pattern)
+	tpl := &routeTemplate{
+		pattern:  pattern,
+		segments: segments,
+		verb:     verb,
+		methods:  make(map[string]struct{}),
+	}
+	t.routes[pattern] = tpl
+	return tpl
+}
+
+// lookup 返回匹配路径的所有已登记方法与合并后的路由配置
+// 任一匹配路由关闭了自动处理时，该路径的自动处理关闭
+func (t *routeMethodTable) lookup(path string) (map[string]struct{}, RouteMethodOptions) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var (
+		methods map[string]struct{}
+		opts    RouteMethodOptions
+	)
+	parts := splitPath(path)
+	for _, tpl := range t.routes {
+		if len(tpl.methods) == 0 || !tpl.match(parts) {
+			continue
+		}
+		if methods == nil {
+			methods = make(map[string]struct{})
+		}
+		for m := range tpl.methods {
+			methods[m] = struct{}{}
+		}
+		opts.DisableAutoOptions = opts.DisableAutoOptions || tpl.options.DisableAutoOptions
+		opts.DisableAutoHead = opts.DisableAutoHead || tpl.options.DisableAutoHead
+	}
+	return methods, opts
+}
+
+// match 路径是否匹配模板
+func (tpl *routeTemplate) match(parts []string) bool {
+	if tpl.verb != "" {
+		if len(parts) == 0 || !strings.HasSuffix(parts[len(parts)-1], ":"+tpl.verb) {
+			return false
+		}
+		last := strings.TrimSuffix(parts[len(parts)-1], ":"+tpl.verb)
+		parts = append(append([]string(nil), parts[:len(parts)-1]...), last)
+	}
+
+	for i, seg := range tpl.segments {
+		if seg == "**" {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if seg != "*" && seg != parts[i] {
+			return false
+		}
+	}
+	return len(parts) == len(tpl.segments)
+}
+
+// compileRouteTemplate 解析路由模板，兼容 google.api.http（/v1/{name=projects/*}:cancel）
+// 与 net/http ServeMux（/files/{path...}、/static/）两种写法
+func compileRouteTemplate(pattern string) ([]string, string) {
+	var (
+		b    strings.Builder
+		verb string
+	)
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				b.WriteString(pattern[i:])
+				i = len(pattern)
+				continue
+			}
+			b.WriteString(variableTemplate(pattern[i+1 : i+end]))
+			i += end
+		case c == ':' && !strings.Contains(pattern[i:], "/"):
+			verb = pattern[i+1:]
+			i = len(pattern)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	expanded := b.String()
+	segments := splitPath(expanded)
+	if strings.HasSuffix(expanded, "/") && expanded != "/" {
+		segments = append(segments, "**")
+	}
+	return segments, verb
+}
+
+// variableTemplate 将路径变量转换为匹配模板
+func variableTemplate(variable string) string {
+	if strings.HasSuffix(variable, "...") {
+		return "**"
+	}
+	if idx := strings.IndexByte(variable, '='); idx >= 0 {
+		return variable[idx+1:]
+	}
+	return "*"
+}
+
+// splitPath 按 / 拆分路径，忽略首尾空段
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// splitMethodPattern 拆分 net/http ServeMux 的 "GET /path" 形式路由
+func splitMethodPattern(pattern string) (string, string) {
+	if idx := strings.IndexByte(pattern, ' '); idx > 0 {
+		return strings.ToUpper(pattern[:idx]), strings.TrimSpace(pattern[idx+1:])
+	}
+	return "", pattern
+}
+
+// RegisterRouteMethod 登记路由的 HTTP 方法，用于 OPTIONS 的 Allow 响应与 HEAD 委托
+func (s *Server) RegisterRouteMethod(method, pattern string) {
+	if method == "" || pattern == "" {
+		return
+	}
+	s.routeMethods.add(method, pattern)
+}
+
+// registerPatternMethod 登记带方法前缀的 ServeMux 路由（如 "GET /files/{id}"），无方法前缀的路由由业务自行处理所有方法
+func (s *Server) registerPatternMethod(pattern string) {
+	if method, path := splitMethodPattern(pattern); method != "" {
+		s.RegisterRouteMethod(method, path)
+	}
+}
+
+// SetRouteMethodOptions 设置单个路由的 OPTIONS/HEAD 处理配置
+func (s *Server) SetRouteMethodOptions(pattern string, opts RouteMethodOptions) {
+	s.routeMethods.setOptions(pattern, opts)
+}
+
+// SetAutoMethodConfig 设置 OPTIONS/HEAD 自动处理配置
+func (s *Server) SetAutoMethodConfig(cfg *AutoMethodConfig) {
+	if cfg == nil {
+		cfg = &AutoMethodConfig{}
+	}
+	s.autoMethodConfig.Store(cfg)
+}
+
+// AllowedMethods 返回路径已登记的 HTTP 方法（含自动处理的 OPTIONS/HEAD）
+func (s *Server) AllowedMethods(path string) []string {
+	methods, opts := s.routeMethods.lookup(path)
+	if len(methods) == 0 {
+		return nil
+	}
+	cfg := s.getAutoMethodConfig()
+	allowed := make(map[string]struct{}, len(methods)+2)
+	for m := range methods {
+		allowed[m] = struct{}{}
+	}
+	if _, hasGet := methods[http.MethodGet]; hasGet && cfg.Head && !opts.DisableAutoHead {
+		allowed[http.MethodHead] = struct{}{}
+	}
+	if cfg.Options && !opts.DisableAutoOptions {
+		allowed[http.MethodOptions] = struct{}{}
+	}
+
+	result := make([]string, 0, len(allowed))
+	for m := range allowed {
+		result = append(result, m)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// getAutoMethodConfig 获取 OPTIONS/HEAD 自动处理配置（请求路径上调用，不使用 s.mu 避免关闭期间阻塞）
+func (s *Server) getAutoMethodConfig() *AutoMethodConfig {
+	if cfg := s.autoMethodConfig.Load(); cfg != nil {
+		return cfg
+	}
+	return DefaultAutoMethodConfig()
+}
+
+// autoMethodMiddleware OPTIONS/HEAD 自动处理中间件
+// 只处理已登记方法的路由，且业务方未显式登记 OPTIONS/HEAD 时才接管
+func (s *Server) autoMethodMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := s.getAutoMethodConfig()
+		methods, opts := s.routeMethods.lookup(r.URL.Path)
+		if len(methods) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, explicit := methods[r.Method]; explicit {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodOptions:
+			if !cfg.Options || opts.DisableAutoOptions {
+				break
+			}
+			w.Header().Set(constants.HeaderAllow, strings.Join(s.AllowedMethods(r.URL.Path), ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodHead:
+			if _, hasGet := methods[http.MethodGet]; !hasGet || !cfg.Head || opts.DisableAutoHead {
+				break
+			}
+			getReq := r.WithContext(r.Context())
+			getReq.Method = http.MethodGet
+			next.ServeHTTP(&headResponseWriter{ResponseWriter: w}, getReq)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headResponseWriter 丢弃响应体的 ResponseWriter，用于 HEAD 委托 GET
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write 丢弃响应体
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Unwrap 返回底层的 http.ResponseWriter
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,83 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\schedules.go
+ * @Description: 定时调用接入 - 内部路由通过与监听器相同的处理链（在途统计、截止时间、中间件链）分发，注册管理接口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetSchedules 设置定时调用，nil 关闭；替换配置时旧调度器停止触发，运行中的调用在后台完成，运行历史不保留
+func (s *Server) SetSchedules(cfg *middleware.SchedulesConfig) error {
+	if cfg == nil {
+		if old := s.scheduler.Swap(nil); old != nil {
+			go old.Stop(context.Background())
+			global.LOGGER.InfoKV("定时调用已关闭")
+		}
+		return nil
+	}
+
+	scheduler, err := middleware.NewScheduler(*cfg, http.HandlerFunc(s.internalDispatch))
+	if err != nil {
+		return err
+	}
+	if old := s.scheduler.Swap(scheduler); old != nil {
+		go old.Stop(context.Background())
+	}
+	scheduler.Start()
+
+	path := scheduler.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.scheduleAdminHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.scheduleAdminHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("定时调用已启用",
+		"calls", len(cfg.Calls),
+		"distributed", cfg.Distributed,
+		"admin_path", path)
+	return nil
+}
+
+// GetScheduler 当前生效的定时调度器，未配置时返回 nil
+func (s *Server) GetScheduler() *middleware.Scheduler {
+	return s.scheduler.Load()
+}
+
+// internalDispatch 进程内请求入口，HTTP 服务尚未初始化时返回 503
+func (s *Server) internalDispatch(w http.ResponseWriter, r *http.Request) {
+	if s.httpChain == nil {
+		response.WriteServiceUnavailableResult(w, "http server is not initialized")
+		return
+	}
+	s.inflight.HTTPMiddleware(s.deadlineMiddleware(s.httpChain)).ServeHTTP(w, r)
+}
+
+// scheduleAdminHandler 管理接口，使用当前生效的调度器
+func (s *Server) scheduleAdminHandler(w http.ResponseWriter, r *http.Request) {
+	scheduler := s.scheduler.Load()
+	if scheduler == nil {
+		response.WriteServiceUnavailableResult(w, "schedules are not configured")
+		return
+	}
+	scheduler.AdminHandler()(w, r)
+}
+
+// stopSchedules 停止触发并等待运行中的调用
+func (s *Server) stopSchedules(ctx context.Context) {
+	if scheduler := s.scheduler.Swap(nil); scheduler != nil {
+		scheduler.Stop(ctx)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\schema_registry.go
+ * @Description: JSON Schema 注册表接入 - 请求体校验位于请求体解压与认证之后，未认证的请求拿不到 Schema 细节；
+ *               管理接口与校验中间件都读取当前生效的注册表，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetSchemaRegistry 设置 JSON Schema 注册表，nil 关闭
+func (s *Server) SetSchemaRegistry(cfg *middleware.SchemaRegistryConfig) error {
+	if cfg == nil {
+		if s.schemaRegistry.Swap(nil) != nil {
+			global.LOGGER.InfoKV("JSON Schema 注册表已关闭")
+		}
+		return nil
+	}
+
+	registry, err := middleware.NewSchemaRegistry(*cfg)
+	if err != nil {
+		return err
+	}
+	s.schemaRegistry.Store(registry)
+	if !s.schemaRegistryRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareSchema, middleware.PrioritySchema, s.schemaValidationMiddleware)
+	}
+
+	path := registry.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.schemaRegistryHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.schemaRegistryHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("JSON Schema 注册表已启用",
+		"path", path,
+		"mode", registry.Mode(),
+		"subjects", registry.Len(),
+		"roles", cfg.Roles)
+	return nil
+}
+
+// GetSchemaRegistry 当前生效的 JSON Schema 注册表，未配置时返回 nil
+func (s *Server) GetSchemaRegistry() *middleware.SchemaRegistry {
+	return s.schemaRegistry.Load()
+}
+
+// schemaValidationMiddleware 按已登记的 Schema 校验请求体，未配置时直接放行
+func (s *Server) schemaValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry := s.schemaRegistry.Load()
+		if registry == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		registry.Handle(w, r, next)
+	})
+}
+
+// schemaRegistryHandler 管理接口，使用当前生效的注册表
+func (s *Server) schemaRegistryHandler(w http.ResponseWriter, r *http.Request) {
+	registry := s.schemaRegistry.Load()
+	if registry == nil {
+		response.WriteServiceUnavailableResult(w, "schema registry is not configured")
+		return
+	}
+	registry.AdminHandler()(w, r)
+}
@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\script_hooks.go
+ * @Description: 脚本钩子接入 - 位于 Casbin 授权之后、规则路由之前，脚本改写后的请求参与后续路由匹配
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetScriptHooks 设置脚本钩子，nil 关闭；脚本编译失败时返回错误并保留当前脚本
+func (s *Server) SetScriptHooks(cfg *middleware.ScriptHooksConfig) error {
+	if cfg == nil {
+		if s.scriptHooks.Swap(nil) != nil {
+			global.LOGGER.InfoKV("脚本钩子已关闭")
+		}
+		return nil
+	}
+
+	hooks, err := middleware.NewScriptHooks(*cfg)
+	if err != nil {
+		return err
+	}
+	s.scriptHooks.Store(hooks)
+
+	if !s.scriptHooksRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareScriptHooks, middleware.PriorityScriptHooks, s.scriptHooksMiddleware)
+	}
+	global.LOGGER.InfoKV("脚本钩子已启用", "rules", hooks.Len(), "fail_open", cfg.FailOpen)
+	return nil
+}
+
+// scriptHooksMiddleware 按当前脚本处理，未配置时直接放行
+func (s *Server) scriptHooksMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks := s.scriptHooks.Load()
+		if hooks == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hooks.Handle(w, r, next)
+	})
+}
@@ -0,0 +1,73 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\sdk.go
+ * @Description: 客户端 SDK 下载接入 - 未指定规范文件时按 Swagger 配置加载（启用聚合时为聚合规范），
+ *               每次下载重新加载，使生成的 SDK 始终与当前部署的接口一致
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/response"
+	"github.com/kamalyes/go-rpc-gateway/sdkgen"
+)
+
+// SetSDKGenerator 设置客户端 SDK 下载接口，nil 关闭
+func (s *Server) SetSDKGenerator(cfg *sdkgen.HandlerConfig) error {
+	if cfg == nil {
+		if s.sdkGenerator.Swap(nil) != nil {
+			global.LOGGER.InfoKV("客户端 SDK 下载接口已关闭")
+		}
+		return nil
+	}
+
+	handler, err := sdkgen.NewHandler(*cfg, s.loadSDKSpecs)
+	if err != nil {
+		return err
+	}
+	s.sdkGenerator.Store(handler)
+
+	path := handler.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.sdkGeneratorHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.sdkGeneratorHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("客户端 SDK 下载接口已注册",
+		"path", path,
+		"package", cfg.Package,
+		"spec_files", len(cfg.SpecFiles),
+		"template_dir", cfg.TemplateDir)
+	return nil
+}
+
+// GetSDKGenerator 当前生效的 SDK 下载接口，未配置时返回 nil
+func (s *Server) GetSDKGenerator() *sdkgen.Handler {
+	return s.sdkGenerator.Load()
+}
+
+// loadSDKSpecs 按 Swagger 配置加载最新规范
+func (s *Server) loadSDKSpecs() ([]map[string]any, error) {
+	spec, err := s.loadOpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]any{spec}, nil
+}
+
+// sdkGeneratorHandler 管理接口，使用当前生效的配置
+func (s *Server) sdkGeneratorHandler(w http.ResponseWriter, r *http.Request) {
+	handler := s.sdkGenerator.Load()
+	if handler == nil {
+		response.WriteServiceUnavailableResult(w, "sdk endpoint is not configured")
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
@@ -15,15 +15,20 @@ import (
 	"context"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	gwconfig "github.com/kamalyes/go-config/pkg/gateway"
+	"github.com/kamalyes/go-rpc-gateway/collection"
+	"github.com/kamalyes/go-rpc-gateway/confighistory"
 	"github.com/kamalyes/go-rpc-gateway/cpool"
 	"github.com/kamalyes/go-rpc-gateway/errors"
 	"github.com/kamalyes/go-rpc-gateway/global"
 	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/sdkgen"
 	"github.com/kamalyes/go-toolbox/pkg/desensitize"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 // Server Gateway服务器
@@ -37,11 +42,15 @@ type Server struct {
 	pprofServer *middleware.PProfServer
 	httpMux     *http.ServeMux // 添加HTTP路由管理器
 
+	// 创建 gwMux 使用的选项（蓝绿切换的上游组复用相同的序列化与中间件）
+	gwMuxOptions []runtime.ServeMuxOption
+
 	// 命名监听器（多端口支持，如 Ops/Tenant 分离）
 	namedListeners map[string]*namedListener
 
 	// 中间件管理器
 	middlewareManager *middleware.Manager
+	httpChain         *middleware.CompiledChain // 预编译的 HTTP 中间件链
 
 	// grpc-gateway 中间件（runtime.Middleware）
 	grpcGatewayMiddlewares         []runtime.Middleware
@@ -50,8 +59,24 @@ type Server struct {
 	// 健康检查管理器
 	healthManager *middleware.HealthManager
 
-	// Banner管理器
+	// gRPC 标准健康检查服务
+	grpcHealth *grpcHealthState
+
+	// gRPC / HTTP Server 调优配置
+	grpcTuning *GRPCTuningConfig
+	httpTuning *HTTPTuningConfig
+
+	// 连接级限制与连接表
+	connLimit *ConnLimitConfig
+	connTable *connTable
+
+	// 慢速攻击防护与临时 IP 封禁列表
+	slowloris *slowlorisGuard
+	ipBans    *IPBanList
+
+	// Banner管理器与启动横幅/启动报告选项
 	bannerManager *BannerManager
+	bannerOptions BannerOptions
 
 	// 连接池管理器
 	poolManager cpool.PoolManager
@@ -66,13 +91,155 @@ type Server struct {
 	gzipWriterPool *sync.Pool
 
 	// Gzip 跳过路径和扩展名的快速查找表（预处理，避免每次请求遍历切片）
-	gzipSkipPathsMap      map[string]bool
+	gzipSkipPaths         *middleware.RouteTable
 	gzipSkipExtensionsMap map[string]bool
 	httpRoutePatterns     map[string]struct{}
 
 	// 数据脱敏器（用于日志敏感数据脱敏）
 	dataMasker *desensitize.DataMasker
 
+	// 在途请求统计与分阶段优雅关闭
+	inflight       *inFlightTracker
+	shutdownConfig *ShutdownConfig
+	shutdownHooks  map[ShutdownPhase][]shutdownHook
+
+	// OPTIONS/HEAD 自动处理
+	routeMethods     *routeMethodTable
+	autoMethodConfig atomic.Pointer[AutoMethodConfig]
+
+	// 自定义 404/405 处理器
+	errorHandlers errorHandlers
+
+	// grpc-gateway 请求头/元数据/查询参数映射规则
+	gatewayHeaders atomic.Pointer[gatewayHeaderRules]
+
+	// 服务端流式 RPC 输出规则
+	gatewayStream atomic.Pointer[gatewayStreamRules]
+
+	// 进程内 gRPC 通道
+	inProcessGRPC     InProcessGRPCConfig
+	inProcessListener atomic.Pointer[bufconn.Listener]
+
+	// 端口被占用时的回退策略
+	portFallback PortFallbackConfig
+
+	// 响应附带构建信息（HTTP 响应头 / gRPC 响应元数据）
+	buildInfoHeaders atomic.Bool
+
+	// 自监控看门狗
+	watchdog *WatchdogConfig
+
+	// 请求截止时间规则
+	deadline atomic.Pointer[deadlineRules]
+
+	// 请求头 / metadata 条数与大小限制
+	headerLimiter           atomic.Pointer[middleware.HeaderLimiter]
+	headerLimiterRegistered atomic.Bool
+
+	// OpenAPI 正向校验
+	openAPIValidator           atomic.Pointer[middleware.OpenAPIValidator]
+	openAPIValidatorRegistered atomic.Bool
+
+	// 请求体解压
+	requestDecompression           atomic.Pointer[middleware.RequestDecompression]
+	requestDecompressionRegistered atomic.Bool
+
+	// 统一认证（产生 Principal，位于外部授权与 Casbin 之前）
+	authn           atomic.Pointer[middleware.Authentication]
+	authnRegistered atomic.Bool
+
+	// JSON Schema 注册表（请求体校验）
+	schemaRegistry           atomic.Pointer[middleware.SchemaRegistry]
+	schemaRegistryRegistered atomic.Bool
+
+	// SSE / WebSocket 流式连接限制
+	streamLimiter           atomic.Pointer[middleware.StreamLimiter]
+	streamLimiterRegistered atomic.Bool
+
+	// 外部授权（HTTP 中间件只注册一次，之后按当前配置判定）
+	extAuthz           atomic.Pointer[middleware.ExtAuthz]
+	extAuthzRegistered atomic.Bool
+
+	// Casbin 授权
+	casbin           atomic.Pointer[middleware.Casbin]
+	casbinRegistered atomic.Bool
+
+	// multipart 表单策略
+	multipart           atomic.Pointer[middleware.Multipart]
+	multipartRegistered atomic.Bool
+
+	// 媒体处理路由
+	media atomic.Pointer[middleware.MediaServer]
+
+	// 异步任务队列
+	jobs atomic.Pointer[middleware.JobManager]
+
+	// 出站 Webhook 投递
+	webhooks atomic.Pointer[middleware.WebhookManager]
+
+	// 广播推送
+	broadcaster atomic.Pointer[middleware.Broadcaster]
+
+	// 定时调用
+	scheduler atomic.Pointer[middleware.Scheduler]
+
+	// 协程泄漏检测
+	leakDetector atomic.Pointer[middleware.LeakDetector]
+
+	// 服务描述符查询
+	descriptors atomic.Pointer[middleware.Descriptors]
+
+	// 客户端 SDK 下载
+	sdkGenerator atomic.Pointer[sdkgen.Handler]
+
+	// 开发者门户
+	portal atomic.Pointer[middleware.Portal]
+
+	// Postman / Insomnia 接口集合下载
+	collections atomic.Pointer[collection.Handler]
+
+	// 配置版本历史
+	configHistory atomic.Pointer[confighistory.History]
+
+	// 签名 URL
+	urlSigner           atomic.Pointer[middleware.URLSigner]
+	urlSignerRegistered atomic.Bool
+
+	// 蓝绿切换
+	cutover atomic.Pointer[middleware.Cutover]
+
+	// 优先级并发限制
+	priorityLimiter           atomic.Pointer[middleware.PriorityLimiter]
+	priorityLimiterRegistered atomic.Bool
+
+	// CDN 缓存指令
+	cacheDirectives           atomic.Pointer[middleware.CacheDirectives]
+	cacheDirectivesRegistered atomic.Bool
+
+	// 脚本钩子
+	scriptHooks           atomic.Pointer[middleware.ScriptHooks]
+	scriptHooksRegistered atomic.Bool
+
+	// 规则路由
+	requestRouter           atomic.Pointer[middleware.RequestRouter]
+	requestRouterRegistered atomic.Bool
+
+	// 声明式路由
+	declarativeRouter           atomic.Pointer[middleware.DeclarativeRouter]
+	declarativeRouterRegistered atomic.Bool
+
+	// 响应缓冲策略
+	responseBuffering           atomic.Pointer[middleware.ResponseBuffering]
+	responseBufferingRegistered atomic.Bool
+
+	// 降级响应
+	fallbacks           atomic.Pointer[middleware.Fallbacks]
+	fallbacksRegistered atomic.Bool
+
+	// 会话保持
+	stickySessions           atomic.Pointer[middleware.StickySessions]
+	stickySessionsRegistered atomic.Bool
+
 	// 状态管理
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -88,6 +255,14 @@ func (s *Server) GetGatewayMux() *runtime.ServeMux {
 	return s.gwMux
 }
 
+// NewGatewayMux 以 gwMux 相同的选项创建独立的 Gateway Mux（用于蓝绿切换的上游组）
+func (s *Server) NewGatewayMux() *runtime.ServeMux {
+	if s.gwMuxOptions == nil {
+		return runtime.NewServeMux(s.buildServeMuxOptions()...)
+	}
+	return runtime.NewServeMux(s.gwMuxOptions...)
+}
+
 // NewServer 创建新的Gateway服务器 - 使用全局 GATEWAY 配置
 func NewServer() (*Server, error) {
 	cfg := global.GATEWAY
@@ -106,10 +281,17 @@ func NewServer() (*Server, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	server := &Server{
-		config:        cfg,
-		ctx:           ctx,
-		cancel:        cancel,
-		bannerManager: NewBannerManager(cfg).WithContext(ctx),
+		config:         cfg,
+		ctx:            ctx,
+		cancel:         cancel,
+		bannerManager:  NewBannerManager(cfg).WithContext(ctx),
+		inflight:       newInFlightTracker(),
+		shutdownConfig: DefaultShutdownConfig(),
+		routeMethods:   newRouteMethodTable(),
+		grpcHealth:     newGRPCHealthState(),
+		connTable:      newConnTable(),
+		slowloris:      newSlowlorisGuard(),
+		ipBans:         NewIPBanList(),
 	}
 
 	// 初始化 Gzip writer 对象池（从配置读取压缩级别）
@@ -0,0 +1,272 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\shutdown.go
+ * @Description: 分阶段优雅关闭 - 停止接入 → 排空在途请求 → 关闭后台任务 → 关闭基础设施连接
+ *               每个阶段独立超时，并输出耗时日志与指标，便于调优关闭窗口
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ShutdownPhase 关闭阶段
+type ShutdownPhase string
+
+// 关闭阶段（按执行顺序）
+const (
+	PhaseStopAccepting ShutdownPhase = "stop_accepting" // 停止接入：gRPC 健康状态置为 NOT_SERVING、摘除流量、关闭 keep-alive、停止 WebSocket、关闭流式连接
+	PhaseDrain         ShutdownPhase = "drain"          // 排空：等待在途 HTTP/gRPC 请求完成，超时后强制关闭
+	PhaseBackground    ShutdownPhase = "background"     // 后台任务：选主任务、定时任务、PProf 等
+	PhaseInfra         ShutdownPhase = "infra"          // 基础设施：配置监听、连接池、状态存储等
+)
+
+// stopAcceptingTimeout 停止接入阶段（不含 PreStopDelay）的超时时间
+const stopAcceptingTimeout = 10 * time.Second
+
+// shutdownPhases 阶段执行顺序
+var shutdownPhases = []ShutdownPhase{PhaseStopAccepting, PhaseDrain, PhaseBackground, PhaseInfra}
+
+// shutdownPhaseDuration 各阶段耗时指标
+var shutdownPhaseDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "gateway_shutdown_phase_duration_seconds",
+	Help: "Duration of the last gateway shutdown phase in seconds",
+}, []string{"phase"})
+
+// ShutdownConfig 优雅关闭配置
+type ShutdownConfig struct {
+	PreStopDelay      time.Duration // 停止接入前的等待时间（留给负载均衡摘除实例）
+	DrainTimeout      time.Duration // 排空在途请求的超时时间
+	BackgroundTimeout time.Duration // 关闭后台任务的超时时间
+	InfraTimeout      time.Duration // 关闭基础设施连接的超时时间
+}
+
+// DefaultShutdownConfig 默认优雅关闭配置
+func DefaultShutdownConfig() *ShutdownConfig {
+	return &ShutdownConfig{
+		PreStopDelay:      0,
+		DrainTimeout:      30 * time.Second,
+		BackgroundTimeout: 10 * time.Second,
+		InfraTimeout:      10 * time.Second,
+	}
+}
+
+// ShutdownHook 关闭阶段钩子，ctx 在阶段超时后取消
+type ShutdownHook func(ctx context.Context) error
+
+// shutdownHook 已注册的关闭钩子
+type shutdownHook struct {
+	name string
+	fn   ShutdownHook
+}
+
+// SetShutdownConfig 设置优雅关闭配置，未设置的超时使用默认值
+func (s *Server) SetShutdownConfig(cfg *ShutdownConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownConfig = normalizeShutdownConfig(cfg)
+}
+
+// GetShutdownConfig 获取优雅关闭配置
+func (s *Server) GetShutdownConfig() ShutdownConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return *s.shutdownConfig
+}
+
+// OnShutdown 注册关闭阶段钩子，同一阶段内按注册顺序执行（在内置动作之后）
+func (s *Server) OnShutdown(phase ShutdownPhase, name string, fn ShutdownHook) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdownHooks == nil {
+		s.shutdownHooks = make(map[ShutdownPhase][]shutdownHook)
+	}
+	s.shutdownHooks[phase] = append(s.shutdownHooks[phase], shutdownHook{name: name, fn: fn})
+}
+
+// normalizeShutdownConfig 填充未设置的超时
+func normalizeShutdownConfig(cfg *ShutdownConfig) *ShutdownConfig {
+	defaults := DefaultShutdownConfig()
+	if cfg == nil {
+		return defaults
+	}
+	config := *cfg
+	if config.PreStopDelay < 0 {
+		config.PreStopDelay = 0
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = defaults.DrainTimeout
+	}
+	if config.BackgroundTimeout <= 0 {
+		config.BackgroundTimeout = defaults.BackgroundTimeout
+	}
+	if config.InfraTimeout <= 0 {
+		config.InfraTimeout = defaults.InfraTimeout
+	}
+	return &config
+}
+
+// phaseTimeout 返回阶段超时时间
+func (c *ShutdownConfig) phaseTimeout(phase ShutdownPhase) time.Duration {
+	switch phase {
+	case PhaseDrain:
+		return c.DrainTimeout
+	case PhaseBackground:
+		return c.BackgroundTimeout
+	case PhaseInfra:
+		return c.InfraTimeout
+	default:
+		return c.PreStopDelay + stopAcceptingTimeout
+	}
+}
+
+// runShutdownPhases 依次执行所有关闭阶段（调用方需持有 s.mu）
+func (s *Server) runShutdownPhases() {
+	total := time.Now()
+	for _, phase := range shutdownPhases {
+		s.runShutdownPhase(phase)
+	}
+	global.LOGGER.InfoKV("优雅关闭完成", "duration", time.Since(total).String())
+}
+
+// runShutdownPhase 执行单个关闭阶段：内置动作 + 用户钩子，并记录耗时
+func (s *Server) runShutdownPhase(phase ShutdownPhase) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownConfig.phaseTimeout(phase))
+	defer cancel()
+
+	start := time.Now()
+	inflight := s.InFlightRequests()
+	global.LOGGER.InfoKV("关闭阶段开始", "phase", phase,
+		"inflight_http", inflight.HTTP, "inflight_grpc", inflight.GRPC)
+
+	switch phase {
+	case PhaseStopAccepting:
+		s.stopAccepting(ctx)
+	case PhaseDrain:
+		s.drain(ctx)
+	case PhaseBackground:
+		s.stopBackground(ctx)
+	}
+
+	for _, hook := range s.shutdownHooks[phase] {
+		if err := hook.fn(ctx); err != nil {
+			global.LOGGER.WarnKV("关闭钩子执行失败", "phase", phase, "hook", hook.name, "error", err)
+		}
+	}
+
+	elapsed := time.Since(start)
+	shutdownPhaseDuration.WithLabelValues(string(phase)).Set(elapsed.Seconds())
+	inflight = s.InFlightRequests()
+	global.LOGGER.InfoKV("关闭阶段完成", "phase", phase, "duration", elapsed.String(),
+		"timed_out", ctx.Err() == context.DeadlineExceeded,
+		"inflight_http", inflight.HTTP, "inflight_grpc", inflight.GRPC)
+}
+
+// stopAccepting 停止接入新流量
+func (s *Server) stopAccepting(ctx context.Context) {
+	// 先切换 gRPC 健康状态，让负载均衡在等待期间摘除实例
+	s.shutdownGRPCHealth()
+
+	if delay := s.shutdownConfig.PreStopDelay; delay > 0 {
+		global.LOGGER.InfoKV("等待负载均衡摘除实例", "delay", delay.String())
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	// 取消服务器上下文，通知依赖它的组件开始退出
+	s.cancel()
+
+	// 关闭 keep-alive，促使客户端在当前请求结束后断开
+	if s.httpServer != nil {
+		s.httpServer.SetKeepAlivesEnabled(false)
+	}
+	for _, nl := range s.namedListeners {
+		if nl.server != nil {
+			nl.server.SetKeepAlivesEnabled(false)
+		}
+	}
+
+	// 停止 WebSocket 服务
+	if s.webSocketService != nil {
+		if err := s.webSocketService.Stop(); err != nil {
+			global.LOGGER.WithError(err).WarnMsg("Failed to stop WebSocket service")
+		}
+	}
+
+	// 向仍在线的 SSE / WebSocket 连接发送关闭消息，避免长连接拖满排空超时
+	s.drainStreams()
+
+	// 断开广播订阅连接（订阅连接可能未经流式连接限制跟踪）
+	s.closeBroadcast()
+}
+
+// drain 关闭监听并等待在途请求完成，超时后强制关闭
+func (s *Server) drain(ctx context.Context) {
+	done := make(chan struct{}, 3)
+	go func() {
+		if err := s.shutdownHTTPServer(ctx); err != nil {
+			global.LOGGER.WithError(err).ErrorMsg("Failed to stop HTTP server")
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		s.shutdownNamedListeners(ctx)
+		done <- struct{}{}
+	}()
+	go func() {
+		s.shutdownGRPCServer(ctx)
+		done <- struct{}{}
+	}()
+	for i := 0; i < cap(done); i++ {
+		<-done
+	}
+}
+
+// stopBackground 停止内置后台组件并等待服务 goroutine 退出
+func (s *Server) stopBackground(ctx context.Context) {
+	if s.pprofServer != nil {
+		if err := s.pprofServer.Shutdown(ctx); err != nil {
+			global.LOGGER.WithError(err).WarnMsg("Failed to stop PProf server")
+		}
+		s.pprofServer = nil
+	}
+
+	// 定时调用会向中间件链发起请求，最先停止
+	s.stopSchedules(ctx)
+
+	// 异步任务需要状态存储与 MinIO，先于全局资源释放前排空
+	s.stopJobs(ctx)
+	s.stopWebhooks(ctx)
+	s.stopLeakDetector(ctx)
+
+	// 请求已排空，刷新并关闭访问日志 sink
+	middleware.CloseAccessLogSinks()
+
+	waitDone := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(waitDone)
+	}()
+	select {
+	case <-waitDone:
+	case <-ctx.Done():
+		global.LOGGER.WarnMsg("等待服务 goroutine 退出超时")
+	}
+}
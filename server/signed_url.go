@@ -0,0 +1,70 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\signed_url.go
+ * @Description: 签名 URL 接入 - 校验中间件与签发接口，运行时可替换密钥或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetSignedURL 设置签名 URL，nil 关闭（之后携带签名参数的请求按普通请求处理）
+// 校验位于 CORS 之后、请求签名之前，通过的请求跳过请求签名、外部授权与 Casbin 授权
+func (s *Server) SetSignedURL(cfg *middleware.SignedURLConfig) error {
+	if cfg == nil {
+		s.urlSigner.Store(nil)
+		middleware.SetCurrentURLSigner(nil)
+		return nil
+	}
+
+	signer, err := middleware.NewURLSigner(*cfg)
+	if err != nil {
+		return err
+	}
+	s.urlSigner.Store(signer)
+	middleware.SetCurrentURLSigner(signer)
+
+	if !s.urlSignerRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareSignedURL, middleware.PrioritySignedURL, s.signedURLMiddleware)
+	}
+	if cfg.MintPath != "" {
+		s.mu.Lock()
+		s.RegisterHTTPHandlerFunc(cfg.MintPath, s.signedURLMintHandler)
+		s.mu.Unlock()
+	}
+	global.LOGGER.InfoKV("签名 URL 已启用", "routes", cfg.Routes, "max_ttl", cfg.MaxTTL, "mint_path", cfg.MintPath)
+	return nil
+}
+
+// signedURLMiddleware 签名 URL 校验，未配置时直接放行
+func (s *Server) signedURLMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signer := s.urlSigner.Load()
+		if signer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		signer.Handle(w, r, next)
+	})
+}
+
+// signedURLMintHandler 签发接口，使用当前生效的密钥
+func (s *Server) signedURLMintHandler(w http.ResponseWriter, r *http.Request) {
+	signer := s.urlSigner.Load()
+	if signer == nil {
+		response.WriteServiceUnavailableResult(w, "signed url is not configured")
+		return
+	}
+	signer.MintHandler()(w, r)
+}
@@ -0,0 +1,295 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\slowloris.go
+ * @Description: 慢速攻击防护 - 严格的请求头读取时限、请求体最低传输速率，
+ *               窗口内多次违规的 IP 自动加入临时封禁列表
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/constants"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 慢速攻击违规类型
+const (
+	slowViolationHeader = "slow_header"
+	slowViolationBody   = "slow_body"
+)
+
+// bodyRateSlack 请求体速率检查的额外容忍时间
+const bodyRateSlack = time.Second
+
+// slowClientViolationsCounter 慢速客户端违规指标
+var slowClientViolationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_slow_client_violations_total",
+	Help: "Total number of slow header/body violations",
+}, []string{"type"})
+
+// errSlowRequestBody 请求体传输速率低于下限
+var errSlowRequestBody = stderrors.New("request body transfer rate below minimum")
+
+// SlowlorisConfig 慢速攻击防护配置
+type SlowlorisConfig struct {
+	HeaderTimeout time.Duration // 严格请求头读取时限，生效的 ReadHeaderTimeout 取其与调优配置中的较小值
+	MinBodyRate   int64         // 请求体最低传输速率（字节/秒），<=0 不检查
+	BodyRateGrace time.Duration // 请求体速率检查的起始宽限期
+	BanThreshold  int           // BanWindow 内违规次数达到阈值后封禁，<=0 不封禁
+	BanWindow     time.Duration // 违规计数窗口
+	BanDuration   time.Duration // 封禁时长
+}
+
+// DefaultSlowlorisConfig 默认慢速攻击防护配置
+func DefaultSlowlorisConfig() *SlowlorisConfig {
+	return &SlowlorisConfig{
+		HeaderTimeout: 5 * time.Second,
+		MinBodyRate:   1 << 10,
+		BodyRateGrace: 5 * time.Second,
+		BanThreshold:  3,
+		BanWindow:     time.Minute,
+		BanDuration:   10 * time.Minute,
+	}
+}
+
+// slowViolation 单个 IP 的违规计数
+type slowViolation struct {
+	count int
+	since time.Time
+}
+
+// slowlorisGuard 慢速攻击防护状态
+type slowlorisGuard struct {
+	config     atomic.Pointer[SlowlorisConfig]
+	pending    sync.Map // net.Conn → 建立时间，尚未读完首个请求头的连接
+	mu         sync.Mutex
+	violations map[string]*slowViolation
+}
+
+// newSlowlorisGuard 创建慢速攻击防护状态（未设置配置时不生效）
+func newSlowlorisGuard() *slowlorisGuard {
+	return &slowlorisGuard{violations: make(map[string]*slowViolation)}
+}
+
+// SetSlowlorisConfig 设置慢速攻击防护配置，nil 关闭防护
+// 请求头时限在服务未运行时立即应用；请求体速率与封禁策略随时生效
+func (s *Server) SetSlowlorisConfig(cfg *SlowlorisConfig) {
+	s.slowloris.config.Store(cfg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		global.LOGGER.WarnMsg("HTTP 服务器正在运行，请求头读取时限将在下次重建时生效")
+		return
+	}
+	s.applyHTTPTuningToServers(s.resolveHTTPTuning())
+}
+
+// strictHeaderTimeout 合并慢速攻击防护的请求头时限
+func (g *slowlorisGuard) strictHeaderTimeout(current time.Duration) time.Duration {
+	cfg := g.config.Load()
+	if cfg == nil || cfg.HeaderTimeout <= 0 {
+		return current
+	}
+	if current <= 0 || cfg.HeaderTimeout < current {
+		return cfg.HeaderTimeout
+	}
+	return current
+}
+
+// slowConnKey 请求上下文中底层连接的 key
+type slowConnKey struct{}
+
+// slowlorisConnContext 将底层连接写入请求上下文，供中间件标记首个请求头已读完
+func slowlorisConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, slowConnKey{}, conn)
+}
+
+// slowlorisConnState 跟踪新连接，首个请求头未读完即因超时关闭的连接记为慢速请求头违规
+// （StateActive 在读到首字节时即触发，无法区分请求头是否完整，因此由中间件在进入处理器时摘除）
+func (s *Server) slowlorisConnState(srv *http.Server) func(net.Conn, http.ConnState) {
+	guard := s.slowloris
+	return func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			if guard.config.Load() != nil {
+				guard.pending.Store(conn, time.Now())
+			}
+		case http.StateHijacked:
+			guard.pending.Delete(conn)
+		case http.StateClosed:
+			value, ok := guard.pending.LoadAndDelete(conn)
+			if !ok {
+				return
+			}
+			// ReadHeaderTimeout 为 0 时 net/http 使用 ReadTimeout 读取请求头
+			timeout := srv.ReadHeaderTimeout
+			if timeout <= 0 {
+				timeout = srv.ReadTimeout
+			}
+			if timeout > 0 && time.Since(value.(time.Time)) >= timeout {
+				s.recordSlowViolation(remoteIP(conn.RemoteAddr()), slowViolationHeader)
+			}
+		}
+	}
+}
+
+// recordSlowViolation 记录违规，窗口内达到阈值时封禁 IP
+func (s *Server) recordSlowViolation(ip, kind string) {
+	slowClientViolationsCounter.WithLabelValues(kind).Inc()
+	global.LOGGER.DebugKV("检测到慢速客户端", "ip", ip, "type", kind)
+
+	cfg := s.slowloris.config.Load()
+	if cfg == nil || cfg.BanThreshold <= 0 || ip == "" {
+		return
+	}
+
+	guard := s.slowloris
+	now := time.Now()
+	guard.mu.Lock()
+	for key, v := range guard.violations {
+		if now.Sub(v.since) > cfg.BanWindow {
+			delete(guard.violations, key)
+		}
+	}
+	v, ok := guard.violations[ip]
+	if !ok {
+		v = &slowViolation{since: now}
+		guard.violations[ip] = v
+	}
+	v.count++
+	reached := v.count >= cfg.BanThreshold
+	if reached {
+		delete(guard.violations, ip)
+	}
+	guard.mu.Unlock()
+
+	if reached {
+		s.ipBans.Ban(ip, cfg.BanDuration, kind)
+	}
+}
+
+// slowlorisMiddleware 标记首个请求头已读完，并为请求体施加最低传输速率限制
+func (s *Server) slowlorisMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, ok := r.Context().Value(slowConnKey{}).(net.Conn); ok {
+			s.slowloris.pending.Delete(conn)
+		}
+
+		cfg := s.slowloris.config.Load()
+		if cfg == nil || cfg.MinBodyRate <= 0 || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body := &minRateBody{
+			ReadCloser: r.Body,
+			rc:         http.NewResponseController(w),
+			cfg:        cfg,
+			deadlines:  true,
+		}
+		body.onViolation = func() {
+			w.Header().Set(constants.HeaderConnection, "close")
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			s.recordSlowViolation(host, slowViolationBody)
+		}
+		r.Body = body
+		next.ServeHTTP(w, r)
+		body.resetDeadline()
+	})
+}
+
+// minRateBody 按最低速率限制读取的请求体，只统计阻塞在 Read 上的时间，处理器自身的慢速消费不计入
+type minRateBody struct {
+	io.ReadCloser
+	rc          *http.ResponseController
+	cfg         *SlowlorisConfig
+	read        int64
+	waited      time.Duration
+	deadlines   bool // 底层连接是否支持设置读超时
+	violated    bool
+	onViolation func()
+}
+
+// allowance 按已读字节计算允许的累计等待时间
+func (b *minRateBody) allowance() time.Duration {
+	return b.cfg.BodyRateGrace + time.Duration(float64(b.read)/float64(b.cfg.MinBodyRate)*float64(time.Second)) + bodyRateSlack
+}
+
+// Read 读取请求体，累计等待时间超出允许值时返回错误
+func (b *minRateBody) Read(p []byte) (int, error) {
+	if b.violated {
+		return 0, errSlowRequestBody
+	}
+
+	remaining := b.allowance() - b.waited
+	if remaining <= 0 {
+		return 0, b.violate()
+	}
+	if b.deadlines {
+		if err := b.rc.SetReadDeadline(time.Now().Add(remaining)); err != nil {
+			b.deadlines = false
+		}
+	}
+
+	start := time.Now()
+	n, err := b.ReadCloser.Read(p)
+	b.waited += time.Since(start)
+	b.read += int64(n)
+
+	if err != nil {
+		if stderrors.Is(err, os.ErrDeadlineExceeded) {
+			return n, b.violate()
+		}
+		if err == io.EOF {
+			b.resetDeadline()
+		}
+		return n, err
+	}
+	if b.waited > b.allowance() {
+		return n, b.violate()
+	}
+	return n, nil
+}
+
+// Close 关闭请求体并清除读超时
+func (b *minRateBody) Close() error {
+	b.resetDeadline()
+	return b.ReadCloser.Close()
+}
+
+// violate 标记违规（只记录一次）
+func (b *minRateBody) violate() error {
+	if !b.violated {
+		b.violated = true
+		b.onViolation()
+	}
+	return errSlowRequestBody
+}
+
+// resetDeadline 清除读超时，避免影响请求体读完后的连接后台读取
+func (b *minRateBody) resetDeadline() {
+	if b.deadlines && !b.violated {
+		_ = b.rc.SetReadDeadline(time.Time{})
+	}
+}
@@ -259,10 +259,15 @@ func (b *BannerManager) buildStartupReport() startupReport {
 }
 
 func (b *BannerManager) printStartupTimestamp(report startupReport) {
-	global.LOGGER.InfoContext(b.ctx, "🕐 服务启动时间: %s", report.startedAt)
+	b.info("🕐 服务启动时间: %s", report.startedAt)
 }
 
 func (b *BannerManager) printStartupStatus(report startupReport) {
+	if b.options.Plain {
+		b.printPlainStartupStatus(report)
+		return
+	}
+
 	cg := global.LOGGER.NewConsoleGroup()
 	cg.Group("🚀 Gateway 服务启动状态检查")
 
@@ -340,12 +345,33 @@ func (b *BannerManager) printStartupStatus(report startupReport) {
 	cg.GroupEnd()
 }
 
+// printPlainStartupStatus 纯文本模式的启动状态检查：每项一行，不使用控制台表格
+func (b *BannerManager) printPlainStartupStatus(report startupReport) {
+	b.info("Gateway 服务启动状态检查: environment=%s debug=%v", report.environment, report.debug)
+	for _, service := range report.services {
+		b.info("   service %s %s:%d %s", service.name, service.host, service.port, b.getStatusIcon(service.enabled))
+	}
+	for _, module := range report.modules {
+		b.info("   module %s %s %s", module.name, b.getStatusIcon(module.enabled), module.path)
+	}
+	for _, item := range report.middleware {
+		b.info("   middleware %s %s", item.name, b.getStatusIcon(item.enabled))
+	}
+	for _, item := range report.monitoring {
+		b.info("   monitoring %s %s %s", item.name, b.getStatusIcon(item.enabled), item.detail)
+	}
+	b.info("启动状态检查完成: %d/%d 个功能已启用 (%s)", report.summary.enabledCount, report.summary.totalCount, report.summary.rate())
+}
+
 func (b *BannerManager) printStartupSummary(report startupReport) {
-	global.LOGGER.InfoContext(b.ctx, "📋 功能启用摘要: %d/%d 个功能已启用 (%s)",
+	b.info("📋 功能启用摘要: %d/%d 个功能已启用 (%s)",
 		report.summary.enabledCount, report.summary.totalCount, report.summary.rate())
 }
 
 func (b *BannerManager) getStatusIcon(enabled bool) string {
+	if b.options.Plain {
+		return mathx.IF(enabled, "已启用", "已禁用")
+	}
 	if enabled {
 		return "✅ 已启用"
 	}
@@ -0,0 +1,265 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\startup_report.go
+ * @Description: 启动报告 - 启动成功后生成监听地址、启用功能、路由数量与配置校验和的 JSON 报告，
+ *               供部署工具读取；横幅支持自定义模板与纯文本（无 emoji / 表格）输出
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-toolbox/pkg/mathx"
+)
+
+// StartupReportStdout ReportFile 取该值时启动报告写到标准输出
+const StartupReportStdout = "-"
+
+// BannerOptions 启动横幅与启动报告选项
+type BannerOptions struct {
+	Template   string // 横幅模板，覆盖配置 Banner.Template；支持 text/template，数据为 StartupReport，如 {{.Name}} v{{.Version}}
+	Plain      bool   // 纯文本输出：去掉 emoji，启动状态与监听地址逐行输出而不是控制台表格，便于日志采集解析
+	ReportFile string // 启动成功后写出 JSON 启动报告的文件路径，"-" 写到标准输出，为空不写
+	LogReport  bool   // 启动成功后以单行 JSON 记录启动报告（日志字段 startup_report）
+}
+
+// StartupListener 启动报告中的监听地址
+type StartupListener struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	URL      string `json:"url,omitempty"`
+}
+
+// StartupBuildInfo 启动报告中的构建信息
+type StartupBuildInfo struct {
+	Time      string `json:"time,omitempty"`
+	User      string `json:"user,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+	GitCommit string `json:"git_commit,omitempty"`
+	GitBranch string `json:"git_branch,omitempty"`
+	GitTag    string `json:"git_tag,omitempty"`
+}
+
+// StartupReport 机器可读的启动报告
+type StartupReport struct {
+	Name           string            `json:"name"`
+	Version        string            `json:"version"`
+	Environment    string            `json:"environment"`
+	Debug          bool              `json:"debug"`
+	StartedAt      time.Time         `json:"started_at"`
+	PID            int               `json:"pid"`
+	GoVersion      string            `json:"go_version"`
+	OSArch         string            `json:"os_arch"`
+	Build          StartupBuildInfo  `json:"build"`
+	Listeners      []StartupListener `json:"listeners"`
+	Features       []string          `json:"features"`
+	Middleware     []string          `json:"middleware"`
+	RouteCount     int               `json:"route_count"`    // HTTPRoutes + GatewayRoutes
+	HTTPRoutes     int               `json:"http_routes"`    // 通过 RegisterHTTPRoute 注册的路由（含健康检查等内置路由）
+	GatewayRoutes  int               `json:"gateway_routes"` // 端点收集器中的 gRPC-Gateway 路由
+	ConfigChecksum string            `json:"config_checksum"`
+}
+
+// SetBannerOptions 设置启动横幅与启动报告选项，需在 Start 之前调用
+func (s *Server) SetBannerOptions(opts BannerOptions) {
+	s.bannerOptions = opts
+	if s.bannerManager != nil {
+		s.bannerManager.options = opts
+	}
+}
+
+// StartupReport 返回最近一次启动成功时生成的启动报告，未启动时返回 nil
+func (s *Server) StartupReport() *StartupReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.bannerManager == nil {
+		return nil
+	}
+	return s.bannerManager.lastReport
+}
+
+// WriteStartupReport 以缩进 JSON 写出启动报告
+func (s *Server) WriteStartupReport(w io.Writer) error {
+	report := s.StartupReport()
+	if report == nil {
+		return errors.NewError(errors.ErrCodeServiceUnavailable, "startup report is not available before the server starts")
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// buildStartupReport 生成启动报告（调用方持有 s.mu）
+func (s *Server) buildStartupReport() *StartupReport {
+	cfg := s.config
+	report := &StartupReport{
+		Name:        mathx.IfEmpty(cfg.Banner.Title, cfg.Name),
+		Version:     cfg.Version,
+		Environment: cfg.Environment,
+		Debug:       cfg.Debug,
+		StartedAt:   time.Now(),
+		PID:         os.Getpid(),
+		GoVersion:   runtime.Version(),
+		OSArch:      runtime.GOOS + "/" + runtime.GOARCH,
+		Build: StartupBuildInfo{
+			Time:      cfg.BuildTime,
+			User:      cfg.BuildUser,
+			GoVersion: cfg.GoVersion,
+			GitCommit: cfg.GitCommit,
+			GitBranch: cfg.GitBranch,
+			GitTag:    cfg.GitTag,
+		},
+		Listeners:      s.startupListeners(),
+		HTTPRoutes:     len(s.httpRoutePatterns),
+		ConfigChecksum: configChecksum(cfg),
+	}
+	if s.endpointCollector != nil {
+		report.GatewayRoutes = len(s.endpointCollector.GetAllEndpoints())
+	}
+	report.RouteCount = report.HTTPRoutes + report.GatewayRoutes
+
+	if s.bannerManager != nil {
+		details := s.bannerManager.buildStartupReport()
+		for _, item := range details.features {
+			if item.enabled {
+				report.Features = append(report.Features, mathx.IF(item.name == "custom_feature", item.label, item.name))
+			}
+		}
+		for _, item := range details.middleware {
+			if item.enabled {
+				report.Middleware = append(report.Middleware, item.name)
+			}
+		}
+	}
+	return report
+}
+
+// startupListeners 收集实际监听地址（端口回退后为实际端口）
+func (s *Server) startupListeners() []StartupListener {
+	httpHost, httpPort := s.config.HTTPServer.Host, s.config.HTTPServer.Port
+	listeners := []StartupListener{{
+		Name:     "HTTP",
+		Protocol: "http",
+		Address:  fmt.Sprintf("%s:%d", httpHost, httpPort),
+		URL:      fmt.Sprintf("http://%s:%d", httpHost, httpPort),
+	}}
+
+	if grpcCfg := s.config.GRPC.Server; grpcCfg.Enable && !(s.inProcessGRPC.Enabled && s.inProcessGRPC.SkipTCP) {
+		listeners = append(listeners, StartupListener{
+			Name:     "gRPC",
+			Protocol: "grpc",
+			Address:  fmt.Sprintf("%s:%d", grpcCfg.Host, grpcCfg.Port),
+			URL:      fmt.Sprintf("grpc://%s:%d", grpcCfg.Host, grpcCfg.Port),
+		})
+	}
+	if s.inProcessGRPC.Enabled {
+		listeners = append(listeners, StartupListener{Name: "gRPC(in-process)", Protocol: "grpc", Address: InProcessTarget})
+	}
+
+	if s.webSocketService != nil && s.webSocketService.IsRunning() {
+		wsHost := s.webSocketService.GetConfig().NodeIP
+		wsPort := s.webSocketService.GetConfig().NodePort
+		listeners = append(listeners, StartupListener{
+			Name:     "WebSocket",
+			Protocol: "ws",
+			Address:  fmt.Sprintf("%s:%d", wsHost, wsPort),
+			URL:      fmt.Sprintf("ws://%s:%d", wsHost, wsPort),
+		})
+	}
+
+	if s.config.Middleware != nil && s.config.Middleware.PProf != nil {
+		if pprofCfg := s.config.Middleware.PProf; pprofCfg.Enabled && pprofCfg.Port > 0 {
+			listeners = append(listeners, StartupListener{
+				Name:     "PProf",
+				Protocol: "http",
+				Address:  fmt.Sprintf(":%d", pprofCfg.Port),
+				URL:      fmt.Sprintf("http://localhost:%d%s", pprofCfg.Port, pprofCfg.PathPrefix),
+			})
+		}
+	}
+
+	for _, nl := range s.namedListeners {
+		listeners = append(listeners, StartupListener{
+			Name:     fmt.Sprintf("Listener[%s]", nl.name),
+			Protocol: "http",
+			Address:  nl.server.Addr,
+			URL:      nl.config.GetEndpoint(),
+		})
+	}
+	return listeners
+}
+
+// configChecksum 配置内容的 sha256，用于部署工具比对实例加载的配置是否一致
+func configChecksum(cfg any) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// printStartupListeners 输出监听地址：默认控制台表格，纯文本模式逐行输出
+func (s *Server) printStartupListeners(report *StartupReport) {
+	if s.bannerOptions.Plain {
+		global.LOGGER.InfoContext(s.ctx, "Gateway 启动成功")
+		for _, l := range report.Listeners {
+			global.LOGGER.InfoKV("listener", "name", l.Name, "protocol", l.Protocol, "address", l.Address, "url", l.URL)
+		}
+		return
+	}
+
+	endpoints := make([]map[string]any, 0, len(report.Listeners))
+	for _, l := range report.Listeners {
+		endpoints = append(endpoints, map[string]any{
+			"服务类型": l.Name,
+			"地址":   l.Address,
+			"URL":  l.URL,
+		})
+	}
+	cg := global.LOGGER.NewConsoleGroup()
+	cg.Group("🚀 Gateway 启动成功!")
+	cg.Table(endpoints)
+	cg.GroupEnd()
+}
+
+// emitStartupReport 按选项写出启动报告；写文件失败只记录警告，不影响启动
+func (s *Server) emitStartupReport(report *StartupReport) {
+	if s.bannerOptions.LogReport {
+		if data, err := json.Marshal(report); err == nil {
+			global.LOGGER.InfoKV("startup report", "startup_report", string(data))
+		}
+	}
+
+	switch path := s.bannerOptions.ReportFile; path {
+	case "":
+	case StartupReportStdout:
+		if data, err := json.MarshalIndent(report, "", "  "); err == nil {
+			fmt.Fprintln(os.Stdout, string(data))
+		}
+	default:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(path, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			global.LOGGER.WarnKV("写出启动报告失败", "path", path, "error", err)
+		}
+	}
+}
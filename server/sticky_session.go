@@ -0,0 +1,53 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\sticky_session.go
+ * @Description: 会话保持接入 - 位于路由之后、gwMux 之前的中间件读取与写回亲和 Cookie，运行时可替换或关闭
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetStickySession 设置会话保持，nil 关闭（之后的请求按 sticky_round_robin 的普通轮询分配，不再写回 Cookie）
+func (s *Server) SetStickySession(cfg *middleware.StickySessionConfig) error {
+	if cfg == nil {
+		if s.stickySessions.Swap(nil) != nil {
+			global.LOGGER.InfoKV("会话保持已关闭")
+		}
+		return nil
+	}
+
+	sessions, err := middleware.NewStickySessions(*cfg)
+	if err != nil {
+		return err
+	}
+	s.stickySessions.Store(sessions)
+
+	if !s.stickySessionsRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareStickySession, middleware.PriorityStickySession, s.stickySessionMiddleware)
+	}
+	global.LOGGER.InfoKV("会话保持已启用", "cookie", cfg.Cookie, "hash_cookie", cfg.HashCookie, "max_age", cfg.MaxAge)
+	return nil
+}
+
+// stickySessionMiddleware 按当前配置处理亲和 Cookie，未配置时直接放行
+func (s *Server) stickySessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessions := s.stickySessions.Load()
+		if sessions == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sessions.Handle(w, r, next)
+	})
+}
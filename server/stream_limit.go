@@ -0,0 +1,71 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\stream_limit.go
+ * @Description: SSE / WebSocket 流式连接限制接入 - 位于认证之后以便按用户计数，
+ *               替换配置时保留已建立连接的计数，停止接入阶段向在线连接发送关闭消息
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SetStreamLimit 设置流式连接限制，nil 关闭；关闭后已建立的连接不再跟踪，也不会在停机时收到关闭消息
+func (s *Server) SetStreamLimit(cfg *middleware.StreamLimitConfig) error {
+	if cfg == nil {
+		s.streamLimiter.Store(nil)
+		return nil
+	}
+	if limiter := s.streamLimiter.Load(); limiter != nil {
+		if err := limiter.Update(*cfg); err != nil {
+			return err
+		}
+	} else {
+		limiter, err := middleware.NewStreamLimiter(*cfg)
+		if err != nil {
+			return err
+		}
+		s.streamLimiter.Store(limiter)
+	}
+	if !s.streamLimiterRegistered.Swap(true) {
+		s.UseMiddleware(middleware.MiddlewareStreamLimit, middleware.PriorityStreamLimit, s.streamLimitMiddleware)
+	}
+	global.LOGGER.InfoKV("流式连接限制已启用",
+		"max_per_user", cfg.MaxPerUser,
+		"max_per_ip", cfg.MaxPerIP,
+		"idle_timeout", cfg.IdleTimeout.String(),
+		"ping_interval", cfg.PingInterval.String())
+	return nil
+}
+
+// streamLimitMiddleware 流式连接限制，未配置时直接放行
+func (s *Server) streamLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := s.streamLimiter.Load()
+		if limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limiter.Handle(w, r, next)
+	})
+}
+
+// drainStreams 拒绝新的流式连接并关闭在线连接
+func (s *Server) drainStreams() {
+	limiter := s.streamLimiter.Load()
+	if limiter == nil {
+		return
+	}
+	if closed := limiter.Drain(); closed > 0 {
+		global.LOGGER.InfoKV("已关闭流式连接", "connections", closed)
+	}
+}
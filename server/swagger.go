@@ -44,9 +44,9 @@ func (s *Server) EnableSwagger() error {
 	// 从 middleware manager 获取 Swagger 处理器
 	swaggerHandler := s.middlewareManager.SwaggerHandler()
 	
-	// 注册 Swagger 路由
+	// 注册 Swagger 路由（启用接口集合下载时服务列表页带上下载入口）
 	for _, path := range s.middlewareManager.GetSwaggerPaths() {
-		s.RegisterHTTPRoute(path, swaggerHandler)
+		s.RegisterHTTPRoute(path, s.withCollectionLinks(swaggerHandler))
 	}
 
 	global.LOGGER.InfoContext(s.ctx, "✅ Swagger 文档服务已启用: ui_path=%s, json_path=%s, title=%s",
@@ -0,0 +1,266 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\watchdog.go
+ * @Description: 自监控看门狗 - 定期检查堆内存、goroutine 与文件描述符数量，超过阈值时自动保存
+ *               heap/goroutine profile（本地目录 + 可选对象存储），通知回调，并可在持续超限时受控重启
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/cpool/oss"
+	"github.com/kamalyes/go-rpc-gateway/errors"
+	"github.com/kamalyes/go-rpc-gateway/global"
+)
+
+const (
+	DefaultWatchdogInterval           = 10 * time.Second // 默认检查间隔
+	DefaultWatchdogCooldown           = 5 * time.Minute  // 同一指标两次抓取 profile 的最小间隔
+	DefaultWatchdogRestartConsecutive = 3                // 默认连续超限次数达到后重启
+	DefaultWatchdogRestartExitCode    = 3                // 受控重启的进程退出码
+)
+
+// 看门狗监控指标
+const (
+	WatchdogMetricHeap       = "heap"
+	WatchdogMetricGoroutines = "goroutines"
+	WatchdogMetricOpenFiles  = "open_files"
+)
+
+// WatchdogConfig 自监控看门狗配置，阈值为 0 表示不检查该项
+type WatchdogConfig struct {
+	Interval      time.Duration // 检查间隔，默认 10s
+	MaxHeapBytes  uint64        // 堆内存（HeapAlloc）上限
+	MaxGoroutines int           // goroutine 数量上限
+	MaxOpenFiles  int           // 打开的文件描述符上限（读取 /proc/self/fd，非 Linux 平台忽略）
+
+	Dir      string        // profile 保存目录，默认 <TempDir>/gateway-watchdog
+	Cooldown time.Duration // 同一指标两次抓取 profile 的最小间隔，默认 5m，避免持续超限时写满磁盘
+
+	Storage oss.StorageHandler // profile 上传的对象存储，为空且设置了 Bucket 时使用连接池中的 MinIO/S3
+	Bucket  string             // 上传的存储桶，为空不上传
+	Prefix  string             // 对象键前缀，默认 watchdog/
+
+	OnBreach func(WatchdogEvent) // 超限事件回调（告警、上报等），在看门狗协程中同步执行
+
+	RestartOnBreach    bool // 连续超限达到 RestartConsecutive 次后优雅关闭并以 RestartExitCode 退出，由进程管理器拉起
+	RestartConsecutive int  // 触发重启的连续超限次数，默认 3
+	RestartExitCode    int  // 受控重启的退出码，默认 3
+}
+
+// WatchdogEvent 超限事件
+type WatchdogEvent struct {
+	Metric      string    // 超限指标：heap / goroutines / open_files
+	Value       uint64    // 当前值
+	Threshold   uint64    // 阈值
+	Consecutive int       // 连续超限次数
+	Time        time.Time // 检测时间
+	Profiles    []string  // 本次保存的本地 profile 文件（冷却期内为空）
+	Uploaded    []string  // 已上传的对象键
+	Restarting  bool      // 是否即将受控重启
+}
+
+// watchdogState 看门狗运行状态，仅在看门狗协程内访问
+type watchdogState struct {
+	lastCapture map[string]time.Time
+	consecutive int
+}
+
+// SetWatchdog 设置自监控看门狗，需在 Start 之前调用，nil 关闭
+func (s *Server) SetWatchdog(cfg *WatchdogConfig) error {
+	if cfg == nil {
+		s.watchdog = nil
+		return nil
+	}
+	if cfg.Interval < 0 || cfg.Cooldown < 0 || cfg.MaxGoroutines < 0 || cfg.MaxOpenFiles < 0 || cfg.RestartConsecutive < 0 {
+		return errors.NewErrorf(errors.ErrCodeInvalidParameter, "watchdog interval, cooldown, thresholds and restart count must not be negative")
+	}
+
+	c := *cfg
+	if c.Interval == 0 {
+		c.Interval = DefaultWatchdogInterval
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = DefaultWatchdogCooldown
+	}
+	if c.Dir == "" {
+		c.Dir = filepath.Join(os.TempDir(), "gateway-watchdog")
+	}
+	if c.Prefix == "" {
+		c.Prefix = "watchdog/"
+	}
+	if c.RestartConsecutive == 0 {
+		c.RestartConsecutive = DefaultWatchdogRestartConsecutive
+	}
+	if c.RestartExitCode == 0 {
+		c.RestartExitCode = DefaultWatchdogRestartExitCode
+	}
+	s.watchdog = &c
+	return nil
+}
+
+// runWatchdog 启动看门狗协程，随服务停止退出
+func (s *Server) runWatchdog() {
+	cfg := s.watchdog
+	if cfg == nil {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		state := &watchdogState{lastCapture: make(map[string]time.Time)}
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if s.checkWatchdog(cfg, state) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// checkWatchdog 执行一次检查，返回 true 表示已触发受控重启
+func (s *Server) checkWatchdog(cfg *WatchdogConfig, state *watchdogState) bool {
+	var breaches []WatchdogEvent
+	now := time.Now()
+
+	if cfg.MaxHeapBytes > 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if ms.HeapAlloc > cfg.MaxHeapBytes {
+			breaches = append(breaches, WatchdogEvent{Metric: WatchdogMetricHeap, Value: ms.HeapAlloc, Threshold: cfg.MaxHeapBytes})
+		}
+	}
+	if cfg.MaxGoroutines > 0 {
+		if n := runtime.NumGoroutine(); n > cfg.MaxGoroutines {
+			breaches = append(breaches, WatchdogEvent{Metric: WatchdogMetricGoroutines, Value: uint64(n), Threshold: uint64(cfg.MaxGoroutines)})
+		}
+	}
+	if cfg.MaxOpenFiles > 0 {
+		if n, ok := openFileCount(); ok && n > cfg.MaxOpenFiles {
+			breaches = append(breaches, WatchdogEvent{Metric: WatchdogMetricOpenFiles, Value: uint64(n), Threshold: uint64(cfg.MaxOpenFiles)})
+		}
+	}
+
+	if len(breaches) == 0 {
+		state.consecutive = 0
+		return false
+	}
+
+	state.consecutive++
+	restart := cfg.RestartOnBreach && state.consecutive >= cfg.RestartConsecutive
+	for _, event := range breaches {
+		event.Time = now
+		event.Consecutive = state.consecutive
+		event.Restarting = restart
+		if last, ok := state.lastCapture[event.Metric]; !ok || now.Sub(last) >= cfg.Cooldown {
+			state.lastCapture[event.Metric] = now
+			event.Profiles, event.Uploaded = s.captureProfiles(cfg, event.Metric, now)
+		}
+
+		global.LOGGER.WarnKV("看门狗检测到资源超限",
+			"metric", event.Metric,
+			"value", event.Value,
+			"threshold", event.Threshold,
+			"consecutive", event.Consecutive,
+			"profiles", event.Profiles,
+			"restarting", event.Restarting)
+		if cfg.OnBreach != nil {
+			cfg.OnBreach(event)
+		}
+	}
+
+	if restart {
+		// 不在 wg 内执行：Stop 会等待后台协程（包括看门狗自身）退出
+		go s.watchdogRestart(cfg, breaches[0].Metric)
+	}
+	return restart
+}
+
+// captureProfiles 保存 heap 与 goroutine profile，并按配置上传到对象存储
+func (s *Server) captureProfiles(cfg *WatchdogConfig, metric string, now time.Time) (files, uploaded []string) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		global.LOGGER.WarnKV("看门狗创建 profile 目录失败", "dir", cfg.Dir, "error", err)
+		return nil, nil
+	}
+
+	storage := cfg.Storage
+	if storage == nil && cfg.Bucket != "" && global.POOL_MANAGER != nil {
+		storage = global.POOL_MANAGER.GetStorage()
+	}
+
+	stamp := now.Format("20060102-150405")
+	for _, kind := range []string{"heap", "goroutine"} {
+		profile := pprof.Lookup(kind)
+		if profile == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := profile.WriteTo(&buf, 0); err != nil {
+			global.LOGGER.WarnKV("看门狗抓取 profile 失败", "profile", kind, "error", err)
+			continue
+		}
+
+		name := fmt.Sprintf("%s-%s-%s-%d.pb.gz", kind, metric, stamp, os.Getpid())
+		file := filepath.Join(cfg.Dir, name)
+		if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+			global.LOGGER.WarnKV("看门狗保存 profile 失败", "file", file, "error", err)
+			continue
+		}
+		files = append(files, file)
+
+		if storage == nil || cfg.Bucket == "" {
+			continue
+		}
+		key := path.Join(cfg.Prefix, name)
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		_, err := storage.PutObject(ctx, cfg.Bucket, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/octet-stream")
+		cancel()
+		if err != nil {
+			global.LOGGER.WarnKV("看门狗上传 profile 失败", "bucket", cfg.Bucket, "key", key, "error", err)
+			continue
+		}
+		uploaded = append(uploaded, key)
+	}
+	return files, uploaded
+}
+
+// watchdogRestart 受控重启：优雅关闭后以非零退出码退出，由 systemd / Kubernetes 等进程管理器拉起
+func (s *Server) watchdogRestart(cfg *WatchdogConfig, metric string) {
+	global.LOGGER.WarnKV("看门狗持续检测到资源超限，开始受控重启",
+		"metric", metric, "exit_code", cfg.RestartExitCode)
+	if err := s.Stop(); err != nil {
+		global.LOGGER.WithError(err).ErrorMsg("watchdog restart: graceful shutdown failed")
+	}
+	os.Exit(cfg.RestartExitCode)
+}
+
+// openFileCount 当前进程打开的文件描述符数量，仅 Linux 可用
+func openFileCount() (int, bool) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return len(entries), true
+}
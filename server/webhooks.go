@@ -0,0 +1,74 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\server\webhooks.go
+ * @Description: Webhook 投递接入 - 注册管理接口，替换配置时旧投递器在后台发完队列后退出
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kamalyes/go-rpc-gateway/global"
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+	"github.com/kamalyes/go-rpc-gateway/response"
+)
+
+// SetWebhooks 设置出站 Webhook 投递，nil 关闭；通过管理接口添加的订阅与投递记录只保存在内存中，替换配置后不保留
+func (s *Server) SetWebhooks(cfg *middleware.WebhooksConfig) error {
+	if cfg == nil {
+		if old := s.webhooks.Swap(nil); old != nil {
+			go old.Shutdown(context.Background())
+			global.LOGGER.InfoKV("Webhook 投递已关闭")
+		}
+		return nil
+	}
+
+	webhooks, err := middleware.NewWebhookManager(*cfg)
+	if err != nil {
+		return err
+	}
+	if old := s.webhooks.Swap(webhooks); old != nil {
+		go old.Shutdown(context.Background())
+	}
+
+	path := webhooks.AdminPath()
+	s.mu.Lock()
+	s.RegisterHTTPHandlerFunc(path, s.webhookAdminHandler)
+	s.RegisterHTTPHandlerFunc(path+"/", s.webhookAdminHandler)
+	s.mu.Unlock()
+	global.LOGGER.InfoKV("Webhook 投递已启用",
+		"subscriptions", len(cfg.Subscriptions),
+		"max_attempts", cfg.MaxAttempts,
+		"rate_limit", cfg.RateLimit,
+		"admin_path", path)
+	return nil
+}
+
+// GetWebhooks 当前生效的 Webhook 投递器，未配置时返回 nil
+func (s *Server) GetWebhooks() *middleware.WebhookManager {
+	return s.webhooks.Load()
+}
+
+// webhookAdminHandler 管理接口，使用当前生效的投递器
+func (s *Server) webhookAdminHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks := s.webhooks.Load()
+	if webhooks == nil {
+		response.WriteServiceUnavailableResult(w, "webhooks are not configured")
+		return
+	}
+	webhooks.AdminHandler()(w, r)
+}
+
+// stopWebhooks 停止接收事件，在 ctx 结束前发完队列中的投递
+func (s *Server) stopWebhooks(ctx context.Context) {
+	if webhooks := s.webhooks.Swap(nil); webhooks != nil {
+		webhooks.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,28 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\signed_url.go
+ * @Description: 签名 URL 签发入口 - 业务处理器为报表下载、邀请链接等生成临时访问地址
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package gateway
+
+import (
+	"time"
+
+	"github.com/kamalyes/go-rpc-gateway/middleware"
+)
+
+// SignURL 签发临时访问 URL，返回签名后的地址与过期时间，需先通过 WithSignedURL 配置
+//
+// 使用示例:
+//
+//	link, expiresAt, err := gateway.SignURL("https://api.example.com/v1/reports/2026-10.csv",
+//	    middleware.SignedURLOptions{TTL: time.Hour})
+func SignURL(rawURL string, opts middleware.SignedURLOptions) (string, time.Time, error) {
+	return middleware.SignURL(rawURL, opts)
+}
@@ -0,0 +1,194 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\store\cluster.go
+ * @Description: 集群存储 - 基于一致性哈希将 key 分片到多个 Store 后端
+ *               支持 Redis 风格的 hash tag（{...}），保证同一 tag 的 key 落在同一分片
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultVirtualNodes 每个分片默认的虚拟节点数
+const defaultVirtualNodes = 128
+
+// ClusterNode 集群分片节点
+type ClusterNode struct {
+	Name  string // 节点名称（参与哈希，需唯一且稳定）
+	Store Store  // 节点存储后端
+}
+
+// ClusterStore 一致性哈希分片存储
+type ClusterStore struct {
+	nodes  map[string]Store
+	ring   []uint32
+	owners map[uint32]string
+}
+
+// NewClusterStore 创建集群存储
+func NewClusterStore(nodes ...ClusterNode) (*ClusterStore, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("store: cluster requires at least one node")
+	}
+
+	c := &ClusterStore{
+		nodes:  make(map[string]Store, len(nodes)),
+		owners: make(map[uint32]string, len(nodes)*defaultVirtualNodes),
+	}
+	for _, node := range nodes {
+		if node.Name == "" || node.Store == nil {
+			return nil, errors.New("store: cluster node requires name and store")
+		}
+		if _, exists := c.nodes[node.Name]; exists {
+			return nil, fmt.Errorf("store: duplicate cluster node %q", node.Name)
+		}
+		c.nodes[node.Name] = node.Store
+		for i := 0; i < defaultVirtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(node.Name + "#" + strconv.Itoa(i)))
+			if _, taken := c.owners[hash]; taken {
+				continue
+			}
+			c.owners[hash] = node.Name
+			c.ring = append(c.ring, hash)
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	return c, nil
+}
+
+// Node 返回 key 所在的分片
+func (c *ClusterStore) Node(key string) Store {
+	hash := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	idx := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= hash })
+	if idx == len(c.ring) {
+		idx = 0
+	}
+	return c.nodes[c.owners[c.ring[idx]]]
+}
+
+// Get 获取 key 的值
+func (c *ClusterStore) Get(ctx context.Context, key string) (string, error) {
+	return c.Node(key).Get(ctx, key)
+}
+
+// Set 设置 key 的值
+func (c *ClusterStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.Node(key).Set(ctx, key, value, ttl)
+}
+
+// SetNX key 不存在时设置值
+func (c *ClusterStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return c.Node(key).SetNX(ctx, key, value, ttl)
+}
+
+// Incr 原子递增 key 的整数值
+func (c *ClusterStore) Incr(ctx context.Context, key string) (int64, error) {
+	return c.Node(key).Incr(ctx, key)
+}
+
+// Expire 设置 key 的过期时间
+func (c *ClusterStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.Node(key).Expire(ctx, key, ttl)
+}
+
+// Del 删除 key（按分片分组后删除）
+func (c *ClusterStore) Del(ctx context.Context, keys ...string) error {
+	groups := make(map[Store][]string)
+	for _, key := range keys {
+		node := c.Node(key)
+		groups[node] = append(groups[node], key)
+	}
+	for node, group := range groups {
+		if err := node.Del(ctx, group...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Eval 在 key 所在分片执行脚本，所有 key 必须落在同一分片（可使用 hash tag）
+func (c *ClusterStore) Eval(ctx context.Context, script *Script, keys []string, args ...any) (any, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("store: cluster eval requires at least one key")
+	}
+	node := c.Node(keys[0])
+	for _, key := range keys[1:] {
+		if c.Node(key) != node {
+			return nil, ErrCrossShard
+		}
+	}
+	return node.Eval(ctx, script, keys, args...)
+}
+
+// DeletePrefix 在所有支持前缀删除的分片上删除指定前缀的 key
+func (c *ClusterStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	deleted := 0
+	for _, node := range c.nodes {
+		deleter, ok := node.(PrefixDeleter)
+		if !ok {
+			continue
+		}
+		n, err := deleter.DeletePrefix(ctx, prefix)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// ScanPrefix 合并所有支持前缀扫描的分片上指定前缀的 key 与值
+func (c *ClusterStore) ScanPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, node := range c.nodes {
+		scanner, ok := node.(PrefixScanner)
+		if !ok {
+			continue
+		}
+		found, err := scanner.ScanPrefix(ctx, prefix)
+		if err != nil {
+			return values, err
+		}
+		maps.Copy(values, found)
+	}
+	return values, nil
+}
+
+// Close 关闭所有分片
+func (c *ClusterStore) Close() error {
+	var errs []error
+	for _, node := range c.nodes {
+		if err := node.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hashTag 提取 key 中的 hash tag（首个非空 {...}），没有时返回完整 key
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
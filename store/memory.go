@@ -0,0 +1,428 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\store\memory.go
+ * @Description: 内嵌内存存储 - 支持过期清理与定期快照落盘，进程重启后可恢复
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryOptions 内嵌存储配置
+type MemoryOptions struct {
+	CleanInterval    time.Duration // 过期 key 清理间隔
+	SnapshotPath     string        // 快照文件路径，为空时不落盘
+	SnapshotInterval time.Duration // 快照间隔，<= 0 时仅在关闭时落盘
+}
+
+// DefaultMemoryOptions 默认内嵌存储配置
+func DefaultMemoryOptions() *MemoryOptions {
+	return &MemoryOptions{
+		CleanInterval:    time.Minute,
+		SnapshotInterval: 30 * time.Second,
+	}
+}
+
+// memoryEntry 内存条目
+type memoryEntry struct {
+	Value    string `json:"value"`
+	ExpireAt int64  `json:"expire_at,omitempty"` // 过期时间（纳秒时间戳），0 表示永不过期
+}
+
+// expired 判断条目是否已过期
+func (e memoryEntry) expired(now int64) bool {
+	return e.ExpireAt > 0 && now >= e.ExpireAt
+}
+
+// MemoryStore 内嵌内存存储
+type MemoryStore struct {
+	mu        sync.Mutex
+	data      map[string]memoryEntry
+	opts      MemoryOptions
+	dirty     bool
+	closed    bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewMemoryStore 创建内嵌存储，配置了快照路径时会先加载已有快照
+func NewMemoryStore(opts *MemoryOptions) (*MemoryStore, error) {
+	if opts == nil {
+		opts = DefaultMemoryOptions()
+	}
+	s := &MemoryStore{
+		data:   make(map[string]memoryEntry),
+		opts:   *opts,
+		stopCh: make(chan struct{}),
+	}
+
+	if s.opts.SnapshotPath != "" {
+		if err := s.loadSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.wg.Add(1)
+	go s.background()
+	return s, nil
+}
+
+// Get 获取 key 的值
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", ErrClosed
+	}
+	return s.cmd().Get(ctx, key)
+}
+
+// Set 设置 key 的值
+func (s *MemoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.cmd().Set(ctx, key, value, ttl)
+}
+
+// SetNX key 不存在时设置值
+func (s *MemoryStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false, ErrClosed
+	}
+	return s.cmd().SetNX(ctx, key, value, ttl)
+}
+
+// Incr 原子递增 key 的整数值，保留原有过期时间
+func (s *MemoryStore) Incr(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	return s.cmd().Incr(ctx, key)
+}
+
+// Expire 设置 key 的过期时间
+func (s *MemoryStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.cmd().Expire(ctx, key, ttl)
+}
+
+// Del 删除 key
+func (s *MemoryStore) Del(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.cmd().Del(ctx, keys...)
+}
+
+// Eval 在存储锁内执行脚本的 Go 实现，保证脚本整体原子
+func (s *MemoryStore) Eval(ctx context.Context, script *Script, keys []string, args ...any) (any, error) {
+	if script == nil || script.fn == nil {
+		return nil, ErrScriptUnsupported
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	return script.fn(ctx, s.cmd(), keys, args)
+}
+
+// DeletePrefix 删除指定前缀的所有 key
+func (s *MemoryStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	deleted := 0
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.data, key)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		s.dirty = true
+	}
+	return deleted, nil
+}
+
+// ScanPrefix 列出指定前缀的未过期 key 与值
+func (s *MemoryStore) ScanPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	now := time.Now().UnixNano()
+	values := make(map[string]string)
+	for key, entry := range s.data {
+		if strings.HasPrefix(key, prefix) && !entry.expired(now) {
+			values[key] = entry.Value
+		}
+	}
+	return values, nil
+}
+
+// Len 返回未过期 key 的数量
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixNano()
+	n := 0
+	for _, entry := range s.data {
+		if !entry.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Close 停止后台任务，并在配置了快照路径时落盘
+func (s *MemoryStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		s.wg.Wait()
+		if s.opts.SnapshotPath != "" {
+			err = s.Snapshot()
+		}
+		s.mu.Lock()
+		s.closed = true
+		s.data = make(map[string]memoryEntry)
+		s.mu.Unlock()
+	})
+	return err
+}
+
+// Snapshot 将当前数据写入快照文件（先写临时文件再原子替换）
+func (s *MemoryStore) Snapshot() error {
+	if s.opts.SnapshotPath == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	now := time.Now().UnixNano()
+	snapshot := make(map[string]memoryEntry, len(s.data))
+	for key, entry := range s.data {
+		if !entry.expired(now) {
+			snapshot[key] = entry
+		}
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("store: marshal snapshot: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.opts.SnapshotPath), 0o755); err != nil {
+		return fmt.Errorf("store: create snapshot dir: %w", err)
+	}
+	tmp := s.opts.SnapshotPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("store: write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, s.opts.SnapshotPath); err != nil {
+		return fmt.Errorf("store: replace snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadSnapshot 从快照文件恢复数据，文件不存在时忽略
+func (s *MemoryStore) loadSnapshot() error {
+	data, err := os.ReadFile(s.opts.SnapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("store: read snapshot: %w", err)
+	}
+
+	snapshot := make(map[string]memoryEntry)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("store: unmarshal snapshot: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	for key, entry := range snapshot {
+		if !entry.expired(now) {
+			s.data[key] = entry
+		}
+	}
+	return nil
+}
+
+// cmd 返回不加锁的命令视图（调用方需持有锁）
+func (s *MemoryStore) cmd() memoryCommands {
+	return memoryCommands{s: s}
+}
+
+// memoryCommands 不加锁的内存命令实现，供公开方法与脚本共用
+type memoryCommands struct {
+	s *MemoryStore
+}
+
+// lookup 查找未过期的条目，已过期的条目会被惰性删除
+func (c memoryCommands) lookup(key string) (memoryEntry, bool) {
+	entry, ok := c.s.data[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if entry.expired(time.Now().UnixNano()) {
+		delete(c.s.data, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// Get 获取 key 的值
+func (c memoryCommands) Get(ctx context.Context, key string) (string, error) {
+	entry, ok := c.lookup(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return entry.Value, nil
+}
+
+// Set 设置 key 的值
+func (c memoryCommands) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.s.data[key] = memoryEntry{Value: value, ExpireAt: expireAt(ttl)}
+	c.s.dirty = true
+	return nil
+}
+
+// SetNX key 不存在时设置值
+func (c memoryCommands) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if _, ok := c.lookup(key); ok {
+		return false, nil
+	}
+	return true, c.Set(ctx, key, value, ttl)
+}
+
+// Incr 递增 key 的整数值，保留原有过期时间
+func (c memoryCommands) Incr(ctx context.Context, key string) (int64, error) {
+	entry, ok := c.lookup(key)
+	var n int64
+	if ok {
+		v, err := strconv.ParseInt(entry.Value, 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		n = v
+	}
+	n++
+	entry.Value = strconv.FormatInt(n, 10)
+	c.s.data[key] = entry
+	c.s.dirty = true
+	return n, nil
+}
+
+// Expire 设置 key 的过期时间
+func (c memoryCommands) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	entry, ok := c.lookup(key)
+	if !ok {
+		return nil
+	}
+	if ttl <= 0 {
+		delete(c.s.data, key)
+	} else {
+		entry.ExpireAt = expireAt(ttl)
+		c.s.data[key] = entry
+	}
+	c.s.dirty = true
+	return nil
+}
+
+// Del 删除 key
+func (c memoryCommands) Del(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(c.s.data, key)
+	}
+	c.s.dirty = true
+	return nil
+}
+
+// background 定期清理过期 key 并按需写快照
+func (s *MemoryStore) background() {
+	defer s.wg.Done()
+
+	cleanInterval := s.opts.CleanInterval
+	if cleanInterval <= 0 {
+		cleanInterval = time.Minute
+	}
+	cleanTicker := time.NewTicker(cleanInterval)
+	defer cleanTicker.Stop()
+
+	var snapshotC <-chan time.Time
+	if s.opts.SnapshotPath != "" && s.opts.SnapshotInterval > 0 {
+		snapshotTicker := time.NewTicker(s.opts.SnapshotInterval)
+		defer snapshotTicker.Stop()
+		snapshotC = snapshotTicker.C
+	}
+
+	for {
+		select {
+		case <-cleanTicker.C:
+			s.evictExpired()
+		case <-snapshotC:
+			s.mu.Lock()
+			dirty := s.dirty
+			s.mu.Unlock()
+			if dirty {
+				_ = s.Snapshot()
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// evictExpired 清理所有已过期的 key
+func (s *MemoryStore) evictExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixNano()
+	for key, entry := range s.data {
+		if entry.expired(now) {
+			delete(s.data, key)
+		}
+	}
+}
+
+// expireAt 根据 ttl 计算过期时间戳
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
@@ -0,0 +1,242 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\store\redis.go
+ * @Description: 基于 Redis 的 Store 实现
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package store
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于 Redis 的存储，支持单机、哨兵与 Redis Cluster（redis.UniversalClient）
+// 连接由 PoolManager 管理，Close 不会关闭底层客户端
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore 创建 Redis 存储
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Client 返回底层 Redis 客户端
+func (r *RedisStore) Client() redis.UniversalClient {
+	return r.client
+}
+
+// Get 获取 key 的值
+func (r *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	v, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return v, err
+}
+
+// Set 设置 key 的值
+func (r *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, redisTTL(ttl)).Err()
+}
+
+// SetNX key 不存在时设置值
+func (r *RedisStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, redisTTL(ttl)).Result()
+}
+
+// Incr 原子递增 key 的整数值
+func (r *RedisStore) Incr(ctx context.Context, key string) (int64, error) {
+	return r.client.Incr(ctx, key).Result()
+}
+
+// Expire 设置 key 的过期时间
+func (r *RedisStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return r.client.Del(ctx, key).Err()
+	}
+	return r.client.Expire(ctx, key, ttl).Err()
+}
+
+// Del 删除 key
+func (r *RedisStore) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// Eval 执行脚本的 Lua 实现（EVALSHA 失败时自动回退 EVAL）
+func (r *RedisStore) Eval(ctx context.Context, script *Script, keys []string, args ...any) (any, error) {
+	if script == nil || script.redis == nil {
+		return nil, ErrScriptUnsupported
+	}
+	result, err := script.redis.Run(ctx, r.client, keys, args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return result, err
+}
+
+// DeletePrefix 使用 SCAN 分批删除指定前缀的 key，避免 KEYS 阻塞
+// Redis Cluster 下会遍历所有主节点，并逐个 key 删除以避免 CROSSSLOT
+func (r *RedisStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return scanDelete(ctx, r.client, prefix+"*", false)
+	}
+
+	var (
+		mu      sync.Mutex
+		deleted int
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := scanDelete(ctx, node, prefix+"*", true)
+		mu.Lock()
+		deleted += n
+		mu.Unlock()
+		return err
+	})
+	return deleted, err
+}
+
+// scanDelete 扫描匹配 pattern 的 key 并分批删除，perKey 为 true 时通过 pipeline 逐个删除
+func scanDelete(ctx context.Context, client redis.UniversalClient, pattern string, perKey bool) (int, error) {
+	deleted := 0
+	batch := make([]string, 0, 100)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var err error
+		if perKey {
+			_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range batch {
+					pipe.Del(ctx, key)
+				}
+				return nil
+			})
+		} else {
+			err = client.Del(ctx, batch...).Err()
+		}
+		if err != nil {
+			return err
+		}
+		deleted += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
+// ScanPrefix 使用 SCAN 列出指定前缀的 key，再分批读取值；扫描与读取之间过期的 key 不出现在结果中
+// Redis Cluster 下会遍历所有主节点，并逐个 key 读取以避免 CROSSSLOT
+func (r *RedisStore) ScanPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		values := make(map[string]string)
+		return values, scanValues(ctx, r.client, prefix+"*", false, values)
+	}
+
+	var (
+		mu     sync.Mutex
+		values = make(map[string]string)
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		found := make(map[string]string)
+		err := scanValues(ctx, node, prefix+"*", true, found)
+		mu.Lock()
+		maps.Copy(values, found)
+		mu.Unlock()
+		return err
+	})
+	return values, err
+}
+
+// scanValues 扫描匹配 pattern 的 key 并分批读取值写入 values，perKey 为 true 时通过 pipeline 逐个读取
+func scanValues(ctx context.Context, client redis.UniversalClient, pattern string, perKey bool, values map[string]string) error {
+	batch := make([]string, 0, 100)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if perKey {
+			cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range batch {
+					pipe.Get(ctx, key)
+				}
+				return nil
+			})
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			for i, cmd := range cmds {
+				if v, err := cmd.(*redis.StringCmd).Result(); err == nil {
+					values[batch[i]] = v
+				}
+			}
+		} else {
+			result, err := client.MGet(ctx, batch...).Result()
+			if err != nil {
+				return err
+			}
+			for i, v := range result {
+				if s, ok := v.(string); ok {
+					values[batch[i]] = s
+				}
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Close Redis 连接由 PoolManager 统一关闭，此处不做处理
+func (r *RedisStore) Close() error {
+	return nil
+}
+
+// redisTTL 将 ttl <= 0 统一转换为永不过期
+func redisTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
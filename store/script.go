@@ -0,0 +1,85 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\store\script.go
+ * @Description: 跨后端原子脚本 - 同一逻辑同时提供 Lua 与 Go 两种实现
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScriptFunc 脚本的 Go 实现，cmd 上的操作与脚本整体在同一原子上下文中执行
+type ScriptFunc func(ctx context.Context, cmd Commands, keys []string, args []any) (any, error)
+
+// Script 跨后端原子脚本
+// Redis 后端执行 Lua（EVALSHA 失败时自动回退 EVAL），内存等后端执行 Go 实现
+// 两种实现的返回值需保持一致（整数统一返回 int64）
+type Script struct {
+	lua   string
+	fn    ScriptFunc
+	redis *redis.Script
+}
+
+// NewScript 创建脚本，lua 与 fn 至少提供一个
+func NewScript(lua string, fn ScriptFunc) *Script {
+	s := &Script{lua: lua, fn: fn}
+	if lua != "" {
+		s.redis = redis.NewScript(lua)
+	}
+	return s
+}
+
+// Lua 返回 Lua 源码
+func (s *Script) Lua() string {
+	return s.lua
+}
+
+// Func 返回 Go 实现
+func (s *Script) Func() ScriptFunc {
+	return s.fn
+}
+
+// ArgInt64 将脚本参数转换为 int64，便于 Go 实现与 Lua 的 tonumber 保持一致
+func ArgInt64(arg any) (int64, error) {
+	switch v := arg.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("store: unsupported script arg type %T", arg)
+	}
+}
+
+// ArgString 将脚本参数转换为字符串
+func ArgString(arg any) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
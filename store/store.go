@@ -0,0 +1,77 @@
+/*
+ * @Author: kamalyes 501893067@qq.com
+ * @Date: 2026-10-16 00:00:00
+ * @LastEditors: kamalyes 501893067@qq.com
+ * @LastEditTime: 2026-10-16 00:00:00
+ * @FilePath: \go-rpc-gateway\store\store.go
+ * @Description: 中间件状态存储抽象 - 限流、配额、缓存、幂等、会话等模块共用
+ *               内置 Redis、内存、集群（一致性哈希分片）三种实现，业务方可实现 Store 接入自定义后端
+ *
+ * Copyright (c) 2026 by kamalyes, All Rights Reserved.
+ */
+
+// Package store 提供中间件状态（限流计数、Nonce、幂等键、会话等）的统一存储接口
+// 单节点部署未配置 Redis 时使用内嵌存储，功能保持完整
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound key 不存在或已过期
+	ErrNotFound = errors.New("store: key not found")
+	// ErrNotInteger 对非整数值执行 Incr
+	ErrNotInteger = errors.New("store: value is not an integer")
+	// ErrClosed 存储已关闭
+	ErrClosed = errors.New("store: closed")
+	// ErrScriptUnsupported 当前后端无法执行该脚本（缺少 Lua 或 Go 实现）
+	ErrScriptUnsupported = errors.New("store: script not supported by backend")
+	// ErrCrossShard 脚本涉及的 key 分布在不同分片
+	ErrCrossShard = errors.New("store: keys span multiple shards")
+)
+
+// Commands 基础键值命令，语义与 Redis 对应命令保持一致
+// 脚本的 Go 实现通过 Commands 在同一原子上下文内访问数据
+type Commands interface {
+	// Get 获取 key 的值，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (string, error)
+
+	// Set 设置 key 的值，ttl <= 0 表示永不过期
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// SetNX key 不存在时设置值，返回是否设置成功
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+
+	// Incr 原子递增 key 的整数值，key 不存在时从 0 开始，保留原有过期时间
+	Incr(ctx context.Context, key string) (int64, error)
+
+	// Expire 设置 key 的过期时间，key 不存在时忽略，ttl <= 0 时删除 key
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+
+	// Del 删除一个或多个 key
+	Del(ctx context.Context, keys ...string) error
+}
+
+// Store 中间件状态存储接口
+type Store interface {
+	Commands
+
+	// Eval 原子执行脚本：Redis 后端执行 Lua，其他后端在存储锁内执行 Go 实现
+	Eval(ctx context.Context, script *Script, keys []string, args ...any) (any, error)
+
+	// Close 关闭存储并释放资源
+	Close() error
+}
+
+// PrefixDeleter 支持按前缀批量删除的存储（可选能力）
+type PrefixDeleter interface {
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// PrefixScanner 支持按前缀列出 key 与值的存储（可选能力），结果不包含已过期的 key
+type PrefixScanner interface {
+	ScanPrefix(ctx context.Context, prefix string) (map[string]string, error)
+}